@@ -0,0 +1,115 @@
+package main
+
+import (
+	"log"
+
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/jwt"
+	"github.com/oleksandr/idp/usecases"
+	"github.com/satori/go.uuid"
+)
+
+const (
+	ephemeralDomain = "localhost"
+	ephemeralUser   = "admin"
+	ephemeralRole   = "admin"
+	ephemeralClient = "ephemeral"
+	ephemeralSPA    = "ephemeral-spa"
+	// ephemeralRedirectURI is a redirect URI of the public client, where a local
+	// single-page app may receive authorization codes
+	ephemeralRedirectURI = "http://localhost:3000/callback"
+	// ephemeralSPEntityID and ephemeralACSURL identify a SAML service provider of a
+	// local app
+	ephemeralSPEntityID = "http://localhost:3000/saml/metadata"
+	ephemeralACSURL     = "http://localhost:3000/saml/acs"
+)
+
+// seedEphemeral creates a domain, an administrator holding all permissions, an OAuth
+// client allowed to use all grants and scopes, a public client of a local single-page
+// app, a SAML service provider of a local app and an active signing key in an empty
+// in-memory storage, so the API can be used right away. The generated password and
+// client secret are logged.
+func seedEphemeral(domainInteractor usecases.DomainInteractor,
+	userInteractor usecases.UserInteractor,
+	rbacInteractor usecases.RBACInteractor,
+	keyInteractor usecases.KeyInteractor,
+	clientInteractor usecases.ClientInteractor,
+	spInteractor usecases.ServiceProviderInteractor) error {
+
+	domain := entities.NewBasicDomain(ephemeralDomain, "Ephemeral development domain")
+	err := domainInteractor.Create(*domain)
+	if err != nil {
+		return err
+	}
+
+	password := uuid.NewV4().String()
+	user := entities.NewBasicUser(ephemeralUser)
+	err = user.SetPassword(password)
+	if err != nil {
+		return err
+	}
+	err = userInteractor.Create(*user, password, []string{domain.ID})
+	if err != nil {
+		return err
+	}
+
+	err = rbacInteractor.CreatePermission(*entities.NewBasicPermission(entities.PermissionWildcard, "All permissions"))
+	if err != nil {
+		return err
+	}
+	err = rbacInteractor.CreateRole(*entities.NewBasicRole(ephemeralRole, "Ephemeral administrator"))
+	if err != nil {
+		return err
+	}
+	err = rbacInteractor.UpdateRoleWithPermissions(ephemeralRole, []string{entities.PermissionWildcard})
+	if err != nil {
+		return err
+	}
+	err = userInteractor.AssignRoles(user.ID, []string{ephemeralRole}, "")
+	if err != nil {
+		return err
+	}
+
+	key, err := keyInteractor.Generate(jwt.RS256)
+	if err != nil {
+		return err
+	}
+	err = keyInteractor.Activate(key.ID)
+	if err != nil {
+		return err
+	}
+
+	client := entities.NewClient(ephemeralClient, domain.ID)
+	client.GrantTypes = []string{entities.GrantTypeClientCredentials, entities.GrantTypePassword, entities.GrantTypeRefreshToken}
+	client.Scopes = []string{entities.PermissionWildcard}
+	secret, err := client.GenerateSecret()
+	if err != nil {
+		return err
+	}
+	err = clientInteractor.Create(*client)
+	if err != nil {
+		return err
+	}
+
+	spa := entities.NewClient(ephemeralSPA, domain.ID)
+	spa.GrantTypes = []string{entities.GrantTypeAuthorizationCode, entities.GrantTypeRefreshToken}
+	spa.Scopes = []string{entities.PermissionWildcard}
+	spa.RedirectURIs = []string{ephemeralRedirectURI}
+	err = clientInteractor.Create(*spa)
+	if err != nil {
+		return err
+	}
+
+	sp := entities.NewServiceProvider(ephemeralClient, domain.ID, ephemeralSPEntityID, ephemeralACSURL)
+	err = spInteractor.Create(*sp)
+	if err != nil {
+		return err
+	}
+
+	log.Println("Running with in-memory storage, nothing will be persisted")
+	log.Printf("Log in to domain %v as %v with password %v", ephemeralDomain, ephemeralUser, password)
+	log.Printf("Authenticate OAuth client %v with secret %v", client.ID, secret)
+	log.Printf("Authorize public OAuth client %v to redirect to %v", spa.ID, ephemeralRedirectURI)
+	log.Printf("Sign in to SAML service provider %v at %v", ephemeralSPEntityID, ephemeralACSURL)
+	return nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"log"
 	"os"
 	"os/signal"
@@ -8,40 +9,185 @@ import (
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
-	//_ "github.com/lib/pq"  # <-- need more testing
-	//_ "github.com/mattn/go-sqlite3"  # <-- temporary disabled. need more testing
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
 	"github.com/oleksandr/idp/config"
 	"github.com/oleksandr/idp/db"
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/kv"
+	"github.com/oleksandr/idp/memory"
 	"github.com/oleksandr/idp/usecases"
+	"github.com/satori/go.uuid"
 )
 
 func main() {
 	log.SetPrefix("[main] ")
 
-	dbmap, err := db.InitDB(os.Getenv(config.EnvIDPDriver), os.Getenv(config.EnvIDPDSN))
-	if err != nil {
-		log.Fatalln(err.Error())
-	}
-	defer dbmap.Db.Close()
-	if config.SQLTraceOn() {
-		dbmap.TraceOn("", log.New(os.Stderr, "[gorp] ", log.LstdFlags))
+	ephemeral := flag.Bool("ephemeral", false, "Keep all data in memory only (development mode)")
+	flag.Parse()
+
+	var (
+		domains     usecases.DomainRepository
+		users       usecases.UserRepository
+		sessions    usecases.SessionRepository
+		roles       usecases.RoleRepository
+		permissions usecases.PermissionRepository
+		keys        usecases.KeyRepository
+		clients     usecases.ClientRepository
+		sps         usecases.ServiceProviderRepository
+		mfa         usecases.MFARepository
+		webAuthn    usecases.WebAuthnRepository
+		lockout     usecases.LockoutRepository
+		policies    usecases.PasswordPolicyRepository
+	)
+	if *ephemeral {
+		store := memory.NewStore()
+		domains = &memory.DomainRepository{Store: store}
+		users = &memory.UserRepository{Store: store}
+		sessions = &memory.SessionRepository{Store: store}
+		roles = &memory.RoleRepository{Store: store}
+		permissions = &memory.PermissionRepository{Store: store}
+		keys = &memory.KeyRepository{Store: store}
+		clients = &memory.ClientRepository{Store: store}
+		sps = &memory.ServiceProviderRepository{Store: store}
+		mfa = &memory.MFARepository{Store: store}
+		webAuthn = &memory.WebAuthnRepository{Store: store}
+		lockout = &memory.LockoutRepository{Store: store}
+		policies = &memory.PasswordPolicyRepository{Store: store}
+	} else {
+		dbmap, err := db.InitDB(os.Getenv(config.EnvIDPDriver), os.Getenv(config.EnvIDPDSN))
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		defer dbmap.Db.Close()
+		if config.SQLTraceOn() {
+			dbmap.TraceOn("", log.New(os.Stderr, "[gorp] ", log.LstdFlags))
+		}
+		err = dbmap.Db.Ping()
+		if err != nil {
+			log.Fatalln("Failed to connect to DB:", err.Error())
+		}
+		err = db.CheckSchemaVersion(dbmap)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		domains = &db.DomainRepository{DBMap: dbmap}
+		users = &db.UserRepository{DBMap: dbmap}
+		sessions = &db.SessionRepository{DBMap: dbmap}
+		roles = &db.RoleRepository{DBMap: dbmap}
+		permissions = &db.PermissionRepository{DBMap: dbmap}
+		keys = &db.KeyRepository{DBMap: dbmap}
+		clients = &db.ClientRepository{DBMap: dbmap}
+		sps = &db.ServiceProviderRepository{DBMap: dbmap}
+		mfa = &db.MFARepository{DBMap: dbmap}
+		webAuthn = &db.WebAuthnRepository{DBMap: dbmap}
+		lockout = &db.LockoutRepository{DBMap: dbmap}
+		policies = &db.PasswordPolicyRepository{DBMap: dbmap}
 	}
-	err = dbmap.Db.Ping()
-	if err != nil {
-		log.Fatalln("Failed to connect to DB:", err.Error())
+	if url := config.SessionStore(); url != "" {
+		store, err := kv.Open(url)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		err = store.Ping()
+		if err != nil {
+			log.Fatalln("Failed to connect to session store:", err.Error())
+		}
+		sessions = &kv.SessionRepository{Store: store}
 	}
 
 	//
 	// Core setup
 	//
 	domainInteractor := new(usecases.DomainInteractorImpl)
-	domainInteractor.DBMap = dbmap
+	domainInteractor.Domains = domains
+	domainInteractor.Sessions = sessions
 	userInteractor := new(usecases.UserInteractorImpl)
-	userInteractor.DBMap = dbmap
+	userInteractor.Users = users
+	userInteractor.Roles = roles
+	userInteractor.Sessions = sessions
 	sessionInteractor := new(usecases.SessionInteractorImpl)
-	sessionInteractor.DBMap = dbmap
+	sessionInteractor.Domains = domains
+	sessionInteractor.Users = users
+	sessionInteractor.Sessions = sessions
 	rbacInteractor := new(usecases.RBACInteractorImpl)
-	rbacInteractor.DBMap = dbmap
+	rbacInteractor.Roles = roles
+	rbacInteractor.Permissions = permissions
+	keyInteractor := new(usecases.KeyInteractorImpl)
+	keyInteractor.Keys = keys
+	keyInteractor.Secret = []byte(config.KeysSecret())
+	if *ephemeral && len(keyInteractor.Secret) == 0 {
+		keyInteractor.Secret = []byte(uuid.NewV4().String())
+	}
+	mfaInteractor := new(usecases.MFAInteractorImpl)
+	mfaInteractor.MFA = mfa
+	mfaInteractor.Users = users
+	mfaInteractor.Secret = keyInteractor.Secret
+	sessionInteractor.MFA = mfaInteractor
+	webAuthnInteractor := new(usecases.WebAuthnInteractorImpl)
+	webAuthnInteractor.WebAuthn = webAuthn
+	webAuthnInteractor.Domains = domains
+	webAuthnInteractor.Users = users
+	sessionInteractor.WebAuthn = webAuthnInteractor
+	lockoutInteractor := new(usecases.LockoutInteractorImpl)
+	lockoutInteractor.Lockout = lockout
+	lockoutInteractor.Users = users
+	lockoutInteractor.Thresholds = map[string]int{
+		entities.LockoutScopeUser:    config.LockoutUserThreshold(),
+		entities.LockoutScopeAddress: config.LockoutAddressThreshold(),
+		entities.LockoutScopeDomain:  config.LockoutDomainThreshold(),
+	}
+	lockoutInteractor.Delay = time.Duration(config.LockoutDelaySeconds()) * time.Second
+	lockoutInteractor.Duration = time.Duration(config.LockoutDurationMinutes()) * time.Minute
+	sessionInteractor.Lockout = lockoutInteractor
+	mfaInteractor.Lockout = lockoutInteractor
+	passwordPolicyInteractor := new(usecases.PasswordPolicyInteractorImpl)
+	passwordPolicyInteractor.Policies = policies
+	passwordPolicyInteractor.Domains = domains
+	passwordPolicyInteractor.Users = users
+	blocklist, err := passwordBlocklist()
+	if err != nil {
+		log.Fatalln("Failed to load password blocklist:", err.Error())
+	}
+	passwordPolicyInteractor.Blocklist = blocklist
+	userInteractor.Passwords = passwordPolicyInteractor
+	sessionInteractor.Passwords = passwordPolicyInteractor
+	tokenInteractor := new(usecases.TokenInteractorImpl)
+	tokenInteractor.RBAC = rbacInteractor
+	tokenInteractor.Keys = keyInteractor
+	clientInteractor := new(usecases.ClientInteractorImpl)
+	clientInteractor.Clients = clients
+	clientInteractor.Permissions = permissions
+	clientInteractor.Sessions = sessions
+	oauthInteractor := new(usecases.OAuthInteractorImpl)
+	oauthInteractor.Clients = clients
+	oauthInteractor.Domains = domains
+	oauthInteractor.Sessions = sessionInteractor
+	oauthInteractor.Users = userInteractor
+	oauthInteractor.RBAC = rbacInteractor
+	oauthInteractor.Tokens = tokenInteractor
+	spInteractor := new(usecases.ServiceProviderInteractorImpl)
+	spInteractor.ServiceProviders = sps
+	samlInteractor := new(usecases.SAMLInteractorImpl)
+	samlInteractor.ServiceProviders = sps
+	samlInteractor.Domains = domains
+	samlInteractor.Sessions = sessionInteractor
+	samlInteractor.RBAC = rbacInteractor
+	samlInteractor.Keys = keyInteractor
+	if config.JWTKey() != "" {
+		key, err := signingKey()
+		if err != nil {
+			log.Fatalln("Failed to load access token signing key:", err.Error())
+		}
+		tokenInteractor.Key = key
+	}
+
+	if *ephemeral {
+		err := seedEphemeral(domainInteractor, userInteractor, rbacInteractor, keyInteractor, clientInteractor, spInteractor)
+		if err != nil {
+			log.Fatalln("Failed to seed in-memory storage:", err.Error())
+		}
+	}
 
 	//
 	// Start the servers and GC
@@ -51,7 +197,13 @@ func main() {
 		domainInteractor,
 		userInteractor,
 		sessionInteractor,
-		rbacInteractor)
+		rbacInteractor,
+		tokenInteractor,
+		keyInteractor,
+		oauthInteractor,
+		samlInteractor,
+		webAuthnInteractor,
+		passwordPolicyInteractor)
 	go startRPCServer(exitCh,
 		domainInteractor,
 		userInteractor,
@@ -61,8 +213,9 @@ func main() {
 		for {
 			select {
 			case <-time.Tick(time.Duration(30) * time.Minute):
-				log.Println("Purging sessions...")
+				log.Println("Purging sessions and refresh tokens...")
 				sessionInteractor.Purge()
+				oauthInteractor.Purge()
 			}
 		}
 
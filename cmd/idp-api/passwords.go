@@ -0,0 +1,22 @@
+package main
+
+import (
+	"os"
+
+	"github.com/oleksandr/idp/config"
+	"github.com/oleksandr/idp/entities"
+)
+
+// passwordBlocklist loads a list of passwords which are too common to be used as
+// configured by environment variables. There is none unless configured.
+func passwordBlocklist() (entities.PasswordBlocklist, error) {
+	if config.PasswordBlocklist() == "" {
+		return nil, nil
+	}
+	f, err := os.Open(config.PasswordBlocklist())
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return entities.NewPasswordBlocklist(f)
+}
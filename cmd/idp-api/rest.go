@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/gorilla/context"
+	"github.com/julienschmidt/httprouter"
 	"github.com/justinas/alice"
 	"github.com/oleksandr/idp/config"
 	"github.com/oleksandr/idp/usecases"
@@ -20,17 +21,51 @@ func startRESTfulServer(exitCh chan bool,
 	domainInteractor usecases.DomainInteractor,
 	userInteractor usecases.UserInteractor,
 	sessionInteractor usecases.SessionInteractor,
-	rbacInteractor usecases.RBACInteractor) {
+	rbacInteractor usecases.RBACInteractor,
+	tokenInteractor usecases.TokenInteractor,
+	keyInteractor usecases.KeyInteractor,
+	oauthInteractor usecases.OAuthInteractor,
+	samlInteractor usecases.SAMLInteractor,
+	webAuthnInteractor usecases.WebAuthnInteractor,
+	passwordPolicyInteractor usecases.PasswordPolicyInteractor) {
 
 	// Web handlers
 	sessionHandler := web.NewSessionWebHandler()
 	sessionHandler.SessionInteractor = sessionInteractor
 	sessionHandler.UserInteractor = userInteractor
 	sessionHandler.DomainInteractor = domainInteractor
+	sessionHandler.TokenInteractor = tokenInteractor
+	sessionHandler.WebAuthnInteractor = webAuthnInteractor
 
 	rbacHandler := web.NewRBACWebHandler()
 	rbacHandler.RBACInteractor = rbacInteractor
 
+	domainHandler := web.NewDomainWebHandler()
+	domainHandler.DomainInteractor = domainInteractor
+
+	passwordPolicyHandler := web.NewPasswordPolicyWebHandler()
+	passwordPolicyHandler.PasswordPolicyInteractor = passwordPolicyInteractor
+
+	userHandler := web.NewUserWebHandler()
+	userHandler.UserInteractor = userInteractor
+
+	credentialHandler := web.NewCredentialWebHandler()
+	credentialHandler.SessionInteractor = sessionInteractor
+	credentialHandler.WebAuthnInteractor = webAuthnInteractor
+
+	keyHandler := web.NewKeyWebHandler()
+	keyHandler.KeyInteractor = keyInteractor
+
+	oauthHandler := web.NewOAuthWebHandler()
+	oauthHandler.OAuthInteractor = oauthInteractor
+
+	openIDHandler := web.NewOpenIDWebHandler()
+	openIDHandler.OAuthInteractor = oauthInteractor
+	openIDHandler.KeyInteractor = keyInteractor
+
+	samlHandler := web.NewSAMLWebHandler()
+	samlHandler.SAMLInteractor = samlInteractor
+
 	//
 	// Middleware chain (mind the order!)
 	//
@@ -53,6 +88,27 @@ func startRESTfulServer(exitCh chan bool,
 		web.JSONRenderingHandler,
 		tokenAuthHandler, // always check if request is authenticated
 	)
+	// OAuth endpoints take form encoded requests and authenticate clients themselves
+	oauthChain := alice.New(
+		context.ClearHandler,
+		web.LoggingHandler,
+		web.RecoverHandler,
+		web.NewContentTypeHandler("application/x-www-form-urlencoded"),
+		web.InfoHeadersHandler,
+		web.JSONRenderingHandler,
+	)
+	// The authorization and SAML single sign-on endpoints render HTML pages
+	authorizeChain := alice.New(
+		context.ClearHandler,
+		web.LoggingHandler,
+		web.RecoverHandler,
+		web.NewContentTypeHandler("application/x-www-form-urlencoded"),
+		web.InfoHeadersHandler,
+	)
+	// Protected chain extended with a permission check
+	permittedChain := func(permission string) alice.Chain {
+		return protectedChain.Append(web.NewAuthorizationHandler(rbacInteractor, permission))
+	}
 
 	//
 	// Routing setup
@@ -60,34 +116,112 @@ func startRESTfulServer(exitCh chan bool,
 	router := newRouter()
 
 	// Domain API
-	/*
-	   router.post("/domains", protectedChain.ThenFunc(domainHandler.Create))
-	   router.get("/domains/:id", protectedChain.ThenFunc(domainHandler.Retrieve))
-	   router.get("/domains", protectedChain.ThenFunc(domainHandler.List))
-	   router.patch("/domains/:id", protectedChain.ThenFunc(domainHandler.Modify))
-	   router.delete("/domains/:id", protectedChain.ThenFunc(domainHandler.Delete))
-	*/
+	router.post(versionedRoute("/domains"), permittedChain("domains.create").ThenFunc(domainHandler.Create))
+	router.get(versionedRoute("/domains/:id"), permittedChain("domains.read").ThenFunc(domainHandler.Retrieve))
+	router.get(versionedRoute("/domains"), permittedChain("domains.read").ThenFunc(domainHandler.List))
+	router.patch(versionedRoute("/domains/:id"), permittedChain("domains.update").ThenFunc(domainHandler.Update))
+	router.delete(versionedRoute("/domains/:id"), permittedChain("domains.delete").ThenFunc(domainHandler.Delete))
+	router.get(versionedRoute("/users/:id/domains"), permittedChain("domains.read").ThenFunc(domainHandler.ListByUser))
+	router.get(versionedRoute("/domains/:id/password-policy"), permittedChain("domains.read").ThenFunc(passwordPolicyHandler.Retrieve))
+	router.put(versionedRoute("/domains/:id/password-policy"), permittedChain("domains.update").ThenFunc(passwordPolicyHandler.Save))
+	router.delete(versionedRoute("/domains/:id/password-policy"), permittedChain("domains.update").ThenFunc(passwordPolicyHandler.Delete))
+
 	// Users API
-	/*
-	   router.post("/users", protectedChain.ThenFunc(userHandler.Create))
-	   router.get("/users/:id", protectedChain.ThenFunc(userHandler.Retrieve))
-	   router.get("/users", protectedChain.ThenFunc(userHandler.List))
-	   router.patch("/users/:id", protectedChain.ThenFunc(userHandler.Modify))
-	   router.delete("/users/:id", protectedChain.ThenFunc(userHandler.Delete))
-	*/
+	router.post(versionedRoute("/users"), permittedChain("users.create").ThenFunc(userHandler.Create))
+	router.get(versionedRoute("/users/:id"), permittedChain("users.read").ThenFunc(userHandler.Retrieve))
+	router.get(versionedRoute("/users"), permittedChain("users.read").ThenFunc(userHandler.List))
+	router.patch(versionedRoute("/users/:id"), permittedChain("users.update").ThenFunc(userHandler.Update))
+	router.delete(versionedRoute("/users/:id"), permittedChain("users.delete").ThenFunc(userHandler.Delete))
+	router.put(versionedRoute("/users/:id/roles/:role"), permittedChain("users.update").ThenFunc(userHandler.AssignRole))
+	router.delete(versionedRoute("/users/:id/roles/:role"), permittedChain("users.update").ThenFunc(userHandler.RevokeRole))
+	router.get(versionedRoute("/domains/:id/users"), permittedChain("users.read").ThenFunc(userHandler.ListByDomain))
+	router.get(versionedRoute("/domains/:id/users/:name"), permittedChain("users.read").ThenFunc(userHandler.RetrieveByNameInDomain))
+
+	// WebAuthn credentials API (of the current user only, i.e. /users/current/credentials)
+	router.post(versionedRoute("/users/:id/credentials/options"), protectedChain.ThenFunc(credentialHandler.Options))
+	router.post(versionedRoute("/users/:id/credentials"), protectedChain.ThenFunc(credentialHandler.Create))
+	router.get(versionedRoute("/users/:id/credentials"), protectedChain.ThenFunc(credentialHandler.List))
+	router.delete(versionedRoute("/users/:id/credentials/:credential"), protectedChain.ThenFunc(credentialHandler.Delete))
 
 	// Sessions API
 	router.post(versionedRoute("/sessions"), publicChain.ThenFunc(sessionHandler.Create))
 	router.head(versionedRoute("/sessions/current"), protectedChain.ThenFunc(sessionHandler.Check))
 	router.get(versionedRoute("/sessions/current"), protectedChain.ThenFunc(sessionHandler.Retrieve))
 	router.delete(versionedRoute("/sessions/current"), protectedChain.ThenFunc(sessionHandler.Delete))
+	// httprouter doesn't allow a parameter next to a static path segment, so issuing a
+	// token, completing a challenge (POST /sessions/:id/mfa) and the WebAuthn ceremony
+	// (POST /sessions/webauthn, POST /sessions/webauthn/assertion) share routes
+	issueTokenHandler := protectedChain.ThenFunc(sessionHandler.IssueToken)
+	completeMFAHandler := publicChain.ThenFunc(sessionHandler.CompleteMFA)
+	beginWebAuthnHandler := publicChain.ThenFunc(sessionHandler.BeginWebAuthn)
+	completeWebAuthnHandler := publicChain.ThenFunc(sessionHandler.CompleteWebAuthn)
+	router.post(versionedRoute("/sessions/:id"), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := context.Get(r, config.CtxParamsKey).(httprouter.Params)
+		if params.ByName("id") != "webauthn" {
+			http.NotFound(w, r)
+			return
+		}
+		beginWebAuthnHandler.ServeHTTP(w, r)
+	}))
+	router.post(versionedRoute("/sessions/:id/:action"), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := context.Get(r, config.CtxParamsKey).(httprouter.Params)
+		switch {
+		case params.ByName("id") == "current" && params.ByName("action") == "token":
+			issueTokenHandler.ServeHTTP(w, r)
+		case params.ByName("id") == "webauthn" && params.ByName("action") == "assertion":
+			completeWebAuthnHandler.ServeHTTP(w, r)
+		case params.ByName("id") != "current" && params.ByName("action") == "mfa":
+			completeMFAHandler.ServeHTTP(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	router.get(versionedRoute("/sessions/current/roles"), protectedChain.ThenFunc(rbacHandler.ListEffectiveRoles))
+	router.get(versionedRoute("/sessions/current/permissions"), protectedChain.ThenFunc(rbacHandler.ListEffectivePermissions))
+
+	// Roles API
+	router.post(versionedRoute("/roles"), permittedChain("roles.create").ThenFunc(rbacHandler.CreateRole))
+	router.get(versionedRoute("/roles/:name"), permittedChain("roles.read").ThenFunc(rbacHandler.RetrieveRole))
+	router.get(versionedRoute("/roles"), permittedChain("roles.read").ThenFunc(rbacHandler.ListRoles))
+	router.patch(versionedRoute("/roles/:name"), permittedChain("roles.update").ThenFunc(rbacHandler.UpdateRole))
+	router.delete(versionedRoute("/roles/:name"), permittedChain("roles.delete").ThenFunc(rbacHandler.DeleteRole))
+	router.get(versionedRoute("/roles/:name/permissions"), permittedChain("roles.read").ThenFunc(rbacHandler.ListRolePermissions))
+	router.put(versionedRoute("/roles/:name/permissions/:permission"), permittedChain("roles.update").ThenFunc(rbacHandler.AddRolePermission))
+	router.delete(versionedRoute("/roles/:name/permissions/:permission"), permittedChain("roles.update").ThenFunc(rbacHandler.RemoveRolePermission))
+	router.get(versionedRoute("/users/:id/roles"), permittedChain("roles.read").ThenFunc(rbacHandler.ListRolesByUser))
+
+	// Permissions API
+	router.post(versionedRoute("/permissions"), permittedChain("permissions.create").ThenFunc(rbacHandler.CreatePermission))
+	router.get(versionedRoute("/permissions/:name"), permittedChain("permissions.read").ThenFunc(rbacHandler.RetrievePermission))
+	router.get(versionedRoute("/permissions"), permittedChain("permissions.read").ThenFunc(rbacHandler.ListPermissions))
+	router.patch(versionedRoute("/permissions/:name"), permittedChain("permissions.update").ThenFunc(rbacHandler.UpdatePermission))
+	router.delete(versionedRoute("/permissions/:name"), permittedChain("permissions.delete").ThenFunc(rbacHandler.DeletePermission))
 
 	// RBAC API
 	router.head(versionedRoute("/assert/role/:role"), protectedChain.ThenFunc(rbacHandler.AssertRole))
 	router.head(versionedRoute("/assert/permission/:permission"), protectedChain.ThenFunc(rbacHandler.AssertPermission))
 
+	// OAuth 2.0 API
+	router.get("/oauth/authorize", authorizeChain.ThenFunc(oauthHandler.Authorize))
+	router.post("/oauth/authorize", authorizeChain.ThenFunc(oauthHandler.Login))
+	router.post("/oauth/token", oauthChain.ThenFunc(oauthHandler.Token))
+	router.post("/oauth/revoke", oauthChain.ThenFunc(oauthHandler.Revoke))
+	router.post("/oauth/introspect", oauthChain.ThenFunc(oauthHandler.Introspect))
+
+	// OpenID Connect API
+	router.get("/.well-known/openid-configuration", publicChain.ThenFunc(openIDHandler.Configuration))
+	router.get("/userinfo", oauthChain.ThenFunc(openIDHandler.UserInfo))
+	router.post("/userinfo", oauthChain.ThenFunc(openIDHandler.UserInfo))
+
+	// SAML 2.0 API
+	router.get("/saml/metadata", authorizeChain.ThenFunc(samlHandler.Metadata))
+	router.get("/saml/sso", authorizeChain.ThenFunc(samlHandler.SSO))
+	router.post("/saml/sso", authorizeChain.ThenFunc(samlHandler.SSOPost))
+	router.post("/saml/login", authorizeChain.ThenFunc(samlHandler.Login))
+
 	// Utilities
 	router.get("/", publicChain.ThenFunc(web.IndexHandler))
+	router.get("/.well-known/jwks.json", publicChain.ThenFunc(keyHandler.JWKS))
 
 	//
 	// Make a HTTP Server structure using our custom handler/router
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/oleksandr/idp/config"
+	"github.com/oleksandr/idp/jwt"
+)
+
+// minHMACSecretLength is the shortest HS256 secret accepted (as long as its hash)
+const minHMACSecretLength = 32
+
+// signingKey loads a key access tokens are signed with as configured by
+// environment variables
+func signingKey() (*jwt.Key, error) {
+	switch config.JWTAlgorithm() {
+	case jwt.HS256:
+		secret := []byte(config.JWTKey())
+		if len(secret) < minHMACSecretLength {
+			return nil, fmt.Errorf("%v secret must be at least %v bytes long", jwt.HS256, minHMACSecretLength)
+		}
+		return jwt.NewHMACKey(config.JWTKeyID(), secret), nil
+	case jwt.RS256:
+		data, err := ioutil.ReadFile(config.JWTKey())
+		if err != nil {
+			return nil, err
+		}
+		private, err := jwt.ParseRSAPrivateKey(data)
+		if err != nil {
+			return nil, err
+		}
+		return jwt.NewRSAKey(config.JWTKeyID(), private), nil
+	}
+	return nil, fmt.Errorf("Unsupported algorithm %q", config.JWTAlgorithm())
+}
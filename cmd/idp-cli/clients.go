@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/codegangsta/cli"
+	"github.com/oleksandr/idp/entities"
+)
+
+func listClients(c *cli.Context) {
+	clients, err := clientInteractor.List(c.String("domain"))
+	assertError(err)
+
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 0, 8, 1, '\t', 0)
+	fmt.Fprintln(w, "ID\tNAME\tDOMAIN\tENABLED\tPUBLIC\tGRANT TYPES\tSCOPES\tREDIRECT URIS")
+	fmt.Fprintln(w, "---\t\t\t\t\t\t\t")
+	for _, cl := range clients {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n", cl.ID, cl.Name, cl.DomainID, cl.Enabled, cl.IsPublic(),
+			strings.Join(cl.GrantTypes, ","), strings.Join(cl.Scopes, ","), strings.Join(cl.RedirectURIs, ","))
+	}
+	w.Flush()
+}
+
+func addClient(c *cli.Context) {
+	if c.String("domain") == "" {
+		assertError(fmt.Errorf("You need to specify domain ID using --domain option"))
+	}
+	if c.String("name") == "" {
+		assertError(fmt.Errorf("You need to specify name using --name option"))
+	}
+
+	cl := entities.NewClient(c.String("name"), c.String("domain"))
+	cl.GrantTypes = c.StringSlice("grant-type")
+	if len(cl.GrantTypes) == 0 {
+		cl.GrantTypes = []string{entities.GrantTypeClientCredentials}
+	}
+	cl.Scopes = c.StringSlice("scope")
+	cl.RedirectURIs = c.StringSlice("redirect-uri")
+	cl.Enabled = !c.Bool("disable")
+	secret := ""
+	if !c.Bool("public") {
+		var err error
+		secret, err = cl.GenerateSecret()
+		assertError(err)
+	}
+
+	err := clientInteractor.Create(*cl)
+	assertError(err)
+
+	fmt.Printf("Client %v created\n", cl.ID)
+	if secret != "" {
+		fmt.Printf("Secret: %v\n", secret)
+	}
+}
+
+func removeClient(c *cli.Context) {
+	if !c.Args().Present() {
+		assertError(fmt.Errorf("You need to provide an ID of the client"))
+	}
+	err := clientInteractor.Delete(c.Args().First())
+	assertError(err)
+	fmt.Printf("Client %v deleted\n", c.Args().First())
+}
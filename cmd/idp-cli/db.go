@@ -2,8 +2,11 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"text/tabwriter"
 
 	"github.com/codegangsta/cli"
+	"github.com/oleksandr/idp/db"
 )
 
 func truncateTables(c *cli.Context) {
@@ -21,11 +24,63 @@ func dropTables(c *cli.Context) {
 	}
 	err := dbmap.DropTablesIfExists()
 	assertError(err)
+	err = db.DropMigrationsTable(dbmap)
+	assertError(err)
 	fmt.Println("Done")
 }
 
 func createTables(c *cli.Context) {
-	err := dbmap.CreateTables()
+	migrations, err := db.MigrateUp(dbmap, 0)
+	printMigrations("Applied", migrations)
 	assertError(err)
 	fmt.Println("Done")
 }
+
+func migrateUp(c *cli.Context) {
+	migrations, err := db.MigrateUp(dbmap, c.Int("steps"))
+	printMigrations("Applied", migrations)
+	assertError(err)
+	if len(migrations) == 0 {
+		fmt.Println("Schema is up to date")
+	}
+}
+
+func migrateDown(c *cli.Context) {
+	if !c.Bool("please") {
+		assertError(fmt.Errorf("Reverting migrations may destroy data. Say --please"))
+	}
+	migrations, err := db.MigrateDown(dbmap, c.Int("steps"))
+	printMigrations("Reverted", migrations)
+	assertError(err)
+	if len(migrations) == 0 {
+		fmt.Println("Nothing to revert")
+	}
+}
+
+func migrationStatus(c *cli.Context) {
+	statuses, err := db.ListMigrations(dbmap)
+	assertError(err)
+
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 0, 8, 4, '\t', 0)
+	fmt.Fprintln(w, "VERSION\tDESCRIPTION\tAPPLIED ON")
+	fmt.Fprintln(w, "---\t\t")
+	for _, s := range statuses {
+		appliedOn := "pending"
+		if s.AppliedOn != nil {
+			appliedOn = s.AppliedOn.Format("2006-01-02 15:04:05 MST")
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\n", s.Version, s.Description, appliedOn)
+	}
+	w.Flush()
+
+	version, err := db.SchemaVersion(dbmap)
+	assertError(err)
+	fmt.Printf("Schema version %v of %v\n", version, db.LatestSchemaVersion())
+}
+
+func printMigrations(action string, migrations []db.Migration) {
+	for _, m := range migrations {
+		fmt.Printf("%v migration %v: %v\n", action, m.Version, m.Description)
+	}
+}
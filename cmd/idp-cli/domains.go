@@ -23,8 +23,8 @@ func listDomains(c *cli.Context) {
 
 	w := new(tabwriter.Writer)
 	w.Init(os.Stdout, 0, 8, 1, '\t', 0)
-	fmt.Fprintln(w, "ID\tNAME\tENABLED\tUSERS\tDESCRIPTION")
-	fmt.Fprintln(w, "---\t\t\t\t")
+	fmt.Fprintln(w, "ID\tNAME\tENABLED\tMFA REQUIRED\tUSERS\tDESCRIPTION")
+	fmt.Fprintln(w, "---\t\t\t\t\t")
 
 	for {
 		if c.String("user") != "" {
@@ -34,7 +34,7 @@ func listDomains(c *cli.Context) {
 		}
 		assertError(err)
 		for _, d := range collection.Domains {
-			fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", d.ID, d.Name, d.Enabled, d.UsersCount, d.Description)
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\n", d.ID, d.Name, d.Enabled, d.MFARequired, d.UsersCount, d.Description)
 		}
 		w.Flush()
 		if !paginator.HasNextPage {
@@ -56,9 +56,9 @@ func findDomain(c *cli.Context) {
 
 	w := new(tabwriter.Writer)
 	w.Init(os.Stdout, 0, 8, 1, '\t', 0)
-	fmt.Fprintln(w, "ID\tNAME\tENABLED\tUSERS\tDESCRIPTION")
-	fmt.Fprintln(w, "---\t\t\t\t")
-	fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", d.ID, d.Name, d.Enabled, count, d.Description)
+	fmt.Fprintln(w, "ID\tNAME\tENABLED\tMFA REQUIRED\tUSERS\tDESCRIPTION")
+	fmt.Fprintln(w, "---\t\t\t\t\t")
+	fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\n", d.ID, d.Name, d.Enabled, d.MFARequired, count, d.Description)
 	w.Flush()
 }
 
@@ -68,6 +68,7 @@ func addDomain(c *cli.Context) {
 	}
 	d := entities.NewBasicDomain(c.String("name"), c.String("description"))
 	d.Enabled = !c.Bool("disable")
+	d.MFARequired = c.Bool("require-mfa")
 	err := domainInteractor.Create(*d)
 	assertError(err)
 	fmt.Printf("Domain %v created\n", d.ID)
@@ -80,6 +81,9 @@ func updateDomain(c *cli.Context) {
 	if c.Bool("enable") && c.Bool("disable") {
 		assertError(fmt.Errorf("You can provide either --enable or --disable, but not both at the same time"))
 	}
+	if c.Bool("require-mfa") && c.Bool("optional-mfa") {
+		assertError(fmt.Errorf("You can provide either --require-mfa or --optional-mfa, but not both at the same time"))
+	}
 
 	d, err := domainInteractor.Find(c.Args().First())
 	assertError(err)
@@ -96,6 +100,12 @@ func updateDomain(c *cli.Context) {
 	if c.Bool("disable") {
 		d.Enabled = false
 	}
+	if c.Bool("require-mfa") {
+		d.MFARequired = true
+	}
+	if c.Bool("optional-mfa") {
+		d.MFARequired = false
+	}
 
 	err = domainInteractor.Update(*d)
 	assertError(err)
@@ -116,3 +126,48 @@ func removeDomain(c *cli.Context) {
 
 	fmt.Printf("Domain %v deleted\n", d.ID)
 }
+
+func showPasswordPolicy(c *cli.Context) {
+	if !c.Args().Present() {
+		assertError(fmt.Errorf("You need to provide an ID of the domain"))
+	}
+	p, err := policyInteractor.Find(c.Args().First())
+	assertError(err)
+
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 0, 8, 1, '\t', 0)
+	fmt.Fprintln(w, "MIN LENGTH\tLOWER\tUPPER\tDIGIT\tSYMBOL\tBLOCKLIST\tHISTORY\tMAX AGE DAYS")
+	fmt.Fprintln(w, "---\t\t\t\t\t\t\t")
+	fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n", p.MinLength, p.RequireLower, p.RequireUpper,
+		p.RequireDigit, p.RequireSymbol, p.Blocklist, p.History, p.MaxAgeDays)
+	w.Flush()
+}
+
+func setPasswordPolicy(c *cli.Context) {
+	if !c.Args().Present() {
+		assertError(fmt.Errorf("You need to provide an ID of the domain"))
+	}
+	p := entities.PasswordPolicy{
+		DomainID:      c.Args().First(),
+		MinLength:     c.Int("min-length"),
+		RequireLower:  c.Bool("require-lower"),
+		RequireUpper:  c.Bool("require-upper"),
+		RequireDigit:  c.Bool("require-digit"),
+		RequireSymbol: c.Bool("require-symbol"),
+		Blocklist:     c.Bool("blocklist"),
+		History:       c.Int("history"),
+		MaxAgeDays:    c.Int("max-age-days"),
+	}
+	err := policyInteractor.Save(p)
+	assertError(err)
+	fmt.Printf("Password policy of domain %v set\n", p.DomainID)
+}
+
+func removePasswordPolicy(c *cli.Context) {
+	if !c.Args().Present() {
+		assertError(fmt.Errorf("You need to provide an ID of the domain"))
+	}
+	err := policyInteractor.Delete(c.Args().First())
+	assertError(err)
+	fmt.Printf("Password policy of domain %v removed\n", c.Args().First())
+}
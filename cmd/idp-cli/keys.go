@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/codegangsta/cli"
+	"github.com/oleksandr/idp/entities"
+)
+
+func listKeys(c *cli.Context) {
+	keys, err := keyInteractor.List()
+	assertError(err)
+
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 0, 8, 1, '\t', 0)
+	fmt.Fprintln(w, "ID\tALGORITHM\tSTATUS\tCREATED\tACTIVATED\tRETIRED")
+	fmt.Fprintln(w, "---\t\t\t\t\t")
+	for _, k := range keys {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\n", k.ID, k.Algorithm, k.Status,
+			formatKeyTime(k.CreatedOn), formatKeyTime(k.ActivatedOn), formatKeyTime(k.RetiredOn))
+	}
+	w.Flush()
+}
+
+func generateKey(c *cli.Context) {
+	k, err := keyInteractor.Generate(c.String("algorithm"))
+	assertError(err)
+	fmt.Printf("Key %v generated\n", k.ID)
+}
+
+func activateKey(c *cli.Context) {
+	if !c.Args().Present() {
+		assertError(fmt.Errorf("You need to provide an ID of the key"))
+	}
+	err := keyInteractor.Activate(c.Args().First())
+	assertError(err)
+	fmt.Printf("Key %v activated\n", c.Args().First())
+}
+
+func retireKey(c *cli.Context) {
+	if !c.Args().Present() {
+		assertError(fmt.Errorf("You need to provide an ID of the key"))
+	}
+	err := keyInteractor.Retire(c.Args().First())
+	assertError(err)
+	fmt.Printf("Key %v retired\n", c.Args().First())
+}
+
+func formatKeyTime(t entities.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
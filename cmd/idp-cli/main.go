@@ -4,24 +4,34 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/codegangsta/cli"
 	_ "github.com/go-sql-driver/mysql"
-	//_ "github.com/lib/pq"  # <-- need more testing
-	//_ "github.com/mattn/go-sqlite3"  # <-- temporary disabled. need more testing
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
 	"github.com/oleksandr/idp/config"
 	"github.com/oleksandr/idp/db"
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/kv"
 	"github.com/oleksandr/idp/usecases"
 	"gopkg.in/gorp.v1"
 )
 
 var (
-	dbmap             *gorp.DbMap
-	err               error
-	domainInteractor  *usecases.DomainInteractorImpl
-	userInteractor    *usecases.UserInteractorImpl
-	rbacInteractor    *usecases.RBACInteractorImpl
-	sessionInteractor *usecases.SessionInteractorImpl
+	dbmap              *gorp.DbMap
+	err                error
+	domainInteractor   *usecases.DomainInteractorImpl
+	userInteractor     *usecases.UserInteractorImpl
+	rbacInteractor     *usecases.RBACInteractorImpl
+	sessionInteractor  *usecases.SessionInteractorImpl
+	keyInteractor      *usecases.KeyInteractorImpl
+	clientInteractor   *usecases.ClientInteractorImpl
+	spInteractor       *usecases.ServiceProviderInteractorImpl
+	mfaInteractor      *usecases.MFAInteractorImpl
+	webAuthnInteractor *usecases.WebAuthnInteractorImpl
+	lockoutInteractor  *usecases.LockoutInteractorImpl
+	policyInteractor   *usecases.PasswordPolicyInteractorImpl
 )
 
 func main() {
@@ -45,14 +55,73 @@ func main() {
 	}
 
 	// Interactors
+	domains := &db.DomainRepository{DBMap: dbmap}
+	users := &db.UserRepository{DBMap: dbmap}
+	roles := &db.RoleRepository{DBMap: dbmap}
+	var sessions usecases.SessionRepository = &db.SessionRepository{DBMap: dbmap}
+	if url := config.SessionStore(); url != "" {
+		store, err := kv.Open(url)
+		assertError(err)
+		sessions = &kv.SessionRepository{Store: store}
+	}
 	domainInteractor = new(usecases.DomainInteractorImpl)
-	domainInteractor.DBMap = dbmap
+	domainInteractor.Domains = domains
+	domainInteractor.Sessions = sessions
 	userInteractor = new(usecases.UserInteractorImpl)
-	userInteractor.DBMap = dbmap
+	userInteractor.Users = users
+	userInteractor.Roles = roles
+	userInteractor.Sessions = sessions
 	sessionInteractor = new(usecases.SessionInteractorImpl)
-	sessionInteractor.DBMap = dbmap
+	sessionInteractor.Domains = domains
+	sessionInteractor.Users = users
+	sessionInteractor.Sessions = sessions
 	rbacInteractor = new(usecases.RBACInteractorImpl)
-	rbacInteractor.DBMap = dbmap
+	rbacInteractor.Roles = roles
+	rbacInteractor.Permissions = &db.PermissionRepository{DBMap: dbmap}
+	keyInteractor = new(usecases.KeyInteractorImpl)
+	keyInteractor.Keys = &db.KeyRepository{DBMap: dbmap}
+	keyInteractor.Secret = []byte(config.KeysSecret())
+	clientInteractor = new(usecases.ClientInteractorImpl)
+	clientInteractor.Clients = &db.ClientRepository{DBMap: dbmap}
+	clientInteractor.Permissions = rbacInteractor.Permissions
+	clientInteractor.Sessions = sessions
+	spInteractor = new(usecases.ServiceProviderInteractorImpl)
+	spInteractor.ServiceProviders = &db.ServiceProviderRepository{DBMap: dbmap}
+	mfaInteractor = new(usecases.MFAInteractorImpl)
+	mfaInteractor.MFA = &db.MFARepository{DBMap: dbmap}
+	mfaInteractor.Users = users
+	mfaInteractor.Secret = keyInteractor.Secret
+	sessionInteractor.MFA = mfaInteractor
+	webAuthnInteractor = new(usecases.WebAuthnInteractorImpl)
+	webAuthnInteractor.WebAuthn = &db.WebAuthnRepository{DBMap: dbmap}
+	webAuthnInteractor.Domains = domains
+	webAuthnInteractor.Users = users
+	sessionInteractor.WebAuthn = webAuthnInteractor
+	lockoutInteractor = new(usecases.LockoutInteractorImpl)
+	lockoutInteractor.Lockout = &db.LockoutRepository{DBMap: dbmap}
+	lockoutInteractor.Users = users
+	lockoutInteractor.Thresholds = map[string]int{
+		entities.LockoutScopeUser:    config.LockoutUserThreshold(),
+		entities.LockoutScopeAddress: config.LockoutAddressThreshold(),
+		entities.LockoutScopeDomain:  config.LockoutDomainThreshold(),
+	}
+	lockoutInteractor.Delay = time.Duration(config.LockoutDelaySeconds()) * time.Second
+	lockoutInteractor.Duration = time.Duration(config.LockoutDurationMinutes()) * time.Minute
+	sessionInteractor.Lockout = lockoutInteractor
+	mfaInteractor.Lockout = lockoutInteractor
+	policyInteractor = new(usecases.PasswordPolicyInteractorImpl)
+	policyInteractor.Policies = &db.PasswordPolicyRepository{DBMap: dbmap}
+	policyInteractor.Domains = domains
+	policyInteractor.Users = users
+	if path := config.PasswordBlocklist(); path != "" {
+		f, err := os.Open(path)
+		assertError(err)
+		policyInteractor.Blocklist, err = entities.NewPasswordBlocklist(f)
+		f.Close()
+		assertError(err)
+	}
+	userInteractor.Passwords = policyInteractor
+	sessionInteractor.Passwords = policyInteractor
 
 	app.Commands = []cli.Command{
 		{
@@ -83,9 +152,48 @@ func main() {
 				},
 				{
 					Name:   "create",
-					Usage:  "Creates all tables",
+					Usage:  "Creates all tables by applying all pending migrations",
 					Action: createTables,
 				},
+				{
+					Name:  "migrate",
+					Usage: "Manage schema migrations",
+					Subcommands: []cli.Command{
+						{
+							Name:   "up",
+							Usage:  "Applies pending migrations",
+							Action: migrateUp,
+							Flags: []cli.Flag{
+								cli.IntFlag{
+									Name:  "steps",
+									Value: 0,
+									Usage: "Number of migrations to apply (0 for all)",
+								},
+							},
+						},
+						{
+							Name:   "down",
+							Usage:  "Reverts applied migrations",
+							Action: migrateDown,
+							Flags: []cli.Flag{
+								cli.IntFlag{
+									Name:  "steps",
+									Value: 1,
+									Usage: "Number of migrations to revert (0 for all)",
+								},
+								cli.BoolFlag{
+									Name:  "please",
+									Usage: "Ask nicely",
+								},
+							},
+						},
+						{
+							Name:   "status",
+							Usage:  "Prints applied and pending migrations",
+							Action: migrationStatus,
+						},
+					},
+				},
 			},
 		},
 		{
@@ -125,6 +233,10 @@ func main() {
 							Name:  "disable",
 							Usage: "Disable domain",
 						},
+						cli.BoolFlag{
+							Name:  "require-mfa",
+							Usage: "Require multi-factor authentication of users",
+						},
 					},
 				},
 				{
@@ -148,6 +260,14 @@ func main() {
 							Name:  "disable",
 							Usage: "Disable domain",
 						},
+						cli.BoolFlag{
+							Name:  "require-mfa",
+							Usage: "Require multi-factor authentication of users",
+						},
+						cli.BoolFlag{
+							Name:  "optional-mfa",
+							Usage: "Make multi-factor authentication optional",
+						},
 					},
 				},
 				{
@@ -161,6 +281,61 @@ func main() {
 						},
 					},
 				},
+				{
+					Name:  "policy",
+					Usage: "Manage password policies of domains",
+					Subcommands: []cli.Command{
+						{
+							Name:   "show",
+							Usage:  "Print a password policy of a domain by given ID",
+							Action: showPasswordPolicy,
+						},
+						{
+							Name:   "set",
+							Usage:  "Set a password policy of a domain by given ID replacing the existing one",
+							Action: setPasswordPolicy,
+							Flags: []cli.Flag{
+								cli.IntFlag{
+									Name:  "min-length",
+									Usage: "Minimum number of characters",
+								},
+								cli.BoolFlag{
+									Name:  "require-lower",
+									Usage: "Require a lower case letter",
+								},
+								cli.BoolFlag{
+									Name:  "require-upper",
+									Usage: "Require an upper case letter",
+								},
+								cli.BoolFlag{
+									Name:  "require-digit",
+									Usage: "Require a digit",
+								},
+								cli.BoolFlag{
+									Name:  "require-symbol",
+									Usage: "Require a symbol",
+								},
+								cli.BoolFlag{
+									Name:  "blocklist",
+									Usage: "Reject passwords listed in the blocklist file (see " + config.EnvIDPPasswordBlocklist + ")",
+								},
+								cli.IntFlag{
+									Name:  "history",
+									Usage: "Number of recent passwords which cannot be reused",
+								},
+								cli.IntFlag{
+									Name:  "max-age-days",
+									Usage: "Number of days passwords expire after",
+								},
+							},
+						},
+						{
+							Name:   "remove",
+							Usage:  "Remove a password policy of a domain by given ID",
+							Action: removePasswordPolicy,
+						},
+					},
+				},
 			},
 		},
 		{
@@ -232,6 +407,10 @@ func main() {
 							Usage: "Role to remove",
 							Value: &cli.StringSlice{},
 						},
+						cli.StringFlag{
+							Name:  "role-domain",
+							Usage: "Domain ID to scope assigned/revoked roles to (global if omitted)",
+						},
 						cli.StringFlag{
 							Name:  "password",
 							Usage: "New password",
@@ -251,6 +430,43 @@ func main() {
 					Usage:  "Remove an existing user",
 					Action: removeUser,
 				},
+				{
+					Name:  "mfa",
+					Usage: "Manage multi-factor authentication of users",
+					Subcommands: []cli.Command{
+						{
+							Name:   "enroll",
+							Usage:  "Enroll a user by given ID and print the TOTP secret and recovery codes",
+							Action: enrollUserMFA,
+						},
+						{
+							Name:   "reset",
+							Usage:  "Reset enrollment of a user by given ID",
+							Action: resetUserMFA,
+						},
+					},
+				},
+				{
+					Name:  "credentials",
+					Usage: "Manage WebAuthn credentials (passkeys) of users",
+					Subcommands: []cli.Command{
+						{
+							Name:   "list",
+							Usage:  "List credentials of a user by given ID",
+							Action: listUserCredentials,
+						},
+						{
+							Name:   "remove",
+							Usage:  "Remove a credential of a user by given user ID and credential ID",
+							Action: removeUserCredential,
+						},
+					},
+				},
+				{
+					Name:   "unlock",
+					Usage:  "Unlock a user locked out after failed attempts to sign in by given ID",
+					Action: unlockUser,
+				},
 			},
 		},
 		{
@@ -351,6 +567,16 @@ func main() {
 							Usage: "Array of permissions to remove",
 							Value: &cli.StringSlice{},
 						},
+						cli.StringSliceFlag{
+							Name:  "inherit",
+							Usage: "Array of junior roles to inherit permissions from",
+							Value: &cli.StringSlice{},
+						},
+						cli.StringSliceFlag{
+							Name:  "uninherit",
+							Usage: "Array of junior roles to stop inheriting",
+							Value: &cli.StringSlice{},
+						},
 						cli.StringFlag{
 							Name:  "name",
 							Usage: "New role's name",
@@ -374,6 +600,37 @@ func main() {
 					Usage:  "Remove an existing role",
 					Action: removeRole,
 				},
+				{
+					Name:   "list-constraints",
+					Usage:  "List separation of duty constraints",
+					Action: listConstraints,
+				},
+				{
+					Name:   "add-constraint",
+					Usage:  "Add a separation of duty constraint (a user may hold at most --max of given roles)",
+					Action: addConstraint,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "name",
+							Usage: "Constraint name (e.g. audit)",
+						},
+						cli.StringSliceFlag{
+							Name:  "role",
+							Usage: "Mutually exclusive role (at least 2)",
+							Value: &cli.StringSlice{},
+						},
+						cli.IntFlag{
+							Name:  "max",
+							Value: 1,
+							Usage: "Maximum number of given roles a user may hold",
+						},
+					},
+				},
+				{
+					Name:   "remove-constraint",
+					Usage:  "Remove a separation of duty constraint by given name",
+					Action: removeConstraint,
+				},
 			},
 		},
 		{
@@ -449,6 +706,148 @@ func main() {
 				},
 			},
 		},
+		{
+			Name:  "clients",
+			Usage: "Manage OAuth clients",
+			Subcommands: []cli.Command{
+				{
+					Name:   "list",
+					Usage:  "List existing clients",
+					Action: listClients,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "domain",
+							Usage: "Filter clients by given domain ID",
+						},
+					},
+				},
+				{
+					Name:   "add",
+					Usage:  "Register a new client, its secret (unless it's public) is printed once",
+					Action: addClient,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "domain",
+							Usage: "Domain ID to register client in",
+						},
+						cli.StringFlag{
+							Name:  "name",
+							Usage: "Client name",
+						},
+						cli.StringSliceFlag{
+							Name:  "grant-type",
+							Usage: "Allowed grant type (authorization_code, client_credentials, password or refresh_token)",
+							Value: &cli.StringSlice{},
+						},
+						cli.StringSliceFlag{
+							Name:  "scope",
+							Usage: "Permission the client may request as a scope",
+							Value: &cli.StringSlice{},
+						},
+						cli.StringSliceFlag{
+							Name:  "redirect-uri",
+							Usage: "Redirect URI of the authorization code grant",
+							Value: &cli.StringSlice{},
+						},
+						cli.BoolFlag{
+							Name:  "public",
+							Usage: "Register a public client (e.g. a single-page app) without a secret",
+						},
+						cli.BoolFlag{
+							Name:  "disable",
+							Usage: "Disable client",
+						},
+					},
+				},
+				{
+					Name:   "remove",
+					Usage:  "Remove an existing client along with its refresh tokens and their sessions",
+					Action: removeClient,
+				},
+			},
+		},
+		{
+			Name:  "sps",
+			Usage: "Manage SAML service providers",
+			Subcommands: []cli.Command{
+				{
+					Name:   "list",
+					Usage:  "List existing service providers",
+					Action: listServiceProviders,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "domain",
+							Usage: "Filter service providers by given domain ID",
+						},
+					},
+				},
+				{
+					Name:   "add",
+					Usage:  "Register a new service provider",
+					Action: addServiceProvider,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "domain",
+							Usage: "Domain ID to register service provider in",
+						},
+						cli.StringFlag{
+							Name:  "name",
+							Usage: "Service provider name",
+						},
+						cli.StringFlag{
+							Name:  "entity-id",
+							Usage: "Entity ID the service provider issues authentication requests with",
+						},
+						cli.StringFlag{
+							Name:  "acs-url",
+							Usage: "Assertion consumer service URL responses are posted to",
+						},
+						cli.BoolFlag{
+							Name:  "disable",
+							Usage: "Disable service provider",
+						},
+					},
+				},
+				{
+					Name:   "remove",
+					Usage:  "Remove an existing service provider",
+					Action: removeServiceProvider,
+				},
+			},
+		},
+		{
+			Name:  "keys",
+			Usage: "Manage access token signing keys",
+			Subcommands: []cli.Command{
+				{
+					Name:   "list",
+					Usage:  "List existing keys",
+					Action: listKeys,
+				},
+				{
+					Name:   "generate",
+					Usage:  "Generate a new key, which is published but doesn't sign tokens until activated",
+					Action: generateKey,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "algorithm",
+							Value: "RS256",
+							Usage: "Key algorithm (RS256 or EdDSA)",
+						},
+					},
+				},
+				{
+					Name:   "activate",
+					Usage:  "Sign new tokens with a key by given ID and retire the active one",
+					Action: activateKey,
+				},
+				{
+					Name:   "retire",
+					Usage:  "Stop signing tokens with a key by given ID",
+					Action: retireKey,
+				},
+			},
+		},
 	}
 
 	app.Run(os.Args)
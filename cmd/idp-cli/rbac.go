@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"strings"
 	"text/tabwriter"
 
 	"github.com/codegangsta/cli"
@@ -198,6 +199,14 @@ func updateRole(c *cli.Context) {
 		err = rbacInteractor.RemovePermissionsFromRole(c.StringSlice("remove"), c.Args().First())
 		assertError(err)
 	}
+	if c.StringSlice("inherit") != nil && len(c.StringSlice("inherit")) > 0 {
+		err = rbacInteractor.InheritRoles(c.Args().First(), c.StringSlice("inherit"))
+		assertError(err)
+	}
+	if c.StringSlice("uninherit") != nil && len(c.StringSlice("uninherit")) > 0 {
+		err = rbacInteractor.UninheritRoles(c.Args().First(), c.StringSlice("uninherit"))
+		assertError(err)
+	}
 
 	r, err := rbacInteractor.FindRole(c.Args().First())
 	assertError(err)
@@ -229,3 +238,53 @@ func removeRole(c *cli.Context) {
 	assertError(err)
 	fmt.Printf("Role %v deleted\n", c.Args().First())
 }
+
+func listConstraints(c *cli.Context) {
+	sorter := entities.Sorter{"name", true}
+	pager := entities.Pager{1, 100}
+
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 0, 8, 4, '\t', 0)
+	fmt.Fprintln(w, "NAME\tMAX\tROLES")
+	fmt.Fprintln(w, "---\t\t")
+
+	for {
+		collection, err := rbacInteractor.ListSoDConstraints(pager, sorter)
+		assertError(err)
+		for _, sod := range collection.Constraints {
+			fmt.Fprintf(w, "%v\t%v\t%v\n", sod.Name, sod.Cardinality, strings.Join(sod.Roles, ", "))
+		}
+		w.Flush()
+		if !collection.Paginator.HasNextPage {
+			fmt.Printf("Page %v of %v (Total records: %v)\n", collection.Paginator.Page, collection.Paginator.TotalPages(), collection.Paginator.Total)
+			break
+		}
+		pager.Page++
+	}
+}
+
+func addConstraint(c *cli.Context) {
+	if c.String("name") == "" {
+		assertError(fmt.Errorf("You need to specify constraint name using --name option"))
+	}
+	if len(c.StringSlice("role")) < 2 {
+		assertError(fmt.Errorf("You need to specify at least 2 roles using --role option"))
+	}
+	sod := entities.SoDConstraint{
+		Name:        c.String("name"),
+		Roles:       c.StringSlice("role"),
+		Cardinality: c.Int("max"),
+	}
+	err := rbacInteractor.CreateSoDConstraint(sod)
+	assertError(err)
+	fmt.Printf("Constraint %v created\n", sod.Name)
+}
+
+func removeConstraint(c *cli.Context) {
+	if !c.Args().Present() {
+		assertError(fmt.Errorf("You need to provide a constraint's name as an argument"))
+	}
+	err := rbacInteractor.DeleteSoDConstraint(c.Args().First())
+	assertError(err)
+	fmt.Printf("Constraint %v deleted\n", c.Args().First())
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/codegangsta/cli"
+	"github.com/oleksandr/idp/entities"
+)
+
+func listServiceProviders(c *cli.Context) {
+	sps, err := spInteractor.List(c.String("domain"))
+	assertError(err)
+
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 0, 8, 1, '\t', 0)
+	fmt.Fprintln(w, "ID\tNAME\tDOMAIN\tENABLED\tENTITY ID\tACS URL")
+	fmt.Fprintln(w, "---\t\t\t\t\t")
+	for _, sp := range sps {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\n", sp.ID, sp.Name, sp.DomainID, sp.Enabled, sp.EntityID, sp.ACSURL)
+	}
+	w.Flush()
+}
+
+func addServiceProvider(c *cli.Context) {
+	if c.String("domain") == "" {
+		assertError(fmt.Errorf("You need to specify domain ID using --domain option"))
+	}
+	if c.String("name") == "" {
+		assertError(fmt.Errorf("You need to specify name using --name option"))
+	}
+	if c.String("entity-id") == "" {
+		assertError(fmt.Errorf("You need to specify entity ID using --entity-id option"))
+	}
+	if c.String("acs-url") == "" {
+		assertError(fmt.Errorf("You need to specify ACS URL using --acs-url option"))
+	}
+
+	sp := entities.NewServiceProvider(c.String("name"), c.String("domain"), c.String("entity-id"), c.String("acs-url"))
+	sp.Enabled = !c.Bool("disable")
+
+	err := spInteractor.Create(*sp)
+	assertError(err)
+
+	fmt.Printf("Service provider %v created\n", sp.ID)
+}
+
+func removeServiceProvider(c *cli.Context) {
+	if !c.Args().Present() {
+		assertError(fmt.Errorf("You need to provide an ID of the service provider"))
+	}
+	err := spInteractor.Delete(c.Args().First())
+	assertError(err)
+	fmt.Printf("Service provider %v deleted\n", c.Args().First())
+}
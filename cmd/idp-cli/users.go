@@ -72,10 +72,11 @@ func addUser(c *cli.Context) {
 		assertError(fmt.Errorf("You need to specify user password --password option"))
 	}
 	u := entities.NewBasicUser(c.String("name"))
-	u.SetPassword(c.String("password"))
+	err := u.SetPassword(c.String("password"))
+	assertError(err)
 	u.Enabled = !c.Bool("disable")
 
-	err := userInteractor.Create(*u, c.StringSlice("domain"))
+	err = userInteractor.Create(*u, c.String("password"), c.StringSlice("domain"))
 	assertError(err)
 	fmt.Printf("User %v created\n", u.ID)
 }
@@ -92,7 +93,8 @@ func updateUser(c *cli.Context) {
 	assertError(err)
 
 	if c.String("password") != "" {
-		u.SetPassword(c.String("password"))
+		err = u.SetPassword(c.String("password"))
+		assertError(err)
 	}
 	if c.Bool("enable") {
 		u.Enabled = true
@@ -101,15 +103,15 @@ func updateUser(c *cli.Context) {
 		u.Enabled = false
 	}
 
-	err = userInteractor.Update(*u, c.StringSlice("add-domain"), c.StringSlice("remove-domain"))
+	err = userInteractor.Update(*u, c.String("password"), c.StringSlice("add-domain"), c.StringSlice("remove-domain"))
 	assertError(err)
 
 	if c.StringSlice("assign-role") != nil && len(c.StringSlice("assign-role")) > 0 {
-		err = userInteractor.AssignRoles(u.ID, c.StringSlice("assign-role"))
+		err = userInteractor.AssignRoles(u.ID, c.StringSlice("assign-role"), c.String("role-domain"))
 		assertError(err)
 	}
 	if c.StringSlice("revoke-role") != nil && len(c.StringSlice("revoke-role")) > 0 {
-		err = userInteractor.RevokeRoles(u.ID, c.StringSlice("revoke-role"))
+		err = userInteractor.RevokeRoles(u.ID, c.StringSlice("revoke-role"), c.String("role-domain"))
 		assertError(err)
 	}
 
@@ -129,3 +131,65 @@ func removeUser(c *cli.Context) {
 
 	fmt.Printf("User %v deleted\n", u.ID)
 }
+
+func enrollUserMFA(c *cli.Context) {
+	if !c.Args().Present() {
+		assertError(fmt.Errorf("You need to provide an ID of the user"))
+	}
+	creds, err := mfaInteractor.Enroll(c.Args().First())
+	assertError(err)
+
+	fmt.Printf("User %v enrolled\n\n", c.Args().First())
+	fmt.Printf("Secret:\t%v\n", creds.Secret)
+	fmt.Printf("URI:\t%v\n\n", creds.URI)
+	fmt.Println("Recovery codes (each can be used once instead of a one-time password):")
+	for _, code := range creds.RecoveryCodes {
+		fmt.Printf("\t%v\n", code)
+	}
+	fmt.Println("\nThe secret and the recovery codes are not shown again.")
+}
+
+func resetUserMFA(c *cli.Context) {
+	if !c.Args().Present() {
+		assertError(fmt.Errorf("You need to provide an ID of the user"))
+	}
+	err := mfaInteractor.Reset(c.Args().First())
+	assertError(err)
+	fmt.Printf("Enrollment of user %v reset\n", c.Args().First())
+}
+
+func listUserCredentials(c *cli.Context) {
+	if !c.Args().Present() {
+		assertError(fmt.Errorf("You need to provide an ID of the user"))
+	}
+	credentials, err := webAuthnInteractor.ListCredentials(c.Args().First())
+	assertError(err)
+
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 0, 8, 1, '\t', 0)
+	fmt.Fprintln(w, "ID\tNAME\tSIGN COUNT\tCREATED\tLAST USED")
+	fmt.Fprintln(w, "---\t\t\t\t")
+	for _, cr := range credentials {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", cr.ID, cr.Name, cr.SignCount,
+			formatKeyTime(cr.CreatedOn), formatKeyTime(cr.LastUsedOn))
+	}
+	w.Flush()
+}
+
+func removeUserCredential(c *cli.Context) {
+	if len(c.Args()) < 2 {
+		assertError(fmt.Errorf("You need to provide an ID of the user and an ID of the credential"))
+	}
+	err := webAuthnInteractor.DeleteCredential(c.Args().First(), c.Args().Get(1))
+	assertError(err)
+	fmt.Printf("Credential %v of user %v removed\n", c.Args().Get(1), c.Args().First())
+}
+
+func unlockUser(c *cli.Context) {
+	if !c.Args().Present() {
+		assertError(fmt.Errorf("You need to provide an ID of the user"))
+	}
+	err := lockoutInteractor.Unlock(c.Args().First())
+	assertError(err)
+	fmt.Printf("User %v unlocked\n", c.Args().First())
+}
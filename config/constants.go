@@ -18,11 +18,65 @@ const (
 	EnvIDPSessionTTL = "IDP_SESSION_TTL"
 	// EnvIDPSecretSalt environment variable
 	EnvIDPSecretSalt = "IDP_SECRET_SALT"
+	// EnvIDPPasswordHasher environment variable
+	EnvIDPPasswordHasher = "IDP_PASSWORD_HASHER"
+	// EnvIDPBcryptCost environment variable
+	EnvIDPBcryptCost = "IDP_BCRYPT_COST"
+	// EnvIDPArgon2Time environment variable
+	EnvIDPArgon2Time = "IDP_ARGON2_TIME"
+	// EnvIDPArgon2Memory environment variable
+	EnvIDPArgon2Memory = "IDP_ARGON2_MEMORY"
+	// EnvIDPArgon2Threads environment variable
+	EnvIDPArgon2Threads = "IDP_ARGON2_THREADS"
 	// EnvIDPSQLTrace environment variable
 	EnvIDPSQLTrace = "IDP_SQL_TRACE"
+	// EnvIDPSessionStore environment variable
+	EnvIDPSessionStore = "IDP_SESSION_STORE"
+	// EnvIDPAccessTokenTTL environment variable
+	EnvIDPAccessTokenTTL = "IDP_ACCESS_TOKEN_TTL"
+	// EnvIDPJWTAlgorithm environment variable
+	EnvIDPJWTAlgorithm = "IDP_JWT_ALGORITHM"
+	// EnvIDPJWTKey environment variable
+	EnvIDPJWTKey = "IDP_JWT_KEY"
+	// EnvIDPJWTKeyID environment variable
+	EnvIDPJWTKeyID = "IDP_JWT_KEY_ID"
+	// EnvIDPJWTIssuer environment variable
+	EnvIDPJWTIssuer = "IDP_JWT_ISSUER"
+	// EnvIDPKeysSecret environment variable
+	EnvIDPKeysSecret = "IDP_KEYS_SECRET"
+	// EnvIDPWebAuthnOrigin environment variable
+	EnvIDPWebAuthnOrigin = "IDP_WEBAUTHN_ORIGIN"
+	// EnvIDPWebAuthnRPID environment variable
+	EnvIDPWebAuthnRPID = "IDP_WEBAUTHN_RP_ID"
+	// EnvIDPLockoutUserThreshold environment variable
+	EnvIDPLockoutUserThreshold = "IDP_LOCKOUT_USER_THRESHOLD"
+	// EnvIDPLockoutAddressThreshold environment variable
+	EnvIDPLockoutAddressThreshold = "IDP_LOCKOUT_ADDRESS_THRESHOLD"
+	// EnvIDPLockoutDomainThreshold environment variable
+	EnvIDPLockoutDomainThreshold = "IDP_LOCKOUT_DOMAIN_THRESHOLD"
+	// EnvIDPLockoutDuration environment variable
+	EnvIDPLockoutDuration = "IDP_LOCKOUT_DURATION"
+	// EnvIDPLockoutDelay environment variable
+	EnvIDPLockoutDelay = "IDP_LOCKOUT_DELAY"
+	// EnvIDPPasswordBlocklist environment variable
+	EnvIDPPasswordBlocklist = "IDP_PASSWORD_BLOCKLIST"
+	// EnvIDPTrustedProxies environment variable
+	EnvIDPTrustedProxies = "IDP_TRUSTED_PROXIES"
 
 	// CtxParamsKey key to store router's params
 	CtxParamsKey = "params"
 	// CtxSessionKey key to store session info
 	CtxSessionKey = "session"
 )
+
+// Bounds of argon2id parameters accepted from environment variables and from
+// stored password hashes. Higher values would let a single hash exhaust memory or
+// CPU on login.
+const (
+	MinArgon2Time    = 1
+	MaxArgon2Time    = 16
+	MinArgon2Memory  = 8
+	MaxArgon2Memory  = 1024 * 1024
+	MinArgon2Threads = 1
+	MaxArgon2Threads = 64
+)
@@ -2,28 +2,68 @@ package config
 
 import (
 	"log"
+	"math"
+	"net"
 	"os"
 	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
 const (
 	defaultSessionTTLMinutes int    = 30
 	defaultHashSecretSalt    string = ""
 	defaultSQLTrace          bool   = false
+	defaultPasswordHasher    string = "argon2id"
+	defaultBcryptCost        int    = 12
+	defaultArgon2Time        int    = 3
+	defaultArgon2Memory      int    = 64 * 1024
+	defaultArgon2Threads     int    = 2
+	defaultAccessTokenTTL    int    = 5
+	defaultJWTAlgorithm      string = "HS256"
+	defaultLockoutUser       int    = 5
+	defaultLockoutAddress    int    = 20
+	defaultLockoutDomain     int    = 0
+	defaultLockoutDuration   int    = 15
+	defaultLockoutDelay      int    = 1
 )
 
 var (
 	sessionTTLMinutes = defaultSessionTTLMinutes
 	hashSecretSalt    = defaultHashSecretSalt
 	traceSQL          = defaultSQLTrace
+	sessionStore      = ""
+	accessTokenTTL    = defaultAccessTokenTTL
+	jwtAlgorithm      = defaultJWTAlgorithm
+	jwtKey            = ""
+	jwtKeyID          = ""
+	jwtIssuer         = ""
+	keysSecret        = ""
+	webAuthnOrigin    = ""
+	webAuthnRPID      = ""
+	passwordHasher    = defaultPasswordHasher
+	bcryptCost        = defaultBcryptCost
+	argon2Time        = defaultArgon2Time
+	argon2Memory      = defaultArgon2Memory
+	argon2Threads     = defaultArgon2Threads
+	lockoutUser       = defaultLockoutUser
+	lockoutAddress    = defaultLockoutAddress
+	lockoutDomain     = defaultLockoutDomain
+	lockoutDuration   = defaultLockoutDuration
+	lockoutDelay      = defaultLockoutDelay
+	passwordBlocklist = ""
+	trustedProxies    = []*net.IPNet{}
 )
 
 func init() {
 	var err error
 
-	sessionTTLMinutes, err = strconv.Atoi(os.Getenv(EnvIDPSessionTTL))
-	if err != nil {
-		log.Fatalf("Failed to read %v: %v", EnvIDPSessionTTL, err.Error())
+	if s := os.Getenv(EnvIDPSessionTTL); s != "" {
+		sessionTTLMinutes, err = strconv.Atoi(s)
+		if err != nil {
+			log.Fatalf("Failed to read %v: %v", EnvIDPSessionTTL, err.Error())
+		}
 	}
 	if sessionTTLMinutes == 0 {
 		sessionTTLMinutes = defaultSessionTTLMinutes
@@ -31,11 +71,92 @@ func init() {
 
 	hashSecretSalt = os.Getenv(EnvIDPSecretSalt)
 
-	traceSQL, err = strconv.ParseBool(os.Getenv(EnvIDPSQLTrace))
-	if err != nil {
-		log.Printf("Failed to read %v: %v", EnvIDPSQLTrace, err.Error())
-		traceSQL = defaultSQLTrace
+	if s := os.Getenv(EnvIDPSQLTrace); s != "" {
+		traceSQL, err = strconv.ParseBool(s)
+		if err != nil {
+			log.Printf("Failed to read %v: %v", EnvIDPSQLTrace, err.Error())
+			traceSQL = defaultSQLTrace
+		}
+	}
+
+	sessionStore = os.Getenv(EnvIDPSessionStore)
+
+	accessTokenTTL = intFromEnv(EnvIDPAccessTokenTTL, defaultAccessTokenTTL)
+	if s := os.Getenv(EnvIDPJWTAlgorithm); s != "" {
+		jwtAlgorithm = s
+	}
+	jwtKey = os.Getenv(EnvIDPJWTKey)
+	jwtKeyID = os.Getenv(EnvIDPJWTKeyID)
+	jwtIssuer = os.Getenv(EnvIDPJWTIssuer)
+	keysSecret = os.Getenv(EnvIDPKeysSecret)
+	webAuthnOrigin = os.Getenv(EnvIDPWebAuthnOrigin)
+	webAuthnRPID = os.Getenv(EnvIDPWebAuthnRPID)
+
+	if s := os.Getenv(EnvIDPPasswordHasher); s != "" {
+		passwordHasher = s
+	}
+	bcryptCost = intRangeFromEnv(EnvIDPBcryptCost, defaultBcryptCost, bcrypt.MinCost, bcrypt.MaxCost)
+	argon2Time = intRangeFromEnv(EnvIDPArgon2Time, defaultArgon2Time, MinArgon2Time, MaxArgon2Time)
+	argon2Memory = intRangeFromEnv(EnvIDPArgon2Memory, defaultArgon2Memory, MinArgon2Memory, MaxArgon2Memory)
+	argon2Threads = intRangeFromEnv(EnvIDPArgon2Threads, defaultArgon2Threads, MinArgon2Threads, MaxArgon2Threads)
+
+	// A threshold of 0 turns a scope off, a delay of 0 leaves only the lockout
+	lockoutUser = intRangeFromEnv(EnvIDPLockoutUserThreshold, defaultLockoutUser, 0, math.MaxInt32)
+	lockoutAddress = intRangeFromEnv(EnvIDPLockoutAddressThreshold, defaultLockoutAddress, 0, math.MaxInt32)
+	lockoutDomain = intRangeFromEnv(EnvIDPLockoutDomainThreshold, defaultLockoutDomain, 0, math.MaxInt32)
+	lockoutDuration = intFromEnv(EnvIDPLockoutDuration, defaultLockoutDuration)
+	lockoutDelay = intRangeFromEnv(EnvIDPLockoutDelay, defaultLockoutDelay, 0, math.MaxInt32)
+
+	passwordBlocklist = os.Getenv(EnvIDPPasswordBlocklist)
+
+	trustedProxies = ipNetsFromEnv(EnvIDPTrustedProxies)
+}
+
+// intFromEnv reads an optional positive integer from a given environment
+// variable falling back to a default value
+func intFromEnv(name string, def int) int {
+	return intRangeFromEnv(name, def, 1, math.MaxInt32)
+}
+
+// intRangeFromEnv reads an optional integer within given bounds from a given
+// environment variable falling back to a default value
+func intRangeFromEnv(name string, def, min, max int) int {
+	s := os.Getenv(name)
+	if s == "" {
+		return def
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < min || v > max {
+		log.Printf("Failed to read %v: invalid value %q", name, s)
+		return def
+	}
+	return v
+}
+
+// ipNetsFromEnv reads an optional comma separated list of IP addresses and CIDR
+// ranges from a given environment variable. Invalid entries are left out.
+func ipNetsFromEnv(name string) []*net.IPNet {
+	nets := []*net.IPNet{}
+	for _, s := range strings.Split(os.Getenv(name), ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if ip := net.ParseIP(s); ip != nil {
+			if ip4 := ip.To4(); ip4 != nil {
+				ip = ip4
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(8*len(ip), 8*len(ip))})
+			continue
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			log.Printf("Failed to read %v: invalid address %q", name, s)
+			continue
+		}
+		nets = append(nets, n)
 	}
+	return nets
 }
 
 // SessionTTLMinutes returns a session TTL duration in minutes read from environment variables
@@ -52,3 +173,119 @@ func HashSecretSalt() string {
 func SQLTraceOn() bool {
 	return traceSQL
 }
+
+// SessionStore returns a URL of a key/value store for sessions ("memory" or
+// "redis://..."), sessions are kept in the database if it's empty
+func SessionStore() string {
+	return sessionStore
+}
+
+// AccessTokenTTLMinutes returns an access token TTL in minutes
+func AccessTokenTTLMinutes() int {
+	return accessTokenTTL
+}
+
+// JWTAlgorithm returns an algorithm access tokens are signed with (HS256 or RS256)
+func JWTAlgorithm() string {
+	return jwtAlgorithm
+}
+
+// JWTKey returns a secret (HS256) or a path to a PEM encoded private key (RS256)
+// access tokens are signed with. Access tokens are disabled if it's empty.
+func JWTKey() string {
+	return jwtKey
+}
+
+// JWTKeyID returns an ID of the signing key put into access tokens' headers
+func JWTKeyID() string {
+	return jwtKeyID
+}
+
+// JWTIssuer returns an issuer put into access tokens
+func JWTIssuer() string {
+	return jwtIssuer
+}
+
+// KeysSecret returns a secret the encryption key of private signing keys is
+// derived from
+func KeysSecret() string {
+	return keysSecret
+}
+
+// WebAuthnOrigin returns an origin of the pages running WebAuthn ceremonies
+func WebAuthnOrigin() string {
+	return webAuthnOrigin
+}
+
+// WebAuthnRPID returns a relying party ID WebAuthn credentials are scoped to
+func WebAuthnRPID() string {
+	return webAuthnRPID
+}
+
+// PasswordHasher returns a name of the algorithm used for hashing new passwords
+// (argon2id or bcrypt)
+func PasswordHasher() string {
+	return passwordHasher
+}
+
+// BcryptCost returns bcrypt's cost parameter
+func BcryptCost() int {
+	return bcryptCost
+}
+
+// Argon2Time returns argon2id's number of passes over the memory
+func Argon2Time() int {
+	return argon2Time
+}
+
+// Argon2Memory returns argon2id's memory size in KiB
+func Argon2Memory() int {
+	return argon2Memory
+}
+
+// Argon2Threads returns argon2id's degree of parallelism
+func Argon2Threads() int {
+	return argon2Threads
+}
+
+// LockoutUserThreshold returns the number of failed attempts to sign in as a user
+// which lock the user out
+func LockoutUserThreshold() int {
+	return lockoutUser
+}
+
+// LockoutAddressThreshold returns the number of failed attempts to sign in from a
+// remote address which lock the address out
+func LockoutAddressThreshold() int {
+	return lockoutAddress
+}
+
+// LockoutDomainThreshold returns the number of failed attempts to sign in to a domain
+// which lock the domain out
+func LockoutDomainThreshold() int {
+	return lockoutDomain
+}
+
+// LockoutDurationMinutes returns how long a lockout lasts (and failed attempts are
+// remembered) in minutes
+func LockoutDurationMinutes() int {
+	return lockoutDuration
+}
+
+// LockoutDelaySeconds returns the delay after the first failed attempt in seconds,
+// which doubles with every further failure
+func LockoutDelaySeconds() int {
+	return lockoutDelay
+}
+
+// PasswordBlocklist returns a path to a file listing passwords which are too common to
+// be used, one per line
+func PasswordBlocklist() string {
+	return passwordBlocklist
+}
+
+// TrustedProxies returns the networks of proxies whose X-Real-IP and X-Forwarded-For
+// headers tell the remote address of a client
+func TrustedProxies() []*net.IPNet {
+	return trustedProxies
+}
@@ -0,0 +1,361 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+	"gopkg.in/gorp.v1"
+)
+
+// Client table. Grant types, scopes and redirect URIs are stored space-separated.
+type Client struct {
+	PK           int64     `db:"oauth_client_id"`
+	ID           string    `db:"object_id"`
+	DomainPK     int64     `db:"domain_id"`
+	Name         string    `db:"name"`
+	Secret       string    `db:"secret"`
+	GrantTypes   string    `db:"grant_types"`
+	Scopes       string    `db:"scopes"`
+	RedirectURIs string    `db:"redirect_uris"`
+	Enabled      bool      `db:"is_enabled"`
+	CreatedOn    time.Time `db:"created_on"`
+}
+
+// ClientView contains all fields for populating the entity
+type ClientView struct {
+	Client
+	// Field resulted as join to domain table
+	DomainID string `db:"domain_object_id"`
+}
+
+// RefreshToken table
+type RefreshToken struct {
+	Hash      string    `db:"token_hash"`
+	ClientPK  int64     `db:"oauth_client_id"`
+	SessionID string    `db:"session_id"`
+	Scopes    string    `db:"scopes"`
+	CreatedOn time.Time `db:"created_on"`
+	ExpiresOn time.Time `db:"expires_on"`
+}
+
+// RefreshTokenView contains all fields for populating the entity
+type RefreshTokenView struct {
+	RefreshToken
+	// Field resulted as join to client table
+	ClientID string `db:"client_object_id"`
+}
+
+// AuthorizationCode table
+type AuthorizationCode struct {
+	Hash          string    `db:"code_hash"`
+	ClientPK      int64     `db:"oauth_client_id"`
+	SessionID     string    `db:"session_id"`
+	RedirectURI   string    `db:"redirect_uri"`
+	Scopes        string    `db:"scopes"`
+	Nonce         string    `db:"nonce"`
+	CodeChallenge string    `db:"code_challenge"`
+	CreatedOn     time.Time `db:"created_on"`
+	ExpiresOn     time.Time `db:"expires_on"`
+}
+
+// AuthorizationCodeView contains all fields for populating the entity
+type AuthorizationCodeView struct {
+	AuthorizationCode
+	// Field resulted as join to client table
+	ClientID string `db:"client_object_id"`
+}
+
+const (
+	clientViewQuery = `SELECT c.*, d.object_id AS domain_object_id
+		FROM oauth_client AS c
+		INNER JOIN domain AS d ON d.domain_id = c.domain_id`
+	refreshTokenViewQuery = `SELECT t.*, c.object_id AS client_object_id
+		FROM oauth_refresh_token AS t
+		INNER JOIN oauth_client AS c ON c.oauth_client_id = t.oauth_client_id`
+	authorizationCodeViewQuery = `SELECT a.*, c.object_id AS client_object_id
+		FROM oauth_authorization_code AS a
+		INNER JOIN oauth_client AS c ON c.oauth_client_id = a.oauth_client_id`
+)
+
+//
+// ClientRepository is a gorp-backed implementation of usecases.ClientRepository
+//
+type ClientRepository struct {
+	DBMap *gorp.DbMap
+}
+
+// Create inserts a new client
+func (repo *ClientRepository) Create(client entities.Client) error {
+	d, err := findDomain(repo.DBMap, "object_id", client.DomainID)
+	if err != nil {
+		return err
+	}
+	c := &Client{
+		ID:           client.ID,
+		DomainPK:     d.PK,
+		Name:         client.Name,
+		Secret:       client.Secret,
+		GrantTypes:   strings.Join(client.GrantTypes, " "),
+		Scopes:       strings.Join(client.Scopes, " "),
+		RedirectURIs: strings.Join(client.RedirectURIs, " "),
+		Enabled:      client.Enabled,
+		CreatedOn:    client.CreatedOn.Time,
+	}
+	err = repo.DBMap.Insert(c)
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to create a client", err)
+	}
+	return nil
+}
+
+// Delete deletes a client along with its refresh tokens and authorization codes
+func (repo *ClientRepository) Delete(id string) error {
+	c, err := findClient(repo.DBMap, id)
+	if err != nil {
+		return err
+	}
+
+	tx, err := repo.DBMap.Begin()
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to begin transaction", err)
+	}
+	for _, q := range []string{
+		"DELETE FROM oauth_refresh_token WHERE oauth_client_id = ?;",
+		"DELETE FROM oauth_authorization_code WHERE oauth_client_id = ?;",
+		"DELETE FROM oauth_client WHERE oauth_client_id = ?;",
+	} {
+		if _, err = tx.Exec(Rebind(repo.DBMap.Dialect, q), c.PK); err != nil {
+			tx.Rollback()
+			return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to delete client by given ID", err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to commit transaction", err)
+	}
+	return nil
+}
+
+// FindByID finds a client by ID
+func (repo *ClientRepository) FindByID(id string) (*entities.Client, error) {
+	var view ClientView
+	err := repo.DBMap.SelectOne(&view, Rebind(repo.DBMap.Dialect, clientViewQuery+" WHERE c.object_id = ?"), id)
+	if err == sql.ErrNoRows {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "Client not found by given ID", err)
+	} else if err != nil {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to perform a lookup of a client", err)
+	}
+	return clientToEntity(&view), nil
+}
+
+// List lists clients of a domain (all clients if the domain ID is empty) ordered by
+// creation date/time
+func (repo *ClientRepository) List(domainID string) ([]entities.Client, error) {
+	var (
+		views []ClientView
+		err   error
+	)
+	if domainID == "" {
+		_, err = repo.DBMap.Select(&views, clientViewQuery+" ORDER BY c.created_on, c.oauth_client_id")
+	} else {
+		q := clientViewQuery + " WHERE d.object_id = ? ORDER BY c.created_on, c.oauth_client_id"
+		_, err = repo.DBMap.Select(&views, Rebind(repo.DBMap.Dialect, q), domainID)
+	}
+	if err != nil {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to perform a lookup of clients", err)
+	}
+	clients := []entities.Client{}
+	for i := range views {
+		clients = append(clients, *clientToEntity(&views[i]))
+	}
+	return clients, nil
+}
+
+// CreateRefreshToken inserts a new refresh token of a client
+func (repo *ClientRepository) CreateRefreshToken(token entities.RefreshToken) error {
+	c, err := findClient(repo.DBMap, token.ClientID)
+	if err != nil {
+		return err
+	}
+	t := &RefreshToken{
+		Hash:      token.Hash,
+		ClientPK:  c.PK,
+		SessionID: token.SessionID,
+		Scopes:    strings.Join(token.Scopes, " "),
+		CreatedOn: token.CreatedOn.Time,
+		ExpiresOn: token.ExpiresOn.Time,
+	}
+	err = repo.DBMap.Insert(t)
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to create a refresh token", err)
+	}
+	return nil
+}
+
+// RetainRefreshToken updates refresh token's expiration date/time
+func (repo *ClientRepository) RetainRefreshToken(hash string, expiresOn time.Time) error {
+	q := "UPDATE oauth_refresh_token SET expires_on = ? WHERE token_hash = ?"
+	_, err := repo.DBMap.Exec(Rebind(repo.DBMap.Dialect, q), expiresOn, hash)
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to retain a refresh token", err)
+	}
+	return nil
+}
+
+// DeleteRefreshToken deletes a refresh token by hash
+func (repo *ClientRepository) DeleteRefreshToken(hash string) error {
+	q := "DELETE FROM oauth_refresh_token WHERE token_hash = ?"
+	_, err := repo.DBMap.Exec(Rebind(repo.DBMap.Dialect, q), hash)
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to delete refresh token", err)
+	}
+	return nil
+}
+
+// DeleteExpiredRefreshTokens deletes all refresh tokens expired by a given time
+func (repo *ClientRepository) DeleteExpiredRefreshTokens(now time.Time) error {
+	q := "DELETE FROM oauth_refresh_token WHERE expires_on <= ?"
+	_, err := repo.DBMap.Exec(Rebind(repo.DBMap.Dialect, q), now)
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to delete expired refresh tokens", err)
+	}
+	return nil
+}
+
+// FindRefreshToken finds a refresh token by hash
+func (repo *ClientRepository) FindRefreshToken(hash string) (*entities.RefreshToken, error) {
+	var view RefreshTokenView
+	err := repo.DBMap.SelectOne(&view, Rebind(repo.DBMap.Dialect, refreshTokenViewQuery+" WHERE t.token_hash = ?"), hash)
+	if err == sql.ErrNoRows {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "Refresh token not found", err)
+	} else if err != nil {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to perform a lookup of a refresh token", err)
+	}
+	return refreshTokenToEntity(&view), nil
+}
+
+// ListRefreshTokens lists all refresh tokens of a client
+func (repo *ClientRepository) ListRefreshTokens(clientID string) ([]entities.RefreshToken, error) {
+	var views []RefreshTokenView
+	q := refreshTokenViewQuery + " WHERE c.object_id = ? ORDER BY t.created_on"
+	_, err := repo.DBMap.Select(&views, Rebind(repo.DBMap.Dialect, q), clientID)
+	if err != nil {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to perform a lookup of refresh tokens", err)
+	}
+	tokens := []entities.RefreshToken{}
+	for i := range views {
+		tokens = append(tokens, *refreshTokenToEntity(&views[i]))
+	}
+	return tokens, nil
+}
+
+// CreateAuthorizationCode inserts a new authorization code of a client
+func (repo *ClientRepository) CreateAuthorizationCode(code entities.AuthorizationCode) error {
+	c, err := findClient(repo.DBMap, code.ClientID)
+	if err != nil {
+		return err
+	}
+	a := &AuthorizationCode{
+		Hash:          code.Hash,
+		ClientPK:      c.PK,
+		SessionID:     code.SessionID,
+		RedirectURI:   code.RedirectURI,
+		Scopes:        strings.Join(code.Scopes, " "),
+		Nonce:         code.Nonce,
+		CodeChallenge: code.CodeChallenge,
+		CreatedOn:     code.CreatedOn.Time,
+		ExpiresOn:     code.ExpiresOn.Time,
+	}
+	err = repo.DBMap.Insert(a)
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to create an authorization code", err)
+	}
+	return nil
+}
+
+// TakeAuthorizationCode finds an authorization code by hash and deletes it. If two
+// callers take the same code concurrently only one of them gets it.
+func (repo *ClientRepository) TakeAuthorizationCode(hash string) (*entities.AuthorizationCode, error) {
+	var view AuthorizationCodeView
+	err := repo.DBMap.SelectOne(&view, Rebind(repo.DBMap.Dialect, authorizationCodeViewQuery+" WHERE a.code_hash = ?"), hash)
+	if err == sql.ErrNoRows {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "Authorization code not found", err)
+	} else if err != nil {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to perform a lookup of an authorization code", err)
+	}
+	res, err := repo.DBMap.Exec(Rebind(repo.DBMap.Dialect, "DELETE FROM oauth_authorization_code WHERE code_hash = ?"), hash)
+	if err != nil {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to delete authorization code", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to delete authorization code", err)
+	} else if n == 0 {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "Authorization code not found", nil)
+	}
+	return authorizationCodeToEntity(&view), nil
+}
+
+// DeleteExpiredAuthorizationCodes deletes all authorization codes expired by a given time
+func (repo *ClientRepository) DeleteExpiredAuthorizationCodes(now time.Time) error {
+	q := "DELETE FROM oauth_authorization_code WHERE expires_on <= ?"
+	_, err := repo.DBMap.Exec(Rebind(repo.DBMap.Dialect, q), now)
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to delete expired authorization codes", err)
+	}
+	return nil
+}
+
+func findClient(dbmap *gorp.DbMap, id string) (*Client, error) {
+	var c Client
+	err := dbmap.SelectOne(&c, Rebind(dbmap.Dialect, "SELECT * FROM oauth_client WHERE object_id = ?"), id)
+	if err == sql.ErrNoRows {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "Client not found by given ID", err)
+	} else if err != nil {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to perform a lookup of a client", err)
+	}
+	return &c, nil
+}
+
+func clientToEntity(view *ClientView) *entities.Client {
+	c := &entities.Client{
+		ID:         view.ID,
+		Name:       view.Name,
+		DomainID:   view.DomainID,
+		Secret:     view.Secret,
+		GrantTypes: strings.Fields(view.GrantTypes),
+		Scopes:     strings.Fields(view.Scopes),
+		Enabled:    view.Enabled,
+	}
+	c.RedirectURIs = strings.Fields(view.RedirectURIs)
+	c.CreatedOn.Time = view.CreatedOn
+	return c
+}
+
+func refreshTokenToEntity(view *RefreshTokenView) *entities.RefreshToken {
+	t := &entities.RefreshToken{
+		Hash:      view.Hash,
+		ClientID:  view.ClientID,
+		SessionID: view.SessionID,
+		Scopes:    strings.Fields(view.Scopes),
+	}
+	t.CreatedOn.Time = view.CreatedOn
+	t.ExpiresOn.Time = view.ExpiresOn
+	return t
+}
+
+func authorizationCodeToEntity(view *AuthorizationCodeView) *entities.AuthorizationCode {
+	a := &entities.AuthorizationCode{
+		Hash:          view.Hash,
+		ClientID:      view.ClientID,
+		SessionID:     view.SessionID,
+		RedirectURI:   view.RedirectURI,
+		Scopes:        strings.Fields(view.Scopes),
+		Nonce:         view.Nonce,
+		CodeChallenge: view.CodeChallenge,
+	}
+	a.CreatedOn.Time = view.CreatedOn
+	a.ExpiresOn.Time = view.ExpiresOn
+	return a
+}
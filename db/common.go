@@ -1,15 +1,30 @@
 package db
 
 import (
+	"bytes"
 	"database/sql"
 	"fmt"
+	"strings"
 
 	"github.com/oleksandr/idp/entities"
 	"gopkg.in/gorp.v1"
 )
 
+// SQLite connection defaults added to a DSN unless it sets them explicitly. WAL mode lets
+// readers work alongside a writer, the busy timeout makes concurrent writers wait for each
+// other instead of failing with "database is locked" and immediate transactions take the
+// write lock upfront, so two transactions never deadlock upgrading their read locks.
+var sqliteDefaults = [][2]string{
+	{"_journal_mode", "WAL"},
+	{"_busy_timeout", "5000"},
+	{"_txlock", "immediate"},
+}
+
 // InitDB connects to a database and creates and returns driver-specific mapper
 func InitDB(driverName, DSN string) (*gorp.DbMap, error) {
+	if driverName == "sqlite3" {
+		DSN = sqliteDSN(DSN)
+	}
 	cpool, err := sql.Open(driverName, DSN)
 	if err != nil {
 		return nil, err
@@ -18,6 +33,10 @@ func InitDB(driverName, DSN string) (*gorp.DbMap, error) {
 	var dbmap *gorp.DbMap
 	switch driverName {
 	case "sqlite3":
+		if strings.Contains(DSN, ":memory:") || strings.Contains(DSN, "mode=memory") {
+			// Every connection to an in-memory database gets its own empty database
+			cpool.SetMaxOpenConns(1)
+		}
 		dbmap = &gorp.DbMap{
 			Db:      cpool,
 			Dialect: gorp.SqliteDialect{},
@@ -25,7 +44,7 @@ func InitDB(driverName, DSN string) (*gorp.DbMap, error) {
 	case "mysql":
 		dbmap = &gorp.DbMap{
 			Db:      cpool,
-			Dialect: gorp.MySQLDialect{"InnoDB", "UTF8"},
+			Dialect: gorp.MySQLDialect{Engine: "InnoDB", Encoding: "UTF8"},
 		}
 	case "postgres":
 		dbmap = &gorp.DbMap{
@@ -50,6 +69,7 @@ func InitDB(driverName, DSN string) (*gorp.DbMap, error) {
 	tmap.ColMap("object_id").SetUnique(true).SetNotNull(true)
 	tmap.ColMap("name").SetUnique(true).SetNotNull(true)
 	tmap.ColMap("passwd").SetMaxSize(500).SetNotNull(true)
+	tmap.ColMap("password_changed_on").SetNotNull(true)
 	tmap.ColMap("is_enabled").SetNotNull(true)
 	tmap.ColMap("created_on").SetNotNull(true)
 	tmap.ColMap("updated_on").SetNotNull(true)
@@ -83,12 +103,165 @@ func InitDB(driverName, DSN string) (*gorp.DbMap, error) {
 	tmap = dbmap.AddTableWithName(RolePermission{}, "role_permission")
 	tmap.SetKeys(false, "role_id", "permission_id")
 
+	tmap = dbmap.AddTableWithName(RoleInheritance{}, "role_inheritance")
+	tmap.SetKeys(false, "senior_role_id", "junior_role_id")
+
+	tmap = dbmap.AddTableWithName(SoDConstraint{}, "sod_constraint")
+	tmap.SetKeys(true, "constraint_id")
+	tmap.ColMap("name").SetUnique(true).SetNotNull(true)
+	tmap.ColMap("cardinality").SetNotNull(true)
+
+	tmap = dbmap.AddTableWithName(SoDConstraintRole{}, "sod_constraint_role")
+	tmap.SetKeys(false, "constraint_id", "role_id")
+
 	tmap = dbmap.AddTableWithName(UserRole{}, "user_role")
-	tmap.SetKeys(false, "user_id", "role_id")
+	tmap.SetKeys(false, "user_id", "role_id", "domain_id")
+
+	tmap = dbmap.AddTableWithName(SigningKey{}, "signing_key")
+	tmap.SetKeys(true, "signing_key_id")
+	tmap.ColMap("object_id").SetUnique(true).SetNotNull(true)
+	tmap.ColMap("algorithm").SetNotNull(true)
+	tmap.ColMap("status").SetNotNull(true)
+
+	tmap = dbmap.AddTableWithName(Client{}, "oauth_client")
+	tmap.SetKeys(true, "oauth_client_id")
+	tmap.ColMap("object_id").SetUnique(true).SetNotNull(true)
+	tmap.ColMap("domain_id").SetNotNull(true)
+	tmap.ColMap("name").SetNotNull(true)
+	tmap.ColMap("secret").SetNotNull(true)
+	tmap.ColMap("scopes").SetMaxSize(1000).SetNotNull(true)
+	tmap.ColMap("redirect_uris").SetMaxSize(2000).SetNotNull(true)
+	tmap.ColMap("is_enabled").SetNotNull(true)
+
+	tmap = dbmap.AddTableWithName(RefreshToken{}, "oauth_refresh_token")
+	tmap.SetKeys(false, "token_hash")
+	tmap.ColMap("oauth_client_id").SetNotNull(true)
+	tmap.ColMap("session_id").SetNotNull(true)
+	tmap.ColMap("scopes").SetMaxSize(1000).SetNotNull(true)
+	tmap.ColMap("expires_on").SetNotNull(true)
+
+	tmap = dbmap.AddTableWithName(AuthorizationCode{}, "oauth_authorization_code")
+	tmap.SetKeys(false, "code_hash")
+	tmap.ColMap("oauth_client_id").SetNotNull(true)
+	tmap.ColMap("session_id").SetNotNull(true)
+	tmap.ColMap("redirect_uri").SetMaxSize(1000).SetNotNull(true)
+	tmap.ColMap("scopes").SetMaxSize(1000).SetNotNull(true)
+	tmap.ColMap("nonce").SetNotNull(true)
+	tmap.ColMap("expires_on").SetNotNull(true)
+
+	tmap = dbmap.AddTableWithName(ServiceProvider{}, "saml_service_provider")
+	tmap.SetKeys(true, "saml_service_provider_id")
+	tmap.ColMap("object_id").SetUnique(true).SetNotNull(true)
+	tmap.ColMap("domain_id").SetNotNull(true)
+	tmap.ColMap("name").SetNotNull(true)
+	tmap.ColMap("entity_id").SetUnique(true).SetNotNull(true)
+	tmap.ColMap("acs_url").SetMaxSize(1000).SetNotNull(true)
+	tmap.ColMap("is_enabled").SetNotNull(true)
+
+	tmap = dbmap.AddTableWithName(MFAEnrollment{}, "mfa_enrollment")
+	tmap.SetKeys(false, "user_id")
+	tmap.ColMap("secret").SetNotNull(true)
+	tmap.ColMap("last_step").SetNotNull(true)
+
+	tmap = dbmap.AddTableWithName(RecoveryCode{}, "mfa_recovery_code")
+	tmap.SetKeys(false, "user_id", "code_hash")
+
+	tmap = dbmap.AddTableWithName(MFAChallenge{}, "mfa_challenge")
+	tmap.SetKeys(false, "challenge_id")
+	tmap.ColMap("domain_id").SetNotNull(true)
+	tmap.ColMap("user_id").SetNotNull(true)
+	tmap.ColMap("user_agent").SetMaxSize(1000).SetNotNull(true)
+	tmap.ColMap("remote_addr").SetNotNull(true)
+	tmap.ColMap("attempts").SetNotNull(true)
+	tmap.ColMap("expires_on").SetNotNull(true)
+
+	tmap = dbmap.AddTableWithName(Credential{}, "webauthn_credential")
+	tmap.SetKeys(false, "credential_id")
+	tmap.ColMap("user_id").SetNotNull(true)
+	tmap.ColMap("name").SetNotNull(true)
+	tmap.ColMap("public_key").SetNotNull(true)
+	tmap.ColMap("sign_count").SetNotNull(true)
+	tmap.ColMap("last_used_on").SetNotNull(true)
+
+	tmap = dbmap.AddTableWithName(WebAuthnChallenge{}, "webauthn_challenge")
+	tmap.SetKeys(false, "challenge_id")
+	tmap.ColMap("ceremony").SetNotNull(true)
+	tmap.ColMap("challenge").SetNotNull(true)
+	tmap.ColMap("domain_id").SetNotNull(true)
+	tmap.ColMap("user_id").SetNotNull(true)
+	tmap.ColMap("expires_on").SetNotNull(true)
+
+	tmap = dbmap.AddTableWithName(LoginFailure{}, "login_failure")
+	tmap.SetKeys(false, "scope", "subject")
+	tmap.ColMap("scope").SetMaxSize(16)
+	tmap.ColMap("failures").SetNotNull(true)
+	tmap.ColMap("last_failed_on").SetNotNull(true)
+	tmap.ColMap("blocked_until").SetNotNull(true)
+
+	tmap = dbmap.AddTableWithName(PasswordHistory{}, "password_history")
+	tmap.SetKeys(true, "password_history_id")
+	tmap.ColMap("user_id").SetNotNull(true)
+	tmap.ColMap("passwd").SetMaxSize(500).SetNotNull(true)
+	tmap.ColMap("created_on").SetNotNull(true)
+
+	tmap = dbmap.AddTableWithName(PasswordPolicy{}, "password_policy")
+	tmap.SetKeys(false, "domain_id")
+	tmap.ColMap("min_length").SetNotNull(true)
+	tmap.ColMap("history").SetNotNull(true)
+	tmap.ColMap("max_age_days").SetNotNull(true)
+	tmap.ColMap("updated_on").SetNotNull(true)
 
 	return dbmap, nil
 }
 
+// sqliteDSN adds connection defaults missing in a SQLite DSN
+func sqliteDSN(dsn string) string {
+	var params []string
+	for _, p := range sqliteDefaults {
+		if !strings.Contains(dsn, p[0]+"=") {
+			params = append(params, p[0]+"="+p[1])
+		}
+	}
+	if len(params) == 0 {
+		return dsn
+	}
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	return dsn + sep + strings.Join(params, "&")
+}
+
+// Rebind replaces "?" bind parameters of a query with the ones of a given dialect
+// (e.g. "$1", "$2" for PostgreSQL). Question marks inside quoted literals are kept.
+// Boolean values should be passed as bind parameters rather than 1/0 literals.
+func Rebind(dialect gorp.Dialect, query string) string {
+	if dialect.BindVar(0) == "?" {
+		return query
+	}
+	var (
+		b     bytes.Buffer
+		n     int
+		quote rune
+	)
+	for _, c := range query {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '?':
+			b.WriteString(dialect.BindVar(n))
+			n++
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
 // OrderByClause takes a Sorter and constructs a "ORDER BY" clause if required
 func OrderByClause(sorter entities.Sorter, alias string) string {
 	clause := ""
@@ -114,3 +287,17 @@ func LimitOffset(pager entities.Pager) string {
 	}
 	return clause
 }
+
+// placeholders returns a list of n "?" bind parameters for an IN clause
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+// stringArgs converts given strings to query arguments
+func stringArgs(values []string) []interface{} {
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return args
+}
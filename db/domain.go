@@ -1,8 +1,12 @@
 package db
 
 import (
+	"database/sql"
+	"fmt"
 	"time"
 
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
 	"gopkg.in/gorp.v1"
 )
 
@@ -13,6 +17,7 @@ type Domain struct {
 	Name        string    `db:"name"`
 	Description string    `db:"description"`
 	Enabled     bool      `db:"is_enabled"`
+	MFARequired bool      `db:"is_mfa_required"`
 	CreatedOn   time.Time `db:"created_on"`
 	UpdatedOn   time.Time `db:"updated_on"`
 }
@@ -29,33 +34,190 @@ type DomainUser struct {
 	UserPK   int64 `db:"user_id"`
 }
 
-// DeleteDomain deletes a domain a referenced records
-func DeleteDomain(dbmap *gorp.DbMap, id string) error {
-	var d Domain
-	err := dbmap.SelectOne(&d, "SELECT * FROM domain WHERE object_id = ?", id)
+//
+// DomainRepository is a gorp-backed implementation of usecases.DomainRepository
+//
+type DomainRepository struct {
+	DBMap *gorp.DbMap
+}
+
+// Create inserts a new domain
+func (repo *DomainRepository) Create(domain entities.BasicDomain) error {
+	now := time.Now().UTC()
+	d := &Domain{
+		ID:          domain.ID,
+		Name:        domain.Name,
+		Description: domain.Description,
+		Enabled:     domain.Enabled,
+		MFARequired: domain.MFARequired,
+		CreatedOn:   now,
+		UpdatedOn:   now,
+	}
+	err := repo.DBMap.Insert(d)
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to create a domain", err)
+	}
+	return nil
+}
+
+// Update updates all attributes of a domain found by ID
+func (repo *DomainRepository) Update(domain entities.BasicDomain) error {
+	d, err := findDomain(repo.DBMap, "object_id", domain.ID)
 	if err != nil {
 		return err
 	}
 
-	tx, err := dbmap.Begin()
+	d.Name = domain.Name
+	d.Description = domain.Description
+	d.Enabled = domain.Enabled
+	d.MFARequired = domain.MFARequired
+	d.UpdatedOn = time.Now().UTC()
+
+	_, err = repo.DBMap.Update(d)
 	if err != nil {
-		return nil
+		return errs.NewDataAccessError(errs.ErrorTypeConflict, "Failed to update domain", err)
 	}
+	return nil
+}
 
-	_, err = tx.Exec("DELETE FROM user_session WHERE domain_id = ?;", d.PK)
+// Delete deletes a domain along with its sessions, users' membership, role assignments,
+// OAuth clients, SAML service providers, pending MFA and WebAuthn challenges and its
+// password policy
+func (repo *DomainRepository) Delete(id string) error {
+	d, err := findDomain(repo.DBMap, "object_id", id)
 	if err != nil {
-		tx.Rollback()
 		return err
 	}
-	_, err = tx.Exec("DELETE FROM domain_user WHERE domain_id = ?;", d.PK)
+
+	tx, err := repo.DBMap.Begin()
 	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to begin transaction", err)
+	}
+	for _, q := range []string{
+		"DELETE FROM session WHERE domain_id = ?;",
+		"DELETE FROM domain_user WHERE domain_id = ?;",
+		"DELETE FROM user_role WHERE domain_id = ?;",
+		"DELETE FROM oauth_refresh_token WHERE oauth_client_id IN (SELECT oauth_client_id FROM oauth_client WHERE domain_id = ?);",
+		"DELETE FROM oauth_authorization_code WHERE oauth_client_id IN (SELECT oauth_client_id FROM oauth_client WHERE domain_id = ?);",
+		"DELETE FROM oauth_client WHERE domain_id = ?;",
+		"DELETE FROM saml_service_provider WHERE domain_id = ?;",
+		"DELETE FROM mfa_challenge WHERE domain_id = ?;",
+		"DELETE FROM webauthn_challenge WHERE domain_id = ?;",
+		"DELETE FROM password_policy WHERE domain_id = ?;",
+	} {
+		if _, err = tx.Exec(Rebind(repo.DBMap.Dialect, q), d.PK); err != nil {
+			tx.Rollback()
+			return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to delete domain by given ID", err)
+		}
+	}
+	if _, err = tx.Delete(d); err != nil {
 		tx.Rollback()
-		return err
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to delete domain by given ID", err)
 	}
-	_, err = tx.Delete(&d)
+	if err = tx.Commit(); err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to commit transaction", err)
+	}
+	return nil
+}
+
+// FindByID finds a domain by ID
+func (repo *DomainRepository) FindByID(id string) (*entities.BasicDomain, error) {
+	d, err := findDomain(repo.DBMap, "object_id", id)
 	if err != nil {
-		tx.Rollback()
-		return err
+		return nil, err
+	}
+	return domainToEntity(d), nil
+}
+
+// FindByName finds a domain by name
+func (repo *DomainRepository) FindByName(name string) (*entities.BasicDomain, error) {
+	d, err := findDomain(repo.DBMap, "name", name)
+	if err != nil {
+		return nil, err
+	}
+	return domainToEntity(d), nil
+}
+
+// CountUsers returns number of users in a domain
+func (repo *DomainRepository) CountUsers(id string) (int64, error) {
+	q := "SELECT COUNT(*) FROM domain_user WHERE domain_id IN (SELECT domain_id FROM domain WHERE object_id = ?)"
+	c, err := repo.DBMap.SelectInt(Rebind(repo.DBMap.Dialect, q), id)
+	if err != nil {
+		return -1, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to count users", err)
+	}
+	return c, nil
+}
+
+// List returns a page of domains along with the total number of domains
+func (repo *DomainRepository) List(pager entities.Pager, sorter entities.Sorter) ([]entities.Domain, int64, error) {
+	total, err := repo.DBMap.SelectInt("SELECT COUNT(*) FROM domain")
+	if err != nil {
+		return nil, 0, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to count domains", err)
+	}
+
+	var records []DomainWithStats
+	q := `SELECT d.*, COUNT(du.domain_id) AS users_count FROM domain AS d
+        LEFT JOIN domain_user AS du ON d.domain_id = du.domain_id
+        GROUP BY d.domain_id %v %v`
+	_, err = repo.DBMap.Select(&records, fmt.Sprintf(q, OrderByClause(sorter, "d"), LimitOffset(pager)))
+	if err != nil {
+		return nil, 0, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to perform a lookup of domains", err)
+	}
+	return domainsToEntities(records), total, nil
+}
+
+// ListByUser returns a page of domains of a given user along with the total number
+// of the user's domains
+func (repo *DomainRepository) ListByUser(userID string, pager entities.Pager, sorter entities.Sorter) ([]entities.Domain, int64, error) {
+	userTbl := repo.DBMap.Dialect.QuotedTableForQuery("", "user")
+	q := fmt.Sprintf(`SELECT count(*) FROM domain_user WHERE user_id IN (SELECT user_id FROM %v WHERE object_id = ?);`, userTbl)
+	total, err := repo.DBMap.SelectInt(Rebind(repo.DBMap.Dialect, q), userID)
+	if err != nil {
+		return nil, 0, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to count domains for a given user", err)
+	}
+
+	var records []DomainWithStats
+	q = `SELECT d.*, COUNT(du.domain_id) AS users_count FROM domain AS d
+        LEFT JOIN domain_user AS du ON d.domain_id = du.domain_id
+        WHERE d.domain_id IN (
+            SELECT DISTINCT domain_id FROM domain_user WHERE user_id
+                IN (SELECT user_id FROM %v WHERE object_id = ?)
+        )
+        GROUP BY d.domain_id %v %v;`
+	_, err = repo.DBMap.Select(&records, Rebind(repo.DBMap.Dialect, fmt.Sprintf(q, userTbl, OrderByClause(sorter, "d"), LimitOffset(pager))), userID)
+	if err != nil {
+		return nil, 0, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to perform a lookup of domains", err)
+	}
+	return domainsToEntities(records), total, nil
+}
+
+// findDomain finds a domain record by a value of a given unique column
+func findDomain(dbmap *gorp.DbMap, column string, value string) (*Domain, error) {
+	var d Domain
+	err := dbmap.SelectOne(&d, Rebind(dbmap.Dialect, fmt.Sprintf("SELECT * FROM domain WHERE %v = ?", column)), value)
+	if err == sql.ErrNoRows {
+		if column == "name" {
+			return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "Domain not found by given name", err)
+		}
+		return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "Domain not found by given ID", err)
+	} else if err != nil {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to perform a lookup of a domain", err)
+	}
+	return &d, nil
+}
+
+func domainToEntity(d *Domain) *entities.BasicDomain {
+	e := entities.NewBasicDomain(d.Name, d.Description)
+	e.ID = d.ID
+	e.Enabled = d.Enabled
+	e.MFARequired = d.MFARequired
+	return e
+}
+
+func domainsToEntities(records []DomainWithStats) []entities.Domain {
+	domains := []entities.Domain{}
+	for _, r := range records {
+		domains = append(domains, entities.Domain{BasicDomain: *domainToEntity(&r.Domain), UsersCount: r.UsersCount})
 	}
-	return tx.Commit()
+	return domains
 }
@@ -0,0 +1,163 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"time"
+
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+	"gopkg.in/gorp.v1"
+)
+
+// SigningKey table. Keys are stored base64 encoded.
+type SigningKey struct {
+	PK          int64         `db:"signing_key_id"`
+	ID          string        `db:"object_id"`
+	Algorithm   string        `db:"algorithm"`
+	Status      string        `db:"status"`
+	PublicKey   string        `db:"public_key"`
+	PrivateKey  string        `db:"private_key"`
+	CreatedOn   time.Time     `db:"created_on"`
+	ActivatedOn gorp.NullTime `db:"activated_on"`
+	RetiredOn   gorp.NullTime `db:"retired_on"`
+}
+
+//
+// KeyRepository is a gorp-backed implementation of usecases.KeyRepository
+//
+type KeyRepository struct {
+	DBMap *gorp.DbMap
+}
+
+// Create inserts a new key
+func (repo *KeyRepository) Create(key entities.SigningKey, sealedPrivateKey []byte) error {
+	k := &SigningKey{
+		ID:         key.ID,
+		Algorithm:  key.Algorithm,
+		Status:     key.Status,
+		PublicKey:  base64.StdEncoding.EncodeToString(key.PublicKey),
+		PrivateKey: base64.StdEncoding.EncodeToString(sealedPrivateKey),
+		CreatedOn:  key.CreatedOn.Time,
+	}
+	err := repo.DBMap.Insert(k)
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to create a key", err)
+	}
+	return nil
+}
+
+// Activate makes a key active and retires the previously active one
+func (repo *KeyRepository) Activate(id string, now time.Time) error {
+	k, err := findKey(repo.DBMap, id)
+	if err != nil {
+		return err
+	}
+
+	tx, err := repo.DBMap.Begin()
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to begin transaction", err)
+	}
+	q := "UPDATE signing_key SET status = ?, retired_on = ? WHERE status = ? AND signing_key_id <> ?"
+	_, err = tx.Exec(Rebind(repo.DBMap.Dialect, q), entities.SigningKeyRetired, now, entities.SigningKeyActive, k.PK)
+	if err != nil {
+		tx.Rollback()
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to retire the active key", err)
+	}
+	q = "UPDATE signing_key SET status = ?, activated_on = ? WHERE signing_key_id = ?"
+	_, err = tx.Exec(Rebind(repo.DBMap.Dialect, q), entities.SigningKeyActive, now, k.PK)
+	if err != nil {
+		tx.Rollback()
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to activate a key", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to commit transaction", err)
+	}
+	return nil
+}
+
+// Retire retires a key
+func (repo *KeyRepository) Retire(id string, now time.Time) error {
+	k, err := findKey(repo.DBMap, id)
+	if err != nil {
+		return err
+	}
+	q := "UPDATE signing_key SET status = ?, retired_on = ? WHERE signing_key_id = ?"
+	_, err = repo.DBMap.Exec(Rebind(repo.DBMap.Dialect, q), entities.SigningKeyRetired, now, k.PK)
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to retire a key", err)
+	}
+	return nil
+}
+
+// FindByID finds a key by ID
+func (repo *KeyRepository) FindByID(id string) (*entities.SigningKey, error) {
+	k, err := findKey(repo.DBMap, id)
+	if err != nil {
+		return nil, err
+	}
+	return keyToEntity(k)
+}
+
+// FindPrivateKey returns a sealed private key of a key found by ID
+func (repo *KeyRepository) FindPrivateKey(id string) ([]byte, error) {
+	k, err := findKey(repo.DBMap, id)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(k.PrivateKey)
+	if err != nil {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to decode a private key", err)
+	}
+	return sealed, nil
+}
+
+// List returns all keys ordered by creation date/time
+func (repo *KeyRepository) List() ([]entities.SigningKey, error) {
+	var records []SigningKey
+	_, err := repo.DBMap.Select(&records, "SELECT * FROM signing_key ORDER BY created_on, signing_key_id")
+	if err != nil {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to perform a lookup of keys", err)
+	}
+	keys := []entities.SigningKey{}
+	for i := range records {
+		k, err := keyToEntity(&records[i])
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *k)
+	}
+	return keys, nil
+}
+
+func findKey(dbmap *gorp.DbMap, id string) (*SigningKey, error) {
+	var k SigningKey
+	err := dbmap.SelectOne(&k, Rebind(dbmap.Dialect, "SELECT * FROM signing_key WHERE object_id = ?"), id)
+	if err == sql.ErrNoRows {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "Key not found by given ID", err)
+	} else if err != nil {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to perform a lookup of a key", err)
+	}
+	return &k, nil
+}
+
+func keyToEntity(k *SigningKey) (*entities.SigningKey, error) {
+	public, err := base64.StdEncoding.DecodeString(k.PublicKey)
+	if err != nil {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to decode a public key", err)
+	}
+	e := &entities.SigningKey{
+		ID:        k.ID,
+		Algorithm: k.Algorithm,
+		Status:    k.Status,
+		PublicKey: public,
+	}
+	e.CreatedOn.Time = k.CreatedOn
+	if k.ActivatedOn.Valid {
+		e.ActivatedOn.Time = k.ActivatedOn.Time
+	}
+	if k.RetiredOn.Valid {
+		e.RetiredOn.Time = k.RetiredOn.Time
+	}
+	return e, nil
+}
@@ -0,0 +1,146 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+	"gopkg.in/gorp.v1"
+)
+
+// LoginFailure table. Subjects are object IDs of users and domains or remote addresses.
+type LoginFailure struct {
+	Scope        string    `db:"scope"`
+	Subject      string    `db:"subject"`
+	Failures     int       `db:"failures"`
+	LastFailedOn time.Time `db:"last_failed_on"`
+	BlockedUntil time.Time `db:"blocked_until"`
+}
+
+//
+// LockoutRepository is a gorp-backed implementation of usecases.LockoutRepository.
+// Failures aren't deleted along with their users or domains, they're purged once
+// they're stale.
+//
+type LockoutRepository struct {
+	DBMap *gorp.DbMap
+}
+
+// Find finds failures of a subject
+func (repo *LockoutRepository) Find(scope, subject string) (*entities.LoginFailures, error) {
+	var f LoginFailure
+	q := "SELECT * FROM login_failure WHERE scope = ? AND subject = ?"
+	err := repo.DBMap.SelectOne(&f, Rebind(repo.DBMap.Dialect, q), scope, subject)
+	if err == sql.ErrNoRows {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "Failures not found by given subject", err)
+	} else if err != nil {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to perform a lookup of failures", err)
+	}
+	failures := &entities.LoginFailures{
+		Scope:    f.Scope,
+		Subject:  f.Subject,
+		Failures: f.Failures,
+	}
+	failures.LastFailedOn.Time = f.LastFailedOn
+	failures.BlockedUntil.Time = f.BlockedUntil
+	return failures, nil
+}
+
+// AddFailure counts a failed attempt of a subject at a given time and returns the
+// number of failures. Failures are forgotten unless there have been failures or
+// blocked attempts since a given time. The count is read in the same transaction, so
+// concurrent failures never get the same number.
+func (repo *LockoutRepository) AddFailure(scope, subject string, now, since time.Time) (int, error) {
+	q := `UPDATE login_failure
+		SET failures = CASE WHEN last_failed_on < ? AND blocked_until < ? THEN 1 ELSE failures + 1 END, last_failed_on = ?
+		WHERE scope = ? AND subject = ?`
+	for i := 0; i < 2; i++ {
+		tx, err := repo.DBMap.Begin()
+		if err != nil {
+			return 0, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to begin transaction", err)
+		}
+		res, err := tx.Exec(Rebind(repo.DBMap.Dialect, q), since, since, now, scope, subject)
+		if err != nil {
+			tx.Rollback()
+			return 0, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to count a failed attempt", err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			tx.Rollback()
+			return 0, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to count a failed attempt", err)
+		}
+
+		if n == 0 {
+			// The first failure, unless another instance inserts it meanwhile
+			err = tx.Insert(&LoginFailure{
+				Scope:        scope,
+				Subject:      subject,
+				Failures:     1,
+				LastFailedOn: now,
+				BlockedUntil: now,
+			})
+			if err != nil {
+				tx.Rollback()
+				continue
+			}
+			if err = tx.Commit(); err != nil {
+				return 0, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to commit transaction", err)
+			}
+			return 1, nil
+		}
+
+		failures, err := tx.SelectInt(Rebind(repo.DBMap.Dialect, "SELECT failures FROM login_failure WHERE scope = ? AND subject = ?"), scope, subject)
+		if err != nil {
+			tx.Rollback()
+			return 0, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to count a failed attempt", err)
+		}
+		if err = tx.Commit(); err != nil {
+			return 0, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to commit transaction", err)
+		}
+		return int(failures), nil
+	}
+	return 0, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to count a failed attempt", nil)
+}
+
+// RemoveFailure takes back a failure of a subject counted in advance by AddFailure
+func (repo *LockoutRepository) RemoveFailure(scope, subject string) error {
+	q := "UPDATE login_failure SET failures = failures - 1 WHERE scope = ? AND subject = ? AND failures > 0"
+	_, err := repo.DBMap.Exec(Rebind(repo.DBMap.Dialect, q), scope, subject)
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to take back a failed attempt", err)
+	}
+	return nil
+}
+
+// Block blocks attempts of a subject until a given time unless they're blocked for
+// longer already
+func (repo *LockoutRepository) Block(scope, subject string, until time.Time) error {
+	q := "UPDATE login_failure SET blocked_until = ? WHERE scope = ? AND subject = ? AND blocked_until < ?"
+	_, err := repo.DBMap.Exec(Rebind(repo.DBMap.Dialect, q), until, scope, subject, until)
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to block attempts", err)
+	}
+	return nil
+}
+
+// Delete deletes failures of a subject if there are any
+func (repo *LockoutRepository) Delete(scope, subject string) error {
+	q := "DELETE FROM login_failure WHERE scope = ? AND subject = ?"
+	_, err := repo.DBMap.Exec(Rebind(repo.DBMap.Dialect, q), scope, subject)
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to delete failures", err)
+	}
+	return nil
+}
+
+// DeleteStale deletes failures which have neither failed nor blocked attempts since a
+// given time
+func (repo *LockoutRepository) DeleteStale(since time.Time) error {
+	q := "DELETE FROM login_failure WHERE last_failed_on < ? AND blocked_until < ?"
+	_, err := repo.DBMap.Exec(Rebind(repo.DBMap.Dialect, q), since, since)
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to delete stale failures", err)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package db
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+	"gopkg.in/gorp.v1"
+)
+
+// testDB creates a migrated SQLite database in a temporary directory
+func testDB(t *testing.T) *gorp.DbMap {
+	dbmap, err := InitDB("sqlite3", t.TempDir()+"/idp.sqlite3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = MigrateUp(dbmap, 0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { dbmap.Db.Close() })
+	return dbmap
+}
+
+func TestLockoutRepository(t *testing.T) {
+	repo := &LockoutRepository{DBMap: testDB(t)}
+	scope := entities.LockoutScopeUser
+	now := time.Now().UTC()
+	since := now.Add(-time.Minute)
+
+	if _, err := repo.Find(scope, "john"); err == nil || err.(*errs.Error).Type != errs.ErrorTypeNotFound {
+		t.Fatalf("Find without failures: %v", err)
+	}
+	for i := 1; i <= 3; i++ {
+		n, err := repo.AddFailure(scope, "john", now, since)
+		if err != nil || n != i {
+			t.Fatalf("AddFailure %v = %v, %v", i, n, err)
+		}
+	}
+	if n, _ := repo.AddFailure(entities.LockoutScopeAddress, "john", now, since); n != 1 {
+		t.Errorf("AddFailure in another scope = %v", n)
+	}
+
+	f, err := repo.Find(scope, "john")
+	if err != nil || f.Failures != 3 || !f.LastFailedOn.Equal(now) || f.RetryAfter() != 0 {
+		t.Fatalf("Find = %+v, %v", f, err)
+	}
+
+	// A block is only ever extended
+	must(t, repo.Block(scope, "john", now.Add(time.Hour)))
+	must(t, repo.Block(scope, "john", now.Add(time.Minute)))
+	must(t, repo.Block(scope, "other", now.Add(time.Hour)))
+	if f, _ = repo.Find(scope, "john"); f.RetryAfter() < 59*time.Minute {
+		t.Errorf("RetryAfter after blocks = %v", f.RetryAfter())
+	}
+	if _, err = repo.Find(scope, "other"); err == nil {
+		t.Error("Block created failures of a subject without any")
+	}
+
+	must(t, repo.RemoveFailure(scope, "john"))
+	if f, _ = repo.Find(scope, "john"); f.Failures != 2 {
+		t.Errorf("Failures after RemoveFailure = %v", f.Failures)
+	}
+	must(t, repo.Delete(scope, "john"))
+	if _, err = repo.Find(scope, "john"); err == nil {
+		t.Error("Find of deleted failures succeeded")
+	}
+	must(t, repo.RemoveFailure(scope, "john"))
+}
+
+func TestLockoutRepositoryWindow(t *testing.T) {
+	repo := &LockoutRepository{DBMap: testDB(t)}
+	scope := entities.LockoutScopeAddress
+	old := time.Now().UTC().Add(-time.Hour)
+	now := time.Now().UTC()
+
+	for i := 0; i < 3; i++ {
+		_, err := repo.AddFailure(scope, "127.0.0.1", old, old.Add(-time.Minute))
+		must(t, err)
+	}
+	_, err := repo.AddFailure(scope, "10.0.0.1", old, old.Add(-time.Minute))
+	must(t, err)
+	must(t, repo.Block(scope, "10.0.0.1", now.Add(time.Minute)))
+
+	// Failures older than the window are forgotten unless attempts are still blocked
+	if n, err := repo.AddFailure(scope, "127.0.0.1", now, now.Add(-time.Minute)); err != nil || n != 1 {
+		t.Errorf("AddFailure after the window = %v, %v", n, err)
+	}
+	if n, err := repo.AddFailure(scope, "10.0.0.1", now, now.Add(-time.Minute)); err != nil || n != 2 {
+		t.Errorf("AddFailure of a blocked subject after the window = %v, %v", n, err)
+	}
+
+	must(t, repo.DeleteStale(now.Add(-time.Minute)))
+	for _, subject := range []string{"127.0.0.1", "10.0.0.1"} {
+		if _, err = repo.Find(scope, subject); err != nil {
+			t.Errorf("DeleteStale deleted recent failures of %v: %v", subject, err)
+		}
+	}
+	must(t, repo.DeleteStale(now.Add(2*time.Minute)))
+	for _, subject := range []string{"127.0.0.1", "10.0.0.1"} {
+		if _, err = repo.Find(scope, subject); err == nil {
+			t.Errorf("DeleteStale kept stale failures of %v", subject)
+		}
+	}
+}
+
+func TestLockoutRepositoryConcurrency(t *testing.T) {
+	repo := &LockoutRepository{DBMap: testDB(t)}
+	scope := entities.LockoutScopeUser
+	now := time.Now().UTC()
+
+	// Concurrent first failures race to insert the record, the losers update it
+	const attempts = 20
+	counts := make(chan int, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n, err := repo.AddFailure(scope, "john", now, now.Add(-time.Minute))
+			if err != nil {
+				t.Error(err)
+			}
+			counts <- n
+		}()
+	}
+	wg.Wait()
+	close(counts)
+
+	seen := map[int]bool{}
+	for n := range counts {
+		seen[n] = true
+	}
+	if len(seen) != attempts || !seen[1] || !seen[attempts] {
+		t.Errorf("AddFailure counts = %v, want each of 1..%v once", seen, attempts)
+	}
+	if f, err := repo.Find(scope, "john"); err != nil || f.Failures != attempts {
+		t.Errorf("Find = %+v, %v", f, err)
+	}
+}
+
+func must(t *testing.T, err error) {
+	if err != nil {
+		t.Fatal(err)
+	}
+}
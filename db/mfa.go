@@ -0,0 +1,316 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+	"gopkg.in/gorp.v1"
+)
+
+// MFAEnrollment table. Secrets are stored base64 encoded.
+type MFAEnrollment struct {
+	UserPK    int64     `db:"user_id"`
+	Secret    string    `db:"secret"`
+	LastStep  int64     `db:"last_step"`
+	CreatedOn time.Time `db:"created_on"`
+}
+
+// MFAEnrollmentView contains all fields for populating the entity
+type MFAEnrollmentView struct {
+	MFAEnrollment
+	// Field resulted as join to user table
+	UserID string `db:"user_object_id"`
+	// Field resulted as count of recovery codes
+	RecoveryCodes int `db:"recovery_codes"`
+}
+
+// RecoveryCode table
+type RecoveryCode struct {
+	UserPK   int64  `db:"user_id"`
+	CodeHash string `db:"code_hash"`
+}
+
+// MFAChallenge table
+type MFAChallenge struct {
+	ID         string    `db:"challenge_id"`
+	DomainPK   int64     `db:"domain_id"`
+	UserPK     int64     `db:"user_id"`
+	UserAgent  string    `db:"user_agent"`
+	RemoteAddr string    `db:"remote_addr"`
+	Attempts   int       `db:"attempts"`
+	CreatedOn  time.Time `db:"created_on"`
+	ExpiresOn  time.Time `db:"expires_on"`
+}
+
+// MFAChallengeView contains all fields for populating the entity
+type MFAChallengeView struct {
+	MFAChallenge
+	// Field resulted as join to domain table
+	DomainID string `db:"domain_object_id"`
+	// Field resulted as join to user table
+	UserID string `db:"user_object_id"`
+}
+
+const mfaEnrollmentViewQuery = `SELECT e.*, u.object_id AS user_object_id,
+		(SELECT COUNT(*) FROM mfa_recovery_code AS c WHERE c.user_id = e.user_id) AS recovery_codes
+		FROM mfa_enrollment AS e
+		INNER JOIN %v AS u ON u.user_id = e.user_id`
+
+const mfaChallengeViewQuery = `SELECT c.*, d.object_id AS domain_object_id, u.object_id AS user_object_id
+		FROM mfa_challenge AS c
+		INNER JOIN domain AS d ON d.domain_id = c.domain_id
+		INNER JOIN %v AS u ON u.user_id = c.user_id`
+
+//
+// MFARepository is a gorp-backed implementation of usecases.MFARepository
+//
+type MFARepository struct {
+	DBMap *gorp.DbMap
+}
+
+// Create inserts a new enrollment along with its recovery codes
+func (repo *MFARepository) Create(enrollment entities.MFAEnrollment, sealedSecret []byte, recoveryCodeHashes []string) error {
+	u, err := findUser(repo.DBMap, "object_id", enrollment.UserID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := repo.DBMap.Begin()
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to begin transaction", err)
+	}
+	e := &MFAEnrollment{
+		UserPK:    u.PK,
+		Secret:    base64.StdEncoding.EncodeToString(sealedSecret),
+		LastStep:  enrollment.LastStep,
+		CreatedOn: enrollment.CreatedOn.Time,
+	}
+	if err = tx.Insert(e); err != nil {
+		tx.Rollback()
+		return errs.NewDataAccessError(errs.ErrorTypeConflict, "Failed to create an enrollment", err)
+	}
+	for _, hash := range recoveryCodeHashes {
+		if err = tx.Insert(&RecoveryCode{UserPK: u.PK, CodeHash: hash}); err != nil {
+			tx.Rollback()
+			return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to create a recovery code", err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to commit transaction", err)
+	}
+	return nil
+}
+
+// Delete deletes an enrollment of a user along with the recovery codes and pending
+// challenges
+func (repo *MFARepository) Delete(userID string) error {
+	u, err := findUser(repo.DBMap, "object_id", userID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := repo.DBMap.Begin()
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to begin transaction", err)
+	}
+	for _, q := range []string{
+		"DELETE FROM mfa_challenge WHERE user_id = ?;",
+		"DELETE FROM mfa_recovery_code WHERE user_id = ?;",
+	} {
+		if _, err = tx.Exec(Rebind(repo.DBMap.Dialect, q), u.PK); err != nil {
+			tx.Rollback()
+			return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to delete enrollment of a given user", err)
+		}
+	}
+	res, err := tx.Exec(Rebind(repo.DBMap.Dialect, "DELETE FROM mfa_enrollment WHERE user_id = ?;"), u.PK)
+	if err != nil {
+		tx.Rollback()
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to delete enrollment of a given user", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		tx.Rollback()
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to delete enrollment of a given user", err)
+	} else if n == 0 {
+		tx.Rollback()
+		return errs.NewDataAccessError(errs.ErrorTypeNotFound, "User is not enrolled", nil)
+	}
+	if err = tx.Commit(); err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to commit transaction", err)
+	}
+	return nil
+}
+
+// FindByUser finds an enrollment of a user
+func (repo *MFARepository) FindByUser(userID string) (*entities.MFAEnrollment, error) {
+	view, err := repo.findEnrollment(userID)
+	if err != nil {
+		return nil, err
+	}
+	return mfaEnrollmentToEntity(view), nil
+}
+
+// FindSecret finds a sealed TOTP secret of a user
+func (repo *MFARepository) FindSecret(userID string) ([]byte, error) {
+	view, err := repo.findEnrollment(userID)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := base64.StdEncoding.DecodeString(view.Secret)
+	if err != nil {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to decode a TOTP secret", err)
+	}
+	return secret, nil
+}
+
+// UseStep records the time step of an accepted one-time password if it's later than
+// the last recorded one
+func (repo *MFARepository) UseStep(userID string, step int64) error {
+	q := fmt.Sprintf("UPDATE mfa_enrollment SET last_step = ? WHERE last_step < ? AND user_id IN (SELECT user_id FROM %v WHERE object_id = ?)",
+		repo.DBMap.Dialect.QuotedTableForQuery("", "user"))
+	res, err := repo.DBMap.Exec(Rebind(repo.DBMap.Dialect, q), step, step, userID)
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to record a one-time password", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to record a one-time password", err)
+	} else if n == 0 {
+		return errs.NewDataAccessError(errs.ErrorTypeConflict, "One-time password has been used already", nil)
+	}
+	return nil
+}
+
+// UseRecoveryCode deletes a recovery code of a user found by hash
+func (repo *MFARepository) UseRecoveryCode(userID, hash string) error {
+	q := fmt.Sprintf("DELETE FROM mfa_recovery_code WHERE code_hash = ? AND user_id IN (SELECT user_id FROM %v WHERE object_id = ?)",
+		repo.DBMap.Dialect.QuotedTableForQuery("", "user"))
+	res, err := repo.DBMap.Exec(Rebind(repo.DBMap.Dialect, q), hash, userID)
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to use a recovery code", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to use a recovery code", err)
+	} else if n == 0 {
+		return errs.NewDataAccessError(errs.ErrorTypeNotFound, "Recovery code not found", nil)
+	}
+	return nil
+}
+
+// CreateChallenge inserts a new challenge
+func (repo *MFARepository) CreateChallenge(challenge entities.MFAChallenge) error {
+	d, err := findDomain(repo.DBMap, "object_id", challenge.DomainID)
+	if err != nil {
+		return err
+	}
+	u, err := findUser(repo.DBMap, "object_id", challenge.UserID)
+	if err != nil {
+		return err
+	}
+	c := &MFAChallenge{
+		ID:         challenge.ID,
+		DomainPK:   d.PK,
+		UserPK:     u.PK,
+		UserAgent:  challenge.UserAgent,
+		RemoteAddr: challenge.RemoteAddr,
+		Attempts:   challenge.Attempts,
+		CreatedOn:  challenge.CreatedOn.Time,
+		ExpiresOn:  challenge.ExpiresOn.Time,
+	}
+	err = repo.DBMap.Insert(c)
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to create a challenge", err)
+	}
+	return nil
+}
+
+// FindChallenge finds a challenge by ID
+func (repo *MFARepository) FindChallenge(id string) (*entities.MFAChallenge, error) {
+	var view MFAChallengeView
+	q := fmt.Sprintf(mfaChallengeViewQuery, repo.DBMap.Dialect.QuotedTableForQuery("", "user")) + " WHERE c.challenge_id = ?"
+	err := repo.DBMap.SelectOne(&view, Rebind(repo.DBMap.Dialect, q), id)
+	if err == sql.ErrNoRows {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "Challenge not found by given ID", err)
+	} else if err != nil {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to perform a lookup of a challenge", err)
+	}
+	return mfaChallengeToEntity(&view), nil
+}
+
+// AddChallengeAttempt counts an attempt unless a challenge has been attempted a given
+// number of times
+func (repo *MFARepository) AddChallengeAttempt(id string, maxAttempts int) error {
+	q := "UPDATE mfa_challenge SET attempts = attempts + 1 WHERE challenge_id = ? AND attempts < ?"
+	res, err := repo.DBMap.Exec(Rebind(repo.DBMap.Dialect, q), id, maxAttempts)
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to count an attempt of a challenge", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to count an attempt of a challenge", err)
+	} else if n == 0 {
+		return errs.NewDataAccessError(errs.ErrorTypeConflict, "Challenge has no attempts left", nil)
+	}
+	return nil
+}
+
+// DeleteChallenge deletes a challenge by ID
+func (repo *MFARepository) DeleteChallenge(id string) error {
+	res, err := repo.DBMap.Exec(Rebind(repo.DBMap.Dialect, "DELETE FROM mfa_challenge WHERE challenge_id = ?"), id)
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to delete challenge by given ID", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to delete challenge by given ID", err)
+	} else if n == 0 {
+		return errs.NewDataAccessError(errs.ErrorTypeNotFound, "Challenge not found by given ID", nil)
+	}
+	return nil
+}
+
+// DeleteExpiredChallenges deletes all challenges expired by a given time
+func (repo *MFARepository) DeleteExpiredChallenges(now time.Time) error {
+	_, err := repo.DBMap.Exec(Rebind(repo.DBMap.Dialect, "DELETE FROM mfa_challenge WHERE expires_on <= ?"), now)
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to delete expired challenges", err)
+	}
+	return nil
+}
+
+// findEnrollment finds an enrollment record of a user
+func (repo *MFARepository) findEnrollment(userID string) (*MFAEnrollmentView, error) {
+	var view MFAEnrollmentView
+	q := fmt.Sprintf(mfaEnrollmentViewQuery, repo.DBMap.Dialect.QuotedTableForQuery("", "user")) + " WHERE u.object_id = ?"
+	err := repo.DBMap.SelectOne(&view, Rebind(repo.DBMap.Dialect, q), userID)
+	if err == sql.ErrNoRows {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "User is not enrolled", err)
+	} else if err != nil {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to perform a lookup of an enrollment", err)
+	}
+	return &view, nil
+}
+
+func mfaEnrollmentToEntity(view *MFAEnrollmentView) *entities.MFAEnrollment {
+	e := &entities.MFAEnrollment{
+		UserID:        view.UserID,
+		LastStep:      view.LastStep,
+		RecoveryCodes: view.RecoveryCodes,
+	}
+	e.CreatedOn.Time = view.CreatedOn
+	return e
+}
+
+func mfaChallengeToEntity(view *MFAChallengeView) *entities.MFAChallenge {
+	c := &entities.MFAChallenge{
+		ID:         view.ID,
+		DomainID:   view.DomainID,
+		UserID:     view.UserID,
+		UserAgent:  view.UserAgent,
+		RemoteAddr: view.RemoteAddr,
+		Attempts:   view.Attempts,
+	}
+	c.CreatedOn.Time = view.CreatedOn
+	c.ExpiresOn.Time = view.ExpiresOn
+	return c
+}
@@ -0,0 +1,655 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/gorp.v1"
+)
+
+// MigrationsTable is a name of the table that records applied migrations
+const MigrationsTable = "schema_migrations"
+
+// MigrationProgressTable is a name of the table that records statements of migrations
+// which are being applied or reverted on databases committing DDL statements implicitly
+const MigrationProgressTable = "schema_migration_progress"
+
+//
+// Migration is a versioned change of the database schema. Statements are written
+// in a dialect neutral way using the following placeholders:
+//
+//	{pk}        auto-incremented primary key
+//	{int}       integer column
+//	{bigint}    64-bit integer column
+//	{bool}      boolean column
+//	{datetime}  timestamp column
+//	{uuid}      random UUID generated for each row
+//	{user}      quoted name of the user table
+//	{suffix}    dialect specific suffix of a CREATE TABLE statement
+//
+// Migrations are append-only: once released a migration must never be changed,
+// a new one has to be added instead. If Unless is given, it's a query which succeeds
+// when the schema already has the changes of a migration (e.g. a database created
+// before migrations were introduced), so Up statements are skipped, but the migration
+// is recorded as applied.
+//
+// MySQL commits DDL statements implicitly, so there a migration isn't atomic: each of
+// its statements is committed along with its progress instead, and a migration which
+// failed halfway resumes after its last successful statement.
+//
+type Migration struct {
+	Version     int64
+	Description string
+	Unless      string
+	Up          []string
+	Down        []string
+}
+
+//
+// MigrationStatus is a migration along with the time it was applied at (if applied)
+//
+type MigrationStatus struct {
+	Migration
+	AppliedOn *time.Time
+}
+
+// SchemaMigration is a record of an applied migration
+type SchemaMigration struct {
+	Version     int64     `db:"version"`
+	Description string    `db:"description"`
+	AppliedOn   time.Time `db:"applied_on"`
+}
+
+// Migrations is an ordered list of all schema migrations
+var Migrations = []Migration{
+	{
+		Version:     1,
+		Description: "Baseline schema",
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS domain (
+				domain_id {pk},
+				object_id varchar(255) NOT NULL UNIQUE,
+				name varchar(255) NOT NULL UNIQUE,
+				description varchar(1000),
+				is_enabled {bool} NOT NULL,
+				created_on {datetime} NOT NULL,
+				updated_on {datetime} NOT NULL
+			){suffix};`,
+			`CREATE TABLE IF NOT EXISTS {user} (
+				user_id {pk},
+				object_id varchar(255) NOT NULL UNIQUE,
+				name varchar(255) NOT NULL UNIQUE,
+				passwd varchar(500) NOT NULL,
+				is_enabled {bool} NOT NULL,
+				created_on {datetime} NOT NULL,
+				updated_on {datetime} NOT NULL
+			){suffix};`,
+			`CREATE TABLE IF NOT EXISTS session (
+				session_id varchar(255) NOT NULL PRIMARY KEY,
+				domain_id {bigint} NOT NULL,
+				user_id {bigint} NOT NULL,
+				user_agent varchar(1000) NOT NULL,
+				remote_addr varchar(255) NOT NULL,
+				created_on {datetime} NOT NULL,
+				updated_on {datetime} NOT NULL,
+				expires_on {datetime} NOT NULL
+			){suffix};`,
+			`CREATE TABLE IF NOT EXISTS role (
+				role_id {pk},
+				name varchar(255) NOT NULL UNIQUE,
+				description varchar(1000),
+				is_enabled {bool} NOT NULL
+			){suffix};`,
+			`CREATE TABLE IF NOT EXISTS permission (
+				permission_id {pk},
+				name varchar(255) NOT NULL UNIQUE,
+				description varchar(1000),
+				evaluation_rule varchar(1000),
+				is_enabled {bool} NOT NULL
+			){suffix};`,
+			`CREATE TABLE IF NOT EXISTS domain_user (
+				domain_id {bigint},
+				user_id {bigint},
+				PRIMARY KEY (domain_id, user_id)
+			){suffix};`,
+			`CREATE TABLE IF NOT EXISTS role_permission (
+				role_id {bigint},
+				permission_id {bigint},
+				PRIMARY KEY (role_id, permission_id)
+			){suffix};`,
+			`CREATE TABLE IF NOT EXISTS role_inheritance (
+				senior_role_id {bigint},
+				junior_role_id {bigint},
+				PRIMARY KEY (senior_role_id, junior_role_id)
+			){suffix};`,
+			`CREATE TABLE IF NOT EXISTS sod_constraint (
+				constraint_id {pk},
+				name varchar(255) NOT NULL UNIQUE,
+				cardinality {int} NOT NULL
+			){suffix};`,
+			`CREATE TABLE IF NOT EXISTS sod_constraint_role (
+				constraint_id {bigint},
+				role_id {bigint},
+				PRIMARY KEY (constraint_id, role_id)
+			){suffix};`,
+			`CREATE TABLE IF NOT EXISTS user_role (
+				user_id {bigint},
+				role_id {bigint},
+				domain_id {bigint},
+				PRIMARY KEY (user_id, role_id, domain_id)
+			){suffix};`,
+		},
+		Down: []string{
+			"DROP TABLE IF EXISTS user_role;",
+			"DROP TABLE IF EXISTS sod_constraint_role;",
+			"DROP TABLE IF EXISTS sod_constraint;",
+			"DROP TABLE IF EXISTS role_inheritance;",
+			"DROP TABLE IF EXISTS role_permission;",
+			"DROP TABLE IF EXISTS domain_user;",
+			"DROP TABLE IF EXISTS permission;",
+			"DROP TABLE IF EXISTS role;",
+			"DROP TABLE IF EXISTS session;",
+			"DROP TABLE IF EXISTS {user};",
+			"DROP TABLE IF EXISTS domain;",
+		},
+	},
+	{
+		Version:     2,
+		Description: "Signing keys",
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS signing_key (
+				signing_key_id {pk},
+				object_id varchar(255) NOT NULL UNIQUE,
+				algorithm varchar(20) NOT NULL,
+				status varchar(20) NOT NULL,
+				public_key text NOT NULL,
+				private_key text NOT NULL,
+				created_on {datetime} NOT NULL,
+				activated_on {datetime} NULL,
+				retired_on {datetime} NULL
+			){suffix};`,
+		},
+		Down: []string{
+			"DROP TABLE IF EXISTS signing_key;",
+		},
+	},
+	{
+		Version:     3,
+		Description: "OAuth 2.0 clients and refresh tokens",
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS oauth_client (
+				oauth_client_id {pk},
+				object_id varchar(255) NOT NULL UNIQUE,
+				domain_id {bigint} NOT NULL,
+				name varchar(255) NOT NULL,
+				secret varchar(255) NOT NULL,
+				grant_types varchar(255) NOT NULL,
+				scopes varchar(1000) NOT NULL,
+				is_enabled {bool} NOT NULL,
+				created_on {datetime} NOT NULL
+			){suffix};`,
+			`CREATE TABLE IF NOT EXISTS oauth_refresh_token (
+				token_hash varchar(64) NOT NULL PRIMARY KEY,
+				oauth_client_id {bigint} NOT NULL,
+				session_id varchar(255) NOT NULL,
+				scopes varchar(1000) NOT NULL,
+				created_on {datetime} NOT NULL,
+				expires_on {datetime} NOT NULL
+			){suffix};`,
+		},
+		Down: []string{
+			"DROP TABLE IF EXISTS oauth_refresh_token;",
+			"DROP TABLE IF EXISTS oauth_client;",
+		},
+	},
+	{
+		Version:     4,
+		Description: "OAuth 2.0 redirect URIs and authorization codes",
+		Up: []string{
+			"ALTER TABLE oauth_client ADD COLUMN redirect_uris varchar(2000) NOT NULL DEFAULT '';",
+			`CREATE TABLE IF NOT EXISTS oauth_authorization_code (
+				code_hash varchar(64) NOT NULL PRIMARY KEY,
+				oauth_client_id {bigint} NOT NULL,
+				session_id varchar(255) NOT NULL,
+				redirect_uri varchar(1000) NOT NULL,
+				scopes varchar(1000) NOT NULL,
+				code_challenge varchar(255) NOT NULL,
+				created_on {datetime} NOT NULL,
+				expires_on {datetime} NOT NULL
+			){suffix};`,
+		},
+		Down: []string{
+			"DROP TABLE IF EXISTS oauth_authorization_code;",
+			"ALTER TABLE oauth_client DROP COLUMN redirect_uris;",
+		},
+	},
+	{
+		Version:     5,
+		Description: "OpenID Connect nonces of authorization codes",
+		Up: []string{
+			"ALTER TABLE oauth_authorization_code ADD COLUMN nonce varchar(255) NOT NULL DEFAULT '';",
+		},
+		Down: []string{
+			"ALTER TABLE oauth_authorization_code DROP COLUMN nonce;",
+		},
+	},
+	{
+		Version:     6,
+		Description: "SAML 2.0 service providers",
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS saml_service_provider (
+				saml_service_provider_id {pk},
+				object_id varchar(255) NOT NULL UNIQUE,
+				domain_id {bigint} NOT NULL,
+				name varchar(255) NOT NULL,
+				entity_id varchar(255) NOT NULL UNIQUE,
+				acs_url varchar(1000) NOT NULL,
+				is_enabled {bool} NOT NULL,
+				created_on {datetime} NOT NULL
+			){suffix};`,
+		},
+		Down: []string{
+			"DROP TABLE IF EXISTS saml_service_provider;",
+		},
+	},
+	{
+		Version:     7,
+		Description: "Multi-factor authentication",
+		Up: []string{
+			"ALTER TABLE domain ADD COLUMN is_mfa_required {bool} NOT NULL DEFAULT FALSE;",
+			`CREATE TABLE IF NOT EXISTS mfa_enrollment (
+				user_id {bigint} NOT NULL PRIMARY KEY,
+				secret text NOT NULL,
+				last_step {bigint} NOT NULL,
+				created_on {datetime} NOT NULL
+			){suffix};`,
+			`CREATE TABLE IF NOT EXISTS mfa_recovery_code (
+				user_id {bigint} NOT NULL,
+				code_hash varchar(64) NOT NULL,
+				PRIMARY KEY (user_id, code_hash)
+			){suffix};`,
+			`CREATE TABLE IF NOT EXISTS mfa_challenge (
+				challenge_id varchar(255) NOT NULL PRIMARY KEY,
+				domain_id {bigint} NOT NULL,
+				user_id {bigint} NOT NULL,
+				user_agent varchar(1000) NOT NULL,
+				remote_addr varchar(255) NOT NULL,
+				attempts {int} NOT NULL,
+				created_on {datetime} NOT NULL,
+				expires_on {datetime} NOT NULL
+			){suffix};`,
+		},
+		Down: []string{
+			"DROP TABLE IF EXISTS mfa_challenge;",
+			"DROP TABLE IF EXISTS mfa_recovery_code;",
+			"DROP TABLE IF EXISTS mfa_enrollment;",
+			"ALTER TABLE domain DROP COLUMN is_mfa_required;",
+		},
+	},
+	{
+		Version:     8,
+		Description: "WebAuthn credentials",
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS webauthn_credential (
+				credential_id varchar(255) NOT NULL PRIMARY KEY,
+				user_id {bigint} NOT NULL,
+				name varchar(255) NOT NULL,
+				public_key text NOT NULL,
+				sign_count {bigint} NOT NULL,
+				created_on {datetime} NOT NULL,
+				last_used_on {datetime} NOT NULL
+			){suffix};`,
+			`CREATE TABLE IF NOT EXISTS webauthn_challenge (
+				challenge_id varchar(255) NOT NULL PRIMARY KEY,
+				ceremony varchar(255) NOT NULL,
+				challenge varchar(255) NOT NULL,
+				domain_id {bigint} NOT NULL,
+				user_id {bigint} NOT NULL,
+				created_on {datetime} NOT NULL,
+				expires_on {datetime} NOT NULL
+			){suffix};`,
+		},
+		Down: []string{
+			"DROP TABLE IF EXISTS webauthn_challenge;",
+			"DROP TABLE IF EXISTS webauthn_credential;",
+		},
+	},
+	{
+		Version:     9,
+		Description: "Login failures",
+		Up: []string{
+			`CREATE TABLE IF NOT EXISTS login_failure (
+				scope varchar(16) NOT NULL,
+				subject varchar(255) NOT NULL,
+				failures {int} NOT NULL,
+				last_failed_on {datetime} NOT NULL,
+				blocked_until {datetime} NOT NULL,
+				PRIMARY KEY (scope, subject)
+			){suffix};`,
+		},
+		Down: []string{
+			"DROP TABLE IF EXISTS login_failure;",
+		},
+	},
+	{
+		Version:     10,
+		Description: "Password policies",
+		Up: []string{
+			"ALTER TABLE {user} ADD COLUMN password_changed_on {datetime} NOT NULL DEFAULT '1970-01-01 00:00:00';",
+			"UPDATE {user} SET password_changed_on = updated_on;",
+			`CREATE TABLE IF NOT EXISTS password_history (
+				password_history_id {pk},
+				user_id {bigint} NOT NULL,
+				passwd varchar(500) NOT NULL,
+				created_on {datetime} NOT NULL
+			){suffix};`,
+			`CREATE TABLE IF NOT EXISTS password_policy (
+				domain_id {bigint} NOT NULL PRIMARY KEY,
+				min_length {int} NOT NULL,
+				require_lower {bool} NOT NULL,
+				require_upper {bool} NOT NULL,
+				require_digit {bool} NOT NULL,
+				require_symbol {bool} NOT NULL,
+				blocklist {bool} NOT NULL,
+				history {int} NOT NULL,
+				max_age_days {int} NOT NULL,
+				updated_on {datetime} NOT NULL
+			){suffix};`,
+		},
+		Down: []string{
+			"DROP TABLE IF EXISTS password_policy;",
+			"DROP TABLE IF EXISTS password_history;",
+			"ALTER TABLE {user} DROP COLUMN password_changed_on;",
+		},
+	},
+	{
+		Version:     11,
+		Description: "Session references",
+		Up: []string{
+			"ALTER TABLE session ADD COLUMN reference varchar(255) NOT NULL DEFAULT '';",
+			"UPDATE session SET reference = {uuid} WHERE reference = '';",
+		},
+		Down: []string{
+			"ALTER TABLE session DROP COLUMN reference;",
+		},
+	},
+	{
+		Version:     12,
+		Description: "Domain scoped role assignments of adopted databases",
+		Unless:      "SELECT domain_id FROM user_role WHERE 1 = 0;",
+		Up: []string{
+			`CREATE TABLE user_role_scoped (
+				user_id {bigint},
+				role_id {bigint},
+				domain_id {bigint},
+				PRIMARY KEY (user_id, role_id, domain_id)
+			){suffix};`,
+			"INSERT INTO user_role_scoped (user_id, role_id, domain_id) SELECT user_id, role_id, 0 FROM user_role;",
+			"DROP TABLE user_role;",
+			"ALTER TABLE user_role_scoped RENAME TO user_role;",
+		},
+		Down: []string{},
+	},
+}
+
+// LatestSchemaVersion returns a version of the last known migration
+func LatestSchemaVersion() int64 {
+	if len(Migrations) == 0 {
+		return 0
+	}
+	return Migrations[len(Migrations)-1].Version
+}
+
+// SchemaVersion returns a version of the last migration applied to a database
+// or 0 if none was applied
+func SchemaVersion(dbmap *gorp.DbMap) (int64, error) {
+	if err := createMigrationsTable(dbmap); err != nil {
+		return 0, err
+	}
+	version, err := dbmap.SelectInt(fmt.Sprintf("SELECT COALESCE(MAX(version), 0) FROM %v;", MigrationsTable))
+	if err != nil {
+		return 0, fmt.Errorf("Failed to read schema version: %v", err)
+	}
+	return version, nil
+}
+
+// CheckSchemaVersion returns an error if a database's schema is behind the latest migration
+func CheckSchemaVersion(dbmap *gorp.DbMap) error {
+	version, err := SchemaVersion(dbmap)
+	if err != nil {
+		return err
+	}
+	if latest := LatestSchemaVersion(); version < latest {
+		return fmt.Errorf("Database schema is at version %v, but version %v is required. Run \"idp-cli db migrate up\"", version, latest)
+	}
+	return nil
+}
+
+// ListMigrations returns all known migrations along with their status
+func ListMigrations(dbmap *gorp.DbMap) ([]MigrationStatus, error) {
+	applied, err := appliedMigrations(dbmap)
+	if err != nil {
+		return nil, err
+	}
+	var statuses []MigrationStatus
+	for _, m := range Migrations {
+		s := MigrationStatus{Migration: m}
+		if r, ok := applied[m.Version]; ok {
+			appliedOn := r.AppliedOn
+			s.AppliedOn = &appliedOn
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
+
+// MigrateUp applies a given number of pending migrations in order (all pending if steps is 0)
+// and returns the applied ones
+func MigrateUp(dbmap *gorp.DbMap, steps int) ([]Migration, error) {
+	applied, err := appliedMigrations(dbmap)
+	if err != nil {
+		return nil, err
+	}
+	var done []Migration
+	for _, m := range Migrations {
+		if steps > 0 && len(done) == steps {
+			break
+		}
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		statements := m.Up
+		if m.Unless != "" {
+			if _, err = dbmap.Exec(m.Unless); err == nil {
+				statements = nil
+			}
+		}
+		q := Rebind(dbmap.Dialect, fmt.Sprintf("INSERT INTO %v (version, description, applied_on) VALUES (?, ?, ?);", MigrationsTable))
+		err = runMigration(dbmap, m, migrationUp, statements, q, m.Version, m.Description, time.Now().UTC())
+		if err != nil {
+			return done, err
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// MigrateDown reverts a given number of applied migrations in reverse order (all if steps is 0)
+// and returns the reverted ones
+func MigrateDown(dbmap *gorp.DbMap, steps int) ([]Migration, error) {
+	applied, err := appliedMigrations(dbmap)
+	if err != nil {
+		return nil, err
+	}
+	var done []Migration
+	for i := len(Migrations) - 1; i >= 0; i-- {
+		m := Migrations[i]
+		if steps > 0 && len(done) == steps {
+			break
+		}
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		q := Rebind(dbmap.Dialect, fmt.Sprintf("DELETE FROM %v WHERE version = ?;", MigrationsTable))
+		err = runMigration(dbmap, m, migrationDown, m.Down, q, m.Version)
+		if err != nil {
+			return done, err
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// DropMigrationsTable drops the tables recording applied migrations
+func DropMigrationsTable(dbmap *gorp.DbMap) error {
+	if _, err := dbmap.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %v;", MigrationProgressTable)); err != nil {
+		return err
+	}
+	_, err := dbmap.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %v;", MigrationsTable))
+	return err
+}
+
+// Directions of migrations recorded in MigrationProgressTable
+const (
+	migrationUp   = "up"
+	migrationDown = "down"
+)
+
+// runMigration executes given statements of a migration and a bookkeeping query in a
+// transaction. On MySQL every statement is committed on its own along with the number
+// of statements done, and statements done by a previous attempt are skipped.
+func runMigration(dbmap *gorp.DbMap, m Migration, direction string, statements []string, q string, args ...interface{}) error {
+	_, perStatement := dbmap.Dialect.(gorp.MySQLDialect)
+	done := 0
+	if perStatement {
+		n, err := dbmap.SelectNullInt(Rebind(dbmap.Dialect, fmt.Sprintf("SELECT statements FROM %v WHERE version = ? AND direction = ?;", MigrationProgressTable)), m.Version, direction)
+		if err != nil {
+			return fmt.Errorf("Failed to read progress of migration %v: %v", m.Version, err)
+		}
+		done = int(n.Int64)
+	}
+
+	tx, err := dbmap.Begin()
+	if err != nil {
+		return fmt.Errorf("Failed to begin transaction: %v", err)
+	}
+	r := dialectReplacer(dbmap)
+	for i := done; i < len(statements); i++ {
+		if _, err = tx.Exec(r.Replace(statements[i])); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("Migration %v (%v) failed: %v", m.Version, m.Description, err)
+		}
+		if !perStatement {
+			continue
+		}
+		if err = recordMigrationProgress(tx, dbmap.Dialect, m.Version, direction, i+1); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err = tx.Commit(); err != nil {
+			return fmt.Errorf("Failed to commit migration %v: %v", m.Version, err)
+		}
+		if tx, err = dbmap.Begin(); err != nil {
+			return fmt.Errorf("Failed to begin transaction: %v", err)
+		}
+	}
+	if perStatement {
+		if err = recordMigrationProgress(tx, dbmap.Dialect, m.Version, direction, 0); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if _, err = tx.Exec(q, args...); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("Failed to record migration %v: %v", m.Version, err)
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("Failed to commit migration %v: %v", m.Version, err)
+	}
+	return nil
+}
+
+// recordMigrationProgress records the number of statements of a migration done in a
+// direction (none are recorded once it's done)
+func recordMigrationProgress(tx *gorp.Transaction, dialect gorp.Dialect, version int64, direction string, statements int) error {
+	_, err := tx.Exec(Rebind(dialect, fmt.Sprintf("DELETE FROM %v WHERE version = ? AND direction = ?;", MigrationProgressTable)), version, direction)
+	if err == nil && statements > 0 {
+		_, err = tx.Exec(Rebind(dialect, fmt.Sprintf("INSERT INTO %v (version, direction, statements) VALUES (?, ?, ?);", MigrationProgressTable)), version, direction, statements)
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to record progress of migration %v: %v", version, err)
+	}
+	return nil
+}
+
+// appliedMigrations returns records of applied migrations by their versions
+func appliedMigrations(dbmap *gorp.DbMap) (map[int64]SchemaMigration, error) {
+	if err := createMigrationsTable(dbmap); err != nil {
+		return nil, err
+	}
+	var records []SchemaMigration
+	_, err := dbmap.Select(&records, fmt.Sprintf("SELECT * FROM %v ORDER BY version;", MigrationsTable))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read applied migrations: %v", err)
+	}
+	applied := map[int64]SchemaMigration{}
+	for _, r := range records {
+		applied[r.Version] = r
+	}
+	return applied, nil
+}
+
+// createMigrationsTable creates the tables recording applied migrations if they don't exist
+func createMigrationsTable(dbmap *gorp.DbMap) error {
+	q := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %v (
+		version {bigint} NOT NULL PRIMARY KEY,
+		description varchar(255) NOT NULL,
+		applied_on {datetime} NOT NULL
+	){suffix};`, MigrationsTable)
+	if _, err := dbmap.Exec(dialectReplacer(dbmap).Replace(q)); err != nil {
+		return fmt.Errorf("Failed to create %v table: %v", MigrationsTable, err)
+	}
+	q = fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %v (
+		version {bigint} NOT NULL,
+		direction varchar(10) NOT NULL,
+		statements {int} NOT NULL,
+		PRIMARY KEY (version, direction)
+	){suffix};`, MigrationProgressTable)
+	if _, err := dbmap.Exec(dialectReplacer(dbmap).Replace(q)); err != nil {
+		return fmt.Errorf("Failed to create %v table: %v", MigrationProgressTable, err)
+	}
+	return nil
+}
+
+// dialectReplacer returns a replacer of migration placeholders with SQL specific
+// to a mapper's dialect. Types match the ones gorp uses when creating tables.
+func dialectReplacer(dbmap *gorp.DbMap) *strings.Replacer {
+	var pk, integer, bigint, boolean, datetime, uuid string
+	switch dbmap.Dialect.(type) {
+	case gorp.MySQLDialect:
+		pk = "bigint NOT NULL AUTO_INCREMENT PRIMARY KEY"
+		integer, bigint, boolean, datetime = "int", "bigint", "boolean", "datetime"
+		uuid = "UUID()"
+	case gorp.PostgresDialect:
+		pk = "bigserial NOT NULL PRIMARY KEY"
+		integer, bigint, boolean, datetime = "integer", "bigint", "boolean", "timestamp with time zone"
+		uuid = "md5(random()::text || clock_timestamp()::text)::uuid::text"
+	default:
+		pk = "integer NOT NULL PRIMARY KEY AUTOINCREMENT"
+		integer, bigint, boolean, datetime = "integer", "integer", "integer", "datetime"
+		// A version 4 UUID built of random bytes
+		uuid = "lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || " +
+			"substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6)))"
+	}
+	return strings.NewReplacer(
+		"{pk}", pk,
+		"{int}", integer,
+		"{bigint}", bigint,
+		"{bool}", boolean,
+		"{datetime}", datetime,
+		"{uuid}", uuid,
+		"{user}", dbmap.Dialect.QuotedTableForQuery("", "user"),
+		"{suffix}", dbmap.Dialect.CreateTableSuffix(),
+	)
+}
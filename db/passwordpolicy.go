@@ -0,0 +1,134 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+	"gopkg.in/gorp.v1"
+)
+
+// PasswordPolicy table
+type PasswordPolicy struct {
+	DomainPK      int64     `db:"domain_id"`
+	MinLength     int       `db:"min_length"`
+	RequireLower  bool      `db:"require_lower"`
+	RequireUpper  bool      `db:"require_upper"`
+	RequireDigit  bool      `db:"require_digit"`
+	RequireSymbol bool      `db:"require_symbol"`
+	Blocklist     bool      `db:"blocklist"`
+	History       int       `db:"history"`
+	MaxAgeDays    int       `db:"max_age_days"`
+	UpdatedOn     time.Time `db:"updated_on"`
+}
+
+// PasswordPolicyView contains all fields for populating the entity
+type PasswordPolicyView struct {
+	PasswordPolicy
+	// Field resulted as join to domain table
+	DomainID string `db:"domain_object_id"`
+}
+
+const passwordPolicyViewQuery = `SELECT p.*, d.object_id AS domain_object_id
+		FROM password_policy AS p
+		INNER JOIN domain AS d ON d.domain_id = p.domain_id`
+
+//
+// PasswordPolicyRepository is a gorp-backed implementation of
+// usecases.PasswordPolicyRepository
+//
+type PasswordPolicyRepository struct {
+	DBMap *gorp.DbMap
+}
+
+// Save inserts a policy of a domain or updates the existing one
+func (repo *PasswordPolicyRepository) Save(policy entities.PasswordPolicy) error {
+	d, err := findDomain(repo.DBMap, "object_id", policy.DomainID)
+	if err != nil {
+		return err
+	}
+	p := &PasswordPolicy{
+		DomainPK:      d.PK,
+		MinLength:     policy.MinLength,
+		RequireLower:  policy.RequireLower,
+		RequireUpper:  policy.RequireUpper,
+		RequireDigit:  policy.RequireDigit,
+		RequireSymbol: policy.RequireSymbol,
+		Blocklist:     policy.Blocklist,
+		History:       policy.History,
+		MaxAgeDays:    policy.MaxAgeDays,
+		UpdatedOn:     time.Now().UTC(),
+	}
+	n, err := repo.DBMap.Update(p)
+	if err == nil && n == 0 {
+		err = repo.DBMap.Insert(p)
+	}
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to save a password policy", err)
+	}
+	return nil
+}
+
+// Delete deletes a policy of a domain
+func (repo *PasswordPolicyRepository) Delete(domainID string) error {
+	q := "DELETE FROM password_policy WHERE domain_id IN (SELECT domain_id FROM domain WHERE object_id = ?)"
+	res, err := repo.DBMap.Exec(Rebind(repo.DBMap.Dialect, q), domainID)
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to delete password policy by given domain ID", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to delete password policy by given domain ID", err)
+	} else if n == 0 {
+		return errs.NewDataAccessError(errs.ErrorTypeNotFound, "Password policy not found by given domain ID", nil)
+	}
+	return nil
+}
+
+// FindByDomain finds a policy of a domain
+func (repo *PasswordPolicyRepository) FindByDomain(domainID string) (*entities.PasswordPolicy, error) {
+	var p PasswordPolicyView
+	err := repo.DBMap.SelectOne(&p, Rebind(repo.DBMap.Dialect, passwordPolicyViewQuery+" WHERE d.object_id = ?"), domainID)
+	if err == sql.ErrNoRows {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "Password policy not found by given domain ID", err)
+	} else if err != nil {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to perform a lookup of a password policy", err)
+	}
+	return passwordPolicyToEntity(&p), nil
+}
+
+// ListByUser lists policies of all domains of a user
+func (repo *PasswordPolicyRepository) ListByUser(userID string) ([]entities.PasswordPolicy, error) {
+	var records []PasswordPolicyView
+	q := passwordPolicyViewQuery + ` WHERE p.domain_id IN (
+			SELECT domain_id FROM domain_user WHERE user_id
+				IN (SELECT user_id FROM %v WHERE object_id = ?)
+		)`
+	userTbl := repo.DBMap.Dialect.QuotedTableForQuery("", "user")
+	_, err := repo.DBMap.Select(&records, Rebind(repo.DBMap.Dialect, fmt.Sprintf(q, userTbl)), userID)
+	if err != nil {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to perform a lookup of password policies", err)
+	}
+	policies := []entities.PasswordPolicy{}
+	for i := range records {
+		policies = append(policies, *passwordPolicyToEntity(&records[i]))
+	}
+	return policies, nil
+}
+
+func passwordPolicyToEntity(p *PasswordPolicyView) *entities.PasswordPolicy {
+	e := &entities.PasswordPolicy{
+		DomainID:      p.DomainID,
+		MinLength:     p.MinLength,
+		RequireLower:  p.RequireLower,
+		RequireUpper:  p.RequireUpper,
+		RequireDigit:  p.RequireDigit,
+		RequireSymbol: p.RequireSymbol,
+		Blocklist:     p.Blocklist,
+		History:       p.History,
+		MaxAgeDays:    p.MaxAgeDays,
+	}
+	e.UpdatedOn.Time = p.UpdatedOn
+	return e
+}
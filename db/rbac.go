@@ -1,6 +1,14 @@
 package db
 
-import "gopkg.in/gorp.v1"
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+	"github.com/oleksandr/idp/usecases"
+	"gopkg.in/gorp.v1"
+)
 
 // Permission table
 type Permission struct {
@@ -25,33 +33,840 @@ type RolePermission struct {
 	PermissionPK int64 `db:"permission_id"`
 }
 
-// DeleteRole deletes a role
-func DeleteRole(dbmap *gorp.DbMap, name string) error {
-	var r Role
-	err := dbmap.SelectOne(&r, "SELECT * FROM role WHERE name = ?", name)
+// RoleInheritance table. A senior role inherits all permissions of a junior role.
+type RoleInheritance struct {
+	SeniorPK int64 `db:"senior_role_id"`
+	JuniorPK int64 `db:"junior_role_id"`
+}
+
+// SoDConstraint table of static separation of duty constraints
+type SoDConstraint struct {
+	PK          int64  `db:"constraint_id"`
+	Name        string `db:"name"`
+	Cardinality int    `db:"cardinality"`
+}
+
+// SoDConstraintRole table
+type SoDConstraintRole struct {
+	ConstraintPK int64 `db:"constraint_id"`
+	RolePK       int64 `db:"role_id"`
+}
+
+//
+// PermissionRepository is a gorp-backed implementation of usecases.PermissionRepository
+//
+type PermissionRepository struct {
+	DBMap *gorp.DbMap
+}
+
+// Create inserts a new permission
+func (repo *PermissionRepository) Create(p entities.BasicPermission) error {
+	d := &Permission{
+		Name:           p.Name,
+		Description:    p.Description,
+		Enabled:        p.Enabled,
+		EvaluationRule: p.EvaluationRule,
+	}
+	err := repo.DBMap.Insert(d)
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to create a permission", err)
+	}
+	return nil
+}
+
+// Update updates all attributes of a permission found by name
+func (repo *PermissionRepository) Update(perm entities.BasicPermission) error {
+	p, err := findPermission(repo.DBMap, perm.Name)
 	if err != nil {
 		return err
 	}
 
-	tx, err := dbmap.Begin()
+	p.Description = perm.Description
+	p.Enabled = perm.Enabled
+	p.EvaluationRule = perm.EvaluationRule
+
+	_, err = repo.DBMap.Update(p)
 	if err != nil {
-		return nil
+		return errs.NewDataAccessError(errs.ErrorTypeConflict, "Failed to update permission", err)
+	}
+	return nil
+}
+
+// Rename renames a permission
+func (repo *PermissionRepository) Rename(oldName, newName string) error {
+	p, err := findPermission(repo.DBMap, oldName)
+	if err != nil {
+		return err
 	}
 
-	_, err = tx.Exec("DELETE FROM user_role WHERE role_id = ?;", r.PK)
+	p.Name = newName
+
+	_, err = repo.DBMap.Update(p)
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeConflict, "Failed to rename permission", err)
+	}
+	return nil
+}
+
+// Delete deletes a permission and removes it from all roles
+func (repo *PermissionRepository) Delete(name string) error {
+	p, err := findPermission(repo.DBMap, name)
+	if err != nil {
+		return err
+	}
+
+	tx, err := repo.DBMap.Begin()
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to begin transaction", err)
+	}
+	_, err = tx.Exec(Rebind(repo.DBMap.Dialect, "DELETE FROM role_permission WHERE permission_id = ?;"), p.PK)
+	if err != nil {
+		tx.Rollback()
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to delete permission by given name", err)
+	}
+	_, err = tx.Delete(p)
 	if err != nil {
 		tx.Rollback()
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to delete permission by given name", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to commit transaction", err)
+	}
+	return nil
+}
+
+// FindByName finds a permission by name
+func (repo *PermissionRepository) FindByName(name string) (*entities.BasicPermission, error) {
+	p, err := findPermission(repo.DBMap, name)
+	if err != nil {
+		return nil, err
+	}
+	return permissionToEntity(p), nil
+}
+
+// List returns a page of permissions along with the total number of permissions
+func (repo *PermissionRepository) List(pager entities.Pager, sorter entities.Sorter) ([]entities.BasicPermission, int64, error) {
+	total, err := repo.DBMap.SelectInt("SELECT COUNT(*) FROM permission")
+	if err != nil {
+		return nil, 0, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to count permissions", err)
+	}
+
+	var records []Permission
+	q := "SELECT * FROM permission AS p %v %v;"
+	_, err = repo.DBMap.Select(&records, fmt.Sprintf(q, OrderByClause(sorter, "p"), LimitOffset(pager)))
+	if err != nil {
+		return nil, 0, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to perform a lookup of permissions", err)
+	}
+	return permissionsToEntities(records), total, nil
+}
+
+// ListByRoles returns a page of distinct permissions of given roles along with the total
+// number of the roles' permissions
+func (repo *PermissionRepository) ListByRoles(roleNames []string, pager entities.Pager, sorter entities.Sorter) ([]entities.BasicPermission, int64, error) {
+	if len(roleNames) == 0 {
+		return []entities.BasicPermission{}, 0, nil
+	}
+	args := stringArgs(roleNames)
+
+	q := fmt.Sprintf(`SELECT COUNT(DISTINCT rp.permission_id) FROM role_permission AS rp
+		INNER JOIN role AS r ON r.role_id = rp.role_id
+		WHERE r.name IN (%v);`, placeholders(len(roleNames)))
+	total, err := repo.DBMap.SelectInt(Rebind(repo.DBMap.Dialect, q), args...)
+	if err != nil {
+		return nil, 0, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to count permissions", err)
+	}
+
+	var records []Permission
+	q = fmt.Sprintf(`SELECT p.* FROM permission AS p
+		WHERE p.permission_id IN (
+			SELECT rp.permission_id FROM role_permission AS rp
+			INNER JOIN role AS r ON r.role_id = rp.role_id
+			WHERE r.name IN (%v)
+		) %v %v;`, placeholders(len(roleNames)), OrderByClause(sorter, "p"), LimitOffset(pager))
+	_, err = repo.DBMap.Select(&records, Rebind(repo.DBMap.Dialect, q), args...)
+	if err != nil {
+		return nil, 0, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to perform a lookup of permissions for role", err)
+	}
+	return permissionsToEntities(records), total, nil
+}
+
+//
+// RoleRepository is a gorp-backed implementation of usecases.RoleRepository
+//
+type RoleRepository struct {
+	DBMap *gorp.DbMap
+}
+
+// Create inserts a new role
+func (repo *RoleRepository) Create(r entities.BasicRole) error {
+	d := &Role{
+		Name:        r.Name,
+		Description: r.Description,
+		Enabled:     r.Enabled,
+	}
+	err := repo.DBMap.Insert(d)
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to create a role", err)
+	}
+	return nil
+}
+
+// Update updates all attributes of a role found by name
+func (repo *RoleRepository) Update(role entities.BasicRole) error {
+	r, err := findRole(repo.DBMap, role.Name)
+	if err != nil {
+		return err
+	}
+
+	r.Description = role.Description
+	r.Enabled = role.Enabled
+
+	_, err = repo.DBMap.Update(r)
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeConflict, "Failed to update a role", err)
+	}
+	return nil
+}
+
+// Rename renames a role
+func (repo *RoleRepository) Rename(oldName, newName string) error {
+	r, err := findRole(repo.DBMap, oldName)
+	if err != nil {
+		return err
+	}
+
+	r.Name = newName
+
+	_, err = repo.DBMap.Update(r)
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeConflict, "Failed to rename a role", err)
+	}
+	return nil
+}
+
+// Delete deletes a role along with its assignments, permissions, inheritance and
+// membership in separation of duty constraints
+func (repo *RoleRepository) Delete(name string) error {
+	r, err := findRole(repo.DBMap, name)
+	if err != nil {
 		return err
 	}
-	_, err = tx.Exec("DELETE FROM role_permission WHERE role_id = ?;", r.PK)
+
+	tx, err := repo.DBMap.Begin()
 	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to begin transaction", err)
+	}
+	for _, q := range []string{
+		"DELETE FROM user_role WHERE role_id = ?;",
+		"DELETE FROM role_permission WHERE role_id = ?;",
+		"DELETE FROM role_inheritance WHERE senior_role_id = ?;",
+		"DELETE FROM role_inheritance WHERE junior_role_id = ?;",
+		"DELETE FROM sod_constraint_role WHERE role_id = ?;",
+	} {
+		if _, err = tx.Exec(Rebind(repo.DBMap.Dialect, q), r.PK); err != nil {
+			tx.Rollback()
+			return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to delete role by given name", err)
+		}
+	}
+	if _, err = tx.Delete(r); err != nil {
 		tx.Rollback()
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to delete role by given name", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to commit transaction", err)
+	}
+	return nil
+}
+
+// FindByName finds a role by name
+func (repo *RoleRepository) FindByName(name string) (*entities.BasicRole, error) {
+	r, err := findRole(repo.DBMap, name)
+	if err != nil {
+		return nil, err
+	}
+	return roleToEntity(r), nil
+}
+
+// List returns a page of roles along with the total number of roles
+func (repo *RoleRepository) List(pager entities.Pager, sorter entities.Sorter) ([]entities.BasicRole, int64, error) {
+	total, err := repo.DBMap.SelectInt("SELECT COUNT(*) FROM role")
+	if err != nil {
+		return nil, 0, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to count roles", err)
+	}
+
+	var records []Role
+	q := "SELECT * FROM role AS r %v %v;"
+	_, err = repo.DBMap.Select(&records, fmt.Sprintf(q, OrderByClause(sorter, "r"), LimitOffset(pager)))
+	if err != nil {
+		return nil, 0, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to perform a lookup of roles", err)
+	}
+	return rolesToEntities(records), total, nil
+}
+
+// ListByNames returns existing roles with given names
+func (repo *RoleRepository) ListByNames(names []string) ([]entities.BasicRole, error) {
+	if len(names) == 0 {
+		return []entities.BasicRole{}, nil
+	}
+	var records []Role
+	q := fmt.Sprintf("SELECT * FROM role WHERE name IN (%v);", placeholders(len(names)))
+	_, err := repo.DBMap.Select(&records, Rebind(repo.DBMap.Dialect, q), stringArgs(names)...)
+	if err != nil {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to perform a lookup of roles", err)
+	}
+	return rolesToEntities(records), nil
+}
+
+// ListByUser returns a page of roles assigned to a given user in any domain or
+// globally along with the total number of such roles
+func (repo *RoleRepository) ListByUser(userID string, pager entities.Pager, sorter entities.Sorter) ([]entities.BasicRole, int64, error) {
+	userTbl := repo.DBMap.Dialect.QuotedTableForQuery("", "user")
+
+	q := fmt.Sprintf("SELECT COUNT(DISTINCT role_id) FROM user_role WHERE user_id IN (SELECT user_id FROM %v WHERE object_id = ?);", userTbl)
+	total, err := repo.DBMap.SelectInt(Rebind(repo.DBMap.Dialect, q), userID)
+	if err != nil {
+		return nil, 0, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to count roles for given user", err)
+	}
+
+	var records []Role
+	q = fmt.Sprintf(`SELECT r.* FROM role AS r
+		WHERE r.role_id IN (
+			SELECT ur.role_id FROM user_role AS ur
+			INNER JOIN %v AS u ON u.user_id = ur.user_id
+			WHERE u.object_id = ?
+		) %v %v;`, userTbl, OrderByClause(sorter, "r"), LimitOffset(pager))
+	_, err = repo.DBMap.Select(&records, Rebind(repo.DBMap.Dialect, q), userID)
+	if err != nil {
+		return nil, 0, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to perform a lookup of roles for given user", err)
+	}
+	return rolesToEntities(records), total, nil
+}
+
+// ListAssignedNames returns names of enabled roles assigned to a given enabled user
+// either globally or in a given domain
+func (repo *RoleRepository) ListAssignedNames(userID, domainID string) ([]string, error) {
+	userTbl := repo.DBMap.Dialect.QuotedTableForQuery("", "user")
+
+	var names []string
+	q := fmt.Sprintf(`SELECT DISTINCT r.name FROM user_role AS ur
+		INNER JOIN %v AS u ON u.user_id = ur.user_id
+		INNER JOIN role AS r ON r.role_id = ur.role_id
+		WHERE u.object_id=?
+			AND (ur.domain_id=0 OR ur.domain_id IN (SELECT domain_id FROM domain WHERE object_id=?))
+			AND u.is_enabled=? AND r.is_enabled=?;`, userTbl)
+	_, err := repo.DBMap.Select(&names, Rebind(repo.DBMap.Dialect, q), userID, domainID, true, true)
+	if err != nil {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to perform a lookup of roles for given user", err)
+	}
+	return names, nil
+}
+
+// AddPermissions adds given permissions to a role
+func (repo *RoleRepository) AddPermissions(roleName string, permissionNames []string) error {
+	r, err := findRole(repo.DBMap, roleName)
+	if err != nil {
+		return err
+	}
+	pks, err := findPermissionPKs(repo.DBMap, permissionNames)
+	if err != nil {
+		return err
+	}
+
+	tx, err := repo.DBMap.Begin()
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to begin transaction", err)
+	}
+	for _, pk := range pks {
+		err = tx.Insert(&RolePermission{
+			RolePK:       r.PK,
+			PermissionPK: pk,
+		})
+		if err != nil {
+			tx.Rollback()
+			return errs.NewDataAccessError(errs.ErrorTypeConflict, "Failed to add permission to role", err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to commit transaction", err)
+	}
+	return nil
+}
+
+// RemovePermissions removes given permissions from a role
+func (repo *RoleRepository) RemovePermissions(roleName string, permissionNames []string) error {
+	r, err := findRole(repo.DBMap, roleName)
+	if err != nil {
+		return err
+	}
+	pks, err := findPermissionPKs(repo.DBMap, permissionNames)
+	if err != nil {
 		return err
 	}
-	_, err = tx.Delete(&r)
+
+	tx, err := repo.DBMap.Begin()
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to begin transaction", err)
+	}
+	for _, pk := range pks {
+		_, err = tx.Delete(&RolePermission{
+			RolePK:       r.PK,
+			PermissionPK: pk,
+		})
+		if err != nil {
+			tx.Rollback()
+			return errs.NewDataAccessError(errs.ErrorTypeConflict, "Failed to remove permission from role", err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to commit transaction", err)
+	}
+	return nil
+}
+
+// Inheritance returns role inheritance as a map of a senior role's name to names of
+// the roles it directly inherits. If enabledOnly is set, disabled junior roles are
+// left out, so neither they nor the roles they inherit are granted.
+func (repo *RoleRepository) Inheritance(enabledOnly bool) (map[string][]string, error) {
+	return loadInheritance(repo.DBMap, repo.DBMap, enabledOnly)
+}
+
+// Inherit makes a senior role inherit given junior roles. Existing inheritance is kept as is.
+func (repo *RoleRepository) Inherit(seniorRoleName string, juniorRoleNames []string, check usecases.RoleCheck) error {
+	senior, err := findRole(repo.DBMap, seniorRoleName)
+	if err != nil {
+		return err
+	}
+	var juniors []*Role
+	for _, name := range juniorRoleNames {
+		r, err := findRole(repo.DBMap, name)
+		if err != nil {
+			return err
+		}
+		juniors = append(juniors, r)
+	}
+
+	tx, err := repo.DBMap.Begin()
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to begin transaction", err)
+	}
+	for _, r := range juniors {
+		n, err := tx.SelectInt(Rebind(repo.DBMap.Dialect, "SELECT COUNT(*) FROM role_inheritance WHERE senior_role_id = ? AND junior_role_id = ?;"), senior.PK, r.PK)
+		if err != nil {
+			tx.Rollback()
+			return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to load role inheritance", err)
+		}
+		if n > 0 {
+			continue
+		}
+		err = tx.Insert(&RoleInheritance{
+			SeniorPK: senior.PK,
+			JuniorPK: r.PK,
+		})
+		if err != nil {
+			tx.Rollback()
+			return errs.NewDataAccessError(errs.ErrorTypeConflict, "Failed to inherit role", err)
+		}
+	}
+	return repo.checkAndCommit(tx, 0, check)
+}
+
+// Uninherit removes given junior roles from roles inherited by a senior role
+func (repo *RoleRepository) Uninherit(seniorRoleName string, juniorRoleNames []string) error {
+	senior, err := findRole(repo.DBMap, seniorRoleName)
+	if err != nil {
+		return err
+	}
+	var juniors []int64
+	for _, name := range juniorRoleNames {
+		r, err := findRole(repo.DBMap, name)
+		if err != nil {
+			return err
+		}
+		juniors = append(juniors, r.PK)
+	}
+
+	tx, err := repo.DBMap.Begin()
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to begin transaction", err)
+	}
+	for _, pk := range juniors {
+		_, err = tx.Delete(&RoleInheritance{
+			SeniorPK: senior.PK,
+			JuniorPK: pk,
+		})
+		if err != nil {
+			tx.Rollback()
+			return errs.NewDataAccessError(errs.ErrorTypeConflict, "Failed to uninherit role", err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to commit transaction", err)
+	}
+	return nil
+}
+
+// Assign assigns given roles to a user in a given domain or globally if the domain ID is empty
+func (repo *RoleRepository) Assign(userID string, roleNames []string, domainID string, check usecases.RoleCheck) error {
+	u, domainPK, rolePKs, err := repo.findAssignment(userID, roleNames, domainID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := repo.DBMap.Begin()
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to begin transaction", err)
+	}
+	for _, pk := range rolePKs {
+		err = tx.Insert(&UserRole{
+			UserPK:   u.PK,
+			RolePK:   pk,
+			DomainPK: domainPK,
+		})
+		if err != nil {
+			tx.Rollback()
+			return errs.NewDataAccessError(errs.ErrorTypeConflict, "Failed to assign role to user", err)
+		}
+	}
+	return repo.checkAndCommit(tx, u.PK, check)
+}
+
+// Revoke revokes given roles from a user in a given domain or globally if the domain ID is empty
+func (repo *RoleRepository) Revoke(userID string, roleNames []string, domainID string) error {
+	u, domainPK, rolePKs, err := repo.findAssignment(userID, roleNames, domainID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := repo.DBMap.Begin()
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to begin transaction", err)
+	}
+	for _, pk := range rolePKs {
+		_, err = tx.Delete(&UserRole{
+			UserPK:   u.PK,
+			RolePK:   pk,
+			DomainPK: domainPK,
+		})
+		if err != nil {
+			tx.Rollback()
+			return errs.NewDataAccessError(errs.ErrorTypeConflict, "Failed to revoke role from user", err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to commit transaction", err)
+	}
+	return nil
+}
+
+// CreateSoDConstraint inserts a new separation of duty constraint
+func (repo *RoleRepository) CreateSoDConstraint(c entities.SoDConstraint, check usecases.RoleCheck) error {
+	var rolePKs []int64
+	for _, name := range c.Roles {
+		r, err := findRole(repo.DBMap, name)
+		if err != nil {
+			return err
+		}
+		rolePKs = append(rolePKs, r.PK)
+	}
+
+	tx, err := repo.DBMap.Begin()
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to begin transaction", err)
+	}
+	d := &SoDConstraint{
+		Name:        c.Name,
+		Cardinality: c.Cardinality,
+	}
+	err = tx.Insert(d)
+	if err != nil {
+		tx.Rollback()
+		return errs.NewDataAccessError(errs.ErrorTypeConflict, "Failed to create a constraint", err)
+	}
+	for _, pk := range rolePKs {
+		err = tx.Insert(&SoDConstraintRole{
+			ConstraintPK: d.PK,
+			RolePK:       pk,
+		})
+		if err != nil {
+			tx.Rollback()
+			return errs.NewDataAccessError(errs.ErrorTypeConflict, "Failed to add role to constraint", err)
+		}
+	}
+	return repo.checkAndCommit(tx, 0, check)
+}
+
+// DeleteSoDConstraint deletes a separation of duty constraint by name
+func (repo *RoleRepository) DeleteSoDConstraint(name string) error {
+	var c SoDConstraint
+	err := repo.DBMap.SelectOne(&c, Rebind(repo.DBMap.Dialect, "SELECT * FROM sod_constraint WHERE name = ?"), name)
+	if err == sql.ErrNoRows {
+		return errs.NewDataAccessError(errs.ErrorTypeNotFound, "Constraint not found by given name", err)
+	} else if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to perform a lookup of a constraint", err)
+	}
+
+	tx, err := repo.DBMap.Begin()
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to begin transaction", err)
+	}
+	_, err = tx.Exec(Rebind(repo.DBMap.Dialect, "DELETE FROM sod_constraint_role WHERE constraint_id = ?;"), c.PK)
+	if err != nil {
+		tx.Rollback()
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to delete a constraint", err)
+	}
+	_, err = tx.Delete(&c)
+	if err != nil {
+		tx.Rollback()
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to delete a constraint", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to commit transaction", err)
+	}
+	return nil
+}
+
+// ListSoDConstraints returns a page of separation of duty constraints along with
+// the total number of constraints
+func (repo *RoleRepository) ListSoDConstraints(pager entities.Pager, sorter entities.Sorter) ([]entities.SoDConstraint, int64, error) {
+	total, err := repo.DBMap.SelectInt("SELECT COUNT(*) FROM sod_constraint")
+	if err != nil {
+		return nil, 0, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to count constraints", err)
+	}
+
+	var records []SoDConstraint
+	q := "SELECT * FROM sod_constraint AS c %v %v;"
+	_, err = repo.DBMap.Select(&records, fmt.Sprintf(q, OrderByClause(sorter, "c"), LimitOffset(pager)))
+	if err != nil {
+		return nil, 0, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to perform a lookup of constraints", err)
+	}
+
+	all, err := loadSoDConstraints(repo.DBMap)
+	if err != nil {
+		return nil, 0, err
+	}
+	roles := map[string][]string{}
+	for _, c := range all {
+		roles[c.Name] = c.Roles
+	}
+
+	constraints := []entities.SoDConstraint{}
+	for _, c := range records {
+		constraints = append(constraints, entities.SoDConstraint{
+			Name:        c.Name,
+			Roles:       roles[c.Name],
+			Cardinality: c.Cardinality,
+		})
+	}
+	return constraints, total, nil
+}
+
+// findAssignment resolves a user, a domain (0 for global assignments) and roles of
+// a role assignment
+func (repo *RoleRepository) findAssignment(userID string, roleNames []string, domainID string) (*User, int64, []int64, error) {
+	u, err := findUser(repo.DBMap, "object_id", userID)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	var domainPK int64
+	if domainID != "" {
+		d, err := findDomain(repo.DBMap, "object_id", domainID)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		domainPK = d.PK
+	}
+	var rolePKs []int64
+	for _, name := range roleNames {
+		r, err := findRole(repo.DBMap, name)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		rolePKs = append(rolePKs, r.PK)
+	}
+	return u, domainPK, rolePKs, nil
+}
+
+// checkAndCommit runs a check against the state of RBAC relations seen by a transaction
+// and commits the transaction if it passes. Only assignments of a given user are loaded
+// unless the user's PK is 0.
+func (repo *RoleRepository) checkAndCommit(tx *gorp.Transaction, userPK int64, check usecases.RoleCheck) error {
+	state, err := loadRoleState(repo.DBMap, tx, userPK)
+	if err == nil {
+		err = check(*state)
+	}
 	if err != nil {
 		tx.Rollback()
 		return err
 	}
-	return tx.Commit()
+	if err = tx.Commit(); err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to commit transaction", err)
+	}
+	return nil
+}
+
+// loadRoleState loads the state of RBAC relations a RoleCheck is performed against
+func loadRoleState(dbmap *gorp.DbMap, exec gorp.SqlExecutor, userPK int64) (*usecases.RoleState, error) {
+	var (
+		state = &usecases.RoleState{}
+		err   error
+	)
+	state.Inheritance, err = loadInheritance(dbmap, exec, false)
+	if err != nil {
+		return nil, err
+	}
+	state.Constraints, err = loadSoDConstraints(exec)
+	if err != nil || len(state.Constraints) == 0 {
+		return state, err
+	}
+
+	userTbl := dbmap.Dialect.QuotedTableForQuery("", "user")
+	q := fmt.Sprintf(`SELECT u.object_id AS user_id, u.name AS user_name, r.name AS role_name,
+			COALESCE(d.object_id, '') AS domain_id FROM user_role AS ur
+		INNER JOIN %v AS u ON u.user_id = ur.user_id
+		INNER JOIN role AS r ON r.role_id = ur.role_id
+		LEFT JOIN domain AS d ON d.domain_id = ur.domain_id`, userTbl)
+	args := []interface{}{}
+	if userPK != 0 {
+		q += " WHERE ur.user_id = ?"
+		args = append(args, userPK)
+	}
+	var records []struct {
+		UserID   string `db:"user_id"`
+		UserName string `db:"user_name"`
+		RoleName string `db:"role_name"`
+		DomainID string `db:"domain_id"`
+	}
+	_, err = exec.Select(&records, Rebind(dbmap.Dialect, q+" ORDER BY u.name;"), args...)
+	if err != nil {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to perform a lookup of role assignments", err)
+	}
+	for _, r := range records {
+		state.Assignments = append(state.Assignments, usecases.RoleAssignment{
+			UserID:   r.UserID,
+			UserName: r.UserName,
+			RoleName: r.RoleName,
+			DomainID: r.DomainID,
+		})
+	}
+	return state, nil
+}
+
+// loadInheritance loads role inheritance by role names
+func loadInheritance(dbmap *gorp.DbMap, exec gorp.SqlExecutor, enabledOnly bool) (map[string][]string, error) {
+	q := `SELECT s.name AS senior_name, j.name AS junior_name FROM role_inheritance AS i
+		INNER JOIN role AS s ON s.role_id = i.senior_role_id
+		INNER JOIN role AS j ON j.role_id = i.junior_role_id`
+	args := []interface{}{}
+	if enabledOnly {
+		q += " WHERE j.is_enabled=?"
+		args = append(args, true)
+	}
+	var edges []struct {
+		SeniorName string `db:"senior_name"`
+		JuniorName string `db:"junior_name"`
+	}
+	_, err := exec.Select(&edges, Rebind(dbmap.Dialect, q+";"), args...)
+	if err != nil {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to load role inheritance", err)
+	}
+
+	inheritance := map[string][]string{}
+	for _, e := range edges {
+		inheritance[e.SeniorName] = append(inheritance[e.SeniorName], e.JuniorName)
+	}
+	return inheritance, nil
+}
+
+// loadSoDConstraints loads all separation of duty constraints along with their roles
+func loadSoDConstraints(exec gorp.SqlExecutor) ([]entities.SoDConstraint, error) {
+	var records []struct {
+		Name        string `db:"name"`
+		Cardinality int    `db:"cardinality"`
+		RoleName    string `db:"role_name"`
+	}
+	q := `SELECT c.name, c.cardinality, r.name AS role_name FROM sod_constraint AS c
+		INNER JOIN sod_constraint_role AS cr ON cr.constraint_id = c.constraint_id
+		INNER JOIN role AS r ON r.role_id = cr.role_id
+		ORDER BY c.name, r.name;`
+	_, err := exec.Select(&records, q)
+	if err != nil {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to load separation of duty constraints", err)
+	}
+
+	var constraints []entities.SoDConstraint
+	for _, r := range records {
+		if len(constraints) == 0 || constraints[len(constraints)-1].Name != r.Name {
+			constraints = append(constraints, entities.SoDConstraint{
+				Name:        r.Name,
+				Cardinality: r.Cardinality,
+			})
+		}
+		c := &constraints[len(constraints)-1]
+		c.Roles = append(c.Roles, r.RoleName)
+	}
+	return constraints, nil
+}
+
+func findPermission(dbmap *gorp.DbMap, name string) (*Permission, error) {
+	var p Permission
+	err := dbmap.SelectOne(&p, Rebind(dbmap.Dialect, "SELECT * FROM permission WHERE name = ?"), name)
+	if err == sql.ErrNoRows {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "Permission not found by given name", err)
+	} else if err != nil {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to perform a lookup of a permission", err)
+	}
+	return &p, nil
+}
+
+// findPermissionPKs resolves PKs of permissions by their names
+func findPermissionPKs(dbmap *gorp.DbMap, names []string) ([]int64, error) {
+	var pks []int64
+	for _, name := range names {
+		pk, err := dbmap.SelectInt(Rebind(dbmap.Dialect, "SELECT permission_id FROM permission WHERE name = ?"), name)
+		if err != nil || pk == 0 {
+			return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "Permission not found by given name", err)
+		}
+		pks = append(pks, pk)
+	}
+	return pks, nil
+}
+
+func findRole(dbmap *gorp.DbMap, name string) (*Role, error) {
+	var r Role
+	err := dbmap.SelectOne(&r, Rebind(dbmap.Dialect, "SELECT * FROM role WHERE name = ?"), name)
+	if err == sql.ErrNoRows {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "Role not found by given name", err)
+	} else if err != nil {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to perform a lookup of a role", err)
+	}
+	return &r, nil
+}
+
+func permissionToEntity(p *Permission) *entities.BasicPermission {
+	e := entities.NewBasicPermission(p.Name, p.Description)
+	e.Enabled = p.Enabled
+	e.EvaluationRule = p.EvaluationRule
+	return e
+}
+
+func permissionsToEntities(records []Permission) []entities.BasicPermission {
+	permissions := []entities.BasicPermission{}
+	for _, p := range records {
+		permissions = append(permissions, *permissionToEntity(&p))
+	}
+	return permissions
+}
+
+func roleToEntity(r *Role) *entities.BasicRole {
+	e := entities.NewBasicRole(r.Name, r.Description)
+	e.Enabled = r.Enabled
+	return e
+}
+
+func rolesToEntities(records []Role) []entities.BasicRole {
+	roles := []entities.BasicRole{}
+	for _, r := range records {
+		roles = append(roles, *roleToEntity(&r))
+	}
+	return roles
 }
@@ -0,0 +1,135 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+	"gopkg.in/gorp.v1"
+)
+
+// ServiceProvider table
+type ServiceProvider struct {
+	PK        int64     `db:"saml_service_provider_id"`
+	ID        string    `db:"object_id"`
+	DomainPK  int64     `db:"domain_id"`
+	Name      string    `db:"name"`
+	EntityID  string    `db:"entity_id"`
+	ACSURL    string    `db:"acs_url"`
+	Enabled   bool      `db:"is_enabled"`
+	CreatedOn time.Time `db:"created_on"`
+}
+
+// ServiceProviderView contains all fields for populating the entity
+type ServiceProviderView struct {
+	ServiceProvider
+	// Field resulted as join to domain table
+	DomainID string `db:"domain_object_id"`
+}
+
+const serviceProviderViewQuery = `SELECT sp.*, d.object_id AS domain_object_id
+		FROM saml_service_provider AS sp
+		INNER JOIN domain AS d ON d.domain_id = sp.domain_id`
+
+//
+// ServiceProviderRepository is a gorp-backed implementation of
+// usecases.ServiceProviderRepository
+//
+type ServiceProviderRepository struct {
+	DBMap *gorp.DbMap
+}
+
+// Create inserts a new service provider
+func (repo *ServiceProviderRepository) Create(sp entities.ServiceProvider) error {
+	d, err := findDomain(repo.DBMap, "object_id", sp.DomainID)
+	if err != nil {
+		return err
+	}
+	s := &ServiceProvider{
+		ID:        sp.ID,
+		DomainPK:  d.PK,
+		Name:      sp.Name,
+		EntityID:  sp.EntityID,
+		ACSURL:    sp.ACSURL,
+		Enabled:   sp.Enabled,
+		CreatedOn: sp.CreatedOn.Time,
+	}
+	err = repo.DBMap.Insert(s)
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to create a service provider", err)
+	}
+	return nil
+}
+
+// Delete deletes a service provider by ID
+func (repo *ServiceProviderRepository) Delete(id string) error {
+	res, err := repo.DBMap.Exec(Rebind(repo.DBMap.Dialect, "DELETE FROM saml_service_provider WHERE object_id = ?"), id)
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to delete service provider by given ID", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to delete service provider by given ID", err)
+	} else if n == 0 {
+		return errs.NewDataAccessError(errs.ErrorTypeNotFound, "Service provider not found by given ID", nil)
+	}
+	return nil
+}
+
+// FindByID finds a service provider by ID
+func (repo *ServiceProviderRepository) FindByID(id string) (*entities.ServiceProvider, error) {
+	return repo.findOne("sp.object_id", id, "Service provider not found by given ID")
+}
+
+// FindByEntityID finds a service provider by entity ID
+func (repo *ServiceProviderRepository) FindByEntityID(entityID string) (*entities.ServiceProvider, error) {
+	return repo.findOne("sp.entity_id", entityID, "Service provider not found by given entity ID")
+}
+
+// List lists service providers of a domain (all of them if the domain ID is empty)
+// ordered by creation date/time
+func (repo *ServiceProviderRepository) List(domainID string) ([]entities.ServiceProvider, error) {
+	var (
+		views []ServiceProviderView
+		err   error
+	)
+	if domainID == "" {
+		_, err = repo.DBMap.Select(&views, serviceProviderViewQuery+" ORDER BY sp.created_on, sp.saml_service_provider_id")
+	} else {
+		q := serviceProviderViewQuery + " WHERE d.object_id = ? ORDER BY sp.created_on, sp.saml_service_provider_id"
+		_, err = repo.DBMap.Select(&views, Rebind(repo.DBMap.Dialect, q), domainID)
+	}
+	if err != nil {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to perform a lookup of service providers", err)
+	}
+	sps := []entities.ServiceProvider{}
+	for i := range views {
+		sps = append(sps, *serviceProviderToEntity(&views[i]))
+	}
+	return sps, nil
+}
+
+// findOne finds a service provider by a value of a given unique column
+func (repo *ServiceProviderRepository) findOne(column, value, notFound string) (*entities.ServiceProvider, error) {
+	var view ServiceProviderView
+	err := repo.DBMap.SelectOne(&view, Rebind(repo.DBMap.Dialect, serviceProviderViewQuery+" WHERE "+column+" = ?"), value)
+	if err == sql.ErrNoRows {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, notFound, err)
+	} else if err != nil {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to perform a lookup of a service provider", err)
+	}
+	return serviceProviderToEntity(&view), nil
+}
+
+func serviceProviderToEntity(view *ServiceProviderView) *entities.ServiceProvider {
+	sp := &entities.ServiceProvider{
+		ID:       view.ID,
+		Name:     view.Name,
+		DomainID: view.DomainID,
+		EntityID: view.EntityID,
+		ACSURL:   view.ACSURL,
+		Enabled:  view.Enabled,
+	}
+	sp.CreatedOn.Time = view.CreatedOn
+	return sp
+}
@@ -1,10 +1,19 @@
 package db
 
-import "time"
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+	"gopkg.in/gorp.v1"
+)
 
 // Session Table
 type Session struct {
 	ID         string    `db:"session_id"`
+	Reference  string    `db:"reference"`
 	DomainPK   int64     `db:"domain_id"`
 	UserPK     int64     `db:"user_id"`
 	UserAgent  string    `db:"user_agent"`
@@ -26,3 +35,178 @@ type SessionView struct {
 	UserName    string `db:"user_name"`
 	UserEnabled bool   `db:"user_enabled"`
 }
+
+//
+// SessionRepository is a gorp-backed implementation of usecases.SessionRepository
+//
+type SessionRepository struct {
+	DBMap *gorp.DbMap
+}
+
+// Create inserts a new session of a user in a domain
+func (repo *SessionRepository) Create(session entities.Session) error {
+	d, err := findDomain(repo.DBMap, "object_id", session.Domain.ID)
+	if err != nil {
+		return err
+	}
+	u, err := findUser(repo.DBMap, "object_id", session.User.ID)
+	if err != nil {
+		return err
+	}
+	s := &Session{
+		ID:         session.ID,
+		Reference:  session.Reference,
+		UserAgent:  session.UserAgent,
+		RemoteAddr: session.RemoteAddr,
+		DomainPK:   d.PK,
+		UserPK:     u.PK,
+		CreatedOn:  session.CreatedOn.Time,
+		UpdatedOn:  session.UpdatedOn.Time,
+		ExpiresOn:  session.ExpiresOn.Time,
+	}
+	err = repo.DBMap.Insert(s)
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to create a session", err)
+	}
+	return nil
+}
+
+// Retain updates session's modification and expiration date/time
+func (repo *SessionRepository) Retain(id string, updatedOn, expiresOn time.Time) error {
+	q := "UPDATE session SET expires_on = ?, updated_on = ? WHERE session_id = ?"
+	_, err := repo.DBMap.Exec(Rebind(repo.DBMap.Dialect, q), expiresOn, updatedOn, id)
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to retain a session", err)
+	}
+	return nil
+}
+
+// Delete deletes a session by ID
+func (repo *SessionRepository) Delete(id string) error {
+	var s Session
+	err := repo.DBMap.SelectOne(&s, Rebind(repo.DBMap.Dialect, "SELECT * FROM session WHERE session_id = ?"), id)
+	if err == sql.ErrNoRows {
+		return errs.NewDataAccessError(errs.ErrorTypeNotFound, "Session not found by given ID", err)
+	} else if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to perform a lookup of a session", err)
+	}
+
+	_, err = repo.DBMap.Delete(&s)
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to delete session", err)
+	}
+	return nil
+}
+
+// DeleteExpired deletes sessions expired by a given time
+func (repo *SessionRepository) DeleteExpired(now time.Time) error {
+	_, err := repo.DBMap.Exec(Rebind(repo.DBMap.Dialect, "DELETE FROM session WHERE expires_on <= ?"), now)
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to purge sessions", err)
+	}
+	return nil
+}
+
+// DeleteByUser deletes all sessions of a user
+func (repo *SessionRepository) DeleteByUser(userID string) error {
+	q := fmt.Sprintf("DELETE FROM session WHERE user_id IN (SELECT user_id FROM %v WHERE object_id = ?)",
+		repo.DBMap.Dialect.QuotedTableForQuery("", "user"))
+	_, err := repo.DBMap.Exec(Rebind(repo.DBMap.Dialect, q), userID)
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to delete sessions", err)
+	}
+	return nil
+}
+
+// DeleteByDomain deletes all sessions in a domain
+func (repo *SessionRepository) DeleteByDomain(domainID string) error {
+	q := "DELETE FROM session WHERE domain_id IN (SELECT domain_id FROM domain WHERE object_id = ?)"
+	_, err := repo.DBMap.Exec(Rebind(repo.DBMap.Dialect, q), domainID)
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to delete sessions", err)
+	}
+	return nil
+}
+
+// FindByID finds a session by ID
+func (repo *SessionRepository) FindByID(id string) (*entities.Session, error) {
+	return repo.findOne("WHERE s.session_id = ?", id)
+}
+
+// FindByReference finds a session by reference
+func (repo *SessionRepository) FindByReference(reference string) (*entities.Session, error) {
+	return repo.findOne("WHERE s.reference = ?", reference)
+}
+
+// FindUserSpecific finds a session of a user in a domain opened with a given
+// user agent from a given remote address
+func (repo *SessionRepository) FindUserSpecific(userID, domainID, userAgent, remoteAddr string) (*entities.Session, error) {
+	return repo.findOne("WHERE u.object_id = ? AND d.object_id = ? AND s.user_agent = ? AND s.remote_addr = ?",
+		userID, domainID, userAgent, remoteAddr)
+}
+
+// List returns a page of sessions along with the total number of sessions
+func (repo *SessionRepository) List(pager entities.Pager, sorter entities.Sorter) ([]entities.Session, int64, error) {
+	total, err := repo.DBMap.SelectInt("SELECT COUNT(*) FROM session")
+	if err != nil {
+		return nil, 0, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to count sessions", err)
+	}
+
+	var records []SessionView
+	q := fmt.Sprintf("%v %v %v;", repo.viewQuery(), OrderByClause(sorter, "s"), LimitOffset(pager))
+	_, err = repo.DBMap.Select(&records, q)
+	if err != nil {
+		return nil, 0, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to perform a lookup of sessions", err)
+	}
+
+	sessions := []entities.Session{}
+	for _, r := range records {
+		sessions = append(sessions, *sessionToEntity(&r))
+	}
+	return sessions, total, nil
+}
+
+func (repo *SessionRepository) findOne(where string, args ...interface{}) (*entities.Session, error) {
+	var sv SessionView
+	q := fmt.Sprintf("%v %v LIMIT 1;", repo.viewQuery(), where)
+	err := repo.DBMap.SelectOne(&sv, Rebind(repo.DBMap.Dialect, q), args...)
+	if err == sql.ErrNoRows {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "Session not found by given ID", err)
+	} else if err != nil {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to perform a lookup of a session", err)
+	}
+	return sessionToEntity(&sv), nil
+}
+
+// viewQuery returns a query selecting sessions joined with their domains and users
+func (repo *SessionRepository) viewQuery() string {
+	return fmt.Sprintf(`SELECT s.*,
+			d.domain_id AS domain_id, d.object_id AS domain_object_id, d.name AS domain_name, d.is_enabled AS domain_enabled,
+			u.user_id AS user_id, u.object_id AS user_object_id, u.name AS user_name, u.is_enabled AS user_enabled
+		FROM session AS s
+        LEFT JOIN %v AS u ON s.user_id=u.user_id
+        LEFT JOIN domain AS d ON d.domain_id=s.domain_id`, repo.DBMap.Dialect.QuotedTableForQuery("", "user"))
+}
+
+func sessionToEntity(s *SessionView) *entities.Session {
+	e := &entities.Session{
+		ID:        s.ID,
+		Reference: s.Reference,
+		Domain: &entities.BasicDomain{
+			ID:      s.DomainID,
+			Name:    s.DomainName,
+			Enabled: s.DomainEnabled,
+		},
+		User: &entities.BasicUser{
+			ID:      s.UserID,
+			Name:    s.UserName,
+			Enabled: s.UserEnabled,
+		},
+		UserAgent:  s.UserAgent,
+		RemoteAddr: s.RemoteAddr,
+	}
+	e.CreatedOn.Time = s.CreatedOn
+	e.UpdatedOn.Time = s.UpdatedOn
+	e.ExpiresOn.Time = s.ExpiresOn
+	return e
+}
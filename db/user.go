@@ -1,20 +1,33 @@
 package db
 
 import (
+	"database/sql"
+	"fmt"
 	"time"
 
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
 	"gopkg.in/gorp.v1"
 )
 
 // User table
 type User struct {
-	PK        int64     `db:"user_id"`
-	ID        string    `db:"object_id"`
-	Name      string    `db:"name"`
+	PK                int64     `db:"user_id"`
+	ID                string    `db:"object_id"`
+	Name              string    `db:"name"`
+	Password          string    `db:"passwd"`
+	PasswordChangedOn time.Time `db:"password_changed_on"`
+	Enabled           bool      `db:"is_enabled"`
+	CreatedOn         time.Time `db:"created_on"`
+	UpdatedOn         time.Time `db:"updated_on"`
+}
+
+// PasswordHistory table of password hashes replaced by new passwords
+type PasswordHistory struct {
+	PK        int64     `db:"password_history_id"`
+	UserPK    int64     `db:"user_id"`
 	Password  string    `db:"passwd"`
-	Enabled   bool      `db:"is_enabled"`
 	CreatedOn time.Time `db:"created_on"`
-	UpdatedOn time.Time `db:"updated_on"`
 }
 
 // UserWithStats is a view with domains count
@@ -23,44 +36,379 @@ type UserWithStats struct {
 	DomainsCount int64 `db:"domains_count"`
 }
 
-// UserRole table
+// UserRole table. DomainPK is 0 for roles assigned globally (in all domains
+// of a user).
 type UserRole struct {
-	UserPK int64 `db:"user_id"`
-	RolePK int64 `db:"role_id"`
+	UserPK   int64 `db:"user_id"`
+	RolePK   int64 `db:"role_id"`
+	DomainPK int64 `db:"domain_id"`
 }
 
-// DeleteUser deletes a domain a referenced records
-func DeleteUser(dbmap *gorp.DbMap, id string) error {
-	var u User
-	err := dbmap.SelectOne(&u, "SELECT * FROM user WHERE object_id = ?", id)
+//
+// UserRepository is a gorp-backed implementation of usecases.UserRepository
+//
+type UserRepository struct {
+	DBMap *gorp.DbMap
+}
+
+// Create inserts a new user and assigns it to given domains
+func (repo *UserRepository) Create(user entities.BasicUser, domainIDs []string) error {
+	domainPKs, err := findDomainPKs(repo.DBMap, domainIDs)
 	if err != nil {
 		return err
 	}
 
-	tx, err := dbmap.Begin()
+	now := time.Now().UTC()
+	u := User{
+		ID:                user.ID,
+		Name:              user.Name,
+		Password:          user.Password,
+		PasswordChangedOn: user.PasswordChangedOn.Time,
+		Enabled:           user.Enabled,
+		CreatedOn:         now,
+		UpdatedOn:         now,
+	}
+	if u.PasswordChangedOn.IsZero() {
+		u.PasswordChangedOn = now
+	}
+
+	tx, err := repo.DBMap.Begin()
 	if err != nil {
-		return nil
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to begin transaction", err)
 	}
 
-	_, err = tx.Exec("DELETE FROM user_session WHERE user_id = ?;", u.PK)
+	err = tx.Insert(&u)
 	if err != nil {
 		tx.Rollback()
+		return errs.NewDataAccessError(errs.ErrorTypeConflict, "Failed to create user", err)
+	}
+
+	for _, pk := range domainPKs {
+		err = tx.Insert(&DomainUser{
+			UserPK:   u.PK,
+			DomainPK: pk,
+		})
+		if err != nil {
+			tx.Rollback()
+			return errs.NewDataAccessError(errs.ErrorTypeConflict, "Failed to assign user to a domain", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to commit transaction", err)
+	}
+	return nil
+}
+
+// Update updates all attributes of a user found by ID and changes its domains. A
+// replaced password hash is added to the user's password history.
+func (repo *UserRepository) Update(user entities.BasicUser, addDomainIDs []string, removeDomainIDs []string) error {
+	u, err := findUser(repo.DBMap, "object_id", user.ID)
+	if err != nil {
 		return err
 	}
-	_, err = tx.Exec("DELETE FROM user_role WHERE user_id = ?;", u.PK)
+	addPKs, err := findDomainPKs(repo.DBMap, addDomainIDs)
 	if err != nil {
-		tx.Rollback()
 		return err
 	}
-	_, err = tx.Exec("DELETE FROM domain_user WHERE user_id = ?;", u.PK)
+	removePKs, err := findDomainPKs(repo.DBMap, removeDomainIDs)
 	if err != nil {
-		tx.Rollback()
 		return err
 	}
-	_, err = tx.Delete(&u)
+
+	now := time.Now().UTC()
+	previous := u.Password
+	if user.Password != previous {
+		u.PasswordChangedOn = user.PasswordChangedOn.Time
+		if u.PasswordChangedOn.IsZero() {
+			u.PasswordChangedOn = now
+		}
+	}
+	u.Name = user.Name
+	u.Password = user.Password
+	u.Enabled = user.Enabled
+	u.UpdatedOn = now
+
+	tx, err := repo.DBMap.Begin()
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to begin transaction", err)
+	}
+
+	_, err = tx.Update(u)
 	if err != nil {
 		tx.Rollback()
+		return errs.NewDataAccessError(errs.ErrorTypeConflict, "Failed to update user", err)
+	}
+
+	// Keep the replaced password hash, but not more than MaxPasswordHistory of them
+	if user.Password != previous {
+		err = tx.Insert(&PasswordHistory{
+			UserPK:    u.PK,
+			Password:  previous,
+			CreatedOn: now,
+		})
+		if err != nil {
+			tx.Rollback()
+			return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to keep a previous password", err)
+		}
+		q := fmt.Sprintf(`SELECT password_history_id FROM password_history WHERE user_id = ?
+			ORDER BY password_history_id DESC LIMIT 1 OFFSET %v`, entities.MaxPasswordHistory)
+		oldest, err := tx.SelectInt(Rebind(repo.DBMap.Dialect, q), u.PK)
+		if err == nil && oldest > 0 {
+			q = "DELETE FROM password_history WHERE user_id = ? AND password_history_id <= ?"
+			_, err = tx.Exec(Rebind(repo.DBMap.Dialect, q), u.PK, oldest)
+		}
+		if err != nil {
+			tx.Rollback()
+			return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to delete old previous passwords", err)
+		}
+	}
+
+	// Assign user to domains
+	for _, pk := range addPKs {
+		err = tx.Insert(&DomainUser{
+			UserPK:   u.PK,
+			DomainPK: pk,
+		})
+		if err != nil {
+			tx.Rollback()
+			return errs.NewDataAccessError(errs.ErrorTypeConflict, "Failed to assign user to a domain", err)
+		}
+	}
+	// Remove user from domains
+	for _, pk := range removePKs {
+		_, err = tx.Delete(&DomainUser{
+			UserPK:   u.PK,
+			DomainPK: pk,
+		})
+		if err != nil {
+			tx.Rollback()
+			return errs.NewDataAccessError(errs.ErrorTypeConflict, "Failed to remove user from a domain", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to commit transaction", err)
+	}
+	return nil
+}
+
+// UpdatePassword replaces a password hash of a user without changing the password
+func (repo *UserRepository) UpdatePassword(id, password string) error {
+	userTbl := repo.DBMap.Dialect.QuotedTableForQuery("", "user")
+	q := fmt.Sprintf("UPDATE %v SET passwd = ?, updated_on = ? WHERE object_id = ?", userTbl)
+	_, err := repo.DBMap.Exec(Rebind(repo.DBMap.Dialect, q), password, time.Now().UTC(), id)
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to update password", err)
+	}
+	return nil
+}
+
+// Delete deletes a user along with its sessions, role assignments, domains membership,
+// MFA enrollment, WebAuthn credentials and previous passwords
+func (repo *UserRepository) Delete(id string) error {
+	u, err := findUser(repo.DBMap, "object_id", id)
+	if err != nil {
 		return err
 	}
-	return tx.Commit()
+
+	tx, err := repo.DBMap.Begin()
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to begin transaction", err)
+	}
+	for _, q := range []string{
+		"DELETE FROM session WHERE user_id = ?;",
+		"DELETE FROM user_role WHERE user_id = ?;",
+		"DELETE FROM domain_user WHERE user_id = ?;",
+		"DELETE FROM mfa_challenge WHERE user_id = ?;",
+		"DELETE FROM mfa_recovery_code WHERE user_id = ?;",
+		"DELETE FROM mfa_enrollment WHERE user_id = ?;",
+		"DELETE FROM webauthn_challenge WHERE user_id = ?;",
+		"DELETE FROM webauthn_credential WHERE user_id = ?;",
+		"DELETE FROM password_history WHERE user_id = ?;",
+	} {
+		if _, err = tx.Exec(Rebind(repo.DBMap.Dialect, q), u.PK); err != nil {
+			tx.Rollback()
+			return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to delete user by given ID", err)
+		}
+	}
+	if _, err = tx.Delete(u); err != nil {
+		tx.Rollback()
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to delete user by given ID", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to commit transaction", err)
+	}
+	return nil
+}
+
+// ListPreviousPasswords lists hashes of a user's previous passwords, most recent first
+func (repo *UserRepository) ListPreviousPasswords(id string, limit int) ([]string, error) {
+	u, err := findUser(repo.DBMap, "object_id", id)
+	if err != nil {
+		return nil, err
+	}
+	var records []PasswordHistory
+	q := fmt.Sprintf("SELECT * FROM password_history WHERE user_id = ? ORDER BY password_history_id DESC LIMIT %v", limit)
+	_, err = repo.DBMap.Select(&records, Rebind(repo.DBMap.Dialect, q), u.PK)
+	if err != nil {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to perform a lookup of previous passwords", err)
+	}
+	hashes := []string{}
+	for _, r := range records {
+		hashes = append(hashes, r.Password)
+	}
+	return hashes, nil
+}
+
+// FindByID finds a user by ID
+func (repo *UserRepository) FindByID(id string) (*entities.BasicUser, error) {
+	u, err := findUser(repo.DBMap, "object_id", id)
+	if err != nil {
+		return nil, err
+	}
+	return userToEntity(u), nil
+}
+
+// FindByName finds a user by name
+func (repo *UserRepository) FindByName(name string) (*entities.BasicUser, error) {
+	u, err := findUser(repo.DBMap, "name", name)
+	if err != nil {
+		return nil, err
+	}
+	return userToEntity(u), nil
+}
+
+// FindInDomain finds a user by ID if it is assigned to a given domain
+func (repo *UserRepository) FindInDomain(userID, domainID string) (*entities.BasicUser, error) {
+	return repo.findInDomain("object_id", userID, domainID)
+}
+
+// FindByNameInDomain finds a user by name if it is assigned to a given domain
+func (repo *UserRepository) FindByNameInDomain(userName, domainID string) (*entities.BasicUser, error) {
+	return repo.findInDomain("name", userName, domainID)
+}
+
+func (repo *UserRepository) findInDomain(column, value, domainID string) (*entities.BasicUser, error) {
+	var (
+		u       User
+		userTbl = repo.DBMap.Dialect.QuotedTableForQuery("", "user")
+	)
+
+	q := fmt.Sprintf(`SELECT u.* FROM domain_user AS du
+		INNER JOIN %v AS u ON u.user_id = du.user_id
+		INNER JOIN domain AS d ON d.domain_id = du.domain_id
+		WHERE u.%v = ?
+		AND d.object_id = ?
+		LIMIT 1;`, userTbl, column)
+	err := repo.DBMap.SelectOne(&u, Rebind(repo.DBMap.Dialect, q), value, domainID)
+	if err == sql.ErrNoRows {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "User not found in a given domain", err)
+	} else if err != nil {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to perform a lookup of a user in a domain", err)
+	}
+	return userToEntity(&u), nil
+}
+
+// CountDomains returns number of domains of a user
+func (repo *UserRepository) CountDomains(id string) (int64, error) {
+	userTbl := repo.DBMap.Dialect.QuotedTableForQuery("", "user")
+	q := `SELECT count(*) FROM domain_user WHERE user_id IN (SELECT user_id FROM %v WHERE object_id = ?);`
+	c, err := repo.DBMap.SelectInt(Rebind(repo.DBMap.Dialect, fmt.Sprintf(q, userTbl)), id)
+	if err != nil {
+		return -1, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to count domains for given user", err)
+	}
+	return c, nil
+}
+
+// List returns a page of users along with the total number of users
+func (repo *UserRepository) List(pager entities.Pager, sorter entities.Sorter) ([]entities.User, int64, error) {
+	userTbl := repo.DBMap.Dialect.QuotedTableForQuery("", "user")
+	total, err := repo.DBMap.SelectInt(fmt.Sprintf("SELECT COUNT(*) FROM %v", userTbl))
+	if err != nil {
+		return nil, 0, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to count users", err)
+	}
+
+	var records []UserWithStats
+	q := `SELECT u.*, count(du.user_id) AS domains_count FROM %v AS u
+		LEFT JOIN domain_user AS du ON u.user_id = du.user_id
+		GROUP BY u.user_id %v %v;`
+	_, err = repo.DBMap.Select(&records, fmt.Sprintf(q, userTbl, OrderByClause(sorter, "u"), LimitOffset(pager)))
+	if err != nil {
+		return nil, 0, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to perform a lookup of users", err)
+	}
+	return usersToEntities(records), total, nil
+}
+
+// ListByDomain returns a page of users of a given domain along with the total number
+// of the domain's users
+func (repo *UserRepository) ListByDomain(domainID string, pager entities.Pager, sorter entities.Sorter) ([]entities.User, int64, error) {
+	userTbl := repo.DBMap.Dialect.QuotedTableForQuery("", "user")
+	q := "SELECT count(*) FROM domain_user WHERE domain_id IN (SELECT domain_id FROM domain WHERE object_id = ?);"
+	total, err := repo.DBMap.SelectInt(Rebind(repo.DBMap.Dialect, q), domainID)
+	if err != nil {
+		return nil, 0, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to count user for a given domain", err)
+	}
+
+	var records []UserWithStats
+	q = `SELECT u.*, count(du.user_id) AS domains_count FROM %v AS u
+		LEFT JOIN domain_user AS du ON u.user_id = du.user_id
+		WHERE u.user_id IN (
+			SELECT DISTINCT user_id FROM domain_user WHERE domain_id
+				IN (SELECT domain_id FROM domain WHERE object_id = ?)
+		)
+		GROUP BY u.user_id %v %v;`
+	_, err = repo.DBMap.Select(&records, Rebind(repo.DBMap.Dialect, fmt.Sprintf(q, userTbl, OrderByClause(sorter, "u"), LimitOffset(pager))), domainID)
+	if err != nil {
+		return nil, 0, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to perform a lookup of users for a given domain", err)
+	}
+	return usersToEntities(records), total, nil
+}
+
+// findUser finds a user record by a value of a given unique column
+func findUser(dbmap *gorp.DbMap, column string, value string) (*User, error) {
+	var u User
+	q := fmt.Sprintf("SELECT * FROM %v WHERE %v = ?", dbmap.Dialect.QuotedTableForQuery("", "user"), column)
+	err := dbmap.SelectOne(&u, Rebind(dbmap.Dialect, q), value)
+	if err == sql.ErrNoRows {
+		if column == "name" {
+			return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "User not found by given name", err)
+		}
+		return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "User not found by given ID", err)
+	} else if err != nil {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to perform a lookup of a user", err)
+	}
+	return &u, nil
+}
+
+// findDomainPKs resolves PKs of domains by their IDs
+func findDomainPKs(dbmap *gorp.DbMap, ids []string) ([]int64, error) {
+	var pks []int64
+	for _, id := range ids {
+		pk, err := dbmap.SelectInt(Rebind(dbmap.Dialect, "SELECT domain_id FROM domain WHERE object_id = ?;"), id)
+		if err != nil || pk == 0 {
+			return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "Domain not found by given ID", err)
+		}
+		pks = append(pks, pk)
+	}
+	return pks, nil
+}
+
+func userToEntity(u *User) *entities.BasicUser {
+	e := entities.NewBasicUser(u.Name)
+	e.ID = u.ID
+	e.Password = u.Password
+	e.PasswordChangedOn.Time = u.PasswordChangedOn
+	e.Enabled = u.Enabled
+	return e
+}
+
+func usersToEntities(records []UserWithStats) []entities.User {
+	users := []entities.User{}
+	for _, r := range records {
+		users = append(users, entities.User{BasicUser: *userToEntity(&r.User), DomainsCount: r.DomainsCount})
+	}
+	return users
 }
@@ -0,0 +1,249 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+	"gopkg.in/gorp.v1"
+)
+
+// Credential table. Public keys are stored base64 encoded.
+type Credential struct {
+	ID         string    `db:"credential_id"`
+	UserPK     int64     `db:"user_id"`
+	Name       string    `db:"name"`
+	PublicKey  string    `db:"public_key"`
+	SignCount  int64     `db:"sign_count"`
+	CreatedOn  time.Time `db:"created_on"`
+	LastUsedOn time.Time `db:"last_used_on"`
+}
+
+// CredentialView contains all fields for populating the entity
+type CredentialView struct {
+	Credential
+	// Field resulted as join to user table
+	UserID string `db:"user_object_id"`
+}
+
+// WebAuthnChallenge table. DomainPK and UserPK are 0 unless a challenge is bound to
+// a domain or a user. Challenges are stored base64 encoded.
+type WebAuthnChallenge struct {
+	ID        string    `db:"challenge_id"`
+	Ceremony  string    `db:"ceremony"`
+	Challenge string    `db:"challenge"`
+	DomainPK  int64     `db:"domain_id"`
+	UserPK    int64     `db:"user_id"`
+	CreatedOn time.Time `db:"created_on"`
+	ExpiresOn time.Time `db:"expires_on"`
+}
+
+// WebAuthnChallengeView contains all fields for populating the entity
+type WebAuthnChallengeView struct {
+	WebAuthnChallenge
+	// Field resulted as join to domain table
+	DomainID string `db:"domain_object_id"`
+	// Field resulted as join to user table
+	UserID string `db:"user_object_id"`
+}
+
+const credentialViewQuery = `SELECT c.*, u.object_id AS user_object_id
+		FROM webauthn_credential AS c
+		INNER JOIN %v AS u ON u.user_id = c.user_id`
+
+const webAuthnChallengeViewQuery = `SELECT c.*, COALESCE(d.object_id, '') AS domain_object_id, COALESCE(u.object_id, '') AS user_object_id
+		FROM webauthn_challenge AS c
+		LEFT JOIN domain AS d ON d.domain_id = c.domain_id
+		LEFT JOIN %v AS u ON u.user_id = c.user_id`
+
+//
+// WebAuthnRepository is a gorp-backed implementation of usecases.WebAuthnRepository
+//
+type WebAuthnRepository struct {
+	DBMap *gorp.DbMap
+}
+
+// CreateCredential inserts a new credential
+func (repo *WebAuthnRepository) CreateCredential(credential entities.Credential) error {
+	u, err := findUser(repo.DBMap, "object_id", credential.UserID)
+	if err != nil {
+		return err
+	}
+	c := &Credential{
+		ID:         credential.ID,
+		UserPK:     u.PK,
+		Name:       credential.Name,
+		PublicKey:  base64.StdEncoding.EncodeToString(credential.PublicKey),
+		SignCount:  int64(credential.SignCount),
+		CreatedOn:  credential.CreatedOn.Time,
+		LastUsedOn: credential.LastUsedOn.Time,
+	}
+	err = repo.DBMap.Insert(c)
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeConflict, "Failed to create a credential", err)
+	}
+	return nil
+}
+
+// DeleteCredential deletes a credential of a user by ID
+func (repo *WebAuthnRepository) DeleteCredential(userID, id string) error {
+	q := fmt.Sprintf("DELETE FROM webauthn_credential WHERE credential_id = ? AND user_id IN (SELECT user_id FROM %v WHERE object_id = ?)",
+		repo.DBMap.Dialect.QuotedTableForQuery("", "user"))
+	res, err := repo.DBMap.Exec(Rebind(repo.DBMap.Dialect, q), id, userID)
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to delete credential by given ID", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to delete credential by given ID", err)
+	} else if n == 0 {
+		return errs.NewDataAccessError(errs.ErrorTypeNotFound, "Credential not found by given ID", nil)
+	}
+	return nil
+}
+
+// FindCredential finds a credential by ID
+func (repo *WebAuthnRepository) FindCredential(id string) (*entities.Credential, error) {
+	var view CredentialView
+	q := fmt.Sprintf(credentialViewQuery, repo.DBMap.Dialect.QuotedTableForQuery("", "user")) + " WHERE c.credential_id = ?"
+	err := repo.DBMap.SelectOne(&view, Rebind(repo.DBMap.Dialect, q), id)
+	if err == sql.ErrNoRows {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "Credential not found by given ID", err)
+	} else if err != nil {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to perform a lookup of a credential", err)
+	}
+	return credentialToEntity(&view)
+}
+
+// ListCredentials lists all credentials of a user in order of registration
+func (repo *WebAuthnRepository) ListCredentials(userID string) ([]entities.Credential, error) {
+	var views []CredentialView
+	q := fmt.Sprintf(credentialViewQuery, repo.DBMap.Dialect.QuotedTableForQuery("", "user")) + " WHERE u.object_id = ? ORDER BY c.created_on"
+	_, err := repo.DBMap.Select(&views, Rebind(repo.DBMap.Dialect, q), userID)
+	if err != nil {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to list credentials", err)
+	}
+	credentials := []entities.Credential{}
+	for i := range views {
+		c, err := credentialToEntity(&views[i])
+		if err != nil {
+			return nil, err
+		}
+		credentials = append(credentials, *c)
+	}
+	return credentials, nil
+}
+
+// UpdateSignCount records the signature counter of a credential used at a given time
+// if the stored counter is still the given one
+func (repo *WebAuthnRepository) UpdateSignCount(id string, from, to uint32, usedOn time.Time) error {
+	q := "UPDATE webauthn_credential SET sign_count = ?, last_used_on = ? WHERE credential_id = ? AND sign_count = ?"
+	res, err := repo.DBMap.Exec(Rebind(repo.DBMap.Dialect, q), int64(to), usedOn, id, int64(from))
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to update a credential", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to update a credential", err)
+	} else if n == 0 {
+		return errs.NewDataAccessError(errs.ErrorTypeConflict, "Signature counter of the credential has changed", nil)
+	}
+	return nil
+}
+
+// CreateChallenge inserts a new challenge
+func (repo *WebAuthnRepository) CreateChallenge(challenge entities.WebAuthnChallenge) error {
+	c := &WebAuthnChallenge{
+		ID:        challenge.ID,
+		Ceremony:  challenge.Ceremony,
+		Challenge: base64.StdEncoding.EncodeToString(challenge.Challenge),
+		CreatedOn: challenge.CreatedOn.Time,
+		ExpiresOn: challenge.ExpiresOn.Time,
+	}
+	if challenge.DomainID != "" {
+		d, err := findDomain(repo.DBMap, "object_id", challenge.DomainID)
+		if err != nil {
+			return err
+		}
+		c.DomainPK = d.PK
+	}
+	if challenge.UserID != "" {
+		u, err := findUser(repo.DBMap, "object_id", challenge.UserID)
+		if err != nil {
+			return err
+		}
+		c.UserPK = u.PK
+	}
+	err := repo.DBMap.Insert(c)
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to create a challenge", err)
+	}
+	return nil
+}
+
+// FindChallenge finds a challenge by ID
+func (repo *WebAuthnRepository) FindChallenge(id string) (*entities.WebAuthnChallenge, error) {
+	var view WebAuthnChallengeView
+	q := fmt.Sprintf(webAuthnChallengeViewQuery, repo.DBMap.Dialect.QuotedTableForQuery("", "user")) + " WHERE c.challenge_id = ?"
+	err := repo.DBMap.SelectOne(&view, Rebind(repo.DBMap.Dialect, q), id)
+	if err == sql.ErrNoRows {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "Challenge not found by given ID", err)
+	} else if err != nil {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to perform a lookup of a challenge", err)
+	}
+	challenge, err := base64.StdEncoding.DecodeString(view.Challenge)
+	if err != nil {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to decode a challenge", err)
+	}
+	c := &entities.WebAuthnChallenge{
+		ID:        view.ID,
+		Ceremony:  view.Ceremony,
+		Challenge: challenge,
+		DomainID:  view.DomainID,
+		UserID:    view.UserID,
+	}
+	c.CreatedOn.Time = view.CreatedOn
+	c.ExpiresOn.Time = view.ExpiresOn
+	return c, nil
+}
+
+// DeleteChallenge deletes a challenge by ID
+func (repo *WebAuthnRepository) DeleteChallenge(id string) error {
+	res, err := repo.DBMap.Exec(Rebind(repo.DBMap.Dialect, "DELETE FROM webauthn_challenge WHERE challenge_id = ?"), id)
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to delete challenge by given ID", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to delete challenge by given ID", err)
+	} else if n == 0 {
+		return errs.NewDataAccessError(errs.ErrorTypeNotFound, "Challenge not found by given ID", nil)
+	}
+	return nil
+}
+
+// DeleteExpiredChallenges deletes all challenges expired by a given time
+func (repo *WebAuthnRepository) DeleteExpiredChallenges(now time.Time) error {
+	_, err := repo.DBMap.Exec(Rebind(repo.DBMap.Dialect, "DELETE FROM webauthn_challenge WHERE expires_on <= ?"), now)
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to delete expired challenges", err)
+	}
+	return nil
+}
+
+func credentialToEntity(view *CredentialView) (*entities.Credential, error) {
+	key, err := base64.StdEncoding.DecodeString(view.PublicKey)
+	if err != nil {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to decode a credential's public key", err)
+	}
+	c := &entities.Credential{
+		ID:        view.ID,
+		UserID:    view.UserID,
+		Name:      view.Name,
+		PublicKey: key,
+		SignCount: uint32(view.SignCount),
+	}
+	c.CreatedOn.Time = view.CreatedOn
+	c.LastUsedOn.Time = view.LastUsedOn
+	return c, nil
+}
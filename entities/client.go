@@ -0,0 +1,232 @@
+package entities
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/satori/go.uuid"
+)
+
+// OAuth 2.0 grant types a client may use
+const (
+	GrantTypeClientCredentials = "client_credentials"
+	GrantTypePassword          = "password"
+	GrantTypeRefreshToken      = "refresh_token"
+	GrantTypeAuthorizationCode = "authorization_code"
+)
+
+// CodeChallengeS256 is the only supported PKCE code challenge method (RFC 7636)
+const CodeChallengeS256 = "S256"
+
+// clientSecretSize is the number of random bytes of a generated client secret
+const clientSecretSize = 32
+
+//
+// Client is an OAuth 2.0 client registered in a domain. Its scopes are names of
+// permissions (wildcards included) it may request. A client without a secret is a
+// public one (e.g. a single-page app), which can't keep a secret and may only use
+// the authorization code (with PKCE) and refresh token grants.
+//
+type Client struct {
+	ID           string   `json:"id"`
+	Name         string   `json:"name"`
+	DomainID     string   `json:"domain_id"`
+	Secret       string   `json:"-"`
+	GrantTypes   []string `json:"grant_types"`
+	Scopes       []string `json:"scopes"`
+	RedirectURIs []string `json:"redirect_uris"`
+	Enabled      bool     `json:"enabled"`
+	CreatedOn    Time     `json:"created_on"`
+}
+
+// NewClient creates a new enabled public Client entity in a given domain
+func NewClient(name, domainID string) *Client {
+	c := &Client{
+		ID:           uuid.NewV4().String(),
+		Name:         name,
+		DomainID:     domainID,
+		GrantTypes:   []string{},
+		Scopes:       []string{},
+		RedirectURIs: []string{},
+		Enabled:      true,
+	}
+	c.CreatedOn.Time = time.Now().UTC()
+	return c
+}
+
+// GenerateSecret generates a new random secret of the client, keeps its hash and
+// returns the secret in clear text. Being random the secret is hashed with SHA-256
+// rather than a password hasher, which keeps authenticating clients cheap.
+func (c *Client) GenerateSecret() (string, error) {
+	b := make([]byte, clientSecretSize)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return "", fmt.Errorf("Failed to generate secret: %v", err)
+	}
+	secret := hex.EncodeToString(b)
+	c.Secret = HashToken(secret)
+	return secret, nil
+}
+
+// IsSecret checks if a given clear text is the client's secret
+func (c *Client) IsSecret(clearTxt string) bool {
+	return c.Secret != "" && subtle.ConstantTimeCompare([]byte(c.Secret), []byte(HashToken(clearTxt))) == 1
+}
+
+// IsPublic tells if the client has no secret
+func (c *Client) IsPublic() bool {
+	return c.Secret == ""
+}
+
+// AllowsRedirectURI checks if a redirect URI is registered for the client. URIs are
+// compared as is.
+func (c *Client) AllowsRedirectURI(uri string) bool {
+	for _, u := range c.RedirectURIs {
+		if u == uri {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsGrantType checks if the client may use a given grant type
+func (c *Client) AllowsGrantType(grantType string) bool {
+	for _, g := range c.GrantTypes {
+		if g == grantType {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsScope checks if any of the client's scopes covers a given one
+func (c *Client) AllowsScope(scope string) bool {
+	for _, s := range c.Scopes {
+		if PermissionGrants(s, scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValid checks if client is valid
+func (c *Client) IsValid() (bool, error) {
+	if c.Name == "" {
+		return false, fmt.Errorf("Name cannot be empty!")
+	}
+	if c.DomainID == "" {
+		return false, fmt.Errorf("Domain cannot be empty!")
+	}
+	for _, g := range c.GrantTypes {
+		switch g {
+		case GrantTypeAuthorizationCode, GrantTypeRefreshToken:
+		case GrantTypeClientCredentials, GrantTypePassword:
+			if c.IsPublic() {
+				return false, fmt.Errorf("Grant type %q requires a secret", g)
+			}
+		default:
+			return false, fmt.Errorf("Unsupported grant type %q", g)
+		}
+	}
+	if c.AllowsGrantType(GrantTypeAuthorizationCode) && len(c.RedirectURIs) == 0 {
+		return false, fmt.Errorf("Redirect URI is required for grant type %q", GrantTypeAuthorizationCode)
+	}
+	for _, uri := range c.RedirectURIs {
+		u, err := url.Parse(uri)
+		if err != nil || !u.IsAbs() || u.Fragment != "" || strings.ContainsAny(uri, " \t\r\n") {
+			return false, fmt.Errorf("Invalid redirect URI %q", uri)
+		}
+	}
+	for _, s := range c.Scopes {
+		if s == "" || strings.ContainsAny(s, " \t\r\n") {
+			return false, fmt.Errorf("Invalid scope %q", s)
+		}
+	}
+	return true, nil
+}
+
+//
+// RefreshToken is an OAuth 2.0 refresh token of a session opened by a client. Only
+// a hash of the token is kept.
+//
+type RefreshToken struct {
+	Hash      string
+	ClientID  string
+	SessionID string
+	Scopes    []string
+	CreatedOn Time
+	ExpiresOn Time
+}
+
+// IsExpired checks if the refresh token is expired
+func (t *RefreshToken) IsExpired() bool {
+	return t.ExpiresOn.Sub(time.Now().UTC()) <= 0
+}
+
+//
+// AuthorizationRequest is a request of an authorization code (RFC 6749 section
+// 4.1.1) with a PKCE code challenge (RFC 7636)
+//
+type AuthorizationRequest struct {
+	ResponseType        string
+	ClientID            string
+	RedirectURI         string
+	Scopes              []string
+	State               string
+	Nonce               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+}
+
+//
+// AuthorizationCode is a short-lived single-use code issued to a client for a
+// session of a user who has logged in. Only a hash of the code is kept. The
+// redirect URI is kept as requested (it may be empty) since the token request
+// has to repeat it.
+//
+type AuthorizationCode struct {
+	Hash          string
+	ClientID      string
+	SessionID     string
+	RedirectURI   string
+	Scopes        []string
+	Nonce         string
+	CodeChallenge string
+	CreatedOn     Time
+	ExpiresOn     Time
+}
+
+// IsExpired checks if the authorization code is expired
+func (c *AuthorizationCode) IsExpired() bool {
+	return c.ExpiresOn.Sub(time.Now().UTC()) <= 0
+}
+
+// VerifyCodeVerifier checks a PKCE code verifier against the code's S256 challenge
+func (c *AuthorizationCode) VerifyCodeVerifier(verifier string) bool {
+	h := sha256.Sum256([]byte(verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(h[:])
+	return subtle.ConstantTimeCompare([]byte(challenge), []byte(c.CodeChallenge)) == 1
+}
+
+// HashToken hashes a random secret or token for storage
+func HashToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+// ParseScope splits a space-delimited scope parameter
+func ParseScope(scope string) []string {
+	return strings.Fields(scope)
+}
+
+// FormatScope joins scopes into a space-delimited scope parameter
+func FormatScope(scopes []string) string {
+	return strings.Join(scopes, " ")
+}
@@ -6,12 +6,14 @@ import (
 	"github.com/satori/go.uuid"
 )
 
-// BasicDomain contains basic domain attributes
+// BasicDomain contains basic domain attributes. Users of a domain which requires
+// MFA have to be enrolled in multi-factor authentication to open sessions in it.
 type BasicDomain struct {
 	ID          string `json:"id"`
 	Name        string `json:"name"`
 	Description string `json:"description"`
 	Enabled     bool   `json:"enabled"`
+	MFARequired bool   `json:"mfa_required"`
 }
 
 // Domain entities represent different tenants which contain User entities.
@@ -0,0 +1,54 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/satori/go.uuid"
+)
+
+const (
+	// SigningKeyGenerated is a status of a key which is published but doesn't sign
+	// tokens yet
+	SigningKeyGenerated = "generated"
+	// SigningKeyActive is a status of the key which signs new tokens
+	SigningKeyActive = "active"
+	// SigningKeyRetired is a status of a key which doesn't sign tokens anymore and
+	// is published only until the tokens it has signed expire
+	SigningKeyRetired = "retired"
+)
+
+//
+// SigningKey is an asymmetric key tokens are signed with. Only its public part is
+// kept in the entity.
+//
+type SigningKey struct {
+	ID          string `json:"id"`
+	Algorithm   string `json:"algorithm"`
+	Status      string `json:"status"`
+	PublicKey   []byte `json:"-"`
+	CreatedOn   Time   `json:"created_on"`
+	ActivatedOn Time   `json:"activated_on"`
+	RetiredOn   Time   `json:"retired_on"`
+}
+
+// NewSigningKey creates a new generated SigningKey entity with a given algorithm and
+// a public key (PKIX, DER)
+func NewSigningKey(algorithm string, publicKey []byte) *SigningKey {
+	k := &SigningKey{
+		ID:        uuid.NewV4().String(),
+		Algorithm: algorithm,
+		Status:    SigningKeyGenerated,
+		PublicKey: publicKey,
+	}
+	k.CreatedOn.Time = time.Now().UTC()
+	return k
+}
+
+// IsPublished tells if the key has to be published for verifying tokens which live
+// for a given TTL
+func (k *SigningKey) IsPublished(now time.Time, ttl time.Duration) bool {
+	if k.Status == SigningKeyRetired {
+		return now.Before(k.RetiredOn.Add(ttl))
+	}
+	return true
+}
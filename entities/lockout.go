@@ -0,0 +1,34 @@
+package entities
+
+import "time"
+
+const (
+	// LockoutScopeUser tracks failed attempts to sign in as a user (by user ID)
+	LockoutScopeUser = "user"
+	// LockoutScopeAddress tracks failed attempts to sign in from a remote address
+	LockoutScopeAddress = "address"
+	// LockoutScopeDomain tracks failed attempts to sign in to a domain (by domain ID)
+	LockoutScopeDomain = "domain"
+)
+
+//
+// LoginFailures are recent failed attempts to sign in with a password within a scope
+// (a user, a remote address or a domain) identified by Subject. Further attempts are
+// rejected until BlockedUntil.
+//
+type LoginFailures struct {
+	Scope        string `json:"scope"`
+	Subject      string `json:"subject"`
+	Failures     int    `json:"failures"`
+	LastFailedOn Time   `json:"last_failed_on"`
+	BlockedUntil Time   `json:"blocked_until"`
+}
+
+// RetryAfter returns how long attempts are still blocked for (0 if they aren't)
+func (f *LoginFailures) RetryAfter() time.Duration {
+	d := f.BlockedUntil.Sub(time.Now().UTC())
+	if d < 0 {
+		return 0
+	}
+	return d
+}
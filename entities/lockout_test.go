@@ -0,0 +1,24 @@
+package entities
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoginFailuresRetryAfter(t *testing.T) {
+	now := time.Now().UTC()
+	for _, tc := range []struct {
+		blockedUntil time.Time
+		min, max     time.Duration
+	}{
+		{time.Time{}, 0, 0},
+		{now.Add(-time.Second), 0, 0},
+		{now.Add(time.Minute), 59 * time.Second, time.Minute},
+	} {
+		f := LoginFailures{Scope: LockoutScopeUser, Subject: "john", Failures: 3}
+		f.BlockedUntil.Time = tc.blockedUntil
+		if d := f.RetryAfter(); d < tc.min || d > tc.max {
+			t.Errorf("RetryAfter until %v = %v", tc.blockedUntil, d)
+		}
+	}
+}
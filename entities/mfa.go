@@ -0,0 +1,217 @@
+package entities
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/satori/go.uuid"
+)
+
+// Parameters of time-based one-time passwords (RFC 6238). They are the defaults of
+// authenticator apps, which ignore the parameters given in a key URI more often
+// than not.
+const (
+	// TOTPDigits is the number of digits of a one-time password
+	TOTPDigits = 6
+	// TOTPPeriod is how long a one-time password is valid
+	TOTPPeriod = 30 * time.Second
+	// totpSecretSize is the number of random bytes of a secret (160 bits as
+	// recommended by RFC 4226)
+	totpSecretSize = 20
+	// totpSkew is the number of periods a password may be behind or ahead of the
+	// current one to allow for clock drift
+	totpSkew = 1
+)
+
+const (
+	// RecoveryCodeCount is the number of recovery codes generated on enrollment
+	RecoveryCodeCount = 10
+	// recoveryCodeSize is the number of random bytes of a recovery code
+	recoveryCodeSize = 5
+)
+
+// MFAChallengeTTL is how long a user may take to complete a challenge
+const MFAChallengeTTL = 5 * time.Minute
+
+//
+// MFAEnrollment is an enrollment of a user in multi-factor authentication with a
+// TOTP authenticator app. The secret isn't kept in the entity. LastStep is the time
+// step of the last accepted one-time password, so a password can't be replayed.
+//
+type MFAEnrollment struct {
+	UserID        string `json:"user_id"`
+	LastStep      int64  `json:"-"`
+	RecoveryCodes int    `json:"recovery_codes"`
+	CreatedOn     Time   `json:"created_on"`
+}
+
+//
+// MFACredentials are the credentials of an enrollment shown to the user only once:
+// the TOTP secret (base32 encoded) along with its otpauth:// key URI, which is
+// usually shown as a QR code, and single-use recovery codes for signing in without
+// the authenticator.
+//
+type MFACredentials struct {
+	Secret        string   `json:"secret"`
+	URI           string   `json:"uri"`
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+//
+// MFAChallenge is the pending second step of opening a session for a user who has
+// provided a valid password. It has to be completed with a one-time password or a
+// recovery code from the same user agent and remote address before it expires.
+//
+type MFAChallenge struct {
+	ID         string `json:"id"`
+	DomainID   string `json:"-"`
+	UserID     string `json:"-"`
+	UserAgent  string `json:"-"`
+	RemoteAddr string `json:"-"`
+	Attempts   int    `json:"-"`
+	CreatedOn  Time   `json:"created_on"`
+	ExpiresOn  Time   `json:"expires_on"`
+}
+
+// NewMFAEnrollment creates a new MFAEnrollment entity of a user
+func NewMFAEnrollment(userID string) *MFAEnrollment {
+	e := &MFAEnrollment{
+		UserID:        userID,
+		RecoveryCodes: RecoveryCodeCount,
+	}
+	e.CreatedOn.Time = time.Now().UTC()
+	return e
+}
+
+// NewMFAChallenge creates a new MFAChallenge entity of a user in a domain
+func NewMFAChallenge(user BasicUser, domain BasicDomain, userAgent, remoteAddr string) *MFAChallenge {
+	c := &MFAChallenge{
+		ID:         uuid.NewV4().String(),
+		DomainID:   domain.ID,
+		UserID:     user.ID,
+		UserAgent:  userAgent,
+		RemoteAddr: remoteAddr,
+	}
+	now := time.Now().UTC()
+	c.CreatedOn.Time = now
+	c.ExpiresOn.Time = now.Add(MFAChallengeTTL)
+	return c
+}
+
+// IsExpired checks if the challenge is expired
+func (c *MFAChallenge) IsExpired() bool {
+	return c.ExpiresOn.Sub(time.Now().UTC()) <= 0
+}
+
+// GenerateTOTPSecret generates a new random TOTP secret
+func GenerateTOTPSecret() ([]byte, error) {
+	secret := make([]byte, totpSecretSize)
+	if _, err := io.ReadFull(rand.Reader, secret); err != nil {
+		return nil, fmt.Errorf("Failed to generate secret: %v", err)
+	}
+	return secret, nil
+}
+
+// EncodeTOTPSecret encodes a secret with base32 as authenticator apps expect it
+func EncodeTOTPSecret(secret []byte) string {
+	return strings.TrimRight(base32.StdEncoding.EncodeToString(secret), "=")
+}
+
+// TOTPKeyURI returns an otpauth:// key URI of a secret, which authenticator apps
+// import from a QR code. The issuer and the account name label the secret in an app.
+func TOTPKeyURI(issuer, account string, secret []byte) string {
+	q := url.Values{}
+	q.Set("secret", EncodeTOTPSecret(secret))
+	q.Set("issuer", issuer)
+	q.Set("digits", fmt.Sprint(TOTPDigits))
+	q.Set("period", fmt.Sprint(int(TOTPPeriod/time.Second)))
+	label := url.QueryEscape(issuer + ":" + account)
+	return "otpauth://totp/" + strings.Replace(label, "+", "%20", -1) + "?" + q.Encode()
+}
+
+// TOTPStep returns the time step of a given time
+func TOTPStep(t time.Time) int64 {
+	return t.Unix() / int64(TOTPPeriod/time.Second)
+}
+
+// TOTP computes the one-time password of a secret for a time step (RFC 4226
+// section 5.3, HMAC-SHA-1)
+func TOTP(secret []byte, step int64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(step))
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < TOTPDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", TOTPDigits, code%mod)
+}
+
+// VerifyTOTP checks a one-time password against the passwords of a secret around a
+// given time. Only passwords of steps later than the last accepted one are valid.
+// The step of the matching password is returned.
+func VerifyTOTP(secret []byte, code string, now time.Time, lastStep int64) (int64, bool) {
+	if !IsTOTPCode(code) {
+		return 0, false
+	}
+	current := TOTPStep(now)
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= lastStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(TOTP(secret, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// IsTOTPCode tells if a code looks like a one-time password rather than a recovery code
+func IsTOTPCode(code string) bool {
+	if len(code) != TOTPDigits {
+		return false
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// GenerateRecoveryCodes generates new random recovery codes. They are formatted as
+// two groups of four characters for readability.
+func GenerateRecoveryCodes() ([]string, error) {
+	codes := []string{}
+	for i := 0; i < RecoveryCodeCount; i++ {
+		b := make([]byte, recoveryCodeSize)
+		if _, err := io.ReadFull(rand.Reader, b); err != nil {
+			return nil, fmt.Errorf("Failed to generate recovery code: %v", err)
+		}
+		s := strings.ToLower(base32.StdEncoding.EncodeToString(b))
+		codes = append(codes, s[:4]+"-"+s[4:])
+	}
+	return codes, nil
+}
+
+// HashRecoveryCode hashes a recovery code for storage. The code is normalized first,
+// so it may be entered in upper case and without the dash.
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.Replace(code, "-", "", -1)
+	return HashToken(code)
+}
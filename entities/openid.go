@@ -0,0 +1,60 @@
+package entities
+
+// OpenID Connect scopes. Unlike other scopes they aren't permissions and are granted
+// to any user a client is allowed to request them for.
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+// IsOpenIDScope checks if a scope is one of the OpenID Connect scopes
+func IsOpenIDScope(scope string) bool {
+	return scope == ScopeOpenID || scope == ScopeProfile || scope == ScopeEmail
+}
+
+// HasScope checks if a scope is among given ones as is (wildcards aren't expanded)
+func HasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+//
+// IDTokenClaims are claims carried by an OpenID Connect ID token. Domain is the name
+// of the user's domain and the session ID claim is the session's reference. The email
+// claims are set only if the email scope is granted.
+//
+type IDTokenClaims struct {
+	Issuer          string `json:"iss"`
+	Subject         string `json:"sub"`
+	Audience        string `json:"aud"`
+	AuthorizedParty string `json:"azp"`
+	ExpiresAt       int64  `json:"exp"`
+	IssuedAt        int64  `json:"iat"`
+	AuthTime        int64  `json:"auth_time"`
+	Nonce           string `json:"nonce,omitempty"`
+	SessionRef      string `json:"sid"`
+	Name            string `json:"name"`
+	Domain          string `json:"domain"`
+	Email           string `json:"email,omitempty"`
+	EmailVerified   *bool  `json:"email_verified,omitempty"`
+}
+
+//
+// UserInfo are claims about a user returned by the OpenID Connect userinfo endpoint.
+// Claims other than the subject and the domain are set according to the granted
+// scopes.
+//
+type UserInfo struct {
+	Subject           string `json:"sub"`
+	Name              string `json:"name,omitempty"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	Domain            string `json:"domain"`
+	DomainID          string `json:"domain_id"`
+	Email             string `json:"email,omitempty"`
+	EmailVerified     *bool  `json:"email_verified,omitempty"`
+}
@@ -0,0 +1,231 @@
+package entities
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/oleksandr/idp/config"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+//
+// PasswordHasher is an interface of a password hashing algorithm producing
+// self-describing hashes (algorithm and its parameters are part of the hash)
+//
+type PasswordHasher interface {
+	// Hash one-way hashes a given clear text
+	Hash(clearTxt string) (string, error)
+	// Recognizes tells if a given hash was produced by this algorithm
+	Recognizes(hash string) bool
+	// Verify checks if a given clear text matches a given hash
+	Verify(hash, clearTxt string) bool
+	// NeedsRehash tells if a given hash was produced with different parameters
+	NeedsRehash(hash string) bool
+}
+
+var (
+	// defaultPasswordHasher is used to hash new passwords
+	defaultPasswordHasher PasswordHasher
+	// passwordHashers are used to verify existing passwords
+	passwordHashers []PasswordHasher
+)
+
+func init() {
+	argon := &Argon2idHasher{
+		Time:    uint32(config.Argon2Time()),
+		Memory:  uint32(config.Argon2Memory()),
+		Threads: uint8(config.Argon2Threads()),
+		KeyLen:  32,
+		SaltLen: 16,
+	}
+	bcr := &BcryptHasher{Cost: config.BcryptCost()}
+	legacy := &LegacySHA1Hasher{Salt: config.HashSecretSalt()}
+
+	passwordHashers = []PasswordHasher{argon, bcr, legacy}
+	switch config.PasswordHasher() {
+	case "bcrypt":
+		defaultPasswordHasher = bcr
+	default:
+		defaultPasswordHasher = argon
+	}
+}
+
+// SetPasswordHasher replaces the hasher used for new passwords. Hashes produced
+// by a previous default hasher are still verified and get rehashed on login.
+func SetPasswordHasher(h PasswordHasher) {
+	defaultPasswordHasher = h
+	for _, existing := range passwordHashers {
+		if existing == h {
+			return
+		}
+	}
+	passwordHashers = append([]PasswordHasher{h}, passwordHashers...)
+}
+
+func hasherFor(hash string) PasswordHasher {
+	for _, h := range passwordHashers {
+		if h.Recognizes(hash) {
+			return h
+		}
+	}
+	return nil
+}
+
+//
+// Argon2idHasher hashes passwords with argon2id and encodes them in PHC string
+// format: $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+//
+type Argon2idHasher struct {
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+	KeyLen  uint32
+	SaltLen uint32
+}
+
+const argon2idPrefix = "$argon2id$"
+
+// Shortest salt and key accepted from stored argon2id hashes
+const (
+	minArgon2idSaltLen = 8
+	minArgon2idKeyLen  = 16
+)
+
+// Hash implements PasswordHasher
+func (h *Argon2idHasher) Hash(clearTxt string) (string, error) {
+	salt := make([]byte, h.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(clearTxt), salt, h.Time, h.Memory, h.Threads, h.KeyLen)
+	return fmt.Sprintf("%vv=%d$m=%d,t=%d,p=%d$%v$%v", argon2idPrefix, argon2.Version, h.Memory, h.Time, h.Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Recognizes implements PasswordHasher
+func (h *Argon2idHasher) Recognizes(hash string) bool {
+	return strings.HasPrefix(hash, argon2idPrefix)
+}
+
+// Verify implements PasswordHasher
+func (h *Argon2idHasher) Verify(hash, clearTxt string) bool {
+	p, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return false
+	}
+	other := argon2.IDKey([]byte(clearTxt), salt, p.Time, p.Memory, p.Threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, other) == 1
+}
+
+// NeedsRehash implements PasswordHasher
+func (h *Argon2idHasher) NeedsRehash(hash string) bool {
+	p, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return true
+	}
+	return p.Time != h.Time || p.Memory != h.Memory || p.Threads != h.Threads ||
+		uint32(len(key)) != h.KeyLen || uint32(len(salt)) != h.SaltLen
+}
+
+func decodeArgon2id(hash string) (*Argon2idHasher, []byte, []byte, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, nil, nil, fmt.Errorf("Invalid argon2id hash")
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, nil, nil, fmt.Errorf("Unsupported argon2id version")
+	}
+	var m, t, threads int
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &m, &t, &threads); err != nil {
+		return nil, nil, nil, err
+	}
+	// argon2 panics on zero passes or threads, and huge values would exhaust the server
+	if t < config.MinArgon2Time || t > config.MaxArgon2Time ||
+		threads < config.MinArgon2Threads || threads > config.MaxArgon2Threads ||
+		m < config.MinArgon2Memory || m < 8*threads || m > config.MaxArgon2Memory {
+		return nil, nil, nil, fmt.Errorf("Unsupported argon2id parameters")
+	}
+	p := &Argon2idHasher{Time: uint32(t), Memory: uint32(m), Threads: uint8(threads)}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if len(salt) < minArgon2idSaltLen || len(key) < minArgon2idKeyLen {
+		return nil, nil, nil, fmt.Errorf("Invalid argon2id salt or key length")
+	}
+	return p, salt, key, nil
+}
+
+//
+// BcryptHasher hashes passwords with bcrypt ($2a$, $2b$ and $2y$ hashes)
+//
+type BcryptHasher struct {
+	Cost int
+}
+
+// Hash implements PasswordHasher
+func (h *BcryptHasher) Hash(clearTxt string) (string, error) {
+	b, err := bcrypt.GenerateFromPassword([]byte(clearTxt), h.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// Recognizes implements PasswordHasher
+func (h *BcryptHasher) Recognizes(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// Verify implements PasswordHasher
+func (h *BcryptHasher) Verify(hash, clearTxt string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(clearTxt)) == nil
+}
+
+// NeedsRehash implements PasswordHasher
+func (h *BcryptHasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.Cost
+}
+
+//
+// LegacySHA1Hasher verifies hashes of sha1(password + IDP_SECRET_SALT) stored
+// by earlier versions. Such hashes always need to be rehashed.
+//
+type LegacySHA1Hasher struct {
+	Salt string
+}
+
+// Hash implements PasswordHasher
+func (h *LegacySHA1Hasher) Hash(clearTxt string) (string, error) {
+	hash := sha1.New()
+	hash.Write([]byte(clearTxt))
+	hash.Write([]byte(h.Salt))
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
+
+// Recognizes implements PasswordHasher
+func (h *LegacySHA1Hasher) Recognizes(hash string) bool {
+	return len(hash) == sha1.Size*2 && !strings.HasPrefix(hash, "$")
+}
+
+// Verify implements PasswordHasher
+func (h *LegacySHA1Hasher) Verify(hash, clearTxt string) bool {
+	other, _ := h.Hash(clearTxt)
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(other)) == 1
+}
+
+// NeedsRehash implements PasswordHasher
+func (h *LegacySHA1Hasher) NeedsRehash(hash string) bool {
+	return true
+}
@@ -0,0 +1,148 @@
+package entities
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// testArgon2id is cheap enough to be used in tests
+var testArgon2id = &Argon2idHasher{Time: 1, Memory: 64, Threads: 1, KeyLen: 32, SaltLen: 16}
+
+func TestArgon2idHasher(t *testing.T) {
+	hash, err := testArgon2id.Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$") || !testArgon2id.Recognizes(hash) {
+		t.Errorf("Hash = %v", hash)
+	}
+	if other, _ := testArgon2id.Hash("secret"); other == hash {
+		t.Error("Hash of the same password with another salt is the same")
+	}
+	if !testArgon2id.Verify(hash, "secret") || testArgon2id.Verify(hash, "Secret") || testArgon2id.Verify(hash, "") {
+		t.Error("Verify accepts another password or rejects the password")
+	}
+	if testArgon2id.NeedsRehash(hash) {
+		t.Error("NeedsRehash of a hash with the same parameters")
+	}
+
+	for _, h := range []*Argon2idHasher{
+		{Time: 2, Memory: 64, Threads: 1, KeyLen: 32, SaltLen: 16},
+		{Time: 1, Memory: 128, Threads: 1, KeyLen: 32, SaltLen: 16},
+		{Time: 1, Memory: 64, Threads: 2, KeyLen: 32, SaltLen: 16},
+		{Time: 1, Memory: 64, Threads: 1, KeyLen: 16, SaltLen: 16},
+		{Time: 1, Memory: 64, Threads: 1, KeyLen: 32, SaltLen: 8},
+	} {
+		if !h.NeedsRehash(hash) {
+			t.Errorf("NeedsRehash with %+v = false", *h)
+		}
+		if !h.Verify(hash, "secret") {
+			t.Errorf("Verify with %+v rejects a hash of other parameters", *h)
+		}
+	}
+}
+
+func TestArgon2idHasherParameters(t *testing.T) {
+	salt := base64.RawStdEncoding.EncodeToString(make([]byte, 16))
+	key := base64.RawStdEncoding.EncodeToString(make([]byte, 32))
+	for _, hash := range []string{
+		fmt.Sprintf("$argon2id$v=19$m=64,t=0,p=1$%v$%v", salt, key),
+		fmt.Sprintf("$argon2id$v=19$m=64,t=17,p=1$%v$%v", salt, key),
+		fmt.Sprintf("$argon2id$v=19$m=64,t=1,p=0$%v$%v", salt, key),
+		fmt.Sprintf("$argon2id$v=19$m=64,t=1,p=65$%v$%v", salt, key),
+		fmt.Sprintf("$argon2id$v=19$m=4,t=1,p=1$%v$%v", salt, key),
+		fmt.Sprintf("$argon2id$v=19$m=16,t=1,p=4$%v$%v", salt, key),
+		fmt.Sprintf("$argon2id$v=19$m=1048577,t=1,p=1$%v$%v", salt, key),
+		fmt.Sprintf("$argon2id$v=16$m=64,t=1,p=1$%v$%v", salt, key),
+		fmt.Sprintf("$argon2id$v=19$m=64,t=1,p=1$%v$%v", salt[:4], key),
+		fmt.Sprintf("$argon2id$v=19$m=64,t=1,p=1$%v$%v", salt, key[:8]),
+		fmt.Sprintf("$argon2id$v=19$m=64,t=1,p=1$%v$%v", "!", key),
+		fmt.Sprintf("$argon2id$v=19$m=64,t=1,p=1$%v", salt),
+		"$argon2id$",
+	} {
+		if testArgon2id.Verify(hash, "") {
+			t.Errorf("Verify(%v) = true", hash)
+		}
+		if !testArgon2id.NeedsRehash(hash) {
+			t.Errorf("NeedsRehash(%v) = false", hash)
+		}
+	}
+}
+
+func TestBcryptHasher(t *testing.T) {
+	h := &BcryptHasher{Cost: 4}
+	hash, err := h.Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "$2a$04$") || !h.Recognizes(hash) || testArgon2id.Recognizes(hash) {
+		t.Errorf("Hash = %v", hash)
+	}
+	if !h.Verify(hash, "secret") || h.Verify(hash, "Secret") {
+		t.Error("Verify accepts another password or rejects the password")
+	}
+	if h.NeedsRehash(hash) || !(&BcryptHasher{Cost: 5}).NeedsRehash(hash) || !h.NeedsRehash("$2a$invalid") {
+		t.Error("NeedsRehash doesn't compare the cost")
+	}
+	if _, err = (&BcryptHasher{Cost: 32}).Hash("secret"); err == nil {
+		t.Error("Hash with an invalid cost succeeded")
+	}
+}
+
+func TestLegacySHA1Hasher(t *testing.T) {
+	h := &LegacySHA1Hasher{Salt: "pepper"}
+	hash, _ := h.Hash("secret")
+	if hash != "0f8912baf3c8178ad9ce5f9d5bd2a6ff5efcf1f0" || !h.Recognizes(hash) {
+		t.Errorf("Hash = %v", hash)
+	}
+	if !h.Verify(hash, "secret") || h.Verify(hash, "Secret") || (&LegacySHA1Hasher{}).Verify(hash, "secret") {
+		t.Error("Verify accepts another password or salt or rejects the password")
+	}
+	if !h.NeedsRehash(hash) {
+		t.Error("NeedsRehash of a legacy hash = false")
+	}
+	if h.Recognizes("$2a$04$"+hash[7:]) || h.Recognizes(hash[1:]) {
+		t.Error("Recognizes a hash of another algorithm")
+	}
+}
+
+func TestUserPassword(t *testing.T) {
+	defer func(h PasswordHasher, hashers []PasswordHasher) {
+		defaultPasswordHasher, passwordHashers = h, hashers
+	}(defaultPasswordHasher, passwordHashers)
+	legacy := &LegacySHA1Hasher{Salt: "pepper"}
+	bcr := &BcryptHasher{Cost: 4}
+	passwordHashers = []PasswordHasher{testArgon2id, bcr, legacy}
+	defaultPasswordHasher = testArgon2id
+
+	u := NewBasicUser("john")
+	u.Password, _ = legacy.Hash("secret")
+	if !u.IsPassword("secret") || u.IsPassword("wrong") || !u.PasswordNeedsRehash() {
+		t.Errorf("Legacy password %v isn't verified or not to be rehashed", u.Password)
+	}
+	u.Password, _ = bcr.Hash("secret")
+	if !u.IsPassword("secret") || !u.PasswordNeedsRehash() {
+		t.Errorf("bcrypt password %v isn't verified or not to be rehashed", u.Password)
+	}
+	if err := u.SetPassword("secret"); err != nil {
+		t.Fatal(err)
+	}
+	if !testArgon2id.Recognizes(u.Password) || !u.IsPassword("secret") || u.PasswordNeedsRehash() {
+		t.Errorf("SetPassword = %v", u.Password)
+	}
+	u.Password = "unknown"
+	if u.IsPassword("unknown") || !u.PasswordNeedsRehash() {
+		t.Error("Password of an unknown algorithm is verified")
+	}
+
+	SetPasswordHasher(bcr)
+	if len(passwordHashers) != 3 {
+		t.Errorf("SetPasswordHasher of a known hasher = %v", passwordHashers)
+	}
+	u.Password, _ = testArgon2id.Hash("secret")
+	if !u.IsPassword("secret") || !u.PasswordNeedsRehash() {
+		t.Error("argon2id password isn't verified or not to be rehashed once bcrypt is the default")
+	}
+}
@@ -0,0 +1,133 @@
+package entities
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxPasswordHistory is the number of previous passwords kept for each user, so a
+// policy can't ban reusing more passwords than that
+const MaxPasswordHistory = 24
+
+//
+// PasswordPolicy is a set of rules passwords of a domain's users have to comply with.
+// Zero values turn the rules off. History is the number of the most recent passwords
+// (including the current one) a new password must not match, MaxAgeDays is the number
+// of days a password may be used for before it has to be changed.
+//
+type PasswordPolicy struct {
+	DomainID      string `json:"domain_id"`
+	MinLength     int    `json:"min_length"`
+	RequireLower  bool   `json:"require_lower"`
+	RequireUpper  bool   `json:"require_upper"`
+	RequireDigit  bool   `json:"require_digit"`
+	RequireSymbol bool   `json:"require_symbol"`
+	Blocklist     bool   `json:"blocklist"`
+	History       int    `json:"history"`
+	MaxAgeDays    int    `json:"max_age_days"`
+	UpdatedOn     Time   `json:"updated_on"`
+}
+
+// IsValid checks if policy is valid
+func (p *PasswordPolicy) IsValid() (bool, error) {
+	if p.MinLength < 0 || p.History < 0 || p.MaxAgeDays < 0 {
+		return false, fmt.Errorf("Minimum length, history and maximum age cannot be negative!")
+	}
+	if p.History > MaxPasswordHistory {
+		return false, fmt.Errorf("History cannot be longer than %v passwords!", MaxPasswordHistory)
+	}
+	return true, nil
+}
+
+// Tighten makes the policy at least as strict as another one, e.g. for users of
+// several domains
+func (p *PasswordPolicy) Tighten(other PasswordPolicy) {
+	if other.MinLength > p.MinLength {
+		p.MinLength = other.MinLength
+	}
+	p.RequireLower = p.RequireLower || other.RequireLower
+	p.RequireUpper = p.RequireUpper || other.RequireUpper
+	p.RequireDigit = p.RequireDigit || other.RequireDigit
+	p.RequireSymbol = p.RequireSymbol || other.RequireSymbol
+	p.Blocklist = p.Blocklist || other.Blocklist
+	if other.History > p.History {
+		p.History = other.History
+	}
+	if other.MaxAgeDays > 0 && (p.MaxAgeDays == 0 || other.MaxAgeDays < p.MaxAgeDays) {
+		p.MaxAgeDays = other.MaxAgeDays
+	}
+}
+
+// Check checks if a clear text password complies with the policy. Reuse of previous
+// passwords is checked against the hashes of the user's passwords separately.
+func (p *PasswordPolicy) Check(clearTxt string, blocklist PasswordBlocklist) error {
+	if utf8.RuneCountInString(clearTxt) < p.MinLength {
+		return fmt.Errorf("Password must be at least %v characters long", p.MinLength)
+	}
+	var lower, upper, digit, symbol bool
+	for _, r := range clearTxt {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		case !unicode.IsLetter(r):
+			symbol = true
+		}
+	}
+	if p.RequireLower && !lower {
+		return fmt.Errorf("Password must contain a lower case letter")
+	}
+	if p.RequireUpper && !upper {
+		return fmt.Errorf("Password must contain an upper case letter")
+	}
+	if p.RequireDigit && !digit {
+		return fmt.Errorf("Password must contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		return fmt.Errorf("Password must contain a symbol")
+	}
+	if p.Blocklist && blocklist.Contains(clearTxt) {
+		return fmt.Errorf("Password is too common")
+	}
+	return nil
+}
+
+// IsExpired tells if a password changed at a given time has to be changed
+func (p *PasswordPolicy) IsExpired(changedOn time.Time) bool {
+	if p.MaxAgeDays == 0 {
+		return false
+	}
+	return time.Now().UTC().After(changedOn.AddDate(0, 0, p.MaxAgeDays))
+}
+
+// PasswordBlocklist is a set of passwords which are too common to be used. Passwords
+// are compared case-insensitively.
+type PasswordBlocklist map[string]bool
+
+// NewPasswordBlocklist reads a blocklist with a password per line
+func NewPasswordBlocklist(r io.Reader) (PasswordBlocklist, error) {
+	l := PasswordBlocklist{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if s := strings.TrimRight(scanner.Text(), " \t\r"); s != "" {
+			l[strings.ToLower(s)] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to read password blocklist: %v", err)
+	}
+	return l, nil
+}
+
+// Contains tells if a clear text password is in the blocklist
+func (l PasswordBlocklist) Contains(clearTxt string) bool {
+	return l[strings.ToLower(clearTxt)]
+}
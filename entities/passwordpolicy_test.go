@@ -0,0 +1,99 @@
+package entities
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	blocklist, err := NewPasswordBlocklist(strings.NewReader("Password1!\n\n  qwerty  \r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blocklist) != 2 || !blocklist.Contains("PASSWORD1!") || !blocklist.Contains("  qwerty") {
+		t.Errorf("NewPasswordBlocklist = %v", blocklist)
+	}
+
+	for _, tc := range []struct {
+		policy   PasswordPolicy
+		password string
+		valid    bool
+	}{
+		{PasswordPolicy{}, "a", true},
+		{PasswordPolicy{MinLength: 8}, "abcdefg", false},
+		{PasswordPolicy{MinLength: 8}, "abcdefgh", true},
+		{PasswordPolicy{MinLength: 8}, "äöüßäöü€", true},
+		{PasswordPolicy{MinLength: 8}, "äöüß", false},
+		{PasswordPolicy{RequireLower: true}, "ABC123!", false},
+		{PasswordPolicy{RequireLower: true}, "ABC123!é", true},
+		{PasswordPolicy{RequireUpper: true}, "abc123!", false},
+		{PasswordPolicy{RequireUpper: true}, "abc123!Ä", true},
+		{PasswordPolicy{RequireDigit: true}, "abcABC!", false},
+		{PasswordPolicy{RequireDigit: true}, "abcABC!1", true},
+		{PasswordPolicy{RequireSymbol: true}, "abcABC123", false},
+		{PasswordPolicy{RequireSymbol: true}, "abc ABC123", true},
+		{PasswordPolicy{RequireSymbol: true}, "abcABC123€", true},
+		{PasswordPolicy{RequireLower: true, RequireUpper: true, RequireDigit: true, RequireSymbol: true}, "aB1!", true},
+		{PasswordPolicy{Blocklist: true}, "password1!", false},
+		{PasswordPolicy{Blocklist: true}, "password1!x", true},
+		{PasswordPolicy{}, "password1!", true},
+	} {
+		err := tc.policy.Check(tc.password, blocklist)
+		if (err == nil) != tc.valid {
+			t.Errorf("Check(%q) with %+v = %v", tc.password, tc.policy, err)
+		}
+	}
+}
+
+func TestPasswordPolicyIsValid(t *testing.T) {
+	for _, tc := range []struct {
+		policy PasswordPolicy
+		valid  bool
+	}{
+		{PasswordPolicy{}, true},
+		{PasswordPolicy{MinLength: 10, History: MaxPasswordHistory, MaxAgeDays: 90}, true},
+		{PasswordPolicy{MinLength: -1}, false},
+		{PasswordPolicy{History: -1}, false},
+		{PasswordPolicy{MaxAgeDays: -1}, false},
+		{PasswordPolicy{History: MaxPasswordHistory + 1}, false},
+	} {
+		if ok, err := tc.policy.IsValid(); ok != tc.valid {
+			t.Errorf("IsValid of %+v = %v, %v", tc.policy, ok, err)
+		}
+	}
+}
+
+func TestPasswordPolicyTighten(t *testing.T) {
+	p := PasswordPolicy{MinLength: 10, RequireLower: true, History: 3, MaxAgeDays: 90}
+	p.Tighten(PasswordPolicy{MinLength: 8, RequireDigit: true, Blocklist: true, History: 5, MaxAgeDays: 30})
+	want := PasswordPolicy{MinLength: 10, RequireLower: true, RequireDigit: true, Blocklist: true, History: 5, MaxAgeDays: 30}
+	if p != want {
+		t.Errorf("Tighten = %+v, want %+v", p, want)
+	}
+
+	// A policy without a maximum age doesn't lift the other one's
+	p.Tighten(PasswordPolicy{})
+	if p != want {
+		t.Errorf("Tighten with an empty policy = %+v", p)
+	}
+	p = PasswordPolicy{}
+	p.Tighten(PasswordPolicy{MaxAgeDays: 60, RequireUpper: true, RequireSymbol: true})
+	if p.MaxAgeDays != 60 || !p.RequireUpper || !p.RequireSymbol {
+		t.Errorf("Tighten of an empty policy = %+v", p)
+	}
+}
+
+func TestPasswordPolicyIsExpired(t *testing.T) {
+	now := time.Now().UTC()
+	p := PasswordPolicy{MaxAgeDays: 30}
+	if p.IsExpired(now.AddDate(0, 0, -29)) || p.IsExpired(now) {
+		t.Error("IsExpired of a password younger than the maximum age")
+	}
+	if !p.IsExpired(now.AddDate(0, 0, -30).Add(-time.Minute)) || !p.IsExpired(time.Time{}) {
+		t.Error("IsExpired of a password older than the maximum age = false")
+	}
+	if (&PasswordPolicy{}).IsExpired(time.Time{}) {
+		t.Error("IsExpired without a maximum age")
+	}
+}
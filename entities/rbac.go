@@ -1,5 +1,14 @@
 package entities
 
+import "strings"
+
+const (
+	// PermissionSeparator separates levels of hierarchical permission names
+	PermissionSeparator = "."
+	// PermissionWildcard grants all permissions of the level it is placed at
+	PermissionWildcard = "*"
+)
+
 // BasicPermission entity
 type BasicPermission struct {
 	Name           string `json:"name"`
@@ -44,3 +53,47 @@ type BasicRoleCollection struct {
 	Roles     []BasicRole `json:"roles"`
 	Paginator Paginator   `json:"paginator"`
 }
+
+//
+// SoDConstraint is a static separation of duty constraint: a user may hold
+// at most Cardinality roles of the set of Roles
+//
+type SoDConstraint struct {
+	Name        string   `json:"name"`
+	Roles       []string `json:"roles"`
+	Cardinality int      `json:"cardinality"`
+}
+
+//
+// SoDConstraintCollection is a paginated collection of SoDConstraint entities
+//
+type SoDConstraintCollection struct {
+	Constraints []SoDConstraint `json:"constraints"`
+	Paginator   Paginator       `json:"paginator"`
+}
+
+// PermissionGrantingNames returns names of all permissions which grant a given
+// permission, i.e. the permission itself and wildcards of all its parent levels.
+// For example "users.delete" is granted by "users.delete", "users.*" and "*".
+func PermissionGrantingNames(name string) []string {
+	names := []string{name}
+	parts := strings.Split(name, PermissionSeparator)
+	for i := len(parts) - 1; i >= 0; i-- {
+		wildcard := strings.Join(append(parts[:i:i], PermissionWildcard), PermissionSeparator)
+		if wildcard != name {
+			names = append(names, wildcard)
+		}
+	}
+	return names
+}
+
+// PermissionGrants checks if a granted permission name (possibly a wildcard)
+// covers a requested permission name
+func PermissionGrants(granted, requested string) bool {
+	for _, name := range PermissionGrantingNames(requested) {
+		if name == granted {
+			return true
+		}
+	}
+	return false
+}
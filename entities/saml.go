@@ -0,0 +1,58 @@
+package entities
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/satori/go.uuid"
+)
+
+//
+// ServiceProvider is a SAML 2.0 service provider registered in a domain. Users of
+// the domain may sign in to it. Its entity ID is unique among all service providers
+// and assertions are sent to its assertion consumer service (ACS) URL only.
+//
+type ServiceProvider struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	DomainID  string `json:"domain_id"`
+	EntityID  string `json:"entity_id"`
+	ACSURL    string `json:"acs_url"`
+	Enabled   bool   `json:"enabled"`
+	CreatedOn Time   `json:"created_on"`
+}
+
+// NewServiceProvider creates a new enabled ServiceProvider entity in a given domain
+func NewServiceProvider(name, domainID, entityID, acsURL string) *ServiceProvider {
+	sp := &ServiceProvider{
+		ID:       uuid.NewV4().String(),
+		Name:     name,
+		DomainID: domainID,
+		EntityID: entityID,
+		ACSURL:   acsURL,
+		Enabled:  true,
+	}
+	sp.CreatedOn.Time = time.Now().UTC()
+	return sp
+}
+
+// IsValid checks if service provider is valid
+func (sp *ServiceProvider) IsValid() (bool, error) {
+	if sp.Name == "" {
+		return false, fmt.Errorf("Name cannot be empty!")
+	}
+	if sp.DomainID == "" {
+		return false, fmt.Errorf("Domain cannot be empty!")
+	}
+	if sp.EntityID == "" || strings.ContainsAny(sp.EntityID, " \t\r\n") {
+		return false, fmt.Errorf("Invalid entity ID %q", sp.EntityID)
+	}
+	u, err := url.Parse(sp.ACSURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Fragment != "" ||
+		strings.ContainsAny(sp.ACSURL, " \t\r\n") {
+		return false, fmt.Errorf("Invalid ACS URL %q", sp.ACSURL)
+	}
+	return true, nil
+}
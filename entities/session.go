@@ -8,10 +8,13 @@ import (
 )
 
 //
-// Session structure represents user's time limited session
+// Session structure represents user's time limited session. The ID is a credential
+// of the session's user, so tokens refer to the session by its reference instead,
+// which is generated separately and can't be used to call the API.
 //
 type Session struct {
 	ID         string       `json:"id"`
+	Reference  string       `json:"-"`
 	Domain     *BasicDomain `json:"domain"`
 	User       *BasicUser   `json:"user"`
 	UserAgent  string       `json:"-"`
@@ -25,6 +28,7 @@ type Session struct {
 func NewSession(user BasicUser, domain BasicDomain, userAgent, remoteAddr string) *Session {
 	s := &Session{
 		ID:         uuid.NewV4().String(),
+		Reference:  uuid.NewV4().String(),
 		Domain:     &domain,
 		User:       &user,
 		UserAgent:  userAgent,
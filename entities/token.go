@@ -0,0 +1,59 @@
+package entities
+
+//
+// AccessToken is a signed self-contained token of a session which services can
+// verify without calling the IdP
+//
+type AccessToken struct {
+	Token     string `json:"token"`
+	Type      string `json:"type"`
+	ExpiresOn Time   `json:"expires_on"`
+}
+
+//
+// AccessTokenClaims are claims carried by an access token. Tokens issued to OAuth
+// clients carry the client's ID and the granted scope as well; a token issued by
+// the client credentials grant has the client as its subject and no session. A
+// token refers to its session by the session's reference, never by its ID.
+//
+type AccessTokenClaims struct {
+	Issuer     string   `json:"iss,omitempty"`
+	Subject    string   `json:"sub"`
+	ID         string   `json:"jti"`
+	IssuedAt   int64    `json:"iat"`
+	ExpiresAt  int64    `json:"exp"`
+	SessionRef string   `json:"sid,omitempty"`
+	DomainID   string   `json:"domain_id"`
+	Roles      []string `json:"roles"`
+	ClientID   string   `json:"client_id,omitempty"`
+	Scope      string   `json:"scope,omitempty"`
+}
+
+//
+// TokenGrant is a result of an OAuth 2.0 grant. The refresh token and the ID token
+// are empty unless they were issued.
+//
+type TokenGrant struct {
+	AccessToken  AccessToken
+	RefreshToken string
+	IDToken      string
+	Scopes       []string
+}
+
+//
+// TokenInfo describes a token as returned by OAuth 2.0 token introspection (RFC
+// 7662). Only Active is set for an inactive token.
+//
+type TokenInfo struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	Issuer    string `json:"iss,omitempty"`
+	ID        string `json:"jti,omitempty"`
+	DomainID  string `json:"domain_id,omitempty"`
+}
@@ -1,19 +1,20 @@
 package entities
 
 import (
-	"crypto/sha1"
 	"fmt"
+	"net/mail"
+	"time"
 
-	"github.com/oleksandr/idp/config"
 	"github.com/satori/go.uuid"
 )
 
 // BasicUser contains basic user attributes
 type BasicUser struct {
-	ID       string `json:"id"`
-	Name     string `json:"name"`
-	Password string `json:"-"`
-	Enabled  bool   `json:"enabled"`
+	ID                string `json:"id"`
+	Name              string `json:"name"`
+	Password          string `json:"-"`
+	PasswordChangedOn Time   `json:"-"`
+	Enabled           bool   `json:"enabled"`
 }
 
 // User entities represent users
@@ -31,9 +32,16 @@ func NewBasicUser(name string) *BasicUser {
 	return a
 }
 
-// SetPassword hashes a given clearTxt and assigns it to password field
-func (u *BasicUser) SetPassword(clearTxt string) {
-	u.Password = passwordHash(clearTxt)
+// SetPassword hashes a given clearTxt and assigns it to password field. Password
+// policies are enforced by the use-cases, which need the clear text as well.
+func (u *BasicUser) SetPassword(clearTxt string) error {
+	hash, err := defaultPasswordHasher.Hash(clearTxt)
+	if err != nil {
+		return fmt.Errorf("Failed to hash password: %v", err)
+	}
+	u.Password = hash
+	u.PasswordChangedOn = Time{time.Now().UTC()}
+	return nil
 }
 
 // IsValid checks if user is valid
@@ -47,17 +55,32 @@ func (u *BasicUser) IsValid() (bool, error) {
 	return true, nil
 }
 
+// Email returns the user's name if it's an email address or an empty string otherwise.
+// Users don't have a separate email address.
+func (u *BasicUser) Email() string {
+	a, err := mail.ParseAddress(u.Name)
+	if err != nil || a.Address != u.Name {
+		return ""
+	}
+	return u.Name
+}
+
 // IsPassword checks if a given clear text is user's password
 func (u *BasicUser) IsPassword(clearTxt string) bool {
-	return u.Password == passwordHash(clearTxt)
+	h := hasherFor(u.Password)
+	if h == nil {
+		return false
+	}
+	return h.Verify(u.Password, clearTxt)
 }
 
-// passwordHash one-way hashes a string with the private HashSecret value.
-func passwordHash(s string) string {
-	hash := sha1.New()
-	hash.Write([]byte(s))
-	hash.Write([]byte(config.HashSecretSalt()))
-	return fmt.Sprintf("%x", hash.Sum(nil))
+// PasswordNeedsRehash tells if user's password hash was produced by a legacy
+// algorithm or with parameters different from the current configuration
+func (u *BasicUser) PasswordNeedsRehash() bool {
+	if !defaultPasswordHasher.Recognizes(u.Password) {
+		return true
+	}
+	return defaultPasswordHasher.NeedsRehash(u.Password)
 }
 
 // BasicUserCollection is a paginated collection of User entities
@@ -0,0 +1,86 @@
+package entities
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/satori/go.uuid"
+)
+
+// WebAuthnChallengeTTL is how long a user may take to complete a WebAuthn ceremony
+const WebAuthnChallengeTTL = 5 * time.Minute
+
+// webAuthnChallengeSize is the number of random bytes of a challenge
+const webAuthnChallengeSize = 32
+
+//
+// Credential is a WebAuthn credential (e.g. a passkey) registered by a user to
+// open sessions without a password. The public key is kept in its COSE_Key
+// encoding. SignCount is the last signature counter reported by the authenticator,
+// which helps to detect cloned authenticators.
+//
+type Credential struct {
+	ID         string `json:"id"`
+	UserID     string `json:"-"`
+	Name       string `json:"name"`
+	PublicKey  []byte `json:"-"`
+	SignCount  uint32 `json:"sign_count"`
+	CreatedOn  Time   `json:"created_on"`
+	LastUsedOn Time   `json:"last_used_on"`
+}
+
+//
+// WebAuthnChallenge is a pending WebAuthn ceremony: the registration of a credential
+// by a user (UserID is set, DomainID is empty) or opening a session in a domain
+// (UserID is empty if any user of the domain may sign in with a passkey). The
+// challenge is a random value the authenticator signs.
+//
+type WebAuthnChallenge struct {
+	ID        string `json:"id"`
+	Ceremony  string `json:"-"`
+	Challenge []byte `json:"-"`
+	DomainID  string `json:"-"`
+	UserID    string `json:"-"`
+	CreatedOn Time   `json:"created_on"`
+	ExpiresOn Time   `json:"expires_on"`
+}
+
+// NewCredential creates a new Credential entity of a user
+func NewCredential(userID, id, name string, publicKey []byte, signCount uint32) *Credential {
+	c := &Credential{
+		ID:        id,
+		UserID:    userID,
+		Name:      name,
+		PublicKey: publicKey,
+		SignCount: signCount,
+	}
+	c.CreatedOn.Time = time.Now().UTC()
+	c.LastUsedOn = c.CreatedOn
+	return c
+}
+
+// NewWebAuthnChallenge creates a new WebAuthnChallenge entity with a random challenge
+func NewWebAuthnChallenge(ceremony, domainID, userID string) (*WebAuthnChallenge, error) {
+	challenge := make([]byte, webAuthnChallengeSize)
+	if _, err := io.ReadFull(rand.Reader, challenge); err != nil {
+		return nil, fmt.Errorf("Failed to generate challenge: %v", err)
+	}
+	c := &WebAuthnChallenge{
+		ID:        uuid.NewV4().String(),
+		Ceremony:  ceremony,
+		Challenge: challenge,
+		DomainID:  domainID,
+		UserID:    userID,
+	}
+	now := time.Now().UTC()
+	c.CreatedOn.Time = now
+	c.ExpiresOn.Time = now.Add(WebAuthnChallengeTTL)
+	return c, nil
+}
+
+// IsExpired checks if the challenge is expired
+func (c *WebAuthnChallenge) IsExpired() bool {
+	return c.ExpiresOn.Sub(time.Now().UTC()) <= 0
+}
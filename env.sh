@@ -6,8 +6,38 @@ export IDP_REST_ADDR="127.0.0.1:8000"
 export IDP_RPC_ADDR="127.0.0.1:8001"
 # Session's TTL (expires=now+TTL)
 export IDP_SESSION_TTL=60
-# Secret salt for hashing passwords (change before initial install, don't change afterwards)
+# Secret salt of legacy SHA-1 password hashes (don't change while such hashes exist)
 export IDP_SECRET_SALT="842d7e1244b98f667f271a4e4d289772"
+# Password hashing algorithm (argon2id or bcrypt) and its cost parameters
+export IDP_PASSWORD_HASHER="argon2id"
+#export IDP_ARGON2_TIME=3
+#export IDP_ARGON2_MEMORY=65536
+#export IDP_ARGON2_THREADS=2
+#export IDP_BCRYPT_COST=12
+
+# Keep sessions in Redis (or "memory") instead of the database
+#export IDP_SESSION_STORE="redis://localhost:6379/0"
+
+# Access tokens (JWT), disabled unless a key is set
+#export IDP_JWT_ALGORITHM="HS256"
+#export IDP_JWT_KEY="change-me-to-a-random-secret-of-32-bytes-or-more"
+#export IDP_JWT_KEY_ID=""
+#export IDP_JWT_ISSUER="https://idp.example.com"
+#export IDP_ACCESS_TOKEN_TTL=5
+# Secret signing keys managed by "idp-cli keys" are encrypted with
+#export IDP_KEYS_SECRET="change-me-to-a-random-secret"
+
+# Lock users, remote addresses and domains out after failed attempts to sign in
+#export IDP_LOCKOUT_USER_THRESHOLD=5
+#export IDP_LOCKOUT_ADDRESS_THRESHOLD=20
+#export IDP_LOCKOUT_DOMAIN_THRESHOLD=0
+#export IDP_LOCKOUT_DURATION=15
+#export IDP_LOCKOUT_DELAY=1
+# Proxies whose X-Real-IP and X-Forwarded-For headers tell the client's address
+#export IDP_TRUSTED_PROXIES="127.0.0.1,10.0.0.0/8"
+
+# Passwords too common to be used by domains whose password policy asks for it
+#export IDP_PASSWORD_BLOCKLIST="/etc/idp/password-blocklist.txt"
 
 # SQL debug
 export IDP_SQL_TRACE=true
@@ -16,12 +46,9 @@ export IDP_SQL_TRACE=true
 export IDP_DB_Driver="mysql"
 export IDP_DB_DSN="root:@tcp(localhost:3306)/idp_dev?parseTime=true"
 
-#
-# NOT SUPPORTED FOR NOW
-#
-# Uncomment below for SQLite3
-#export IDP_DB_Driver="sqlite3"
-#export IDP_DB_DSN="/Users/alex/src/github.com/oleksandr/idp/db.sqlite3"
 # Uncomment below for PostgreSQL
 #export IDP_DB_Driver="postgres"
 #export IDP_DB_DSN="postgres://alex:@localhost/idp_dev?sslmode=disable"
+# Uncomment below for SQLite3
+#export IDP_DB_Driver="sqlite3"
+#export IDP_DB_DSN="/Users/alex/src/github.com/oleksandr/idp/db.sqlite3"
@@ -1,6 +1,9 @@
 package errs
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 // ErrorDomain defines domain of an error
 type ErrorDomain string
@@ -28,14 +31,18 @@ const (
 	ErrorTypeUnauthorized ErrorType = "UNAUTHORIZED"
 	// ErrorTypeOperational is usually an internal server error (unexpected)
 	ErrorTypeOperational ErrorType = "OPERATIONAL"
+	// ErrorTypePasswordExpired represents access denied until the password is changed
+	ErrorTypePasswordExpired ErrorType = "PASSWORD EXPIRED"
 )
 
-// Error represents general error at use-case level
+// Error represents general error at use-case level. RetryAfter hints when a
+// request failed because of too many attempts may be retried.
 type Error struct {
-	Domain ErrorDomain
-	Type   ErrorType
-	Msg    string
-	Cause  error
+	Domain     ErrorDomain
+	Type       ErrorType
+	Msg        string
+	Cause      error
+	RetryAfter time.Duration
 }
 
 // NewError constructs a new error structure
@@ -0,0 +1,97 @@
+package jwt
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+//
+// JWK is a public JSON Web Key (RFC 7517) of an RSA or an Ed25519 (RFC 8037) key
+//
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+	KeyID     string `json:"kid,omitempty"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+}
+
+//
+// JWKSet is a set of public keys as published at a JWKS endpoint
+//
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK returns the public part of an asymmetric key as a JWK
+func (k *Key) JWK() (JWK, error) {
+	jwk := JWK{
+		Use:       "sig",
+		Algorithm: k.Algorithm,
+		KeyID:     k.ID,
+	}
+	switch public := k.Public.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = encode(public.N.Bytes())
+		jwk.E = encode(big.NewInt(int64(public.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = encode(public)
+	default:
+		return jwk, fmt.Errorf("Unsupported public key type %T", k.Public)
+	}
+	return jwk, nil
+}
+
+// Key creates a key verifying tokens from a JWK
+func (jwk JWK) Key() (*Key, error) {
+	switch {
+	case jwk.KeyType == "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		exp := new(big.Int).SetBytes(e)
+		if !exp.IsInt64() || exp.Int64() > 1<<31-1 {
+			return nil, errors.New("Invalid RSA public exponent")
+		}
+		return NewPublicKey(jwk.KeyID, &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())})
+	case jwk.KeyType == "OKP" && jwk.Curve == "Ed25519":
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("Invalid Ed25519 public key size")
+		}
+		return NewPublicKey(jwk.KeyID, ed25519.PublicKey(x))
+	}
+	return nil, fmt.Errorf("Unsupported JWK type %q", jwk.KeyType)
+}
+
+// PublicKeys creates keys verifying tokens from all supported keys of a set
+func (set JWKSet) PublicKeys() []*Key {
+	keys := []*Key{}
+	for _, jwk := range set.Keys {
+		if k, err := jwk.Key(); err == nil {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,92 @@
+package jwt
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// publish marshals a JWKS of keys and parses it back as a service fetching it would
+func publish(t *testing.T, keys ...*Key) []*Key {
+	set := JWKSet{Keys: []JWK{}}
+	for _, k := range keys {
+		jwk, err := k.JWK()
+		must(t, err)
+		set.Keys = append(set.Keys, jwk)
+	}
+	b, err := json.Marshal(set)
+	must(t, err)
+
+	var parsed JWKSet
+	must(t, json.Unmarshal(b, &parsed))
+	return parsed.PublicKeys()
+}
+
+func TestJWKRoundTrip(t *testing.T) {
+	for _, algorithm := range []string{RS256, EdDSA} {
+		key := generateKey(t, "key1", algorithm)
+		keys := publish(t, key)
+		if len(keys) != 1 || keys[0].ID != "key1" || keys[0].Algorithm != algorithm || keys[0].CanSign() {
+			t.Fatalf("PublicKeys of a %v JWKS = %+v", algorithm, keys)
+		}
+
+		tok, err := Sign(testClaims{Subject: "john"}, key)
+		must(t, err)
+		var claims testClaims
+		if err = Verify(tok, keys, &claims); err != nil || claims.Subject != "john" {
+			t.Errorf("Verify of a %v token with the JWKS = %+v, %v", algorithm, claims, err)
+		}
+	}
+
+	if _, err := NewHMACKey("hmac", []byte("secret")).JWK(); err == nil {
+		t.Error("JWK of a HMAC key succeeded")
+	}
+	for _, jwk := range []JWK{
+		{KeyType: "oct", KeyID: "hmac"},
+		{KeyType: "EC", Curve: "P-256", X: "AA"},
+		{KeyType: "OKP", Curve: "X25519", X: encode(make([]byte, 32))},
+		{KeyType: "OKP", Curve: "Ed25519", X: encode(make([]byte, 31))},
+		{KeyType: "OKP", Curve: "Ed25519", X: "!"},
+		{KeyType: "RSA", N: "!", E: "AQAB"},
+		{KeyType: "RSA", N: "AQAB", E: "__________8"},
+	} {
+		if _, err := jwk.Key(); err == nil {
+			t.Errorf("Key of %+v succeeded", jwk)
+		}
+	}
+	if keys := (JWKSet{Keys: []JWK{{KeyType: "oct"}}}).PublicKeys(); len(keys) != 0 {
+		t.Errorf("PublicKeys of an unsupported JWK = %+v", keys)
+	}
+}
+
+func TestJWKRotation(t *testing.T) {
+	old := generateKey(t, "old", RS256)
+	current := generateKey(t, "current", EdDSA)
+	oldToken, err := Sign(testClaims{Subject: "john"}, old)
+	must(t, err)
+	token, err := Sign(testClaims{Subject: "jane"}, current)
+	must(t, err)
+
+	keys := publish(t, current, old)
+	for _, tok := range []string{oldToken, token} {
+		if err = Verify(tok, keys, &testClaims{}); err != nil {
+			t.Errorf("Verify while the old key is still published: %v", err)
+		}
+	}
+
+	keys = publish(t, current)
+	if err = Verify(oldToken, keys, &testClaims{}); err != ErrUnknownKey {
+		t.Errorf("Verify of a token of a key which isn't published = %v", err)
+	}
+	if err = Verify(token, keys, &testClaims{}); err != nil {
+		t.Errorf("Verify with the current key: %v", err)
+	}
+
+	// Keys of the same algorithm are told apart by their IDs
+	next := generateKey(t, "next", EdDSA)
+	if err = Verify(token, publish(t, next), &testClaims{}); err != ErrUnknownKey {
+		t.Errorf("Verify with another key of the same algorithm = %v", err)
+	}
+	if err = Verify(token, publish(t, next, current), &testClaims{}); err != nil {
+		t.Errorf("Verify with a key published next to the current one: %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+// Package jwt signs and verifies JSON Web Tokens (RFC 7519) in the compact JWS
+// form. Only HS256, RS256 and EdDSA (Ed25519) are supported, which is enough for
+// the IdP's access tokens and for services verifying them.
+package jwt
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+var (
+	// ErrMalformed is returned when a token can't be decoded
+	ErrMalformed = errors.New("Malformed token")
+	// ErrUnknownKey is returned when none of the keys matches a token's key ID and algorithm
+	ErrUnknownKey = errors.New("Unknown token key")
+	// ErrSignature is returned when a token's signature is invalid
+	ErrSignature = errors.New("Invalid token signature")
+	// ErrExpired is returned when a token has expired or isn't valid yet
+	ErrExpired = errors.New("Token has expired or is not valid yet")
+)
+
+// header is a JOSE header
+type header struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ,omitempty"`
+	KeyID     string `json:"kid,omitempty"`
+}
+
+// timeClaims are registered claims checked by Verify
+type timeClaims struct {
+	ExpiresAt int64 `json:"exp"`
+	NotBefore int64 `json:"nbf"`
+}
+
+// Sign encodes claims as JSON and signs them with a given key
+func Sign(claims interface{}, key *Key) (string, error) {
+	if !key.CanSign() {
+		return "", errors.New("Key can't be used for signing")
+	}
+	h, err := json.Marshal(header{Algorithm: key.Algorithm, Type: "JWT", KeyID: key.ID})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := encode(h) + "." + encode(payload)
+	sig, err := signature(signingInput, key)
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + encode(sig), nil
+}
+
+// Verify checks a token's signature with a key matching its key ID and algorithm,
+// checks "exp" and "nbf" claims (if present) and decodes the claims
+func Verify(token string, keys []*Key, claims interface{}) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ErrMalformed
+	}
+	var h header
+	if err := decodeJSON(parts[0], &h); err != nil {
+		return ErrMalformed
+	}
+	sig, err := decode(parts[2])
+	if err != nil {
+		return ErrMalformed
+	}
+
+	signingInput := parts[0] + "." + parts[1]
+	found := false
+	verified := false
+	for _, key := range keys {
+		// The algorithm must be the key's one, never the one a token claims
+		if key.Algorithm != h.Algorithm || (h.KeyID != "" && key.ID != h.KeyID) {
+			continue
+		}
+		found = true
+		if verifySignature(signingInput, sig, key) {
+			verified = true
+			break
+		}
+	}
+	if !found {
+		return ErrUnknownKey
+	}
+	if !verified {
+		return ErrSignature
+	}
+
+	var tc timeClaims
+	if err := decodeJSON(parts[1], &tc); err != nil {
+		return ErrMalformed
+	}
+	now := time.Now().Unix()
+	if (tc.ExpiresAt != 0 && now >= tc.ExpiresAt) || (tc.NotBefore != 0 && now < tc.NotBefore) {
+		return ErrExpired
+	}
+	if err := decodeJSON(parts[1], claims); err != nil {
+		return ErrMalformed
+	}
+	return nil
+}
+
+func signature(signingInput string, key *Key) ([]byte, error) {
+	switch key.Algorithm {
+	case HS256:
+		mac := hmac.New(sha256.New, key.Secret)
+		mac.Write([]byte(signingInput))
+		return mac.Sum(nil), nil
+	case RS256:
+		digest := sha256.Sum256([]byte(signingInput))
+		return key.Private.Sign(rand.Reader, digest[:], crypto.SHA256)
+	case EdDSA:
+		return key.Private.Sign(rand.Reader, []byte(signingInput), crypto.Hash(0))
+	}
+	return nil, errors.New("Unsupported algorithm " + key.Algorithm)
+}
+
+func verifySignature(signingInput string, sig []byte, key *Key) bool {
+	switch key.Algorithm {
+	case HS256:
+		if len(key.Secret) == 0 {
+			return false
+		}
+		mac := hmac.New(sha256.New, key.Secret)
+		mac.Write([]byte(signingInput))
+		return hmac.Equal(sig, mac.Sum(nil))
+	case RS256:
+		public, ok := key.Public.(*rsa.PublicKey)
+		if !ok {
+			return false
+		}
+		digest := sha256.Sum256([]byte(signingInput))
+		return rsa.VerifyPKCS1v15(public, crypto.SHA256, digest[:], sig) == nil
+	case EdDSA:
+		public, ok := key.Public.(ed25519.PublicKey)
+		if !ok {
+			return false
+		}
+		return ed25519.Verify(public, []byte(signingInput), sig)
+	}
+	return false
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
+
+func decodeJSON(s string, v interface{}) error {
+	b, err := decode(s)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
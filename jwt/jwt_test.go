@@ -0,0 +1,128 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testClaims struct {
+	Subject   string `json:"sub"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	NotBefore int64  `json:"nbf,omitempty"`
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// generateKey generates a key of any algorithm, including a HMAC key with a random secret
+func generateKey(t *testing.T, id, algorithm string) *Key {
+	if algorithm == HS256 {
+		secret := make([]byte, 32)
+		_, err := rand.Read(secret)
+		must(t, err)
+		return NewHMACKey(id, secret)
+	}
+	key, err := GenerateKey(id, algorithm)
+	must(t, err)
+	return key
+}
+
+// token signs a raw header and payload with a key, whatever algorithm the header claims
+func token(t *testing.T, h, payload string, key *Key) string {
+	signingInput := encode([]byte(h)) + "." + encode([]byte(payload))
+	if key == nil {
+		return signingInput + "."
+	}
+	sig, err := signature(signingInput, key)
+	must(t, err)
+	return signingInput + "." + encode(sig)
+}
+
+func TestSignVerify(t *testing.T) {
+	for _, algorithm := range []string{HS256, RS256, EdDSA} {
+		key := generateKey(t, "key1", algorithm)
+		tok, err := Sign(testClaims{Subject: "john", ExpiresAt: time.Now().Add(time.Minute).Unix()}, key)
+		must(t, err)
+
+		var claims testClaims
+		if err = Verify(tok, []*Key{key}, &claims); err != nil || claims.Subject != "john" {
+			t.Errorf("Verify of a %v token = %+v, %v", algorithm, claims, err)
+		}
+		if algorithm == HS256 {
+			continue
+		}
+		public, err := NewPublicKey("key1", key.Public)
+		must(t, err)
+		if _, err = Sign(claims, public); err == nil {
+			t.Errorf("Sign with a %v public key succeeded", algorithm)
+		}
+		if err = Verify(tok, []*Key{public}, &claims); err != nil {
+			t.Errorf("Verify of a %v token with the public key: %v", algorithm, err)
+		}
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	rsaKey := generateKey(t, "rsa", RS256)
+	hmacKey := generateKey(t, "hmac", HS256)
+	edKey := generateKey(t, "ed", EdDSA)
+	rsaPublic, err := rsaKey.MarshalPublicKey()
+	must(t, err)
+	keys := []*Key{rsaKey, hmacKey, edKey}
+
+	now := time.Now().Unix()
+	valid, err := Sign(testClaims{Subject: "john"}, rsaKey)
+	must(t, err)
+	parts := strings.Split(valid, ".")
+	payload := `{"sub":"john"}`
+
+	for _, tc := range []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{"alg none", token(t, `{"alg":"none","kid":"rsa"}`, payload, nil), ErrUnknownKey},
+		{"alg none without a key ID", token(t, `{"alg":"none"}`, payload, nil), ErrUnknownKey},
+		{"RS256 key as a HS256 secret",
+			token(t, `{"alg":"HS256","kid":"rsa"}`, payload, NewHMACKey("rsa", rsaPublic)), ErrUnknownKey},
+		{"RS256 key as a HS256 secret without a key ID",
+			token(t, `{"alg":"HS256"}`, payload, NewHMACKey("", rsaPublic)), ErrSignature},
+		{"HS256 key as RS256", token(t, `{"alg":"RS256","kid":"hmac"}`, payload, hmacKey), ErrUnknownKey},
+		{"ES256", token(t, `{"alg":"ES256","kid":"ed"}`, payload, edKey), ErrUnknownKey},
+		{"EdDSA signature as RS256", token(t, `{"alg":"RS256","kid":"ed"}`, payload, edKey), ErrUnknownKey},
+		{"unknown key ID", token(t, `{"alg":"RS256","kid":"other"}`, payload, rsaKey), ErrUnknownKey},
+		{"tampered payload", parts[0] + "." + encode([]byte(`{"sub":"root"}`)) + "." + parts[2], ErrSignature},
+		{"tampered header", encode([]byte(`{"alg":"RS256","typ":"JWT","kid":"rsa","x":1}`)) + "." + parts[1] + "." + parts[2], ErrSignature},
+		{"truncated signature", valid[:len(valid)-4], ErrSignature},
+		{"expired", token(t, `{"alg":"EdDSA","kid":"ed"}`, `{"sub":"john","exp":`+strconv.FormatInt(now-1, 10)+`}`, edKey), ErrExpired},
+		{"expiring now", token(t, `{"alg":"EdDSA","kid":"ed"}`, `{"sub":"john","exp":`+strconv.FormatInt(now, 10)+`}`, edKey), ErrExpired},
+		{"not valid yet", token(t, `{"alg":"EdDSA","kid":"ed"}`, `{"sub":"john","nbf":`+strconv.FormatInt(now+60, 10)+`}`, edKey), ErrExpired},
+		{"two segments", parts[0] + "." + parts[1], ErrMalformed},
+		{"four segments", valid + "." + parts[2], ErrMalformed},
+		{"empty", "", ErrMalformed},
+		{"header isn't base64url", "!" + valid, ErrMalformed},
+		{"header isn't JSON", encode([]byte("RS256")) + "." + parts[1] + "." + parts[2], ErrMalformed},
+		{"signature isn't base64url", valid + "=", ErrMalformed},
+		{"payload isn't JSON", token(t, `{"alg":"RS256","kid":"rsa"}`, "john", rsaKey), ErrMalformed},
+		{"claims of a wrong type", token(t, `{"alg":"RS256","kid":"rsa"}`, `{"sub":1}`, rsaKey), ErrMalformed},
+	} {
+		var claims testClaims
+		if err := Verify(tc.token, keys, &claims); err != tc.err {
+			t.Errorf("Verify with %v = %v, want %v", tc.name, err, tc.err)
+		}
+	}
+
+	if err := Verify(valid, nil, &testClaims{}); err != ErrUnknownKey {
+		t.Errorf("Verify without keys = %v", err)
+	}
+	if err := Verify(token(t, `{"alg":"HS256"}`, payload, NewHMACKey("", nil)), []*Key{NewHMACKey("", nil)}, &testClaims{}); err != ErrSignature {
+		t.Errorf("Verify with an empty HMAC secret = %v", err)
+	}
+}
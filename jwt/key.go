@@ -0,0 +1,191 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+const (
+	// HS256 is HMAC using SHA-256
+	HS256 = "HS256"
+	// RS256 is RSASSA-PKCS1-v1_5 using SHA-256
+	RS256 = "RS256"
+	// EdDSA is Edwards-curve signature algorithm (Ed25519 only)
+	EdDSA = "EdDSA"
+)
+
+// rsaKeyBits is the size of generated RSA keys
+const rsaKeyBits = 2048
+
+//
+// Key is a key tokens are signed and/or verified with. A HMAC key keeps its
+// secret, an asymmetric key keeps its public key (*rsa.PublicKey or
+// ed25519.PublicKey) and, if it can sign, its private key.
+//
+type Key struct {
+	ID        string
+	Algorithm string
+	Secret    []byte
+	Private   crypto.Signer
+	Public    crypto.PublicKey
+}
+
+// NewHMACKey creates a HS256 key with a given ID (may be empty) and a secret
+func NewHMACKey(id string, secret []byte) *Key {
+	return &Key{
+		ID:        id,
+		Algorithm: HS256,
+		Secret:    secret,
+	}
+}
+
+// NewRSAKey creates a RS256 key with a given ID (may be empty) and a private key
+func NewRSAKey(id string, private *rsa.PrivateKey) *Key {
+	return &Key{
+		ID:        id,
+		Algorithm: RS256,
+		Private:   private,
+		Public:    &private.PublicKey,
+	}
+}
+
+// NewEd25519Key creates an EdDSA key with a given ID (may be empty) and a private key
+func NewEd25519Key(id string, private ed25519.PrivateKey) *Key {
+	return &Key{
+		ID:        id,
+		Algorithm: EdDSA,
+		Private:   private,
+		Public:    private.Public(),
+	}
+}
+
+// NewPublicKey creates a RS256 or EdDSA key (depending on a public key's type)
+// which can only verify tokens
+func NewPublicKey(id string, public crypto.PublicKey) (*Key, error) {
+	k := &Key{ID: id, Public: public}
+	switch public.(type) {
+	case *rsa.PublicKey:
+		k.Algorithm = RS256
+	case ed25519.PublicKey:
+		k.Algorithm = EdDSA
+	default:
+		return nil, fmt.Errorf("Unsupported public key type %T", public)
+	}
+	return k, nil
+}
+
+// GenerateKey generates a new RS256 or EdDSA key with a given ID
+func GenerateKey(id, algorithm string) (*Key, error) {
+	switch algorithm {
+	case RS256:
+		private, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+		if err != nil {
+			return nil, err
+		}
+		return NewRSAKey(id, private), nil
+	case EdDSA:
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return NewEd25519Key(id, private), nil
+	}
+	return nil, fmt.Errorf("Unsupported algorithm %q", algorithm)
+}
+
+// CanSign tells if the key has everything to sign tokens
+func (k *Key) CanSign() bool {
+	switch k.Algorithm {
+	case HS256:
+		return len(k.Secret) > 0
+	case RS256, EdDSA:
+		return k.Private != nil
+	}
+	return false
+}
+
+// MarshalPrivateKey encodes a private key of an asymmetric key in PKCS #8 form (DER)
+func (k *Key) MarshalPrivateKey() ([]byte, error) {
+	if k.Private == nil {
+		return nil, errors.New("Key has no private key")
+	}
+	return x509.MarshalPKCS8PrivateKey(k.Private)
+}
+
+// MarshalPublicKey encodes a public key of an asymmetric key in PKIX form (DER)
+func (k *Key) MarshalPublicKey() ([]byte, error) {
+	if k.Public == nil {
+		return nil, errors.New("Key has no public key")
+	}
+	return x509.MarshalPKIXPublicKey(k.Public)
+}
+
+// ParsePrivateKey creates a key with a given ID from a RSA or Ed25519 private key in
+// PKCS #8 form (DER)
+func ParsePrivateKey(id string, der []byte) (*Key, error) {
+	private, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	switch p := private.(type) {
+	case *rsa.PrivateKey:
+		return NewRSAKey(id, p), nil
+	case ed25519.PrivateKey:
+		return NewEd25519Key(id, p), nil
+	}
+	return nil, fmt.Errorf("Unsupported private key type %T", private)
+}
+
+// ParsePublicKey creates a key with a given ID from a RSA or Ed25519 public key in
+// PKIX form (DER)
+func ParsePublicKey(id string, der []byte) (*Key, error) {
+	public, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, err
+	}
+	return NewPublicKey(id, public)
+}
+
+// ParseRSAPrivateKey parses a PEM encoded RSA private key in PKCS #1 or PKCS #8 form
+func ParseRSAPrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("No PEM data found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("Not an RSA private key: %T", key)
+	}
+	return rsaKey, nil
+}
+
+// ParseRSAPublicKey parses a PEM encoded RSA public key in PKIX form
+func ParseRSAPublicKey(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("No PEM data found")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("Not an RSA public key: %T", key)
+	}
+	return rsaKey, nil
+}
@@ -0,0 +1,231 @@
+package kv
+
+import (
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often expired keys are evicted from a MemoryStore
+const sweepInterval = time.Minute
+
+// item is a stored value or set with its expiration time (zero if it never expires)
+type item struct {
+	value     []byte
+	members   map[string]bool
+	expiresOn time.Time
+}
+
+func (i item) expired(now time.Time) bool {
+	return !i.expiresOn.IsZero() && !i.expiresOn.After(now)
+}
+
+//
+// MemoryStore is an in-process implementation of Store. Expired keys are
+// invisible immediately and are evicted from memory periodically.
+//
+type MemoryStore struct {
+	mu        sync.Mutex
+	items     map[string]item
+	lastSweep time.Time
+}
+
+// NewMemoryStore creates an empty in-process store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		items:     map[string]item{},
+		lastSweep: time.Now(),
+	}
+}
+
+// Ping always succeeds
+func (s *MemoryStore) Ping() error {
+	return nil
+}
+
+// Get returns a value of a key or ErrNotFound
+func (s *MemoryStore) Get(key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, ok := s.get(key, time.Now())
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if i.members != nil {
+		return nil, ErrWrongType
+	}
+	return i.value, nil
+}
+
+// MGet returns values of given keys, nil for the missing ones
+func (s *MemoryStore) MGet(keys []string) ([][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	values := make([][]byte, len(keys))
+	for n, key := range keys {
+		if i, ok := s.get(key, now); ok {
+			values[n] = i.value
+		}
+	}
+	return values, nil
+}
+
+// Set sets a value of a key
+func (s *MemoryStore) Set(key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.set(key, item{value: append([]byte{}, value...)}, ttl, time.Now())
+	return nil
+}
+
+// Replace sets a value of a key only if the key exists and tells if it did
+func (s *MemoryStore) Replace(key string, value []byte, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if _, ok := s.get(key, now); !ok {
+		return false, nil
+	}
+	s.set(key, item{value: append([]byte{}, value...)}, ttl, now)
+	return true, nil
+}
+
+// Del deletes given keys and returns a number of the deleted ones
+func (s *MemoryStore) Del(keys []string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	n := 0
+	for _, key := range keys {
+		if _, ok := s.get(key, now); ok {
+			delete(s.items, key)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// TTL returns the time a key has left to live (0 if it never expires) or ErrNotFound
+func (s *MemoryStore) TTL(key string) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	i, ok := s.get(key, now)
+	if !ok {
+		return 0, ErrNotFound
+	}
+	if i.expiresOn.IsZero() {
+		return 0, nil
+	}
+	return i.expiresOn.Sub(now), nil
+}
+
+// Expire sets a positive TTL of a key and tells if the key exists
+func (s *MemoryStore) Expire(key string, ttl time.Duration) (bool, error) {
+	if ttl <= 0 {
+		return false, errInvalidTTL
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	i, ok := s.get(key, now)
+	if !ok {
+		return false, nil
+	}
+	s.set(key, i, ttl, now)
+	return true, nil
+}
+
+// SAdd adds members to a set, the set is created if it doesn't exist
+func (s *MemoryStore) SAdd(key string, members []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	i, ok := s.get(key, now)
+	if !ok {
+		i = item{members: map[string]bool{}}
+	} else if i.members == nil {
+		return ErrWrongType
+	}
+	for _, m := range members {
+		i.members[m] = true
+	}
+	s.items[key] = i
+	return nil
+}
+
+// SRem removes members from a set, the set is deleted once it's empty
+func (s *MemoryStore) SRem(key string, members []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, ok := s.get(key, time.Now())
+	if !ok {
+		return nil
+	}
+	if i.members == nil {
+		return ErrWrongType
+	}
+	for _, m := range members {
+		delete(i.members, m)
+	}
+	if len(i.members) == 0 {
+		delete(s.items, key)
+	}
+	return nil
+}
+
+// SMembers returns all members of a set, none if it doesn't exist
+func (s *MemoryStore) SMembers(key string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	members := []string{}
+	i, ok := s.get(key, time.Now())
+	if !ok {
+		return members, nil
+	}
+	if i.members == nil {
+		return nil, ErrWrongType
+	}
+	for m := range i.members {
+		members = append(members, m)
+	}
+	return members, nil
+}
+
+// get returns an item of a key unless it has expired. Must be called with the lock held.
+func (s *MemoryStore) get(key string, now time.Time) (item, bool) {
+	i, ok := s.items[key]
+	if !ok || i.expired(now) {
+		return item{}, false
+	}
+	return i, true
+}
+
+// set stores an item expiring after a given TTL and evicts expired keys from time
+// to time. Must be called with the lock held.
+func (s *MemoryStore) set(key string, i item, ttl time.Duration, now time.Time) {
+	i.expiresOn = time.Time{}
+	if ttl > 0 {
+		i.expiresOn = now.Add(ttl)
+	}
+	s.items[key] = i
+
+	if now.Sub(s.lastSweep) >= sweepInterval {
+		for k, i := range s.items {
+			if i.expired(now) {
+				delete(s.items, k)
+			}
+		}
+		s.lastSweep = now
+	}
+}
@@ -0,0 +1,358 @@
+package kv
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// redisDefaultPort is used when a URL doesn't specify a port
+	redisDefaultPort = "6379"
+	// redisTimeout limits dialing and every command round trip
+	redisTimeout = 5 * time.Second
+	// redisMaxIdleConns is the number of connections kept open between commands
+	redisMaxIdleConns = 16
+)
+
+// redisConn is a connection to a Redis-compatible server
+type redisConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+}
+
+//
+// RedisStore is an implementation of Store that talks RESP to a Redis-compatible
+// server. It is safe for concurrent use: connections are dialed on demand and
+// up to redisMaxIdleConns of them are reused.
+//
+type RedisStore struct {
+	addr     string
+	password string
+	db       int
+	idle     chan *redisConn
+}
+
+// NewRedisStore creates a store for a given "redis://[:password@]host[:port][/db]" URL.
+// No connection is made until the first command.
+func NewRedisStore(rawurl string) (*RedisStore, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "redis" {
+		return nil, fmt.Errorf("Unsupported Redis URL scheme %q", u.Scheme)
+	}
+
+	s := &RedisStore{
+		addr: u.Host,
+		idle: make(chan *redisConn, redisMaxIdleConns),
+	}
+	if _, _, err := net.SplitHostPort(s.addr); err != nil {
+		s.addr = net.JoinHostPort(s.addr, redisDefaultPort)
+	}
+	if u.User != nil {
+		if p, ok := u.User.Password(); ok {
+			s.password = p
+		} else {
+			s.password = u.User.Username()
+		}
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		s.db, err = strconv.Atoi(db)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid Redis database %q", db)
+		}
+	}
+	return s, nil
+}
+
+// Ping checks if the server is reachable
+func (s *RedisStore) Ping() error {
+	_, err := s.do("PING")
+	return err
+}
+
+// Get returns a value of a key or ErrNotFound
+func (s *RedisStore) Get(key string) ([]byte, error) {
+	reply, err := s.do("GET", key)
+	if err != nil {
+		return nil, err
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, unexpectedReply("GET", reply)
+	}
+	if value == nil {
+		return nil, ErrNotFound
+	}
+	return value, nil
+}
+
+// MGet returns values of given keys, nil for the missing ones
+func (s *RedisStore) MGet(keys []string) ([][]byte, error) {
+	if len(keys) == 0 {
+		return [][]byte{}, nil
+	}
+	reply, err := s.do("MGET", keys...)
+	if err != nil {
+		return nil, err
+	}
+	items, ok := reply.([]interface{})
+	if !ok || len(items) != len(keys) {
+		return nil, unexpectedReply("MGET", reply)
+	}
+	values := make([][]byte, len(items))
+	for i, item := range items {
+		if values[i], ok = item.([]byte); !ok {
+			return nil, unexpectedReply("MGET", reply)
+		}
+	}
+	return values, nil
+}
+
+// Set sets a value of a key
+func (s *RedisStore) Set(key string, value []byte, ttl time.Duration) error {
+	_, err := s.set(key, value, ttl)
+	return err
+}
+
+// Replace sets a value of a key only if the key exists and tells if it did
+func (s *RedisStore) Replace(key string, value []byte, ttl time.Duration) (bool, error) {
+	return s.set(key, value, ttl, "XX")
+}
+
+// Del deletes given keys and returns a number of the deleted ones
+func (s *RedisStore) Del(keys []string) (int, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	reply, err := s.do("DEL", keys...)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := reply.(int64)
+	if !ok {
+		return 0, unexpectedReply("DEL", reply)
+	}
+	return int(n), nil
+}
+
+// TTL returns the time a key has left to live (0 if it never expires) or ErrNotFound
+func (s *RedisStore) TTL(key string) (time.Duration, error) {
+	reply, err := s.do("PTTL", key)
+	if err != nil {
+		return 0, err
+	}
+	ms, ok := reply.(int64)
+	if !ok {
+		return 0, unexpectedReply("PTTL", reply)
+	}
+	switch {
+	case ms == -2:
+		return 0, ErrNotFound
+	case ms < 0:
+		return 0, nil
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// Expire sets a positive TTL of a key and tells if the key exists
+func (s *RedisStore) Expire(key string, ttl time.Duration) (bool, error) {
+	if ttl <= 0 {
+		return false, errInvalidTTL
+	}
+	reply, err := s.do("PEXPIRE", key, strconv.FormatInt(milliseconds(ttl), 10))
+	if err != nil {
+		return false, err
+	}
+	n, ok := reply.(int64)
+	if !ok {
+		return false, unexpectedReply("PEXPIRE", reply)
+	}
+	return n == 1, nil
+}
+
+// SAdd adds members to a set, the set is created if it doesn't exist
+func (s *RedisStore) SAdd(key string, members []string) error {
+	if len(members) == 0 {
+		return nil
+	}
+	return s.doInt("SADD", append([]string{key}, members...)...)
+}
+
+// SRem removes members from a set, the set is deleted once it's empty
+func (s *RedisStore) SRem(key string, members []string) error {
+	if len(members) == 0 {
+		return nil
+	}
+	return s.doInt("SREM", append([]string{key}, members...)...)
+}
+
+// SMembers returns all members of a set, none if it doesn't exist
+func (s *RedisStore) SMembers(key string) ([]string, error) {
+	reply, err := s.do("SMEMBERS", key)
+	if err != nil {
+		return nil, err
+	}
+	items, ok := reply.([]interface{})
+	if !ok {
+		return nil, unexpectedReply("SMEMBERS", reply)
+	}
+	members := make([]string, len(items))
+	for i, item := range items {
+		b, ok := item.([]byte)
+		if !ok {
+			return nil, unexpectedReply("SMEMBERS", reply)
+		}
+		members[i] = string(b)
+	}
+	return members, nil
+}
+
+// set runs SET with an optional expiration and given flags and tells if the
+// value has been set (SET replies with a null bulk string when NX/XX prevent it)
+func (s *RedisStore) set(key string, value []byte, ttl time.Duration, flags ...string) (bool, error) {
+	args := [][]byte{[]byte("SET"), []byte(key), value}
+	if ttl > 0 {
+		args = append(args, []byte("PX"), []byte(strconv.FormatInt(milliseconds(ttl), 10)))
+	}
+	for _, f := range flags {
+		args = append(args, []byte(f))
+	}
+
+	reply, err := s.doArgs(args)
+	if err != nil {
+		return false, err
+	}
+	switch r := reply.(type) {
+	case string:
+		return true, nil
+	case []byte:
+		if r == nil {
+			return false, nil
+		}
+	}
+	return false, unexpectedReply("SET", reply)
+}
+
+// do runs a command with string arguments
+func (s *RedisStore) do(cmd string, args ...string) (interface{}, error) {
+	b := make([][]byte, 0, len(args)+1)
+	b = append(b, []byte(cmd))
+	for _, arg := range args {
+		b = append(b, []byte(arg))
+	}
+	return s.doArgs(b)
+}
+
+// doInt runs a command with string arguments which replies with an integer
+func (s *RedisStore) doInt(cmd string, args ...string) error {
+	reply, err := s.do(cmd, args...)
+	if err != nil {
+		return err
+	}
+	if _, ok := reply.(int64); !ok {
+		return unexpectedReply(cmd, reply)
+	}
+	return nil
+}
+
+// doArgs sends a command and reads its reply. Error replies are returned as
+// errors. A connection is reused unless it failed.
+func (s *RedisStore) doArgs(args [][]byte) (interface{}, error) {
+	c, err := s.get()
+	if err != nil {
+		return nil, err
+	}
+	reply, err := c.roundTrip(args)
+	if err != nil {
+		c.conn.Close()
+		return nil, err
+	}
+	s.put(c)
+
+	if e, ok := reply.(respError); ok {
+		return nil, fmt.Errorf("Redis %s failed: %v", args[0], e)
+	}
+	return reply, nil
+}
+
+// get takes an idle connection or dials a new one
+func (s *RedisStore) get() (*redisConn, error) {
+	select {
+	case c := <-s.idle:
+		return c, nil
+	default:
+	}
+
+	conn, err := net.DialTimeout("tcp", s.addr, redisTimeout)
+	if err != nil {
+		return nil, err
+	}
+	c := &redisConn{conn: conn, r: bufio.NewReader(conn), w: bufio.NewWriter(conn)}
+	if s.password != "" {
+		if err = c.expectOK("AUTH", s.password); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if s.db != 0 {
+		if err = c.expectOK("SELECT", strconv.Itoa(s.db)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// put returns a connection to the idle ones or closes it if there are enough
+func (s *RedisStore) put(c *redisConn) {
+	select {
+	case s.idle <- c:
+	default:
+		c.conn.Close()
+	}
+}
+
+func (c *redisConn) roundTrip(args [][]byte) (interface{}, error) {
+	c.conn.SetDeadline(time.Now().Add(redisTimeout))
+	if err := writeCommand(c.w, args); err != nil {
+		return nil, err
+	}
+	return readReply(c.r)
+}
+
+// expectOK runs a connection setup command which must reply with OK
+func (c *redisConn) expectOK(args ...string) error {
+	b := make([][]byte, len(args))
+	for i, arg := range args {
+		b[i] = []byte(arg)
+	}
+	reply, err := c.roundTrip(b)
+	if err != nil {
+		return err
+	}
+	if reply != "OK" {
+		return fmt.Errorf("Redis %s failed: %v", args[0], reply)
+	}
+	return nil
+}
+
+// milliseconds converts a positive TTL to milliseconds rounding it up to at least one
+func milliseconds(ttl time.Duration) int64 {
+	ms := int64(ttl / time.Millisecond)
+	if ms < 1 {
+		ms = 1
+	}
+	return ms
+}
+
+func unexpectedReply(cmd string, reply interface{}) error {
+	return fmt.Errorf("Unexpected reply to Redis %s: %#v", cmd, reply)
+}
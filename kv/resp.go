@@ -0,0 +1,131 @@
+package kv
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// maxBulkLength is the largest bulk string accepted (the same limit as Redis has)
+const maxBulkLength = 512 * 1024 * 1024
+
+// respError is an error reply of the RESP protocol (e.g. "ERR unknown command")
+type respError string
+
+func (e respError) Error() string {
+	return string(e)
+}
+
+// writeCommand writes a command as a RESP array of bulk strings
+func writeCommand(w *bufio.Writer, args [][]byte) error {
+	fmt.Fprintf(w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		writeBulk(w, arg)
+	}
+	return w.Flush()
+}
+
+// writeReply writes a reply: a string as a simple string, respError as an
+// error, int64 as an integer, []byte as a bulk string (nil as a null one) and
+// [][]byte as an array of bulk strings
+func writeReply(w *bufio.Writer, reply interface{}) error {
+	switch r := reply.(type) {
+	case string:
+		fmt.Fprintf(w, "+%s\r\n", r)
+	case respError:
+		fmt.Fprintf(w, "-%s\r\n", r)
+	case int64:
+		fmt.Fprintf(w, ":%d\r\n", r)
+	case []byte:
+		writeBulk(w, r)
+	case [][]byte:
+		fmt.Fprintf(w, "*%d\r\n", len(r))
+		for _, b := range r {
+			writeBulk(w, b)
+		}
+	default:
+		return fmt.Errorf("Unsupported reply type %T", reply)
+	}
+	return w.Flush()
+}
+
+func writeBulk(w *bufio.Writer, b []byte) {
+	if b == nil {
+		w.WriteString("$-1\r\n")
+		return
+	}
+	fmt.Fprintf(w, "$%d\r\n", len(b))
+	w.Write(b)
+	w.WriteString("\r\n")
+}
+
+// readReply reads a RESP value. Simple strings are returned as string, errors
+// as respError, integers as int64, bulk strings as []byte (nil for a null bulk
+// string) and arrays as []interface{} (nil for a null array).
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errors.New("Empty RESP line")
+	}
+
+	switch line[0] {
+	case '+':
+		return string(line[1:]), nil
+	case '-':
+		return respError(line[1:]), nil
+	case ':':
+		return strconv.ParseInt(string(line[1:]), 10, 64)
+	case '$':
+		n, err := strconv.Atoi(string(line[1:]))
+		if err != nil || n < -1 || n > maxBulkLength {
+			return nil, fmt.Errorf("Invalid RESP bulk length %q", line[1:])
+		}
+		if n == -1 {
+			return []byte(nil), nil
+		}
+		b := make([]byte, n+2)
+		if _, err = io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		if b[n] != '\r' || b[n+1] != '\n' {
+			return nil, errors.New("Invalid RESP bulk string terminator")
+		}
+		return b[:n], nil
+	case '*':
+		n, err := strconv.Atoi(string(line[1:]))
+		if err != nil || n < -1 {
+			return nil, fmt.Errorf("Invalid RESP array length %q", line[1:])
+		}
+		if n == -1 {
+			return []interface{}(nil), nil
+		}
+		values := make([]interface{}, n)
+		for i := range values {
+			values[i], err = readReply(r)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("Unexpected RESP type %q", line[0])
+}
+
+// readLine reads a line terminated by CRLF and returns it without the terminator
+func readLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return nil, errors.New("RESP line is too long")
+	} else if err != nil {
+		return nil, err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, errors.New("Invalid RESP line terminator")
+	}
+	return line[:len(line)-2], nil
+}
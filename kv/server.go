@@ -0,0 +1,277 @@
+package kv
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//
+// Server exposes a Store over the Redis protocol (RESP). It understands only the
+// commands RedisStore sends (PING, AUTH, SELECT, GET, MGET, SET, DEL, PTTL,
+// PEXPIRE, SADD, SREM, SMEMBERS and QUIT), so it is a local stand-in for Redis in
+// tests and development rather than a replacement. AUTH and SELECT are accepted
+// and ignored.
+//
+type Server struct {
+	Store Store
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]bool
+}
+
+// ListenAndServe listens on a given TCP address and serves connections until
+// the server is closed
+func (srv *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return srv.Serve(l)
+}
+
+// Serve serves connections accepted by a given listener until the server is closed
+func (srv *Server) Serve(l net.Listener) error {
+	srv.mu.Lock()
+	srv.listener = l
+	srv.conns = map[net.Conn]bool{}
+	srv.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		srv.mu.Lock()
+		srv.conns[conn] = true
+		srv.mu.Unlock()
+		go srv.serveConn(conn)
+	}
+}
+
+// Addr returns the address the server listens on or nil if it doesn't
+func (srv *Server) Addr() net.Addr {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.listener == nil {
+		return nil
+	}
+	return srv.listener.Addr()
+}
+
+// Close stops listening and closes all connections
+func (srv *Server) Close() error {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	var err error
+	if srv.listener != nil {
+		err = srv.listener.Close()
+	}
+	for conn := range srv.conns {
+		conn.Close()
+	}
+	return err
+}
+
+func (srv *Server) serveConn(conn net.Conn) {
+	defer func() {
+		conn.Close()
+		srv.mu.Lock()
+		delete(srv.conns, conn)
+		srv.mu.Unlock()
+	}()
+
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	for {
+		req, err := readReply(r)
+		if err != nil {
+			if err != io.EOF {
+				writeReply(w, respError("ERR Protocol error: "+err.Error()))
+			}
+			return
+		}
+		args, ok := commandArgs(req)
+		if !ok {
+			writeReply(w, respError("ERR Protocol error: expected an array of bulk strings"))
+			return
+		}
+		if err = writeReply(w, srv.execute(args)); err != nil {
+			return
+		}
+		if strings.ToUpper(string(args[0])) == "QUIT" {
+			return
+		}
+	}
+}
+
+// commandArgs converts a request to command arguments
+func commandArgs(req interface{}) ([][]byte, bool) {
+	items, ok := req.([]interface{})
+	if !ok || len(items) == 0 {
+		return nil, false
+	}
+	args := make([][]byte, len(items))
+	for i, item := range items {
+		if args[i], ok = item.([]byte); !ok || args[i] == nil {
+			return nil, false
+		}
+	}
+	return args, true
+}
+
+// execute runs a command against the store and returns its reply
+func (srv *Server) execute(args [][]byte) interface{} {
+	cmd := strings.ToUpper(string(args[0]))
+	argc := len(args) - 1
+	switch {
+	case cmd == "PING" && argc == 0:
+		return "PONG"
+	case cmd == "PING" && argc == 1:
+		return args[1]
+	case cmd == "QUIT" && argc == 0,
+		cmd == "AUTH" && argc >= 1,
+		cmd == "SELECT" && argc == 1:
+		return "OK"
+	case cmd == "GET" && argc == 1:
+		value, err := srv.Store.Get(string(args[1]))
+		if err == ErrNotFound {
+			return []byte(nil)
+		} else if err != nil {
+			return storeError(err)
+		}
+		return value
+	case cmd == "MGET" && argc >= 1:
+		values, err := srv.Store.MGet(stringArgs(args[1:]))
+		if err != nil {
+			return storeError(err)
+		}
+		return values
+	case cmd == "SET" && argc >= 2:
+		return srv.set(args[1:])
+	case cmd == "DEL" && argc >= 1:
+		n, err := srv.Store.Del(stringArgs(args[1:]))
+		if err != nil {
+			return storeError(err)
+		}
+		return int64(n)
+	case cmd == "PTTL" && argc == 1:
+		ttl, err := srv.Store.TTL(string(args[1]))
+		if err == ErrNotFound {
+			return int64(-2)
+		} else if err != nil {
+			return storeError(err)
+		} else if ttl == 0 {
+			return int64(-1)
+		}
+		return int64(ttl / time.Millisecond)
+	case cmd == "PEXPIRE" && argc == 2:
+		ms, err := strconv.ParseInt(string(args[2]), 10, 64)
+		if err != nil || ms <= 0 {
+			return respError("ERR invalid expire time in pexpire")
+		}
+		ok, err := srv.Store.Expire(string(args[1]), time.Duration(ms)*time.Millisecond)
+		if err != nil {
+			return storeError(err)
+		} else if !ok {
+			return int64(0)
+		}
+		return int64(1)
+	// Replies of SADD and SREM are the numbers of given members rather than of the
+	// ones actually added or removed, which RedisStore doesn't use
+	case cmd == "SADD" && argc >= 2:
+		if err := srv.Store.SAdd(string(args[1]), stringArgs(args[2:])); err != nil {
+			return storeError(err)
+		}
+		return int64(argc - 1)
+	case cmd == "SREM" && argc >= 2:
+		if err := srv.Store.SRem(string(args[1]), stringArgs(args[2:])); err != nil {
+			return storeError(err)
+		}
+		return int64(argc - 1)
+	case cmd == "SMEMBERS" && argc == 1:
+		members, err := srv.Store.SMembers(string(args[1]))
+		if err != nil {
+			return storeError(err)
+		}
+		values := make([][]byte, len(members))
+		for i, m := range members {
+			values[i] = []byte(m)
+		}
+		return values
+	case cmd == "PING", cmd == "QUIT", cmd == "AUTH", cmd == "SELECT",
+		cmd == "GET", cmd == "MGET", cmd == "SET", cmd == "DEL", cmd == "PTTL",
+		cmd == "PEXPIRE", cmd == "SADD", cmd == "SREM", cmd == "SMEMBERS":
+		return respError(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(cmd)))
+	}
+	return respError(fmt.Sprintf("ERR unknown command '%s'", args[0]))
+}
+
+// set runs "SET key value [EX seconds|PX milliseconds] [XX]"
+func (srv *Server) set(args [][]byte) interface{} {
+	var (
+		key       = string(args[0])
+		value     = args[1]
+		ttl       time.Duration
+		xx        bool
+		syntaxErr = respError("ERR syntax error")
+	)
+	for i := 2; i < len(args); i++ {
+		switch opt := strings.ToUpper(string(args[i])); opt {
+		case "XX":
+			xx = true
+		case "EX", "PX":
+			if i+1 == len(args) {
+				return syntaxErr
+			}
+			i++
+			n, err := strconv.ParseInt(string(args[i]), 10, 64)
+			if err != nil || n <= 0 {
+				return respError("ERR invalid expire time in set")
+			}
+			if opt == "EX" {
+				ttl = time.Duration(n) * time.Second
+			} else {
+				ttl = time.Duration(n) * time.Millisecond
+			}
+		default:
+			return syntaxErr
+		}
+	}
+	if xx {
+		ok, err := srv.Store.Replace(key, value, ttl)
+		if err != nil {
+			return storeError(err)
+		}
+		if !ok {
+			return []byte(nil)
+		}
+		return "OK"
+	}
+	if err := srv.Store.Set(key, value, ttl); err != nil {
+		return storeError(err)
+	}
+	return "OK"
+}
+
+func stringArgs(args [][]byte) []string {
+	s := make([]string, len(args))
+	for i, arg := range args {
+		s[i] = string(arg)
+	}
+	return s
+}
+
+func storeError(err error) respError {
+	if err == ErrWrongType {
+		return respError("WRONGTYPE Operation against a key holding the wrong kind of value")
+	}
+	return respError("ERR " + err.Error())
+}
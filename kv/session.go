@@ -0,0 +1,424 @@
+package kv
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+)
+
+const (
+	// sessionKeyPrefix prefixes keys of sessions (followed by session ID)
+	sessionKeyPrefix = "idp:session:"
+	// lookupKeyPrefix prefixes keys that map a user, a domain, a user agent and
+	// a remote address to a session ID
+	lookupKeyPrefix = "idp:session-lookup:"
+	// referenceKeyPrefix prefixes keys that map a session reference to a session ID
+	referenceKeyPrefix = "idp:session-reference:"
+	// userIndexPrefix prefixes sets of IDs of a user's sessions (followed by user ID)
+	userIndexPrefix = "idp:session-user:"
+	// domainIndexPrefix prefixes sets of IDs of sessions in a domain (followed by
+	// domain ID)
+	domainIndexPrefix = "idp:session-domain:"
+	// allIndexKey is a key of the set of IDs of all sessions
+	allIndexKey = "idp:sessions"
+)
+
+// sessionRecord is a stored session. Domain and user attributes are copied on
+// creation so a lookup doesn't need the database.
+type sessionRecord struct {
+	ID            string    `json:"id"`
+	Reference     string    `json:"reference"`
+	DomainID      string    `json:"domain_id"`
+	DomainName    string    `json:"domain_name"`
+	DomainEnabled bool      `json:"domain_enabled"`
+	UserID        string    `json:"user_id"`
+	UserName      string    `json:"user_name"`
+	UserEnabled   bool      `json:"user_enabled"`
+	UserAgent     string    `json:"user_agent"`
+	RemoteAddr    string    `json:"remote_addr"`
+	CreatedOn     time.Time `json:"created_on"`
+	UpdatedOn     time.Time `json:"updated_on"`
+	ExpiresOn     time.Time `json:"expires_on"`
+}
+
+//
+// SessionRepository is an implementation of usecases.SessionRepository on top
+// of a key/value store. Sessions expire by the store's TTL, so DeleteExpired
+// does nothing. IDs of sessions are kept in index sets of their users, their
+// domains and of all sessions, which live as long as the longest living session
+// in them. IDs of expired sessions are removed from an index once it's read.
+//
+type SessionRepository struct {
+	Store Store
+}
+
+// Create stores a new session of a user in a domain
+func (repo *SessionRepository) Create(session entities.Session) error {
+	r := &sessionRecord{
+		ID:            session.ID,
+		Reference:     session.Reference,
+		DomainID:      session.Domain.ID,
+		DomainName:    session.Domain.Name,
+		DomainEnabled: session.Domain.Enabled,
+		UserID:        session.User.ID,
+		UserName:      session.User.Name,
+		UserEnabled:   session.User.Enabled,
+		UserAgent:     session.UserAgent,
+		RemoteAddr:    session.RemoteAddr,
+		CreatedOn:     session.CreatedOn.Time,
+		UpdatedOn:     session.UpdatedOn.Time,
+		ExpiresOn:     session.ExpiresOn.Time,
+	}
+
+	if err := repo.put(r, false); err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to create a session", err)
+	}
+	return nil
+}
+
+// Retain updates session's modification and expiration date/time. A session
+// which doesn't exist (anymore) is left alone.
+func (repo *SessionRepository) Retain(id string, updatedOn, expiresOn time.Time) error {
+	r, err := repo.find(id)
+	if err != nil {
+		if e, ok := err.(*errs.Error); ok && e.Type == errs.ErrorTypeNotFound {
+			return nil
+		}
+		return err
+	}
+	r.UpdatedOn = updatedOn
+	r.ExpiresOn = expiresOn
+
+	if err = repo.put(r, true); err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to retain a session", err)
+	}
+	return nil
+}
+
+// Delete deletes a session by ID
+func (repo *SessionRepository) Delete(id string) error {
+	r, err := repo.find(id)
+	if err != nil {
+		return err
+	}
+	if _, err = repo.Store.Del(recordKeys(r)); err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to delete session", err)
+	}
+	if err = repo.unindex([]*sessionRecord{r}); err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to delete session", err)
+	}
+	return nil
+}
+
+// DeleteExpired does nothing as the store expires sessions itself
+func (repo *SessionRepository) DeleteExpired(now time.Time) error {
+	return nil
+}
+
+// DeleteByUser deletes all sessions of a user
+func (repo *SessionRepository) DeleteByUser(userID string) error {
+	return repo.deleteIndexed(userIndexPrefix + userID)
+}
+
+// DeleteByDomain deletes all sessions in a domain
+func (repo *SessionRepository) DeleteByDomain(domainID string) error {
+	return repo.deleteIndexed(domainIndexPrefix + domainID)
+}
+
+// FindByID finds a session by ID
+func (repo *SessionRepository) FindByID(id string) (*entities.Session, error) {
+	r, err := repo.find(id)
+	if err != nil {
+		return nil, err
+	}
+	return sessionToEntity(r), nil
+}
+
+// FindByReference finds a session by reference
+func (repo *SessionRepository) FindByReference(reference string) (*entities.Session, error) {
+	if reference == "" {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "Session not found by given ID", nil)
+	}
+	return repo.findIndirect(referenceKey(reference))
+}
+
+// FindUserSpecific finds a session of a user in a domain opened with a given
+// user agent from a given remote address
+func (repo *SessionRepository) FindUserSpecific(userID, domainID, userAgent, remoteAddr string) (*entities.Session, error) {
+	key := lookupKey(&sessionRecord{
+		UserID:     userID,
+		DomainID:   domainID,
+		UserAgent:  userAgent,
+		RemoteAddr: remoteAddr,
+	})
+	return repo.findIndirect(key)
+}
+
+// List returns a page of sessions along with the total number of sessions. All
+// sessions are loaded to be sorted, which is fine for administrative listings.
+func (repo *SessionRepository) List(pager entities.Pager, sorter entities.Sorter) ([]entities.Session, int64, error) {
+	records, err := repo.indexed(allIndexKey)
+	if err != nil {
+		return nil, 0, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to perform a lookup of sessions", err)
+	}
+	sort.Sort(sessionsByColumn{records, sorter})
+
+	total := int64(len(records))
+	if pager.PerPage > 0 {
+		offset := pager.Offset()
+		if offset < 0 || offset >= total {
+			records = nil
+		} else {
+			end := offset + int64(pager.PerPage)
+			if end > total {
+				end = total
+			}
+			records = records[offset:end]
+		}
+	}
+
+	sessions := []entities.Session{}
+	for _, r := range records {
+		sessions = append(sessions, *sessionToEntity(r))
+	}
+	return sessions, total, nil
+}
+
+// find loads a session record by ID. Sessions stored before they had references are
+// ended, since tokens can't refer to them.
+func (repo *SessionRepository) find(id string) (*sessionRecord, error) {
+	b, err := repo.Store.Get(sessionKey(id))
+	if err == ErrNotFound {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "Session not found by given ID", err)
+	} else if err != nil {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to perform a lookup of a session", err)
+	}
+	var r sessionRecord
+	if err = json.Unmarshal(b, &r); err != nil {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to decode a session", err)
+	}
+	if r.Reference == "" {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "Session not found by given ID", nil)
+	}
+	return &r, nil
+}
+
+// findIndirect finds a session by a key holding its ID
+func (repo *SessionRepository) findIndirect(key string) (*entities.Session, error) {
+	id, err := repo.Store.Get(key)
+	if err == ErrNotFound {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "Session not found by given ID", err)
+	} else if err != nil {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to perform a lookup of a session", err)
+	}
+	return repo.FindByID(string(id))
+}
+
+// put stores a session record and its lookup and reference keys until the session expires and
+// indexes it. An existing session is only replaced, so a deleted one is not brought
+// back.
+func (repo *SessionRepository) put(r *sessionRecord, replace bool) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	ttl := r.ExpiresOn.Sub(time.Now())
+	if ttl < time.Millisecond {
+		ttl = time.Millisecond
+	}
+
+	if replace {
+		ok, err := repo.Store.Replace(sessionKey(r.ID), b, ttl)
+		if err != nil || !ok {
+			return err
+		}
+	} else if err = repo.Store.Set(sessionKey(r.ID), b, ttl); err != nil {
+		return err
+	}
+	if err = repo.Store.Set(lookupKey(r), []byte(r.ID), ttl); err != nil {
+		return err
+	}
+	if err = repo.Store.Set(referenceKey(r.Reference), []byte(r.ID), ttl); err != nil {
+		return err
+	}
+	return repo.index(r, ttl)
+}
+
+// index adds a session to its index sets and makes them live at least as long as
+// the session
+func (repo *SessionRepository) index(r *sessionRecord, ttl time.Duration) error {
+	for _, key := range indexKeys(r) {
+		if err := repo.Store.SAdd(key, []string{r.ID}); err != nil {
+			return err
+		}
+		left, err := repo.Store.TTL(key)
+		if err == ErrNotFound || (err == nil && left >= ttl) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if _, err = repo.Store.Expire(key, ttl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unindex removes given sessions from their index sets
+func (repo *SessionRepository) unindex(records []*sessionRecord) error {
+	ids := map[string][]string{}
+	for _, r := range records {
+		for _, key := range indexKeys(r) {
+			ids[key] = append(ids[key], r.ID)
+		}
+	}
+	for key, members := range ids {
+		if err := repo.Store.SRem(key, members); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexed loads all sessions of an index set and removes IDs of the expired ones
+// from it
+func (repo *SessionRepository) indexed(key string) ([]*sessionRecord, error) {
+	ids, err := repo.Store.SMembers(key)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = sessionKey(id)
+	}
+	values, err := repo.Store.MGet(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	records := []*sessionRecord{}
+	expired := []string{}
+	for i, b := range values {
+		if b == nil {
+			expired = append(expired, ids[i])
+			continue
+		}
+		var r sessionRecord
+		if err = json.Unmarshal(b, &r); err != nil {
+			return nil, err
+		}
+		records = append(records, &r)
+	}
+	if err = repo.Store.SRem(key, expired); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// deleteIndexed deletes all sessions of an index set
+func (repo *SessionRepository) deleteIndexed(key string) error {
+	records, err := repo.indexed(key)
+	if err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to perform a lookup of sessions", err)
+	}
+	keys := []string{}
+	for _, r := range records {
+		keys = append(keys, recordKeys(r)...)
+	}
+	if _, err = repo.Store.Del(keys); err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to delete sessions", err)
+	}
+	if err = repo.unindex(records); err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to delete sessions", err)
+	}
+	return nil
+}
+
+func sessionKey(id string) string {
+	return sessionKeyPrefix + id
+}
+
+// recordKeys returns keys of a session record and of its lookup and reference
+func recordKeys(r *sessionRecord) []string {
+	return []string{sessionKey(r.ID), lookupKey(r), referenceKey(r.Reference)}
+}
+
+func referenceKey(reference string) string {
+	return referenceKeyPrefix + reference
+}
+
+// indexKeys returns keys of the index sets of a session
+func indexKeys(r *sessionRecord) []string {
+	return []string{userIndexPrefix + r.UserID, domainIndexPrefix + r.DomainID, allIndexKey}
+}
+
+// lookupKey returns a key of a session ID by the session's user, domain, user agent
+// and remote address. They are hashed as user agents may be long.
+func lookupKey(r *sessionRecord) string {
+	h := sha1.New()
+	for _, s := range []string{r.UserID, r.DomainID, r.UserAgent, r.RemoteAddr} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return lookupKeyPrefix + hex.EncodeToString(h.Sum(nil))
+}
+
+func sessionToEntity(r *sessionRecord) *entities.Session {
+	e := &entities.Session{
+		ID:        r.ID,
+		Reference: r.Reference,
+		Domain: &entities.BasicDomain{
+			ID:      r.DomainID,
+			Name:    r.DomainName,
+			Enabled: r.DomainEnabled,
+		},
+		User: &entities.BasicUser{
+			ID:      r.UserID,
+			Name:    r.UserName,
+			Enabled: r.UserEnabled,
+		},
+		UserAgent:  r.UserAgent,
+		RemoteAddr: r.RemoteAddr,
+	}
+	e.CreatedOn.Time = r.CreatedOn
+	e.UpdatedOn.Time = r.UpdatedOn
+	e.ExpiresOn.Time = r.ExpiresOn
+	return e
+}
+
+// sessionsByColumn sorts sessions by a column of the session table (by creation
+// date/time if it's not set)
+type sessionsByColumn struct {
+	records []*sessionRecord
+	sorter  entities.Sorter
+}
+
+func (b sessionsByColumn) Len() int      { return len(b.records) }
+func (b sessionsByColumn) Swap(i, j int) { b.records[i], b.records[j] = b.records[j], b.records[i] }
+func (b sessionsByColumn) Less(i, j int) bool {
+	x, y := b.records[i], b.records[j]
+	var less, greater bool
+	switch b.sorter.Field {
+	case "session_id":
+		less, greater = x.ID < y.ID, x.ID > y.ID
+	case "user_agent":
+		less, greater = x.UserAgent < y.UserAgent, x.UserAgent > y.UserAgent
+	case "remote_addr":
+		less, greater = x.RemoteAddr < y.RemoteAddr, x.RemoteAddr > y.RemoteAddr
+	case "updated_on":
+		less, greater = x.UpdatedOn.Before(y.UpdatedOn), x.UpdatedOn.After(y.UpdatedOn)
+	case "expires_on":
+		less, greater = x.ExpiresOn.Before(y.ExpiresOn), x.ExpiresOn.After(y.ExpiresOn)
+	default:
+		less, greater = x.CreatedOn.Before(y.CreatedOn), x.CreatedOn.After(y.CreatedOn)
+	}
+	if less || greater {
+		return less == (b.sorter.Asc || b.sorter.Field == "")
+	}
+	return x.ID < y.ID
+}
@@ -0,0 +1,170 @@
+package kv
+
+import (
+	"testing"
+	"time"
+
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+)
+
+func newTestSession(userID, domainID, userAgent string) entities.Session {
+	u := entities.BasicUser{ID: userID, Name: "user-" + userID, Enabled: true}
+	d := entities.BasicDomain{ID: domainID, Name: "domain-" + domainID, Enabled: true}
+	return *entities.NewSession(u, d, userAgent, "127.0.0.1")
+}
+
+func isNotFound(err error) bool {
+	e, ok := err.(*errs.Error)
+	return ok && e.Type == errs.ErrorTypeNotFound
+}
+
+func TestSessionRepository(t *testing.T) {
+	testStores(t, func(t *testing.T, s Store) {
+		repo := &SessionRepository{Store: s}
+		session := newTestSession("u1", "d1", "agent")
+		must(t, repo.Create(session))
+
+		found, err := repo.FindByID(session.ID)
+		must(t, err)
+		if found.User.Name != "user-u1" || found.Domain.Name != "domain-d1" || found.UserAgent != "agent" {
+			t.Errorf("FindByID = %+v", found)
+		}
+		found, err = repo.FindUserSpecific("u1", "d1", "agent", "127.0.0.1")
+		if err != nil || found.ID != session.ID {
+			t.Errorf("FindUserSpecific = %+v, %v", found, err)
+		}
+		if _, err = repo.FindUserSpecific("u1", "d1", "other agent", "127.0.0.1"); !isNotFound(err) {
+			t.Errorf("FindUserSpecific of another agent: %v", err)
+		}
+		if _, err = repo.FindByID("missing"); !isNotFound(err) {
+			t.Errorf("FindByID of a missing session: %v", err)
+		}
+		found, err = repo.FindByReference(session.Reference)
+		if err != nil || found.ID != session.ID || found.Reference != session.Reference {
+			t.Errorf("FindByReference = %+v, %v", found, err)
+		}
+		if _, err = repo.FindByReference(session.ID); !isNotFound(err) {
+			t.Errorf("FindByReference of a session ID: %v", err)
+		}
+
+		updatedOn := time.Now().UTC().Add(time.Second)
+		must(t, repo.Retain(session.ID, updatedOn, updatedOn.Add(time.Hour)))
+		found, err = repo.FindByID(session.ID)
+		must(t, err)
+		if !found.UpdatedOn.Time.Equal(updatedOn) || !found.ExpiresOn.Time.Equal(updatedOn.Add(time.Hour)) {
+			t.Errorf("FindByID after Retain = %+v", found)
+		}
+
+		must(t, repo.Delete(session.ID))
+		if _, err = repo.FindByID(session.ID); !isNotFound(err) {
+			t.Errorf("FindByID of a deleted session: %v", err)
+		}
+		if _, err = repo.FindUserSpecific("u1", "d1", "agent", "127.0.0.1"); !isNotFound(err) {
+			t.Errorf("FindUserSpecific of a deleted session: %v", err)
+		}
+		if _, err = repo.FindByReference(session.Reference); !isNotFound(err) {
+			t.Errorf("FindByReference of a deleted session: %v", err)
+		}
+		must(t, repo.Retain(session.ID, updatedOn, updatedOn.Add(time.Hour)))
+		if _, err = repo.FindByID(session.ID); !isNotFound(err) {
+			t.Errorf("Retain brought a deleted session back: %v", err)
+		}
+		if err = repo.Delete(session.ID); !isNotFound(err) {
+			t.Errorf("Delete of a deleted session: %v", err)
+		}
+		for _, key := range indexKeys(&sessionRecord{UserID: "u1", DomainID: "d1"}) {
+			if ids, _ := s.SMembers(key); len(ids) != 0 {
+				t.Errorf("Index %v keeps a deleted session: %v", key, ids)
+			}
+		}
+	})
+}
+
+func TestSessionRepositoryExpiration(t *testing.T) {
+	testStores(t, func(t *testing.T, s Store) {
+		repo := &SessionRepository{Store: s}
+		short := newTestSession("u1", "d1", "agent")
+		long := newTestSession("u1", "d1", "other agent")
+		must(t, repo.Create(short))
+		must(t, repo.Create(long))
+		now := time.Now().UTC()
+		must(t, repo.Retain(short.ID, now, now.Add(50*time.Millisecond)))
+
+		time.Sleep(100 * time.Millisecond)
+		if _, err := repo.FindByID(short.ID); !isNotFound(err) {
+			t.Errorf("FindByID of an expired session: %v", err)
+		}
+		if _, err := repo.FindUserSpecific("u1", "d1", "agent", "127.0.0.1"); !isNotFound(err) {
+			t.Errorf("FindUserSpecific of an expired session: %v", err)
+		}
+		sessions, total, err := repo.List(entities.Pager{}, entities.Sorter{})
+		if err != nil || total != 1 || sessions[0].ID != long.ID {
+			t.Errorf("List = %+v, %v, %v", sessions, total, err)
+		}
+		if ids, _ := s.SMembers(userIndexPrefix + "u1"); len(ids) != 2 {
+			t.Errorf("User index = %v, want both sessions before it's read", ids)
+		}
+		if ids, _ := s.SMembers(allIndexKey); len(ids) != 1 || ids[0] != long.ID {
+			t.Errorf("Index of all sessions = %v, want the expired session removed", ids)
+		}
+		for _, key := range indexKeys(&sessionRecord{UserID: "u1", DomainID: "d1"}) {
+			if ttl, err := s.TTL(key); err != nil || ttl < long.ExpiresOn.Sub(time.Now())-time.Second {
+				t.Errorf("TTL of index %v = %v, %v, want the TTL of the longest living session", key, ttl, err)
+			}
+		}
+	})
+}
+
+func TestSessionRepositoryDeleteIndexed(t *testing.T) {
+	testStores(t, func(t *testing.T, s Store) {
+		repo := &SessionRepository{Store: s}
+		sessions := []entities.Session{
+			newTestSession("u1", "d1", "agent"),
+			newTestSession("u1", "d2", "agent"),
+			newTestSession("u2", "d1", "agent"),
+			newTestSession("u2", "d2", "agent"),
+			newTestSession("u3", "d2", "agent"),
+		}
+		for _, session := range sessions {
+			must(t, repo.Create(session))
+		}
+
+		must(t, repo.DeleteByUser("u1"))
+		must(t, repo.DeleteByDomain("d1"))
+		must(t, repo.DeleteByUser("missing"))
+
+		for i, session := range sessions {
+			_, err := repo.FindByID(session.ID)
+			if deleted := i < 3; deleted != isNotFound(err) {
+				t.Errorf("FindByID of session %v of %v in %v: %v", i, session.User.ID, session.Domain.ID, err)
+			}
+		}
+		if _, err := repo.FindUserSpecific("u1", "d2", "agent", "127.0.0.1"); !isNotFound(err) {
+			t.Errorf("FindUserSpecific of a deleted session: %v", err)
+		}
+
+		page, total, err := repo.List(entities.Pager{Page: 2, PerPage: 1}, entities.Sorter{Field: "session_id", Asc: true})
+		if err != nil || total != 2 || len(page) != 1 {
+			t.Fatalf("List = %+v, %v, %v", page, total, err)
+		}
+		want := sessions[3].ID
+		if sessions[4].ID > want {
+			want = sessions[4].ID
+		}
+		if page[0].ID != want {
+			t.Errorf("Second page = %v, want %v", page[0].ID, want)
+		}
+		for key, want := range map[string]int{
+			userIndexPrefix + "u1":   0,
+			userIndexPrefix + "u2":   1,
+			domainIndexPrefix + "d1": 0,
+			domainIndexPrefix + "d2": 2,
+			allIndexKey:              2,
+		} {
+			if ids, _ := s.SMembers(key); len(ids) != want {
+				t.Errorf("Index %v = %v, want %v sessions", key, ids, want)
+			}
+		}
+	})
+}
@@ -0,0 +1,59 @@
+package kv
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when a key doesn't exist or has already expired
+	ErrNotFound = errors.New("Key not found")
+	// ErrWrongType is returned when a value is read as a set or the other way round
+	ErrWrongType = errors.New("Key holds a value of another type")
+
+	errInvalidTTL = errors.New("TTL must be positive")
+)
+
+//
+// Store is a key/value storage with native expiration of keys. A zero TTL
+// means that a key never expires. Besides plain values keys can hold sets of
+// strings, which are used as indexes.
+//
+type Store interface {
+	// Ping checks if the store is reachable
+	Ping() error
+	// Get returns a value of a key or ErrNotFound
+	Get(key string) ([]byte, error)
+	// MGet returns values of given keys, nil for the missing ones
+	MGet(keys []string) ([][]byte, error)
+	// Set sets a value of a key
+	Set(key string, value []byte, ttl time.Duration) error
+	// Replace sets a value of a key only if the key exists and tells if it did
+	Replace(key string, value []byte, ttl time.Duration) (bool, error)
+	// Del deletes given keys and returns a number of the deleted ones
+	Del(keys []string) (int, error)
+	// TTL returns the time a key has left to live (0 if it never expires) or ErrNotFound
+	TTL(key string) (time.Duration, error)
+	// Expire sets a positive TTL of a key and tells if the key exists
+	Expire(key string, ttl time.Duration) (bool, error)
+	// SAdd adds members to a set, the set is created if it doesn't exist
+	SAdd(key string, members []string) error
+	// SRem removes members from a set, the set is deleted once it's empty
+	SRem(key string, members []string) error
+	// SMembers returns all members of a set, none if it doesn't exist
+	SMembers(key string) ([]string, error)
+}
+
+// Open opens a store by a given URL: "memory" for an in-process store or
+// "redis://[:password@]host[:port][/db]" for a Redis-compatible server
+func Open(rawurl string) (Store, error) {
+	switch {
+	case rawurl == "memory":
+		return NewMemoryStore(), nil
+	case strings.HasPrefix(rawurl, "redis://"):
+		return NewRedisStore(rawurl)
+	}
+	return nil, fmt.Errorf("Unsupported key/value store %q", rawurl)
+}
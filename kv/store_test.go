@@ -0,0 +1,149 @@
+package kv
+
+import (
+	"net"
+	"sort"
+	"testing"
+	"time"
+)
+
+// testStores runs a test against an in-process store and against a RedisStore
+// talking to a Server on a loopback listener
+func testStores(t *testing.T, test func(t *testing.T, s Store)) {
+	t.Run("memory", func(t *testing.T) {
+		test(t, NewMemoryStore())
+	})
+	t.Run("redis", func(t *testing.T) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv := &Server{Store: NewMemoryStore()}
+		go srv.Serve(l)
+		defer srv.Close()
+
+		s, err := NewRedisStore("redis://:secret@" + l.Addr().String() + "/1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = s.Ping(); err != nil {
+			t.Fatal(err)
+		}
+		test(t, s)
+	})
+}
+
+func TestStoreValues(t *testing.T) {
+	testStores(t, func(t *testing.T, s Store) {
+		if _, err := s.Get("a"); err != ErrNotFound {
+			t.Errorf("Get of a missing key: %v", err)
+		}
+		must(t, s.Set("a", []byte("1"), 0))
+		must(t, s.Set("b", []byte("2"), time.Minute))
+		if v, err := s.Get("a"); err != nil || string(v) != "1" {
+			t.Errorf("Get = %q, %v", v, err)
+		}
+
+		if ok, err := s.Replace("c", []byte("3"), 0); ok || err != nil {
+			t.Errorf("Replace of a missing key = %v, %v", ok, err)
+		}
+		if ok, err := s.Replace("a", []byte("4"), 0); !ok || err != nil {
+			t.Errorf("Replace = %v, %v", ok, err)
+		}
+		values, err := s.MGet([]string{"a", "c", "b"})
+		if err != nil || len(values) != 3 || string(values[0]) != "4" || values[1] != nil || string(values[2]) != "2" {
+			t.Errorf("MGet = %q, %v", values, err)
+		}
+
+		if n, err := s.Del([]string{"a", "c"}); n != 1 || err != nil {
+			t.Errorf("Del = %v, %v", n, err)
+		}
+		if _, err := s.Get("a"); err != ErrNotFound {
+			t.Errorf("Get of a deleted key: %v", err)
+		}
+	})
+}
+
+func TestStoreExpiration(t *testing.T) {
+	testStores(t, func(t *testing.T, s Store) {
+		must(t, s.Set("a", []byte("1"), 50*time.Millisecond))
+		must(t, s.Set("b", []byte("2"), 0))
+		if ttl, err := s.TTL("a"); err != nil || ttl <= 0 || ttl > 50*time.Millisecond {
+			t.Errorf("TTL = %v, %v", ttl, err)
+		}
+		if ttl, err := s.TTL("b"); err != nil || ttl != 0 {
+			t.Errorf("TTL of a persistent key = %v, %v", ttl, err)
+		}
+		if ok, err := s.Expire("b", 50*time.Millisecond); !ok || err != nil {
+			t.Errorf("Expire = %v, %v", ok, err)
+		}
+		if ok, err := s.Expire("c", time.Minute); ok || err != nil {
+			t.Errorf("Expire of a missing key = %v, %v", ok, err)
+		}
+		if _, err := s.Expire("a", 0); err == nil {
+			t.Error("Expire with a zero TTL succeeded")
+		}
+
+		time.Sleep(100 * time.Millisecond)
+		for _, key := range []string{"a", "b"} {
+			if _, err := s.Get(key); err != ErrNotFound {
+				t.Errorf("Get of an expired key %v: %v", key, err)
+			}
+			if _, err := s.TTL(key); err != ErrNotFound {
+				t.Errorf("TTL of an expired key %v: %v", key, err)
+			}
+		}
+		if ok, err := s.Replace("a", []byte("1"), 0); ok || err != nil {
+			t.Errorf("Replace of an expired key = %v, %v", ok, err)
+		}
+	})
+}
+
+func TestStoreSets(t *testing.T) {
+	testStores(t, func(t *testing.T, s Store) {
+		if members, err := s.SMembers("set"); err != nil || len(members) != 0 {
+			t.Errorf("SMembers of a missing set = %v, %v", members, err)
+		}
+		must(t, s.SAdd("set", []string{"a", "b"}))
+		must(t, s.SAdd("set", []string{"b", "c"}))
+		must(t, s.SRem("set", []string{"a", "x"}))
+		members, err := s.SMembers("set")
+		sort.Strings(members)
+		if err != nil || len(members) != 2 || members[0] != "b" || members[1] != "c" {
+			t.Errorf("SMembers = %v, %v", members, err)
+		}
+
+		if ok, err := s.Expire("set", 50*time.Millisecond); !ok || err != nil {
+			t.Errorf("Expire of a set = %v, %v", ok, err)
+		}
+		must(t, s.SAdd("set", []string{"d"}))
+		if ttl, err := s.TTL("set"); err != nil || ttl <= 0 {
+			t.Errorf("TTL of a set after SAdd = %v, %v", ttl, err)
+		}
+		time.Sleep(100 * time.Millisecond)
+		if members, err := s.SMembers("set"); err != nil || len(members) != 0 {
+			t.Errorf("SMembers of an expired set = %v, %v", members, err)
+		}
+
+		must(t, s.SAdd("set", []string{"a"}))
+		must(t, s.SRem("set", []string{"a"}))
+		if _, err := s.TTL("set"); err != ErrNotFound {
+			t.Errorf("TTL of an emptied set: %v", err)
+		}
+
+		must(t, s.Set("value", []byte("1"), 0))
+		if err := s.SAdd("value", []string{"a"}); err == nil {
+			t.Error("SAdd to a value succeeded")
+		}
+		must(t, s.SAdd("set", []string{"a"}))
+		if _, err := s.Get("set"); err == nil || err == ErrNotFound {
+			t.Errorf("Get of a set: %v", err)
+		}
+	})
+}
+
+func must(t *testing.T, err error) {
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,265 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+)
+
+// clientRecord is a stored client
+type clientRecord struct {
+	seq    int64
+	client entities.Client
+}
+
+//
+// ClientRepository is an in-memory implementation of usecases.ClientRepository
+//
+type ClientRepository struct {
+	Store *Store
+}
+
+// Create adds a new client
+func (repo *ClientRepository) Create(client entities.Client) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.findDomain(client.DomainID); err != nil {
+		return err
+	}
+	if _, ok := s.clients[client.ID]; ok {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to create a client",
+			fmt.Errorf("Client ID %v is already taken", client.ID))
+	}
+	client.GrantTypes = append([]string{}, client.GrantTypes...)
+	client.Scopes = append([]string{}, client.Scopes...)
+	client.RedirectURIs = append([]string{}, client.RedirectURIs...)
+	s.clients[client.ID] = &clientRecord{
+		seq:    s.next(),
+		client: client,
+	}
+	return nil
+}
+
+// Delete deletes a client along with its refresh tokens and authorization codes
+func (repo *ClientRepository) Delete(id string) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.findClient(id); err != nil {
+		return err
+	}
+	s.deleteClient(id)
+	return nil
+}
+
+// FindByID finds a client by ID
+func (repo *ClientRepository) FindByID(id string) (*entities.Client, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	r, err := s.findClient(id)
+	if err != nil {
+		return nil, err
+	}
+	return r.copy(), nil
+}
+
+// List lists clients of a domain (all clients if the domain ID is empty) in creation order
+func (repo *ClientRepository) List(domainID string) ([]entities.Client, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	records := []*clientRecord{}
+	for _, r := range s.clients {
+		if domainID == "" || r.client.DomainID == domainID {
+			records = append(records, r)
+		}
+	}
+	sort.Sort(clientsBySeq(records))
+
+	clients := []entities.Client{}
+	for _, r := range records {
+		clients = append(clients, *r.copy())
+	}
+	return clients, nil
+}
+
+// CreateRefreshToken adds a new refresh token of a client
+func (repo *ClientRepository) CreateRefreshToken(token entities.RefreshToken) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.findClient(token.ClientID); err != nil {
+		return err
+	}
+	if _, ok := s.refreshTokens[token.Hash]; ok {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to create a refresh token",
+			fmt.Errorf("Refresh token is already taken"))
+	}
+	token.Scopes = append([]string{}, token.Scopes...)
+	s.refreshTokens[token.Hash] = &token
+	return nil
+}
+
+// RetainRefreshToken updates refresh token's expiration date/time
+func (repo *ClientRepository) RetainRefreshToken(hash string, expiresOn time.Time) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t, ok := s.refreshTokens[hash]; ok {
+		t.ExpiresOn.Time = expiresOn
+	}
+	return nil
+}
+
+// DeleteRefreshToken deletes a refresh token by hash
+func (repo *ClientRepository) DeleteRefreshToken(hash string) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.refreshTokens, hash)
+	return nil
+}
+
+// DeleteExpiredRefreshTokens deletes all refresh tokens expired by a given time
+func (repo *ClientRepository) DeleteExpiredRefreshTokens(now time.Time) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, t := range s.refreshTokens {
+		if !t.ExpiresOn.After(now) {
+			delete(s.refreshTokens, hash)
+		}
+	}
+	return nil
+}
+
+// FindRefreshToken finds a refresh token by hash
+func (repo *ClientRepository) FindRefreshToken(hash string) (*entities.RefreshToken, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	t, ok := s.refreshTokens[hash]
+	if !ok {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "Refresh token not found", nil)
+	}
+	c := *t
+	c.Scopes = append([]string{}, t.Scopes...)
+	return &c, nil
+}
+
+// ListRefreshTokens lists all refresh tokens of a client
+func (repo *ClientRepository) ListRefreshTokens(clientID string) ([]entities.RefreshToken, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tokens := []entities.RefreshToken{}
+	for _, t := range s.refreshTokens {
+		if t.ClientID == clientID {
+			c := *t
+			c.Scopes = append([]string{}, t.Scopes...)
+			tokens = append(tokens, c)
+		}
+	}
+	return tokens, nil
+}
+
+// CreateAuthorizationCode adds a new authorization code of a client
+func (repo *ClientRepository) CreateAuthorizationCode(code entities.AuthorizationCode) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.findClient(code.ClientID); err != nil {
+		return err
+	}
+	if _, ok := s.codes[code.Hash]; ok {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to create an authorization code",
+			fmt.Errorf("Authorization code is already taken"))
+	}
+	code.Scopes = append([]string{}, code.Scopes...)
+	s.codes[code.Hash] = &code
+	return nil
+}
+
+// TakeAuthorizationCode finds an authorization code by hash and deletes it
+func (repo *ClientRepository) TakeAuthorizationCode(hash string) (*entities.AuthorizationCode, error) {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.codes[hash]
+	if !ok {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "Authorization code not found", nil)
+	}
+	delete(s.codes, hash)
+	return c, nil
+}
+
+// DeleteExpiredAuthorizationCodes deletes all authorization codes expired by a given time
+func (repo *ClientRepository) DeleteExpiredAuthorizationCodes(now time.Time) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, c := range s.codes {
+		if !c.ExpiresOn.After(now) {
+			delete(s.codes, hash)
+		}
+	}
+	return nil
+}
+
+func (s *Store) findClient(id string) (*clientRecord, error) {
+	r, ok := s.clients[id]
+	if !ok {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "Client not found by given ID", nil)
+	}
+	return r, nil
+}
+
+// deleteClient deletes a client along with its refresh tokens and authorization
+// codes. Must be called with the write lock held.
+func (s *Store) deleteClient(id string) {
+	for hash, t := range s.refreshTokens {
+		if t.ClientID == id {
+			delete(s.refreshTokens, hash)
+		}
+	}
+	for hash, c := range s.codes {
+		if c.ClientID == id {
+			delete(s.codes, hash)
+		}
+	}
+	delete(s.clients, id)
+}
+
+// copy returns a copy of the client which doesn't share slices with the store
+func (r *clientRecord) copy() *entities.Client {
+	c := r.client
+	c.GrantTypes = append([]string{}, r.client.GrantTypes...)
+	c.Scopes = append([]string{}, r.client.Scopes...)
+	c.RedirectURIs = append([]string{}, r.client.RedirectURIs...)
+	return &c
+}
+
+// clientsBySeq sorts clients in insertion order
+type clientsBySeq []*clientRecord
+
+func (c clientsBySeq) Len() int           { return len(c) }
+func (c clientsBySeq) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
+func (c clientsBySeq) Less(i, j int) bool { return c[i].seq < c[j].seq }
@@ -0,0 +1,237 @@
+package memory
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+)
+
+// domainRecord is a stored domain
+type domainRecord struct {
+	seq       int64
+	domain    entities.BasicDomain
+	createdOn time.Time
+	updatedOn time.Time
+}
+
+func (r *domainRecord) order() int64 { return r.seq }
+
+func (r *domainRecord) column(name string) interface{} {
+	switch name {
+	case "name":
+		return r.domain.Name
+	case "is_enabled":
+		return r.domain.Enabled
+	case "created_on":
+		return r.createdOn
+	case "updated_on":
+		return r.updatedOn
+	}
+	return nil
+}
+
+//
+// DomainRepository is an in-memory implementation of usecases.DomainRepository
+//
+type DomainRepository struct {
+	Store *Store
+}
+
+// Create adds a new domain
+func (repo *DomainRepository) Create(domain entities.BasicDomain) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkDomainUnique(domain.ID, domain.Name); err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to create a domain", err)
+	}
+	now := time.Now().UTC()
+	s.domains[domain.ID] = &domainRecord{
+		seq:       s.next(),
+		domain:    domain,
+		createdOn: now,
+		updatedOn: now,
+	}
+	return nil
+}
+
+// Update updates all attributes of a domain found by ID
+func (repo *DomainRepository) Update(domain entities.BasicDomain) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, err := s.findDomain(domain.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.checkDomainUnique("", domain.Name); err != nil && r.domain.Name != domain.Name {
+		return errs.NewDataAccessError(errs.ErrorTypeConflict, "Failed to update domain", err)
+	}
+	r.domain = domain
+	r.updatedOn = time.Now().UTC()
+	return nil
+}
+
+// Delete deletes a domain along with its sessions, users' membership, role assignments,
+// OAuth clients, SAML service providers, pending MFA and WebAuthn challenges and its
+// password policy
+func (repo *DomainRepository) Delete(id string) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.findDomain(id); err != nil {
+		return err
+	}
+	for sid, r := range s.sessions {
+		if r.domainID == id {
+			delete(s.sessions, sid)
+		}
+	}
+	for m := range s.memberships {
+		if m.domainID == id {
+			delete(s.memberships, m)
+		}
+	}
+	for a := range s.assignments {
+		if a.domainID == id {
+			delete(s.assignments, a)
+		}
+	}
+	for cid, r := range s.clients {
+		if r.client.DomainID == id {
+			s.deleteClient(cid)
+		}
+	}
+	for spID, r := range s.serviceProviders {
+		if r.sp.DomainID == id {
+			delete(s.serviceProviders, spID)
+		}
+	}
+	for cid, c := range s.challenges {
+		if c.DomainID == id {
+			delete(s.challenges, cid)
+		}
+	}
+	for cid, c := range s.ceremonies {
+		if c.DomainID == id {
+			delete(s.ceremonies, cid)
+		}
+	}
+	delete(s.policies, id)
+	delete(s.domains, id)
+	return nil
+}
+
+// FindByID finds a domain by ID
+func (repo *DomainRepository) FindByID(id string) (*entities.BasicDomain, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	r, err := s.findDomain(id)
+	if err != nil {
+		return nil, err
+	}
+	d := r.domain
+	return &d, nil
+}
+
+// FindByName finds a domain by name
+func (repo *DomainRepository) FindByName(name string) (*entities.BasicDomain, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, r := range s.domains {
+		if r.domain.Name == name {
+			d := r.domain
+			return &d, nil
+		}
+	}
+	return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "Domain not found by given name", nil)
+}
+
+// CountUsers returns number of users in a domain
+func (repo *DomainRepository) CountUsers(id string) (int64, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.countUsers(id), nil
+}
+
+// List returns a page of domains along with the total number of domains
+func (repo *DomainRepository) List(pager entities.Pager, sorter entities.Sorter) ([]entities.Domain, int64, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var records []record
+	for _, r := range s.domains {
+		records = append(records, r)
+	}
+	return s.domainsToEntities(sortAndPage(records, pager, sorter)), int64(len(records)), nil
+}
+
+// ListByUser returns a page of domains of a given user along with the total number
+// of the user's domains
+func (repo *DomainRepository) ListByUser(userID string, pager entities.Pager, sorter entities.Sorter) ([]entities.Domain, int64, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var records []record
+	for m := range s.memberships {
+		if m.userID == userID {
+			records = append(records, s.domains[m.domainID])
+		}
+	}
+	return s.domainsToEntities(sortAndPage(records, pager, sorter)), int64(len(records)), nil
+}
+
+// findDomain finds a domain record by ID
+func (s *Store) findDomain(id string) (*domainRecord, error) {
+	r, ok := s.domains[id]
+	if !ok {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "Domain not found by given ID", nil)
+	}
+	return r, nil
+}
+
+// checkDomainUnique checks if a domain ID and a name are not taken yet
+func (s *Store) checkDomainUnique(id, name string) error {
+	if _, ok := s.domains[id]; ok {
+		return fmt.Errorf("Domain ID %v is already taken", id)
+	}
+	for _, r := range s.domains {
+		if r.domain.Name == name {
+			return fmt.Errorf("Domain name %v is already taken", name)
+		}
+	}
+	return nil
+}
+
+// countUsers returns number of users in a domain
+func (s *Store) countUsers(id string) int64 {
+	var n int64
+	for m := range s.memberships {
+		if m.domainID == id {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *Store) domainsToEntities(records []record) []entities.Domain {
+	domains := []entities.Domain{}
+	for _, r := range records {
+		d := r.(*domainRecord).domain
+		domains = append(domains, entities.Domain{BasicDomain: d, UsersCount: s.countUsers(d.ID)})
+	}
+	return domains
+}
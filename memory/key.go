@@ -0,0 +1,139 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+)
+
+// keyRecord is a stored signing key
+type keyRecord struct {
+	seq        int64
+	key        entities.SigningKey
+	privateKey []byte
+}
+
+//
+// KeyRepository is an in-memory implementation of usecases.KeyRepository
+//
+type KeyRepository struct {
+	Store *Store
+}
+
+// Create adds a new key
+func (repo *KeyRepository) Create(key entities.SigningKey, sealedPrivateKey []byte) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.keys[key.ID]; ok {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to create a key",
+			fmt.Errorf("Key ID %v is already taken", key.ID))
+	}
+	s.keys[key.ID] = &keyRecord{
+		seq:        s.next(),
+		key:        key,
+		privateKey: sealedPrivateKey,
+	}
+	return nil
+}
+
+// Activate makes a key active and retires the previously active one
+func (repo *KeyRepository) Activate(id string, now time.Time) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, err := s.findKey(id)
+	if err != nil {
+		return err
+	}
+	for _, other := range s.keys {
+		if other != r && other.key.Status == entities.SigningKeyActive {
+			other.key.Status = entities.SigningKeyRetired
+			other.key.RetiredOn.Time = now
+		}
+	}
+	r.key.Status = entities.SigningKeyActive
+	r.key.ActivatedOn.Time = now
+	return nil
+}
+
+// Retire retires a key
+func (repo *KeyRepository) Retire(id string, now time.Time) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, err := s.findKey(id)
+	if err != nil {
+		return err
+	}
+	r.key.Status = entities.SigningKeyRetired
+	r.key.RetiredOn.Time = now
+	return nil
+}
+
+// FindByID finds a key by ID
+func (repo *KeyRepository) FindByID(id string) (*entities.SigningKey, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	r, err := s.findKey(id)
+	if err != nil {
+		return nil, err
+	}
+	k := r.key
+	return &k, nil
+}
+
+// FindPrivateKey returns a sealed private key of a key found by ID
+func (repo *KeyRepository) FindPrivateKey(id string) ([]byte, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	r, err := s.findKey(id)
+	if err != nil {
+		return nil, err
+	}
+	return r.privateKey, nil
+}
+
+// List returns all keys in creation order
+func (repo *KeyRepository) List() ([]entities.SigningKey, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	records := []*keyRecord{}
+	for _, r := range s.keys {
+		records = append(records, r)
+	}
+	sort.Sort(keysBySeq(records))
+
+	keys := []entities.SigningKey{}
+	for _, r := range records {
+		keys = append(keys, r.key)
+	}
+	return keys, nil
+}
+
+func (s *Store) findKey(id string) (*keyRecord, error) {
+	r, ok := s.keys[id]
+	if !ok {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "Key not found by given ID", nil)
+	}
+	return r, nil
+}
+
+// keysBySeq sorts keys in insertion order
+type keysBySeq []*keyRecord
+
+func (k keysBySeq) Len() int           { return len(k) }
+func (k keysBySeq) Swap(i, j int)      { k[i], k[j] = k[j], k[i] }
+func (k keysBySeq) Less(i, j int) bool { return k[i].seq < k[j].seq }
@@ -0,0 +1,102 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+)
+
+//
+// LockoutRepository is an in-memory implementation of usecases.LockoutRepository
+//
+type LockoutRepository struct {
+	Store *Store
+}
+
+// Find finds failures of a subject
+func (repo *LockoutRepository) Find(scope, subject string) (*entities.LoginFailures, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	f, ok := s.failures[loginSubject{scope, subject}]
+	if !ok {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "Failures not found by given subject", nil)
+	}
+	ff := *f
+	return &ff, nil
+}
+
+// AddFailure counts a failed attempt of a subject at a given time and returns the
+// number of failures. Failures are forgotten unless there have been failures or
+// blocked attempts since a given time.
+func (repo *LockoutRepository) AddFailure(scope, subject string, now, since time.Time) (int, error) {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := loginSubject{scope, subject}
+	f, ok := s.failures[key]
+	if !ok {
+		f = &entities.LoginFailures{Scope: scope, Subject: subject}
+		f.BlockedUntil.Time = now
+		s.failures[key] = f
+	}
+	if f.LastFailedOn.Before(since) && f.BlockedUntil.Before(since) {
+		f.Failures = 0
+	}
+	f.Failures++
+	f.LastFailedOn.Time = now
+	return f.Failures, nil
+}
+
+// RemoveFailure takes back a failure of a subject counted in advance by AddFailure
+func (repo *LockoutRepository) RemoveFailure(scope, subject string) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if f, ok := s.failures[loginSubject{scope, subject}]; ok && f.Failures > 0 {
+		f.Failures--
+	}
+	return nil
+}
+
+// Block blocks attempts of a subject until a given time unless they're blocked for
+// longer already
+func (repo *LockoutRepository) Block(scope, subject string, until time.Time) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if f, ok := s.failures[loginSubject{scope, subject}]; ok && f.BlockedUntil.Before(until) {
+		f.BlockedUntil.Time = until
+	}
+	return nil
+}
+
+// Delete deletes failures of a subject if there are any
+func (repo *LockoutRepository) Delete(scope, subject string) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.failures, loginSubject{scope, subject})
+	return nil
+}
+
+// DeleteStale deletes failures which have neither failed nor blocked attempts since a
+// given time
+func (repo *LockoutRepository) DeleteStale(since time.Time) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, f := range s.failures {
+		if f.LastFailedOn.Before(since) && f.BlockedUntil.Before(since) {
+			delete(s.failures, key)
+		}
+	}
+	return nil
+}
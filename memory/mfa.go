@@ -0,0 +1,204 @@
+package memory
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+)
+
+// enrollmentRecord is a stored enrollment along with its sealed secret and hashes of
+// unused recovery codes
+type enrollmentRecord struct {
+	enrollment    entities.MFAEnrollment
+	secret        []byte
+	recoveryCodes map[string]bool
+}
+
+//
+// MFARepository is an in-memory implementation of usecases.MFARepository
+//
+type MFARepository struct {
+	Store *Store
+}
+
+// Create adds a new enrollment along with its recovery codes
+func (repo *MFARepository) Create(enrollment entities.MFAEnrollment, sealedSecret []byte, recoveryCodeHashes []string) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.findUser(enrollment.UserID); err != nil {
+		return err
+	}
+	if _, ok := s.enrollments[enrollment.UserID]; ok {
+		return errs.NewDataAccessError(errs.ErrorTypeConflict, "Failed to create an enrollment",
+			fmt.Errorf("User %v is already enrolled", enrollment.UserID))
+	}
+	r := &enrollmentRecord{
+		enrollment:    enrollment,
+		secret:        append([]byte(nil), sealedSecret...),
+		recoveryCodes: map[string]bool{},
+	}
+	for _, hash := range recoveryCodeHashes {
+		r.recoveryCodes[hash] = true
+	}
+	s.enrollments[enrollment.UserID] = r
+	return nil
+}
+
+// Delete deletes an enrollment of a user along with the recovery codes and pending
+// challenges
+func (repo *MFARepository) Delete(userID string) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.findUser(userID); err != nil {
+		return err
+	}
+	if _, ok := s.enrollments[userID]; !ok {
+		return errs.NewDataAccessError(errs.ErrorTypeNotFound, "User is not enrolled", nil)
+	}
+	for id, c := range s.challenges {
+		if c.UserID == userID {
+			delete(s.challenges, id)
+		}
+	}
+	delete(s.enrollments, userID)
+	return nil
+}
+
+// FindByUser finds an enrollment of a user
+func (repo *MFARepository) FindByUser(userID string) (*entities.MFAEnrollment, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	r, ok := s.enrollments[userID]
+	if !ok {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "User is not enrolled", nil)
+	}
+	e := r.enrollment
+	e.RecoveryCodes = len(r.recoveryCodes)
+	return &e, nil
+}
+
+// FindSecret finds a sealed TOTP secret of a user
+func (repo *MFARepository) FindSecret(userID string) ([]byte, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	r, ok := s.enrollments[userID]
+	if !ok {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "User is not enrolled", nil)
+	}
+	return append([]byte(nil), r.secret...), nil
+}
+
+// UseStep records the time step of an accepted one-time password if it's later than
+// the last recorded one
+func (repo *MFARepository) UseStep(userID string, step int64) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.enrollments[userID]
+	if !ok || r.enrollment.LastStep >= step {
+		return errs.NewDataAccessError(errs.ErrorTypeConflict, "One-time password has been used already", nil)
+	}
+	r.enrollment.LastStep = step
+	return nil
+}
+
+// UseRecoveryCode deletes a recovery code of a user found by hash
+func (repo *MFARepository) UseRecoveryCode(userID, hash string) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.enrollments[userID]
+	if !ok || !r.recoveryCodes[hash] {
+		return errs.NewDataAccessError(errs.ErrorTypeNotFound, "Recovery code not found", nil)
+	}
+	delete(r.recoveryCodes, hash)
+	return nil
+}
+
+// CreateChallenge adds a new challenge
+func (repo *MFARepository) CreateChallenge(challenge entities.MFAChallenge) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.findDomain(challenge.DomainID); err != nil {
+		return err
+	}
+	if _, err := s.findUser(challenge.UserID); err != nil {
+		return err
+	}
+	if _, ok := s.challenges[challenge.ID]; ok {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to create a challenge",
+			fmt.Errorf("Challenge ID %v is already taken", challenge.ID))
+	}
+	s.challenges[challenge.ID] = &challenge
+	return nil
+}
+
+// FindChallenge finds a challenge by ID
+func (repo *MFARepository) FindChallenge(id string) (*entities.MFAChallenge, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	c, ok := s.challenges[id]
+	if !ok {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "Challenge not found by given ID", nil)
+	}
+	cc := *c
+	return &cc, nil
+}
+
+// AddChallengeAttempt counts an attempt unless a challenge has been attempted a given
+// number of times
+func (repo *MFARepository) AddChallengeAttempt(id string, maxAttempts int) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.challenges[id]
+	if !ok || c.Attempts >= maxAttempts {
+		return errs.NewDataAccessError(errs.ErrorTypeConflict, "Challenge has no attempts left", nil)
+	}
+	c.Attempts++
+	return nil
+}
+
+// DeleteChallenge deletes a challenge by ID
+func (repo *MFARepository) DeleteChallenge(id string) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.challenges[id]; !ok {
+		return errs.NewDataAccessError(errs.ErrorTypeNotFound, "Challenge not found by given ID", nil)
+	}
+	delete(s.challenges, id)
+	return nil
+}
+
+// DeleteExpiredChallenges deletes all challenges expired by a given time
+func (repo *MFARepository) DeleteExpiredChallenges(now time.Time) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, c := range s.challenges {
+		if !c.ExpiresOn.After(now) {
+			delete(s.challenges, id)
+		}
+	}
+	return nil
+}
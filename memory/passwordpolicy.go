@@ -0,0 +1,72 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+)
+
+//
+// PasswordPolicyRepository is an in-memory implementation of
+// usecases.PasswordPolicyRepository
+//
+type PasswordPolicyRepository struct {
+	Store *Store
+}
+
+// Save inserts a policy of a domain or updates the existing one
+func (repo *PasswordPolicyRepository) Save(policy entities.PasswordPolicy) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.findDomain(policy.DomainID); err != nil {
+		return err
+	}
+	policy.UpdatedOn.Time = time.Now().UTC()
+	s.policies[policy.DomainID] = &policy
+	return nil
+}
+
+// Delete deletes a policy of a domain
+func (repo *PasswordPolicyRepository) Delete(domainID string) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.policies[domainID]; !ok {
+		return errs.NewDataAccessError(errs.ErrorTypeNotFound, "Password policy not found by given domain ID", nil)
+	}
+	delete(s.policies, domainID)
+	return nil
+}
+
+// FindByDomain finds a policy of a domain
+func (repo *PasswordPolicyRepository) FindByDomain(domainID string) (*entities.PasswordPolicy, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	p, ok := s.policies[domainID]
+	if !ok {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "Password policy not found by given domain ID", nil)
+	}
+	pp := *p
+	return &pp, nil
+}
+
+// ListByUser lists policies of all domains of a user
+func (repo *PasswordPolicyRepository) ListByUser(userID string) ([]entities.PasswordPolicy, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	policies := []entities.PasswordPolicy{}
+	for m := range s.memberships {
+		if p, ok := s.policies[m.domainID]; ok && m.userID == userID {
+			policies = append(policies, *p)
+		}
+	}
+	return policies, nil
+}
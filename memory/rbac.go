@@ -0,0 +1,739 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+	"github.com/oleksandr/idp/usecases"
+)
+
+// roleRecord is a stored role
+type roleRecord struct {
+	pk   int64
+	role entities.BasicRole
+}
+
+func (r *roleRecord) order() int64 { return r.pk }
+
+func (r *roleRecord) column(name string) interface{} {
+	switch name {
+	case "name":
+		return r.role.Name
+	case "is_enabled":
+		return r.role.Enabled
+	}
+	return nil
+}
+
+// permissionRecord is a stored permission
+type permissionRecord struct {
+	pk         int64
+	permission entities.BasicPermission
+}
+
+func (r *permissionRecord) order() int64 { return r.pk }
+
+func (r *permissionRecord) column(name string) interface{} {
+	switch name {
+	case "name":
+		return r.permission.Name
+	case "is_enabled":
+		return r.permission.Enabled
+	}
+	return nil
+}
+
+// constraintRecord is a stored separation of duty constraint
+type constraintRecord struct {
+	pk          int64
+	name        string
+	cardinality int
+	roles       map[int64]bool
+}
+
+func (r *constraintRecord) order() int64 { return r.pk }
+
+func (r *constraintRecord) column(name string) interface{} {
+	switch name {
+	case "name":
+		return r.name
+	case "cardinality":
+		return r.cardinality
+	}
+	return nil
+}
+
+//
+// PermissionRepository is an in-memory implementation of usecases.PermissionRepository
+//
+type PermissionRepository struct {
+	Store *Store
+}
+
+// Create adds a new permission
+func (repo *PermissionRepository) Create(p entities.BasicPermission) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.findPermissionByName(p.Name) != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to create a permission",
+			fmt.Errorf("Permission name %v is already taken", p.Name))
+	}
+	pk := s.next()
+	s.permissions[pk] = &permissionRecord{pk, p}
+	return nil
+}
+
+// Update updates all attributes of a permission found by name
+func (repo *PermissionRepository) Update(p entities.BasicPermission) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, err := s.findPermission(p.Name)
+	if err != nil {
+		return err
+	}
+	r.permission = p
+	return nil
+}
+
+// Rename renames a permission
+func (repo *PermissionRepository) Rename(oldName, newName string) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, err := s.findPermission(oldName)
+	if err != nil {
+		return err
+	}
+	if other := s.findPermissionByName(newName); other != nil && other != r {
+		return errs.NewDataAccessError(errs.ErrorTypeConflict, "Failed to rename permission",
+			fmt.Errorf("Permission name %v is already taken", newName))
+	}
+	r.permission.Name = newName
+	return nil
+}
+
+// Delete deletes a permission and removes it from all roles
+func (repo *PermissionRepository) Delete(name string) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, err := s.findPermission(name)
+	if err != nil {
+		return err
+	}
+	for rp := range s.rolePermissions {
+		if rp.permissionPK == r.pk {
+			delete(s.rolePermissions, rp)
+		}
+	}
+	delete(s.permissions, r.pk)
+	return nil
+}
+
+// FindByName finds a permission by name
+func (repo *PermissionRepository) FindByName(name string) (*entities.BasicPermission, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	r, err := s.findPermission(name)
+	if err != nil {
+		return nil, err
+	}
+	p := r.permission
+	return &p, nil
+}
+
+// List returns a page of permissions along with the total number of permissions
+func (repo *PermissionRepository) List(pager entities.Pager, sorter entities.Sorter) ([]entities.BasicPermission, int64, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var records []record
+	for _, r := range s.permissions {
+		records = append(records, r)
+	}
+	return permissionsToEntities(sortAndPage(records, pager, sorter)), int64(len(records)), nil
+}
+
+// ListByRoles returns a page of distinct permissions of given roles along with the total
+// number of the roles' permissions
+func (repo *PermissionRepository) ListByRoles(roleNames []string, pager entities.Pager, sorter entities.Sorter) ([]entities.BasicPermission, int64, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	roles := map[int64]bool{}
+	for _, name := range roleNames {
+		if r := s.findRoleByName(name); r != nil {
+			roles[r.pk] = true
+		}
+	}
+	seen := map[int64]bool{}
+	var records []record
+	for rp := range s.rolePermissions {
+		if roles[rp.rolePK] && !seen[rp.permissionPK] {
+			seen[rp.permissionPK] = true
+			records = append(records, s.permissions[rp.permissionPK])
+		}
+	}
+	return permissionsToEntities(sortAndPage(records, pager, sorter)), int64(len(records)), nil
+}
+
+//
+// RoleRepository is an in-memory implementation of usecases.RoleRepository
+//
+type RoleRepository struct {
+	Store *Store
+}
+
+// Create adds a new role
+func (repo *RoleRepository) Create(r entities.BasicRole) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.findRoleByName(r.Name) != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to create a role",
+			fmt.Errorf("Role name %v is already taken", r.Name))
+	}
+	pk := s.next()
+	s.roles[pk] = &roleRecord{pk, r}
+	return nil
+}
+
+// Update updates all attributes of a role found by name
+func (repo *RoleRepository) Update(role entities.BasicRole) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, err := s.findRole(role.Name)
+	if err != nil {
+		return err
+	}
+	r.role = role
+	return nil
+}
+
+// Rename renames a role
+func (repo *RoleRepository) Rename(oldName, newName string) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, err := s.findRole(oldName)
+	if err != nil {
+		return err
+	}
+	if other := s.findRoleByName(newName); other != nil && other != r {
+		return errs.NewDataAccessError(errs.ErrorTypeConflict, "Failed to rename a role",
+			fmt.Errorf("Role name %v is already taken", newName))
+	}
+	r.role.Name = newName
+	return nil
+}
+
+// Delete deletes a role along with its assignments, permissions, inheritance and
+// membership in separation of duty constraints
+func (repo *RoleRepository) Delete(name string) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, err := s.findRole(name)
+	if err != nil {
+		return err
+	}
+	for a := range s.assignments {
+		if a.rolePK == r.pk {
+			delete(s.assignments, a)
+		}
+	}
+	for rp := range s.rolePermissions {
+		if rp.rolePK == r.pk {
+			delete(s.rolePermissions, rp)
+		}
+	}
+	for i := range s.inheritance {
+		if i.seniorPK == r.pk || i.juniorPK == r.pk {
+			delete(s.inheritance, i)
+		}
+	}
+	for _, c := range s.constraints {
+		delete(c.roles, r.pk)
+	}
+	delete(s.roles, r.pk)
+	return nil
+}
+
+// FindByName finds a role by name
+func (repo *RoleRepository) FindByName(name string) (*entities.BasicRole, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	r, err := s.findRole(name)
+	if err != nil {
+		return nil, err
+	}
+	role := r.role
+	return &role, nil
+}
+
+// List returns a page of roles along with the total number of roles
+func (repo *RoleRepository) List(pager entities.Pager, sorter entities.Sorter) ([]entities.BasicRole, int64, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var records []record
+	for _, r := range s.roles {
+		records = append(records, r)
+	}
+	return rolesToEntities(sortAndPage(records, pager, sorter)), int64(len(records)), nil
+}
+
+// ListByNames returns existing roles with given names
+func (repo *RoleRepository) ListByNames(names []string) ([]entities.BasicRole, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	roles := []entities.BasicRole{}
+	for _, name := range names {
+		if r := s.findRoleByName(name); r != nil {
+			roles = append(roles, r.role)
+		}
+	}
+	return roles, nil
+}
+
+// ListByUser returns a page of roles assigned to a given user in any domain or
+// globally along with the total number of such roles
+func (repo *RoleRepository) ListByUser(userID string, pager entities.Pager, sorter entities.Sorter) ([]entities.BasicRole, int64, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	seen := map[int64]bool{}
+	var records []record
+	for a := range s.assignments {
+		if a.userID == userID && !seen[a.rolePK] {
+			seen[a.rolePK] = true
+			records = append(records, s.roles[a.rolePK])
+		}
+	}
+	return rolesToEntities(sortAndPage(records, pager, sorter)), int64(len(records)), nil
+}
+
+// ListAssignedNames returns names of enabled roles assigned to a given enabled user
+// either globally or in a given domain
+func (repo *RoleRepository) ListAssignedNames(userID, domainID string) ([]string, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var names []string
+	u, ok := s.users[userID]
+	if !ok || !u.user.Enabled {
+		return names, nil
+	}
+	seen := map[int64]bool{}
+	for a := range s.assignments {
+		if a.userID != userID || (a.domainID != "" && a.domainID != domainID) || seen[a.rolePK] {
+			continue
+		}
+		seen[a.rolePK] = true
+		if r := s.roles[a.rolePK]; r.role.Enabled {
+			names = append(names, r.role.Name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// AddPermissions adds given permissions to a role
+func (repo *RoleRepository) AddPermissions(roleName string, permissionNames []string) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, pks, err := s.findRolePermissions(roleName, permissionNames)
+	if err != nil {
+		return err
+	}
+	for _, pk := range pks {
+		if s.rolePermissions[rolePermission{r.pk, pk}] {
+			return errs.NewDataAccessError(errs.ErrorTypeConflict, "Failed to add permission to role",
+				fmt.Errorf("Permission %v is already added to role %v", s.permissions[pk].permission.Name, roleName))
+		}
+	}
+	for _, pk := range pks {
+		s.rolePermissions[rolePermission{r.pk, pk}] = true
+	}
+	return nil
+}
+
+// RemovePermissions removes given permissions from a role
+func (repo *RoleRepository) RemovePermissions(roleName string, permissionNames []string) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, pks, err := s.findRolePermissions(roleName, permissionNames)
+	if err != nil {
+		return err
+	}
+	for _, pk := range pks {
+		delete(s.rolePermissions, rolePermission{r.pk, pk})
+	}
+	return nil
+}
+
+// Inheritance returns role inheritance as a map of a senior role's name to names of
+// the roles it directly inherits. If enabledOnly is set, disabled junior roles are
+// left out, so neither they nor the roles they inherit are granted.
+func (repo *RoleRepository) Inheritance(enabledOnly bool) (map[string][]string, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.inheritanceByName(enabledOnly), nil
+}
+
+// Inherit makes a senior role inherit given junior roles. Existing inheritance is kept as is.
+func (repo *RoleRepository) Inherit(seniorRoleName string, juniorRoleNames []string, check usecases.RoleCheck) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	senior, err := s.findRole(seniorRoleName)
+	if err != nil {
+		return err
+	}
+	var added []inheritance
+	for _, name := range juniorRoleNames {
+		r, err := s.findRole(name)
+		if err != nil {
+			return err
+		}
+		i := inheritance{senior.pk, r.pk}
+		if !s.inheritance[i] {
+			added = append(added, i)
+		}
+	}
+
+	for _, i := range added {
+		s.inheritance[i] = true
+	}
+	if err = check(s.roleState("")); err != nil {
+		for _, i := range added {
+			delete(s.inheritance, i)
+		}
+		return err
+	}
+	return nil
+}
+
+// Uninherit removes given junior roles from roles inherited by a senior role
+func (repo *RoleRepository) Uninherit(seniorRoleName string, juniorRoleNames []string) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	senior, err := s.findRole(seniorRoleName)
+	if err != nil {
+		return err
+	}
+	var juniors []int64
+	for _, name := range juniorRoleNames {
+		r, err := s.findRole(name)
+		if err != nil {
+			return err
+		}
+		juniors = append(juniors, r.pk)
+	}
+	for _, pk := range juniors {
+		delete(s.inheritance, inheritance{senior.pk, pk})
+	}
+	return nil
+}
+
+// Assign assigns given roles to a user in a given domain or globally if the domain ID is empty
+func (repo *RoleRepository) Assign(userID string, roleNames []string, domainID string, check usecases.RoleCheck) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	assignments, err := s.findAssignments(userID, roleNames, domainID)
+	if err != nil {
+		return err
+	}
+	for _, a := range assignments {
+		if s.assignments[a] {
+			return errs.NewDataAccessError(errs.ErrorTypeConflict, "Failed to assign role to user",
+				fmt.Errorf("Role %v is already assigned", s.roles[a.rolePK].role.Name))
+		}
+	}
+
+	for _, a := range assignments {
+		s.assignments[a] = true
+	}
+	if err = check(s.roleState(userID)); err != nil {
+		for _, a := range assignments {
+			delete(s.assignments, a)
+		}
+		return err
+	}
+	return nil
+}
+
+// Revoke revokes given roles from a user in a given domain or globally if the domain ID is empty
+func (repo *RoleRepository) Revoke(userID string, roleNames []string, domainID string) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	assignments, err := s.findAssignments(userID, roleNames, domainID)
+	if err != nil {
+		return err
+	}
+	for _, a := range assignments {
+		delete(s.assignments, a)
+	}
+	return nil
+}
+
+// CreateSoDConstraint adds a new separation of duty constraint
+func (repo *RoleRepository) CreateSoDConstraint(c entities.SoDConstraint, check usecases.RoleCheck) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	roles := map[int64]bool{}
+	for _, name := range c.Roles {
+		r, err := s.findRole(name)
+		if err != nil {
+			return err
+		}
+		roles[r.pk] = true
+	}
+	if s.findConstraint(c.Name) != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeConflict, "Failed to create a constraint",
+			fmt.Errorf("Constraint name %v is already taken", c.Name))
+	}
+
+	pk := s.next()
+	s.constraints[pk] = &constraintRecord{
+		pk:          pk,
+		name:        c.Name,
+		cardinality: c.Cardinality,
+		roles:       roles,
+	}
+	if err := check(s.roleState("")); err != nil {
+		delete(s.constraints, pk)
+		return err
+	}
+	return nil
+}
+
+// DeleteSoDConstraint deletes a separation of duty constraint by name
+func (repo *RoleRepository) DeleteSoDConstraint(name string) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := s.findConstraint(name)
+	if c == nil {
+		return errs.NewDataAccessError(errs.ErrorTypeNotFound, "Constraint not found by given name", nil)
+	}
+	delete(s.constraints, c.pk)
+	return nil
+}
+
+// ListSoDConstraints returns a page of separation of duty constraints along with
+// the total number of constraints
+func (repo *RoleRepository) ListSoDConstraints(pager entities.Pager, sorter entities.Sorter) ([]entities.SoDConstraint, int64, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var records []record
+	for _, c := range s.constraints {
+		records = append(records, c)
+	}
+	constraints := []entities.SoDConstraint{}
+	for _, r := range sortAndPage(records, pager, sorter) {
+		constraints = append(constraints, s.constraintToEntity(r.(*constraintRecord)))
+	}
+	return constraints, int64(len(records)), nil
+}
+
+// roleState returns the state of RBAC relations a RoleCheck is performed against.
+// Only assignments of a given user are included unless the user ID is empty.
+func (s *Store) roleState(userID string) usecases.RoleState {
+	state := usecases.RoleState{
+		Inheritance: s.inheritanceByName(false),
+	}
+	var records []record
+	for _, c := range s.constraints {
+		records = append(records, c)
+	}
+	for _, r := range sortAndPage(records, entities.Pager{}, entities.Sorter{Field: "name", Asc: true}) {
+		state.Constraints = append(state.Constraints, s.constraintToEntity(r.(*constraintRecord)))
+	}
+	if len(state.Constraints) == 0 {
+		return state
+	}
+	for a := range s.assignments {
+		if userID != "" && a.userID != userID {
+			continue
+		}
+		state.Assignments = append(state.Assignments, usecases.RoleAssignment{
+			UserID:   a.userID,
+			UserName: s.users[a.userID].user.Name,
+			RoleName: s.roles[a.rolePK].role.Name,
+			DomainID: a.domainID,
+		})
+	}
+	return state
+}
+
+// inheritanceByName returns role inheritance by role names
+func (s *Store) inheritanceByName(enabledOnly bool) map[string][]string {
+	result := map[string][]string{}
+	for i := range s.inheritance {
+		senior, junior := s.roles[i.seniorPK].role, s.roles[i.juniorPK].role
+		if enabledOnly && !junior.Enabled {
+			continue
+		}
+		result[senior.Name] = append(result[senior.Name], junior.Name)
+	}
+	for _, names := range result {
+		sort.Strings(names)
+	}
+	return result
+}
+
+// findAssignments resolves assignments of given roles to a user in a domain
+func (s *Store) findAssignments(userID string, roleNames []string, domainID string) ([]assignment, error) {
+	if _, err := s.findUser(userID); err != nil {
+		return nil, err
+	}
+	if domainID != "" {
+		if _, err := s.findDomain(domainID); err != nil {
+			return nil, err
+		}
+	}
+	var assignments []assignment
+	for _, name := range roleNames {
+		r, err := s.findRole(name)
+		if err != nil {
+			return nil, err
+		}
+		assignments = append(assignments, assignment{userID, r.pk, domainID})
+	}
+	return assignments, nil
+}
+
+// findRolePermissions resolves a role and PKs of given permissions
+func (s *Store) findRolePermissions(roleName string, permissionNames []string) (*roleRecord, []int64, error) {
+	r, err := s.findRole(roleName)
+	if err != nil {
+		return nil, nil, err
+	}
+	var pks []int64
+	for _, name := range permissionNames {
+		p, err := s.findPermission(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		pks = append(pks, p.pk)
+	}
+	return r, pks, nil
+}
+
+// findRole finds a role record by name
+func (s *Store) findRole(name string) (*roleRecord, error) {
+	if r := s.findRoleByName(name); r != nil {
+		return r, nil
+	}
+	return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "Role not found by given name", nil)
+}
+
+// findRoleByName finds a role record by name or returns nil
+func (s *Store) findRoleByName(name string) *roleRecord {
+	for _, r := range s.roles {
+		if r.role.Name == name {
+			return r
+		}
+	}
+	return nil
+}
+
+// findPermission finds a permission record by name
+func (s *Store) findPermission(name string) (*permissionRecord, error) {
+	if p := s.findPermissionByName(name); p != nil {
+		return p, nil
+	}
+	return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "Permission not found by given name", nil)
+}
+
+// findPermissionByName finds a permission record by name or returns nil
+func (s *Store) findPermissionByName(name string) *permissionRecord {
+	for _, p := range s.permissions {
+		if p.permission.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// findConstraint finds a constraint record by name or returns nil
+func (s *Store) findConstraint(name string) *constraintRecord {
+	for _, c := range s.constraints {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func (s *Store) constraintToEntity(c *constraintRecord) entities.SoDConstraint {
+	e := entities.SoDConstraint{
+		Name:        c.name,
+		Roles:       []string{},
+		Cardinality: c.cardinality,
+	}
+	for pk := range c.roles {
+		e.Roles = append(e.Roles, s.roles[pk].role.Name)
+	}
+	sort.Strings(e.Roles)
+	return e
+}
+
+func permissionsToEntities(records []record) []entities.BasicPermission {
+	permissions := []entities.BasicPermission{}
+	for _, r := range records {
+		permissions = append(permissions, r.(*permissionRecord).permission)
+	}
+	return permissions
+}
+
+func rolesToEntities(records []record) []entities.BasicRole {
+	roles := []entities.BasicRole{}
+	for _, r := range records {
+		roles = append(roles, r.(*roleRecord).role)
+	}
+	return roles
+}
@@ -0,0 +1,126 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+)
+
+// serviceProviderRecord is a stored service provider
+type serviceProviderRecord struct {
+	seq int64
+	sp  entities.ServiceProvider
+}
+
+//
+// ServiceProviderRepository is an in-memory implementation of
+// usecases.ServiceProviderRepository
+//
+type ServiceProviderRepository struct {
+	Store *Store
+}
+
+// Create adds a new service provider
+func (repo *ServiceProviderRepository) Create(sp entities.ServiceProvider) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.findDomain(sp.DomainID); err != nil {
+		return err
+	}
+	if _, ok := s.serviceProviders[sp.ID]; ok {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to create a service provider",
+			fmt.Errorf("Service provider ID %v is already taken", sp.ID))
+	}
+	if s.findServiceProviderByEntityID(sp.EntityID) != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to create a service provider",
+			fmt.Errorf("Entity ID %v is already taken", sp.EntityID))
+	}
+	s.serviceProviders[sp.ID] = &serviceProviderRecord{
+		seq: s.next(),
+		sp:  sp,
+	}
+	return nil
+}
+
+// Delete deletes a service provider by ID
+func (repo *ServiceProviderRepository) Delete(id string) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.serviceProviders[id]; !ok {
+		return errs.NewDataAccessError(errs.ErrorTypeNotFound, "Service provider not found by given ID", nil)
+	}
+	delete(s.serviceProviders, id)
+	return nil
+}
+
+// FindByID finds a service provider by ID
+func (repo *ServiceProviderRepository) FindByID(id string) (*entities.ServiceProvider, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	r, ok := s.serviceProviders[id]
+	if !ok {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "Service provider not found by given ID", nil)
+	}
+	sp := r.sp
+	return &sp, nil
+}
+
+// FindByEntityID finds a service provider by entity ID
+func (repo *ServiceProviderRepository) FindByEntityID(entityID string) (*entities.ServiceProvider, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	r := s.findServiceProviderByEntityID(entityID)
+	if r == nil {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "Service provider not found by given entity ID", nil)
+	}
+	sp := r.sp
+	return &sp, nil
+}
+
+// List lists service providers of a domain (all of them if the domain ID is empty)
+// in creation order
+func (repo *ServiceProviderRepository) List(domainID string) ([]entities.ServiceProvider, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	records := []*serviceProviderRecord{}
+	for _, r := range s.serviceProviders {
+		if domainID == "" || r.sp.DomainID == domainID {
+			records = append(records, r)
+		}
+	}
+	sort.Sort(serviceProvidersBySeq(records))
+
+	sps := []entities.ServiceProvider{}
+	for _, r := range records {
+		sps = append(sps, r.sp)
+	}
+	return sps, nil
+}
+
+func (s *Store) findServiceProviderByEntityID(entityID string) *serviceProviderRecord {
+	for _, r := range s.serviceProviders {
+		if r.sp.EntityID == entityID {
+			return r
+		}
+	}
+	return nil
+}
+
+// serviceProvidersBySeq sorts service providers in insertion order
+type serviceProvidersBySeq []*serviceProviderRecord
+
+func (c serviceProvidersBySeq) Len() int           { return len(c) }
+func (c serviceProvidersBySeq) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
+func (c serviceProvidersBySeq) Less(i, j int) bool { return c[i].seq < c[j].seq }
@@ -0,0 +1,231 @@
+package memory
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+)
+
+// sessionRecord is a stored session
+type sessionRecord struct {
+	seq        int64
+	id         string
+	reference  string
+	domainID   string
+	userID     string
+	userAgent  string
+	remoteAddr string
+	createdOn  time.Time
+	updatedOn  time.Time
+	expiresOn  time.Time
+}
+
+func (r *sessionRecord) order() int64 { return r.seq }
+
+func (r *sessionRecord) column(name string) interface{} {
+	switch name {
+	case "session_id":
+		return r.id
+	case "user_agent":
+		return r.userAgent
+	case "remote_addr":
+		return r.remoteAddr
+	case "created_on":
+		return r.createdOn
+	case "updated_on":
+		return r.updatedOn
+	case "expires_on":
+		return r.expiresOn
+	}
+	return nil
+}
+
+//
+// SessionRepository is an in-memory implementation of usecases.SessionRepository
+//
+type SessionRepository struct {
+	Store *Store
+}
+
+// Create adds a new session of a user in a domain
+func (repo *SessionRepository) Create(session entities.Session) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.findDomain(session.Domain.ID); err != nil {
+		return err
+	}
+	if _, err := s.findUser(session.User.ID); err != nil {
+		return err
+	}
+	if _, ok := s.sessions[session.ID]; ok {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to create a session",
+			fmt.Errorf("Session ID %v is already taken", session.ID))
+	}
+	s.sessions[session.ID] = &sessionRecord{
+		seq:        s.next(),
+		id:         session.ID,
+		reference:  session.Reference,
+		domainID:   session.Domain.ID,
+		userID:     session.User.ID,
+		userAgent:  session.UserAgent,
+		remoteAddr: session.RemoteAddr,
+		createdOn:  session.CreatedOn.Time,
+		updatedOn:  session.UpdatedOn.Time,
+		expiresOn:  session.ExpiresOn.Time,
+	}
+	return nil
+}
+
+// Retain updates session's modification and expiration date/time
+func (repo *SessionRepository) Retain(id string, updatedOn, expiresOn time.Time) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r, ok := s.sessions[id]; ok {
+		r.updatedOn = updatedOn
+		r.expiresOn = expiresOn
+	}
+	return nil
+}
+
+// Delete deletes a session by ID
+func (repo *SessionRepository) Delete(id string) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.sessions[id]; !ok {
+		return errs.NewDataAccessError(errs.ErrorTypeNotFound, "Session not found by given ID", nil)
+	}
+	delete(s.sessions, id)
+	return nil
+}
+
+// DeleteExpired deletes sessions expired by a given time
+func (repo *SessionRepository) DeleteExpired(now time.Time) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, r := range s.sessions {
+		if !r.expiresOn.After(now) {
+			delete(s.sessions, id)
+		}
+	}
+	return nil
+}
+
+// DeleteByUser deletes all sessions of a user
+func (repo *SessionRepository) DeleteByUser(userID string) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, r := range s.sessions {
+		if r.userID == userID {
+			delete(s.sessions, id)
+		}
+	}
+	return nil
+}
+
+// DeleteByDomain deletes all sessions in a domain
+func (repo *SessionRepository) DeleteByDomain(domainID string) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, r := range s.sessions {
+		if r.domainID == domainID {
+			delete(s.sessions, id)
+		}
+	}
+	return nil
+}
+
+// FindByID finds a session by ID
+func (repo *SessionRepository) FindByID(id string) (*entities.Session, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	r, ok := s.sessions[id]
+	if !ok {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "Session not found by given ID", nil)
+	}
+	return s.sessionToEntity(r), nil
+}
+
+// FindByReference finds a session by reference
+func (repo *SessionRepository) FindByReference(reference string) (*entities.Session, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, r := range s.sessions {
+		if r.reference == reference {
+			return s.sessionToEntity(r), nil
+		}
+	}
+	return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "Session not found by given reference", nil)
+}
+
+// FindUserSpecific finds a session of a user in a domain opened with a given
+// user agent from a given remote address
+func (repo *SessionRepository) FindUserSpecific(userID, domainID, userAgent, remoteAddr string) (*entities.Session, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, r := range s.sessions {
+		if r.userID == userID && r.domainID == domainID && r.userAgent == userAgent && r.remoteAddr == remoteAddr {
+			return s.sessionToEntity(r), nil
+		}
+	}
+	return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "Session not found by given ID", nil)
+}
+
+// List returns a page of sessions along with the total number of sessions
+func (repo *SessionRepository) List(pager entities.Pager, sorter entities.Sorter) ([]entities.Session, int64, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var records []record
+	for _, r := range s.sessions {
+		records = append(records, r)
+	}
+	sessions := []entities.Session{}
+	for _, r := range sortAndPage(records, pager, sorter) {
+		sessions = append(sessions, *s.sessionToEntity(r.(*sessionRecord)))
+	}
+	return sessions, int64(len(records)), nil
+}
+
+func (s *Store) sessionToEntity(r *sessionRecord) *entities.Session {
+	e := &entities.Session{
+		ID:         r.id,
+		Reference:  r.reference,
+		Domain:     &entities.BasicDomain{ID: r.domainID},
+		User:       &entities.BasicUser{ID: r.userID},
+		UserAgent:  r.userAgent,
+		RemoteAddr: r.remoteAddr,
+	}
+	if d, ok := s.domains[r.domainID]; ok {
+		e.Domain.Name = d.domain.Name
+		e.Domain.Enabled = d.domain.Enabled
+	}
+	if u, ok := s.users[r.userID]; ok {
+		e.User.Name = u.user.Name
+		e.User.Enabled = u.user.Enabled
+	}
+	e.CreatedOn.Time = r.createdOn
+	e.UpdatedOn.Time = r.updatedOn
+	e.ExpiresOn.Time = r.expiresOn
+	return e
+}
@@ -0,0 +1,189 @@
+package memory
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/oleksandr/idp/entities"
+)
+
+//
+// Store keeps all entities in memory. It is shared by the repositories of this
+// package and is safe for concurrent use. Nothing is persisted, so the store is
+// meant for tests and development only.
+//
+type Store struct {
+	mu  sync.RWMutex
+	seq int64
+
+	domains          map[string]*domainRecord
+	users            map[string]*userRecord
+	memberships      map[membership]bool
+	sessions         map[string]*sessionRecord
+	roles            map[int64]*roleRecord
+	permissions      map[int64]*permissionRecord
+	rolePermissions  map[rolePermission]bool
+	inheritance      map[inheritance]bool
+	assignments      map[assignment]bool
+	constraints      map[int64]*constraintRecord
+	keys             map[string]*keyRecord
+	clients          map[string]*clientRecord
+	refreshTokens    map[string]*entities.RefreshToken
+	codes            map[string]*entities.AuthorizationCode
+	serviceProviders map[string]*serviceProviderRecord
+	enrollments      map[string]*enrollmentRecord
+	challenges       map[string]*entities.MFAChallenge
+	credentials      map[string]*credentialRecord
+	ceremonies       map[string]*entities.WebAuthnChallenge
+	failures         map[loginSubject]*entities.LoginFailures
+	passwordHistory  map[string][]string
+	policies         map[string]*entities.PasswordPolicy
+}
+
+// NewStore creates an empty store
+func NewStore() *Store {
+	return &Store{
+		domains:          map[string]*domainRecord{},
+		users:            map[string]*userRecord{},
+		memberships:      map[membership]bool{},
+		sessions:         map[string]*sessionRecord{},
+		roles:            map[int64]*roleRecord{},
+		permissions:      map[int64]*permissionRecord{},
+		rolePermissions:  map[rolePermission]bool{},
+		inheritance:      map[inheritance]bool{},
+		assignments:      map[assignment]bool{},
+		constraints:      map[int64]*constraintRecord{},
+		keys:             map[string]*keyRecord{},
+		clients:          map[string]*clientRecord{},
+		refreshTokens:    map[string]*entities.RefreshToken{},
+		codes:            map[string]*entities.AuthorizationCode{},
+		serviceProviders: map[string]*serviceProviderRecord{},
+		enrollments:      map[string]*enrollmentRecord{},
+		challenges:       map[string]*entities.MFAChallenge{},
+		credentials:      map[string]*credentialRecord{},
+		ceremonies:       map[string]*entities.WebAuthnChallenge{},
+		failures:         map[loginSubject]*entities.LoginFailures{},
+		passwordHistory:  map[string][]string{},
+		policies:         map[string]*entities.PasswordPolicy{},
+	}
+}
+
+// next returns the next sequence number, which is used both as a primary key and
+// to keep the insertion order. Must be called with the write lock held.
+func (s *Store) next() int64 {
+	s.seq++
+	return s.seq
+}
+
+// membership of a user (ID) in a domain (ID)
+type membership struct {
+	userID   string
+	domainID string
+}
+
+// rolePermission is a permission (PK) granted to a role (PK)
+type rolePermission struct {
+	rolePK       int64
+	permissionPK int64
+}
+
+// inheritance of a junior role (PK) by a senior role (PK)
+type inheritance struct {
+	seniorPK int64
+	juniorPK int64
+}
+
+// assignment of a role (PK) to a user (ID). The domain ID is empty for global assignments.
+type assignment struct {
+	userID   string
+	rolePK   int64
+	domainID string
+}
+
+// loginSubject is a user (ID), a remote address or a domain (ID) failing to sign in
+type loginSubject struct {
+	scope   string
+	subject string
+}
+
+//
+// record is a stored entity which can be sorted by its columns
+//
+type record interface {
+	order() int64
+	column(name string) interface{}
+}
+
+// sortAndPage sorts records by a column given by a sorter (by insertion order if it's not
+// set) and returns the page requested by a pager
+func sortAndPage(records []record, pager entities.Pager, sorter entities.Sorter) []record {
+	sort.Sort(byColumn{records, sorter})
+	if pager.PerPage <= 0 {
+		return records
+	}
+	offset := int(pager.Offset())
+	if offset < 0 || offset >= len(records) {
+		return nil
+	}
+	end := offset + pager.PerPage
+	if end > len(records) {
+		end = len(records)
+	}
+	return records[offset:end]
+}
+
+// byColumn sorts records by a column, records with equal values are kept in insertion order
+type byColumn struct {
+	records []record
+	sorter  entities.Sorter
+}
+
+func (b byColumn) Len() int      { return len(b.records) }
+func (b byColumn) Swap(i, j int) { b.records[i], b.records[j] = b.records[j], b.records[i] }
+func (b byColumn) Less(i, j int) bool {
+	if b.sorter.Field != "" {
+		c := compare(b.records[i].column(b.sorter.Field), b.records[j].column(b.sorter.Field))
+		if c != 0 {
+			return (c < 0) == b.sorter.Asc
+		}
+	}
+	return b.records[i].order() < b.records[j].order()
+}
+
+// compare compares column values of the same type
+func compare(a, b interface{}) int {
+	switch a := a.(type) {
+	case string:
+		if a < b.(string) {
+			return -1
+		} else if a > b.(string) {
+			return 1
+		}
+		return 0
+	case bool:
+		if a == b.(bool) {
+			return 0
+		} else if a {
+			return 1
+		}
+		return -1
+	case int64:
+		if a < b.(int64) {
+			return -1
+		} else if a > b.(int64) {
+			return 1
+		}
+		return 0
+	case int:
+		return compare(int64(a), int64(b.(int)))
+	case time.Time:
+		if a.Before(b.(time.Time)) {
+			return -1
+		} else if a.After(b.(time.Time)) {
+			return 1
+		}
+		return 0
+	}
+	return 0
+}
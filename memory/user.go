@@ -0,0 +1,337 @@
+package memory
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+)
+
+// userRecord is a stored user
+type userRecord struct {
+	seq       int64
+	user      entities.BasicUser
+	createdOn time.Time
+	updatedOn time.Time
+}
+
+func (r *userRecord) order() int64 { return r.seq }
+
+func (r *userRecord) column(name string) interface{} {
+	switch name {
+	case "name":
+		return r.user.Name
+	case "is_enabled":
+		return r.user.Enabled
+	case "created_on":
+		return r.createdOn
+	case "updated_on":
+		return r.updatedOn
+	}
+	return nil
+}
+
+//
+// UserRepository is an in-memory implementation of usecases.UserRepository
+//
+type UserRepository struct {
+	Store *Store
+}
+
+// Create adds a new user and assigns it to given domains
+func (repo *UserRepository) Create(user entities.BasicUser, domainIDs []string) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range domainIDs {
+		if _, err := s.findDomain(id); err != nil {
+			return err
+		}
+	}
+	if err := s.checkUserUnique(user.ID, user.Name); err != nil {
+		return errs.NewDataAccessError(errs.ErrorTypeConflict, "Failed to create user", err)
+	}
+
+	now := time.Now().UTC()
+	if user.PasswordChangedOn.IsZero() {
+		user.PasswordChangedOn.Time = now
+	}
+	s.users[user.ID] = &userRecord{
+		seq:       s.next(),
+		user:      user,
+		createdOn: now,
+		updatedOn: now,
+	}
+	for _, id := range domainIDs {
+		s.memberships[membership{user.ID, id}] = true
+	}
+	return nil
+}
+
+// Update updates all attributes of a user found by ID and changes its domains. A
+// replaced password hash is added to the user's password history.
+func (repo *UserRepository) Update(user entities.BasicUser, addDomainIDs []string, removeDomainIDs []string) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, err := s.findUser(user.ID)
+	if err != nil {
+		return err
+	}
+	for _, id := range append(append([]string{}, addDomainIDs...), removeDomainIDs...) {
+		if _, err := s.findDomain(id); err != nil {
+			return err
+		}
+	}
+	if err := s.checkUserUnique("", user.Name); err != nil && r.user.Name != user.Name {
+		return errs.NewDataAccessError(errs.ErrorTypeConflict, "Failed to update user", err)
+	}
+	for _, id := range addDomainIDs {
+		if s.memberships[membership{user.ID, id}] {
+			return errs.NewDataAccessError(errs.ErrorTypeConflict, "Failed to assign user to a domain",
+				fmt.Errorf("User is already in domain %v", id))
+		}
+	}
+
+	now := time.Now().UTC()
+	if user.Password != r.user.Password {
+		if user.PasswordChangedOn.IsZero() {
+			user.PasswordChangedOn.Time = now
+		}
+		history := append([]string{r.user.Password}, s.passwordHistory[user.ID]...)
+		if len(history) > entities.MaxPasswordHistory {
+			history = history[:entities.MaxPasswordHistory]
+		}
+		s.passwordHistory[user.ID] = history
+	} else {
+		user.PasswordChangedOn = r.user.PasswordChangedOn
+	}
+	r.user = user
+	r.updatedOn = now
+	for _, id := range addDomainIDs {
+		s.memberships[membership{user.ID, id}] = true
+	}
+	for _, id := range removeDomainIDs {
+		delete(s.memberships, membership{user.ID, id})
+	}
+	return nil
+}
+
+// UpdatePassword replaces a password hash of a user without changing the password
+func (repo *UserRepository) UpdatePassword(id, password string) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r, ok := s.users[id]; ok {
+		r.user.Password = password
+		r.updatedOn = time.Now().UTC()
+	}
+	return nil
+}
+
+// Delete deletes a user along with its sessions, role assignments, domains membership,
+// MFA enrollment, WebAuthn credentials and previous passwords
+func (repo *UserRepository) Delete(id string) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.findUser(id); err != nil {
+		return err
+	}
+	for sid, r := range s.sessions {
+		if r.userID == id {
+			delete(s.sessions, sid)
+		}
+	}
+	for a := range s.assignments {
+		if a.userID == id {
+			delete(s.assignments, a)
+		}
+	}
+	for m := range s.memberships {
+		if m.userID == id {
+			delete(s.memberships, m)
+		}
+	}
+	for cid, c := range s.challenges {
+		if c.UserID == id {
+			delete(s.challenges, cid)
+		}
+	}
+	delete(s.enrollments, id)
+	for cid, c := range s.ceremonies {
+		if c.UserID == id {
+			delete(s.ceremonies, cid)
+		}
+	}
+	for cid, r := range s.credentials {
+		if r.credential.UserID == id {
+			delete(s.credentials, cid)
+		}
+	}
+	delete(s.passwordHistory, id)
+	delete(s.users, id)
+	return nil
+}
+
+// ListPreviousPasswords lists hashes of a user's previous passwords, most recent first
+func (repo *UserRepository) ListPreviousPasswords(id string, limit int) ([]string, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, err := s.findUser(id); err != nil {
+		return nil, err
+	}
+	history := s.passwordHistory[id]
+	if limit < len(history) {
+		history = history[:limit]
+	}
+	return append([]string{}, history...), nil
+}
+
+// FindByID finds a user by ID
+func (repo *UserRepository) FindByID(id string) (*entities.BasicUser, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	r, err := s.findUser(id)
+	if err != nil {
+		return nil, err
+	}
+	u := r.user
+	return &u, nil
+}
+
+// FindByName finds a user by name
+func (repo *UserRepository) FindByName(name string) (*entities.BasicUser, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if r := s.findUserByName(name); r != nil {
+		u := r.user
+		return &u, nil
+	}
+	return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "User not found by given name", nil)
+}
+
+// FindInDomain finds a user by ID if it is assigned to a given domain
+func (repo *UserRepository) FindInDomain(userID, domainID string) (*entities.BasicUser, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if r, ok := s.users[userID]; ok && s.memberships[membership{userID, domainID}] {
+		u := r.user
+		return &u, nil
+	}
+	return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "User not found in a given domain", nil)
+}
+
+// FindByNameInDomain finds a user by name if it is assigned to a given domain
+func (repo *UserRepository) FindByNameInDomain(userName, domainID string) (*entities.BasicUser, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if r := s.findUserByName(userName); r != nil && s.memberships[membership{r.user.ID, domainID}] {
+		u := r.user
+		return &u, nil
+	}
+	return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "User not found in a given domain", nil)
+}
+
+// CountDomains returns number of domains of a user
+func (repo *UserRepository) CountDomains(id string) (int64, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.countDomains(id), nil
+}
+
+// List returns a page of users along with the total number of users
+func (repo *UserRepository) List(pager entities.Pager, sorter entities.Sorter) ([]entities.User, int64, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var records []record
+	for _, r := range s.users {
+		records = append(records, r)
+	}
+	return s.usersToEntities(sortAndPage(records, pager, sorter)), int64(len(records)), nil
+}
+
+// ListByDomain returns a page of users of a given domain along with the total number
+// of the domain's users
+func (repo *UserRepository) ListByDomain(domainID string, pager entities.Pager, sorter entities.Sorter) ([]entities.User, int64, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var records []record
+	for m := range s.memberships {
+		if m.domainID == domainID {
+			records = append(records, s.users[m.userID])
+		}
+	}
+	return s.usersToEntities(sortAndPage(records, pager, sorter)), int64(len(records)), nil
+}
+
+// findUser finds a user record by ID
+func (s *Store) findUser(id string) (*userRecord, error) {
+	r, ok := s.users[id]
+	if !ok {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "User not found by given ID", nil)
+	}
+	return r, nil
+}
+
+// findUserByName finds a user record by name or returns nil
+func (s *Store) findUserByName(name string) *userRecord {
+	for _, r := range s.users {
+		if r.user.Name == name {
+			return r
+		}
+	}
+	return nil
+}
+
+// checkUserUnique checks if a user ID and a name are not taken yet
+func (s *Store) checkUserUnique(id, name string) error {
+	if _, ok := s.users[id]; ok {
+		return fmt.Errorf("User ID %v is already taken", id)
+	}
+	if s.findUserByName(name) != nil {
+		return fmt.Errorf("User name %v is already taken", name)
+	}
+	return nil
+}
+
+// countDomains returns number of domains of a user
+func (s *Store) countDomains(id string) int64 {
+	var n int64
+	for m := range s.memberships {
+		if m.userID == id {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *Store) usersToEntities(records []record) []entities.User {
+	users := []entities.User{}
+	for _, r := range records {
+		u := r.(*userRecord).user
+		users = append(users, entities.User{BasicUser: u, DomainsCount: s.countDomains(u.ID)})
+	}
+	return users
+}
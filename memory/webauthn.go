@@ -0,0 +1,186 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+)
+
+// credentialRecord is a stored WebAuthn credential
+type credentialRecord struct {
+	seq        int64
+	credential entities.Credential
+}
+
+//
+// WebAuthnRepository is an in-memory implementation of usecases.WebAuthnRepository
+//
+type WebAuthnRepository struct {
+	Store *Store
+}
+
+// CreateCredential adds a new credential
+func (repo *WebAuthnRepository) CreateCredential(credential entities.Credential) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.findUser(credential.UserID); err != nil {
+		return err
+	}
+	if _, ok := s.credentials[credential.ID]; ok {
+		return errs.NewDataAccessError(errs.ErrorTypeConflict, "Failed to create a credential",
+			fmt.Errorf("Credential ID %v is already taken", credential.ID))
+	}
+	credential.PublicKey = append([]byte(nil), credential.PublicKey...)
+	s.credentials[credential.ID] = &credentialRecord{
+		seq:        s.next(),
+		credential: credential,
+	}
+	return nil
+}
+
+// DeleteCredential deletes a credential of a user by ID
+func (repo *WebAuthnRepository) DeleteCredential(userID, id string) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.credentials[id]
+	if !ok || r.credential.UserID != userID {
+		return errs.NewDataAccessError(errs.ErrorTypeNotFound, "Credential not found by given ID", nil)
+	}
+	delete(s.credentials, id)
+	return nil
+}
+
+// FindCredential finds a credential by ID
+func (repo *WebAuthnRepository) FindCredential(id string) (*entities.Credential, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	r, ok := s.credentials[id]
+	if !ok {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "Credential not found by given ID", nil)
+	}
+	c := r.credential
+	c.PublicKey = append([]byte(nil), c.PublicKey...)
+	return &c, nil
+}
+
+// ListCredentials lists all credentials of a user in order of registration
+func (repo *WebAuthnRepository) ListCredentials(userID string) ([]entities.Credential, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	records := []*credentialRecord{}
+	for _, r := range s.credentials {
+		if r.credential.UserID == userID {
+			records = append(records, r)
+		}
+	}
+	sort.Sort(credentialsBySeq(records))
+
+	credentials := []entities.Credential{}
+	for _, r := range records {
+		c := r.credential
+		c.PublicKey = append([]byte(nil), c.PublicKey...)
+		credentials = append(credentials, c)
+	}
+	return credentials, nil
+}
+
+// UpdateSignCount records the signature counter of a credential used at a given time
+// if the stored counter is still the given one
+func (repo *WebAuthnRepository) UpdateSignCount(id string, from, to uint32, usedOn time.Time) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.credentials[id]
+	if !ok || r.credential.SignCount != from {
+		return errs.NewDataAccessError(errs.ErrorTypeConflict, "Signature counter of the credential has changed", nil)
+	}
+	r.credential.SignCount = to
+	r.credential.LastUsedOn.Time = usedOn
+	return nil
+}
+
+// CreateChallenge adds a new challenge
+func (repo *WebAuthnRepository) CreateChallenge(challenge entities.WebAuthnChallenge) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if challenge.DomainID != "" {
+		if _, err := s.findDomain(challenge.DomainID); err != nil {
+			return err
+		}
+	}
+	if challenge.UserID != "" {
+		if _, err := s.findUser(challenge.UserID); err != nil {
+			return err
+		}
+	}
+	if _, ok := s.ceremonies[challenge.ID]; ok {
+		return errs.NewDataAccessError(errs.ErrorTypeOperational, "Failed to create a challenge",
+			fmt.Errorf("Challenge ID %v is already taken", challenge.ID))
+	}
+	challenge.Challenge = append([]byte(nil), challenge.Challenge...)
+	s.ceremonies[challenge.ID] = &challenge
+	return nil
+}
+
+// FindChallenge finds a challenge by ID
+func (repo *WebAuthnRepository) FindChallenge(id string) (*entities.WebAuthnChallenge, error) {
+	s := repo.Store
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	c, ok := s.ceremonies[id]
+	if !ok {
+		return nil, errs.NewDataAccessError(errs.ErrorTypeNotFound, "Challenge not found by given ID", nil)
+	}
+	cc := *c
+	cc.Challenge = append([]byte(nil), c.Challenge...)
+	return &cc, nil
+}
+
+// DeleteChallenge deletes a challenge by ID
+func (repo *WebAuthnRepository) DeleteChallenge(id string) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.ceremonies[id]; !ok {
+		return errs.NewDataAccessError(errs.ErrorTypeNotFound, "Challenge not found by given ID", nil)
+	}
+	delete(s.ceremonies, id)
+	return nil
+}
+
+// DeleteExpiredChallenges deletes all challenges expired by a given time
+func (repo *WebAuthnRepository) DeleteExpiredChallenges(now time.Time) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, c := range s.ceremonies {
+		if !c.ExpiresOn.After(now) {
+			delete(s.ceremonies, id)
+		}
+	}
+	return nil
+}
+
+// credentialsBySeq sorts credentials in insertion order
+type credentialsBySeq []*credentialRecord
+
+func (c credentialsBySeq) Len() int           { return len(c) }
+func (c credentialsBySeq) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
+func (c credentialsBySeq) Less(i, j int) bool { return c[i].seq < c[j].seq }
@@ -26,7 +26,10 @@ func Usage() {
 	fmt.Fprintln(os.Stderr, "  bool checkSession(string sessionID, string userAgent, string remoteAddr)")
 	fmt.Fprintln(os.Stderr, "  bool deleteSession(string sessionID, string userAgent, string remoteAddr)")
 	fmt.Fprintln(os.Stderr, "  bool assertRole(string sessionID, string roleName)")
-	fmt.Fprintln(os.Stderr, "  bool assertPermission(string sessionID, string permissioName)")
+	fmt.Fprintln(os.Stderr, "  bool assertPermission(string sessionID, string permissioName,  attributes)")
+	fmt.Fprintln(os.Stderr, "   getEffectivePermissions(string sessionID)")
+	fmt.Fprintln(os.Stderr, "  SessionChallenge beginSession(string domain, string name, string password, string userAgent, string remoteAddr)")
+	fmt.Fprintln(os.Stderr, "  Session completeSessionMFA(string challengeID, string code, string userAgent, string remoteAddr)")
 	fmt.Fprintln(os.Stderr)
 	os.Exit(0)
 }
@@ -194,15 +197,77 @@ func main() {
 		fmt.Print("\n")
 		break
 	case "assertPermission":
-		if flag.NArg()-1 != 2 {
-			fmt.Fprintln(os.Stderr, "AssertPermission requires 2 args")
+		if flag.NArg()-1 != 3 {
+			fmt.Fprintln(os.Stderr, "AssertPermission requires 3 args")
+			flag.Usage()
+		}
+		argvalue0 := flag.Arg(1)
+		value0 := argvalue0
+		argvalue1 := flag.Arg(2)
+		value1 := argvalue1
+		arg15 := flag.Arg(3)
+		mbTrans16 := thrift.NewTMemoryBufferLen(len(arg15))
+		defer mbTrans16.Close()
+		_, err17 := mbTrans16.WriteString(arg15)
+		if err17 != nil {
+			Usage()
+			return
+		}
+		factory18 := thrift.NewTSimpleJSONProtocolFactory()
+		jsProt19 := factory18.GetProtocol(mbTrans16)
+		containerStruct2 := services.NewAssertPermissionArgs()
+		err20 := containerStruct2.ReadField3(jsProt19)
+		if err20 != nil {
+			Usage()
+			return
+		}
+		argvalue2 := containerStruct2.Attributes
+		value2 := argvalue2
+		fmt.Print(client.AssertPermission(value0, value1, value2))
+		fmt.Print("\n")
+		break
+	case "getEffectivePermissions":
+		if flag.NArg()-1 != 1 {
+			fmt.Fprintln(os.Stderr, "GetEffectivePermissions requires 1 args")
+			flag.Usage()
+		}
+		argvalue0 := flag.Arg(1)
+		value0 := argvalue0
+		fmt.Print(client.GetEffectivePermissions(value0))
+		fmt.Print("\n")
+		break
+	case "beginSession":
+		if flag.NArg()-1 != 5 {
+			fmt.Fprintln(os.Stderr, "BeginSession requires 5 args")
 			flag.Usage()
 		}
 		argvalue0 := flag.Arg(1)
 		value0 := argvalue0
 		argvalue1 := flag.Arg(2)
 		value1 := argvalue1
-		fmt.Print(client.AssertPermission(value0, value1))
+		argvalue2 := flag.Arg(3)
+		value2 := argvalue2
+		argvalue3 := flag.Arg(4)
+		value3 := argvalue3
+		argvalue4 := flag.Arg(5)
+		value4 := argvalue4
+		fmt.Print(client.BeginSession(value0, value1, value2, value3, value4))
+		fmt.Print("\n")
+		break
+	case "completeSessionMFA":
+		if flag.NArg()-1 != 4 {
+			fmt.Fprintln(os.Stderr, "CompleteSessionMFA requires 4 args")
+			flag.Usage()
+		}
+		argvalue0 := flag.Arg(1)
+		value0 := argvalue0
+		argvalue1 := flag.Arg(2)
+		value1 := argvalue1
+		argvalue2 := flag.Arg(3)
+		value2 := argvalue2
+		argvalue3 := flag.Arg(4)
+		value3 := argvalue3
+		fmt.Print(client.CompleteSessionMFA(value0, value1, value2, value3))
 		fmt.Print("\n")
 		break
 	case "":
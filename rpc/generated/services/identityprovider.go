@@ -46,7 +46,24 @@ type IdentityProvider interface { //IdentityProvider service
 	// Parameters:
 	//  - SessionID
 	//  - PermissioName
-	AssertPermission(sessionID string, permissioName string) (r bool, err error)
+	//  - Attributes
+	AssertPermission(sessionID string, permissioName string, attributes map[string]string) (r bool, err error)
+	// Parameters:
+	//  - SessionID
+	GetEffectivePermissions(sessionID string) (r []*Permission, err error)
+	// Parameters:
+	//  - Domain
+	//  - Name
+	//  - Password
+	//  - UserAgent
+	//  - RemoteAddr
+	BeginSession(domain string, name string, password string, userAgent string, remoteAddr string) (r *SessionChallenge, err error)
+	// Parameters:
+	//  - ChallengeID
+	//  - Code
+	//  - UserAgent
+	//  - RemoteAddr
+	CompleteSessionMFA(challengeID string, code string, userAgent string, remoteAddr string) (r *Session, err error)
 }
 
 //IdentityProvider service
@@ -526,14 +543,15 @@ func (p *IdentityProviderClient) recvAssertRole() (value bool, err error) {
 // Parameters:
 //  - SessionID
 //  - PermissioName
-func (p *IdentityProviderClient) AssertPermission(sessionID string, permissioName string) (r bool, err error) {
-	if err = p.sendAssertPermission(sessionID, permissioName); err != nil {
+//  - Attributes
+func (p *IdentityProviderClient) AssertPermission(sessionID string, permissioName string, attributes map[string]string) (r bool, err error) {
+	if err = p.sendAssertPermission(sessionID, permissioName, attributes); err != nil {
 		return
 	}
 	return p.recvAssertPermission()
 }
 
-func (p *IdentityProviderClient) sendAssertPermission(sessionID string, permissioName string) (err error) {
+func (p *IdentityProviderClient) sendAssertPermission(sessionID string, permissioName string, attributes map[string]string) (err error) {
 	oprot := p.OutputProtocol
 	if oprot == nil {
 		oprot = p.ProtocolFactory.GetProtocol(p.Transport)
@@ -546,6 +564,7 @@ func (p *IdentityProviderClient) sendAssertPermission(sessionID string, permissi
 	args := AssertPermissionArgs{
 		SessionID:     sessionID,
 		PermissioName: permissioName,
+		Attributes:    attributes,
 	}
 	if err = args.Write(oprot); err != nil {
 		return
@@ -610,6 +629,275 @@ func (p *IdentityProviderClient) recvAssertPermission() (value bool, err error)
 	return
 }
 
+// Parameters:
+//  - SessionID
+func (p *IdentityProviderClient) GetEffectivePermissions(sessionID string) (r []*Permission, err error) {
+	if err = p.sendGetEffectivePermissions(sessionID); err != nil {
+		return
+	}
+	return p.recvGetEffectivePermissions()
+}
+
+func (p *IdentityProviderClient) sendGetEffectivePermissions(sessionID string) (err error) {
+	oprot := p.OutputProtocol
+	if oprot == nil {
+		oprot = p.ProtocolFactory.GetProtocol(p.Transport)
+		p.OutputProtocol = oprot
+	}
+	p.SeqId++
+	if err = oprot.WriteMessageBegin("getEffectivePermissions", thrift.CALL, p.SeqId); err != nil {
+		return
+	}
+	args := GetEffectivePermissionsArgs{
+		SessionID: sessionID,
+	}
+	if err = args.Write(oprot); err != nil {
+		return
+	}
+	if err = oprot.WriteMessageEnd(); err != nil {
+		return
+	}
+	return oprot.Flush()
+}
+
+func (p *IdentityProviderClient) recvGetEffectivePermissions() (value []*Permission, err error) {
+	iprot := p.InputProtocol
+	if iprot == nil {
+		iprot = p.ProtocolFactory.GetProtocol(p.Transport)
+		p.InputProtocol = iprot
+	}
+	_, mTypeId, seqId, err := iprot.ReadMessageBegin()
+	if err != nil {
+		return
+	}
+	if mTypeId == thrift.EXCEPTION {
+		error12 := thrift.NewTApplicationException(thrift.UNKNOWN_APPLICATION_EXCEPTION, "Unknown Exception")
+		var error13 error
+		error13, err = error12.Read(iprot)
+		if err != nil {
+			return
+		}
+		if err = iprot.ReadMessageEnd(); err != nil {
+			return
+		}
+		err = error13
+		return
+	}
+	if p.SeqId != seqId {
+		err = thrift.NewTApplicationException(thrift.BAD_SEQUENCE_ID, "getEffectivePermissions failed: out of sequence response")
+		return
+	}
+	result := GetEffectivePermissionsResult{}
+	if err = result.Read(iprot); err != nil {
+		return
+	}
+	if err = iprot.ReadMessageEnd(); err != nil {
+		return
+	}
+	if result.Error1 != nil {
+		err = result.Error1
+		return
+	} else if result.Error2 != nil {
+		err = result.Error2
+		return
+	} else if result.Error3 != nil {
+		err = result.Error3
+		return
+	} else if result.Error4 != nil {
+		err = result.Error4
+		return
+	} else if result.Error5 != nil {
+		err = result.Error5
+		return
+	}
+	value = result.GetSuccess()
+	return
+}
+
+// Parameters:
+//  - Domain
+//  - Name
+//  - Password
+//  - UserAgent
+//  - RemoteAddr
+func (p *IdentityProviderClient) BeginSession(domain string, name string, password string, userAgent string, remoteAddr string) (r *SessionChallenge, err error) {
+	if err = p.sendBeginSession(domain, name, password, userAgent, remoteAddr); err != nil {
+		return
+	}
+	return p.recvBeginSession()
+}
+
+func (p *IdentityProviderClient) sendBeginSession(domain string, name string, password string, userAgent string, remoteAddr string) (err error) {
+	oprot := p.OutputProtocol
+	if oprot == nil {
+		oprot = p.ProtocolFactory.GetProtocol(p.Transport)
+		p.OutputProtocol = oprot
+	}
+	p.SeqId++
+	if err = oprot.WriteMessageBegin("beginSession", thrift.CALL, p.SeqId); err != nil {
+		return
+	}
+	args := BeginSessionArgs{
+		Domain:     domain,
+		Name:       name,
+		Password:   password,
+		UserAgent:  userAgent,
+		RemoteAddr: remoteAddr,
+	}
+	if err = args.Write(oprot); err != nil {
+		return
+	}
+	if err = oprot.WriteMessageEnd(); err != nil {
+		return
+	}
+	return oprot.Flush()
+}
+
+func (p *IdentityProviderClient) recvBeginSession() (value *SessionChallenge, err error) {
+	iprot := p.InputProtocol
+	if iprot == nil {
+		iprot = p.ProtocolFactory.GetProtocol(p.Transport)
+		p.InputProtocol = iprot
+	}
+	_, mTypeId, seqId, err := iprot.ReadMessageBegin()
+	if err != nil {
+		return
+	}
+	if mTypeId == thrift.EXCEPTION {
+		error0 := thrift.NewTApplicationException(thrift.UNKNOWN_APPLICATION_EXCEPTION, "Unknown Exception")
+		var error1 error
+		error1, err = error0.Read(iprot)
+		if err != nil {
+			return
+		}
+		if err = iprot.ReadMessageEnd(); err != nil {
+			return
+		}
+		err = error1
+		return
+	}
+	if p.SeqId != seqId {
+		err = thrift.NewTApplicationException(thrift.BAD_SEQUENCE_ID, "beginSession failed: out of sequence response")
+		return
+	}
+	result := BeginSessionResult{}
+	if err = result.Read(iprot); err != nil {
+		return
+	}
+	if err = iprot.ReadMessageEnd(); err != nil {
+		return
+	}
+	if result.Error1 != nil {
+		err = result.Error1
+		return
+	} else if result.Error2 != nil {
+		err = result.Error2
+		return
+	} else if result.Error3 != nil {
+		err = result.Error3
+		return
+	} else if result.Error4 != nil {
+		err = result.Error4
+		return
+	} else if result.Error5 != nil {
+		err = result.Error5
+		return
+	}
+	value = result.GetSuccess()
+	return
+}
+
+// Parameters:
+//  - ChallengeID
+//  - Code
+//  - UserAgent
+//  - RemoteAddr
+func (p *IdentityProviderClient) CompleteSessionMFA(challengeID string, code string, userAgent string, remoteAddr string) (r *Session, err error) {
+	if err = p.sendCompleteSessionMFA(challengeID, code, userAgent, remoteAddr); err != nil {
+		return
+	}
+	return p.recvCompleteSessionMFA()
+}
+
+func (p *IdentityProviderClient) sendCompleteSessionMFA(challengeID string, code string, userAgent string, remoteAddr string) (err error) {
+	oprot := p.OutputProtocol
+	if oprot == nil {
+		oprot = p.ProtocolFactory.GetProtocol(p.Transport)
+		p.OutputProtocol = oprot
+	}
+	p.SeqId++
+	if err = oprot.WriteMessageBegin("completeSessionMFA", thrift.CALL, p.SeqId); err != nil {
+		return
+	}
+	args := CompleteSessionMFAArgs{
+		ChallengeID: challengeID,
+		Code:        code,
+		UserAgent:   userAgent,
+		RemoteAddr:  remoteAddr,
+	}
+	if err = args.Write(oprot); err != nil {
+		return
+	}
+	if err = oprot.WriteMessageEnd(); err != nil {
+		return
+	}
+	return oprot.Flush()
+}
+
+func (p *IdentityProviderClient) recvCompleteSessionMFA() (value *Session, err error) {
+	iprot := p.InputProtocol
+	if iprot == nil {
+		iprot = p.ProtocolFactory.GetProtocol(p.Transport)
+		p.InputProtocol = iprot
+	}
+	_, mTypeId, seqId, err := iprot.ReadMessageBegin()
+	if err != nil {
+		return
+	}
+	if mTypeId == thrift.EXCEPTION {
+		error0 := thrift.NewTApplicationException(thrift.UNKNOWN_APPLICATION_EXCEPTION, "Unknown Exception")
+		var error1 error
+		error1, err = error0.Read(iprot)
+		if err != nil {
+			return
+		}
+		if err = iprot.ReadMessageEnd(); err != nil {
+			return
+		}
+		err = error1
+		return
+	}
+	if p.SeqId != seqId {
+		err = thrift.NewTApplicationException(thrift.BAD_SEQUENCE_ID, "completeSessionMFA failed: out of sequence response")
+		return
+	}
+	result := CompleteSessionMFAResult{}
+	if err = result.Read(iprot); err != nil {
+		return
+	}
+	if err = iprot.ReadMessageEnd(); err != nil {
+		return
+	}
+	if result.Error1 != nil {
+		err = result.Error1
+		return
+	} else if result.Error2 != nil {
+		err = result.Error2
+		return
+	} else if result.Error3 != nil {
+		err = result.Error3
+		return
+	} else if result.Error4 != nil {
+		err = result.Error4
+		return
+	} else if result.Error5 != nil {
+		err = result.Error5
+		return
+	}
+	value = result.GetSuccess()
+	return
+}
+
 type IdentityProviderProcessor struct {
 	processorMap map[string]thrift.TProcessorFunction
 	handler      IdentityProvider
@@ -630,14 +918,17 @@ func (p *IdentityProviderProcessor) ProcessorMap() map[string]thrift.TProcessorF
 
 func NewIdentityProviderProcessor(handler IdentityProvider) *IdentityProviderProcessor {
 
-	self12 := &IdentityProviderProcessor{handler: handler, processorMap: make(map[string]thrift.TProcessorFunction)}
-	self12.processorMap["createSession"] = &identityProviderProcessorCreateSession{handler: handler}
-	self12.processorMap["getSession"] = &identityProviderProcessorGetSession{handler: handler}
-	self12.processorMap["checkSession"] = &identityProviderProcessorCheckSession{handler: handler}
-	self12.processorMap["deleteSession"] = &identityProviderProcessorDeleteSession{handler: handler}
-	self12.processorMap["assertRole"] = &identityProviderProcessorAssertRole{handler: handler}
-	self12.processorMap["assertPermission"] = &identityProviderProcessorAssertPermission{handler: handler}
-	return self12
+	self14 := &IdentityProviderProcessor{handler: handler, processorMap: make(map[string]thrift.TProcessorFunction)}
+	self14.processorMap["createSession"] = &identityProviderProcessorCreateSession{handler: handler}
+	self14.processorMap["getSession"] = &identityProviderProcessorGetSession{handler: handler}
+	self14.processorMap["checkSession"] = &identityProviderProcessorCheckSession{handler: handler}
+	self14.processorMap["deleteSession"] = &identityProviderProcessorDeleteSession{handler: handler}
+	self14.processorMap["assertRole"] = &identityProviderProcessorAssertRole{handler: handler}
+	self14.processorMap["assertPermission"] = &identityProviderProcessorAssertPermission{handler: handler}
+	self14.processorMap["getEffectivePermissions"] = &identityProviderProcessorGetEffectivePermissions{handler: handler}
+	self14.processorMap["beginSession"] = &identityProviderProcessorBeginSession{handler: handler}
+	self14.processorMap["completeSessionMFA"] = &identityProviderProcessorCompleteSessionMFA{handler: handler}
+	return self14
 }
 
 func (p *IdentityProviderProcessor) Process(iprot, oprot thrift.TProtocol) (success bool, err thrift.TException) {
@@ -984,7 +1275,7 @@ func (p *identityProviderProcessorAssertPermission) Process(seqId int32, iprot,
 	result := AssertPermissionResult{}
 	var retval bool
 	var err2 error
-	if retval, err2 = p.handler.AssertPermission(args.SessionID, args.PermissioName); err2 != nil {
+	if retval, err2 = p.handler.AssertPermission(args.SessionID, args.PermissioName, args.Attributes); err2 != nil {
 		switch v := err2.(type) {
 		case *ServerError:
 			result.Error1 = v
@@ -1025,18 +1316,201 @@ func (p *identityProviderProcessorAssertPermission) Process(seqId int32, iprot,
 	return true, err
 }
 
-// HELPER FUNCTIONS AND STRUCTURES
-
-type CreateSessionArgs struct {
-	Domain     string `thrift:"domain,1" json:"domain"`
-	Name       string `thrift:"name,2" json:"name"`
-	Password   string `thrift:"password,3" json:"password"`
-	UserAgent  string `thrift:"userAgent,4" json:"userAgent"`
-	RemoteAddr string `thrift:"remoteAddr,5" json:"remoteAddr"`
-}
-
-func NewCreateSessionArgs() *CreateSessionArgs {
-	return &CreateSessionArgs{}
+type identityProviderProcessorBeginSession struct {
+	handler IdentityProvider
+}
+
+func (p *identityProviderProcessorBeginSession) Process(seqId int32, iprot, oprot thrift.TProtocol) (success bool, err thrift.TException) {
+	args := BeginSessionArgs{}
+	if err = args.Read(iprot); err != nil {
+		iprot.ReadMessageEnd()
+		x := thrift.NewTApplicationException(thrift.PROTOCOL_ERROR, err.Error())
+		oprot.WriteMessageBegin("beginSession", thrift.EXCEPTION, seqId)
+		x.Write(oprot)
+		oprot.WriteMessageEnd()
+		oprot.Flush()
+		return false, err
+	}
+
+	iprot.ReadMessageEnd()
+	result := BeginSessionResult{}
+	var retval *SessionChallenge
+	var err2 error
+	if retval, err2 = p.handler.BeginSession(args.Domain, args.Name, args.Password, args.UserAgent, args.RemoteAddr); err2 != nil {
+		switch v := err2.(type) {
+		case *ServerError:
+			result.Error1 = v
+		case *BadRequestError:
+			result.Error2 = v
+		case *UnauthorizedError:
+			result.Error3 = v
+		case *ForbiddenError:
+			result.Error4 = v
+		case *NotFoundError:
+			result.Error5 = v
+		default:
+			x := thrift.NewTApplicationException(thrift.INTERNAL_ERROR, "Internal error processing beginSession: "+err2.Error())
+			oprot.WriteMessageBegin("beginSession", thrift.EXCEPTION, seqId)
+			x.Write(oprot)
+			oprot.WriteMessageEnd()
+			oprot.Flush()
+			return true, err2
+		}
+	} else {
+		result.Success = retval
+	}
+	if err2 = oprot.WriteMessageBegin("beginSession", thrift.REPLY, seqId); err2 != nil {
+		err = err2
+	}
+	if err2 = result.Write(oprot); err == nil && err2 != nil {
+		err = err2
+	}
+	if err2 = oprot.WriteMessageEnd(); err == nil && err2 != nil {
+		err = err2
+	}
+	if err2 = oprot.Flush(); err == nil && err2 != nil {
+		err = err2
+	}
+	if err != nil {
+		return
+	}
+	return true, err
+}
+
+type identityProviderProcessorCompleteSessionMFA struct {
+	handler IdentityProvider
+}
+
+func (p *identityProviderProcessorCompleteSessionMFA) Process(seqId int32, iprot, oprot thrift.TProtocol) (success bool, err thrift.TException) {
+	args := CompleteSessionMFAArgs{}
+	if err = args.Read(iprot); err != nil {
+		iprot.ReadMessageEnd()
+		x := thrift.NewTApplicationException(thrift.PROTOCOL_ERROR, err.Error())
+		oprot.WriteMessageBegin("completeSessionMFA", thrift.EXCEPTION, seqId)
+		x.Write(oprot)
+		oprot.WriteMessageEnd()
+		oprot.Flush()
+		return false, err
+	}
+
+	iprot.ReadMessageEnd()
+	result := CompleteSessionMFAResult{}
+	var retval *Session
+	var err2 error
+	if retval, err2 = p.handler.CompleteSessionMFA(args.ChallengeID, args.Code, args.UserAgent, args.RemoteAddr); err2 != nil {
+		switch v := err2.(type) {
+		case *ServerError:
+			result.Error1 = v
+		case *BadRequestError:
+			result.Error2 = v
+		case *UnauthorizedError:
+			result.Error3 = v
+		case *ForbiddenError:
+			result.Error4 = v
+		case *NotFoundError:
+			result.Error5 = v
+		default:
+			x := thrift.NewTApplicationException(thrift.INTERNAL_ERROR, "Internal error processing completeSessionMFA: "+err2.Error())
+			oprot.WriteMessageBegin("completeSessionMFA", thrift.EXCEPTION, seqId)
+			x.Write(oprot)
+			oprot.WriteMessageEnd()
+			oprot.Flush()
+			return true, err2
+		}
+	} else {
+		result.Success = retval
+	}
+	if err2 = oprot.WriteMessageBegin("completeSessionMFA", thrift.REPLY, seqId); err2 != nil {
+		err = err2
+	}
+	if err2 = result.Write(oprot); err == nil && err2 != nil {
+		err = err2
+	}
+	if err2 = oprot.WriteMessageEnd(); err == nil && err2 != nil {
+		err = err2
+	}
+	if err2 = oprot.Flush(); err == nil && err2 != nil {
+		err = err2
+	}
+	if err != nil {
+		return
+	}
+	return true, err
+}
+
+// HELPER FUNCTIONS AND STRUCTURES
+
+type identityProviderProcessorGetEffectivePermissions struct {
+	handler IdentityProvider
+}
+
+func (p *identityProviderProcessorGetEffectivePermissions) Process(seqId int32, iprot, oprot thrift.TProtocol) (success bool, err thrift.TException) {
+	args := GetEffectivePermissionsArgs{}
+	if err = args.Read(iprot); err != nil {
+		iprot.ReadMessageEnd()
+		x := thrift.NewTApplicationException(thrift.PROTOCOL_ERROR, err.Error())
+		oprot.WriteMessageBegin("getEffectivePermissions", thrift.EXCEPTION, seqId)
+		x.Write(oprot)
+		oprot.WriteMessageEnd()
+		oprot.Flush()
+		return false, err
+	}
+
+	iprot.ReadMessageEnd()
+	result := GetEffectivePermissionsResult{}
+	var retval []*Permission
+	var err2 error
+	if retval, err2 = p.handler.GetEffectivePermissions(args.SessionID); err2 != nil {
+		switch v := err2.(type) {
+		case *ServerError:
+			result.Error1 = v
+		case *BadRequestError:
+			result.Error2 = v
+		case *UnauthorizedError:
+			result.Error3 = v
+		case *ForbiddenError:
+			result.Error4 = v
+		case *NotFoundError:
+			result.Error5 = v
+		default:
+			x := thrift.NewTApplicationException(thrift.INTERNAL_ERROR, "Internal error processing getEffectivePermissions: "+err2.Error())
+			oprot.WriteMessageBegin("getEffectivePermissions", thrift.EXCEPTION, seqId)
+			x.Write(oprot)
+			oprot.WriteMessageEnd()
+			oprot.Flush()
+			return true, err2
+		}
+	} else {
+		result.Success = retval
+	}
+	if err2 = oprot.WriteMessageBegin("getEffectivePermissions", thrift.REPLY, seqId); err2 != nil {
+		err = err2
+	}
+	if err2 = result.Write(oprot); err == nil && err2 != nil {
+		err = err2
+	}
+	if err2 = oprot.WriteMessageEnd(); err == nil && err2 != nil {
+		err = err2
+	}
+	if err2 = oprot.Flush(); err == nil && err2 != nil {
+		err = err2
+	}
+	if err != nil {
+		return
+	}
+	return true, err
+}
+
+type CreateSessionArgs struct {
+	Domain     string `thrift:"domain,1" json:"domain"`
+	Name       string `thrift:"name,2" json:"name"`
+	Password   string `thrift:"password,3" json:"password"`
+	UserAgent  string `thrift:"userAgent,4" json:"userAgent"`
+	RemoteAddr string `thrift:"remoteAddr,5" json:"remoteAddr"`
+}
+
+func NewCreateSessionArgs() *CreateSessionArgs {
+	return &CreateSessionArgs{}
 }
 
 func (p *CreateSessionArgs) GetDomain() string {
@@ -3435,8 +3909,9 @@ func (p *AssertRoleResult) String() string {
 }
 
 type AssertPermissionArgs struct {
-	SessionID     string `thrift:"sessionID,1" json:"sessionID"`
-	PermissioName string `thrift:"permissioName,2" json:"permissioName"`
+	SessionID     string            `thrift:"sessionID,1" json:"sessionID"`
+	PermissioName string            `thrift:"permissioName,2" json:"permissioName"`
+	Attributes    map[string]string `thrift:"attributes,3" json:"attributes"`
 }
 
 func NewAssertPermissionArgs() *AssertPermissionArgs {
@@ -3450,6 +3925,10 @@ func (p *AssertPermissionArgs) GetSessionID() string {
 func (p *AssertPermissionArgs) GetPermissioName() string {
 	return p.PermissioName
 }
+
+func (p *AssertPermissionArgs) GetAttributes() map[string]string {
+	return p.Attributes
+}
 func (p *AssertPermissionArgs) Read(iprot thrift.TProtocol) error {
 	if _, err := iprot.ReadStructBegin(); err != nil {
 		return fmt.Errorf("%T read error: %s", p, err)
@@ -3471,6 +3950,10 @@ func (p *AssertPermissionArgs) Read(iprot thrift.TProtocol) error {
 			if err := p.ReadField2(iprot); err != nil {
 				return err
 			}
+		case 3:
+			if err := p.ReadField3(iprot); err != nil {
+				return err
+			}
 		default:
 			if err := iprot.Skip(fieldTypeId); err != nil {
 				return err
@@ -3504,6 +3987,34 @@ func (p *AssertPermissionArgs) ReadField2(iprot thrift.TProtocol) error {
 	return nil
 }
 
+func (p *AssertPermissionArgs) ReadField3(iprot thrift.TProtocol) error {
+	_, _, size, err := iprot.ReadMapBegin()
+	if err != nil {
+		return fmt.Errorf("error reading map begin: %s", err)
+	}
+	tMap := make(map[string]string, size)
+	p.Attributes = tMap
+	for i := 0; i < size; i++ {
+		var _key15 string
+		if v, err := iprot.ReadString(); err != nil {
+			return fmt.Errorf("error reading field 0: %s", err)
+		} else {
+			_key15 = v
+		}
+		var _val16 string
+		if v, err := iprot.ReadString(); err != nil {
+			return fmt.Errorf("error reading field 0: %s", err)
+		} else {
+			_val16 = v
+		}
+		p.Attributes[_key15] = _val16
+	}
+	if err := iprot.ReadMapEnd(); err != nil {
+		return fmt.Errorf("error reading map end: %s", err)
+	}
+	return nil
+}
+
 func (p *AssertPermissionArgs) Write(oprot thrift.TProtocol) error {
 	if err := oprot.WriteStructBegin("assertPermission_args"); err != nil {
 		return fmt.Errorf("%T write struct begin error: %s", p, err)
@@ -3514,6 +4025,9 @@ func (p *AssertPermissionArgs) Write(oprot thrift.TProtocol) error {
 	if err := p.writeField2(oprot); err != nil {
 		return err
 	}
+	if err := p.writeField3(oprot); err != nil {
+		return err
+	}
 	if err := oprot.WriteFieldStop(); err != nil {
 		return fmt.Errorf("write field stop error: %s", err)
 	}
@@ -3549,6 +4063,30 @@ func (p *AssertPermissionArgs) writeField2(oprot thrift.TProtocol) (err error) {
 	return err
 }
 
+func (p *AssertPermissionArgs) writeField3(oprot thrift.TProtocol) (err error) {
+	if err := oprot.WriteFieldBegin("attributes", thrift.MAP, 3); err != nil {
+		return fmt.Errorf("%T write field begin error 3:attributes: %s", p, err)
+	}
+	if err := oprot.WriteMapBegin(thrift.STRING, thrift.STRING, len(p.Attributes)); err != nil {
+		return fmt.Errorf("error writing map begin: %s", err)
+	}
+	for k, v := range p.Attributes {
+		if err := oprot.WriteString(string(k)); err != nil {
+			return fmt.Errorf("%T. (0) field write error: %s", p, err)
+		}
+		if err := oprot.WriteString(string(v)); err != nil {
+			return fmt.Errorf("%T. (0) field write error: %s", p, err)
+		}
+	}
+	if err := oprot.WriteMapEnd(); err != nil {
+		return fmt.Errorf("error writing map end: %s", err)
+	}
+	if err := oprot.WriteFieldEnd(); err != nil {
+		return fmt.Errorf("%T write field end error 3:attributes: %s", p, err)
+	}
+	return err
+}
+
 func (p *AssertPermissionArgs) String() string {
 	if p == nil {
 		return "<nil>"
@@ -3874,3 +4412,1477 @@ func (p *AssertPermissionResult) String() string {
 	}
 	return fmt.Sprintf("AssertPermissionResult(%+v)", *p)
 }
+
+type GetEffectivePermissionsArgs struct {
+	SessionID string `thrift:"sessionID,1" json:"sessionID"`
+}
+
+func NewGetEffectivePermissionsArgs() *GetEffectivePermissionsArgs {
+	return &GetEffectivePermissionsArgs{}
+}
+
+func (p *GetEffectivePermissionsArgs) GetSessionID() string {
+	return p.SessionID
+}
+
+func (p *GetEffectivePermissionsArgs) Read(iprot thrift.TProtocol) error {
+	if _, err := iprot.ReadStructBegin(); err != nil {
+		return fmt.Errorf("%T read error: %s", p, err)
+	}
+	for {
+		_, fieldTypeId, fieldId, err := iprot.ReadFieldBegin()
+		if err != nil {
+			return fmt.Errorf("%T field %d read error: %s", p, fieldId, err)
+		}
+		if fieldTypeId == thrift.STOP {
+			break
+		}
+		switch fieldId {
+		case 1:
+			if err := p.ReadField1(iprot); err != nil {
+				return err
+			}
+		default:
+			if err := iprot.Skip(fieldTypeId); err != nil {
+				return err
+			}
+		}
+		if err := iprot.ReadFieldEnd(); err != nil {
+			return err
+		}
+	}
+	if err := iprot.ReadStructEnd(); err != nil {
+		return fmt.Errorf("%T read struct end error: %s", p, err)
+	}
+	return nil
+}
+
+func (p *GetEffectivePermissionsArgs) ReadField1(iprot thrift.TProtocol) error {
+	if v, err := iprot.ReadString(); err != nil {
+		return fmt.Errorf("error reading field 1: %s", err)
+	} else {
+		p.SessionID = v
+	}
+	return nil
+}
+
+func (p *GetEffectivePermissionsArgs) Write(oprot thrift.TProtocol) error {
+	if err := oprot.WriteStructBegin("getEffectivePermissions_args"); err != nil {
+		return fmt.Errorf("%T write struct begin error: %s", p, err)
+	}
+	if err := p.writeField1(oprot); err != nil {
+		return err
+	}
+	if err := oprot.WriteFieldStop(); err != nil {
+		return fmt.Errorf("write field stop error: %s", err)
+	}
+	if err := oprot.WriteStructEnd(); err != nil {
+		return fmt.Errorf("write struct stop error: %s", err)
+	}
+	return nil
+}
+
+func (p *GetEffectivePermissionsArgs) writeField1(oprot thrift.TProtocol) (err error) {
+	if err := oprot.WriteFieldBegin("sessionID", thrift.STRING, 1); err != nil {
+		return fmt.Errorf("%T write field begin error 1:sessionID: %s", p, err)
+	}
+	if err := oprot.WriteString(string(p.SessionID)); err != nil {
+		return fmt.Errorf("%T.sessionID (1) field write error: %s", p, err)
+	}
+	if err := oprot.WriteFieldEnd(); err != nil {
+		return fmt.Errorf("%T write field end error 1:sessionID: %s", p, err)
+	}
+	return err
+}
+
+func (p *GetEffectivePermissionsArgs) String() string {
+	if p == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("GetEffectivePermissionsArgs(%+v)", *p)
+}
+
+type GetEffectivePermissionsResult struct {
+	Success []*Permission      `thrift:"success,0" json:"success"`
+	Error1  *ServerError       `thrift:"error1,1" json:"error1"`
+	Error2  *BadRequestError   `thrift:"error2,2" json:"error2"`
+	Error3  *UnauthorizedError `thrift:"error3,3" json:"error3"`
+	Error4  *ForbiddenError    `thrift:"error4,4" json:"error4"`
+	Error5  *NotFoundError     `thrift:"error5,5" json:"error5"`
+}
+
+func NewGetEffectivePermissionsResult() *GetEffectivePermissionsResult {
+	return &GetEffectivePermissionsResult{}
+}
+
+var GetEffectivePermissionsResult_Success_DEFAULT []*Permission
+
+func (p *GetEffectivePermissionsResult) GetSuccess() []*Permission {
+	return p.Success
+}
+
+var GetEffectivePermissionsResult_Error1_DEFAULT *ServerError
+
+func (p *GetEffectivePermissionsResult) GetError1() *ServerError {
+	if !p.IsSetError1() {
+		return GetEffectivePermissionsResult_Error1_DEFAULT
+	}
+	return p.Error1
+}
+
+var GetEffectivePermissionsResult_Error2_DEFAULT *BadRequestError
+
+func (p *GetEffectivePermissionsResult) GetError2() *BadRequestError {
+	if !p.IsSetError2() {
+		return GetEffectivePermissionsResult_Error2_DEFAULT
+	}
+	return p.Error2
+}
+
+var GetEffectivePermissionsResult_Error3_DEFAULT *UnauthorizedError
+
+func (p *GetEffectivePermissionsResult) GetError3() *UnauthorizedError {
+	if !p.IsSetError3() {
+		return GetEffectivePermissionsResult_Error3_DEFAULT
+	}
+	return p.Error3
+}
+
+var GetEffectivePermissionsResult_Error4_DEFAULT *ForbiddenError
+
+func (p *GetEffectivePermissionsResult) GetError4() *ForbiddenError {
+	if !p.IsSetError4() {
+		return GetEffectivePermissionsResult_Error4_DEFAULT
+	}
+	return p.Error4
+}
+
+var GetEffectivePermissionsResult_Error5_DEFAULT *NotFoundError
+
+func (p *GetEffectivePermissionsResult) GetError5() *NotFoundError {
+	if !p.IsSetError5() {
+		return GetEffectivePermissionsResult_Error5_DEFAULT
+	}
+	return p.Error5
+}
+func (p *GetEffectivePermissionsResult) IsSetSuccess() bool {
+	return p.Success != nil
+}
+
+func (p *GetEffectivePermissionsResult) IsSetError1() bool {
+	return p.Error1 != nil
+}
+
+func (p *GetEffectivePermissionsResult) IsSetError2() bool {
+	return p.Error2 != nil
+}
+
+func (p *GetEffectivePermissionsResult) IsSetError3() bool {
+	return p.Error3 != nil
+}
+
+func (p *GetEffectivePermissionsResult) IsSetError4() bool {
+	return p.Error4 != nil
+}
+
+func (p *GetEffectivePermissionsResult) IsSetError5() bool {
+	return p.Error5 != nil
+}
+
+func (p *GetEffectivePermissionsResult) Read(iprot thrift.TProtocol) error {
+	if _, err := iprot.ReadStructBegin(); err != nil {
+		return fmt.Errorf("%T read error: %s", p, err)
+	}
+	for {
+		_, fieldTypeId, fieldId, err := iprot.ReadFieldBegin()
+		if err != nil {
+			return fmt.Errorf("%T field %d read error: %s", p, fieldId, err)
+		}
+		if fieldTypeId == thrift.STOP {
+			break
+		}
+		switch fieldId {
+		case 0:
+			if err := p.ReadField0(iprot); err != nil {
+				return err
+			}
+		case 1:
+			if err := p.ReadField1(iprot); err != nil {
+				return err
+			}
+		case 2:
+			if err := p.ReadField2(iprot); err != nil {
+				return err
+			}
+		case 3:
+			if err := p.ReadField3(iprot); err != nil {
+				return err
+			}
+		case 4:
+			if err := p.ReadField4(iprot); err != nil {
+				return err
+			}
+		case 5:
+			if err := p.ReadField5(iprot); err != nil {
+				return err
+			}
+		default:
+			if err := iprot.Skip(fieldTypeId); err != nil {
+				return err
+			}
+		}
+		if err := iprot.ReadFieldEnd(); err != nil {
+			return err
+		}
+	}
+	if err := iprot.ReadStructEnd(); err != nil {
+		return fmt.Errorf("%T read struct end error: %s", p, err)
+	}
+	return nil
+}
+
+func (p *GetEffectivePermissionsResult) ReadField0(iprot thrift.TProtocol) error {
+	_, size, err := iprot.ReadListBegin()
+	if err != nil {
+		return fmt.Errorf("error reading list begin: %s", err)
+	}
+	tSlice := make([]*Permission, 0, size)
+	p.Success = tSlice
+	for i := 0; i < size; i++ {
+		_elem17 := &Permission{}
+		if err := _elem17.Read(iprot); err != nil {
+			return fmt.Errorf("%T error reading struct: %s", _elem17, err)
+		}
+		p.Success = append(p.Success, _elem17)
+	}
+	if err := iprot.ReadListEnd(); err != nil {
+		return fmt.Errorf("error reading list end: %s", err)
+	}
+	return nil
+}
+
+func (p *GetEffectivePermissionsResult) ReadField1(iprot thrift.TProtocol) error {
+	p.Error1 = &ServerError{}
+	if err := p.Error1.Read(iprot); err != nil {
+		return fmt.Errorf("%T error reading struct: %s", p.Error1, err)
+	}
+	return nil
+}
+
+func (p *GetEffectivePermissionsResult) ReadField2(iprot thrift.TProtocol) error {
+	p.Error2 = &BadRequestError{}
+	if err := p.Error2.Read(iprot); err != nil {
+		return fmt.Errorf("%T error reading struct: %s", p.Error2, err)
+	}
+	return nil
+}
+
+func (p *GetEffectivePermissionsResult) ReadField3(iprot thrift.TProtocol) error {
+	p.Error3 = &UnauthorizedError{}
+	if err := p.Error3.Read(iprot); err != nil {
+		return fmt.Errorf("%T error reading struct: %s", p.Error3, err)
+	}
+	return nil
+}
+
+func (p *GetEffectivePermissionsResult) ReadField4(iprot thrift.TProtocol) error {
+	p.Error4 = &ForbiddenError{}
+	if err := p.Error4.Read(iprot); err != nil {
+		return fmt.Errorf("%T error reading struct: %s", p.Error4, err)
+	}
+	return nil
+}
+
+func (p *GetEffectivePermissionsResult) ReadField5(iprot thrift.TProtocol) error {
+	p.Error5 = &NotFoundError{}
+	if err := p.Error5.Read(iprot); err != nil {
+		return fmt.Errorf("%T error reading struct: %s", p.Error5, err)
+	}
+	return nil
+}
+
+func (p *GetEffectivePermissionsResult) Write(oprot thrift.TProtocol) error {
+	if err := oprot.WriteStructBegin("getEffectivePermissions_result"); err != nil {
+		return fmt.Errorf("%T write struct begin error: %s", p, err)
+	}
+	if err := p.writeField0(oprot); err != nil {
+		return err
+	}
+	if err := p.writeField1(oprot); err != nil {
+		return err
+	}
+	if err := p.writeField2(oprot); err != nil {
+		return err
+	}
+	if err := p.writeField3(oprot); err != nil {
+		return err
+	}
+	if err := p.writeField4(oprot); err != nil {
+		return err
+	}
+	if err := p.writeField5(oprot); err != nil {
+		return err
+	}
+	if err := oprot.WriteFieldStop(); err != nil {
+		return fmt.Errorf("write field stop error: %s", err)
+	}
+	if err := oprot.WriteStructEnd(); err != nil {
+		return fmt.Errorf("write struct stop error: %s", err)
+	}
+	return nil
+}
+
+func (p *GetEffectivePermissionsResult) writeField0(oprot thrift.TProtocol) (err error) {
+	if p.IsSetSuccess() {
+		if err := oprot.WriteFieldBegin("success", thrift.LIST, 0); err != nil {
+			return fmt.Errorf("%T write field begin error 0:success: %s", p, err)
+		}
+		if err := oprot.WriteListBegin(thrift.STRUCT, len(p.Success)); err != nil {
+			return fmt.Errorf("error writing list begin: %s", err)
+		}
+		for _, v := range p.Success {
+			if err := v.Write(oprot); err != nil {
+				return fmt.Errorf("%T error writing struct: %s", v, err)
+			}
+		}
+		if err := oprot.WriteListEnd(); err != nil {
+			return fmt.Errorf("error writing list end: %s", err)
+		}
+		if err := oprot.WriteFieldEnd(); err != nil {
+			return fmt.Errorf("%T write field end error 0:success: %s", p, err)
+		}
+	}
+	return err
+}
+
+func (p *GetEffectivePermissionsResult) writeField1(oprot thrift.TProtocol) (err error) {
+	if p.IsSetError1() {
+		if err := oprot.WriteFieldBegin("error1", thrift.STRUCT, 1); err != nil {
+			return fmt.Errorf("%T write field begin error 1:error1: %s", p, err)
+		}
+		if err := p.Error1.Write(oprot); err != nil {
+			return fmt.Errorf("%T error writing struct: %s", p.Error1, err)
+		}
+		if err := oprot.WriteFieldEnd(); err != nil {
+			return fmt.Errorf("%T write field end error 1:error1: %s", p, err)
+		}
+	}
+	return err
+}
+
+func (p *GetEffectivePermissionsResult) writeField2(oprot thrift.TProtocol) (err error) {
+	if p.IsSetError2() {
+		if err := oprot.WriteFieldBegin("error2", thrift.STRUCT, 2); err != nil {
+			return fmt.Errorf("%T write field begin error 2:error2: %s", p, err)
+		}
+		if err := p.Error2.Write(oprot); err != nil {
+			return fmt.Errorf("%T error writing struct: %s", p.Error2, err)
+		}
+		if err := oprot.WriteFieldEnd(); err != nil {
+			return fmt.Errorf("%T write field end error 2:error2: %s", p, err)
+		}
+	}
+	return err
+}
+
+func (p *GetEffectivePermissionsResult) writeField3(oprot thrift.TProtocol) (err error) {
+	if p.IsSetError3() {
+		if err := oprot.WriteFieldBegin("error3", thrift.STRUCT, 3); err != nil {
+			return fmt.Errorf("%T write field begin error 3:error3: %s", p, err)
+		}
+		if err := p.Error3.Write(oprot); err != nil {
+			return fmt.Errorf("%T error writing struct: %s", p.Error3, err)
+		}
+		if err := oprot.WriteFieldEnd(); err != nil {
+			return fmt.Errorf("%T write field end error 3:error3: %s", p, err)
+		}
+	}
+	return err
+}
+
+func (p *GetEffectivePermissionsResult) writeField4(oprot thrift.TProtocol) (err error) {
+	if p.IsSetError4() {
+		if err := oprot.WriteFieldBegin("error4", thrift.STRUCT, 4); err != nil {
+			return fmt.Errorf("%T write field begin error 4:error4: %s", p, err)
+		}
+		if err := p.Error4.Write(oprot); err != nil {
+			return fmt.Errorf("%T error writing struct: %s", p.Error4, err)
+		}
+		if err := oprot.WriteFieldEnd(); err != nil {
+			return fmt.Errorf("%T write field end error 4:error4: %s", p, err)
+		}
+	}
+	return err
+}
+
+func (p *GetEffectivePermissionsResult) writeField5(oprot thrift.TProtocol) (err error) {
+	if p.IsSetError5() {
+		if err := oprot.WriteFieldBegin("error5", thrift.STRUCT, 5); err != nil {
+			return fmt.Errorf("%T write field begin error 5:error5: %s", p, err)
+		}
+		if err := p.Error5.Write(oprot); err != nil {
+			return fmt.Errorf("%T error writing struct: %s", p.Error5, err)
+		}
+		if err := oprot.WriteFieldEnd(); err != nil {
+			return fmt.Errorf("%T write field end error 5:error5: %s", p, err)
+		}
+	}
+	return err
+}
+
+func (p *GetEffectivePermissionsResult) String() string {
+	if p == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("GetEffectivePermissionsResult(%+v)", *p)
+}
+
+type BeginSessionArgs struct {
+	Domain     string `thrift:"domain,1" json:"domain"`
+	Name       string `thrift:"name,2" json:"name"`
+	Password   string `thrift:"password,3" json:"password"`
+	UserAgent  string `thrift:"userAgent,4" json:"userAgent"`
+	RemoteAddr string `thrift:"remoteAddr,5" json:"remoteAddr"`
+}
+
+func NewBeginSessionArgs() *BeginSessionArgs {
+	return &BeginSessionArgs{}
+}
+
+func (p *BeginSessionArgs) GetDomain() string {
+	return p.Domain
+}
+
+func (p *BeginSessionArgs) GetName() string {
+	return p.Name
+}
+
+func (p *BeginSessionArgs) GetPassword() string {
+	return p.Password
+}
+
+func (p *BeginSessionArgs) GetUserAgent() string {
+	return p.UserAgent
+}
+
+func (p *BeginSessionArgs) GetRemoteAddr() string {
+	return p.RemoteAddr
+}
+func (p *BeginSessionArgs) Read(iprot thrift.TProtocol) error {
+	if _, err := iprot.ReadStructBegin(); err != nil {
+		return fmt.Errorf("%T read error: %s", p, err)
+	}
+	for {
+		_, fieldTypeId, fieldId, err := iprot.ReadFieldBegin()
+		if err != nil {
+			return fmt.Errorf("%T field %d read error: %s", p, fieldId, err)
+		}
+		if fieldTypeId == thrift.STOP {
+			break
+		}
+		switch fieldId {
+		case 1:
+			if err := p.ReadField1(iprot); err != nil {
+				return err
+			}
+		case 2:
+			if err := p.ReadField2(iprot); err != nil {
+				return err
+			}
+		case 3:
+			if err := p.ReadField3(iprot); err != nil {
+				return err
+			}
+		case 4:
+			if err := p.ReadField4(iprot); err != nil {
+				return err
+			}
+		case 5:
+			if err := p.ReadField5(iprot); err != nil {
+				return err
+			}
+		default:
+			if err := iprot.Skip(fieldTypeId); err != nil {
+				return err
+			}
+		}
+		if err := iprot.ReadFieldEnd(); err != nil {
+			return err
+		}
+	}
+	if err := iprot.ReadStructEnd(); err != nil {
+		return fmt.Errorf("%T read struct end error: %s", p, err)
+	}
+	return nil
+}
+
+func (p *BeginSessionArgs) ReadField1(iprot thrift.TProtocol) error {
+	if v, err := iprot.ReadString(); err != nil {
+		return fmt.Errorf("error reading field 1: %s", err)
+	} else {
+		p.Domain = v
+	}
+	return nil
+}
+
+func (p *BeginSessionArgs) ReadField2(iprot thrift.TProtocol) error {
+	if v, err := iprot.ReadString(); err != nil {
+		return fmt.Errorf("error reading field 2: %s", err)
+	} else {
+		p.Name = v
+	}
+	return nil
+}
+
+func (p *BeginSessionArgs) ReadField3(iprot thrift.TProtocol) error {
+	if v, err := iprot.ReadString(); err != nil {
+		return fmt.Errorf("error reading field 3: %s", err)
+	} else {
+		p.Password = v
+	}
+	return nil
+}
+
+func (p *BeginSessionArgs) ReadField4(iprot thrift.TProtocol) error {
+	if v, err := iprot.ReadString(); err != nil {
+		return fmt.Errorf("error reading field 4: %s", err)
+	} else {
+		p.UserAgent = v
+	}
+	return nil
+}
+
+func (p *BeginSessionArgs) ReadField5(iprot thrift.TProtocol) error {
+	if v, err := iprot.ReadString(); err != nil {
+		return fmt.Errorf("error reading field 5: %s", err)
+	} else {
+		p.RemoteAddr = v
+	}
+	return nil
+}
+
+func (p *BeginSessionArgs) Write(oprot thrift.TProtocol) error {
+	if err := oprot.WriteStructBegin("beginSession_args"); err != nil {
+		return fmt.Errorf("%T write struct begin error: %s", p, err)
+	}
+	if err := p.writeField1(oprot); err != nil {
+		return err
+	}
+	if err := p.writeField2(oprot); err != nil {
+		return err
+	}
+	if err := p.writeField3(oprot); err != nil {
+		return err
+	}
+	if err := p.writeField4(oprot); err != nil {
+		return err
+	}
+	if err := p.writeField5(oprot); err != nil {
+		return err
+	}
+	if err := oprot.WriteFieldStop(); err != nil {
+		return fmt.Errorf("write field stop error: %s", err)
+	}
+	if err := oprot.WriteStructEnd(); err != nil {
+		return fmt.Errorf("write struct stop error: %s", err)
+	}
+	return nil
+}
+
+func (p *BeginSessionArgs) writeField1(oprot thrift.TProtocol) (err error) {
+	if err := oprot.WriteFieldBegin("domain", thrift.STRING, 1); err != nil {
+		return fmt.Errorf("%T write field begin error 1:domain: %s", p, err)
+	}
+	if err := oprot.WriteString(string(p.Domain)); err != nil {
+		return fmt.Errorf("%T.domain (1) field write error: %s", p, err)
+	}
+	if err := oprot.WriteFieldEnd(); err != nil {
+		return fmt.Errorf("%T write field end error 1:domain: %s", p, err)
+	}
+	return err
+}
+
+func (p *BeginSessionArgs) writeField2(oprot thrift.TProtocol) (err error) {
+	if err := oprot.WriteFieldBegin("name", thrift.STRING, 2); err != nil {
+		return fmt.Errorf("%T write field begin error 2:name: %s", p, err)
+	}
+	if err := oprot.WriteString(string(p.Name)); err != nil {
+		return fmt.Errorf("%T.name (2) field write error: %s", p, err)
+	}
+	if err := oprot.WriteFieldEnd(); err != nil {
+		return fmt.Errorf("%T write field end error 2:name: %s", p, err)
+	}
+	return err
+}
+
+func (p *BeginSessionArgs) writeField3(oprot thrift.TProtocol) (err error) {
+	if err := oprot.WriteFieldBegin("password", thrift.STRING, 3); err != nil {
+		return fmt.Errorf("%T write field begin error 3:password: %s", p, err)
+	}
+	if err := oprot.WriteString(string(p.Password)); err != nil {
+		return fmt.Errorf("%T.password (3) field write error: %s", p, err)
+	}
+	if err := oprot.WriteFieldEnd(); err != nil {
+		return fmt.Errorf("%T write field end error 3:password: %s", p, err)
+	}
+	return err
+}
+
+func (p *BeginSessionArgs) writeField4(oprot thrift.TProtocol) (err error) {
+	if err := oprot.WriteFieldBegin("userAgent", thrift.STRING, 4); err != nil {
+		return fmt.Errorf("%T write field begin error 4:userAgent: %s", p, err)
+	}
+	if err := oprot.WriteString(string(p.UserAgent)); err != nil {
+		return fmt.Errorf("%T.userAgent (4) field write error: %s", p, err)
+	}
+	if err := oprot.WriteFieldEnd(); err != nil {
+		return fmt.Errorf("%T write field end error 4:userAgent: %s", p, err)
+	}
+	return err
+}
+
+func (p *BeginSessionArgs) writeField5(oprot thrift.TProtocol) (err error) {
+	if err := oprot.WriteFieldBegin("remoteAddr", thrift.STRING, 5); err != nil {
+		return fmt.Errorf("%T write field begin error 5:remoteAddr: %s", p, err)
+	}
+	if err := oprot.WriteString(string(p.RemoteAddr)); err != nil {
+		return fmt.Errorf("%T.remoteAddr (5) field write error: %s", p, err)
+	}
+	if err := oprot.WriteFieldEnd(); err != nil {
+		return fmt.Errorf("%T write field end error 5:remoteAddr: %s", p, err)
+	}
+	return err
+}
+
+func (p *BeginSessionArgs) String() string {
+	if p == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("BeginSessionArgs(%+v)", *p)
+}
+
+type BeginSessionResult struct {
+	Success *SessionChallenge  `thrift:"success,0" json:"success"`
+	Error1  *ServerError       `thrift:"error1,1" json:"error1"`
+	Error2  *BadRequestError   `thrift:"error2,2" json:"error2"`
+	Error3  *UnauthorizedError `thrift:"error3,3" json:"error3"`
+	Error4  *ForbiddenError    `thrift:"error4,4" json:"error4"`
+	Error5  *NotFoundError     `thrift:"error5,5" json:"error5"`
+}
+
+func NewBeginSessionResult() *BeginSessionResult {
+	return &BeginSessionResult{}
+}
+
+var BeginSessionResult_Success_DEFAULT *SessionChallenge
+
+func (p *BeginSessionResult) GetSuccess() *SessionChallenge {
+	if !p.IsSetSuccess() {
+		return BeginSessionResult_Success_DEFAULT
+	}
+	return p.Success
+}
+
+var BeginSessionResult_Error1_DEFAULT *ServerError
+
+func (p *BeginSessionResult) GetError1() *ServerError {
+	if !p.IsSetError1() {
+		return BeginSessionResult_Error1_DEFAULT
+	}
+	return p.Error1
+}
+
+var BeginSessionResult_Error2_DEFAULT *BadRequestError
+
+func (p *BeginSessionResult) GetError2() *BadRequestError {
+	if !p.IsSetError2() {
+		return BeginSessionResult_Error2_DEFAULT
+	}
+	return p.Error2
+}
+
+var BeginSessionResult_Error3_DEFAULT *UnauthorizedError
+
+func (p *BeginSessionResult) GetError3() *UnauthorizedError {
+	if !p.IsSetError3() {
+		return BeginSessionResult_Error3_DEFAULT
+	}
+	return p.Error3
+}
+
+var BeginSessionResult_Error4_DEFAULT *ForbiddenError
+
+func (p *BeginSessionResult) GetError4() *ForbiddenError {
+	if !p.IsSetError4() {
+		return BeginSessionResult_Error4_DEFAULT
+	}
+	return p.Error4
+}
+
+var BeginSessionResult_Error5_DEFAULT *NotFoundError
+
+func (p *BeginSessionResult) GetError5() *NotFoundError {
+	if !p.IsSetError5() {
+		return BeginSessionResult_Error5_DEFAULT
+	}
+	return p.Error5
+}
+func (p *BeginSessionResult) IsSetSuccess() bool {
+	return p.Success != nil
+}
+
+func (p *BeginSessionResult) IsSetError1() bool {
+	return p.Error1 != nil
+}
+
+func (p *BeginSessionResult) IsSetError2() bool {
+	return p.Error2 != nil
+}
+
+func (p *BeginSessionResult) IsSetError3() bool {
+	return p.Error3 != nil
+}
+
+func (p *BeginSessionResult) IsSetError4() bool {
+	return p.Error4 != nil
+}
+
+func (p *BeginSessionResult) IsSetError5() bool {
+	return p.Error5 != nil
+}
+
+func (p *BeginSessionResult) Read(iprot thrift.TProtocol) error {
+	if _, err := iprot.ReadStructBegin(); err != nil {
+		return fmt.Errorf("%T read error: %s", p, err)
+	}
+	for {
+		_, fieldTypeId, fieldId, err := iprot.ReadFieldBegin()
+		if err != nil {
+			return fmt.Errorf("%T field %d read error: %s", p, fieldId, err)
+		}
+		if fieldTypeId == thrift.STOP {
+			break
+		}
+		switch fieldId {
+		case 0:
+			if err := p.ReadField0(iprot); err != nil {
+				return err
+			}
+		case 1:
+			if err := p.ReadField1(iprot); err != nil {
+				return err
+			}
+		case 2:
+			if err := p.ReadField2(iprot); err != nil {
+				return err
+			}
+		case 3:
+			if err := p.ReadField3(iprot); err != nil {
+				return err
+			}
+		case 4:
+			if err := p.ReadField4(iprot); err != nil {
+				return err
+			}
+		case 5:
+			if err := p.ReadField5(iprot); err != nil {
+				return err
+			}
+		default:
+			if err := iprot.Skip(fieldTypeId); err != nil {
+				return err
+			}
+		}
+		if err := iprot.ReadFieldEnd(); err != nil {
+			return err
+		}
+	}
+	if err := iprot.ReadStructEnd(); err != nil {
+		return fmt.Errorf("%T read struct end error: %s", p, err)
+	}
+	return nil
+}
+
+func (p *BeginSessionResult) ReadField0(iprot thrift.TProtocol) error {
+	p.Success = &SessionChallenge{}
+	if err := p.Success.Read(iprot); err != nil {
+		return fmt.Errorf("%T error reading struct: %s", p.Success, err)
+	}
+	return nil
+}
+
+func (p *BeginSessionResult) ReadField1(iprot thrift.TProtocol) error {
+	p.Error1 = &ServerError{}
+	if err := p.Error1.Read(iprot); err != nil {
+		return fmt.Errorf("%T error reading struct: %s", p.Error1, err)
+	}
+	return nil
+}
+
+func (p *BeginSessionResult) ReadField2(iprot thrift.TProtocol) error {
+	p.Error2 = &BadRequestError{}
+	if err := p.Error2.Read(iprot); err != nil {
+		return fmt.Errorf("%T error reading struct: %s", p.Error2, err)
+	}
+	return nil
+}
+
+func (p *BeginSessionResult) ReadField3(iprot thrift.TProtocol) error {
+	p.Error3 = &UnauthorizedError{}
+	if err := p.Error3.Read(iprot); err != nil {
+		return fmt.Errorf("%T error reading struct: %s", p.Error3, err)
+	}
+	return nil
+}
+
+func (p *BeginSessionResult) ReadField4(iprot thrift.TProtocol) error {
+	p.Error4 = &ForbiddenError{}
+	if err := p.Error4.Read(iprot); err != nil {
+		return fmt.Errorf("%T error reading struct: %s", p.Error4, err)
+	}
+	return nil
+}
+
+func (p *BeginSessionResult) ReadField5(iprot thrift.TProtocol) error {
+	p.Error5 = &NotFoundError{}
+	if err := p.Error5.Read(iprot); err != nil {
+		return fmt.Errorf("%T error reading struct: %s", p.Error5, err)
+	}
+	return nil
+}
+
+func (p *BeginSessionResult) Write(oprot thrift.TProtocol) error {
+	if err := oprot.WriteStructBegin("beginSession_result"); err != nil {
+		return fmt.Errorf("%T write struct begin error: %s", p, err)
+	}
+	if err := p.writeField0(oprot); err != nil {
+		return err
+	}
+	if err := p.writeField1(oprot); err != nil {
+		return err
+	}
+	if err := p.writeField2(oprot); err != nil {
+		return err
+	}
+	if err := p.writeField3(oprot); err != nil {
+		return err
+	}
+	if err := p.writeField4(oprot); err != nil {
+		return err
+	}
+	if err := p.writeField5(oprot); err != nil {
+		return err
+	}
+	if err := oprot.WriteFieldStop(); err != nil {
+		return fmt.Errorf("write field stop error: %s", err)
+	}
+	if err := oprot.WriteStructEnd(); err != nil {
+		return fmt.Errorf("write struct stop error: %s", err)
+	}
+	return nil
+}
+
+func (p *BeginSessionResult) writeField0(oprot thrift.TProtocol) (err error) {
+	if p.IsSetSuccess() {
+		if err := oprot.WriteFieldBegin("success", thrift.STRUCT, 0); err != nil {
+			return fmt.Errorf("%T write field begin error 0:success: %s", p, err)
+		}
+		if err := p.Success.Write(oprot); err != nil {
+			return fmt.Errorf("%T error writing struct: %s", p.Success, err)
+		}
+		if err := oprot.WriteFieldEnd(); err != nil {
+			return fmt.Errorf("%T write field end error 0:success: %s", p, err)
+		}
+	}
+	return err
+}
+
+func (p *BeginSessionResult) writeField1(oprot thrift.TProtocol) (err error) {
+	if p.IsSetError1() {
+		if err := oprot.WriteFieldBegin("error1", thrift.STRUCT, 1); err != nil {
+			return fmt.Errorf("%T write field begin error 1:error1: %s", p, err)
+		}
+		if err := p.Error1.Write(oprot); err != nil {
+			return fmt.Errorf("%T error writing struct: %s", p.Error1, err)
+		}
+		if err := oprot.WriteFieldEnd(); err != nil {
+			return fmt.Errorf("%T write field end error 1:error1: %s", p, err)
+		}
+	}
+	return err
+}
+
+func (p *BeginSessionResult) writeField2(oprot thrift.TProtocol) (err error) {
+	if p.IsSetError2() {
+		if err := oprot.WriteFieldBegin("error2", thrift.STRUCT, 2); err != nil {
+			return fmt.Errorf("%T write field begin error 2:error2: %s", p, err)
+		}
+		if err := p.Error2.Write(oprot); err != nil {
+			return fmt.Errorf("%T error writing struct: %s", p.Error2, err)
+		}
+		if err := oprot.WriteFieldEnd(); err != nil {
+			return fmt.Errorf("%T write field end error 2:error2: %s", p, err)
+		}
+	}
+	return err
+}
+
+func (p *BeginSessionResult) writeField3(oprot thrift.TProtocol) (err error) {
+	if p.IsSetError3() {
+		if err := oprot.WriteFieldBegin("error3", thrift.STRUCT, 3); err != nil {
+			return fmt.Errorf("%T write field begin error 3:error3: %s", p, err)
+		}
+		if err := p.Error3.Write(oprot); err != nil {
+			return fmt.Errorf("%T error writing struct: %s", p.Error3, err)
+		}
+		if err := oprot.WriteFieldEnd(); err != nil {
+			return fmt.Errorf("%T write field end error 3:error3: %s", p, err)
+		}
+	}
+	return err
+}
+
+func (p *BeginSessionResult) writeField4(oprot thrift.TProtocol) (err error) {
+	if p.IsSetError4() {
+		if err := oprot.WriteFieldBegin("error4", thrift.STRUCT, 4); err != nil {
+			return fmt.Errorf("%T write field begin error 4:error4: %s", p, err)
+		}
+		if err := p.Error4.Write(oprot); err != nil {
+			return fmt.Errorf("%T error writing struct: %s", p.Error4, err)
+		}
+		if err := oprot.WriteFieldEnd(); err != nil {
+			return fmt.Errorf("%T write field end error 4:error4: %s", p, err)
+		}
+	}
+	return err
+}
+
+func (p *BeginSessionResult) writeField5(oprot thrift.TProtocol) (err error) {
+	if p.IsSetError5() {
+		if err := oprot.WriteFieldBegin("error5", thrift.STRUCT, 5); err != nil {
+			return fmt.Errorf("%T write field begin error 5:error5: %s", p, err)
+		}
+		if err := p.Error5.Write(oprot); err != nil {
+			return fmt.Errorf("%T error writing struct: %s", p.Error5, err)
+		}
+		if err := oprot.WriteFieldEnd(); err != nil {
+			return fmt.Errorf("%T write field end error 5:error5: %s", p, err)
+		}
+	}
+	return err
+}
+
+func (p *BeginSessionResult) String() string {
+	if p == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("BeginSessionResult(%+v)", *p)
+}
+
+type CompleteSessionMFAArgs struct {
+	ChallengeID string `thrift:"challengeID,1" json:"challengeID"`
+	Code        string `thrift:"code,2" json:"code"`
+	UserAgent   string `thrift:"userAgent,3" json:"userAgent"`
+	RemoteAddr  string `thrift:"remoteAddr,4" json:"remoteAddr"`
+}
+
+func NewCompleteSessionMFAArgs() *CompleteSessionMFAArgs {
+	return &CompleteSessionMFAArgs{}
+}
+
+func (p *CompleteSessionMFAArgs) GetChallengeID() string {
+	return p.ChallengeID
+}
+
+func (p *CompleteSessionMFAArgs) GetCode() string {
+	return p.Code
+}
+
+func (p *CompleteSessionMFAArgs) GetUserAgent() string {
+	return p.UserAgent
+}
+
+func (p *CompleteSessionMFAArgs) GetRemoteAddr() string {
+	return p.RemoteAddr
+}
+func (p *CompleteSessionMFAArgs) Read(iprot thrift.TProtocol) error {
+	if _, err := iprot.ReadStructBegin(); err != nil {
+		return fmt.Errorf("%T read error: %s", p, err)
+	}
+	for {
+		_, fieldTypeId, fieldId, err := iprot.ReadFieldBegin()
+		if err != nil {
+			return fmt.Errorf("%T field %d read error: %s", p, fieldId, err)
+		}
+		if fieldTypeId == thrift.STOP {
+			break
+		}
+		switch fieldId {
+		case 1:
+			if err := p.ReadField1(iprot); err != nil {
+				return err
+			}
+		case 2:
+			if err := p.ReadField2(iprot); err != nil {
+				return err
+			}
+		case 3:
+			if err := p.ReadField3(iprot); err != nil {
+				return err
+			}
+		case 4:
+			if err := p.ReadField4(iprot); err != nil {
+				return err
+			}
+		default:
+			if err := iprot.Skip(fieldTypeId); err != nil {
+				return err
+			}
+		}
+		if err := iprot.ReadFieldEnd(); err != nil {
+			return err
+		}
+	}
+	if err := iprot.ReadStructEnd(); err != nil {
+		return fmt.Errorf("%T read struct end error: %s", p, err)
+	}
+	return nil
+}
+
+func (p *CompleteSessionMFAArgs) ReadField1(iprot thrift.TProtocol) error {
+	if v, err := iprot.ReadString(); err != nil {
+		return fmt.Errorf("error reading field 1: %s", err)
+	} else {
+		p.ChallengeID = v
+	}
+	return nil
+}
+
+func (p *CompleteSessionMFAArgs) ReadField2(iprot thrift.TProtocol) error {
+	if v, err := iprot.ReadString(); err != nil {
+		return fmt.Errorf("error reading field 2: %s", err)
+	} else {
+		p.Code = v
+	}
+	return nil
+}
+
+func (p *CompleteSessionMFAArgs) ReadField3(iprot thrift.TProtocol) error {
+	if v, err := iprot.ReadString(); err != nil {
+		return fmt.Errorf("error reading field 3: %s", err)
+	} else {
+		p.UserAgent = v
+	}
+	return nil
+}
+
+func (p *CompleteSessionMFAArgs) ReadField4(iprot thrift.TProtocol) error {
+	if v, err := iprot.ReadString(); err != nil {
+		return fmt.Errorf("error reading field 4: %s", err)
+	} else {
+		p.RemoteAddr = v
+	}
+	return nil
+}
+
+func (p *CompleteSessionMFAArgs) Write(oprot thrift.TProtocol) error {
+	if err := oprot.WriteStructBegin("completeSessionMFA_args"); err != nil {
+		return fmt.Errorf("%T write struct begin error: %s", p, err)
+	}
+	if err := p.writeField1(oprot); err != nil {
+		return err
+	}
+	if err := p.writeField2(oprot); err != nil {
+		return err
+	}
+	if err := p.writeField3(oprot); err != nil {
+		return err
+	}
+	if err := p.writeField4(oprot); err != nil {
+		return err
+	}
+	if err := oprot.WriteFieldStop(); err != nil {
+		return fmt.Errorf("write field stop error: %s", err)
+	}
+	if err := oprot.WriteStructEnd(); err != nil {
+		return fmt.Errorf("write struct stop error: %s", err)
+	}
+	return nil
+}
+
+func (p *CompleteSessionMFAArgs) writeField1(oprot thrift.TProtocol) (err error) {
+	if err := oprot.WriteFieldBegin("challengeID", thrift.STRING, 1); err != nil {
+		return fmt.Errorf("%T write field begin error 1:challengeID: %s", p, err)
+	}
+	if err := oprot.WriteString(string(p.ChallengeID)); err != nil {
+		return fmt.Errorf("%T.challengeID (1) field write error: %s", p, err)
+	}
+	if err := oprot.WriteFieldEnd(); err != nil {
+		return fmt.Errorf("%T write field end error 1:challengeID: %s", p, err)
+	}
+	return err
+}
+
+func (p *CompleteSessionMFAArgs) writeField2(oprot thrift.TProtocol) (err error) {
+	if err := oprot.WriteFieldBegin("code", thrift.STRING, 2); err != nil {
+		return fmt.Errorf("%T write field begin error 2:code: %s", p, err)
+	}
+	if err := oprot.WriteString(string(p.Code)); err != nil {
+		return fmt.Errorf("%T.code (2) field write error: %s", p, err)
+	}
+	if err := oprot.WriteFieldEnd(); err != nil {
+		return fmt.Errorf("%T write field end error 2:code: %s", p, err)
+	}
+	return err
+}
+
+func (p *CompleteSessionMFAArgs) writeField3(oprot thrift.TProtocol) (err error) {
+	if err := oprot.WriteFieldBegin("userAgent", thrift.STRING, 3); err != nil {
+		return fmt.Errorf("%T write field begin error 3:userAgent: %s", p, err)
+	}
+	if err := oprot.WriteString(string(p.UserAgent)); err != nil {
+		return fmt.Errorf("%T.userAgent (3) field write error: %s", p, err)
+	}
+	if err := oprot.WriteFieldEnd(); err != nil {
+		return fmt.Errorf("%T write field end error 3:userAgent: %s", p, err)
+	}
+	return err
+}
+
+func (p *CompleteSessionMFAArgs) writeField4(oprot thrift.TProtocol) (err error) {
+	if err := oprot.WriteFieldBegin("remoteAddr", thrift.STRING, 4); err != nil {
+		return fmt.Errorf("%T write field begin error 4:remoteAddr: %s", p, err)
+	}
+	if err := oprot.WriteString(string(p.RemoteAddr)); err != nil {
+		return fmt.Errorf("%T.remoteAddr (4) field write error: %s", p, err)
+	}
+	if err := oprot.WriteFieldEnd(); err != nil {
+		return fmt.Errorf("%T write field end error 4:remoteAddr: %s", p, err)
+	}
+	return err
+}
+
+func (p *CompleteSessionMFAArgs) String() string {
+	if p == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("CompleteSessionMFAArgs(%+v)", *p)
+}
+
+type CompleteSessionMFAResult struct {
+	Success *Session           `thrift:"success,0" json:"success"`
+	Error1  *ServerError       `thrift:"error1,1" json:"error1"`
+	Error2  *BadRequestError   `thrift:"error2,2" json:"error2"`
+	Error3  *UnauthorizedError `thrift:"error3,3" json:"error3"`
+	Error4  *ForbiddenError    `thrift:"error4,4" json:"error4"`
+	Error5  *NotFoundError     `thrift:"error5,5" json:"error5"`
+}
+
+func NewCompleteSessionMFAResult() *CompleteSessionMFAResult {
+	return &CompleteSessionMFAResult{}
+}
+
+var CompleteSessionMFAResult_Success_DEFAULT *Session
+
+func (p *CompleteSessionMFAResult) GetSuccess() *Session {
+	if !p.IsSetSuccess() {
+		return CompleteSessionMFAResult_Success_DEFAULT
+	}
+	return p.Success
+}
+
+var CompleteSessionMFAResult_Error1_DEFAULT *ServerError
+
+func (p *CompleteSessionMFAResult) GetError1() *ServerError {
+	if !p.IsSetError1() {
+		return CompleteSessionMFAResult_Error1_DEFAULT
+	}
+	return p.Error1
+}
+
+var CompleteSessionMFAResult_Error2_DEFAULT *BadRequestError
+
+func (p *CompleteSessionMFAResult) GetError2() *BadRequestError {
+	if !p.IsSetError2() {
+		return CompleteSessionMFAResult_Error2_DEFAULT
+	}
+	return p.Error2
+}
+
+var CompleteSessionMFAResult_Error3_DEFAULT *UnauthorizedError
+
+func (p *CompleteSessionMFAResult) GetError3() *UnauthorizedError {
+	if !p.IsSetError3() {
+		return CompleteSessionMFAResult_Error3_DEFAULT
+	}
+	return p.Error3
+}
+
+var CompleteSessionMFAResult_Error4_DEFAULT *ForbiddenError
+
+func (p *CompleteSessionMFAResult) GetError4() *ForbiddenError {
+	if !p.IsSetError4() {
+		return CompleteSessionMFAResult_Error4_DEFAULT
+	}
+	return p.Error4
+}
+
+var CompleteSessionMFAResult_Error5_DEFAULT *NotFoundError
+
+func (p *CompleteSessionMFAResult) GetError5() *NotFoundError {
+	if !p.IsSetError5() {
+		return CompleteSessionMFAResult_Error5_DEFAULT
+	}
+	return p.Error5
+}
+func (p *CompleteSessionMFAResult) IsSetSuccess() bool {
+	return p.Success != nil
+}
+
+func (p *CompleteSessionMFAResult) IsSetError1() bool {
+	return p.Error1 != nil
+}
+
+func (p *CompleteSessionMFAResult) IsSetError2() bool {
+	return p.Error2 != nil
+}
+
+func (p *CompleteSessionMFAResult) IsSetError3() bool {
+	return p.Error3 != nil
+}
+
+func (p *CompleteSessionMFAResult) IsSetError4() bool {
+	return p.Error4 != nil
+}
+
+func (p *CompleteSessionMFAResult) IsSetError5() bool {
+	return p.Error5 != nil
+}
+
+func (p *CompleteSessionMFAResult) Read(iprot thrift.TProtocol) error {
+	if _, err := iprot.ReadStructBegin(); err != nil {
+		return fmt.Errorf("%T read error: %s", p, err)
+	}
+	for {
+		_, fieldTypeId, fieldId, err := iprot.ReadFieldBegin()
+		if err != nil {
+			return fmt.Errorf("%T field %d read error: %s", p, fieldId, err)
+		}
+		if fieldTypeId == thrift.STOP {
+			break
+		}
+		switch fieldId {
+		case 0:
+			if err := p.ReadField0(iprot); err != nil {
+				return err
+			}
+		case 1:
+			if err := p.ReadField1(iprot); err != nil {
+				return err
+			}
+		case 2:
+			if err := p.ReadField2(iprot); err != nil {
+				return err
+			}
+		case 3:
+			if err := p.ReadField3(iprot); err != nil {
+				return err
+			}
+		case 4:
+			if err := p.ReadField4(iprot); err != nil {
+				return err
+			}
+		case 5:
+			if err := p.ReadField5(iprot); err != nil {
+				return err
+			}
+		default:
+			if err := iprot.Skip(fieldTypeId); err != nil {
+				return err
+			}
+		}
+		if err := iprot.ReadFieldEnd(); err != nil {
+			return err
+		}
+	}
+	if err := iprot.ReadStructEnd(); err != nil {
+		return fmt.Errorf("%T read struct end error: %s", p, err)
+	}
+	return nil
+}
+
+func (p *CompleteSessionMFAResult) ReadField0(iprot thrift.TProtocol) error {
+	p.Success = &Session{}
+	if err := p.Success.Read(iprot); err != nil {
+		return fmt.Errorf("%T error reading struct: %s", p.Success, err)
+	}
+	return nil
+}
+
+func (p *CompleteSessionMFAResult) ReadField1(iprot thrift.TProtocol) error {
+	p.Error1 = &ServerError{}
+	if err := p.Error1.Read(iprot); err != nil {
+		return fmt.Errorf("%T error reading struct: %s", p.Error1, err)
+	}
+	return nil
+}
+
+func (p *CompleteSessionMFAResult) ReadField2(iprot thrift.TProtocol) error {
+	p.Error2 = &BadRequestError{}
+	if err := p.Error2.Read(iprot); err != nil {
+		return fmt.Errorf("%T error reading struct: %s", p.Error2, err)
+	}
+	return nil
+}
+
+func (p *CompleteSessionMFAResult) ReadField3(iprot thrift.TProtocol) error {
+	p.Error3 = &UnauthorizedError{}
+	if err := p.Error3.Read(iprot); err != nil {
+		return fmt.Errorf("%T error reading struct: %s", p.Error3, err)
+	}
+	return nil
+}
+
+func (p *CompleteSessionMFAResult) ReadField4(iprot thrift.TProtocol) error {
+	p.Error4 = &ForbiddenError{}
+	if err := p.Error4.Read(iprot); err != nil {
+		return fmt.Errorf("%T error reading struct: %s", p.Error4, err)
+	}
+	return nil
+}
+
+func (p *CompleteSessionMFAResult) ReadField5(iprot thrift.TProtocol) error {
+	p.Error5 = &NotFoundError{}
+	if err := p.Error5.Read(iprot); err != nil {
+		return fmt.Errorf("%T error reading struct: %s", p.Error5, err)
+	}
+	return nil
+}
+
+func (p *CompleteSessionMFAResult) Write(oprot thrift.TProtocol) error {
+	if err := oprot.WriteStructBegin("completeSessionMFA_result"); err != nil {
+		return fmt.Errorf("%T write struct begin error: %s", p, err)
+	}
+	if err := p.writeField0(oprot); err != nil {
+		return err
+	}
+	if err := p.writeField1(oprot); err != nil {
+		return err
+	}
+	if err := p.writeField2(oprot); err != nil {
+		return err
+	}
+	if err := p.writeField3(oprot); err != nil {
+		return err
+	}
+	if err := p.writeField4(oprot); err != nil {
+		return err
+	}
+	if err := p.writeField5(oprot); err != nil {
+		return err
+	}
+	if err := oprot.WriteFieldStop(); err != nil {
+		return fmt.Errorf("write field stop error: %s", err)
+	}
+	if err := oprot.WriteStructEnd(); err != nil {
+		return fmt.Errorf("write struct stop error: %s", err)
+	}
+	return nil
+}
+
+func (p *CompleteSessionMFAResult) writeField0(oprot thrift.TProtocol) (err error) {
+	if p.IsSetSuccess() {
+		if err := oprot.WriteFieldBegin("success", thrift.STRUCT, 0); err != nil {
+			return fmt.Errorf("%T write field begin error 0:success: %s", p, err)
+		}
+		if err := p.Success.Write(oprot); err != nil {
+			return fmt.Errorf("%T error writing struct: %s", p.Success, err)
+		}
+		if err := oprot.WriteFieldEnd(); err != nil {
+			return fmt.Errorf("%T write field end error 0:success: %s", p, err)
+		}
+	}
+	return err
+}
+
+func (p *CompleteSessionMFAResult) writeField1(oprot thrift.TProtocol) (err error) {
+	if p.IsSetError1() {
+		if err := oprot.WriteFieldBegin("error1", thrift.STRUCT, 1); err != nil {
+			return fmt.Errorf("%T write field begin error 1:error1: %s", p, err)
+		}
+		if err := p.Error1.Write(oprot); err != nil {
+			return fmt.Errorf("%T error writing struct: %s", p.Error1, err)
+		}
+		if err := oprot.WriteFieldEnd(); err != nil {
+			return fmt.Errorf("%T write field end error 1:error1: %s", p, err)
+		}
+	}
+	return err
+}
+
+func (p *CompleteSessionMFAResult) writeField2(oprot thrift.TProtocol) (err error) {
+	if p.IsSetError2() {
+		if err := oprot.WriteFieldBegin("error2", thrift.STRUCT, 2); err != nil {
+			return fmt.Errorf("%T write field begin error 2:error2: %s", p, err)
+		}
+		if err := p.Error2.Write(oprot); err != nil {
+			return fmt.Errorf("%T error writing struct: %s", p.Error2, err)
+		}
+		if err := oprot.WriteFieldEnd(); err != nil {
+			return fmt.Errorf("%T write field end error 2:error2: %s", p, err)
+		}
+	}
+	return err
+}
+
+func (p *CompleteSessionMFAResult) writeField3(oprot thrift.TProtocol) (err error) {
+	if p.IsSetError3() {
+		if err := oprot.WriteFieldBegin("error3", thrift.STRUCT, 3); err != nil {
+			return fmt.Errorf("%T write field begin error 3:error3: %s", p, err)
+		}
+		if err := p.Error3.Write(oprot); err != nil {
+			return fmt.Errorf("%T error writing struct: %s", p.Error3, err)
+		}
+		if err := oprot.WriteFieldEnd(); err != nil {
+			return fmt.Errorf("%T write field end error 3:error3: %s", p, err)
+		}
+	}
+	return err
+}
+
+func (p *CompleteSessionMFAResult) writeField4(oprot thrift.TProtocol) (err error) {
+	if p.IsSetError4() {
+		if err := oprot.WriteFieldBegin("error4", thrift.STRUCT, 4); err != nil {
+			return fmt.Errorf("%T write field begin error 4:error4: %s", p, err)
+		}
+		if err := p.Error4.Write(oprot); err != nil {
+			return fmt.Errorf("%T error writing struct: %s", p.Error4, err)
+		}
+		if err := oprot.WriteFieldEnd(); err != nil {
+			return fmt.Errorf("%T write field end error 4:error4: %s", p, err)
+		}
+	}
+	return err
+}
+
+func (p *CompleteSessionMFAResult) writeField5(oprot thrift.TProtocol) (err error) {
+	if p.IsSetError5() {
+		if err := oprot.WriteFieldBegin("error5", thrift.STRUCT, 5); err != nil {
+			return fmt.Errorf("%T write field begin error 5:error5: %s", p, err)
+		}
+		if err := p.Error5.Write(oprot); err != nil {
+			return fmt.Errorf("%T error writing struct: %s", p.Error5, err)
+		}
+		if err := oprot.WriteFieldEnd(); err != nil {
+			return fmt.Errorf("%T write field end error 5:error5: %s", p, err)
+		}
+	}
+	return err
+}
+
+func (p *CompleteSessionMFAResult) String() string {
+	if p == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("CompleteSessionMFAResult(%+v)", *p)
+}
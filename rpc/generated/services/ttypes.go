@@ -704,6 +704,328 @@ func (p *Session) String() string {
 	return fmt.Sprintf("Session(%+v)", *p)
 }
 
+type SessionChallenge struct {
+	Session     *Session `thrift:"session,1" json:"session,omitempty"`
+	ChallengeID string   `thrift:"challengeID,2" json:"challengeID"`
+	ExpiresOn   string   `thrift:"expiresOn,3" json:"expiresOn"`
+}
+
+func NewSessionChallenge() *SessionChallenge {
+	return &SessionChallenge{}
+}
+
+var SessionChallenge_Session_DEFAULT *Session
+
+func (p *SessionChallenge) GetSession() *Session {
+	if !p.IsSetSession() {
+		return SessionChallenge_Session_DEFAULT
+	}
+	return p.Session
+}
+
+func (p *SessionChallenge) GetChallengeID() string {
+	return p.ChallengeID
+}
+
+func (p *SessionChallenge) GetExpiresOn() string {
+	return p.ExpiresOn
+}
+func (p *SessionChallenge) IsSetSession() bool {
+	return p.Session != nil
+}
+
+func (p *SessionChallenge) Read(iprot thrift.TProtocol) error {
+	if _, err := iprot.ReadStructBegin(); err != nil {
+		return fmt.Errorf("%T read error: %s", p, err)
+	}
+	for {
+		_, fieldTypeId, fieldId, err := iprot.ReadFieldBegin()
+		if err != nil {
+			return fmt.Errorf("%T field %d read error: %s", p, fieldId, err)
+		}
+		if fieldTypeId == thrift.STOP {
+			break
+		}
+		switch fieldId {
+		case 1:
+			if err := p.ReadField1(iprot); err != nil {
+				return err
+			}
+		case 2:
+			if err := p.ReadField2(iprot); err != nil {
+				return err
+			}
+		case 3:
+			if err := p.ReadField3(iprot); err != nil {
+				return err
+			}
+		default:
+			if err := iprot.Skip(fieldTypeId); err != nil {
+				return err
+			}
+		}
+		if err := iprot.ReadFieldEnd(); err != nil {
+			return err
+		}
+	}
+	if err := iprot.ReadStructEnd(); err != nil {
+		return fmt.Errorf("%T read struct end error: %s", p, err)
+	}
+	return nil
+}
+
+func (p *SessionChallenge) ReadField1(iprot thrift.TProtocol) error {
+	p.Session = &Session{}
+	if err := p.Session.Read(iprot); err != nil {
+		return fmt.Errorf("%T error reading struct: %s", p.Session, err)
+	}
+	return nil
+}
+
+func (p *SessionChallenge) ReadField2(iprot thrift.TProtocol) error {
+	if v, err := iprot.ReadString(); err != nil {
+		return fmt.Errorf("error reading field 2: %s", err)
+	} else {
+		p.ChallengeID = v
+	}
+	return nil
+}
+
+func (p *SessionChallenge) ReadField3(iprot thrift.TProtocol) error {
+	if v, err := iprot.ReadString(); err != nil {
+		return fmt.Errorf("error reading field 3: %s", err)
+	} else {
+		p.ExpiresOn = v
+	}
+	return nil
+}
+
+func (p *SessionChallenge) Write(oprot thrift.TProtocol) error {
+	if err := oprot.WriteStructBegin("SessionChallenge"); err != nil {
+		return fmt.Errorf("%T write struct begin error: %s", p, err)
+	}
+	if err := p.writeField1(oprot); err != nil {
+		return err
+	}
+	if err := p.writeField2(oprot); err != nil {
+		return err
+	}
+	if err := p.writeField3(oprot); err != nil {
+		return err
+	}
+	if err := oprot.WriteFieldStop(); err != nil {
+		return fmt.Errorf("write field stop error: %s", err)
+	}
+	if err := oprot.WriteStructEnd(); err != nil {
+		return fmt.Errorf("write struct stop error: %s", err)
+	}
+	return nil
+}
+
+func (p *SessionChallenge) writeField1(oprot thrift.TProtocol) (err error) {
+	if p.IsSetSession() {
+		if err := oprot.WriteFieldBegin("session", thrift.STRUCT, 1); err != nil {
+			return fmt.Errorf("%T write field begin error 1:session: %s", p, err)
+		}
+		if err := p.Session.Write(oprot); err != nil {
+			return fmt.Errorf("%T error writing struct: %s", p.Session, err)
+		}
+		if err := oprot.WriteFieldEnd(); err != nil {
+			return fmt.Errorf("%T write field end error 1:session: %s", p, err)
+		}
+	}
+	return err
+}
+
+func (p *SessionChallenge) writeField2(oprot thrift.TProtocol) (err error) {
+	if err := oprot.WriteFieldBegin("challengeID", thrift.STRING, 2); err != nil {
+		return fmt.Errorf("%T write field begin error 2:challengeID: %s", p, err)
+	}
+	if err := oprot.WriteString(string(p.ChallengeID)); err != nil {
+		return fmt.Errorf("%T.challengeID (2) field write error: %s", p, err)
+	}
+	if err := oprot.WriteFieldEnd(); err != nil {
+		return fmt.Errorf("%T write field end error 2:challengeID: %s", p, err)
+	}
+	return err
+}
+
+func (p *SessionChallenge) writeField3(oprot thrift.TProtocol) (err error) {
+	if err := oprot.WriteFieldBegin("expiresOn", thrift.STRING, 3); err != nil {
+		return fmt.Errorf("%T write field begin error 3:expiresOn: %s", p, err)
+	}
+	if err := oprot.WriteString(string(p.ExpiresOn)); err != nil {
+		return fmt.Errorf("%T.expiresOn (3) field write error: %s", p, err)
+	}
+	if err := oprot.WriteFieldEnd(); err != nil {
+		return fmt.Errorf("%T write field end error 3:expiresOn: %s", p, err)
+	}
+	return err
+}
+
+func (p *SessionChallenge) String() string {
+	if p == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("SessionChallenge(%+v)", *p)
+}
+
+type Permission struct {
+	Name           string `thrift:"name,1" json:"name"`
+	Description    string `thrift:"description,2" json:"description"`
+	EvaluationRule string `thrift:"evaluationRule,3" json:"evaluationRule"`
+}
+
+func NewPermission() *Permission {
+	return &Permission{}
+}
+
+func (p *Permission) GetName() string {
+	return p.Name
+}
+
+func (p *Permission) GetDescription() string {
+	return p.Description
+}
+
+func (p *Permission) GetEvaluationRule() string {
+	return p.EvaluationRule
+}
+func (p *Permission) Read(iprot thrift.TProtocol) error {
+	if _, err := iprot.ReadStructBegin(); err != nil {
+		return fmt.Errorf("%T read error: %s", p, err)
+	}
+	for {
+		_, fieldTypeId, fieldId, err := iprot.ReadFieldBegin()
+		if err != nil {
+			return fmt.Errorf("%T field %d read error: %s", p, fieldId, err)
+		}
+		if fieldTypeId == thrift.STOP {
+			break
+		}
+		switch fieldId {
+		case 1:
+			if err := p.ReadField1(iprot); err != nil {
+				return err
+			}
+		case 2:
+			if err := p.ReadField2(iprot); err != nil {
+				return err
+			}
+		case 3:
+			if err := p.ReadField3(iprot); err != nil {
+				return err
+			}
+		default:
+			if err := iprot.Skip(fieldTypeId); err != nil {
+				return err
+			}
+		}
+		if err := iprot.ReadFieldEnd(); err != nil {
+			return err
+		}
+	}
+	if err := iprot.ReadStructEnd(); err != nil {
+		return fmt.Errorf("%T read struct end error: %s", p, err)
+	}
+	return nil
+}
+
+func (p *Permission) ReadField1(iprot thrift.TProtocol) error {
+	if v, err := iprot.ReadString(); err != nil {
+		return fmt.Errorf("error reading field 1: %s", err)
+	} else {
+		p.Name = v
+	}
+	return nil
+}
+
+func (p *Permission) ReadField2(iprot thrift.TProtocol) error {
+	if v, err := iprot.ReadString(); err != nil {
+		return fmt.Errorf("error reading field 2: %s", err)
+	} else {
+		p.Description = v
+	}
+	return nil
+}
+
+func (p *Permission) ReadField3(iprot thrift.TProtocol) error {
+	if v, err := iprot.ReadString(); err != nil {
+		return fmt.Errorf("error reading field 3: %s", err)
+	} else {
+		p.EvaluationRule = v
+	}
+	return nil
+}
+
+func (p *Permission) Write(oprot thrift.TProtocol) error {
+	if err := oprot.WriteStructBegin("Permission"); err != nil {
+		return fmt.Errorf("%T write struct begin error: %s", p, err)
+	}
+	if err := p.writeField1(oprot); err != nil {
+		return err
+	}
+	if err := p.writeField2(oprot); err != nil {
+		return err
+	}
+	if err := p.writeField3(oprot); err != nil {
+		return err
+	}
+	if err := oprot.WriteFieldStop(); err != nil {
+		return fmt.Errorf("write field stop error: %s", err)
+	}
+	if err := oprot.WriteStructEnd(); err != nil {
+		return fmt.Errorf("write struct stop error: %s", err)
+	}
+	return nil
+}
+
+func (p *Permission) writeField1(oprot thrift.TProtocol) (err error) {
+	if err := oprot.WriteFieldBegin("name", thrift.STRING, 1); err != nil {
+		return fmt.Errorf("%T write field begin error 1:name: %s", p, err)
+	}
+	if err := oprot.WriteString(string(p.Name)); err != nil {
+		return fmt.Errorf("%T.name (1) field write error: %s", p, err)
+	}
+	if err := oprot.WriteFieldEnd(); err != nil {
+		return fmt.Errorf("%T write field end error 1:name: %s", p, err)
+	}
+	return err
+}
+
+func (p *Permission) writeField2(oprot thrift.TProtocol) (err error) {
+	if err := oprot.WriteFieldBegin("description", thrift.STRING, 2); err != nil {
+		return fmt.Errorf("%T write field begin error 2:description: %s", p, err)
+	}
+	if err := oprot.WriteString(string(p.Description)); err != nil {
+		return fmt.Errorf("%T.description (2) field write error: %s", p, err)
+	}
+	if err := oprot.WriteFieldEnd(); err != nil {
+		return fmt.Errorf("%T write field end error 2:description: %s", p, err)
+	}
+	return err
+}
+
+func (p *Permission) writeField3(oprot thrift.TProtocol) (err error) {
+	if err := oprot.WriteFieldBegin("evaluationRule", thrift.STRING, 3); err != nil {
+		return fmt.Errorf("%T write field begin error 3:evaluationRule: %s", p, err)
+	}
+	if err := oprot.WriteString(string(p.EvaluationRule)); err != nil {
+		return fmt.Errorf("%T.evaluationRule (3) field write error: %s", p, err)
+	}
+	if err := oprot.WriteFieldEnd(); err != nil {
+		return fmt.Errorf("%T write field end error 3:evaluationRule: %s", p, err)
+	}
+	return err
+}
+
+func (p *Permission) String() string {
+	if p == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("Permission(%+v)", *p)
+}
+
 type ServerError struct {
 	Msg   string `thrift:"msg,1" json:"msg"`
 	Cause string `thrift:"cause,2" json:"cause"`
@@ -1083,8 +1405,10 @@ func (p *UnauthorizedError) Error() string {
 }
 
 type ForbiddenError struct {
-	Msg   string `thrift:"msg,1" json:"msg"`
-	Cause string `thrift:"cause,2" json:"cause"`
+	Msg             string `thrift:"msg,1" json:"msg"`
+	Cause           string `thrift:"cause,2" json:"cause"`
+	RetryAfter      int32  `thrift:"retryAfter,3" json:"retryAfter"`
+	PasswordExpired bool   `thrift:"passwordExpired,4" json:"passwordExpired"`
 }
 
 func NewForbiddenError() *ForbiddenError {
@@ -1098,6 +1422,14 @@ func (p *ForbiddenError) GetMsg() string {
 func (p *ForbiddenError) GetCause() string {
 	return p.Cause
 }
+
+func (p *ForbiddenError) GetRetryAfter() int32 {
+	return p.RetryAfter
+}
+
+func (p *ForbiddenError) GetPasswordExpired() bool {
+	return p.PasswordExpired
+}
 func (p *ForbiddenError) Read(iprot thrift.TProtocol) error {
 	if _, err := iprot.ReadStructBegin(); err != nil {
 		return fmt.Errorf("%T read error: %s", p, err)
@@ -1119,6 +1451,14 @@ func (p *ForbiddenError) Read(iprot thrift.TProtocol) error {
 			if err := p.ReadField2(iprot); err != nil {
 				return err
 			}
+		case 3:
+			if err := p.ReadField3(iprot); err != nil {
+				return err
+			}
+		case 4:
+			if err := p.ReadField4(iprot); err != nil {
+				return err
+			}
 		default:
 			if err := iprot.Skip(fieldTypeId); err != nil {
 				return err
@@ -1152,6 +1492,24 @@ func (p *ForbiddenError) ReadField2(iprot thrift.TProtocol) error {
 	return nil
 }
 
+func (p *ForbiddenError) ReadField3(iprot thrift.TProtocol) error {
+	if v, err := iprot.ReadI32(); err != nil {
+		return fmt.Errorf("error reading field 3: %s", err)
+	} else {
+		p.RetryAfter = v
+	}
+	return nil
+}
+
+func (p *ForbiddenError) ReadField4(iprot thrift.TProtocol) error {
+	if v, err := iprot.ReadBool(); err != nil {
+		return fmt.Errorf("error reading field 4: %s", err)
+	} else {
+		p.PasswordExpired = v
+	}
+	return nil
+}
+
 func (p *ForbiddenError) Write(oprot thrift.TProtocol) error {
 	if err := oprot.WriteStructBegin("ForbiddenError"); err != nil {
 		return fmt.Errorf("%T write struct begin error: %s", p, err)
@@ -1162,6 +1520,12 @@ func (p *ForbiddenError) Write(oprot thrift.TProtocol) error {
 	if err := p.writeField2(oprot); err != nil {
 		return err
 	}
+	if err := p.writeField3(oprot); err != nil {
+		return err
+	}
+	if err := p.writeField4(oprot); err != nil {
+		return err
+	}
 	if err := oprot.WriteFieldStop(); err != nil {
 		return fmt.Errorf("write field stop error: %s", err)
 	}
@@ -1197,6 +1561,32 @@ func (p *ForbiddenError) writeField2(oprot thrift.TProtocol) (err error) {
 	return err
 }
 
+func (p *ForbiddenError) writeField3(oprot thrift.TProtocol) (err error) {
+	if err := oprot.WriteFieldBegin("retryAfter", thrift.I32, 3); err != nil {
+		return fmt.Errorf("%T write field begin error 3:retryAfter: %s", p, err)
+	}
+	if err := oprot.WriteI32(int32(p.RetryAfter)); err != nil {
+		return fmt.Errorf("%T.retryAfter (3) field write error: %s", p, err)
+	}
+	if err := oprot.WriteFieldEnd(); err != nil {
+		return fmt.Errorf("%T write field end error 3:retryAfter: %s", p, err)
+	}
+	return err
+}
+
+func (p *ForbiddenError) writeField4(oprot thrift.TProtocol) (err error) {
+	if err := oprot.WriteFieldBegin("passwordExpired", thrift.BOOL, 4); err != nil {
+		return fmt.Errorf("%T write field begin error 4:passwordExpired: %s", p, err)
+	}
+	if err := oprot.WriteBool(bool(p.PasswordExpired)); err != nil {
+		return fmt.Errorf("%T.passwordExpired (4) field write error: %s", p, err)
+	}
+	if err := oprot.WriteFieldEnd(); err != nil {
+		return fmt.Errorf("%T write field end error 4:passwordExpired: %s", p, err)
+	}
+	return err
+}
+
 func (p *ForbiddenError) String() string {
 	if p == nil {
 		return "<nil>"
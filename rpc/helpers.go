@@ -1,6 +1,8 @@
 package rpc
 
 import (
+	"time"
+
 	"github.com/oleksandr/idp/errs"
 	"github.com/oleksandr/idp/rpc/generated/services"
 )
@@ -14,12 +16,17 @@ func errorToServiceError(err *errs.Error) error {
 			e.Cause = err.Cause.Error()
 		}
 		return e
-	case errs.ErrorTypeForbidden:
+	case errs.ErrorTypeForbidden, errs.ErrorTypePasswordExpired:
 		e := services.NewForbiddenError()
 		e.Msg = err.Msg
 		if err.Cause != nil {
 			e.Cause = err.Cause.Error()
 		}
+		if err.RetryAfter > 0 {
+			// Whole seconds, rounded up
+			e.RetryAfter = int32((err.RetryAfter + time.Second - 1) / time.Second)
+		}
+		e.PasswordExpired = err.Type == errs.ErrorTypePasswordExpired
 		return e
 
 	case errs.ErrorTypeConflict:
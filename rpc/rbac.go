@@ -1,6 +1,9 @@
 package rpc
 
-import "github.com/oleksandr/idp/errs"
+import (
+	"github.com/oleksandr/idp/errs"
+	"github.com/oleksandr/idp/rpc/generated/services"
+)
 
 // AssertRole implements RBAC's interface
 func (handler *IdentityProviderHandler) AssertRole(sessionID string, roleName string) (r bool, err error) {
@@ -12,7 +15,7 @@ func (handler *IdentityProviderHandler) AssertRole(sessionID string, roleName st
 		return false, errorToServiceError(e)
 	}
 
-	ok, err := handler.RBACInteractor.AssertRole(session.User.ID, roleName)
+	ok, err := handler.RBACInteractor.AssertRole(*session, roleName)
 	if err != nil {
 		handler.log.Println("ERROR:", err.Error())
 		return false, errorToServiceError(err.(*errs.Error))
@@ -26,8 +29,8 @@ func (handler *IdentityProviderHandler) AssertRole(sessionID string, roleName st
 }
 
 // AssertPermission implements RBAC's interface
-func (handler *IdentityProviderHandler) AssertPermission(sessionID string, permissioName string) (r bool, err error) {
-	handler.log.Printf("AssertPermission(%v, %v)", sessionID, permissioName)
+func (handler *IdentityProviderHandler) AssertPermission(sessionID string, permissioName string, attributes map[string]string) (r bool, err error) {
+	handler.log.Printf("AssertPermission(%v, %v, %v)", sessionID, permissioName, attributes)
 
 	session, err := handler.SessionInteractor.Find(sessionID)
 	if err != nil {
@@ -35,7 +38,7 @@ func (handler *IdentityProviderHandler) AssertPermission(sessionID string, permi
 		return false, errorToServiceError(e)
 	}
 
-	ok, err := handler.RBACInteractor.AssertPermission(session.User.ID, permissioName)
+	ok, err := handler.RBACInteractor.AssertPermission(*session, permissioName, attributes)
 	if err != nil {
 		handler.log.Println("ERROR:", err.Error())
 		return false, errorToServiceError(err.(*errs.Error))
@@ -47,3 +50,30 @@ func (handler *IdentityProviderHandler) AssertPermission(sessionID string, permi
 
 	return false, nil
 }
+
+// GetEffectivePermissions implements RBAC's interface
+func (handler *IdentityProviderHandler) GetEffectivePermissions(sessionID string) (r []*services.Permission, err error) {
+	handler.log.Printf("GetEffectivePermissions(%v)", sessionID)
+
+	session, err := handler.SessionInteractor.Find(sessionID)
+	if err != nil {
+		e := err.(*errs.Error)
+		return nil, errorToServiceError(e)
+	}
+
+	permissions, err := handler.RBACInteractor.ListEffectivePermissions(*session)
+	if err != nil {
+		handler.log.Println("ERROR:", err.Error())
+		return nil, errorToServiceError(err.(*errs.Error))
+	}
+
+	r = []*services.Permission{}
+	for _, p := range permissions {
+		r = append(r, &services.Permission{
+			Name:           p.Name,
+			Description:    p.Description,
+			EvaluationRule: p.EvaluationRule,
+		})
+	}
+	return r, nil
+}
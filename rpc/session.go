@@ -29,6 +29,48 @@ func (handler *IdentityProviderHandler) CreateSession(domain string, name string
 	return nil, errorToServiceError(e)
 }
 
+// BeginSession is an implementation of Authenticator's BeginSession method. A challenge
+// is returned instead of a session if the user has to provide an authentication code.
+func (handler *IdentityProviderHandler) BeginSession(domain string, name string, password string, userAgent string, remoteAddr string) (r *services.SessionChallenge, err error) {
+	handler.log.Printf("beginSession(%v, %v)", domain, name)
+
+	// Prepare arguments
+	u := entities.BasicUser{}
+	u.Name = name
+	d := entities.BasicDomain{}
+	d.Name = domain
+
+	// Create session or challenge
+	session, challenge, err := handler.SessionInteractor.BeginWithPassword(d, u, password, userAgent, remoteAddr)
+	if err == nil {
+		r = services.NewSessionChallenge()
+		if challenge != nil {
+			r.ChallengeID = challenge.ID
+			r.ExpiresOn = challenge.ExpiresOn.Format(time.RFC3339)
+		} else {
+			r.Session = sessionToResponse(session)
+		}
+		return r, nil
+	}
+
+	// Handle errors
+	e := err.(*errs.Error)
+	return nil, errorToServiceError(e)
+}
+
+// CompleteSessionMFA is an implementation of Authenticator's CompleteSessionMFA method
+func (handler *IdentityProviderHandler) CompleteSessionMFA(challengeID string, code string, userAgent string, remoteAddr string) (r *services.Session, err error) {
+	handler.log.Printf("completeSessionMFA(%v, %v, %v)", challengeID, userAgent, remoteAddr)
+
+	session, err := handler.SessionInteractor.CompleteMFA(challengeID, code, userAgent, remoteAddr)
+	if err == nil {
+		return sessionToResponse(session), nil
+	}
+
+	e := err.(*errs.Error)
+	return nil, errorToServiceError(e)
+}
+
 // GetSession is an implementation of Authenticator's GetSession method
 func (handler *IdentityProviderHandler) GetSession(sessionID string, userAgent string, remoteAddr string) (r *services.Session, err error) {
 	handler.log.Printf("getSession(%v, %v, %v)", sessionID, userAgent, remoteAddr)
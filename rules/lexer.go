@@ -0,0 +1,116 @@
+package rules
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenOperator
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+// operators are sorted so that longer ones are matched first
+var operators = []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!", "(", ")", "[", "]", ",", "."}
+
+// tokenize splits a rule into a list of tokens terminated by tokenEOF. Positions
+// are byte offsets into the source.
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(src) {
+		c, size := utf8.DecodeRuneInString(src[i:])
+		switch {
+		case c == utf8.RuneError && size == 1:
+			return nil, fmt.Errorf("Invalid UTF-8 at position %v", i)
+		case unicode.IsSpace(c):
+			i += size
+		case c == '"' || c == '\'':
+			s, n, err := scanString(src[i:])
+			if err != nil {
+				return nil, fmt.Errorf("%v at position %v", err, i)
+			}
+			tokens = append(tokens, token{kind: tokenString, value: s, pos: i})
+			i += n
+		case isDigit(c) || (c == '-' && i+1 < len(src) && isDigit(rune(src[i+1]))):
+			j := i + 1
+			for j < len(src) && (isDigit(rune(src[j])) || src[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, value: src[i:j], pos: i})
+			i = j
+		case unicode.IsLetter(c) || c == '_':
+			j := i + size
+			for j < len(src) {
+				r, n := utf8.DecodeRuneInString(src[j:])
+				if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+					break
+				}
+				j += n
+			}
+			tokens = append(tokens, token{kind: tokenIdent, value: src[i:j], pos: i})
+			i = j
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(src[i:], op) {
+					tokens = append(tokens, token{kind: tokenOperator, value: op, pos: i})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("Unexpected character %q at position %v", c, i)
+			}
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(src)}), nil
+}
+
+// isDigit tells if a rune is an ASCII digit, the only digits numbers are made of
+func isDigit(c rune) bool {
+	return c >= '0' && c <= '9'
+}
+
+// scanString reads a quoted string literal supporting \" \' \\ \n and \t escapes.
+// It returns the unquoted value and the number of bytes consumed. Multi-byte
+// characters are copied as they are, since quotes and escapes are ASCII.
+func scanString(src string) (string, int, error) {
+	quote := src[0]
+	var b bytes.Buffer
+	for i := 1; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == quote:
+			return b.String(), i + 1, nil
+		case c == '\\' && i+1 < len(src):
+			i++
+			switch src[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			default:
+				b.WriteByte(src[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("Unterminated string")
+}
@@ -0,0 +1,465 @@
+// Package rules implements a small expression language used by evaluation
+// rules of permissions, e.g.
+//
+//	domain.name == "domain1.com" && attrs.owner == user.id
+//
+// Expressions are sandboxed: they can only read values of a given environment,
+// there are no function calls, loops or assignments, and both the length and
+// the nesting depth of an expression are limited.
+//
+// Supported are string ("..." or '...'), number, true, false and null literals,
+// lists of literals ([1, 2]), dotted paths (user.id), comparison operators
+// (==, !=, <, <=, >, >=), the "in" operator, logical operators (&&, ||, !)
+// and parentheses. Missing paths evaluate to null. Strings holding numbers are
+// compared with numbers numerically.
+package rules
+
+import (
+	"fmt"
+	"strconv"
+)
+
+const (
+	// MaxLength is the maximum length of a rule's source
+	MaxLength = 1000
+	// MaxDepth is the maximum nesting depth of a rule
+	MaxDepth = 32
+)
+
+// Env is a set of named values a rule is evaluated against. Values can be
+// strings, numbers, booleans, nil, map[string]string or map[string]interface{}.
+type Env map[string]interface{}
+
+//
+// Rule is a parsed expression ready for evaluation
+//
+type Rule struct {
+	src  string
+	root node
+}
+
+// Parse compiles a given source into a rule
+func Parse(src string) (*Rule, error) {
+	if len(src) > MaxLength {
+		return nil, fmt.Errorf("Rule is longer than %v characters", MaxLength)
+	}
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, fmt.Errorf("Unexpected %q at position %v", t.value, t.pos)
+	}
+	return &Rule{src: src, root: root}, nil
+}
+
+// Eval evaluates the rule against a given environment. The result of the
+// expression must be a boolean.
+func (r *Rule) Eval(env Env) (bool, error) {
+	v, err := r.root.eval(env)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("Rule evaluated to %v instead of a boolean", v)
+	}
+	return b, nil
+}
+
+// String returns the rule's source
+func (r *Rule) String() string {
+	return r.src
+}
+
+type node interface {
+	eval(env Env) (interface{}, error)
+}
+
+type literalNode struct {
+	value interface{}
+}
+
+func (n *literalNode) eval(env Env) (interface{}, error) {
+	return n.value, nil
+}
+
+type pathNode struct {
+	names []string
+}
+
+func (n *pathNode) eval(env Env) (interface{}, error) {
+	var v interface{} = map[string]interface{}(env)
+	for _, name := range n.names {
+		switch m := v.(type) {
+		case map[string]interface{}:
+			v = m[name]
+		case map[string]string:
+			s, ok := m[name]
+			if !ok {
+				return nil, nil
+			}
+			v = s
+		default:
+			return nil, nil
+		}
+	}
+	if i, ok := v.(int); ok {
+		return float64(i), nil
+	}
+	return v, nil
+}
+
+type listNode struct {
+	items []node
+}
+
+func (n *listNode) eval(env Env) (interface{}, error) {
+	values := make([]interface{}, len(n.items))
+	for i, item := range n.items {
+		v, err := item.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+type notNode struct {
+	operand node
+}
+
+func (n *notNode) eval(env Env) (interface{}, error) {
+	b, err := evalBool(n.operand, env, "!")
+	if err != nil {
+		return nil, err
+	}
+	return !b, nil
+}
+
+type logicalNode struct {
+	op          string
+	left, right node
+}
+
+func (n *logicalNode) eval(env Env) (interface{}, error) {
+	l, err := evalBool(n.left, env, n.op)
+	if err != nil {
+		return nil, err
+	}
+	if (n.op == "&&" && !l) || (n.op == "||" && l) {
+		return l, nil
+	}
+	return evalBool(n.right, env, n.op)
+}
+
+type compareNode struct {
+	op          string
+	left, right node
+}
+
+func (n *compareNode) eval(env Env) (interface{}, error) {
+	l, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	r, err := n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return equal(l, r), nil
+	case "!=":
+		return !equal(l, r), nil
+	case "in":
+		values, ok := r.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("Right operand of in must be a list")
+		}
+		for _, v := range values {
+			if equal(l, v) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	c, err := compare(l, r)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	default:
+		return c >= 0, nil
+	}
+}
+
+func evalBool(n node, env Env, op string) (bool, error) {
+	v, err := n.eval(env)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("Operand of %v must be a boolean, got %v", op, v)
+	}
+	return b, nil
+}
+
+// toNumber converts numbers and numeric strings to float64
+func toNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func equal(a, b interface{}) bool {
+	_, aNum := a.(float64)
+	_, bNum := b.(float64)
+	if aNum || bNum {
+		x, ok1 := toNumber(a)
+		y, ok2 := toNumber(b)
+		return ok1 && ok2 && x == y
+	}
+	switch x := a.(type) {
+	case nil:
+		return b == nil
+	case string:
+		y, ok := b.(string)
+		return ok && x == y
+	case bool:
+		y, ok := b.(bool)
+		return ok && x == y
+	}
+	return false
+}
+
+func compare(a, b interface{}) (int, error) {
+	_, aNum := a.(float64)
+	_, bNum := b.(float64)
+	if aNum || bNum {
+		x, ok1 := toNumber(a)
+		y, ok2 := toNumber(b)
+		if !ok1 || !ok2 {
+			return 0, fmt.Errorf("Cannot compare %v with %v", a, b)
+		}
+		switch {
+		case x < y:
+			return -1, nil
+		case x > y:
+			return 1, nil
+		}
+		return 0, nil
+	}
+	x, ok1 := a.(string)
+	y, ok2 := b.(string)
+	if !ok1 || !ok2 {
+		return 0, fmt.Errorf("Cannot compare %v with %v", a, b)
+	}
+	switch {
+	case x < y:
+		return -1, nil
+	case x > y:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+//
+// parser is a recursive descent parser of the following grammar:
+//
+//	or      = and { "||" and }
+//	and     = unary { "&&" unary }
+//	unary   = "!" unary | compare
+//	compare = operand [ ( "==" | "!=" | "<" | "<=" | ">" | ">=" | "in" ) operand ]
+//	operand = literal | path | "(" or ")" | "[" [ or { "," or } ] "]"
+//
+type parser struct {
+	tokens []token
+	pos    int
+	depth  int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) accept(op string) bool {
+	if t := p.peek(); t.kind == tokenOperator && t.value == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(op string) error {
+	if !p.accept(op) {
+		t := p.peek()
+		return fmt.Errorf("Expected %q at position %v", op, t.pos)
+	}
+	return nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > MaxDepth {
+		return nil, fmt.Errorf("Rule is nested deeper than %v levels", MaxDepth)
+	}
+
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{op: "||", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{op: "&&", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.accept("!") {
+		p.depth++
+		defer func() { p.depth-- }()
+		if p.depth > MaxDepth {
+			return nil, fmt.Errorf("Rule is nested deeper than %v levels", MaxDepth)
+		}
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand}, nil
+	}
+	return p.parseCompare()
+}
+
+func (p *parser) parseCompare() (node, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	switch {
+	case t.kind == tokenOperator && (t.value == "==" || t.value == "!=" || t.value == "<" ||
+		t.value == "<=" || t.value == ">" || t.value == ">="):
+	case t.kind == tokenIdent && t.value == "in":
+	default:
+		return left, nil
+	}
+	p.next()
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return &compareNode{op: t.value, left: left, right: right}, nil
+}
+
+func (p *parser) parseOperand() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenString:
+		return &literalNode{value: t.value}, nil
+	case tokenNumber:
+		f, err := strconv.ParseFloat(t.value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid number %v at position %v", t.value, t.pos)
+		}
+		return &literalNode{value: f}, nil
+	case tokenIdent:
+		switch t.value {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null":
+			return &literalNode{value: nil}, nil
+		case "in":
+			return nil, fmt.Errorf("Unexpected in at position %v", t.pos)
+		}
+		path := &pathNode{names: []string{t.value}}
+		for p.accept(".") {
+			name := p.next()
+			if name.kind != tokenIdent {
+				return nil, fmt.Errorf("Expected a name at position %v", name.pos)
+			}
+			path.names = append(path.names, name.value)
+		}
+		return path, nil
+	case tokenOperator:
+		switch t.value {
+		case "(":
+			n, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return n, p.expect(")")
+		case "[":
+			list := &listNode{}
+			if p.accept("]") {
+				return list, nil
+			}
+			for {
+				item, err := p.parseOr()
+				if err != nil {
+					return nil, err
+				}
+				list.items = append(list.items, item)
+				if p.accept("]") {
+					return list, nil
+				}
+				if err := p.expect(","); err != nil {
+					return nil, err
+				}
+			}
+		}
+	case tokenEOF:
+		return nil, fmt.Errorf("Unexpected end of rule")
+	}
+	return nil, fmt.Errorf("Unexpected %q at position %v", t.value, t.pos)
+}
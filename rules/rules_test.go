@@ -0,0 +1,181 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+)
+
+var testEnv = Env{
+	"user": map[string]string{
+		"id":   "42",
+		"name": "jürgen",
+	},
+	"domain": map[string]interface{}{
+		"name":    "domain1.com",
+		"enabled": true,
+		"users":   3,
+	},
+	"attrs": map[string]string{
+		"owner": "42",
+		"size":  "10",
+		"city":  "Zürich",
+	},
+}
+
+func TestEval(t *testing.T) {
+	for _, tc := range []struct {
+		src  string
+		want bool
+	}{
+		{`domain.name == "domain1.com"`, true},
+		{`domain.name == 'domain1.com' && attrs.owner == user.id`, true},
+		{`domain.name != "domain1.com" || !domain.enabled`, false},
+		{`domain.users == 3 && domain.users > 2.5 && domain.users <= 3`, true},
+		{`attrs.size > 9`, true},
+		{`attrs.size == 10.0`, true},
+		{`-1 < 0`, true},
+		{`"a" < "b"`, true},
+		{`user.id in ["1", "42"]`, true},
+		{`user.id in []`, false},
+		{`attrs.size in [1, 10]`, true},
+		{`!(domain.users in [1, 2])`, true},
+		{`(true || false) && !false`, true},
+
+		// null semantics: missing paths are null, null equals null only
+		{`attrs.missing == null`, true},
+		{`attrs.missing.deeper == null`, true},
+		{`nothing == null`, true},
+		{`null == null`, true},
+		{`attrs.missing != null`, false},
+		{`attrs.missing == ""`, false},
+		{`attrs.missing == false`, false},
+		{`attrs.missing == 0`, false},
+		{`null in [1, null]`, true},
+		{`false && attrs.missing < 1`, false},
+
+		// non-ASCII identifiers and strings
+		{`user.name == "jürgen"`, true},
+		{`attrs.city == 'Zürich' && attrs.city != "Zurich"`, true},
+		{`"日本" == "日本"`, true},
+		{`"é" > "e"`, true},
+		{`"say \"hi\"\n" == 'say "hi"` + "\n" + `'`, true},
+	} {
+		r, err := Parse(tc.src)
+		if err != nil {
+			t.Errorf("Parse(%v): %v", tc.src, err)
+			continue
+		}
+		got, err := r.Eval(testEnv)
+		if err != nil {
+			t.Errorf("Eval(%v): %v", tc.src, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("Eval(%v) = %v, want %v", tc.src, got, tc.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tc := range []struct {
+		src string
+		err string
+	}{
+		{``, "Unexpected end"},
+		{`user.id ==`, "Unexpected end"},
+		{`user.id == "42`, "Unterminated string"},
+		{`user.id = "42"`, `Unexpected character '='`},
+		{`(true`, `Expected ")"`},
+		{`[1, 2`, `Expected ","`},
+		{`true true`, `Unexpected "true"`},
+		{`user.`, "Expected a name"},
+		{`in == 1`, "Unexpected in"},
+		{`1.2.3 == 1`, "Invalid number"},
+		{`user.id == 42 $`, "Unexpected character '$' at position 14"},
+		{`"日本" == x §`, "Unexpected character '§' at position 14"},
+		{`٣ == 3`, "Unexpected character '٣'"},
+		{"user.id == \"\xff\" \xff", "Invalid UTF-8 at position 15"},
+		{strings.Repeat(" ", MaxLength) + "x", "longer than 1000"},
+		{strings.Repeat("(", MaxDepth) + "true" + strings.Repeat(")", MaxDepth), "nested deeper than 32"},
+		{strings.Repeat("!", MaxDepth) + "true", "nested deeper than 32"},
+		{"1 in " + strings.Repeat("[", MaxDepth) + "1" + strings.Repeat("]", MaxDepth), "nested deeper than 32"},
+	} {
+		_, err := Parse(tc.src)
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("Parse(%.40q) = %v, want %q", tc.src, err, tc.err)
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	src := `user.id == "42"`
+	src += strings.Repeat(" ", MaxLength-len(src))
+	if _, err := Parse(src); err != nil {
+		t.Errorf("Parse of %v bytes: %v", len(src), err)
+	}
+
+	for _, src := range []string{
+		strings.Repeat("(", MaxDepth-1) + "true" + strings.Repeat(")", MaxDepth-1),
+		strings.Repeat("!", MaxDepth-1) + "true",
+	} {
+		r, err := Parse(src)
+		if err != nil {
+			t.Errorf("Parse(%.40v): %v", src, err)
+			continue
+		}
+		if _, err := r.Eval(testEnv); err != nil {
+			t.Errorf("Eval(%.40v): %v", src, err)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	for _, tc := range []struct {
+		src string
+		err string
+	}{
+		{`user.id`, "instead of a boolean"},
+		{`1 == 1 && "yes"`, "Operand of && must be a boolean"},
+		{`false || 1`, "Operand of || must be a boolean"},
+		{`!user.id`, "Operand of ! must be a boolean"},
+		{`user.id in "42"`, "must be a list"},
+		{`user.name < 1`, "Cannot compare"},
+		{`true < false`, "Cannot compare"},
+		{`attrs.missing < 1`, "Cannot compare"},
+		{`null >= null`, "Cannot compare"},
+		{`domain.enabled > "a"`, "Cannot compare"},
+	} {
+		r, err := Parse(tc.src)
+		if err != nil {
+			t.Errorf("Parse(%v): %v", tc.src, err)
+			continue
+		}
+		_, err = r.Eval(testEnv)
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("Eval(%v) = %v, want %q", tc.src, err, tc.err)
+		}
+	}
+}
+
+func TestTokenizePositions(t *testing.T) {
+	tokens, err := tokenize(`ü.ß == "€"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []token{
+		{tokenIdent, "ü", 0},
+		{tokenOperator, ".", 2},
+		{tokenIdent, "ß", 3},
+		{tokenOperator, "==", 6},
+		{tokenString, "€", 9},
+		{tokenEOF, "", 14},
+	}
+	if len(tokens) != len(want) {
+		t.Fatalf("tokenize = %v, want %v", tokens, want)
+	}
+	for i := range want {
+		if tokens[i] != want[i] {
+			t.Errorf("token %v = %v, want %v", i, tokens[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,322 @@
+// Package saml implements the parts of SAML 2.0 an identity provider needs for
+// SP-initiated single sign-on: decoding authentication requests of the HTTP-Redirect
+// and HTTP-POST bindings, issuing responses with signed assertions and describing
+// the IdP with metadata. Assertions are signed with RSA-SHA256 (XML Signature,
+// enveloped, exclusive canonicalization).
+package saml
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"time"
+)
+
+// XML namespaces
+const (
+	ProtocolNamespace  = "urn:oasis:names:tc:SAML:2.0:protocol"
+	AssertionNamespace = "urn:oasis:names:tc:SAML:2.0:assertion"
+	MetadataNamespace  = "urn:oasis:names:tc:SAML:2.0:metadata"
+	SignatureNamespace = "http://www.w3.org/2000/09/xmldsig#"
+)
+
+// Bindings of protocol messages
+const (
+	HTTPRedirectBinding = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect"
+	HTTPPOSTBinding     = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+)
+
+// Identifiers used in responses and metadata
+const (
+	NameIDFormatUnspecified  = "urn:oasis:names:tc:SAML:1.1:nameid-format:unspecified"
+	AttributeNameFormatBasic = "urn:oasis:names:tc:SAML:2.0:attrname-format:basic"
+	StatusSuccess            = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	BearerConfirmation       = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+	PasswordAuthnContext     = "urn:oasis:names:tc:SAML:2.0:ac:classes:PasswordProtectedTransport"
+
+	exclusiveC14N      = "http://www.w3.org/2001/10/xml-exc-c14n#"
+	envelopedSignature = "http://www.w3.org/2000/09/xmldsig#enveloped-signature"
+	rsaSHA256          = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	sha256Digest       = "http://www.w3.org/2001/04/xmlenc#sha256"
+)
+
+const (
+	// maxRequestSize limits the size of a decoded (inflated) request
+	maxRequestSize = 64 * 1024
+	// assertionTTL is how long an assertion may be consumed after it's issued
+	assertionTTL = 5 * time.Minute
+	// clockSkew is allowed for service providers whose clocks are behind
+	clockSkew = time.Minute
+	// certificateTTL is the validity period of a signing certificate
+	certificateTTL = 10 * 365 * 24 * time.Hour
+	// timeFormat is the format of SAML date/time values (always UTC)
+	timeFormat = "2006-01-02T15:04:05Z"
+)
+
+var (
+	// ErrMalformedRequest is returned when a request can't be decoded or parsed
+	ErrMalformedRequest = errors.New("Malformed SAML request")
+	// ErrUnsupportedKey is returned when a key can't sign assertions
+	ErrUnsupportedKey = errors.New("Only RSA keys can sign SAML assertions")
+)
+
+//
+// AuthnRequest is an authentication request of a service provider. Only the parts
+// the IdP uses are decoded.
+//
+type AuthnRequest struct {
+	XMLName                     xml.Name `xml:"urn:oasis:names:tc:SAML:2.0:protocol AuthnRequest"`
+	ID                          string   `xml:"ID,attr"`
+	Version                     string   `xml:"Version,attr"`
+	Destination                 string   `xml:"Destination,attr"`
+	AssertionConsumerServiceURL string   `xml:"AssertionConsumerServiceURL,attr"`
+	ProtocolBinding             string   `xml:"ProtocolBinding,attr"`
+	ForceAuthn                  bool     `xml:"ForceAuthn,attr"`
+	Issuer                      string   `xml:"urn:oasis:names:tc:SAML:2.0:assertion Issuer"`
+}
+
+// DecodeRedirectRequest decodes a SAMLRequest parameter of the HTTP-Redirect binding
+// (base64 encoded DEFLATE)
+func DecodeRedirectRequest(s string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrMalformedRequest
+	}
+	r := io.LimitReader(flate.NewReader(bytes.NewReader(data)), maxRequestSize+1)
+	data, err = ioutil.ReadAll(r)
+	if err != nil || len(data) > maxRequestSize {
+		return nil, ErrMalformedRequest
+	}
+	return data, nil
+}
+
+// DecodePOSTRequest decodes a SAMLRequest parameter of the HTTP-POST binding (base64
+// encoded)
+func DecodePOSTRequest(s string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(data) > maxRequestSize {
+		return nil, ErrMalformedRequest
+	}
+	return data, nil
+}
+
+// EncodeRedirectRequest encodes a request for the HTTP-Redirect binding
+func EncodeRedirectRequest(data []byte) (string, error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.DefaultCompression)
+	if err != nil {
+		return "", err
+	}
+	if _, err = w.Write(data); err != nil {
+		return "", err
+	}
+	if err = w.Close(); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// ParseAuthnRequest parses an authentication request. It must have an ID and an
+// issuer. Neither DTDs nor external entities are processed.
+func ParseAuthnRequest(data []byte) (*AuthnRequest, error) {
+	var req AuthnRequest
+	if err := xml.Unmarshal(data, &req); err != nil {
+		return nil, ErrMalformedRequest
+	}
+	if req.ID == "" || req.Issuer == "" || req.Version != "2.0" {
+		return nil, ErrMalformedRequest
+	}
+	return &req, nil
+}
+
+// Attribute is an attribute of a subject
+type Attribute struct {
+	Name   string
+	Values []string
+}
+
+//
+// Assertion describes an assertion about an authenticated subject issued to a
+// service provider in response to its request
+//
+type Assertion struct {
+	Issuer              string
+	Audience            string
+	Recipient           string
+	InResponseTo        string
+	NameID              string
+	SessionIndex        string
+	AuthnInstant        time.Time
+	SessionNotOnOrAfter time.Time
+	Attributes          []Attribute
+}
+
+// NewResponse creates a successful response to a service provider with an assertion
+// signed with a RSA key and carrying the key's X.509 certificate (DER)
+func NewResponse(a Assertion, key crypto.Signer, cert []byte, now time.Time) ([]byte, error) {
+	if _, ok := key.Public().(*rsa.PublicKey); !ok {
+		return nil, ErrUnsupportedKey
+	}
+	responseID, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	assertionID, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	now = now.UTC()
+	issueInstant := now.Format(timeFormat)
+	notOnOrAfter := now.Add(assertionTTL).Format(timeFormat)
+
+	attributes := newElement("saml:AttributeStatement")
+	for _, attribute := range a.Attributes {
+		e := newElement("saml:Attribute", "Name", attribute.Name, "NameFormat", AttributeNameFormatBasic)
+		for _, v := range attribute.Values {
+			e.add(textElement("saml:AttributeValue", v))
+		}
+		attributes.add(e)
+	}
+	assertion := newElement("saml:Assertion", "ID", assertionID, "IssueInstant", issueInstant, "Version", "2.0").add(
+		textElement("saml:Issuer", a.Issuer),
+		newElement("saml:Subject").add(
+			textElement("saml:NameID", a.NameID, "Format", NameIDFormatUnspecified),
+			newElement("saml:SubjectConfirmation", "Method", BearerConfirmation).add(
+				newElement("saml:SubjectConfirmationData",
+					"InResponseTo", a.InResponseTo, "NotOnOrAfter", notOnOrAfter, "Recipient", a.Recipient),
+			),
+		),
+		newElement("saml:Conditions",
+			"NotBefore", now.Add(-clockSkew).Format(timeFormat), "NotOnOrAfter", notOnOrAfter).add(
+			newElement("saml:AudienceRestriction").add(textElement("saml:Audience", a.Audience)),
+		),
+		newElement("saml:AuthnStatement",
+			"AuthnInstant", a.AuthnInstant.UTC().Format(timeFormat),
+			"SessionIndex", a.SessionIndex,
+			"SessionNotOnOrAfter", a.SessionNotOnOrAfter.UTC().Format(timeFormat)).add(
+			newElement("saml:AuthnContext").add(textElement("saml:AuthnContextClassRef", PasswordAuthnContext)),
+		),
+	)
+	if len(a.Attributes) > 0 {
+		assertion.add(attributes)
+	}
+
+	signature, err := sign(assertion, assertionID, key, cert)
+	if err != nil {
+		return nil, err
+	}
+	// The signature follows the issuer (SAML core section 5.4.1)
+	assertion.insert(1, signature)
+
+	response := newElement("samlp:Response",
+		"Destination", a.Recipient,
+		"ID", responseID,
+		"InResponseTo", a.InResponseTo,
+		"IssueInstant", issueInstant,
+		"Version", "2.0").add(
+		textElement("saml:Issuer", a.Issuer),
+		newElement("samlp:Status").add(newElement("samlp:StatusCode", "Value", StatusSuccess)),
+		assertion,
+	)
+	return append([]byte(xml.Header), response.canonical()...), nil
+}
+
+// Metadata describes an identity provider with a given entity ID, single sign-on
+// service URL and signing certificate (DER)
+func Metadata(entityID, ssoURL string, cert []byte) []byte {
+	descriptor := newElement("md:EntityDescriptor", "entityID", entityID).add(
+		newElement("md:IDPSSODescriptor",
+			"WantAuthnRequestsSigned", "false",
+			"protocolSupportEnumeration", ProtocolNamespace).add(
+			newElement("md:KeyDescriptor", "use", "signing").add(keyInfo(cert)),
+			textElement("md:NameIDFormat", NameIDFormatUnspecified),
+			newElement("md:SingleSignOnService", "Binding", HTTPRedirectBinding, "Location", ssoURL),
+			newElement("md:SingleSignOnService", "Binding", HTTPPOSTBinding, "Location", ssoURL),
+		),
+	)
+	return append([]byte(xml.Header), descriptor.canonical()...)
+}
+
+// NewCertificate creates a self-signed X.509 certificate (DER) of a RSA key. The
+// certificate only carries the key to service providers, so it's derived from the
+// key and a given name and issue time alone: the same certificate is created every
+// time.
+func NewCertificate(key crypto.Signer, commonName string, notBefore time.Time) ([]byte, error) {
+	if _, ok := key.Public().(*rsa.PublicKey); !ok {
+		return nil, ErrUnsupportedKey
+	}
+	public, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.Sum256(public)
+	template := &x509.Certificate{
+		SerialNumber:          new(big.Int).SetBytes(h[:16]),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             notBefore.UTC().Truncate(time.Second),
+		NotAfter:              notBefore.UTC().Truncate(time.Second).Add(certificateTTL),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		SignatureAlgorithm:    x509.SHA256WithRSA,
+		BasicConstraintsValid: true,
+	}
+	return x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+}
+
+// sign creates an enveloped signature of an element referenced by a given ID
+func sign(e *element, id string, key crypto.Signer, cert []byte) (*element, error) {
+	digest := sha256.Sum256(e.canonical())
+	signedInfo := newElement("ds:SignedInfo").add(
+		newElement("ds:CanonicalizationMethod", "Algorithm", exclusiveC14N),
+		newElement("ds:SignatureMethod", "Algorithm", rsaSHA256),
+		newElement("ds:Reference", "URI", "#"+id).add(
+			newElement("ds:Transforms").add(
+				newElement("ds:Transform", "Algorithm", envelopedSignature),
+				newElement("ds:Transform", "Algorithm", exclusiveC14N),
+			),
+			newElement("ds:DigestMethod", "Algorithm", sha256Digest),
+			textElement("ds:DigestValue", base64.StdEncoding.EncodeToString(digest[:])),
+		),
+	)
+	h := sha256.Sum256(signedInfo.canonical())
+	sig, err := key.Sign(rand.Reader, h[:], crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to sign: %v", err)
+	}
+	return newElement("ds:Signature").add(
+		signedInfo,
+		textElement("ds:SignatureValue", base64.StdEncoding.EncodeToString(sig)),
+		keyInfo(cert),
+	), nil
+}
+
+// keyInfo describes a key by its X.509 certificate (DER)
+func keyInfo(cert []byte) *element {
+	return newElement("ds:KeyInfo").add(
+		newElement("ds:X509Data").add(
+			textElement("ds:X509Certificate", base64.StdEncoding.EncodeToString(cert)),
+		),
+	)
+}
+
+// newID generates a random identifier of a protocol message or an assertion. It
+// starts with an underscore as IDs must be valid XML names.
+func newID() (string, error) {
+	b := make([]byte, 20)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return "", fmt.Errorf("Failed to generate ID: %v", err)
+	}
+	return "_" + hex.EncodeToString(b), nil
+}
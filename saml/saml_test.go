@@ -0,0 +1,225 @@
+package saml
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// signature is the part of an enveloped signature a service provider reads
+type signature struct {
+	SignedInfo struct {
+		Reference struct {
+			URI         string `xml:"URI,attr"`
+			DigestValue string
+		}
+	}
+	SignatureValue string
+	KeyInfo        struct {
+		X509Data struct {
+			X509Certificate string
+		}
+	}
+}
+
+// verify checks the signature of the assertion of a response the way a service
+// provider does: the assertion without the signature is canonicalized as a document
+// apex and digested, the signed info is canonicalized and verified with the
+// certificate. Both are canonical already, so only namespaces declared by ancestors
+// have to be added.
+func verify(res []byte) (*x509.Certificate, error) {
+	doc := string(bytes.TrimPrefix(res, []byte(xml.Header)))
+	start := strings.Index(doc, "<saml:Assertion ")
+	end := strings.Index(doc, "</saml:Assertion>")
+	sigStart := strings.Index(doc, "<ds:Signature ")
+	sigEnd := strings.Index(doc, "</ds:Signature>")
+	if start < 0 || sigStart < start || sigEnd < sigStart || end < sigEnd {
+		return nil, errors.New("response has no signed assertion")
+	}
+	sigEnd += len("</ds:Signature>")
+	end += len("</saml:Assertion>")
+	assertion := doc[start:sigStart] + doc[sigEnd:end]
+
+	var sig signature
+	if err := xml.Unmarshal([]byte(doc[sigStart:sigEnd]), &sig); err != nil {
+		return nil, err
+	}
+	var id struct {
+		ID string `xml:"ID,attr"`
+	}
+	if err := xml.Unmarshal([]byte(assertion), &id); err != nil {
+		return nil, err
+	}
+	if sig.SignedInfo.Reference.URI != "#"+id.ID {
+		return nil, errors.New("signature doesn't reference the assertion")
+	}
+	digest := sha256.Sum256([]byte(assertion))
+	if sig.SignedInfo.Reference.DigestValue != base64.StdEncoding.EncodeToString(digest[:]) {
+		return nil, errors.New("digest mismatch")
+	}
+
+	infoStart := strings.Index(doc[sigStart:], "<ds:SignedInfo>") + sigStart
+	infoEnd := strings.Index(doc, "</ds:SignedInfo>") + len("</ds:SignedInfo>")
+	signedInfo := `<ds:SignedInfo xmlns:ds="` + SignatureNamespace + `">` + doc[infoStart+len("<ds:SignedInfo>"):infoEnd]
+	der, err := base64.StdEncoding.DecodeString(sig.KeyInfo.X509Data.X509Certificate)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	value, err := base64.StdEncoding.DecodeString(sig.SignatureValue)
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.Sum256([]byte(signedInfo))
+	return cert, rsa.VerifyPKCS1v15(cert.PublicKey.(*rsa.PublicKey), crypto.SHA256, h[:], value)
+}
+
+func TestNewResponse(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	must(t, err)
+	now := time.Now()
+	cert, err := NewCertificate(key, "IdP", now)
+	must(t, err)
+	a := Assertion{
+		Issuer:              "https://idp.example.com/saml/metadata",
+		Audience:            "https://sp.example.com",
+		Recipient:           "https://sp.example.com/acs",
+		InResponseTo:        "_request",
+		NameID:              "john",
+		SessionIndex:        "reference",
+		AuthnInstant:        now,
+		SessionNotOnOrAfter: now.Add(time.Hour),
+		Attributes: []Attribute{
+			{Name: "roles", Values: []string{"R&D <lead>", "admin"}},
+			{Name: "domain", Values: []string{"domain1.com"}},
+		},
+	}
+	res, err := NewResponse(a, key, cert, now)
+	must(t, err)
+
+	signer, err := verify(res)
+	if err != nil {
+		t.Fatalf("Signature of the assertion: %v", err)
+	}
+	if !signer.PublicKey.(*rsa.PublicKey).Equal(&key.PublicKey) {
+		t.Error("Certificate of the signature isn't the key's")
+	}
+
+	var parsed struct {
+		InResponseTo string `xml:"InResponseTo,attr"`
+		Assertion    struct {
+			NameID     string   `xml:"Subject>NameID"`
+			Audience   string   `xml:"Conditions>AudienceRestriction>Audience"`
+			Attributes []string `xml:"AttributeStatement>Attribute>AttributeValue"`
+		}
+	}
+	must(t, xml.Unmarshal(res, &parsed))
+	if parsed.InResponseTo != a.InResponseTo || parsed.Assertion.NameID != "john" || parsed.Assertion.Audience != a.Audience ||
+		strings.Join(parsed.Assertion.Attributes, ",") != "R&D <lead>,admin,domain1.com" {
+		t.Errorf("Response = %+v", parsed)
+	}
+
+	// A changed assertion or a signature of another key doesn't verify
+	if _, err = verify(bytes.Replace(res, []byte(">john<"), []byte(">jane<"), 1)); err == nil {
+		t.Error("Signature of a changed assertion verified")
+	}
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	must(t, err)
+	otherCert, err := NewCertificate(other, "IdP", now)
+	must(t, err)
+	forged := bytes.Replace(res, []byte(base64.StdEncoding.EncodeToString(cert)), []byte(base64.StdEncoding.EncodeToString(otherCert)), 1)
+	if _, err = verify(forged); err == nil {
+		t.Error("Signature verified with the certificate of another key")
+	}
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	must(t, err)
+	if _, err = NewResponse(a, edKey, cert, now); err != ErrUnsupportedKey {
+		t.Errorf("NewResponse with an Ed25519 key = %v", err)
+	}
+}
+
+func TestNewCertificate(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	must(t, err)
+	now := time.Now()
+	der, err := NewCertificate(key, "IdP", now)
+	must(t, err)
+	cert, err := x509.ParseCertificate(der)
+	must(t, err)
+	if err = cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature); err != nil {
+		t.Errorf("Certificate isn't self-signed: %v", err)
+	}
+	if cert.Subject.CommonName != "IdP" || !cert.NotBefore.Equal(now.UTC().Truncate(time.Second)) {
+		t.Errorf("Certificate = %v, %v", cert.Subject, cert.NotBefore)
+	}
+
+	// The metadata carries the certificate
+	metadata := Metadata("https://idp.example.com/saml/metadata", "https://idp.example.com/saml/sso", der)
+	if !bytes.Contains(metadata, []byte(base64.StdEncoding.EncodeToString(der))) {
+		t.Error("Metadata doesn't carry the certificate")
+	}
+}
+
+func TestAuthnRequest(t *testing.T) {
+	data := []byte(`<samlp:AuthnRequest xmlns:samlp="` + ProtocolNamespace + `" xmlns:saml="` + AssertionNamespace + `"` +
+		` ID="_request" Version="2.0" AssertionConsumerServiceURL="https://sp.example.com/acs" ForceAuthn="true">` +
+		`<saml:Issuer>https://sp.example.com</saml:Issuer></samlp:AuthnRequest>`)
+
+	encoded, err := EncodeRedirectRequest(data)
+	must(t, err)
+	decoded, err := DecodeRedirectRequest(encoded)
+	if err != nil || !bytes.Equal(decoded, data) {
+		t.Fatalf("DecodeRedirectRequest = %s, %v", decoded, err)
+	}
+	req, err := ParseAuthnRequest(decoded)
+	must(t, err)
+	if req.ID != "_request" || req.Issuer != "https://sp.example.com" || req.AssertionConsumerServiceURL != "https://sp.example.com/acs" || !req.ForceAuthn {
+		t.Errorf("ParseAuthnRequest = %+v", req)
+	}
+
+	for _, s := range []string{"!", base64.StdEncoding.EncodeToString(data)} {
+		if _, err = DecodeRedirectRequest(s); err != ErrMalformedRequest {
+			t.Errorf("DecodeRedirectRequest(%q) = %v", s, err)
+		}
+	}
+	large, err := EncodeRedirectRequest(make([]byte, maxRequestSize+1))
+	must(t, err)
+	if _, err = DecodeRedirectRequest(large); err != ErrMalformedRequest {
+		t.Errorf("DecodeRedirectRequest of a too large request = %v", err)
+	}
+	if _, err = DecodePOSTRequest(base64.StdEncoding.EncodeToString(make([]byte, maxRequestSize+1))); err != ErrMalformedRequest {
+		t.Errorf("DecodePOSTRequest of a too large request = %v", err)
+	}
+
+	for _, invalid := range []string{
+		strings.Replace(string(data), `Version="2.0"`, `Version="1.1"`, 1),
+		strings.Replace(string(data), ` ID="_request"`, "", 1),
+		strings.Replace(string(data), "<saml:Issuer>https://sp.example.com</saml:Issuer>", "", 1),
+		strings.Replace(string(data), ProtocolNamespace, AssertionNamespace, 1),
+		`<!DOCTYPE r [<!ENTITY e SYSTEM "file:///etc/passwd">]>` + strings.Replace(string(data), "https://sp.example.com<", "&e;<", 1),
+	} {
+		if _, err = ParseAuthnRequest([]byte(invalid)); err != ErrMalformedRequest {
+			t.Errorf("ParseAuthnRequest(%s) = %v", invalid, err)
+		}
+	}
+}
+
+func must(t *testing.T, err error) {
+	if err != nil {
+		t.Fatal(err)
+	}
+}
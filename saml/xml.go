@@ -0,0 +1,126 @@
+package saml
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+)
+
+// namespaces maps prefixes of the elements written by this package to their URIs
+var namespaces = map[string]string{
+	"samlp": ProtocolNamespace,
+	"saml":  AssertionNamespace,
+	"ds":    SignatureNamespace,
+	"md":    MetadataNamespace,
+}
+
+// attr is an unqualified attribute of an element
+type attr struct {
+	name  string
+	value string
+}
+
+// attrsByName sorts unqualified attributes in canonical order
+type attrsByName []attr
+
+func (a attrsByName) Len() int           { return len(a) }
+func (a attrsByName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a attrsByName) Less(i, j int) bool { return a[i].name < a[j].name }
+
+//
+// element is an XML element with a prefixed name, unqualified attributes and either
+// child elements or text. It's written in exclusive canonical form (Exclusive XML
+// Canonicalization 1.0 without comments): namespaces are declared where they are
+// first used, attributes are sorted and empty elements have end tags. Hence the
+// bytes written are exactly what a verifier canonicalizes a signed element to.
+//
+type element struct {
+	name     string
+	attrs    []attr
+	children []*element
+	text     string
+}
+
+// newElement creates an element with attributes given as name/value pairs
+func newElement(name string, attrs ...string) *element {
+	e := &element{name: name}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		e.attrs = append(e.attrs, attr{attrs[i], attrs[i+1]})
+	}
+	return e
+}
+
+// textElement creates an element with text content and attributes given as
+// name/value pairs
+func textElement(name, text string, attrs ...string) *element {
+	e := newElement(name, attrs...)
+	e.text = text
+	return e
+}
+
+// add appends child elements and returns the element
+func (e *element) add(children ...*element) *element {
+	e.children = append(e.children, children...)
+	return e
+}
+
+// insert inserts a child element at a given position
+func (e *element) insert(i int, child *element) {
+	e.children = append(e.children, nil)
+	copy(e.children[i+1:], e.children[i:])
+	e.children[i] = child
+}
+
+// canonical returns the element written in exclusive canonical form as a document
+// apex
+func (e *element) canonical() []byte {
+	var buf bytes.Buffer
+	e.write(&buf, map[string]bool{})
+	return buf.Bytes()
+}
+
+// write writes the element given the prefixes declared by its ancestors
+func (e *element) write(buf *bytes.Buffer, declared map[string]bool) {
+	prefix := ""
+	if i := strings.Index(e.name, ":"); i >= 0 {
+		prefix = e.name[:i]
+	}
+	buf.WriteString("<" + e.name)
+	if prefix != "" && !declared[prefix] {
+		buf.WriteString(" xmlns:" + prefix + `="` + escapeAttr(namespaces[prefix]) + `"`)
+		scope := map[string]bool{prefix: true}
+		for p := range declared {
+			scope[p] = true
+		}
+		declared = scope
+	}
+	attrs := make([]attr, len(e.attrs))
+	copy(attrs, e.attrs)
+	sort.Sort(attrsByName(attrs))
+	for _, a := range attrs {
+		buf.WriteString(" " + a.name + `="` + escapeAttr(a.value) + `"`)
+	}
+	buf.WriteString(">")
+	if len(e.children) == 0 {
+		buf.WriteString(escapeText(e.text))
+	}
+	for _, c := range e.children {
+		c.write(buf, declared)
+	}
+	buf.WriteString("</" + e.name + ">")
+}
+
+var (
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+)
+
+// escapeText escapes character data the way canonical XML does
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+// escapeAttr escapes an attribute value the way canonical XML does
+func escapeAttr(s string) string {
+	return attrEscaper.Replace(s)
+}
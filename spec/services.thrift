@@ -39,6 +39,26 @@ struct Session {
     8: string expiresOn
 }
 
+/*
+ * Result of beginning a session: either the session opened or a challenge
+ * to complete with completeSessionMFA if the user has to provide an
+ * authentication code
+ */
+struct SessionChallenge {
+    1: optional Session session,
+    2: string challengeID,
+    3: string expiresOn
+}
+
+/*
+ * Permission entity
+ */
+struct Permission {
+    1: string name,
+    2: string description,
+    3: string evaluationRule
+}
+
 /**
  * Exception represents internal server error
  */
@@ -64,11 +84,15 @@ exception UnauthorizedError {
 }
 
 /**
- * Exception represents forbidden error
+ * Exception represents forbidden error. retryAfter is the number of seconds
+ * to wait before retrying if too many attempts have failed (0 otherwise),
+ * passwordExpired is set if the user has to change the password first
  */
 exception ForbiddenError {
     1: string msg,
-    2: string cause
+    2: string cause,
+    3: i32 retryAfter,
+    4: bool passwordExpired
 }
 
 /**
@@ -128,10 +152,44 @@ service IdentityProvider {
                                                                    4:ForbiddenError error4,
                                                                    5:NotFoundError error5),
 
-    # Assert permission for a current user
-    bool assertPermission(1:string sessionID, 2:string permissioName) throws (1:ServerError error1,
-                                                                              2:BadRequestError error2,
-                                                                              3:UnauthorizedError error3,
-                                                                              4:ForbiddenError error4,
-                                                                              5:NotFoundError error5),
+    # Assert permission for a current user. Attributes are exposed to the
+    # permission's evaluation rule as attrs.<name>
+    bool assertPermission(1:string sessionID,
+                          2:string permissioName,
+                          3:map<string,string> attributes) throws (1:ServerError error1,
+                                                                   2:BadRequestError error2,
+                                                                   3:UnauthorizedError error3,
+                                                                   4:ForbiddenError error4,
+                                                                   5:NotFoundError error5),
+
+    # List effective permissions of a current user. Permissions with an
+    # evaluation rule may still be denied by assertPermission
+    list<Permission> getEffectivePermissions(1:string sessionID) throws (1:ServerError error1,
+                                                                         2:BadRequestError error2,
+                                                                         3:UnauthorizedError error3,
+                                                                         4:ForbiddenError error4,
+                                                                         5:NotFoundError error5),
+
+    # Begin a new session. A challenge is returned instead of the session if
+    # the user has to provide an authentication code
+    SessionChallenge beginSession(1:string domain,
+                                  2:string name,
+                                  3:string password,
+                                  4:string userAgent,
+                                  5:string remoteAddr) throws (1:ServerError error1,
+                                                               2:BadRequestError error2,
+                                                               3:UnauthorizedError error3,
+                                                               4:ForbiddenError error4,
+                                                               5:NotFoundError error5),
+
+    # Complete a challenge returned by beginSession with a one-time password
+    # or a recovery code
+    Session completeSessionMFA(1:string challengeID,
+                               2:string code,
+                               3:string userAgent,
+                               4:string remoteAddr) throws (1:ServerError error1,
+                                                            2:BadRequestError error2,
+                                                            3:UnauthorizedError error3,
+                                                            4:ForbiddenError error4,
+                                                            5:NotFoundError error5),
 }
@@ -7,7 +7,7 @@
 
 # Reset DB
 idp-cli db drop --please
-idp-cli db create
+idp-cli db migrate up
 
 
 #
@@ -89,6 +89,11 @@ idp-cli roles add --name=manager --description="Basic manager"
 idp-cli roles add --name=moderator --description="Content moderator"
 idp-cli roles add --name=user --description="Basic user"
 idp-cli roles add --name=tester --description="Tester (disabled by default)" --disable
+idp-cli roles add --name=auditor --description="Financial auditor"
+idp-cli roles add --name=accountant --description="Accountant"
+
+# Nobody may be an auditor and an accountant at the same time
+idp-cli roles add-constraint --name=audit --role=auditor --role=accountant --max=1
 
 #
 # Permissions
@@ -98,10 +103,24 @@ echo "Creating permissions... "
 idp-cli permissions add --name="*" --description="Allow all"
 idp-cli permissions add --name="login" --description="Allow to sign in"
 idp-cli permissions add --name="roles.*" --description="Manage roles"
+idp-cli permissions add --name="roles.create" --description="Create roles"
+idp-cli permissions add --name="roles.read" --description="Read access to roles"
+idp-cli permissions add --name="roles.update" --description="Modify roles and their permissions"
+idp-cli permissions add --name="roles.delete" --description="Delete roles"
 idp-cli permissions add --name="permissions.*" --description="Manage permissions"
+idp-cli permissions add --name="permissions.create" --description="Create permissions"
+idp-cli permissions add --name="permissions.read" --description="Read access to permissions"
+idp-cli permissions add --name="permissions.update" --description="Modify permissions"
+idp-cli permissions add --name="permissions.delete" --description="Delete permissions"
 idp-cli permissions add --name="users.*" --description="Manage users"
+idp-cli permissions add --name="users.create" --description="Create users"
+idp-cli permissions add --name="users.read" --description="Read access to users"
+idp-cli permissions add --name="users.update" --description="Modify users and their roles"
+idp-cli permissions add --name="users.delete" --description="Delete users"
 idp-cli permissions add --name="domains.create" --description="Create domain"
 idp-cli permissions add --name="domains.read" --description="Read access to domains"
+idp-cli permissions add --name="domains.update" --description="Modify domains"
+idp-cli permissions add --name="domains.delete" --description="Delete domains"
 idp-cli permissions add --name="posts.create" --description="Allow to create new post"
 idp-cli permissions add --name="posts.delete" --description="Allow to delete any post"
 idp-cli permissions add --name="dummy" --description="Disabled dummy permission" --disable
@@ -135,6 +154,20 @@ idp-cli roles update \
     user
 idp-cli roles update --add "dummy" tester
 
+echo "Building role hierarchy... "
+
+# admin inherits manager's permissions and manager inherits user's permissions
+idp-cli roles update --inherit manager admin
+idp-cli roles update --inherit user manager
+
+echo "Assigning roles to users... "
+
+# Global assignment applies in all user's domains
+idp-cli users update --assign-role=user $U1
+# manager1 is an admin in domain1.com but a basic user in domain2.com
+idp-cli users update --assign-role=admin --role-domain="$ID1" $U4
+idp-cli users update --assign-role=user --role-domain="$ID2" $U4
+
 # List domains
 echo ""
 echo "DOMAINS"
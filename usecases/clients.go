@@ -0,0 +1,74 @@
+package usecases
+
+import (
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+)
+
+//
+// ClientInteractor is an interface that defines all OAuth client related use-cases
+// signatures
+//
+type ClientInteractor interface {
+	Create(client entities.Client) error
+	Delete(id string) error
+	Find(id string) (*entities.Client, error)
+	List(domainID string) ([]entities.Client, error)
+}
+
+// ClientInteractorImpl is an actual interactor that implements ClientInteractor
+type ClientInteractorImpl struct {
+	Clients     ClientRepository
+	Permissions PermissionRepository
+	Sessions    SessionRepository
+}
+
+// Create registers a new client. Its scopes must be names of existing permissions or
+// OpenID Connect scopes.
+func (inter *ClientInteractorImpl) Create(client entities.Client) error {
+	if ok, err := client.IsValid(); !ok {
+		return errs.NewUseCaseError(errs.ErrorTypeConflict, "Client is invalid", err)
+	}
+	for _, scope := range client.Scopes {
+		if entities.IsOpenIDScope(scope) {
+			continue
+		}
+		_, err := inter.Permissions.FindByName(scope)
+		if err != nil {
+			if e, ok := err.(*errs.Error); ok && e.Type == errs.ErrorTypeNotFound {
+				return errs.NewUseCaseError(errs.ErrorTypeConflict, "Scope is not a permission: "+scope, err)
+			}
+			return err
+		}
+	}
+	return inter.Clients.Create(client)
+}
+
+// Delete deletes a client along with its refresh tokens and the sessions they refresh
+func (inter *ClientInteractorImpl) Delete(id string) error {
+	tokens, err := inter.Clients.ListRefreshTokens(id)
+	if err != nil {
+		return err
+	}
+	err = inter.Clients.Delete(id)
+	if err != nil {
+		return err
+	}
+	for _, t := range tokens {
+		err = inter.Sessions.Delete(t.SessionID)
+		if e, ok := err.(*errs.Error); ok && e.Type != errs.ErrorTypeNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+// Find finds a client by given ID
+func (inter *ClientInteractorImpl) Find(id string) (*entities.Client, error) {
+	return inter.Clients.FindByID(id)
+}
+
+// List lists clients of a domain or all clients if the domain ID is empty
+func (inter *ClientInteractorImpl) List(domainID string) ([]entities.Client, error) {
+	return inter.Clients.List(domainID)
+}
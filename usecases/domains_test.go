@@ -0,0 +1,83 @@
+package usecases_test
+
+import (
+	"testing"
+
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+)
+
+func TestDomainInteractor(t *testing.T) {
+	f := newFixture()
+	d1 := f.domain(t, "domain1.com")
+	d2 := f.domain(t, "domain2.com")
+	f.user(t, "john", "secret", d1)
+	f.user(t, "jane", "secret", d1, d2)
+
+	if err := f.domains.Create(*entities.NewBasicDomain("", "")); errType(err) != errs.ErrorTypeConflict {
+		t.Errorf("Create of a domain without a name: %v", err)
+	}
+	if err := f.domains.Create(*entities.NewBasicDomain("domain1.com", "")); err == nil {
+		t.Error("Create of a duplicate domain succeeded")
+	}
+
+	d, err := f.domains.FindByName("domain2.com")
+	must(t, err)
+	if d.ID != d2.ID || !d.Enabled {
+		t.Errorf("FindByName = %+v, want %+v", d, d2)
+	}
+	d.Description = "Second"
+	d.Enabled = false
+	must(t, f.domains.Update(*d))
+	d, err = f.domains.Find(d2.ID)
+	must(t, err)
+	if d.Description != "Second" || d.Enabled {
+		t.Errorf("Find after Update = %+v", d)
+	}
+	if _, err = f.domains.Find("missing"); errType(err) != errs.ErrorTypeNotFound {
+		t.Errorf("Find of a missing domain: %v", err)
+	}
+
+	c, err := f.domains.List(entities.Pager{Page: 1, PerPage: 1}, entities.Sorter{Field: "name", Asc: false})
+	must(t, err)
+	if c.Paginator.Total != 2 || len(c.Domains) != 1 || c.Domains[0].Name != "domain2.com" || !c.Paginator.HasNextPage {
+		t.Errorf("List = %+v", c)
+	}
+	if n, err := f.domains.CountUsers(d1.ID); err != nil || n != 2 {
+		t.Errorf("CountUsers = %v, %v", n, err)
+	}
+	u, err := f.users.FindByNameInDomain("jane", d2.ID)
+	must(t, err)
+	c, err = f.domains.ListByUser(u.ID, firstPage, byName)
+	must(t, err)
+	if len(c.Domains) != 2 || c.Domains[0].UsersCount != 2 || c.Domains[1].UsersCount != 1 {
+		t.Errorf("ListByUser = %+v", c)
+	}
+}
+
+func TestDomainInteractorDelete(t *testing.T) {
+	f := newFixture()
+	d1 := f.domain(t, "domain1.com")
+	d2 := f.domain(t, "domain2.com")
+	u := f.user(t, "john", "secret", d1, d2)
+	_, err := f.sessions.Create(*d1, *u, "agent", "127.0.0.1")
+	must(t, err)
+	s2, err := f.sessions.Create(*d2, *u, "agent", "127.0.0.1")
+	must(t, err)
+
+	must(t, f.domains.Delete(d1.ID))
+	if _, err = f.domains.Find(d1.ID); errType(err) != errs.ErrorTypeNotFound {
+		t.Errorf("Find of a deleted domain: %v", err)
+	}
+	c, err := f.sessions.List(firstPage, entities.Sorter{})
+	must(t, err)
+	if len(c.Sessions) != 1 || c.Sessions[0].ID != s2.ID {
+		t.Errorf("Sessions left after Delete: %+v", c.Sessions)
+	}
+	if n, err := f.users.CountDomains(u.ID); err != nil || n != 1 {
+		t.Errorf("CountDomains = %v, %v", n, err)
+	}
+	if err = f.domains.Delete(d1.ID); errType(err) != errs.ErrorTypeNotFound {
+		t.Errorf("Delete of a deleted domain: %v", err)
+	}
+}
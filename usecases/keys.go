@@ -0,0 +1,273 @@
+package usecases
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/oleksandr/idp/config"
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+	"github.com/oleksandr/idp/jwt"
+	"golang.org/x/crypto/argon2"
+)
+
+// Parameters of deriving an encryption key of private signing keys and TOTP secrets
+// from a secret
+const (
+	sealSaltSize      = 16
+	sealArgon2Time    = 1
+	sealArgon2Memory  = 64 * 1024
+	sealArgon2Threads = 2
+)
+
+// sealCacheSize is the number of ciphers a sealer keeps before the cache is cleared
+const sealCacheSize = 1024
+
+//
+// sealer encrypts private keys and other secrets. Every sealed secret has its own
+// salt, so the cipher deriving its key (which takes 64 MiB and most of the time) is
+// cached by salt and secret and verifying a TOTP code costs a decryption only.
+//
+type sealer struct {
+	mu      sync.Mutex
+	ciphers map[string]cipher.AEAD
+}
+
+//
+// KeyInteractor is an interface that defines all signing key related use-cases
+// signatures
+//
+type KeyInteractor interface {
+	Generate(algorithm string) (*entities.SigningKey, error)
+	Activate(id string) error
+	Retire(id string) error
+	List() ([]entities.SigningKey, error)
+	PublicKeys() (*jwt.JWKSet, error)
+	SigningKey() (*jwt.Key, error)
+}
+
+// KeyInteractorImpl is an actual interactor that implements KeyInteractor. Private
+// keys are encrypted with a key derived from a secret, decrypted keys are cached.
+type KeyInteractorImpl struct {
+	Keys   KeyRepository
+	Secret []byte
+
+	mu     sync.Mutex
+	cache  map[string]*jwt.Key
+	sealer sealer
+}
+
+// Generate generates a new RS256 or EdDSA key. The key is published right away but
+// doesn't sign tokens until it's activated.
+func (inter *KeyInteractorImpl) Generate(algorithm string) (*entities.SigningKey, error) {
+	if len(inter.Secret) == 0 {
+		return nil, errs.NewUseCaseError(errs.ErrorTypeConflict, "Key encryption secret is not configured", nil)
+	}
+	if algorithm != jwt.RS256 && algorithm != jwt.EdDSA {
+		return nil, errs.NewUseCaseError(errs.ErrorTypeConflict, "Unsupported key algorithm", nil)
+	}
+
+	k, err := jwt.GenerateKey("", algorithm)
+	if err != nil {
+		return nil, errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to generate a key", err)
+	}
+	public, err := k.MarshalPublicKey()
+	if err != nil {
+		return nil, errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to encode a public key", err)
+	}
+	private, err := k.MarshalPrivateKey()
+	if err != nil {
+		return nil, errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to encode a private key", err)
+	}
+
+	key := entities.NewSigningKey(algorithm, public)
+	sealed, err := inter.sealer.seal(inter.Secret, key.ID, private)
+	if err != nil {
+		return nil, errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to encrypt a private key", err)
+	}
+	err = inter.Keys.Create(*key, sealed)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Activate makes a key sign new tokens instead of the currently active key, which
+// is retired
+func (inter *KeyInteractorImpl) Activate(id string) error {
+	key, err := inter.Keys.FindByID(id)
+	if err != nil {
+		return err
+	}
+	switch key.Status {
+	case entities.SigningKeyActive:
+		return errs.NewUseCaseError(errs.ErrorTypeConflict, "Key is already active", nil)
+	case entities.SigningKeyRetired:
+		return errs.NewUseCaseError(errs.ErrorTypeConflict, "Retired key can't be activated", nil)
+	}
+	// Make sure the key can be decrypted before it's used
+	if _, err = inter.privateKey(key.ID); err != nil {
+		return err
+	}
+	return inter.Keys.Activate(id, time.Now().UTC())
+}
+
+// Retire stops a key from signing new tokens. It's still published until the
+// tokens it has signed expire.
+func (inter *KeyInteractorImpl) Retire(id string) error {
+	key, err := inter.Keys.FindByID(id)
+	if err != nil {
+		return err
+	}
+	if key.Status == entities.SigningKeyRetired {
+		return errs.NewUseCaseError(errs.ErrorTypeConflict, "Key is already retired", nil)
+	}
+	return inter.Keys.Retire(id, time.Now().UTC())
+}
+
+// List lists all keys ordered by creation date/time
+func (inter *KeyInteractorImpl) List() ([]entities.SigningKey, error) {
+	return inter.Keys.List()
+}
+
+// PublicKeys returns the set of published public keys
+func (inter *KeyInteractorImpl) PublicKeys() (*jwt.JWKSet, error) {
+	keys, err := inter.Keys.List()
+	if err != nil {
+		return nil, err
+	}
+
+	set := &jwt.JWKSet{Keys: []jwt.JWK{}}
+	now := time.Now().UTC()
+	ttl := time.Duration(config.AccessTokenTTLMinutes()) * time.Minute
+	for _, key := range keys {
+		if !key.IsPublished(now, ttl) {
+			continue
+		}
+		k, err := jwt.ParsePublicKey(key.ID, key.PublicKey)
+		if err != nil {
+			return nil, errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to decode a public key", err)
+		}
+		jwk, err := k.JWK()
+		if err != nil {
+			return nil, errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to encode a public key", err)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set, nil
+}
+
+// SigningKey returns the active key or nil if there is none
+func (inter *KeyInteractorImpl) SigningKey() (*jwt.Key, error) {
+	keys, err := inter.Keys.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		if key.Status == entities.SigningKeyActive {
+			return inter.privateKey(key.ID)
+		}
+	}
+	return nil, nil
+}
+
+// privateKey loads and decrypts a private key unless it's cached
+func (inter *KeyInteractorImpl) privateKey(id string) (*jwt.Key, error) {
+	inter.mu.Lock()
+	defer inter.mu.Unlock()
+
+	if k, ok := inter.cache[id]; ok {
+		return k, nil
+	}
+	if len(inter.Secret) == 0 {
+		return nil, errs.NewUseCaseError(errs.ErrorTypeConflict, "Key encryption secret is not configured", nil)
+	}
+	sealed, err := inter.Keys.FindPrivateKey(id)
+	if err != nil {
+		return nil, err
+	}
+	private, err := inter.sealer.unseal(inter.Secret, id, sealed)
+	if err != nil {
+		return nil, errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to decrypt a private key", err)
+	}
+	k, err := jwt.ParsePrivateKey(id, private)
+	if err != nil {
+		return nil, errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to decode a private key", err)
+	}
+
+	if inter.cache == nil {
+		inter.cache = map[string]*jwt.Key{}
+	}
+	inter.cache[id] = k
+	return k, nil
+}
+
+// seal encrypts a private key (or another secret) with AES-256-GCM using a key
+// derived from a secret with argon2id and a random salt. The ID of the key (or of
+// the secret's owner) is authenticated as well, so sealed data can't be swapped.
+// The result is salt, nonce and ciphertext.
+func (s *sealer) seal(secret []byte, id string, private []byte) ([]byte, error) {
+	salt := make([]byte, sealSaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	aead, err := s.cipher(secret, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	sealed := append(salt, nonce...)
+	return aead.Seal(sealed, nonce, private, []byte(id)), nil
+}
+
+// unseal decrypts data encrypted by seal
+func (s *sealer) unseal(secret []byte, id string, sealed []byte) ([]byte, error) {
+	if len(sealed) < sealSaltSize {
+		return nil, errors.New("Sealed data is too short")
+	}
+	aead, err := s.cipher(secret, sealed[:sealSaltSize])
+	if err != nil {
+		return nil, err
+	}
+	sealed = sealed[sealSaltSize:]
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("Sealed data is too short")
+	}
+	return aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(id))
+}
+
+// cipher returns a cipher of a key derived from a secret and a salt unless it's
+// cached. Derivations are serialized, so concurrent requests can't exhaust memory.
+func (s *sealer) cipher(secret, salt []byte) (cipher.AEAD, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := string(salt) + string(secret)
+	if aead, ok := s.ciphers[id]; ok {
+		return aead, nil
+	}
+	key := argon2.IDKey(secret, salt, sealArgon2Time, sealArgon2Memory, sealArgon2Threads, 32)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.ciphers == nil || len(s.ciphers) >= sealCacheSize {
+		s.ciphers = map[string]cipher.AEAD{}
+	}
+	s.ciphers[id] = aead
+	return aead, nil
+}
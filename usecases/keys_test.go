@@ -0,0 +1,73 @@
+package usecases_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/jwt"
+	"github.com/oleksandr/idp/memory"
+	"github.com/oleksandr/idp/usecases"
+)
+
+func TestKeyInteractorRotation(t *testing.T) {
+	f := newFixture()
+	repo := &memory.KeyRepository{Store: f.store}
+	keys := &usecases.KeyInteractorImpl{Keys: repo, Secret: []byte("secret")}
+	f.tokens.Keys = keys
+	d := f.domain(t, "domain1.com")
+	u := f.user(t, "john", "secret", d)
+	s, err := f.sessions.Create(*d, *u, "agent", "127.0.0.1")
+	must(t, err)
+
+	// published verifies a token with the JWKS as a service fetching it would
+	published := func(token string) error {
+		set, err := keys.PublicKeys()
+		must(t, err)
+		b, err := json.Marshal(set)
+		must(t, err)
+		var parsed jwt.JWKSet
+		must(t, json.Unmarshal(b, &parsed))
+		return jwt.Verify(token, parsed.PublicKeys(), &entities.AccessTokenClaims{})
+	}
+
+	old, err := keys.Generate(jwt.RS256)
+	must(t, err)
+	must(t, keys.Activate(old.ID))
+	oldToken, err := f.tokens.Issue(*s)
+	must(t, err)
+
+	current, err := keys.Generate(jwt.EdDSA)
+	must(t, err)
+	must(t, keys.Activate(current.ID))
+	token, err := f.tokens.Issue(*s)
+	must(t, err)
+	if k, err := keys.SigningKey(); err != nil || k.ID != current.ID {
+		t.Fatalf("SigningKey after rotation = %+v, %v", k, err)
+	}
+	if k, _ := repo.FindByID(old.ID); k.Status != entities.SigningKeyRetired {
+		t.Errorf("Status of the previously active key = %v", k.Status)
+	}
+
+	for _, tok := range []string{oldToken.Token, token.Token} {
+		if err = published(tok); err != nil {
+			t.Errorf("Verify with the JWKS while the old key is still published: %v", err)
+		}
+		if _, err = f.tokens.Verify(tok); err != nil {
+			t.Errorf("TokenInteractor.Verify while the old key is still published: %v", err)
+		}
+	}
+
+	// The old key is no longer published once tokens it has signed have expired
+	must(t, repo.Retire(old.ID, time.Now().UTC().Add(-time.Hour)))
+	if err = published(oldToken.Token); err != jwt.ErrUnknownKey {
+		t.Errorf("Verify with the JWKS once the old key isn't published = %v", err)
+	}
+	if _, err = f.tokens.Verify(oldToken.Token); err == nil {
+		t.Error("TokenInteractor.Verify of a token of a key which isn't published succeeded")
+	}
+	if err = published(token.Token); err != nil {
+		t.Errorf("Verify with the JWKS and the current key: %v", err)
+	}
+}
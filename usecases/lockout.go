@@ -0,0 +1,180 @@
+package usecases
+
+import (
+	"time"
+
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+)
+
+//
+// LockoutInteractor is an interface that defines all use-cases signatures related to
+// throttling of failed attempts to sign in
+//
+type LockoutInteractor interface {
+	Check(scope, subject string) error
+	Reserve(scope, subject string) error
+	Release(scope, subject string, failed bool) error
+	Fail(scope, subject string) error
+	Reset(scope, subject string) error
+	Unlock(userID string) error
+	Purge() error
+}
+
+// LockoutInteractorImpl is an actual interactor that implements LockoutInteractor.
+// Thresholds are the numbers of failures per scope which lock a subject out for
+// Duration; scopes without a threshold aren't tracked. Until then every failure of a
+// user or from a remote address delays further attempts by Delay, which doubles with
+// every failure. Domains are only locked out, so that guessing spread over many users
+// and addresses is stopped without slowing down everybody else. Failures are
+// forgotten after Duration without failed or blocked attempts. Attempts which can be
+// guessed (passwords, codes) are reserved before they're checked, so concurrent
+// attempts can't get past a threshold between a check and a failure.
+type LockoutInteractorImpl struct {
+	Lockout    LockoutRepository
+	Users      UserRepository
+	Thresholds map[string]int
+	Delay      time.Duration
+	Duration   time.Duration
+}
+
+// Check fails with a forbidden error hinting when to retry if attempts of a subject
+// are blocked
+func (inter *LockoutInteractorImpl) Check(scope, subject string) error {
+	if subject == "" || inter.Thresholds[scope] <= 0 {
+		return nil
+	}
+	f, err := inter.Lockout.Find(scope, subject)
+	if err != nil {
+		if e, ok := err.(*errs.Error); ok && e.Type == errs.ErrorTypeNotFound {
+			return nil
+		}
+		return err
+	}
+	if d := f.RetryAfter(); d > 0 {
+		e := errs.NewUseCaseError(errs.ErrorTypeForbidden, "Too many failed attempts, try again later", nil)
+		e.RetryAfter = d
+		return e
+	}
+	return nil
+}
+
+// Reserve counts an attempt of a subject as failed in advance and fails with a
+// forbidden error hinting when to retry if attempts are blocked or the attempt would
+// exceed the threshold along with the ones being checked concurrently. The attempt
+// isn't counted then, otherwise it has to be concluded with Release().
+func (inter *LockoutInteractorImpl) Reserve(scope, subject string) error {
+	threshold := inter.Thresholds[scope]
+	if subject == "" || threshold <= 0 {
+		return nil
+	}
+	now := time.Now().UTC()
+	n, err := inter.Lockout.AddFailure(scope, subject, now, now.Add(-inter.Duration))
+	if err != nil {
+		return err
+	}
+	f, err := inter.Lockout.Find(scope, subject)
+	if err != nil {
+		// Failures have been reset meanwhile
+		if e, ok := err.(*errs.Error); ok && e.Type == errs.ErrorTypeNotFound {
+			return nil
+		}
+		return err
+	}
+
+	d := f.RetryAfter()
+	if d <= 0 && n <= threshold {
+		return nil
+	}
+	if err = inter.Lockout.RemoveFailure(scope, subject); err != nil {
+		return err
+	}
+	if d <= 0 {
+		// The attempts being checked block further ones if they fail
+		d = time.Second
+	}
+	e := errs.NewUseCaseError(errs.ErrorTypeForbidden, "Too many failed attempts, try again later", nil)
+	e.RetryAfter = d
+	return e
+}
+
+// Release concludes an attempt reserved by Reserve(). A failed attempt stays counted
+// and blocks further attempts like Fail() does, a successful one is taken back.
+func (inter *LockoutInteractorImpl) Release(scope, subject string, failed bool) error {
+	if subject == "" || inter.Thresholds[scope] <= 0 {
+		return nil
+	}
+	if !failed {
+		return inter.Lockout.RemoveFailure(scope, subject)
+	}
+	f, err := inter.Lockout.Find(scope, subject)
+	if err != nil {
+		if e, ok := err.(*errs.Error); ok && e.Type == errs.ErrorTypeNotFound {
+			return nil
+		}
+		return err
+	}
+	return inter.block(scope, subject, f.Failures, time.Now().UTC())
+}
+
+// Fail counts a failed attempt of a subject and blocks further attempts for a while
+func (inter *LockoutInteractorImpl) Fail(scope, subject string) error {
+	if subject == "" || inter.Thresholds[scope] <= 0 {
+		return nil
+	}
+	now := time.Now().UTC()
+	n, err := inter.Lockout.AddFailure(scope, subject, now, now.Add(-inter.Duration))
+	if err != nil {
+		return err
+	}
+	return inter.block(scope, subject, n, now)
+}
+
+// Reset forgets failed attempts of a subject
+func (inter *LockoutInteractorImpl) Reset(scope, subject string) error {
+	if subject == "" || inter.Thresholds[scope] <= 0 {
+		return nil
+	}
+	return inter.Lockout.Delete(scope, subject)
+}
+
+// Unlock forgets failed attempts to sign in as a user, so the user isn't locked out
+// anymore
+func (inter *LockoutInteractorImpl) Unlock(userID string) error {
+	u, err := inter.Users.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	return inter.Lockout.Delete(entities.LockoutScopeUser, u.ID)
+}
+
+// Purge purges all failures which are forgotten
+func (inter *LockoutInteractorImpl) Purge() error {
+	return inter.Lockout.DeleteStale(time.Now().UTC().Add(-inter.Duration))
+}
+
+// block blocks attempts of a subject after a given number of failures
+func (inter *LockoutInteractorImpl) block(scope, subject string, failures int, now time.Time) error {
+	var d time.Duration
+	if failures >= inter.Thresholds[scope] {
+		d = inter.Duration
+	} else if scope != entities.LockoutScopeDomain {
+		d = inter.delay(failures)
+	}
+	if d <= 0 {
+		return nil
+	}
+	return inter.Lockout.Block(scope, subject, now.Add(d))
+}
+
+// delay returns how long attempts are delayed after a given number of failures
+func (inter *LockoutInteractorImpl) delay(failures int) time.Duration {
+	d := inter.Delay
+	for i := 1; i < failures && d < inter.Duration; i++ {
+		d *= 2
+	}
+	if d > inter.Duration {
+		d = inter.Duration
+	}
+	return d
+}
@@ -0,0 +1,188 @@
+package usecases_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+	"github.com/oleksandr/idp/memory"
+)
+
+// retryAfter returns the retry hint of a lockout error
+func retryAfter(t *testing.T, err error) time.Duration {
+	t.Helper()
+	e, ok := err.(*errs.Error)
+	if !ok || e.Type != errs.ErrorTypeForbidden || e.RetryAfter <= 0 {
+		t.Fatalf("Lockout error = %v", err)
+	}
+	return e.RetryAfter
+}
+
+func TestLockoutInteractorThreshold(t *testing.T) {
+	f := newFixture()
+	d := f.domain(t, "domain1.com")
+	u := f.user(t, "john", "secret", d)
+	user := entities.LockoutScopeUser
+
+	for i := 0; i < 4; i++ {
+		must(t, f.lockout.Fail(user, u.ID))
+		must(t, f.lockout.Check(user, u.ID))
+	}
+	must(t, f.lockout.Fail(user, u.ID))
+	if d := retryAfter(t, f.lockout.Check(user, u.ID)); d > time.Minute || d < 59*time.Second {
+		t.Errorf("RetryAfter at the threshold = %v", d)
+	}
+	retryAfter(t, f.lockout.Reserve(user, u.ID))
+	if _, err := f.sessions.CreateWithPassword(*d, *u, "secret", "agent", "127.0.0.1"); errType(err) != errs.ErrorTypeForbidden {
+		t.Errorf("CreateWithPassword of a locked out user: %v", err)
+	}
+
+	// Other subjects and scopes aren't blocked
+	must(t, f.lockout.Check(user, "other"))
+	must(t, f.lockout.Check(entities.LockoutScopeAddress, u.ID))
+	must(t, f.lockout.Check(entities.LockoutScopeDomain, u.ID))
+
+	if err := f.lockout.Unlock("missing"); errType(err) != errs.ErrorTypeNotFound {
+		t.Errorf("Unlock of an unknown user: %v", err)
+	}
+	must(t, f.lockout.Unlock(u.ID))
+	must(t, f.lockout.Check(user, u.ID))
+	if _, err := f.sessions.CreateWithPassword(*d, *u, "secret", "agent", "127.0.0.1"); err != nil {
+		t.Errorf("CreateWithPassword of an unlocked user: %v", err)
+	}
+}
+
+func TestLockoutInteractorScopes(t *testing.T) {
+	f := newFixture()
+	f.lockout.Delay = time.Second
+	f.lockout.Thresholds[entities.LockoutScopeDomain] = 3
+
+	// Every failure doubles the delay of further attempts
+	for i, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		must(t, f.lockout.Fail(entities.LockoutScopeAddress, "10.0.0.1"))
+		if d := retryAfter(t, f.lockout.Check(entities.LockoutScopeAddress, "10.0.0.1")); d > want || d < want-time.Second {
+			t.Errorf("RetryAfter after %v failures = %v, want %v", i+1, d, want)
+		}
+	}
+
+	// Domains are only locked out
+	for i := 0; i < 2; i++ {
+		must(t, f.lockout.Fail(entities.LockoutScopeDomain, "domain"))
+		must(t, f.lockout.Check(entities.LockoutScopeDomain, "domain"))
+	}
+	must(t, f.lockout.Fail(entities.LockoutScopeDomain, "domain"))
+	retryAfter(t, f.lockout.Check(entities.LockoutScopeDomain, "domain"))
+
+	// Scopes without a threshold and unknown subjects aren't tracked
+	delete(f.lockout.Thresholds, entities.LockoutScopeDomain)
+	must(t, f.lockout.Check(entities.LockoutScopeDomain, "domain"))
+	for i := 0; i < 10; i++ {
+		must(t, f.lockout.Fail(entities.LockoutScopeDomain, "other"))
+		must(t, f.lockout.Fail(entities.LockoutScopeUser, ""))
+	}
+	must(t, f.lockout.Reserve(entities.LockoutScopeDomain, "other"))
+	must(t, f.lockout.Check(entities.LockoutScopeUser, ""))
+	if _, err := (&memory.LockoutRepository{Store: f.store}).Find(entities.LockoutScopeDomain, "other"); err == nil {
+		t.Error("Failures of a scope without a threshold are counted")
+	}
+}
+
+func TestLockoutInteractorWindow(t *testing.T) {
+	f := newFixture()
+	repo := &memory.LockoutRepository{Store: f.store}
+	user := entities.LockoutScopeUser
+	old := time.Now().UTC().Add(-2 * time.Minute)
+
+	// A lockout expires, the failures are kept until the window has passed
+	for i := 0; i < 5; i++ {
+		_, err := repo.AddFailure(user, "john", old, old.Add(-time.Minute))
+		must(t, err)
+	}
+	must(t, repo.Block(user, "john", old.Add(time.Second)))
+	must(t, f.lockout.Check(user, "john"))
+
+	// Failures are forgotten after a window without failed or blocked attempts
+	must(t, f.lockout.Fail(user, "john"))
+	must(t, f.lockout.Check(user, "john"))
+	if found, err := repo.Find(user, "john"); err != nil || found.Failures != 1 {
+		t.Errorf("Failures after the window = %+v, %v", found, err)
+	}
+
+	_, err := repo.AddFailure(user, "jane", old, old.Add(-time.Minute))
+	must(t, err)
+	must(t, f.lockout.Purge())
+	if _, err = repo.Find(user, "jane"); errType(err) != errs.ErrorTypeNotFound {
+		t.Errorf("Find of purged failures: %v", err)
+	}
+	if _, err = repo.Find(user, "john"); err != nil {
+		t.Errorf("Purge deleted recent failures: %v", err)
+	}
+}
+
+func TestLockoutInteractorReserve(t *testing.T) {
+	f := newFixture()
+	repo := &memory.LockoutRepository{Store: f.store}
+	user := entities.LockoutScopeUser
+
+	// Attempts being checked count against the threshold
+	for i := 0; i < 5; i++ {
+		must(t, f.lockout.Reserve(user, "john"))
+	}
+	retryAfter(t, f.lockout.Reserve(user, "john"))
+	must(t, f.lockout.Check(user, "john"))
+
+	// A successful attempt is taken back, a failed one blocks when it's the last one
+	must(t, f.lockout.Release(user, "john", false))
+	if found, _ := repo.Find(user, "john"); found.Failures != 4 {
+		t.Errorf("Failures after a successful attempt = %v", found.Failures)
+	}
+	must(t, f.lockout.Reserve(user, "john"))
+	must(t, f.lockout.Release(user, "john", true))
+	retryAfter(t, f.lockout.Check(user, "john"))
+
+	// Releasing attempts of reset failures doesn't count them again
+	must(t, f.lockout.Reset(user, "john"))
+	must(t, f.lockout.Release(user, "john", true))
+	must(t, f.lockout.Release(user, "john", false))
+	must(t, f.lockout.Check(user, "john"))
+}
+
+func TestLockoutInteractorConcurrency(t *testing.T) {
+	f := newFixture()
+	d := f.domain(t, "domain1.com")
+	u := f.user(t, "john", "secret", d)
+
+	// Concurrent guesses can't get past the threshold between a check and a failure
+	const guesses = 30
+	errors := make(chan error, guesses)
+	var wg sync.WaitGroup
+	for i := 0; i < guesses; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := f.sessions.CreateWithPassword(*d, *u, "wrong", "agent", "127.0.0.1")
+			errors <- err
+		}()
+	}
+	wg.Wait()
+	close(errors)
+
+	checked := 0
+	for err := range errors {
+		switch errType(err) {
+		case errs.ErrorTypeUnauthorized:
+			checked++
+		case errs.ErrorTypeForbidden:
+		default:
+			t.Errorf("CreateWithPassword with an invalid password: %v", err)
+		}
+	}
+	if checked != 5 {
+		t.Errorf("%v of %v concurrent guesses were checked, want 5", checked, guesses)
+	}
+	if _, err := f.sessions.CreateWithPassword(*d, *u, "secret", "agent", "127.0.0.1"); errType(err) != errs.ErrorTypeForbidden {
+		t.Errorf("CreateWithPassword after concurrent guesses: %v", err)
+	}
+}
@@ -0,0 +1,236 @@
+package usecases
+
+import (
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/oleksandr/idp/config"
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+)
+
+const (
+	// mfaIssuer labels TOTP secrets in authenticator apps unless the issuer is configured
+	mfaIssuer = "IdP"
+	// maxMFAAttempts is the number of codes a challenge may be attempted with
+	maxMFAAttempts = 5
+)
+
+//
+// MFAInteractor is an interface that defines all multi-factor authentication related
+// use-cases signatures
+//
+type MFAInteractor interface {
+	Enroll(userID string) (*entities.MFACredentials, error)
+	Reset(userID string) error
+	Find(userID string) (*entities.MFAEnrollment, error)
+	Verify(userID, code string) error
+	Challenge(user entities.BasicUser, domain entities.BasicDomain, userAgent, remoteAddr string) (*entities.MFAChallenge, error)
+	CompleteChallenge(id, code, userAgent, remoteAddr string) (*entities.MFAChallenge, error)
+	Purge() error
+}
+
+// MFAInteractorImpl is an actual interactor that implements MFAInteractor. TOTP
+// secrets are encrypted the same way private signing keys are, so the secret
+// (IDP_KEYS_SECRET) must not be lost or changed once users are enrolled; their keys
+// are derived once and cached, so verifying a code is cheap. Invalid
+// codes of challenges count as failed attempts to sign in as their users, so
+// guessing codes is throttled the same way guessing passwords is.
+type MFAInteractorImpl struct {
+	MFA     MFARepository
+	Users   UserRepository
+	Lockout LockoutInteractor
+	Secret  []byte
+
+	sealer sealer
+}
+
+// Enroll enrolls a user in multi-factor authentication with a new TOTP secret and
+// recovery codes, which are returned to be shown to the user once
+func (inter *MFAInteractorImpl) Enroll(userID string) (*entities.MFACredentials, error) {
+	if len(inter.Secret) == 0 {
+		return nil, errs.NewUseCaseError(errs.ErrorTypeConflict, "Key encryption secret is not configured", nil)
+	}
+	u, err := inter.Users.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	_, err = inter.MFA.FindByUser(u.ID)
+	if err == nil {
+		return nil, errs.NewUseCaseError(errs.ErrorTypeConflict, "User is already enrolled, reset the enrollment first", nil)
+	}
+	if e, ok := err.(*errs.Error); !ok || e.Type != errs.ErrorTypeNotFound {
+		return nil, err
+	}
+
+	secret, err := entities.GenerateTOTPSecret()
+	if err != nil {
+		return nil, errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to generate a TOTP secret", err)
+	}
+	codes, err := entities.GenerateRecoveryCodes()
+	if err != nil {
+		return nil, errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to generate recovery codes", err)
+	}
+	sealed, err := inter.sealer.seal(inter.Secret, u.ID, secret)
+	if err != nil {
+		return nil, errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to encrypt a TOTP secret", err)
+	}
+	hashes := []string{}
+	for _, code := range codes {
+		hashes = append(hashes, entities.HashRecoveryCode(code))
+	}
+	err = inter.MFA.Create(*entities.NewMFAEnrollment(u.ID), sealed, hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entities.MFACredentials{
+		Secret:        entities.EncodeTOTPSecret(secret),
+		URI:           entities.TOTPKeyURI(issuerLabel(), u.Name, secret),
+		RecoveryCodes: codes,
+	}, nil
+}
+
+// Reset deletes a user's enrollment along with the recovery codes, e.g. when the
+// authenticator is lost. The user has to be enrolled again unless MFA is optional.
+func (inter *MFAInteractorImpl) Reset(userID string) error {
+	return inter.MFA.Delete(userID)
+}
+
+// Find finds an enrollment of a user
+func (inter *MFAInteractorImpl) Find(userID string) (*entities.MFAEnrollment, error) {
+	return inter.MFA.FindByUser(userID)
+}
+
+// Verify checks a one-time password or a recovery code of an enrolled user. Either
+// can be used only once. Invalid codes are reported as an unauthorized error.
+func (inter *MFAInteractorImpl) Verify(userID, code string) error {
+	invalid := errs.NewUseCaseError(errs.ErrorTypeUnauthorized, "Invalid authentication code", nil)
+	enrollment, err := inter.MFA.FindByUser(userID)
+	if err != nil {
+		if e, ok := err.(*errs.Error); ok && e.Type == errs.ErrorTypeNotFound {
+			return invalid
+		}
+		return err
+	}
+
+	if !entities.IsTOTPCode(code) {
+		err = inter.MFA.UseRecoveryCode(enrollment.UserID, entities.HashRecoveryCode(code))
+		if e, ok := err.(*errs.Error); ok && e.Type == errs.ErrorTypeNotFound {
+			return invalid
+		}
+		return err
+	}
+
+	if len(inter.Secret) == 0 {
+		return errs.NewUseCaseError(errs.ErrorTypeOperational, "Key encryption secret is not configured", nil)
+	}
+	sealed, err := inter.MFA.FindSecret(enrollment.UserID)
+	if err != nil {
+		return err
+	}
+	secret, err := inter.sealer.unseal(inter.Secret, enrollment.UserID, sealed)
+	if err != nil {
+		return errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to decrypt a TOTP secret", err)
+	}
+	step, ok := entities.VerifyTOTP(secret, code, time.Now().UTC(), enrollment.LastStep)
+	if !ok {
+		return invalid
+	}
+	// The step is recorded conditionally, so a concurrent request can't reuse the code
+	err = inter.MFA.UseStep(enrollment.UserID, step)
+	if e, ok := err.(*errs.Error); ok && e.Type == errs.ErrorTypeConflict {
+		return invalid
+	}
+	return err
+}
+
+// Challenge creates a challenge a user has to complete to open a session in a domain
+func (inter *MFAInteractorImpl) Challenge(user entities.BasicUser, domain entities.BasicDomain, userAgent, remoteAddr string) (*entities.MFAChallenge, error) {
+	c := entities.NewMFAChallenge(user, domain, userAgent, remoteAddr)
+	err := inter.MFA.CreateChallenge(*c)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// CompleteChallenge completes a challenge with a one-time password or a recovery code
+// and deletes it. The challenge must be completed from the user agent and remote
+// address it was created for; it's deleted after maxMFAAttempts invalid codes. Only
+// an invalid code is reported as an unauthorized error, so another one may be tried.
+// Codes are rejected while the user is locked out, an invalid code counts as a failed
+// attempt of the user and a valid one resets the user's failures.
+func (inter *MFAInteractorImpl) CompleteChallenge(id, code, userAgent, remoteAddr string) (*entities.MFAChallenge, error) {
+	notFound := errs.NewUseCaseError(errs.ErrorTypeNotFound, "Challenge not found", nil)
+	c, err := inter.MFA.FindChallenge(id)
+	if err != nil {
+		if e, ok := err.(*errs.Error); ok && e.Type == errs.ErrorTypeNotFound {
+			return nil, notFound
+		}
+		return nil, err
+	}
+	if c.UserAgent != userAgent || c.RemoteAddr != remoteAddr {
+		return nil, notFound
+	}
+	if c.IsExpired() {
+		inter.MFA.DeleteChallenge(c.ID)
+		return nil, errs.NewUseCaseError(errs.ErrorTypeNotFound, "Challenge expired", nil)
+	}
+	if err = inter.Lockout.Reserve(entities.LockoutScopeUser, c.UserID); err != nil {
+		return nil, err
+	}
+
+	err = inter.MFA.AddChallengeAttempt(c.ID, maxMFAAttempts)
+	if err != nil {
+		inter.Lockout.Release(entities.LockoutScopeUser, c.UserID, false)
+		if e, ok := err.(*errs.Error); ok && e.Type == errs.ErrorTypeConflict {
+			inter.MFA.DeleteChallenge(c.ID)
+			return nil, errs.NewUseCaseError(errs.ErrorTypeForbidden, "Too many invalid codes, sign in again", err)
+		}
+		return nil, err
+	}
+	err = inter.Verify(c.UserID, code)
+	failed := false
+	if e, ok := err.(*errs.Error); ok && e.Type == errs.ErrorTypeUnauthorized {
+		failed = true
+	}
+	if e := inter.Lockout.Release(entities.LockoutScopeUser, c.UserID, failed); e != nil {
+		return nil, e
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Deleting the challenge makes sure it's completed only once
+	err = inter.MFA.DeleteChallenge(c.ID)
+	if err != nil {
+		if e, ok := err.(*errs.Error); ok && e.Type == errs.ErrorTypeNotFound {
+			return nil, notFound
+		}
+		return nil, err
+	}
+	if err = inter.Lockout.Reset(entities.LockoutScopeUser, c.UserID); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Purge purges all expired challenges
+func (inter *MFAInteractorImpl) Purge() error {
+	return inter.MFA.DeleteExpiredChallenges(time.Now().UTC())
+}
+
+// issuerLabel returns the host of the configured issuer to label TOTP secrets with.
+// The port is dropped, since authenticator apps split labels by colons.
+func issuerLabel() string {
+	u, err := url.Parse(config.JWTIssuer())
+	if err != nil || u.Host == "" {
+		return mfaIssuer
+	}
+	if host, _, err := net.SplitHostPort(u.Host); err == nil {
+		return host
+	}
+	return u.Host
+}
@@ -0,0 +1,107 @@
+package usecases_test
+
+import (
+	"encoding/base32"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+)
+
+// totpCodes returns the current one-time password of an enrollment along with a
+// code which isn't valid around now
+func totpCodes(t *testing.T, c *entities.MFACredentials) (string, string) {
+	secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(c.Secret)
+	must(t, err)
+	step := entities.TOTPStep(time.Now().UTC())
+	valid := map[string]bool{}
+	for s := step - 1; s <= step+1; s++ {
+		valid[entities.TOTP(secret, s)] = true
+	}
+	for i := 0; ; i++ {
+		if invalid := fmt.Sprintf("%06d", i); !valid[invalid] {
+			return entities.TOTP(secret, step), invalid
+		}
+	}
+}
+
+func TestMFAInteractorLockout(t *testing.T) {
+	f := newFixture()
+	d := f.domain(t, "domain1.com")
+	u := f.user(t, "john", "secret", d)
+	creds, err := f.mfa.Enroll(u.ID)
+	must(t, err)
+	code, invalid := totpCodes(t, creds)
+
+	begin := func() *entities.MFAChallenge {
+		_, c, err := f.sessions.BeginWithPassword(*d, *u, "secret", "agent", "127.0.0.1")
+		must(t, err)
+		if c == nil {
+			t.Fatal("BeginWithPassword of an enrolled user opened a session")
+		}
+		return c
+	}
+	c := begin()
+	for i := 0; i < 3; i++ {
+		if _, err = f.sessions.CompleteMFA(c.ID, invalid, "agent", "127.0.0.1"); errType(err) != errs.ErrorTypeUnauthorized {
+			t.Fatalf("CompleteMFA %v with an invalid code: %v", i, err)
+		}
+	}
+	c = begin()
+	for i := 3; i < 5; i++ {
+		if _, err = f.sessions.CompleteMFA(c.ID, invalid, "agent", "127.0.0.1"); errType(err) != errs.ErrorTypeUnauthorized {
+			t.Fatalf("CompleteMFA %v with an invalid code: %v", i, err)
+		}
+	}
+
+	_, err = f.sessions.CompleteMFA(c.ID, code, "agent", "127.0.0.1")
+	if e, ok := err.(*errs.Error); !ok || e.Type != errs.ErrorTypeForbidden || e.RetryAfter <= 0 {
+		t.Errorf("CompleteMFA of a locked out user: %v", err)
+	}
+	if _, _, err = f.sessions.BeginWithPassword(*d, *u, "secret", "agent", "127.0.0.1"); errType(err) != errs.ErrorTypeForbidden {
+		t.Errorf("BeginWithPassword of a locked out user: %v", err)
+	}
+
+	must(t, f.lockout.Unlock(u.ID))
+	s, err := f.sessions.CompleteMFA(c.ID, code, "agent", "127.0.0.1")
+	if err != nil || s.User.ID != u.ID {
+		t.Errorf("CompleteMFA of an unlocked user = %+v, %v", s, err)
+	}
+}
+
+func TestMFAInteractorLockoutReset(t *testing.T) {
+	f := newFixture()
+	d := f.domain(t, "domain1.com")
+	u := f.user(t, "john", "secret", d)
+	creds, err := f.mfa.Enroll(u.ID)
+	must(t, err)
+	code, _ := totpCodes(t, creds)
+
+	fail := func(n int) {
+		for i := 0; i < n; i++ {
+			if _, _, err := f.sessions.BeginWithPassword(*d, *u, "wrong", "agent", "127.0.0.1"); errType(err) != errs.ErrorTypeUnauthorized {
+				t.Fatalf("BeginWithPassword %v with an invalid password: %v", i, err)
+			}
+		}
+	}
+	fail(4)
+	_, c, err := f.sessions.BeginWithPassword(*d, *u, "secret", "agent", "127.0.0.1")
+	must(t, err)
+	_, _, err = f.sessions.BeginWithPassword(*d, *u, "secret", "agent", "127.0.0.1")
+	must(t, err)
+	fail(1)
+	if _, _, err = f.sessions.BeginWithPassword(*d, *u, "secret", "agent", "127.0.0.1"); errType(err) != errs.ErrorTypeForbidden {
+		t.Fatalf("BeginWithPassword after a valid password without a code reset failures: %v", err)
+	}
+
+	must(t, f.lockout.Unlock(u.ID))
+	fail(4)
+	_, err = f.sessions.CompleteMFA(c.ID, code, "agent", "127.0.0.1")
+	must(t, err)
+	fail(4)
+	if _, c, err = f.sessions.BeginWithPassword(*d, *u, "secret", "agent", "127.0.0.1"); err != nil || c == nil {
+		t.Errorf("BeginWithPassword after a completed challenge = %+v, %v, want failures reset", c, err)
+	}
+}
@@ -0,0 +1,702 @@
+package usecases
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"time"
+
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+)
+
+// OAuth 2.0 error codes (RFC 6749 sections 4.1.2.1 and 5.2, RFC 6750 section 3.1,
+// RFC 7009 section 2.2.1)
+const (
+	OAuthInvalidRequest          = "invalid_request"
+	OAuthInvalidClient           = "invalid_client"
+	OAuthInvalidGrant            = "invalid_grant"
+	OAuthUnauthorizedClient      = "unauthorized_client"
+	OAuthUnsupportedGrantType    = "unsupported_grant_type"
+	OAuthInvalidScope            = "invalid_scope"
+	OAuthUnsupportedTokenType    = "unsupported_token_type"
+	OAuthUnsupportedResponseType = "unsupported_response_type"
+	OAuthAccessDenied            = "access_denied"
+	OAuthInvalidToken            = "invalid_token"
+	OAuthInsufficientScope       = "insufficient_scope"
+)
+
+const (
+	// refreshTokenSize is the number of random bytes of a refresh token
+	refreshTokenSize = 32
+	// authorizationCodeSize is the number of random bytes of an authorization code
+	authorizationCodeSize = 32
+	// authorizationCodeTTL is how long an authorization code may be exchanged for tokens
+	authorizationCodeTTL = time.Minute
+)
+
+//
+// OAuthError is an error reported to an OAuth client as is. Other errors are
+// internal ones.
+//
+type OAuthError struct {
+	Code        string
+	Description string
+}
+
+func (err *OAuthError) Error() string {
+	return err.Code + ": " + err.Description
+}
+
+//
+// OAuthInteractor is an interface that defines all OAuth 2.0 authorization server
+// use-cases signatures
+//
+type OAuthInteractor interface {
+	Authenticate(clientID, secret string) (*entities.Client, error)
+	ValidateAuthorization(req entities.AuthorizationRequest) (*entities.Client, string, error)
+	Authorize(client entities.Client, req entities.AuthorizationRequest, userName, password, userAgent, remoteAddr string) (string, *entities.MFAChallenge, error)
+	AuthorizeMFA(client entities.Client, req entities.AuthorizationRequest, challengeID, code, userAgent, remoteAddr string) (string, error)
+	ExchangeCode(client entities.Client, code, redirectURI, codeVerifier string) (*entities.TokenGrant, error)
+	GrantClientCredentials(client entities.Client, scopes []string) (*entities.TokenGrant, error)
+	GrantPassword(client entities.Client, userName, password string, scopes []string, userAgent, remoteAddr string) (*entities.TokenGrant, error)
+	Refresh(client entities.Client, refreshToken string, scopes []string) (*entities.TokenGrant, error)
+	Revoke(client entities.Client, token string) error
+	Introspect(client entities.Client, token string) (*entities.TokenInfo, error)
+	UserInfo(accessToken string) (*entities.UserInfo, error)
+	Purge() error
+}
+
+// OAuthInteractorImpl is an actual interactor that implements OAuthInteractor.
+// Scopes are names of permissions: a client may request the scopes it's registered
+// with, a user's token gets those of them the user's effective permissions cover.
+// The password grant and the authorization code grant open a session of the user,
+// which a refresh token is bound to. Access tokens are issued by Tokens, so are
+// OpenID Connect ID tokens if the openid scope is granted for a session.
+type OAuthInteractorImpl struct {
+	Clients  ClientRepository
+	Domains  DomainRepository
+	Sessions SessionInteractor
+	Users    UserInteractor
+	RBAC     RBACInteractor
+	Tokens   TokenInteractor
+}
+
+// Authenticate finds an enabled client of an enabled domain by its ID and secret.
+// Public clients are identified by their IDs only and must not send a secret.
+func (inter *OAuthInteractorImpl) Authenticate(clientID, secret string) (*entities.Client, error) {
+	client, err := inter.findClient(clientID)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return nil, &OAuthError{OAuthInvalidClient, "Client authentication failed"}
+	}
+	if client.IsPublic() {
+		if secret != "" {
+			return nil, &OAuthError{OAuthInvalidClient, "Client authentication failed"}
+		}
+	} else if !client.IsSecret(secret) {
+		return nil, &OAuthError{OAuthInvalidClient, "Client authentication failed"}
+	}
+	return client, nil
+}
+
+// ValidateAuthorization checks an authorization request and returns the client along
+// with the redirect URI to send the user back to. The redirect URI may be left out if
+// the client has just one. If the client or the redirect URI is invalid the returned
+// redirect URI is empty and the error must be shown to the user instead.
+func (inter *OAuthInteractorImpl) ValidateAuthorization(req entities.AuthorizationRequest) (*entities.Client, string, error) {
+	client, err := inter.findClient(req.ClientID)
+	if err != nil {
+		return nil, "", err
+	}
+	if client == nil {
+		return nil, "", &OAuthError{OAuthInvalidRequest, "Unknown client"}
+	}
+	redirectURI := req.RedirectURI
+	if redirectURI == "" && len(client.RedirectURIs) == 1 {
+		redirectURI = client.RedirectURIs[0]
+	}
+	if !client.AllowsRedirectURI(redirectURI) {
+		return nil, "", &OAuthError{OAuthInvalidRequest, "Redirect URI is not registered for the client"}
+	}
+
+	if req.ResponseType != "code" {
+		return client, redirectURI, &OAuthError{OAuthUnsupportedResponseType, "Only the code response type is supported"}
+	}
+	if !client.AllowsGrantType(entities.GrantTypeAuthorizationCode) {
+		return client, redirectURI, &OAuthError{OAuthUnauthorizedClient, "Grant type is not allowed for the client"}
+	}
+	if req.CodeChallenge == "" {
+		return client, redirectURI, &OAuthError{OAuthInvalidRequest, "Code challenge is required"}
+	}
+	if req.CodeChallengeMethod != entities.CodeChallengeS256 {
+		return client, redirectURI, &OAuthError{OAuthInvalidRequest, "Transform algorithm not supported"}
+	}
+	if len(req.CodeChallenge) != 43 || !isPKCEString(req.CodeChallenge) {
+		return client, redirectURI, &OAuthError{OAuthInvalidRequest, "Invalid code challenge"}
+	}
+	for _, scope := range req.Scopes {
+		if !client.AllowsScope(scope) {
+			return client, redirectURI, &OAuthError{OAuthInvalidScope, "Scope is not allowed for the client: " + scope}
+		}
+	}
+	return client, redirectURI, nil
+}
+
+// Authorize logs a user of the client's domain in for a request validated by
+// ValidateAuthorization and returns an authorization code. The code is bound to
+// the session opened for the user and may be exchanged once within a minute.
+// Invalid credentials are reported with the access_denied code, while attempts
+// rejected after too many failures and expired passwords are returned as they are. A challenge is
+// returned instead of the code if the user has to provide a code as well, see
+// AuthorizeMFA.
+func (inter *OAuthInteractorImpl) Authorize(client entities.Client, req entities.AuthorizationRequest, userName, password, userAgent, remoteAddr string) (string, *entities.MFAChallenge, error) {
+	domain := entities.BasicDomain{}
+	domain.ID = client.DomainID
+	user := entities.BasicUser{}
+	user.Name = userName
+	session, challenge, err := inter.Sessions.BeginWithPassword(domain, user, password, clientUserAgent(client, userAgent), remoteAddr)
+	if err != nil {
+		if e, ok := err.(*errs.Error); ok && e.Type != errs.ErrorTypeOperational && e.Type != errs.ErrorTypePasswordExpired && e.RetryAfter == 0 {
+			return "", nil, &OAuthError{OAuthAccessDenied, "Invalid resource owner credentials"}
+		}
+		return "", nil, err
+	}
+	if challenge != nil {
+		return "", challenge, nil
+	}
+	code, err := inter.issueCode(client, req, session)
+	if err != nil {
+		return "", nil, err
+	}
+	return code, nil, nil
+}
+
+// AuthorizeMFA completes a challenge returned by Authorize with a one-time password
+// or a recovery code and returns an authorization code. Errors of the challenge are
+// returned as they are, so the user can be asked for another code.
+func (inter *OAuthInteractorImpl) AuthorizeMFA(client entities.Client, req entities.AuthorizationRequest, challengeID, code, userAgent, remoteAddr string) (string, error) {
+	session, err := inter.Sessions.CompleteMFA(challengeID, code, clientUserAgent(client, userAgent), remoteAddr)
+	if err != nil {
+		return "", err
+	}
+	if session.Domain.ID != client.DomainID {
+		return "", errs.NewUseCaseError(errs.ErrorTypeForbidden, "Session is not of the client's domain", nil)
+	}
+	return inter.issueCode(client, req, session)
+}
+
+// issueCode issues an authorization code of a session for a request
+func (inter *OAuthInteractorImpl) issueCode(client entities.Client, req entities.AuthorizationRequest, session *entities.Session) (string, error) {
+	scopes, err := inter.grantScopes(client, session, req.Scopes)
+	if err != nil {
+		return "", err
+	}
+
+	b := make([]byte, authorizationCodeSize)
+	if _, err = io.ReadFull(rand.Reader, b); err != nil {
+		return "", errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to generate an authorization code", err)
+	}
+	code := hex.EncodeToString(b)
+	c := entities.AuthorizationCode{
+		Hash:          entities.HashToken(code),
+		ClientID:      client.ID,
+		SessionID:     session.ID,
+		RedirectURI:   req.RedirectURI,
+		Scopes:        scopes,
+		Nonce:         req.Nonce,
+		CodeChallenge: req.CodeChallenge,
+	}
+	c.CreatedOn.Time = time.Now().UTC()
+	c.ExpiresOn.Time = c.CreatedOn.Add(authorizationCodeTTL)
+	err = inter.Clients.CreateAuthorizationCode(c)
+	if err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// ExchangeCode exchanges an authorization code for an access token of the session
+// it's bound to along with a refresh token (if the client may refresh tokens). The
+// redirect URI must be the one of the authorization request and the code verifier
+// must match the code challenge (RFC 7636).
+func (inter *OAuthInteractorImpl) ExchangeCode(client entities.Client, code, redirectURI, codeVerifier string) (*entities.TokenGrant, error) {
+	if !client.AllowsGrantType(entities.GrantTypeAuthorizationCode) {
+		return nil, &OAuthError{OAuthUnauthorizedClient, "Grant type is not allowed for the client"}
+	}
+	if len(codeVerifier) < 43 || len(codeVerifier) > 128 || !isPKCEString(codeVerifier) {
+		return nil, &OAuthError{OAuthInvalidRequest, "Invalid code verifier"}
+	}
+	c, err := inter.Clients.TakeAuthorizationCode(entities.HashToken(code))
+	if err != nil {
+		if e, ok := err.(*errs.Error); ok && e.Type == errs.ErrorTypeNotFound {
+			return nil, &OAuthError{OAuthInvalidGrant, "Invalid authorization code"}
+		}
+		return nil, err
+	}
+	if c.ClientID != client.ID || c.IsExpired() || c.RedirectURI != redirectURI {
+		return nil, &OAuthError{OAuthInvalidGrant, "Invalid authorization code"}
+	}
+	if !c.VerifyCodeVerifier(codeVerifier) {
+		return nil, &OAuthError{OAuthInvalidGrant, "Code verifier doesn't match the code challenge"}
+	}
+
+	session, err := inter.Sessions.Find(c.SessionID)
+	if err != nil {
+		if e, ok := err.(*errs.Error); ok && e.Type == errs.ErrorTypeNotFound {
+			return nil, &OAuthError{OAuthInvalidGrant, "Invalid authorization code"}
+		}
+		return nil, err
+	}
+	if session.IsExpired() {
+		return nil, &OAuthError{OAuthInvalidGrant, "Invalid authorization code"}
+	}
+	return inter.issueWithRefreshToken(client, session, c.Scopes, c.Nonce)
+}
+
+// GrantClientCredentials issues an access token to a client itself
+func (inter *OAuthInteractorImpl) GrantClientCredentials(client entities.Client, scopes []string) (*entities.TokenGrant, error) {
+	if !client.AllowsGrantType(entities.GrantTypeClientCredentials) {
+		return nil, &OAuthError{OAuthUnauthorizedClient, "Grant type is not allowed for the client"}
+	}
+	scopes, err := inter.grantScopes(client, nil, scopes)
+	if err != nil {
+		return nil, err
+	}
+	return inter.issue(client, nil, scopes, "")
+}
+
+// GrantPassword opens a session of a user of the client's domain and issues an access
+// token of the session along with a refresh token (if the client may refresh tokens)
+func (inter *OAuthInteractorImpl) GrantPassword(client entities.Client, userName, password string, scopes []string, userAgent, remoteAddr string) (*entities.TokenGrant, error) {
+	if !client.AllowsGrantType(entities.GrantTypePassword) {
+		return nil, &OAuthError{OAuthUnauthorizedClient, "Grant type is not allowed for the client"}
+	}
+	session, err := inter.openSession(client, userName, password, userAgent, remoteAddr)
+	if err != nil {
+		return nil, err
+	}
+	scopes, err = inter.grantScopes(client, session, scopes)
+	if err != nil {
+		return nil, err
+	}
+	return inter.issueWithRefreshToken(client, session, scopes, "")
+}
+
+// openSession opens a session of a user of the client's domain. Invalid credentials
+// and expired passwords are reported with the invalid_grant code.
+func (inter *OAuthInteractorImpl) openSession(client entities.Client, userName, password, userAgent, remoteAddr string) (*entities.Session, error) {
+	domain := entities.BasicDomain{}
+	domain.ID = client.DomainID
+	user := entities.BasicUser{}
+	user.Name = userName
+	session, err := inter.Sessions.CreateWithPassword(domain, user, password, clientUserAgent(client, userAgent), remoteAddr)
+	if err != nil {
+		if e, ok := err.(*errs.Error); ok && e.Type == errs.ErrorTypePasswordExpired {
+			return nil, &OAuthError{OAuthInvalidGrant, e.Msg}
+		}
+		if e, ok := err.(*errs.Error); ok && e.Type != errs.ErrorTypeOperational {
+			return nil, &OAuthError{OAuthInvalidGrant, "Invalid resource owner credentials"}
+		}
+		return nil, err
+	}
+	return session, nil
+}
+
+// issueWithRefreshToken issues an access token of a session along with a refresh token
+// bound to it if the client may refresh tokens
+func (inter *OAuthInteractorImpl) issueWithRefreshToken(client entities.Client, session *entities.Session, scopes []string, nonce string) (*entities.TokenGrant, error) {
+	grant, err := inter.issue(client, session, scopes, nonce)
+	if err != nil {
+		return nil, err
+	}
+	if !client.AllowsGrantType(entities.GrantTypeRefreshToken) {
+		return grant, nil
+	}
+
+	b := make([]byte, refreshTokenSize)
+	if _, err = io.ReadFull(rand.Reader, b); err != nil {
+		return nil, errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to generate a refresh token", err)
+	}
+	grant.RefreshToken = hex.EncodeToString(b)
+	t := entities.RefreshToken{
+		Hash:      entities.HashToken(grant.RefreshToken),
+		ClientID:  client.ID,
+		SessionID: session.ID,
+		Scopes:    scopes,
+	}
+	t.CreatedOn.Time = time.Now().UTC()
+	t.ExpiresOn = session.ExpiresOn
+	err = inter.Clients.CreateRefreshToken(t)
+	if err != nil {
+		return nil, err
+	}
+	return grant, nil
+}
+
+// Refresh retains the session a refresh token is bound to and issues a new access
+// token of it. The scopes may be narrowed down but never extended.
+func (inter *OAuthInteractorImpl) Refresh(client entities.Client, refreshToken string, scopes []string) (*entities.TokenGrant, error) {
+	if !client.AllowsGrantType(entities.GrantTypeRefreshToken) {
+		return nil, &OAuthError{OAuthUnauthorizedClient, "Grant type is not allowed for the client"}
+	}
+	t, session, err := inter.findRefreshToken(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil || t.ClientID != client.ID {
+		return nil, &OAuthError{OAuthInvalidGrant, "Invalid refresh token"}
+	}
+
+	for _, scope := range scopes {
+		if !scopeCovered(t.Scopes, scope) {
+			return nil, &OAuthError{OAuthInvalidScope, "Scope exceeds the granted one: " + scope}
+		}
+	}
+	if len(scopes) == 0 {
+		scopes = t.Scopes
+	}
+	scopes, err = inter.grantScopes(client, session, scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	err = inter.Sessions.Retain(*session)
+	if err != nil {
+		return nil, err
+	}
+	session, err = inter.Sessions.Find(session.ID)
+	if err != nil {
+		return nil, err
+	}
+	err = inter.Clients.RetainRefreshToken(t.Hash, session.ExpiresOn.Time)
+	if err != nil {
+		return nil, err
+	}
+	grant, err := inter.issue(client, session, scopes, "")
+	if err != nil {
+		return nil, err
+	}
+	grant.RefreshToken = refreshToken
+	return grant, nil
+}
+
+// Revoke revokes a refresh token or an access token of a client (RFC 7009) by
+// deleting the session they belong to. Unknown tokens and tokens of other clients
+// are ignored. Access tokens of the client credentials grant aren't tracked and
+// can't be revoked.
+func (inter *OAuthInteractorImpl) Revoke(client entities.Client, token string) error {
+	t, err := inter.Clients.FindRefreshToken(entities.HashToken(token))
+	if err == nil {
+		if t.ClientID != client.ID {
+			return nil
+		}
+		err = inter.Clients.DeleteRefreshToken(t.Hash)
+		if err != nil {
+			return err
+		}
+		return inter.deleteSession(inter.Sessions.Find(t.SessionID))
+	} else if e, ok := err.(*errs.Error); !ok || e.Type != errs.ErrorTypeNotFound {
+		return err
+	}
+
+	claims, err := inter.Tokens.Verify(token)
+	if err != nil {
+		if e, ok := err.(*errs.Error); ok && e.Type == errs.ErrorTypeUnauthorized {
+			return nil
+		}
+		return err
+	}
+	if claims.ClientID != client.ID {
+		return nil
+	}
+	if claims.SessionRef == "" {
+		return &OAuthError{OAuthUnsupportedTokenType, "Access tokens of the client credentials grant can't be revoked"}
+	}
+	return inter.deleteSession(inter.Sessions.FindByReference(claims.SessionRef))
+}
+
+// Introspect describes a refresh token or an access token (RFC 7662) issued in the
+// domain of the client. A token is active unless it has expired or the session it
+// belongs to has been deleted or has expired. Public clients may not introspect tokens.
+func (inter *OAuthInteractorImpl) Introspect(client entities.Client, token string) (*entities.TokenInfo, error) {
+	if client.IsPublic() {
+		return nil, &OAuthError{OAuthInvalidClient, "Public clients can't introspect tokens"}
+	}
+	inactive := &entities.TokenInfo{Active: false}
+
+	t, session, err := inter.findRefreshToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if t != nil {
+		if session.Domain.ID != client.DomainID {
+			return inactive, nil
+		}
+		return &entities.TokenInfo{
+			Active:    true,
+			Scope:     entities.FormatScope(t.Scopes),
+			ClientID:  t.ClientID,
+			Username:  session.User.Name,
+			ExpiresAt: t.ExpiresOn.Unix(),
+			IssuedAt:  t.CreatedOn.Unix(),
+			Subject:   session.User.ID,
+			DomainID:  session.Domain.ID,
+		}, nil
+	}
+
+	claims, err := inter.Tokens.Verify(token)
+	if err != nil {
+		if e, ok := err.(*errs.Error); ok && e.Type == errs.ErrorTypeUnauthorized {
+			return inactive, nil
+		}
+		return nil, err
+	}
+	if claims.DomainID != client.DomainID {
+		return inactive, nil
+	}
+	info := &entities.TokenInfo{
+		Active:    true,
+		Scope:     claims.Scope,
+		ClientID:  claims.ClientID,
+		TokenType: "Bearer",
+		ExpiresAt: claims.ExpiresAt,
+		IssuedAt:  claims.IssuedAt,
+		Subject:   claims.Subject,
+		Issuer:    claims.Issuer,
+		ID:        claims.ID,
+		DomainID:  claims.DomainID,
+	}
+	if claims.SessionRef != "" {
+		session, err := inter.Sessions.FindByReference(claims.SessionRef)
+		if err != nil {
+			if e, ok := err.(*errs.Error); ok && e.Type == errs.ErrorTypeNotFound {
+				return inactive, nil
+			}
+			return nil, err
+		}
+		if session.IsExpired() {
+			return inactive, nil
+		}
+		info.Username = session.User.Name
+	}
+	return info, nil
+}
+
+// UserInfo returns claims about the user of an access token (OpenID Connect Core
+// section 5.3). The token must be issued for a session which hasn't ended, with the
+// openid scope granted. Claims other than the subject and the user's domain depend
+// on the profile and email scopes.
+func (inter *OAuthInteractorImpl) UserInfo(accessToken string) (*entities.UserInfo, error) {
+	claims, err := inter.Tokens.Verify(accessToken)
+	if err != nil {
+		if e, ok := err.(*errs.Error); ok && e.Type == errs.ErrorTypeUnauthorized {
+			return nil, &OAuthError{OAuthInvalidToken, "Invalid access token"}
+		}
+		return nil, err
+	}
+	scopes := entities.ParseScope(claims.Scope)
+	if claims.SessionRef == "" || !entities.HasScope(scopes, entities.ScopeOpenID) {
+		return nil, &OAuthError{OAuthInsufficientScope, "Access token is not granted the openid scope"}
+	}
+	session, err := inter.Sessions.FindByReference(claims.SessionRef)
+	if err != nil {
+		if e, ok := err.(*errs.Error); ok && e.Type == errs.ErrorTypeNotFound {
+			return nil, &OAuthError{OAuthInvalidToken, "Session has ended"}
+		}
+		return nil, err
+	}
+	if session.IsExpired() {
+		return nil, &OAuthError{OAuthInvalidToken, "Session has ended"}
+	}
+	user, err := inter.Users.Find(claims.Subject)
+	if err != nil {
+		if e, ok := err.(*errs.Error); ok && e.Type == errs.ErrorTypeNotFound {
+			return nil, &OAuthError{OAuthInvalidToken, "User not found"}
+		}
+		return nil, err
+	}
+
+	info := &entities.UserInfo{
+		Subject:  user.ID,
+		Domain:   session.Domain.Name,
+		DomainID: session.Domain.ID,
+	}
+	if entities.HasScope(scopes, entities.ScopeProfile) {
+		info.Name = user.Name
+		info.PreferredUsername = user.Name
+	}
+	if entities.HasScope(scopes, entities.ScopeEmail) {
+		if email := user.Email(); email != "" {
+			verified := false
+			info.Email, info.EmailVerified = email, &verified
+		}
+	}
+	return info, nil
+}
+
+// Purge purges all expired refresh tokens and authorization codes
+func (inter *OAuthInteractorImpl) Purge() error {
+	now := time.Now().UTC()
+	if err := inter.Clients.DeleteExpiredRefreshTokens(now); err != nil {
+		return err
+	}
+	return inter.Clients.DeleteExpiredAuthorizationCodes(now)
+}
+
+// findClient finds an enabled client of an enabled domain. Nothing is returned if
+// there is no such client.
+func (inter *OAuthInteractorImpl) findClient(id string) (*entities.Client, error) {
+	client, err := inter.Clients.FindByID(id)
+	if err != nil {
+		if e, ok := err.(*errs.Error); ok && e.Type == errs.ErrorTypeNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if !client.Enabled {
+		return nil, nil
+	}
+	domain, err := inter.Domains.FindByID(client.DomainID)
+	if err != nil {
+		return nil, err
+	}
+	if !domain.Enabled {
+		return nil, nil
+	}
+	return client, nil
+}
+
+// findRefreshToken finds a refresh token which hasn't expired along with its session.
+// Nothing is returned if there is no such token or its session is gone.
+func (inter *OAuthInteractorImpl) findRefreshToken(token string) (*entities.RefreshToken, *entities.Session, error) {
+	t, err := inter.Clients.FindRefreshToken(entities.HashToken(token))
+	if err != nil {
+		if e, ok := err.(*errs.Error); ok && e.Type == errs.ErrorTypeNotFound {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	if t.IsExpired() {
+		return nil, nil, nil
+	}
+	session, err := inter.Sessions.Find(t.SessionID)
+	if err != nil {
+		if e, ok := err.(*errs.Error); ok && e.Type == errs.ErrorTypeNotFound {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	if session.IsExpired() {
+		return nil, nil, nil
+	}
+	return t, session, nil
+}
+
+// grantScopes returns the scopes granted to a client. All of the client's scopes are
+// requested if none are. A scope the client may not request is an error, while scopes
+// a session's user doesn't have permissions for are left out. OpenID Connect scopes
+// are granted for any session and left out without one.
+func (inter *OAuthInteractorImpl) grantScopes(client entities.Client, session *entities.Session, requested []string) ([]string, error) {
+	explicit := len(requested) > 0
+	if !explicit {
+		requested = client.Scopes
+	}
+	for _, scope := range requested {
+		if !client.AllowsScope(scope) {
+			return nil, &OAuthError{OAuthInvalidScope, "Scope is not allowed for the client: " + scope}
+		}
+	}
+	if session == nil {
+		scopes := []string{}
+		for _, scope := range requested {
+			if !entities.IsOpenIDScope(scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+		if explicit && len(scopes) == 0 {
+			return nil, &OAuthError{OAuthInvalidScope, "OpenID Connect scopes require a user"}
+		}
+		return scopes, nil
+	}
+
+	permissions, err := inter.RBAC.ListEffectivePermissions(*session)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, p := range permissions {
+		names = append(names, p.Name)
+	}
+	scopes := []string{}
+	for _, scope := range requested {
+		if entities.IsOpenIDScope(scope) || scopeCovered(names, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	if explicit && len(scopes) == 0 {
+		return nil, &OAuthError{OAuthInvalidScope, "None of the requested scopes is granted to the user"}
+	}
+	return scopes, nil
+}
+
+// issue issues an access token along with an ID token if the openid scope is granted
+// for a session
+func (inter *OAuthInteractorImpl) issue(client entities.Client, session *entities.Session, scopes []string, nonce string) (*entities.TokenGrant, error) {
+	token, err := inter.Tokens.IssueForClient(client, session, scopes)
+	if err != nil {
+		return nil, err
+	}
+	grant := &entities.TokenGrant{AccessToken: *token, Scopes: scopes}
+	if session != nil && entities.HasScope(scopes, entities.ScopeOpenID) {
+		grant.IDToken, err = inter.Tokens.IssueIDToken(client, *session, scopes, nonce)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return grant, nil
+}
+
+// deleteSession deletes a session found by ID or by reference unless it's gone already
+func (inter *OAuthInteractorImpl) deleteSession(session *entities.Session, err error) error {
+	if err != nil {
+		if e, ok := err.(*errs.Error); ok && e.Type == errs.ErrorTypeNotFound {
+			return nil
+		}
+		return err
+	}
+	return inter.Sessions.Delete(*session)
+}
+
+// clientUserAgent is the user agent sessions of a client are opened with. Sessions
+// of different clients are kept apart, so revoking a grant of one client doesn't
+// affect others.
+func clientUserAgent(client entities.Client, userAgent string) string {
+	return "OAuth client " + client.ID + " " + userAgent
+}
+
+// scopeCovered checks if any of the granted scopes (permission names) covers a given one
+func scopeCovered(granted []string, scope string) bool {
+	for _, g := range granted {
+		if entities.PermissionGrants(g, scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPKCEString checks if a code verifier or challenge consists of unreserved URI
+// characters only (RFC 7636 section 4.1)
+func isPKCEString(s string) bool {
+	for _, c := range s {
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9':
+		case c == '-' || c == '.' || c == '_' || c == '~':
+		default:
+			return false
+		}
+	}
+	return true
+}
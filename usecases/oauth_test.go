@@ -0,0 +1,56 @@
+package usecases_test
+
+import (
+	"testing"
+
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/jwt"
+)
+
+func TestOAuthInteractorSessionReference(t *testing.T) {
+	f := newFixture()
+	d := f.domain(t, "domain1.com")
+	u := f.user(t, "john", "secret", d)
+	client := entities.NewClient("app", d.ID)
+	client.GrantTypes = []string{entities.GrantTypePassword, entities.GrantTypeRefreshToken}
+	client.Scopes = []string{entities.ScopeOpenID}
+	_, err := client.GenerateSecret()
+	must(t, err)
+	must(t, f.oauth.Clients.Create(*client))
+
+	grant, err := f.oauth.GrantPassword(*client, "john", "secret", nil, "agent", "127.0.0.1")
+	must(t, err)
+	session, err := f.sessions.FindUserSpecific(u.ID, d.ID, "OAuth client "+client.ID+" agent", "127.0.0.1")
+	must(t, err)
+	claims, err := f.tokens.Verify(grant.AccessToken.Token)
+	must(t, err)
+	if claims.SessionRef == "" || claims.SessionRef != session.Reference || claims.SessionRef == session.ID {
+		t.Errorf("Access token sid = %q, want the reference of session %v", claims.SessionRef, session.ID)
+	}
+	var idClaims entities.IDTokenClaims
+	must(t, jwt.Verify(grant.IDToken, []*jwt.Key{f.tokens.Key}, &idClaims))
+	if idClaims.SessionRef != session.Reference {
+		t.Errorf("ID token sid = %q, want %q", idClaims.SessionRef, session.Reference)
+	}
+
+	info, err := f.oauth.Introspect(*client, grant.AccessToken.Token)
+	if err != nil || !info.Active || info.Username != "john" {
+		t.Errorf("Introspect = %+v, %v", info, err)
+	}
+	userInfo, err := f.oauth.UserInfo(grant.AccessToken.Token)
+	if err != nil || userInfo.Subject != u.ID || userInfo.Domain != "domain1.com" {
+		t.Errorf("UserInfo = %+v, %v", userInfo, err)
+	}
+
+	must(t, f.oauth.Revoke(*client, grant.AccessToken.Token))
+	if _, err = f.sessions.Find(session.ID); errType(err) == "" {
+		t.Error("Revoke of an access token left its session")
+	}
+	info, err = f.oauth.Introspect(*client, grant.AccessToken.Token)
+	if err != nil || info.Active {
+		t.Errorf("Introspect of a revoked token = %+v, %v", info, err)
+	}
+	if _, err = f.oauth.UserInfo(grant.AccessToken.Token); err == nil {
+		t.Error("UserInfo of a revoked token succeeded")
+	}
+}
@@ -0,0 +1,125 @@
+package usecases
+
+import (
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+)
+
+//
+// PasswordPolicyInteractor is an interface that defines all password policy related
+// use-cases signatures
+//
+type PasswordPolicyInteractor interface {
+	Save(policy entities.PasswordPolicy) error
+	Delete(domainID string) error
+	Find(domainID string) (*entities.PasswordPolicy, error)
+	Check(user entities.BasicUser, password string, addDomainIDs, removeDomainIDs []string) error
+	CheckAge(user entities.BasicUser, domainID string) error
+}
+
+// PasswordPolicyInteractorImpl is an actual interactor that implements
+// PasswordPolicyInteractor. Users of several domains have to comply with the
+// strictest rules of their domains' policies. Blocklist lists passwords which are
+// too common to be used by domains whose policy asks for it.
+type PasswordPolicyInteractorImpl struct {
+	Policies  PasswordPolicyRepository
+	Domains   DomainRepository
+	Users     UserRepository
+	Blocklist entities.PasswordBlocklist
+}
+
+// Save sets a password policy of a domain replacing the existing one. The policy
+// applies to passwords set from now on, except for the maximum age.
+func (inter *PasswordPolicyInteractorImpl) Save(policy entities.PasswordPolicy) error {
+	if ok, err := policy.IsValid(); !ok {
+		return errs.NewUseCaseError(errs.ErrorTypeConflict, err.Error(), err)
+	}
+	if policy.Blocklist && len(inter.Blocklist) == 0 {
+		return errs.NewUseCaseError(errs.ErrorTypeConflict, "Password blocklist is not configured", nil)
+	}
+	if _, err := inter.Domains.FindByID(policy.DomainID); err != nil {
+		return err
+	}
+	return inter.Policies.Save(policy)
+}
+
+// Delete deletes a password policy of a domain, so any non-empty password is accepted
+func (inter *PasswordPolicyInteractorImpl) Delete(domainID string) error {
+	return inter.Policies.Delete(domainID)
+}
+
+// Find finds a password policy of a domain
+func (inter *PasswordPolicyInteractorImpl) Find(domainID string) (*entities.PasswordPolicy, error) {
+	return inter.Policies.FindByDomain(domainID)
+}
+
+// Check checks a new password of a user against the policies of the user's domains,
+// including the ones the user is being added to and excluding the ones the user is
+// being removed from. A password matching the current or a previous one is rejected
+// as well if the policies ask for it.
+func (inter *PasswordPolicyInteractorImpl) Check(user entities.BasicUser, password string, addDomainIDs, removeDomainIDs []string) error {
+	policy := entities.PasswordPolicy{}
+	policies, err := inter.Policies.ListByUser(user.ID)
+	if err != nil {
+		return err
+	}
+	removed := map[string]bool{}
+	for _, id := range removeDomainIDs {
+		removed[id] = true
+	}
+	for _, p := range policies {
+		if !removed[p.DomainID] {
+			policy.Tighten(p)
+		}
+	}
+	for _, id := range addDomainIDs {
+		p, err := inter.Policies.FindByDomain(id)
+		if e, ok := err.(*errs.Error); ok && e.Type == errs.ErrorTypeNotFound {
+			continue
+		} else if err != nil {
+			return err
+		}
+		policy.Tighten(*p)
+	}
+
+	if err = policy.Check(password, inter.Blocklist); err != nil {
+		return errs.NewUseCaseError(errs.ErrorTypeConflict, err.Error(), err)
+	}
+	if policy.History == 0 {
+		return nil
+	}
+
+	// Reuse of the current and previous passwords (new users have neither)
+	u, err := inter.Users.FindByID(user.ID)
+	if e, ok := err.(*errs.Error); ok && e.Type == errs.ErrorTypeNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	hashes, err := inter.Users.ListPreviousPasswords(u.ID, policy.History-1)
+	if err != nil {
+		return err
+	}
+	for _, hash := range append([]string{u.Password}, hashes...) {
+		previous := entities.BasicUser{Password: hash}
+		if previous.IsPassword(password) {
+			return errs.NewUseCaseError(errs.ErrorTypeConflict, "Password has been used recently", nil)
+		}
+	}
+	return nil
+}
+
+// CheckAge fails with a password expired error if a user's password is older than
+// the policy of a domain allows
+func (inter *PasswordPolicyInteractorImpl) CheckAge(user entities.BasicUser, domainID string) error {
+	p, err := inter.Policies.FindByDomain(domainID)
+	if e, ok := err.(*errs.Error); ok && e.Type == errs.ErrorTypeNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if p.IsExpired(user.PasswordChangedOn.Time) {
+		return errs.NewUseCaseError(errs.ErrorTypePasswordExpired, "Password expired, must change", nil)
+	}
+	return nil
+}
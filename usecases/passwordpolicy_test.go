@@ -0,0 +1,146 @@
+package usecases_test
+
+import (
+	"testing"
+
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+)
+
+func TestPasswordPolicyInteractorSave(t *testing.T) {
+	f := newFixture()
+	d := f.domain(t, "domain1.com")
+	passwords := f.sessions.Passwords
+
+	for _, tc := range []struct {
+		policy entities.PasswordPolicy
+		err    errs.ErrorType
+	}{
+		{entities.PasswordPolicy{DomainID: d.ID, MinLength: -1}, errs.ErrorTypeConflict},
+		{entities.PasswordPolicy{DomainID: d.ID, History: entities.MaxPasswordHistory + 1}, errs.ErrorTypeConflict},
+		{entities.PasswordPolicy{DomainID: d.ID, Blocklist: true}, errs.ErrorTypeConflict},
+		{entities.PasswordPolicy{DomainID: "missing"}, errs.ErrorTypeNotFound},
+	} {
+		if err := passwords.Save(tc.policy); errType(err) != tc.err {
+			t.Errorf("Save(%+v) = %v, want %v", tc.policy, err, tc.err)
+		}
+	}
+	must(t, passwords.Save(entities.PasswordPolicy{DomainID: d.ID, MinLength: 8}))
+	p, err := passwords.Find(d.ID)
+	must(t, err)
+	if p.MinLength != 8 {
+		t.Errorf("Find = %+v", p)
+	}
+	must(t, passwords.Delete(d.ID))
+	if _, err = passwords.Find(d.ID); errType(err) != errs.ErrorTypeNotFound {
+		t.Errorf("Find of a deleted policy: %v", err)
+	}
+}
+
+func TestPasswordPolicyInteractorCheck(t *testing.T) {
+	f := newFixture()
+	d1 := f.domain(t, "domain1.com")
+	d2 := f.domain(t, "domain2.com")
+	d3 := f.domain(t, "domain3.com")
+	passwords := f.sessions.Passwords
+	must(t, passwords.Save(entities.PasswordPolicy{DomainID: d1.ID, MinLength: 8}))
+	must(t, passwords.Save(entities.PasswordPolicy{DomainID: d2.ID, RequireDigit: true}))
+	must(t, passwords.Save(entities.PasswordPolicy{DomainID: d3.ID, RequireSymbol: true}))
+	u := f.user(t, "john", "secret12", d1, d2)
+
+	for _, tc := range []struct {
+		password string
+		add      []string
+		remove   []string
+		valid    bool
+	}{
+		{"secret12", nil, nil, true},
+		{"secret1", nil, nil, false},
+		{"secretsecret", nil, nil, false},
+		{"secretsecret", nil, []string{d2.ID}, true},
+		{"secret1", nil, []string{d1.ID}, true},
+		{"secret12", []string{d3.ID}, nil, false},
+		{"secret1!", []string{d3.ID}, nil, true},
+		{"secret12", []string{"unknown"}, nil, true},
+	} {
+		err := passwords.Check(*u, tc.password, tc.add, tc.remove)
+		if (err == nil) != tc.valid || (err != nil && errType(err) != errs.ErrorTypeConflict) {
+			t.Errorf("Check(%q, add %v, remove %v) = %v", tc.password, tc.add, tc.remove, err)
+		}
+	}
+
+	// A new user has to comply with the policies of the domains it's added to
+	if err := passwords.Check(*entities.NewBasicUser("jane"), "secret", []string{d1.ID}, nil); errType(err) != errs.ErrorTypeConflict {
+		t.Errorf("Check of a new user: %v", err)
+	}
+}
+
+func TestPasswordPolicyInteractorHistory(t *testing.T) {
+	f := newFixture()
+	d1 := f.domain(t, "domain1.com")
+	d2 := f.domain(t, "domain2.com")
+	passwords := f.sessions.Passwords
+	must(t, passwords.Save(entities.PasswordPolicy{DomainID: d1.ID, History: 2}))
+	u := f.user(t, "john", "first", d1, d2)
+
+	change := func(password string) error {
+		if err := passwords.Check(*u, password, nil, nil); err != nil {
+			return err
+		}
+		must(t, u.SetPassword(password))
+		return f.users.Update(*u, password, nil, nil)
+	}
+
+	if err := change("first"); errType(err) != errs.ErrorTypeConflict {
+		t.Errorf("Check of the current password: %v", err)
+	}
+	must(t, change("second"))
+	for _, password := range []string{"first", "second"} {
+		if err := change(password); errType(err) != errs.ErrorTypeConflict {
+			t.Errorf("Check of a recent password %q: %v", password, err)
+		}
+	}
+	must(t, change("third"))
+	if err := change("first"); err != nil {
+		t.Errorf("Check of a password older than the history: %v", err)
+	}
+
+	// The longest history of the user's domains applies
+	must(t, passwords.Save(entities.PasswordPolicy{DomainID: d2.ID, History: 4}))
+	for _, password := range []string{"first", "second", "third"} {
+		if err := passwords.Check(*u, password, nil, nil); errType(err) != errs.ErrorTypeConflict {
+			t.Errorf("Check of a recent password %q with a longer history: %v", password, err)
+		}
+	}
+	if err := passwords.Check(*u, "third", nil, []string{d2.ID}); errType(err) != errs.ErrorTypeConflict {
+		t.Errorf("Check of the previous password while leaving the domain of the longer history: %v", err)
+	}
+	if err := passwords.Check(*u, "second", nil, []string{d2.ID}); err != nil {
+		t.Errorf("Check of an old password while leaving the domain of the longer history: %v", err)
+	}
+}
+
+func TestPasswordPolicyInteractorCheckAge(t *testing.T) {
+	f := newFixture()
+	d1 := f.domain(t, "domain1.com")
+	d2 := f.domain(t, "domain2.com")
+	passwords := f.sessions.Passwords
+	u := f.user(t, "john", "secret", d1, d2)
+	must(t, passwords.Save(entities.PasswordPolicy{DomainID: d1.ID, MaxAgeDays: 30}))
+	must(t, passwords.Save(entities.PasswordPolicy{DomainID: d2.ID, MinLength: 4}))
+
+	must(t, passwords.CheckAge(*u, d1.ID))
+	expirePassword(t, f, u)
+	if err := passwords.CheckAge(*u, d1.ID); errType(err) != errs.ErrorTypePasswordExpired {
+		t.Errorf("CheckAge of an expired password: %v", err)
+	}
+	// The age is checked against the policy of the domain the session is opened in
+	for _, id := range []string{d2.ID, "unknown"} {
+		if err := passwords.CheckAge(*u, id); err != nil {
+			t.Errorf("CheckAge in a domain without a maximum age: %v", err)
+		}
+	}
+	if _, err := f.sessions.CreateWithPassword(*d2, *u, "secret", "agent", "127.0.0.1"); err != nil {
+		t.Errorf("CreateWithPassword in a domain without a maximum age: %v", err)
+	}
+}
@@ -1,13 +1,12 @@
 package usecases
 
 import (
-	"database/sql"
-	"fmt"
+	"sort"
+	"strings"
 
-	"github.com/oleksandr/idp/db"
 	"github.com/oleksandr/idp/entities"
 	"github.com/oleksandr/idp/errs"
-	"gopkg.in/gorp.v1"
+	"github.com/oleksandr/idp/rules"
 )
 
 //
@@ -16,22 +15,36 @@ import (
 //
 type RBACInteractor interface {
 	CreatePermission(p entities.BasicPermission) error
+	UpdatePermission(p entities.BasicPermission) error
+	RenamePermission(oldName, newName string) error
 	DeletePermission(name string) error
+	FindPermission(name string) (*entities.BasicPermission, error)
 	CreateRole(r entities.BasicRole) error
+	UpdateRole(r entities.BasicRole) error
+	RenameRole(oldName, newName string) error
 	DeleteRole(name string) error
+	FindRole(name string) (*entities.BasicRole, error)
 	UpdateRoleWithPermissions(roleName string, permissions []string) error
 	RemovePermissionsFromRole(permissions []string, roleName string) error
+	InheritRoles(seniorRoleName string, juniorRoleNames []string) error
+	UninheritRoles(seniorRoleName string, juniorRoleNames []string) error
+	CreateSoDConstraint(c entities.SoDConstraint) error
+	DeleteSoDConstraint(name string) error
+	ListSoDConstraints(pager entities.Pager, sorter entities.Sorter) (*entities.SoDConstraintCollection, error)
 	ListPermissions(pager entities.Pager, sorter entities.Sorter) (*entities.BasicPermissionCollection, error)
 	ListPermissionsByRole(roleName string, pager entities.Pager, sorter entities.Sorter) (*entities.BasicPermissionCollection, error)
 	ListRoles(pager entities.Pager, sorter entities.Sorter) (*entities.BasicRoleCollection, error)
 	ListRolesByUser(userID string, pager entities.Pager, sorter entities.Sorter) (*entities.BasicRoleCollection, error)
-	AssertRole(userID, roleName string) (bool, error)
-	AssertPermission(userID, permissionName string) (bool, error)
+	ListEffectiveRoles(session entities.Session) ([]entities.BasicRole, error)
+	ListEffectivePermissions(session entities.Session) ([]entities.BasicPermission, error)
+	AssertRole(session entities.Session, roleName string) (bool, error)
+	AssertPermission(session entities.Session, permissionName string, attrs map[string]string) (bool, error)
 }
 
 // RBACInteractorImpl is an actual interactor that implements RBACInteractor
 type RBACInteractorImpl struct {
-	DBMap *gorp.DbMap
+	Roles       RoleRepository
+	Permissions PermissionRepository
 }
 
 // CreatePermission creates a new permission
@@ -39,74 +52,28 @@ func (inter *RBACInteractorImpl) CreatePermission(p entities.BasicPermission) er
 	if p.Name == "" {
 		return errs.NewUseCaseError(errs.ErrorTypeConflict, "Permission name cannot be empty", nil)
 	}
-	d := &db.Permission{
-		Name:           p.Name,
-		Description:    p.Description,
-		Enabled:        p.Enabled,
-		EvaluationRule: p.EvaluationRule,
-	}
-	err := inter.DBMap.Insert(d)
-	if err != nil {
-		return errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to create a permission", err)
-	}
-	return nil
-}
-
-func (inter *RBACInteractorImpl) findPermission(name string) (*db.Permission, error) {
-	var (
-		p    db.Permission
-		err  error
-		pTbl = inter.DBMap.Dialect.QuotedTableForQuery("", "permission")
-	)
-
-	err = inter.DBMap.SelectOne(&p, fmt.Sprintf("SELECT * FROM %v WHERE name = ?", pTbl), name)
-	if err == sql.ErrNoRows {
-		return nil, errs.NewUseCaseError(errs.ErrorTypeNotFound, "Permission not found by given name", err)
-	} else if err != nil {
-		return nil, errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to perform a lookup of a permission", err)
+	if err := validateEvaluationRule(p.EvaluationRule); err != nil {
+		return err
 	}
-
-	return &p, nil
+	return inter.Permissions.Create(p)
 }
 
 // DeletePermission deletes existing permission
 func (inter *RBACInteractorImpl) DeletePermission(name string) error {
-	p, err := inter.findPermission(name)
-	if err != nil {
-		return err
-	}
-	_, err = inter.DBMap.Delete(p)
-	if err != nil {
-		return errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to delete permission by given name", err)
-	}
-	return nil
+	return inter.Permissions.Delete(name)
 }
 
 // FindPermission finds a role by given role name
 func (inter *RBACInteractorImpl) FindPermission(name string) (*entities.BasicPermission, error) {
-	p, err := inter.findPermission(name)
-	if err != nil {
-		return nil, err
-	}
-	return permissionToEntity(p), nil
+	return inter.Permissions.FindByName(name)
 }
 
 // UpdatePermission updates all attributes of a given domain entity in the database
 func (inter *RBACInteractorImpl) UpdatePermission(perm entities.BasicPermission) error {
-	p, err := inter.findPermission(perm.Name)
-	if err != nil {
+	if err := validateEvaluationRule(perm.EvaluationRule); err != nil {
 		return err
 	}
-
-	p.Description = perm.Description
-	p.Enabled = perm.Enabled
-	p.EvaluationRule = perm.EvaluationRule
-
-	_, err = inter.DBMap.Update(&p)
-	if err != nil {
-		return errs.NewUseCaseError(errs.ErrorTypeConflict, "Failed to update permission", err)
-	}
-	return nil
+	return inter.Permissions.Update(perm)
 }
 
 // RenamePermission renames oldName permission to newName
@@ -114,19 +81,7 @@ func (inter *RBACInteractorImpl) RenamePermission(oldName, newName string) error
 	if newName == "" {
 		return errs.NewUseCaseError(errs.ErrorTypeConflict, "Permission name cannot be empty", nil)
 	}
-
-	p, err := inter.findPermission(oldName)
-	if err != nil {
-		return err
-	}
-
-	p.Name = newName
-
-	_, err = inter.DBMap.Update(&p)
-	if err != nil {
-		return errs.NewUseCaseError(errs.ErrorTypeConflict, "Failed to rename permission", err)
-	}
-	return nil
+	return inter.Permissions.Rename(oldName, newName)
 }
 
 // CreateRole creates a new role
@@ -134,33 +89,7 @@ func (inter *RBACInteractorImpl) CreateRole(r entities.BasicRole) error {
 	if r.Name == "" {
 		return errs.NewUseCaseError(errs.ErrorTypeConflict, "Role name cannot be empty", nil)
 	}
-
-	d := &db.Role{
-		Name:        r.Name,
-		Description: r.Description,
-		Enabled:     r.Enabled,
-	}
-	err := inter.DBMap.Insert(d)
-	if err != nil {
-		return errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to create a role", err)
-	}
-	return nil
-}
-
-func (inter *RBACInteractorImpl) findRole(name string) (*db.Role, error) {
-	var (
-		r    db.Role
-		err  error
-		rTbl = inter.DBMap.Dialect.QuotedTableForQuery("", "role")
-	)
-	err = inter.DBMap.SelectOne(&r, fmt.Sprintf("SELECT * FROM %v WHERE name = ?", rTbl), name)
-	if err == sql.ErrNoRows {
-		return nil, errs.NewUseCaseError(errs.ErrorTypeNotFound, "Role not found by given name", err)
-	} else if err != nil {
-		return nil, errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to perform a lookup of a role", err)
-	}
-
-	return &r, nil
+	return inter.Roles.Create(r)
 }
 
 // UpdateRole updates all attributes of a given domain entity in the database
@@ -168,21 +97,7 @@ func (inter *RBACInteractorImpl) UpdateRole(role entities.BasicRole) error {
 	if role.Name == "" {
 		return errs.NewUseCaseError(errs.ErrorTypeConflict, "Role name cannot be empty", nil)
 	}
-
-	r, err := inter.findRole(role.Name)
-	if err != nil {
-		return err
-	}
-
-	r.Description = role.Description
-	r.Enabled = role.Enabled
-
-	_, err = inter.DBMap.Update(r)
-	if err != nil {
-		return errs.NewUseCaseError(errs.ErrorTypeConflict, "Failed to update a role", err)
-	}
-
-	return nil
+	return inter.Roles.Update(role)
 }
 
 // RenameRole renames oldName role to newName
@@ -190,293 +105,305 @@ func (inter *RBACInteractorImpl) RenameRole(oldName, newName string) error {
 	if newName == "" {
 		return errs.NewUseCaseError(errs.ErrorTypeConflict, "Role name cannot be empty", nil)
 	}
-
-	r, err := inter.findRole(oldName)
-	if err != nil {
-		return err
-	}
-
-	r.Name = newName
-
-	_, err = inter.DBMap.Update(&r)
-	if err != nil {
-		return errs.NewUseCaseError(errs.ErrorTypeConflict, "Failed to rename a role", err)
-	}
-	return nil
+	return inter.Roles.Rename(oldName, newName)
 }
 
 // DeleteRole deletes existing role
 func (inter *RBACInteractorImpl) DeleteRole(name string) error {
-	err := db.DeleteRole(inter.DBMap, name)
-	if err != nil {
-		return errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to delete permission by given name", err)
-	}
-	return nil
+	return inter.Roles.Delete(name)
 }
 
 // FindRole finds a role by given role name
 func (inter *RBACInteractorImpl) FindRole(name string) (*entities.BasicRole, error) {
-	r, err := inter.findRole(name)
-	if err != nil {
-		return nil, err
-	}
-	return roleToEntity(r), nil
+	return inter.Roles.FindByName(name)
 }
 
 // UpdateRoleWithPermissions adds given permissions to existing role
 func (inter *RBACInteractorImpl) UpdateRoleWithPermissions(roleName string, permissions []string) error {
-	var (
-		err  error
-		pk   int64
-		pp   []int64
-		pTbl = inter.DBMap.Dialect.QuotedTableForQuery("", "permission")
-	)
-	r, err := inter.findRole(roleName)
-	if err != nil {
-		return err
-	}
+	return inter.Roles.AddPermissions(roleName, permissions)
+}
 
-	for _, name := range permissions {
-		pk, err = inter.DBMap.SelectInt(fmt.Sprintf("SELECT permission_id FROM %v WHERE name = ?", pTbl), name)
-		if err != nil || pk == 0 {
-			return errs.NewUseCaseError(errs.ErrorTypeNotFound, "Permission not found by given name", err)
-		}
-		pp = append(pp, pk)
-	}
-	if len(pp) != len(permissions) {
-		return errs.NewUseCaseError(errs.ErrorTypeNotFound, fmt.Sprintf("Resolve only %v of %v provided permissions", len(pp), len(permissions)), nil)
-	}
+// RemovePermissionsFromRole removes givens permission from existing role
+func (inter *RBACInteractorImpl) RemovePermissionsFromRole(permissions []string, roleName string) error {
+	return inter.Roles.RemovePermissions(roleName, permissions)
+}
 
-	tx, err := inter.DBMap.Begin()
-	if err != nil {
-		return errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to begin transaction", err)
-	}
-	for _, pk = range pp {
-		err = tx.Insert(&db.RolePermission{
-			RolePK:       r.PK,
-			PermissionPK: pk,
-		})
-		if err != nil {
-			tx.Rollback()
-			return errs.NewUseCaseError(errs.ErrorTypeConflict, "Failed to add permission to role", err)
+// InheritRoles makes a senior role inherit permissions of given junior roles (and
+// transitively of the roles they inherit). Inheritance creating a cycle is rejected.
+func (inter *RBACInteractorImpl) InheritRoles(seniorRoleName string, juniorRoleNames []string) error {
+	return inter.Roles.Inherit(seniorRoleName, juniorRoleNames, func(state RoleState) error {
+		graph := roleGraph(state.Inheritance)
+		if graph.inherits(seniorRoleName, seniorRoleName) {
+			return errs.NewUseCaseError(errs.ErrorTypeConflict, "Role inheritance would create a cycle", nil)
 		}
-	}
-	err = tx.Commit()
-	if err != nil {
-		return errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to commit transaction", err)
-	}
+		// Inherited roles count towards separation of duty constraints
+		return checkAllSoDConstraints(graph, state.Constraints, state.Assignments)
+	})
+}
 
-	return nil
+// UninheritRoles removes given junior roles from roles inherited by a senior role
+func (inter *RBACInteractorImpl) UninheritRoles(seniorRoleName string, juniorRoleNames []string) error {
+	return inter.Roles.Uninherit(seniorRoleName, juniorRoleNames)
 }
 
-// RemovePermissionsFromRole removes givens permission from existing role
-func (inter *RBACInteractorImpl) RemovePermissionsFromRole(permissions []string, roleName string) error {
-	var (
-		pk   int64
-		pp   []int64
-		pTbl = inter.DBMap.Dialect.QuotedTableForQuery("", "permission")
-	)
-	r, err := inter.findRole(roleName)
+// ListPermissions lists existing permissions page by page
+func (inter *RBACInteractorImpl) ListPermissions(pager entities.Pager, sorter entities.Sorter) (*entities.BasicPermissionCollection, error) {
+	permissions, total, err := inter.Permissions.List(pager, sorter)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return &entities.BasicPermissionCollection{
+		Permissions: permissions,
+		Paginator:   *pager.CreatePaginator(len(permissions), total),
+	}, nil
+}
 
-	for _, name := range permissions {
-		pk, err = inter.DBMap.SelectInt(fmt.Sprintf("SELECT permission_id FROM %v WHERE name = ?", pTbl), name)
-		if err != nil || pk == 0 {
-			return errs.NewUseCaseError(errs.ErrorTypeNotFound, "Permission not found by given name", err)
-		}
-		pp = append(pp, pk)
+// ListPermissionsByRole lists existing permissions by role (including permissions of
+// the roles it inherits) page by page
+func (inter *RBACInteractorImpl) ListPermissionsByRole(roleName string, pager entities.Pager, sorter entities.Sorter) (*entities.BasicPermissionCollection, error) {
+	if _, err := inter.Roles.FindByName(roleName); err != nil {
+		return nil, err
 	}
-	if len(pp) != len(permissions) {
-		return errs.NewUseCaseError(errs.ErrorTypeNotFound, fmt.Sprintf("Resolve only %v of %v provided permissions", len(pp), len(permissions)), nil)
+	inheritance, err := inter.Roles.Inheritance(true)
+	if err != nil {
+		return nil, err
 	}
+	roles := roleGraph(inheritance).closure([]string{roleName})
 
-	tx, err := inter.DBMap.Begin()
+	permissions, total, err := inter.Permissions.ListByRoles(roles, pager, sorter)
 	if err != nil {
-		return errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to begin transaction", err)
-	}
-	for _, pk = range pp {
-		_, err = tx.Delete(&db.RolePermission{
-			RolePK:       r.PK,
-			PermissionPK: pk,
-		})
-		if err != nil {
-			tx.Rollback()
-			return errs.NewUseCaseError(errs.ErrorTypeConflict, "Failed to remove permission from role", err)
-		}
+		return nil, err
 	}
-	err = tx.Commit()
+	return &entities.BasicPermissionCollection{
+		Permissions: permissions,
+		Paginator:   *pager.CreatePaginator(len(permissions), total),
+	}, nil
+}
+
+// ListRoles lists existing roles page by page
+func (inter *RBACInteractorImpl) ListRoles(pager entities.Pager, sorter entities.Sorter) (*entities.BasicRoleCollection, error) {
+	roles, total, err := inter.Roles.List(pager, sorter)
 	if err != nil {
-		return errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to commit transaction", err)
+		return nil, err
 	}
-
-	return nil
+	return &entities.BasicRoleCollection{
+		Roles:     roles,
+		Paginator: *pager.CreatePaginator(len(roles), total),
+	}, nil
 }
 
-// ListPermissions lists existing permissions page by page
-func (inter *RBACInteractorImpl) ListPermissions(pager entities.Pager, sorter entities.Sorter) (*entities.BasicPermissionCollection, error) {
-	pTbl := inter.DBMap.Dialect.QuotedTableForQuery("", "permission")
-	total, err := inter.DBMap.SelectInt(fmt.Sprintf("SELECT COUNT(*) FROM %v", pTbl))
+// ListRolesByUser lists existing roles by user page by page
+func (inter *RBACInteractorImpl) ListRolesByUser(userID string, pager entities.Pager, sorter entities.Sorter) (*entities.BasicRoleCollection, error) {
+	roles, total, err := inter.Roles.ListByUser(userID, pager, sorter)
 	if err != nil {
-		return nil, errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to count permissions", err)
+		return nil, err
 	}
+	return &entities.BasicRoleCollection{
+		Roles:     roles,
+		Paginator: *pager.CreatePaginator(len(roles), total),
+	}, nil
+}
 
-	var records []db.Permission
-	q := "SELECT * FROM %v AS p %v %v;"
-	_, err = inter.DBMap.Select(&records, fmt.Sprintf(q, pTbl, db.OrderByClause(sorter, "p"), db.LimitOffset(pager)))
+// ListEffectiveRoles lists all enabled roles a session's user holds either globally or in
+// the session's domain, including the roles they inherit. Roles are sorted by name.
+func (inter *RBACInteractorImpl) ListEffectiveRoles(session entities.Session) ([]entities.BasicRole, error) {
+	roles := []entities.BasicRole{}
+	names, err := inter.sessionRoleNames(session)
 	if err != nil {
 		return nil, err
 	}
-	c := &entities.BasicPermissionCollection{
-		Permissions: []entities.BasicPermission{},
-		Paginator:   *pager.CreatePaginator(len(records), total),
+	if len(names) == 0 {
+		return roles, nil
+	}
+
+	records, err := inter.Roles.ListByNames(names)
+	if err != nil {
+		return nil, err
 	}
 	for _, r := range records {
-		c.Permissions = append(c.Permissions, *permissionToEntity(&r))
+		if r.Enabled {
+			roles = append(roles, r)
+		}
 	}
-	return c, nil
+	sort.Sort(rolesByName(roles))
+	return roles, nil
 }
 
-// ListPermissionsByRole lists existing permissions by role page by page
-func (inter *RBACInteractorImpl) ListPermissionsByRole(roleName string, pager entities.Pager, sorter entities.Sorter) (*entities.BasicPermissionCollection, error) {
-	pTbl := inter.DBMap.Dialect.QuotedTableForQuery("", "permission")
-	rTbl := inter.DBMap.Dialect.QuotedTableForQuery("", "role")
-
-	q := fmt.Sprintf("SELECT COUNT(*) FROM role_permission WHERE role_id IN (SELECT role_id FROM role WHERE name = ?);", rTbl)
-	total, err := inter.DBMap.SelectInt(q, roleName)
+// ListEffectivePermissions lists all enabled permissions granted to a session's user via
+// their effective roles (see ListEffectiveRoles). Permissions are sorted by name. Evaluation
+// rules are returned as is, so a permission with a rule may still be denied by AssertPermission.
+func (inter *RBACInteractorImpl) ListEffectivePermissions(session entities.Session) ([]entities.BasicPermission, error) {
+	permissions := []entities.BasicPermission{}
+	records, err := inter.sessionPermissions(session)
 	if err != nil {
-		return nil, errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to count permissions", err)
+		return nil, err
 	}
-
-	var records []db.Permission
-	q = `SELECT p.* FROM role_permission AS rp
-		LEFT JOIN %v AS p ON p.permission_id=rp.permission_id
-		LEFT JOIN %v AS r ON r.role_id=rp.role_id
-		WHERE r.name=? GROUP BY p.permission_id
-		%v %v;`
-	_, err = inter.DBMap.Select(&records, fmt.Sprintf(q, pTbl, rTbl, db.OrderByClause(sorter, "p"), db.LimitOffset(pager)), roleName)
-	if err == sql.ErrNoRows {
-		return nil, errs.NewUseCaseError(errs.ErrorTypeNotFound, "No permission found for given role", err)
-	} else if err != nil {
-		return nil, errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to perform a lookup of permissions for role", err)
+	for _, p := range records {
+		if p.Enabled {
+			permissions = append(permissions, p)
+		}
 	}
+	return permissions, nil
+}
 
-	c := &entities.BasicPermissionCollection{
-		Permissions: []entities.BasicPermission{},
-		Paginator:   *pager.CreatePaginator(len(records), total),
+// AssertRole checks if a session's user has given role assigned either globally
+// or in the session's domain, directly or via inheritance
+func (inter *RBACInteractorImpl) AssertRole(session entities.Session, roleName string) (bool, error) {
+	names, err := inter.sessionRoleNames(session)
+	if err != nil {
+		return false, err
 	}
-	for _, r := range records {
-		c.Permissions = append(c.Permissions, *permissionToEntity(&r))
+	for _, name := range names {
+		if name == roleName {
+			return true, nil
+		}
 	}
-	return c, nil
+	return false, nil
 }
 
-// ListRoles lists existing roles page by page
-func (inter *RBACInteractorImpl) ListRoles(pager entities.Pager, sorter entities.Sorter) (*entities.BasicRoleCollection, error) {
-	rTbl := inter.DBMap.Dialect.QuotedTableForQuery("", "role")
-	total, err := inter.DBMap.SelectInt(fmt.Sprintf("SELECT COUNT(*) FROM %v", rTbl))
+// AssertPermission checks if a session's user has a given permission via any of the roles assigned
+// either globally or in the session's domain, directly or via inheritance.
+// Permissions are hierarchical, so "users.*" grants "users.delete" and "*" grants everything.
+// A permission with an evaluation rule is granted only if the rule evaluates to true for
+// the session's user and domain and the given attributes.
+func (inter *RBACInteractorImpl) AssertPermission(session entities.Session, permissionName string, attrs map[string]string) (bool, error) {
+	permissions, err := inter.sessionPermissions(session)
 	if err != nil {
-		return nil, errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to count roles", err)
+		return false, err
 	}
 
-	var records []db.Role
-	q := "SELECT * FROM %v AS r %v %v;"
-	_, err = inter.DBMap.Select(&records, fmt.Sprintf(q, rTbl, db.OrderByClause(sorter, "r"), db.LimitOffset(pager)))
-	if err == sql.ErrNoRows {
-		return nil, errs.NewUseCaseError(errs.ErrorTypeNotFound, "No roles found", err)
-	} else if err != nil {
-		return nil, errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to perform a lookup of roles", err)
+	var env rules.Env
+	for _, p := range permissions {
+		if !p.Enabled || !entities.PermissionGrants(p.Name, permissionName) {
+			continue
+		}
+		if strings.TrimSpace(p.EvaluationRule) == "" {
+			return true, nil
+		}
+		if env == nil {
+			env = ruleEnv(session, attrs)
+		}
+		// A rule which cannot be parsed or evaluated (e.g. a missing attribute
+		// compared with a number) does not grant the permission
+		rule, err := rules.Parse(p.EvaluationRule)
+		if err != nil {
+			continue
+		}
+		if ok, err := rule.Eval(env); err == nil && ok {
+			return true, nil
+		}
 	}
+	return false, nil
+}
 
-	c := &entities.BasicRoleCollection{
-		Roles:     []entities.BasicRole{},
-		Paginator: *pager.CreatePaginator(len(records), total),
+// sessionRoleNames returns names of enabled roles of a session's user assigned either globally
+// or in the session's domain along with all enabled roles they inherit
+func (inter *RBACInteractorImpl) sessionRoleNames(session entities.Session) ([]string, error) {
+	assigned, err := inter.Roles.ListAssignedNames(session.User.ID, sessionDomainID(session))
+	if err != nil {
+		return nil, err
 	}
-	for _, r := range records {
-		c.Roles = append(c.Roles, *roleToEntity(&r))
+	if len(assigned) == 0 {
+		return nil, nil
 	}
-	return c, nil
-}
 
-// ListRolesByUser lists existing roles by user page by page
-func (inter *RBACInteractorImpl) ListRolesByUser(userID string, pager entities.Pager, sorter entities.Sorter) (*entities.BasicRoleCollection, error) {
-	uTbl := inter.DBMap.Dialect.QuotedTableForQuery("", "user")
-	rTbl := inter.DBMap.Dialect.QuotedTableForQuery("", "role")
-
-	q := fmt.Sprintf("SELECT COUNT(*) FROM user_role WHERE user_id IN (SELECT user_id FROM %v WHERE object_id = ?);", uTbl)
-	total, err := inter.DBMap.SelectInt(q, userID)
+	inheritance, err := inter.Roles.Inheritance(true)
 	if err != nil {
-		return nil, errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to count roles for given user", err)
+		return nil, err
 	}
+	return roleGraph(inheritance).closure(assigned), nil
+}
 
-	var records []db.Role
-	q = `SELECT r.* FROM user_role AS ur
-		LEFT JOIN %v AS r ON r.role_id=ur.role_id
-		LEFT JOIN %v AS u ON u.user_id=ur.user_id
-		WHERE u.object_id=? GROUP BY r.role_id
-		%v %v;`
-	_, err = inter.DBMap.Select(&records, fmt.Sprintf(q, rTbl, uTbl, db.OrderByClause(sorter, "r"), db.LimitOffset(pager)), userID)
-	if err == sql.ErrNoRows {
-		return nil, errs.NewUseCaseError(errs.ErrorTypeNotFound, "No roles found for given user", err)
-	} else if err != nil {
-		return nil, errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to perform a lookup of roles for given user", err)
+// sessionPermissions returns permissions (disabled ones included) of all roles
+// of a session's user sorted by name
+func (inter *RBACInteractorImpl) sessionPermissions(session entities.Session) ([]entities.BasicPermission, error) {
+	names, err := inter.sessionRoleNames(session)
+	if err != nil || len(names) == 0 {
+		return nil, err
 	}
+	permissions, _, err := inter.Permissions.ListByRoles(names, entities.Pager{}, entities.Sorter{Field: "name", Asc: true})
+	return permissions, err
+}
 
-	c := &entities.BasicRoleCollection{
-		Roles:     []entities.BasicRole{},
-		Paginator: *pager.CreatePaginator(len(records), total),
-	}
-	for _, r := range records {
-		c.Roles = append(c.Roles, *roleToEntity(&r))
+//
+// roleGraph maps a senior role's name to names of the roles it directly inherits
+//
+type roleGraph map[string][]string
+
+// closure returns given role names along with names of all roles they inherit transitively
+func (g roleGraph) closure(names []string) []string {
+	var (
+		result []string
+		seen   = map[string]bool{}
+		queue  = append([]string{}, names...)
+	)
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		result = append(result, name)
+		queue = append(queue, g[name]...)
 	}
-	return c, nil
+	return result
 }
 
-// AssertRole checks if a given user has given role assigned
-func (inter *RBACInteractorImpl) AssertRole(userID, roleName string) (bool, error) {
-	uTbl := inter.DBMap.Dialect.QuotedTableForQuery("", "user")
-	rTbl := inter.DBMap.Dialect.QuotedTableForQuery("", "role")
-	q := fmt.Sprintf(`SELECT COUNT(*) FROM user_role AS ur
-		INNER JOIN %v AS u ON u.user_id = ur.user_id
-		INNER JOIN %v AS r ON r.role_id = ur.role_id
-		WHERE u.object_id=? AND r.name=?
-			AND u.is_enabled=1 AND r.is_enabled=1;`, uTbl, rTbl)
-	total, err := inter.DBMap.SelectInt(q, userID, roleName)
-	if err != nil {
-		return false, errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to assert role", err)
+// inherits checks if a senior role inherits a junior role transitively
+func (g roleGraph) inherits(senior, junior string) bool {
+	for _, name := range g.closure(g[senior]) {
+		if name == junior {
+			return true
+		}
 	}
-	return total > 0, nil
+	return false
 }
 
-// AssertPermission checks if a given user has a given permission via any of the assigned roles
-func (inter *RBACInteractorImpl) AssertPermission(userID, permissionName string) (bool, error) {
-	uTbl := inter.DBMap.Dialect.QuotedTableForQuery("", "user")
-	rTbl := inter.DBMap.Dialect.QuotedTableForQuery("", "role")
-	pTbl := inter.DBMap.Dialect.QuotedTableForQuery("", "permission")
-	q := fmt.Sprintf(`SELECT COUNT(*) FROM user_role AS ur
-		INNER JOIN %v AS u ON u.user_id = ur.user_id
-		INNER JOIN %v AS r ON r.role_id = ur.role_id
-		INNER JOIN role_permission AS rp ON rp.role_id = r.role_id
-		INNER JOIN %v AS p ON p.permission_id = rp.permission_id
-		WHERE u.object_id=? AND p.name=?
-			AND u.is_enabled=1 AND r.is_enabled=1 AND p.is_enabled=1;`, uTbl, rTbl, pTbl)
-	total, err := inter.DBMap.SelectInt(q, userID, permissionName)
-	if err != nil {
-		return false, errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to assert permission", err)
+// rolesByName sorts roles by their names
+type rolesByName []entities.BasicRole
+
+func (r rolesByName) Len() int           { return len(r) }
+func (r rolesByName) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+func (r rolesByName) Less(i, j int) bool { return r[i].Name < r[j].Name }
+
+// sessionDomainID returns ID of a session's domain or an empty string
+func sessionDomainID(session entities.Session) string {
+	if session.Domain == nil {
+		return ""
 	}
-	return total > 0, nil
+	return session.Domain.ID
 }
 
-func permissionToEntity(p *db.Permission) *entities.BasicPermission {
-	e := entities.NewBasicPermission(p.Name, p.Description)
-	e.Enabled = p.Enabled
-	e.EvaluationRule = p.EvaluationRule
-	return e
+// validateEvaluationRule checks if a given permission's evaluation rule is valid
+func validateEvaluationRule(src string) error {
+	if strings.TrimSpace(src) == "" {
+		return nil
+	}
+	if _, err := rules.Parse(src); err != nil {
+		return errs.NewUseCaseError(errs.ErrorTypeConflict, "Invalid evaluation rule", err)
+	}
+	return nil
 }
 
-func roleToEntity(r *db.Role) *entities.BasicRole {
-	e := entities.NewBasicRole(r.Name, r.Description)
-	e.Enabled = r.Enabled
-	return e
+// ruleEnv builds an environment evaluation rules are evaluated against
+func ruleEnv(session entities.Session, attrs map[string]string) rules.Env {
+	user := map[string]interface{}{}
+	if session.User != nil {
+		user["id"] = session.User.ID
+		user["name"] = session.User.Name
+	}
+	domain := map[string]interface{}{}
+	if session.Domain != nil {
+		domain["id"] = session.Domain.ID
+		domain["name"] = session.Domain.Name
+	}
+	if attrs == nil {
+		attrs = map[string]string{}
+	}
+	return rules.Env{
+		"user":   user,
+		"domain": domain,
+		"attrs":  attrs,
+	}
 }
@@ -0,0 +1,118 @@
+package usecases_test
+
+import (
+	"testing"
+
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+)
+
+func TestRBACInteractor(t *testing.T) {
+	f := newFixture()
+	d := f.domain(t, "domain1.com")
+	u := f.user(t, "john", "secret", d)
+
+	for _, p := range []struct{ name, rule string }{
+		{"users.*", ""},
+		{"docs.read", ""},
+		{"docs.write", `attrs.owner == user.id`},
+	} {
+		perm := entities.NewBasicPermission(p.name, "")
+		perm.EvaluationRule = p.rule
+		perm.Enabled = true
+		must(t, f.rbac.CreatePermission(*perm))
+	}
+	perm := entities.NewBasicPermission("docs.broken", "")
+	perm.EvaluationRule = `attrs.owner ==`
+	if err := f.rbac.CreatePermission(*perm); errType(err) != errs.ErrorTypeConflict {
+		t.Errorf("CreatePermission with an invalid rule: %v", err)
+	}
+
+	for _, name := range []string{"admin", "editor", "reader"} {
+		r := entities.NewBasicRole(name, "")
+		r.Enabled = true
+		must(t, f.rbac.CreateRole(*r))
+	}
+	must(t, f.rbac.UpdateRoleWithPermissions("admin", []string{"users.*"}))
+	must(t, f.rbac.UpdateRoleWithPermissions("editor", []string{"docs.write"}))
+	must(t, f.rbac.UpdateRoleWithPermissions("reader", []string{"docs.read"}))
+	must(t, f.rbac.InheritRoles("admin", []string{"editor"}))
+	must(t, f.rbac.InheritRoles("editor", []string{"reader"}))
+	if err := f.rbac.InheritRoles("reader", []string{"admin"}); errType(err) != errs.ErrorTypeConflict {
+		t.Errorf("InheritRoles making a cycle: %v", err)
+	}
+
+	must(t, f.users.AssignRoles(u.ID, []string{"editor"}, d.ID))
+	s, err := f.sessions.Create(*d, *u, "agent", "127.0.0.1")
+	must(t, err)
+
+	roles, err := f.rbac.ListEffectiveRoles(*s)
+	must(t, err)
+	if len(roles) != 2 {
+		t.Errorf("ListEffectiveRoles = %+v", roles)
+	}
+	for _, tc := range []struct {
+		perm  string
+		attrs map[string]string
+		want  bool
+	}{
+		{"docs.read", nil, true},
+		{"docs.write", map[string]string{"owner": u.ID}, true},
+		{"docs.write", map[string]string{"owner": "somebody"}, false},
+		{"docs.write", nil, false},
+		{"users.delete", nil, false},
+	} {
+		ok, err := f.rbac.AssertPermission(*s, tc.perm, tc.attrs)
+		must(t, err)
+		if ok != tc.want {
+			t.Errorf("AssertPermission(%v, %v) = %v, want %v", tc.perm, tc.attrs, ok, tc.want)
+		}
+	}
+
+	must(t, f.users.AssignRoles(u.ID, []string{"admin"}, d.ID))
+	if ok, err := f.rbac.AssertPermission(*s, "users.delete", nil); err != nil || !ok {
+		t.Errorf("AssertPermission of a wildcard permission = %v, %v", ok, err)
+	}
+
+	must(t, f.rbac.DeleteRole("editor"))
+	roles, err = f.rbac.ListEffectiveRoles(*s)
+	must(t, err)
+	if len(roles) != 1 || roles[0].Name != "admin" {
+		t.Errorf("ListEffectiveRoles after DeleteRole = %+v", roles)
+	}
+	c, err := f.rbac.ListPermissionsByRole("admin", firstPage, byName)
+	must(t, err)
+	if c.Paginator.Total != 1 || c.Permissions[0].Name != "users.*" {
+		t.Errorf("ListPermissionsByRole = %+v", c)
+	}
+}
+
+func TestRBACInteractorSoDConstraints(t *testing.T) {
+	f := newFixture()
+	d := f.domain(t, "domain1.com")
+	u := f.user(t, "john", "secret", d)
+	for _, name := range []string{"clerk", "auditor"} {
+		r := entities.NewBasicRole(name, "")
+		r.Enabled = true
+		must(t, f.rbac.CreateRole(*r))
+	}
+	must(t, f.users.AssignRoles(u.ID, []string{"clerk", "auditor"}, d.ID))
+
+	c := entities.SoDConstraint{Name: "books", Roles: []string{"clerk", "auditor"}, Cardinality: 1}
+	if err := f.rbac.CreateSoDConstraint(c); errType(err) != errs.ErrorTypeConflict {
+		t.Errorf("CreateSoDConstraint violated by existing assignments: %v", err)
+	}
+	must(t, f.users.RevokeRoles(u.ID, []string{"auditor"}, d.ID))
+	must(t, f.rbac.CreateSoDConstraint(c))
+	if err := f.rbac.InheritRoles("clerk", []string{"auditor"}); errType(err) != errs.ErrorTypeConflict {
+		t.Errorf("InheritRoles violating a constraint: %v", err)
+	}
+
+	sc, err := f.rbac.ListSoDConstraints(firstPage, byName)
+	must(t, err)
+	if sc.Paginator.Total != 1 || len(sc.Constraints[0].Roles) != 2 {
+		t.Errorf("ListSoDConstraints = %+v", sc)
+	}
+	must(t, f.rbac.DeleteSoDConstraint("books"))
+	must(t, f.users.AssignRoles(u.ID, []string{"auditor"}, d.ID))
+}
@@ -0,0 +1,255 @@
+package usecases
+
+import (
+	"time"
+
+	"github.com/oleksandr/idp/entities"
+)
+
+//
+// DomainRepository is an interface of a storage of domains. Domains are looked up
+// by their IDs or unique names.
+//
+type DomainRepository interface {
+	Create(domain entities.BasicDomain) error
+	Update(domain entities.BasicDomain) error
+	Delete(id string) error
+	FindByID(id string) (*entities.BasicDomain, error)
+	FindByName(name string) (*entities.BasicDomain, error)
+	CountUsers(id string) (int64, error)
+	List(pager entities.Pager, sorter entities.Sorter) ([]entities.Domain, int64, error)
+	ListByUser(userID string, pager entities.Pager, sorter entities.Sorter) ([]entities.Domain, int64, error)
+}
+
+//
+// UserRepository is an interface of a storage of users and their domains. Password
+// hashes replaced by Update are kept (up to entities.MaxPasswordHistory of them).
+//
+type UserRepository interface {
+	Create(user entities.BasicUser, domainIDs []string) error
+	Update(user entities.BasicUser, addDomainIDs []string, removeDomainIDs []string) error
+	// UpdatePassword replaces a password hash of the same password (e.g. rehashed)
+	UpdatePassword(id, password string) error
+	// ListPreviousPasswords lists hashes of a user's previous passwords, most recent first
+	ListPreviousPasswords(id string, limit int) ([]string, error)
+	Delete(id string) error
+	FindByID(id string) (*entities.BasicUser, error)
+	FindByName(name string) (*entities.BasicUser, error)
+	FindInDomain(userID, domainID string) (*entities.BasicUser, error)
+	FindByNameInDomain(userName, domainID string) (*entities.BasicUser, error)
+	CountDomains(id string) (int64, error)
+	List(pager entities.Pager, sorter entities.Sorter) ([]entities.User, int64, error)
+	ListByDomain(domainID string, pager entities.Pager, sorter entities.Sorter) ([]entities.User, int64, error)
+}
+
+//
+// SessionRepository is an interface of a storage of sessions. Sessions refer to
+// their domains and users by IDs.
+//
+type SessionRepository interface {
+	Create(session entities.Session) error
+	Retain(id string, updatedOn, expiresOn time.Time) error
+	Delete(id string) error
+	DeleteExpired(now time.Time) error
+	DeleteByUser(userID string) error
+	DeleteByDomain(domainID string) error
+	FindByID(id string) (*entities.Session, error)
+	FindByReference(reference string) (*entities.Session, error)
+	FindUserSpecific(userID, domainID, userAgent, remoteAddr string) (*entities.Session, error)
+	List(pager entities.Pager, sorter entities.Sorter) ([]entities.Session, int64, error)
+}
+
+//
+// PermissionRepository is an interface of a storage of permissions
+//
+type PermissionRepository interface {
+	Create(p entities.BasicPermission) error
+	Update(p entities.BasicPermission) error
+	Rename(oldName, newName string) error
+	Delete(name string) error
+	FindByName(name string) (*entities.BasicPermission, error)
+	List(pager entities.Pager, sorter entities.Sorter) ([]entities.BasicPermission, int64, error)
+	ListByRoles(roleNames []string, pager entities.Pager, sorter entities.Sorter) ([]entities.BasicPermission, int64, error)
+}
+
+//
+// RoleRepository is an interface of a storage of roles along with their permissions,
+// inheritance, assignments to users and separation of duty constraints.
+// Changes which may violate RBAC invariants take a RoleCheck which is called with
+// the resulting state before the change is made permanent. If it returns an error
+// the change is discarded and the error is returned.
+//
+type RoleRepository interface {
+	Create(r entities.BasicRole) error
+	Update(r entities.BasicRole) error
+	Rename(oldName, newName string) error
+	Delete(name string) error
+	FindByName(name string) (*entities.BasicRole, error)
+	List(pager entities.Pager, sorter entities.Sorter) ([]entities.BasicRole, int64, error)
+	ListByNames(names []string) ([]entities.BasicRole, error)
+	ListByUser(userID string, pager entities.Pager, sorter entities.Sorter) ([]entities.BasicRole, int64, error)
+	ListAssignedNames(userID, domainID string) ([]string, error)
+	AddPermissions(roleName string, permissionNames []string) error
+	RemovePermissions(roleName string, permissionNames []string) error
+	Inheritance(enabledOnly bool) (map[string][]string, error)
+	Inherit(seniorRoleName string, juniorRoleNames []string, check RoleCheck) error
+	Uninherit(seniorRoleName string, juniorRoleNames []string) error
+	Assign(userID string, roleNames []string, domainID string, check RoleCheck) error
+	Revoke(userID string, roleNames []string, domainID string) error
+	CreateSoDConstraint(c entities.SoDConstraint, check RoleCheck) error
+	DeleteSoDConstraint(name string) error
+	ListSoDConstraints(pager entities.Pager, sorter entities.Sorter) ([]entities.SoDConstraint, int64, error)
+}
+
+//
+// RoleAssignment is an assignment of a role to a user. DomainID is empty for roles
+// assigned globally (in all domains of a user).
+//
+type RoleAssignment struct {
+	UserID   string
+	UserName string
+	RoleName string
+	DomainID string
+}
+
+//
+// RoleState is a state of RBAC relations a RoleCheck is performed against.
+// Inheritance maps a senior role's name to names of the roles it directly inherits
+// (disabled roles included). Assignments are loaded only if there are constraints
+// to check them against and only for the user being changed if any.
+//
+type RoleState struct {
+	Inheritance map[string][]string
+	Constraints []entities.SoDConstraint
+	Assignments []RoleAssignment
+}
+
+// RoleCheck validates a state of RBAC relations resulting from a change
+type RoleCheck func(state RoleState) error
+
+//
+// KeyRepository is an interface of a storage of signing keys. Private keys are
+// stored sealed (encrypted) as given.
+//
+type KeyRepository interface {
+	Create(key entities.SigningKey, sealedPrivateKey []byte) error
+	// Activate makes a key active and retires the previously active one
+	Activate(id string, now time.Time) error
+	Retire(id string, now time.Time) error
+	FindByID(id string) (*entities.SigningKey, error)
+	FindPrivateKey(id string) ([]byte, error)
+	List() ([]entities.SigningKey, error)
+}
+
+//
+// ClientRepository is an interface of a storage of OAuth 2.0 clients along with
+// refresh tokens and authorization codes issued to them. Tokens and codes are looked
+// up by their hashes and are deleted along with their clients.
+//
+type ClientRepository interface {
+	Create(client entities.Client) error
+	Delete(id string) error
+	FindByID(id string) (*entities.Client, error)
+	// List lists clients of a domain or all clients if the domain ID is empty
+	List(domainID string) ([]entities.Client, error)
+	CreateRefreshToken(token entities.RefreshToken) error
+	RetainRefreshToken(hash string, expiresOn time.Time) error
+	DeleteRefreshToken(hash string) error
+	DeleteExpiredRefreshTokens(now time.Time) error
+	FindRefreshToken(hash string) (*entities.RefreshToken, error)
+	ListRefreshTokens(clientID string) ([]entities.RefreshToken, error)
+	CreateAuthorizationCode(code entities.AuthorizationCode) error
+	// TakeAuthorizationCode finds a code and deletes it, so it can be used only once
+	TakeAuthorizationCode(hash string) (*entities.AuthorizationCode, error)
+	DeleteExpiredAuthorizationCodes(now time.Time) error
+}
+
+//
+// ServiceProviderRepository is an interface of a storage of SAML 2.0 service
+// providers. Service providers are looked up by their IDs or unique entity IDs.
+//
+type ServiceProviderRepository interface {
+	Create(sp entities.ServiceProvider) error
+	Delete(id string) error
+	FindByID(id string) (*entities.ServiceProvider, error)
+	FindByEntityID(entityID string) (*entities.ServiceProvider, error)
+	// List lists service providers of a domain or all of them if the domain ID is empty
+	List(domainID string) ([]entities.ServiceProvider, error)
+}
+
+//
+// MFARepository is an interface of a storage of users' enrollments in multi-factor
+// authentication along with their recovery codes and pending challenges. TOTP
+// secrets are stored sealed (encrypted) as given, recovery codes are looked up by
+// their hashes. Enrollments and challenges are deleted along with their users.
+//
+type MFARepository interface {
+	Create(enrollment entities.MFAEnrollment, sealedSecret []byte, recoveryCodeHashes []string) error
+	Delete(userID string) error
+	FindByUser(userID string) (*entities.MFAEnrollment, error)
+	FindSecret(userID string) ([]byte, error)
+	// UseStep records the time step of an accepted one-time password. It fails with a
+	// conflict unless the step is later than the last recorded one.
+	UseStep(userID string, step int64) error
+	// UseRecoveryCode finds a recovery code and deletes it, so it can be used only once
+	UseRecoveryCode(userID, hash string) error
+	CreateChallenge(challenge entities.MFAChallenge) error
+	FindChallenge(id string) (*entities.MFAChallenge, error)
+	// AddChallengeAttempt counts an attempt to complete a challenge. It fails with a
+	// conflict if the challenge has been attempted a given number of times already.
+	AddChallengeAttempt(id string, maxAttempts int) error
+	DeleteChallenge(id string) error
+	DeleteExpiredChallenges(now time.Time) error
+}
+
+//
+// WebAuthnRepository is an interface of a storage of users' WebAuthn credentials and
+// pending ceremonies. Credentials are looked up by their (base64url encoded) IDs and
+// are deleted along with their users, as are challenges.
+//
+type WebAuthnRepository interface {
+	CreateCredential(credential entities.Credential) error
+	DeleteCredential(userID, id string) error
+	FindCredential(id string) (*entities.Credential, error)
+	ListCredentials(userID string) ([]entities.Credential, error)
+	// UpdateSignCount records the signature counter of a credential used at a given
+	// time. It fails with a conflict unless the stored counter is still the given one.
+	UpdateSignCount(id string, from, to uint32, usedOn time.Time) error
+	CreateChallenge(challenge entities.WebAuthnChallenge) error
+	FindChallenge(id string) (*entities.WebAuthnChallenge, error)
+	DeleteChallenge(id string) error
+	DeleteExpiredChallenges(now time.Time) error
+}
+
+//
+// LockoutRepository is an interface of a storage of failed attempts to sign in. It's
+// shared by all instances of the service, so failures are counted atomically.
+//
+type LockoutRepository interface {
+	Find(scope, subject string) (*entities.LoginFailures, error)
+	// AddFailure counts a failed attempt at a given time and returns the number of
+	// failures counted. Failures are forgotten unless there have been failures or
+	// blocked attempts since a given time.
+	AddFailure(scope, subject string, now, since time.Time) (int, error)
+	// RemoveFailure takes back a failure counted in advance by AddFailure
+	RemoveFailure(scope, subject string) error
+	// Block blocks attempts until a given time unless they're blocked for longer already
+	Block(scope, subject string, until time.Time) error
+	Delete(scope, subject string) error
+	// DeleteStale deletes failures which have neither failed nor blocked attempts since
+	// a given time
+	DeleteStale(since time.Time) error
+}
+
+//
+// PasswordPolicyRepository is an interface of a storage of domains' password policies.
+// Policies are deleted along with their domains.
+//
+type PasswordPolicyRepository interface {
+	// Save creates a policy of a domain or replaces the existing one
+	Save(policy entities.PasswordPolicy) error
+	Delete(domainID string) error
+	FindByDomain(domainID string) (*entities.PasswordPolicy, error)
+	// ListByUser lists policies of all domains of a user
+	ListByUser(userID string) ([]entities.PasswordPolicy, error)
+}
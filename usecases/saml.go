@@ -0,0 +1,245 @@
+package usecases
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oleksandr/idp/config"
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+	"github.com/oleksandr/idp/jwt"
+	"github.com/oleksandr/idp/saml"
+)
+
+// Paths of the IdP's SAML endpoints relative to its public URL
+const (
+	samlMetadataPath = "/saml/metadata"
+	samlSSOPath      = "/saml/sso"
+)
+
+//
+// SAMLInteractor is an interface that defines all SAML 2.0 identity provider related
+// use-cases signatures
+//
+type SAMLInteractor interface {
+	Metadata() ([]byte, error)
+	ValidateRequest(req saml.AuthnRequest) (*entities.ServiceProvider, error)
+	Login(sp entities.ServiceProvider, userName, password, userAgent, remoteAddr string) (*entities.Session, *entities.MFAChallenge, error)
+	CompleteMFA(sp entities.ServiceProvider, challengeID, code, userAgent, remoteAddr string) (*entities.Session, error)
+	Resume(sp entities.ServiceProvider, reference, userAgent, remoteAddr string) (*entities.Session, error)
+	Respond(sp entities.ServiceProvider, req saml.AuthnRequest, session entities.Session) ([]byte, error)
+}
+
+// SAMLInteractorImpl is an actual interactor that implements SAMLInteractor. The
+// IdP's entity ID is the URL of its metadata, which is derived from the issuer (or
+// the configured IDP_JWT_ISSUER) the same way OpenID Connect endpoints are. Assertions are signed
+// with the active signing key, which must be a RS256 one; its self-signed
+// certificate is published with the metadata.
+type SAMLInteractorImpl struct {
+	ServiceProviders ServiceProviderRepository
+	Domains          DomainRepository
+	Sessions         SessionInteractor
+	RBAC             RBACInteractor
+	Keys             KeyInteractor
+	Issuer           string
+
+	mu    sync.Mutex
+	certs map[string][]byte
+}
+
+// Metadata describes the IdP to service providers
+func (inter *SAMLInteractorImpl) Metadata() ([]byte, error) {
+	entityID, ssoURL, err := inter.endpoints()
+	if err != nil {
+		return nil, err
+	}
+	_, cert, err := inter.signingKey()
+	if err != nil {
+		return nil, err
+	}
+	return saml.Metadata(entityID, ssoURL, cert), nil
+}
+
+// ValidateRequest finds an enabled service provider of an enabled domain which has
+// issued an authentication request. The request must ask for the response to be sent
+// to the service provider's ACS URL with the HTTP-POST binding, if it asks at all.
+func (inter *SAMLInteractorImpl) ValidateRequest(req saml.AuthnRequest) (*entities.ServiceProvider, error) {
+	sp, err := inter.ServiceProviders.FindByEntityID(req.Issuer)
+	if err != nil {
+		if e, ok := err.(*errs.Error); ok && e.Type == errs.ErrorTypeNotFound {
+			return nil, errs.NewUseCaseError(errs.ErrorTypeNotFound, "Service provider is not registered", err)
+		}
+		return nil, err
+	}
+	if !sp.Enabled {
+		return nil, errs.NewUseCaseError(errs.ErrorTypeForbidden, "Service provider is disabled", nil)
+	}
+	domain, err := inter.Domains.FindByID(sp.DomainID)
+	if err != nil {
+		return nil, err
+	}
+	if !domain.Enabled {
+		return nil, errs.NewUseCaseError(errs.ErrorTypeForbidden, "Domain is disabled", nil)
+	}
+	if req.AssertionConsumerServiceURL != "" && req.AssertionConsumerServiceURL != sp.ACSURL {
+		return nil, errs.NewUseCaseError(errs.ErrorTypeConflict, "ACS URL is not registered", nil)
+	}
+	if req.ProtocolBinding != "" && req.ProtocolBinding != saml.HTTPPOSTBinding {
+		return nil, errs.NewUseCaseError(errs.ErrorTypeConflict, "Protocol binding is not supported", nil)
+	}
+	return sp, nil
+}
+
+// Login opens a session of a user in the service provider's domain. Invalid
+// credentials are reported as an unauthorized error, while attempts rejected after
+// too many failures and expired passwords are returned as they are. A challenge is returned instead of
+// the session if the user has to provide a code as well, see CompleteMFA.
+func (inter *SAMLInteractorImpl) Login(sp entities.ServiceProvider, userName, password, userAgent, remoteAddr string) (*entities.Session, *entities.MFAChallenge, error) {
+	domain := entities.BasicDomain{}
+	domain.ID = sp.DomainID
+	user := entities.BasicUser{}
+	user.Name = userName
+	session, challenge, err := inter.Sessions.BeginWithPassword(domain, user, password, userAgent, remoteAddr)
+	if err != nil {
+		if e, ok := err.(*errs.Error); ok && e.Type != errs.ErrorTypeOperational && e.Type != errs.ErrorTypePasswordExpired && e.RetryAfter == 0 {
+			return nil, nil, errs.NewUseCaseError(errs.ErrorTypeUnauthorized, "Invalid user name or password", err)
+		}
+		return nil, nil, err
+	}
+	return session, challenge, nil
+}
+
+// CompleteMFA completes a challenge returned by Login with a one-time password or a
+// recovery code and returns the session opened. Errors of the challenge are
+// returned as they are, so the user can be asked for another code.
+func (inter *SAMLInteractorImpl) CompleteMFA(sp entities.ServiceProvider, challengeID, code, userAgent, remoteAddr string) (*entities.Session, error) {
+	session, err := inter.Sessions.CompleteMFA(challengeID, code, userAgent, remoteAddr)
+	if err != nil {
+		return nil, err
+	}
+	if session.Domain.ID != sp.DomainID {
+		return nil, errs.NewUseCaseError(errs.ErrorTypeForbidden, "Session is not of the service provider's domain", nil)
+	}
+	return session, nil
+}
+
+// Resume finds an existing session by its reference the user has signed in with
+// before, so the user doesn't have to sign in again. The session must be of the service provider's
+// domain and of the same user agent and remote address. It's retained if found,
+// nothing is returned if there is no such session.
+func (inter *SAMLInteractorImpl) Resume(sp entities.ServiceProvider, reference, userAgent, remoteAddr string) (*entities.Session, error) {
+	if reference == "" {
+		return nil, nil
+	}
+	session, err := inter.Sessions.FindByReference(reference)
+	if err != nil {
+		if e, ok := err.(*errs.Error); ok && e.Type == errs.ErrorTypeNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if session.IsExpired() || session.Domain.ID != sp.DomainID ||
+		session.UserAgent != userAgent || session.RemoteAddr != remoteAddr {
+		return nil, nil
+	}
+	if err = inter.Sessions.Retain(*session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Respond creates a response to an authentication request with a signed assertion
+// about a session's user. The subject is identified by the user name and carries the
+// names of the roles effective in the session's domain and of the domain as attributes.
+func (inter *SAMLInteractorImpl) Respond(sp entities.ServiceProvider, req saml.AuthnRequest, session entities.Session) ([]byte, error) {
+	entityID, _, err := inter.endpoints()
+	if err != nil {
+		return nil, err
+	}
+	key, cert, err := inter.signingKey()
+	if err != nil {
+		return nil, err
+	}
+	if session.IsExpired() {
+		return nil, errs.NewUseCaseError(errs.ErrorTypeUnauthorized, "Session has expired", nil)
+	}
+
+	effective, err := inter.RBAC.ListEffectiveRoles(session)
+	if err != nil {
+		return nil, err
+	}
+	roles := []string{}
+	for _, r := range effective {
+		roles = append(roles, r.Name)
+	}
+
+	res, err := saml.NewResponse(saml.Assertion{
+		Issuer:              entityID,
+		Audience:            sp.EntityID,
+		Recipient:           sp.ACSURL,
+		InResponseTo:        req.ID,
+		NameID:              session.User.Name,
+		SessionIndex:        session.Reference,
+		AuthnInstant:        session.CreatedOn.Time,
+		SessionNotOnOrAfter: session.ExpiresOn.Time,
+		Attributes: []saml.Attribute{
+			{Name: "roles", Values: roles},
+			{Name: "domain", Values: []string{session.Domain.Name}},
+		},
+	}, key.Private, cert, time.Now())
+	if err != nil {
+		return nil, errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to sign an assertion", err)
+	}
+	return res, nil
+}
+
+// signingKey returns the active signing key along with its certificate. SAML is
+// disabled unless there is an active RS256 key.
+func (inter *SAMLInteractorImpl) signingKey() (*jwt.Key, []byte, error) {
+	key, err := inter.Keys.SigningKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	if key == nil || key.Algorithm != jwt.RS256 || key.Private == nil {
+		return nil, nil, errs.NewUseCaseError(errs.ErrorTypeConflict, "SAML requires an active RS256 signing key", nil)
+	}
+
+	inter.mu.Lock()
+	defer inter.mu.Unlock()
+	if cert, ok := inter.certs[key.ID]; ok {
+		return key, cert, nil
+	}
+	keys, err := inter.Keys.List()
+	if err != nil {
+		return nil, nil, err
+	}
+	var notBefore time.Time
+	for _, k := range keys {
+		if k.ID == key.ID {
+			notBefore = k.CreatedOn.Time
+		}
+	}
+	cert, err := saml.NewCertificate(key.Private, "IdP signing key "+key.ID, notBefore)
+	if err != nil {
+		return nil, nil, errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to create a certificate", err)
+	}
+	if inter.certs == nil {
+		inter.certs = map[string][]byte{}
+	}
+	inter.certs[key.ID] = cert
+	return key, cert, nil
+}
+
+// endpoints returns the IdP's entity ID and the URL of its single sign-on service
+func (inter *SAMLInteractorImpl) endpoints() (entityID, ssoURL string, err error) {
+	issuer := inter.Issuer
+	if issuer == "" {
+		issuer = config.JWTIssuer()
+	}
+	if issuer == "" {
+		return "", "", errs.NewUseCaseError(errs.ErrorTypeConflict, "SAML requires the issuer to be configured", nil)
+	}
+	base := strings.TrimSuffix(issuer, "/")
+	return base + samlMetadataPath, base + samlSSOPath, nil
+}
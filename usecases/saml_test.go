@@ -0,0 +1,151 @@
+package usecases_test
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+	"github.com/oleksandr/idp/jwt"
+	"github.com/oleksandr/idp/memory"
+	"github.com/oleksandr/idp/saml"
+	"github.com/oleksandr/idp/usecases"
+)
+
+// samlInteractor creates a SAML interactor signing with an active RS256 key and a
+// service provider of a given domain
+func samlInteractor(t *testing.T, f *fixture, d *entities.BasicDomain) (*usecases.SAMLInteractorImpl, *entities.ServiceProvider) {
+	keys := &usecases.KeyInteractorImpl{Keys: &memory.KeyRepository{Store: f.store}, Secret: []byte("secret")}
+	key, err := keys.Generate(jwt.RS256)
+	must(t, err)
+	must(t, keys.Activate(key.ID))
+
+	sps := &memory.ServiceProviderRepository{Store: f.store}
+	sp := entities.NewServiceProvider("App", d.ID, "https://sp.example.com", "https://sp.example.com/acs")
+	must(t, sps.Create(*sp))
+	return &usecases.SAMLInteractorImpl{
+		ServiceProviders: sps,
+		Domains:          &memory.DomainRepository{Store: f.store},
+		Sessions:         f.sessions,
+		RBAC:             f.rbac,
+		Keys:             keys,
+		Issuer:           "https://idp.example.com",
+	}, sp
+}
+
+func TestSAMLInteractorValidateRequest(t *testing.T) {
+	f := newFixture()
+	d := f.domain(t, "domain1.com")
+	inter, sp := samlInteractor(t, f, d)
+
+	for _, tc := range []struct {
+		req saml.AuthnRequest
+		err errs.ErrorType
+	}{
+		{saml.AuthnRequest{Issuer: sp.EntityID}, ""},
+		{saml.AuthnRequest{Issuer: sp.EntityID, AssertionConsumerServiceURL: sp.ACSURL, ProtocolBinding: saml.HTTPPOSTBinding}, ""},
+		{saml.AuthnRequest{Issuer: "https://unknown.example.com", AssertionConsumerServiceURL: sp.ACSURL}, errs.ErrorTypeNotFound},
+		{saml.AuthnRequest{Issuer: sp.EntityID, AssertionConsumerServiceURL: "https://evil.example.com/acs"}, errs.ErrorTypeConflict},
+		{saml.AuthnRequest{Issuer: sp.EntityID, ProtocolBinding: saml.HTTPRedirectBinding}, errs.ErrorTypeConflict},
+	} {
+		found, err := inter.ValidateRequest(tc.req)
+		if errType(err) != tc.err || (err == nil && found.ID != sp.ID) {
+			t.Errorf("ValidateRequest(%+v) = %+v, %v", tc.req, found, err)
+		}
+	}
+
+	// A service provider of another domain can't be asked for the ACS URL of this one
+	other := f.domain(t, "domain2.com")
+	sp2 := entities.NewServiceProvider("Other", other.ID, "https://other.example.com", "https://other.example.com/acs")
+	must(t, inter.ServiceProviders.Create(*sp2))
+	if _, err := inter.ValidateRequest(saml.AuthnRequest{Issuer: sp2.EntityID, AssertionConsumerServiceURL: sp.ACSURL}); errType(err) != errs.ErrorTypeConflict {
+		t.Errorf("ValidateRequest with the ACS URL of another service provider: %v", err)
+	}
+}
+
+func TestSAMLInteractorRespond(t *testing.T) {
+	f := newFixture()
+	d1 := f.domain(t, "domain1.com")
+	d2 := f.domain(t, "domain2.com")
+	u := f.user(t, "john", "secret", d1, d2)
+	inter, sp := samlInteractor(t, f, d1)
+
+	for _, name := range []string{"editor", "reader", "admin", "disabled"} {
+		r := entities.NewBasicRole(name, "")
+		r.Enabled = name != "disabled"
+		must(t, f.rbac.CreateRole(*r))
+	}
+	must(t, f.rbac.InheritRoles("editor", []string{"reader"}))
+	must(t, f.users.AssignRoles(u.ID, []string{"editor", "disabled"}, d1.ID))
+	must(t, f.users.AssignRoles(u.ID, []string{"admin"}, d2.ID))
+
+	s, err := f.sessions.Create(*d1, *u, "agent", "127.0.0.1")
+	must(t, err)
+	res, err := inter.Respond(*sp, saml.AuthnRequest{ID: "_request", Issuer: sp.EntityID}, *s)
+	must(t, err)
+
+	// Only the roles effective in the session's domain are asserted
+	var parsed struct {
+		Assertion struct {
+			Issuer         string `xml:"Issuer"`
+			NameID         string `xml:"Subject>NameID"`
+			AuthnStatement struct {
+				SessionIndex string `xml:"SessionIndex,attr"`
+			}
+			Attributes []struct {
+				Name   string   `xml:"Name,attr"`
+				Values []string `xml:"AttributeValue"`
+			} `xml:"AttributeStatement>Attribute"`
+		}
+	}
+	must(t, xml.Unmarshal(res, &parsed))
+	a := parsed.Assertion
+	if a.Issuer != "https://idp.example.com/saml/metadata" || a.NameID != "john" || a.AuthnStatement.SessionIndex != s.Reference {
+		t.Errorf("Assertion = %+v", a)
+	}
+	attributes := map[string][]string{}
+	for _, attr := range a.Attributes {
+		attributes[attr.Name] = attr.Values
+	}
+	if roles := attributes["roles"]; len(roles) != 2 || roles[0] != "editor" || roles[1] != "reader" {
+		t.Errorf("Roles asserted in domain1.com = %v", roles)
+	}
+	if domain := attributes["domain"]; len(domain) != 1 || domain[0] != "domain1.com" {
+		t.Errorf("Domain asserted = %v", domain)
+	}
+
+	// Assertions are only signed with an active RS256 key
+	inter.Keys = &usecases.KeyInteractorImpl{Keys: &memory.KeyRepository{Store: memory.NewStore()}, Secret: []byte("secret")}
+	if _, err = inter.Respond(*sp, saml.AuthnRequest{ID: "_request", Issuer: sp.EntityID}, *s); errType(err) != errs.ErrorTypeConflict {
+		t.Errorf("Respond without a signing key: %v", err)
+	}
+}
+
+func TestSAMLInteractorResume(t *testing.T) {
+	f := newFixture()
+	d1 := f.domain(t, "domain1.com")
+	d2 := f.domain(t, "domain2.com")
+	u := f.user(t, "john", "secret", d1, d2)
+	inter, sp := samlInteractor(t, f, d1)
+	s, err := f.sessions.Create(*d1, *u, "agent", "127.0.0.1")
+	must(t, err)
+	other, err := f.sessions.Create(*d2, *u, "agent", "127.0.0.1")
+	must(t, err)
+
+	// Sessions are resumed by their reference only, not by their bearer ID
+	found, err := inter.Resume(*sp, s.Reference, "agent", "127.0.0.1")
+	if err != nil || found == nil || found.ID != s.ID {
+		t.Errorf("Resume by reference = %+v, %v", found, err)
+	}
+	for _, tc := range []struct{ reference, userAgent, remoteAddr string }{
+		{s.ID, "agent", "127.0.0.1"},
+		{"", "agent", "127.0.0.1"},
+		{s.Reference, "other", "127.0.0.1"},
+		{s.Reference, "agent", "10.0.0.1"},
+		{other.Reference, "agent", "127.0.0.1"},
+	} {
+		if found, err = inter.Resume(*sp, tc.reference, tc.userAgent, tc.remoteAddr); err != nil || found != nil {
+			t.Errorf("Resume(%+v) = %+v, %v", tc, found, err)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package usecases
+
+import (
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+)
+
+//
+// ServiceProviderInteractor is an interface that defines all SAML service provider
+// related use-cases signatures
+//
+type ServiceProviderInteractor interface {
+	Create(sp entities.ServiceProvider) error
+	Delete(id string) error
+	Find(id string) (*entities.ServiceProvider, error)
+	List(domainID string) ([]entities.ServiceProvider, error)
+}
+
+// ServiceProviderInteractorImpl is an actual interactor that implements
+// ServiceProviderInteractor
+type ServiceProviderInteractorImpl struct {
+	ServiceProviders ServiceProviderRepository
+}
+
+// Create registers a new service provider. Its entity ID must not be registered yet.
+func (inter *ServiceProviderInteractorImpl) Create(sp entities.ServiceProvider) error {
+	if ok, err := sp.IsValid(); !ok {
+		return errs.NewUseCaseError(errs.ErrorTypeConflict, "Service provider is invalid", err)
+	}
+	_, err := inter.ServiceProviders.FindByEntityID(sp.EntityID)
+	if err == nil {
+		return errs.NewUseCaseError(errs.ErrorTypeConflict, "Entity ID is already registered: "+sp.EntityID, nil)
+	}
+	if e, ok := err.(*errs.Error); !ok || e.Type != errs.ErrorTypeNotFound {
+		return err
+	}
+	return inter.ServiceProviders.Create(sp)
+}
+
+// Delete deletes a service provider
+func (inter *ServiceProviderInteractorImpl) Delete(id string) error {
+	return inter.ServiceProviders.Delete(id)
+}
+
+// Find finds a service provider by given ID
+func (inter *ServiceProviderInteractorImpl) Find(id string) (*entities.ServiceProvider, error) {
+	return inter.ServiceProviders.FindByID(id)
+}
+
+// List lists service providers of a domain or all of them if the domain ID is empty
+func (inter *ServiceProviderInteractorImpl) List(domainID string) ([]entities.ServiceProvider, error) {
+	return inter.ServiceProviders.List(domainID)
+}
@@ -1,15 +1,12 @@
 package usecases
 
 import (
-	"database/sql"
-	"fmt"
 	"time"
 
 	"github.com/oleksandr/idp/config"
-	"github.com/oleksandr/idp/db"
 	"github.com/oleksandr/idp/entities"
 	"github.com/oleksandr/idp/errs"
-	"gopkg.in/gorp.v1"
+	"github.com/oleksandr/idp/webauthn"
 )
 
 //
@@ -19,80 +16,231 @@ import (
 type SessionInteractor interface {
 	Create(domain entities.BasicDomain, user entities.BasicUser, userAgent string, remoteAddr string) (*entities.Session, error)
 	CreateWithPassword(domain entities.BasicDomain, user entities.BasicUser, password, userAgent, remoteAddr string) (*entities.Session, error)
+	BeginWithPassword(domain entities.BasicDomain, user entities.BasicUser, password, userAgent, remoteAddr string) (*entities.Session, *entities.MFAChallenge, error)
+	CompleteMFA(challengeID, code, userAgent, remoteAddr string) (*entities.Session, error)
+	CompleteWebAuthn(challengeID string, res webauthn.AssertionResponse, userAgent, remoteAddr string) (*entities.Session, error)
+	ChangePassword(domain entities.BasicDomain, user entities.BasicUser, password, code, newPassword, remoteAddr string) error
+	Reauthenticate(session entities.Session, password, code, remoteAddr string) error
 	Retain(session entities.Session) error
 	Delete(session entities.Session) error
 	Purge() error
 	Find(id string) (*entities.Session, error)
+	FindByReference(reference string) (*entities.Session, error)
 	FindUserSpecific(userID, domainID, userAgent, remoteAddr string) (*entities.Session, error)
 	List(pager entities.Pager, sorter entities.Sorter) (*entities.SessionCollection, error)
 }
 
-// SessionInteractorImpl is an actual interactor that implements SessionInteractor
+// SessionInteractorImpl is an actual interactor that implements SessionInteractor.
+// Users enrolled in multi-factor authentication (and all users of domains which
+// require it) have to provide a one-time password or a recovery code along with
+// their password. Sessions opened with a WebAuthn credential need no password (nor
+// a code), since authenticators verify their users themselves. Failed attempts to
+// sign in with a password are throttled per user, remote address and domain, and
+// passwords older than the domain's password policy allows have to be changed first.
 type SessionInteractorImpl struct {
-	DBMap *gorp.DbMap
+	Domains   DomainRepository
+	Users     UserRepository
+	Sessions  SessionRepository
+	MFA       MFAInteractor
+	WebAuthn  WebAuthnInteractor
+	Lockout   LockoutInteractor
+	Passwords PasswordPolicyInteractor
 }
 
-func (inter *SessionInteractorImpl) create(domain entities.BasicDomain, user entities.BasicUser, checkPwd bool, password string, userAgent, remoteAddr string) (*entities.Session, error) {
+// authenticate finds an enabled domain and an enabled user of the domain and checks
+// the user's password if asked to. Password checks are rejected while attempts from
+// the remote address, to the domain or as the user are blocked and are reserved
+// before the password is verified, see reserve(). Unknown domains and users count as
+// failed attempts as well as invalid passwords do. The user's failures are kept
+// after a valid password, since a second factor may be required, see reset().
+func (inter *SessionInteractorImpl) authenticate(domain entities.BasicDomain, user entities.BasicUser, checkPwd bool, password, remoteAddr string) (*entities.BasicDomain, *entities.BasicUser, error) {
 	var (
-		err     error
-		d       *db.Domain
-		u       *db.User
-		s       *db.Session
-		session *entities.Session
+		err error
+		d   *entities.BasicDomain
+		u   *entities.BasicUser
 	)
 
+	if checkPwd {
+		if err = inter.Lockout.Check(entities.LockoutScopeAddress, remoteAddr); err != nil {
+			return nil, nil, err
+		}
+	}
+
 	// Check/find domain
 	if domain.ID != "" {
-		d, err = findDomainByID(inter.DBMap, domain.ID)
+		d, err = inter.Domains.FindByID(domain.ID)
 	} else if domain.Name != "" {
-		d, err = findDomainByName(inter.DBMap, domain.Name)
+		d, err = inter.Domains.FindByName(domain.Name)
 	} else {
 		err = errs.NewUseCaseError(errs.ErrorTypeConflict, "You need to provide domain ID or name", nil)
 	}
 	if err != nil {
-		return nil, err
+		if e, ok := err.(*errs.Error); ok && checkPwd && e.Type == errs.ErrorTypeNotFound {
+			return nil, nil, inter.fail(err, remoteAddr, nil, nil)
+		}
+		return nil, nil, err
 	}
 
 	// Check/find user
 	if user.ID != "" {
-		u, err = findUserByID(inter.DBMap, user.ID)
+		u, err = inter.Users.FindByID(user.ID)
 	} else if user.Name != "" {
-		u, err = findUserByName(inter.DBMap, user.Name)
+		u, err = inter.Users.FindByName(user.Name)
 	} else {
 		err = errs.NewUseCaseError(errs.ErrorTypeConflict, "You need to provide user ID or name", nil)
 	}
 	if err != nil {
-		return nil, err
+		if e, ok := err.(*errs.Error); ok && checkPwd && e.Type == errs.ErrorTypeNotFound {
+			return nil, nil, inter.fail(err, remoteAddr, d, nil)
+		}
+		return nil, nil, err
 	}
 
 	// Check if domain/user are enabled
 	if !d.Enabled {
-		return nil, errs.NewUseCaseError(errs.ErrorTypeForbidden, "Domain is disabled", nil)
+		return nil, nil, errs.NewUseCaseError(errs.ErrorTypeForbidden, "Domain is disabled", nil)
 	}
 	if !u.Enabled {
-		return nil, errs.NewUseCaseError(errs.ErrorTypeForbidden, "User is disabled", nil)
+		return nil, nil, errs.NewUseCaseError(errs.ErrorTypeForbidden, "User is disabled", nil)
 	}
 
 	// Password check
 	if checkPwd {
-		basicUser := userToEntity(u)
-		if !basicUser.IsPassword(password) {
-			return nil, errs.NewUseCaseError(errs.ErrorTypeUnauthorized, "Invalid password", nil)
+		if err = inter.reserve(remoteAddr, d, u); err != nil {
+			return nil, nil, err
+		}
+		valid := u.IsPassword(password)
+		if err = inter.release(!valid, remoteAddr, d, u); err != nil {
+			return nil, nil, err
+		}
+		if !valid {
+			return nil, nil, errs.NewUseCaseError(errs.ErrorTypeUnauthorized, "Invalid password", nil)
+		}
+		if u.PasswordNeedsRehash() {
+			inter.rehashPassword(u, password)
 		}
 	}
 
 	// Check if user is assigned to a domain
-	_, err = findUserInDomain(inter.DBMap, u.ID, d.ID)
+	_, err = inter.Users.FindInDomain(u.ID, d.ID)
 	if err != nil {
 		e := err.(*errs.Error)
 		if e.Type == errs.ErrorTypeOperational {
-			return nil, errs.NewUseCaseError(errs.ErrorTypeOperational, "Error checking user's domain", err)
+			return nil, nil, errs.NewUseCaseError(errs.ErrorTypeOperational, "Error checking user's domain", err)
+		}
+		return nil, nil, errs.NewUseCaseError(errs.ErrorTypeForbidden, "User is not in domain", err)
+	}
+	return d, u, nil
+}
+
+// fail counts a failed attempt to sign in from a remote address, to a domain and as a
+// user (unless they're unknown) and returns a given error unless counting fails
+func (inter *SessionInteractorImpl) fail(err error, remoteAddr string, d *entities.BasicDomain, u *entities.BasicUser) error {
+	if e := inter.Lockout.Fail(entities.LockoutScopeAddress, remoteAddr); e != nil {
+		return e
+	}
+	if d != nil {
+		if e := inter.Lockout.Fail(entities.LockoutScopeDomain, d.ID); e != nil {
+			return e
+		}
+	}
+	if u != nil {
+		if e := inter.Lockout.Fail(entities.LockoutScopeUser, u.ID); e != nil {
+			return e
+		}
+	}
+	return err
+}
+
+// reserve reserves an attempt to authenticate from a remote address, to a domain and
+// as a user (unless it's nil) before it's checked, so concurrent attempts are counted
+// right away. Attempts reserved before one is rejected are taken back.
+func (inter *SessionInteractorImpl) reserve(remoteAddr string, d *entities.BasicDomain, u *entities.BasicUser) error {
+	subjects := lockoutSubjects(remoteAddr, d, u)
+	for i, s := range subjects {
+		if err := inter.Lockout.Reserve(s[0], s[1]); err != nil {
+			for _, r := range subjects[:i] {
+				inter.Lockout.Release(r[0], r[1], false)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// release concludes attempts reserved by reserve()
+func (inter *SessionInteractorImpl) release(failed bool, remoteAddr string, d *entities.BasicDomain, u *entities.BasicUser) error {
+	for _, s := range lockoutSubjects(remoteAddr, d, u) {
+		if err := inter.Lockout.Release(s[0], s[1], failed); err != nil {
+			return err
 		}
-		return nil, errs.NewUseCaseError(errs.ErrorTypeForbidden, "User is not in domain", err)
 	}
+	return nil
+}
 
+// lockoutSubjects returns scopes and subjects of an attempt to authenticate
+func lockoutSubjects(remoteAddr string, d *entities.BasicDomain, u *entities.BasicUser) [][2]string {
+	subjects := [][2]string{{entities.LockoutScopeAddress, remoteAddr}}
+	if d != nil {
+		subjects = append(subjects, [2]string{entities.LockoutScopeDomain, d.ID})
+	}
+	if u != nil {
+		subjects = append(subjects, [2]string{entities.LockoutScopeUser, u.ID})
+	}
+	return subjects
+}
+
+// reset forgets failed attempts to sign in as a user once the user is fully
+// authenticated
+func (inter *SessionInteractorImpl) reset(u *entities.BasicUser) error {
+	return inter.Lockout.Reset(entities.LockoutScopeUser, u.ID)
+}
+
+// requiresMFA tells if a user has to provide a code to open a session in a domain.
+// Users who aren't enrolled can't open sessions in a domain which requires MFA.
+func (inter *SessionInteractorImpl) requiresMFA(d *entities.BasicDomain, u *entities.BasicUser) (bool, error) {
+	_, err := inter.MFA.Find(u.ID)
+	if err == nil {
+		return true, nil
+	}
+	if e, ok := err.(*errs.Error); !ok || e.Type != errs.ErrorTypeNotFound {
+		return false, err
+	}
+	if d.MFARequired {
+		return false, errs.NewUseCaseError(errs.ErrorTypeForbidden, "Domain requires multi-factor authentication, but user is not enrolled", nil)
+	}
+	return false, nil
+}
+
+// verifyCode checks a code of a user who has to provide one along with the password.
+// Invalid codes count as failed attempts of the user and the remote address.
+func (inter *SessionInteractorImpl) verifyCode(d *entities.BasicDomain, u *entities.BasicUser, code, remoteAddr string) error {
+	required, err := inter.requiresMFA(d, u)
+	if err != nil || !required {
+		return err
+	}
+	if code == "" {
+		return errs.NewUseCaseError(errs.ErrorTypeUnauthorized, "Authentication code is required", nil)
+	}
+	if err = inter.reserve(remoteAddr, nil, u); err != nil {
+		return err
+	}
+	err = inter.MFA.Verify(u.ID, code)
+	failed := false
+	if e, ok := err.(*errs.Error); ok && e.Type == errs.ErrorTypeUnauthorized {
+		failed = true
+	}
+	if e := inter.release(failed, remoteAddr, nil, u); e != nil {
+		return e
+	}
+	return err
+}
+
+// open retains an existing session of a user in a domain from the same user agent and
+// remote address or creates a new one
+func (inter *SessionInteractorImpl) open(d *entities.BasicDomain, u *entities.BasicUser, userAgent, remoteAddr string) (*entities.Session, error) {
 	// Lookup existing
-	session, err = inter.FindUserSpecific(u.ID, d.ID, userAgent, remoteAddr)
+	session, err := inter.FindUserSpecific(u.ID, d.ID, userAgent, remoteAddr)
 	if session != nil && !session.IsExpired() {
 		err = inter.Retain(*session)
 		if err != nil {
@@ -102,183 +250,228 @@ func (inter *SessionInteractorImpl) create(domain entities.BasicDomain, user ent
 	}
 
 	// Create new session
-	session = entities.NewSession(*userToEntity(u), *domainToEntity(d), userAgent, remoteAddr)
-	s = &db.Session{
-		ID:         session.ID,
-		UserAgent:  session.UserAgent,
-		RemoteAddr: session.RemoteAddr,
-		DomainPK:   d.PK,
-		UserPK:     u.PK,
-		CreatedOn:  session.CreatedOn.Time,
-		UpdatedOn:  session.UpdatedOn.Time,
-		ExpiresOn:  session.ExpiresOn.Time,
-	}
-	err = inter.DBMap.Insert(s)
+	session = entities.NewSession(*u, *d, userAgent, remoteAddr)
+	err = inter.Sessions.Create(*session)
 	if err != nil {
-		return nil, errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to create a session", err)
+		return nil, err
 	}
 	return session, nil
 }
 
+// rehashPassword upgrades a legacy or outdated password hash of a user who
+// has just been authenticated. It is a best effort: the login must not fail
+// because of it, so errors are ignored and the upgrade is retried next time.
+func (inter *SessionInteractorImpl) rehashPassword(u *entities.BasicUser, password string) {
+	rehashed := *u
+	if err := rehashed.SetPassword(password); err != nil {
+		return
+	}
+	if err := inter.Users.UpdatePassword(u.ID, rehashed.Password); err == nil {
+		u.Password = rehashed.Password
+	}
+}
+
 // Create a user session for a given domain, user and user's agent with remote address
 func (inter *SessionInteractorImpl) Create(domain entities.BasicDomain, user entities.BasicUser, userAgent string, remoteAddr string) (*entities.Session, error) {
-	return inter.create(domain, user, false, "", userAgent, remoteAddr)
+	d, u, err := inter.authenticate(domain, user, false, "", "")
+	if err != nil {
+		return nil, err
+	}
+	return inter.open(d, u, userAgent, remoteAddr)
 }
 
-// CreateWithPassword is the same as Create() but also performs password checks
+// CreateWithPassword is the same as Create() but also performs password checks. It
+// fails for users who have to provide a code as well, see BeginWithPassword().
 func (inter *SessionInteractorImpl) CreateWithPassword(domain entities.BasicDomain, user entities.BasicUser, password, userAgent, remoteAddr string) (*entities.Session, error) {
-	return inter.create(domain, user, true, password, userAgent, remoteAddr)
+	d, u, err := inter.authenticate(domain, user, true, password, remoteAddr)
+	if err != nil {
+		return nil, err
+	}
+	if err = inter.Passwords.CheckAge(*u, d.ID); err != nil {
+		return nil, err
+	}
+	required, err := inter.requiresMFA(d, u)
+	if err != nil {
+		return nil, err
+	}
+	if required {
+		return nil, errs.NewUseCaseError(errs.ErrorTypeForbidden, "Multi-factor authentication is required", nil)
+	}
+	if err = inter.reset(u); err != nil {
+		return nil, err
+	}
+	return inter.open(d, u, userAgent, remoteAddr)
 }
 
-// Retain prolongs session's expiration date/time till given time
-func (inter *SessionInteractorImpl) Retain(session entities.Session) error {
-	now := time.Now().UTC()
-	expiresOn := now.Add(time.Duration(config.SessionTTLMinutes()) * time.Minute)
-
-	r, err := inter.DBMap.Exec("UPDATE session SET expires_on = ?, updated_on = ? WHERE session_id = ?", expiresOn, now, session.ID)
+// BeginWithPassword performs password checks and opens a session like
+// CreateWithPassword() unless the user has to provide a code as well. In that case
+// a challenge is returned instead, which has to be completed with CompleteMFA().
+func (inter *SessionInteractorImpl) BeginWithPassword(domain entities.BasicDomain, user entities.BasicUser, password, userAgent, remoteAddr string) (*entities.Session, *entities.MFAChallenge, error) {
+	d, u, err := inter.authenticate(domain, user, true, password, remoteAddr)
 	if err != nil {
-		return errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to retain a session", err)
+		return nil, nil, err
 	}
-
-	_, err = r.RowsAffected()
+	if err = inter.Passwords.CheckAge(*u, d.ID); err != nil {
+		return nil, nil, err
+	}
+	required, err := inter.requiresMFA(d, u)
+	if err != nil {
+		return nil, nil, err
+	}
+	if required {
+		c, err := inter.MFA.Challenge(*u, *d, userAgent, remoteAddr)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, c, nil
+	}
+	if err = inter.reset(u); err != nil {
+		return nil, nil, err
+	}
+	session, err := inter.open(d, u, userAgent, remoteAddr)
 	if err != nil {
-		return errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to retain a session", err)
+		return nil, nil, err
 	}
+	return session, nil, nil
+}
 
-	return err
+// CompleteMFA completes a challenge returned by BeginWithPassword() with a one-time
+// password or a recovery code and opens the session. The domain and the user are
+// checked again, since they may have been changed in the meantime.
+func (inter *SessionInteractorImpl) CompleteMFA(challengeID, code, userAgent, remoteAddr string) (*entities.Session, error) {
+	c, err := inter.MFA.CompleteChallenge(challengeID, code, userAgent, remoteAddr)
+	if err != nil {
+		return nil, err
+	}
+	domain := entities.BasicDomain{}
+	domain.ID = c.DomainID
+	user := entities.BasicUser{}
+	user.ID = c.UserID
+	d, u, err := inter.authenticate(domain, user, false, "", "")
+	if err != nil {
+		return nil, err
+	}
+	return inter.open(d, u, userAgent, remoteAddr)
 }
 
-// Delete deletes session from database
-func (inter *SessionInteractorImpl) Delete(session entities.Session) error {
-	var (
-		s   db.Session
-		err error
-	)
+// CompleteWebAuthn completes a challenge of WebAuthnInteractor.BeginAssertion() with
+// an assertion of a credential and opens a session of the credential's user. The
+// domain and the user are checked the same way as for CreateWithPassword().
+func (inter *SessionInteractorImpl) CompleteWebAuthn(challengeID string, res webauthn.AssertionResponse, userAgent, remoteAddr string) (*entities.Session, error) {
+	c, err := inter.WebAuthn.FinishAssertion(challengeID, res)
+	if err != nil {
+		return nil, err
+	}
+	domain := entities.BasicDomain{}
+	domain.ID = c.DomainID
+	user := entities.BasicUser{}
+	user.ID = c.UserID
+	d, u, err := inter.authenticate(domain, user, false, "", "")
+	if err != nil {
+		return nil, err
+	}
+	return inter.open(d, u, userAgent, remoteAddr)
+}
 
-	err = inter.DBMap.SelectOne(&s, "SELECT * FROM session WHERE session_id = ?", session.ID)
-	if err == sql.ErrNoRows {
-		return errs.NewUseCaseError(errs.ErrorTypeNotFound, "Session not found by given ID", err)
-	} else if err != nil {
-		return errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to perform a lookup of a session", err)
+// ChangePassword changes a user's password once it has expired. The current password
+// and the code (if the user has to provide one) are checked the same way
+// Reauthenticate() does, unexpired passwords can't be changed this way. The new
+// password has to comply with the password policies of the user's domains. No
+// session is opened.
+func (inter *SessionInteractorImpl) ChangePassword(domain entities.BasicDomain, user entities.BasicUser, password, code, newPassword, remoteAddr string) error {
+	d, u, err := inter.authenticate(domain, user, true, password, remoteAddr)
+	if err != nil {
+		return err
+	}
+	err = inter.Passwords.CheckAge(*u, d.ID)
+	if err == nil {
+		return errs.NewUseCaseError(errs.ErrorTypeForbidden, "Password has not expired", nil)
+	}
+	if e, ok := err.(*errs.Error); !ok || e.Type != errs.ErrorTypePasswordExpired {
+		return err
+	}
+	if err = inter.verifyCode(d, u, code, remoteAddr); err != nil {
+		return err
+	}
+	if err = inter.reset(u); err != nil {
+		return err
 	}
+	if newPassword == "" {
+		return errs.NewUseCaseError(errs.ErrorTypeConflict, "Password cannot be empty", nil)
+	}
+	if err = inter.Passwords.Check(*u, newPassword, nil, nil); err != nil {
+		return err
+	}
+	if err = u.SetPassword(newPassword); err != nil {
+		return errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to change password", err)
+	}
+	return inter.Users.Update(*u, nil, nil)
+}
 
-	_, err = inter.DBMap.Delete(&s)
+// Reauthenticate checks the password (and the code, if the user has to provide one)
+// of a session's user once again before a sensitive change, e.g. registering a
+// credential, so a hijacked session alone isn't enough for it. Failures are counted
+// the same way as for BeginWithPassword() and CompleteMFA().
+func (inter *SessionInteractorImpl) Reauthenticate(session entities.Session, password, code, remoteAddr string) error {
+	d, u, err := inter.authenticate(*session.Domain, *session.User, true, password, remoteAddr)
 	if err != nil {
-		return errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to delete session", err)
+		return err
 	}
-	return nil
+	if err = inter.verifyCode(d, u, code, remoteAddr); err != nil {
+		return err
+	}
+	return inter.reset(u)
 }
 
-// Purge purges all expired sessions
-func (inter *SessionInteractorImpl) Purge() error {
+// Retain prolongs session's expiration date/time till given time
+func (inter *SessionInteractorImpl) Retain(session entities.Session) error {
 	now := time.Now().UTC()
-	_, err := inter.DBMap.Exec("DELETE FROM session WHERE expires_on <= ?", now)
-	return err
+	expiresOn := now.Add(time.Duration(config.SessionTTLMinutes()) * time.Minute)
+	return inter.Sessions.Retain(session.ID, now, expiresOn)
+}
+
+// Delete deletes session from database
+func (inter *SessionInteractorImpl) Delete(session entities.Session) error {
+	return inter.Sessions.Delete(session.ID)
+}
+
+// Purge purges all expired sessions, challenges and stale failed attempts
+func (inter *SessionInteractorImpl) Purge() error {
+	if err := inter.MFA.Purge(); err != nil {
+		return err
+	}
+	if err := inter.WebAuthn.Purge(); err != nil {
+		return err
+	}
+	if err := inter.Lockout.Purge(); err != nil {
+		return err
+	}
+	return inter.Sessions.DeleteExpired(time.Now().UTC())
 }
 
 // Find looks for a session by given session ID
 func (inter *SessionInteractorImpl) Find(id string) (*entities.Session, error) {
-	var (
-		sv      db.SessionView
-		err     error
-		userTbl = inter.DBMap.Dialect.QuotedTableForQuery("", "user")
-	)
+	return inter.Sessions.FindByID(id)
+}
 
-	q := fmt.Sprintf(`SELECT s.*,
-			d.domain_id AS domain_id, d.object_id AS domain_object_id, d.name AS domain_name, d.is_enabled AS domain_enabled,
-			u.user_id AS user_id, u.object_id AS user_object_id, u.name AS user_name, u.is_enabled AS user_enabled
-		FROM session AS s
-        LEFT JOIN %v AS u ON s.user_id=u.user_id
-        LEFT JOIN domain AS d ON d.domain_id=s.domain_id
-        WHERE s.session_id = ?
-        LIMIT 1;`, userTbl)
-	err = inter.DBMap.SelectOne(&sv, q, id)
-	if err == sql.ErrNoRows {
-		return nil, errs.NewUseCaseError(errs.ErrorTypeNotFound, "Session not found by given ID", err)
-	} else if err != nil {
-		return nil, errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to perform a lookup of a session", err)
-	}
-
-	return sessionToEntity(&sv), nil
+// FindByReference finds a session by the reference tokens carry
+func (inter *SessionInteractorImpl) FindByReference(reference string) (*entities.Session, error) {
+	return inter.Sessions.FindByReference(reference)
 }
 
 // FindUserSpecific looks for a session by given session ID, user agent and remote address
 func (inter *SessionInteractorImpl) FindUserSpecific(userID, domainID, userAgent, remoteAddr string) (*entities.Session, error) {
-	var (
-		sv      db.SessionView
-		err     error
-		userTbl = inter.DBMap.Dialect.QuotedTableForQuery("", "user")
-	)
-
-	q := fmt.Sprintf(`SELECT s.*,
-			d.domain_id AS domain_id, d.object_id AS domain_object_id, d.name AS domain_name, d.is_enabled AS domain_enabled,
-			u.user_id AS user_id, u.object_id AS user_object_id, u.name AS user_name, u.is_enabled AS user_enabled
-		FROM session AS s
-        LEFT JOIN %v AS u ON s.user_id=u.user_id
-        LEFT JOIN domain AS d ON d.domain_id=s.domain_id
-        WHERE u.object_id = ? AND d.object_id = ? AND s.user_agent = ? AND s.remote_addr = ?
-        LIMIT 1;`, userTbl)
-	err = inter.DBMap.SelectOne(&sv, q, userID, domainID, userAgent, remoteAddr)
-	if err == sql.ErrNoRows {
-		return nil, errs.NewUseCaseError(errs.ErrorTypeNotFound, "Session not found by given ID", err)
-	} else if err != nil {
-		return nil, errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to perform a lookup of a session", err)
-	}
-
-	return sessionToEntity(&sv), nil
+	return inter.Sessions.FindUserSpecific(userID, domainID, userAgent, remoteAddr)
 }
 
 // List implements a paginated listing of session
 func (inter *SessionInteractorImpl) List(pager entities.Pager, sorter entities.Sorter) (*entities.SessionCollection, error) {
-	total, err := inter.DBMap.SelectInt("SELECT COUNT(*) FROM session")
+	sessions, total, err := inter.Sessions.List(pager, sorter)
 	if err != nil {
-		return nil, errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to count sessions", err)
-	}
-
-	var records []db.SessionView
-	userTbl := inter.DBMap.Dialect.QuotedTableForQuery("", "user")
-	q := fmt.Sprintf(`SELECT s.*,
-			d.domain_id AS domain_id, d.object_id AS domain_object_id, d.name AS domain_name, d.is_enabled AS domain_enabled,
-			u.user_id AS user_id, u.object_id AS user_object_id, u.name AS user_name, u.is_enabled AS user_enabled
-		FROM session AS s
-        LEFT JOIN %v AS u ON s.user_id=u.user_id
-        LEFT JOIN domain AS d ON d.domain_id=s.domain_id
-        %v %v;`, userTbl, db.OrderByClause(sorter, "s"), db.LimitOffset(pager))
-	_, err = inter.DBMap.Select(&records, q)
-	if err == sql.ErrNoRows {
-		return nil, errs.NewUseCaseError(errs.ErrorTypeNotFound, "No sessions found", err)
-	} else if err != nil {
-		return nil, errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to perform a lookup of sessions", err)
-	}
-
-	c := &entities.SessionCollection{
-		Sessions:  []entities.Session{},
-		Paginator: *pager.CreatePaginator(len(records), total),
-	}
-	for _, r := range records {
-		c.Sessions = append(c.Sessions, *sessionToEntity(&r))
+		return nil, err
 	}
-	return c, nil
-}
-
-func sessionToEntity(s *db.SessionView) *entities.Session {
-	e := &entities.Session{
-		ID: s.ID,
-		Domain: &entities.BasicDomain{
-			ID:      s.DomainID,
-			Name:    s.DomainName,
-			Enabled: s.DomainEnabled,
-		},
-		User: &entities.BasicUser{
-			ID:      s.UserID,
-			Name:    s.UserName,
-			Enabled: s.UserEnabled,
-		},
-		UserAgent:  s.UserAgent,
-		RemoteAddr: s.RemoteAddr,
-	}
-	e.CreatedOn.Time = s.CreatedOn
-	e.UpdatedOn.Time = s.UpdatedOn
-	e.ExpiresOn.Time = s.ExpiresOn
-	return e
+	return &entities.SessionCollection{
+		Sessions:  sessions,
+		Paginator: *pager.CreatePaginator(len(sessions), total),
+	}, nil
 }
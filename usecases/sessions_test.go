@@ -0,0 +1,212 @@
+package usecases_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/oleksandr/idp/config"
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+	"github.com/oleksandr/idp/memory"
+)
+
+func TestSessionInteractor(t *testing.T) {
+	f := newFixture()
+	d := f.domain(t, "domain1.com")
+	u := f.user(t, "john", "secret", d)
+	f.domain(t, "domain2.com")
+
+	s, err := f.sessions.CreateWithPassword(*d, *u, "secret", "agent", "127.0.0.1")
+	must(t, err)
+	if s.User.ID != u.ID || s.Domain.ID != d.ID || s.IsExpired() {
+		t.Errorf("CreateWithPassword = %+v", s)
+	}
+	s2, err := f.sessions.CreateWithPassword(*d, *u, "secret", "agent", "127.0.0.1")
+	must(t, err)
+	if s2.ID != s.ID {
+		t.Errorf("CreateWithPassword from the same agent and address opened another session")
+	}
+	s3, err := f.sessions.CreateWithPassword(*d, *u, "secret", "other agent", "127.0.0.1")
+	must(t, err)
+	if s3.ID == s.ID {
+		t.Errorf("CreateWithPassword from another agent retained a session")
+	}
+
+	byName := entities.BasicDomain{Name: "domain1.com"}
+	for _, tc := range []struct {
+		domain   entities.BasicDomain
+		user     entities.BasicUser
+		password string
+		err      errs.ErrorType
+	}{
+		{*d, *u, "wrong", errs.ErrorTypeUnauthorized},
+		{byName, entities.BasicUser{Name: "john"}, "wrong", errs.ErrorTypeUnauthorized},
+		{entities.BasicDomain{Name: "domain2.com"}, *u, "secret", errs.ErrorTypeForbidden},
+		{entities.BasicDomain{Name: "missing"}, *u, "secret", errs.ErrorTypeNotFound},
+		{byName, entities.BasicUser{Name: "missing"}, "secret", errs.ErrorTypeNotFound},
+		{entities.BasicDomain{}, *u, "secret", errs.ErrorTypeConflict},
+	} {
+		_, err := f.sessions.CreateWithPassword(tc.domain, tc.user, tc.password, "agent", "127.0.0.1")
+		if errType(err) != tc.err {
+			t.Errorf("CreateWithPassword(%v, %v, %v) = %v, want %v", tc.domain.Name, tc.user.Name, tc.password, err, tc.err)
+		}
+	}
+
+	found, err := f.sessions.Find(s.ID)
+	must(t, err)
+	if found.User.Name != "john" || found.Domain.Name != "domain1.com" {
+		t.Errorf("Find = %+v", found)
+	}
+	found, err = f.sessions.FindUserSpecific(u.ID, d.ID, "other agent", "127.0.0.1")
+	must(t, err)
+	if found.ID != s3.ID {
+		t.Errorf("FindUserSpecific = %+v, want %v", found, s3.ID)
+	}
+
+	must(t, f.sessions.Delete(*s))
+	if _, err = f.sessions.Find(s.ID); errType(err) != errs.ErrorTypeNotFound {
+		t.Errorf("Find of a deleted session: %v", err)
+	}
+	c, err := f.sessions.List(firstPage, entities.Sorter{})
+	must(t, err)
+	if len(c.Sessions) != 1 || c.Sessions[0].ID != s3.ID {
+		t.Errorf("List = %+v", c.Sessions)
+	}
+}
+
+func TestSessionInteractorDisabled(t *testing.T) {
+	f := newFixture()
+	d := f.domain(t, "domain1.com")
+	u := f.user(t, "john", "secret", d)
+
+	d.Enabled = false
+	must(t, f.domains.Update(*d))
+	if _, err := f.sessions.CreateWithPassword(*d, *u, "secret", "agent", "127.0.0.1"); errType(err) != errs.ErrorTypeForbidden {
+		t.Errorf("CreateWithPassword in a disabled domain: %v", err)
+	}
+	d.Enabled = true
+	must(t, f.domains.Update(*d))
+
+	u.Enabled = false
+	must(t, f.users.Update(*u, "", nil, nil))
+	if _, err := f.sessions.CreateWithPassword(*d, *u, "secret", "agent", "127.0.0.1"); errType(err) != errs.ErrorTypeForbidden {
+		t.Errorf("CreateWithPassword as a disabled user: %v", err)
+	}
+}
+
+func TestSessionInteractorReauthenticate(t *testing.T) {
+	f := newFixture()
+	d := f.domain(t, "domain1.com")
+	u := f.user(t, "john", "secret", d)
+	s, err := f.sessions.CreateWithPassword(*d, *u, "secret", "agent", "127.0.0.1")
+	must(t, err)
+
+	must(t, f.sessions.Reauthenticate(*s, "secret", "", "127.0.0.1"))
+	if err = f.sessions.Reauthenticate(*s, "wrong", "", "127.0.0.1"); errType(err) != errs.ErrorTypeUnauthorized {
+		t.Errorf("Reauthenticate with an invalid password: %v", err)
+	}
+
+	creds, err := f.mfa.Enroll(u.ID)
+	must(t, err)
+	code, invalid := totpCodes(t, creds)
+	for _, c := range []string{"", invalid} {
+		if err = f.sessions.Reauthenticate(*s, "secret", c, "127.0.0.1"); errType(err) != errs.ErrorTypeUnauthorized {
+			t.Errorf("Reauthenticate of an enrolled user with code %q: %v", c, err)
+		}
+	}
+	must(t, f.sessions.Reauthenticate(*s, "secret", code, "127.0.0.1"))
+
+	for i := 0; i < 5; i++ {
+		if err = f.sessions.Reauthenticate(*s, "secret", invalid, "127.0.0.1"); errType(err) != errs.ErrorTypeUnauthorized {
+			t.Fatalf("Reauthenticate %v with an invalid code: %v", i, err)
+		}
+	}
+	if err = f.sessions.Reauthenticate(*s, "secret", code, "127.0.0.1"); errType(err) != errs.ErrorTypeForbidden {
+		t.Errorf("Reauthenticate of a locked out user: %v", err)
+	}
+}
+
+// expirePassword makes a user's password older than a policy of 30 days allows
+func expirePassword(t *testing.T, f *fixture, u *entities.BasicUser) {
+	// The change date is only stored along with a new password hash
+	must(t, u.SetPassword("secret"))
+	u.PasswordChangedOn.Time = time.Now().UTC().AddDate(0, 0, -31)
+	must(t, (&memory.UserRepository{Store: f.store}).Update(*u, nil, nil))
+}
+
+func TestSessionInteractorChangePassword(t *testing.T) {
+	f := newFixture()
+	d := f.domain(t, "domain1.com")
+	u := f.user(t, "john", "secret", d)
+	must(t, f.sessions.Passwords.Save(entities.PasswordPolicy{DomainID: d.ID, MinLength: 6, MaxAgeDays: 30}))
+
+	if err := f.sessions.ChangePassword(*d, *u, "secret", "", "changed", "127.0.0.1"); errType(err) != errs.ErrorTypeForbidden {
+		t.Errorf("ChangePassword of an unexpired password: %v", err)
+	}
+
+	expirePassword(t, f, u)
+	if _, err := f.sessions.CreateWithPassword(*d, *u, "secret", "agent", "127.0.0.1"); errType(err) != errs.ErrorTypePasswordExpired {
+		t.Fatalf("CreateWithPassword with an expired password: %v", err)
+	}
+	if err := f.sessions.ChangePassword(*d, *u, "wrong", "", "changed", "127.0.0.1"); errType(err) != errs.ErrorTypeUnauthorized {
+		t.Errorf("ChangePassword with an invalid password: %v", err)
+	}
+	if err := f.sessions.ChangePassword(*d, *u, "secret", "", "short", "127.0.0.1"); errType(err) != errs.ErrorTypeConflict {
+		t.Errorf("ChangePassword to a password violating the policy: %v", err)
+	}
+	must(t, f.sessions.ChangePassword(*d, *u, "secret", "", "changed", "127.0.0.1"))
+	if _, err := f.sessions.CreateWithPassword(*d, *u, "changed", "agent", "127.0.0.1"); err != nil {
+		t.Errorf("CreateWithPassword with a changed password: %v", err)
+	}
+}
+
+func TestSessionInteractorChangePasswordMFA(t *testing.T) {
+	f := newFixture()
+	d := f.domain(t, "domain1.com")
+	u := f.user(t, "john", "secret", d)
+	must(t, f.sessions.Passwords.Save(entities.PasswordPolicy{DomainID: d.ID, MaxAgeDays: 30}))
+	creds, err := f.mfa.Enroll(u.ID)
+	must(t, err)
+	expirePassword(t, f, u)
+
+	code, invalid := totpCodes(t, creds)
+	for _, c := range []string{"", invalid} {
+		if err = f.sessions.ChangePassword(*d, *u, "secret", c, "changed", "127.0.0.1"); errType(err) != errs.ErrorTypeUnauthorized {
+			t.Errorf("ChangePassword of an enrolled user with code %q: %v", c, err)
+		}
+	}
+	if found, _ := f.users.Find(u.ID); !found.IsPassword("secret") {
+		t.Fatal("ChangePassword without a valid code changed the password")
+	}
+	must(t, f.sessions.ChangePassword(*d, *u, "secret", code, "changed", "127.0.0.1"))
+	if found, _ := f.users.Find(u.ID); !found.IsPassword("changed") {
+		t.Error("ChangePassword with a valid code didn't change the password")
+	}
+}
+
+func TestSessionInteractorRehash(t *testing.T) {
+	f := newFixture()
+	d := f.domain(t, "domain1.com")
+	u := f.user(t, "john", "secret", d)
+	users := &memory.UserRepository{Store: f.store}
+	legacy, err := (&entities.LegacySHA1Hasher{Salt: config.HashSecretSalt()}).Hash("secret")
+	must(t, err)
+	must(t, users.UpdatePassword(u.ID, legacy))
+
+	if _, err = f.sessions.CreateWithPassword(*d, *u, "wrong", "agent", "127.0.0.1"); errType(err) != errs.ErrorTypeUnauthorized {
+		t.Fatalf("CreateWithPassword with an invalid password: %v", err)
+	}
+	if found, _ := users.FindByID(u.ID); found.Password != legacy {
+		t.Errorf("Failed sign-in rehashed the password: %v", found.Password)
+	}
+	_, err = f.sessions.CreateWithPassword(*d, *u, "secret", "agent", "127.0.0.1")
+	must(t, err)
+	found, err := users.FindByID(u.ID)
+	must(t, err)
+	if found.Password == legacy || found.PasswordNeedsRehash() || !found.IsPassword("secret") {
+		t.Errorf("Password after sign-in = %v, want a current hash of the same password", found.Password)
+	}
+	if !found.PasswordChangedOn.Equal(u.PasswordChangedOn.Time) {
+		t.Errorf("Rehashing changed the password's age: %v, want %v", found.PasswordChangedOn, u.PasswordChangedOn)
+	}
+}
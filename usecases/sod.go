@@ -0,0 +1,139 @@
+package usecases
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+)
+
+// CreateSoDConstraint creates a new static separation of duty constraint. The constraint
+// is rejected if existing role assignments already violate it.
+func (inter *RBACInteractorImpl) CreateSoDConstraint(c entities.SoDConstraint) error {
+	if c.Name == "" {
+		return errs.NewUseCaseError(errs.ErrorTypeConflict, "Constraint name cannot be empty", nil)
+	}
+	if len(c.Roles) < 2 {
+		return errs.NewUseCaseError(errs.ErrorTypeConflict, "Constraint requires at least 2 roles", nil)
+	}
+	if c.Cardinality < 1 || c.Cardinality >= len(c.Roles) {
+		return errs.NewUseCaseError(errs.ErrorTypeConflict, fmt.Sprintf("Constraint cardinality must be between 1 and %v", len(c.Roles)-1), nil)
+	}
+
+	unique := map[string]bool{}
+	for _, name := range c.Roles {
+		unique[name] = true
+	}
+	if len(unique) != len(c.Roles) {
+		return errs.NewUseCaseError(errs.ErrorTypeConflict, "Constraint roles must be unique", nil)
+	}
+
+	return inter.Roles.CreateSoDConstraint(c, func(state RoleState) error {
+		// Verify existing assignments of all users
+		for _, constraint := range state.Constraints {
+			if constraint.Name == c.Name {
+				return checkAllSoDConstraints(roleGraph(state.Inheritance), []entities.SoDConstraint{constraint}, state.Assignments)
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteSoDConstraint deletes an existing separation of duty constraint
+func (inter *RBACInteractorImpl) DeleteSoDConstraint(name string) error {
+	return inter.Roles.DeleteSoDConstraint(name)
+}
+
+// ListSoDConstraints lists existing separation of duty constraints page by page
+func (inter *RBACInteractorImpl) ListSoDConstraints(pager entities.Pager, sorter entities.Sorter) (*entities.SoDConstraintCollection, error) {
+	constraints, total, err := inter.Roles.ListSoDConstraints(pager, sorter)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range constraints {
+		sort.Strings(c.Roles)
+	}
+	return &entities.SoDConstraintCollection{
+		Constraints: constraints,
+		Paginator:   *pager.CreatePaginator(len(constraints), total),
+	}, nil
+}
+
+// violatesSoDConstraint checks if a set of held roles contains more roles of
+// a constraint than allowed
+func violatesSoDConstraint(c entities.SoDConstraint, held []string) bool {
+	roles := map[string]bool{}
+	for _, name := range c.Roles {
+		roles[name] = true
+	}
+	n := 0
+	for _, name := range held {
+		if roles[name] {
+			n++
+		}
+	}
+	return n > c.Cardinality
+}
+
+// checkAllSoDConstraints checks role assignments of all users against given constraints
+func checkAllSoDConstraints(graph roleGraph, constraints []entities.SoDConstraint, assignments []RoleAssignment) error {
+	if len(constraints) == 0 {
+		return nil
+	}
+
+	var ids []string
+	users := map[string][]RoleAssignment{}
+	for _, ra := range assignments {
+		if _, ok := users[ra.UserID]; !ok {
+			ids = append(ids, ra.UserID)
+		}
+		users[ra.UserID] = append(users[ra.UserID], ra)
+	}
+
+	for _, id := range ids {
+		if err := checkSoDConstraints(graph, constraints, users[id]); err != nil {
+			e := err.(*errs.Error)
+			return errs.NewUseCaseError(errs.ErrorTypeConflict, fmt.Sprintf("Role assignments of user %v: %v", users[id][0].UserName, e.Msg), nil)
+		}
+	}
+	return nil
+}
+
+// checkSoDConstraints checks role assignments of a single user against given constraints.
+// Global assignments are checked on their own and together with the assignments of every
+// domain, taking role inheritance into account.
+func checkSoDConstraints(graph roleGraph, constraints []entities.SoDConstraint, assignments []RoleAssignment) error {
+	if len(constraints) == 0 {
+		return nil
+	}
+
+	var global []string
+	scoped := map[string][]string{}
+	for _, ra := range assignments {
+		if ra.DomainID == "" {
+			global = append(global, ra.RoleName)
+		} else {
+			scoped[ra.DomainID] = append(scoped[ra.DomainID], ra.RoleName)
+		}
+	}
+	scopes := [][]string{global}
+	for _, names := range scoped {
+		scopes = append(scopes, append(append([]string{}, global...), names...))
+	}
+
+	for _, names := range scopes {
+		held := graph.closure(names)
+		for _, c := range constraints {
+			if violatesSoDConstraint(c, held) {
+				roles := append([]string{}, c.Roles...)
+				sort.Strings(roles)
+				msg := fmt.Sprintf("Separation of duty constraint %v is violated: a user may hold at most %v of roles %v",
+					c.Name, c.Cardinality, strings.Join(roles, ", "))
+				return errs.NewUseCaseError(errs.ErrorTypeConflict, msg, nil)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package usecases
+
+import (
+	"time"
+
+	"github.com/oleksandr/idp/config"
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+	"github.com/oleksandr/idp/jwt"
+	"github.com/satori/go.uuid"
+)
+
+//
+// TokenInteractor is an interface that defines all access token related use-cases
+// signatures
+//
+type TokenInteractor interface {
+	Issue(session entities.Session) (*entities.AccessToken, error)
+	IssueForClient(client entities.Client, session *entities.Session, scopes []string) (*entities.AccessToken, error)
+	IssueIDToken(client entities.Client, session entities.Session, scopes []string, nonce string) (string, error)
+	Verify(token string) (*entities.AccessTokenClaims, error)
+}
+
+// TokenInteractorImpl is an actual interactor that implements TokenInteractor.
+// Tokens are signed with the active key of Keys if there is one or with Key
+// otherwise. Access tokens are disabled if there is no key at all.
+type TokenInteractorImpl struct {
+	RBAC RBACInteractor
+	Keys KeyInteractor
+	Key  *jwt.Key
+}
+
+// Issue issues a signed access token of a session carrying its user's effective
+// roles. A token doesn't outlive its session, a new one is issued with the same
+// session (which is retained meanwhile) as long as it's not deleted.
+func (inter *TokenInteractorImpl) Issue(session entities.Session) (*entities.AccessToken, error) {
+	return inter.issue(nil, &session, nil)
+}
+
+// IssueForClient issues a signed access token to an OAuth client carrying the granted
+// scopes. A token of a session (password and refresh token grants) carries its user's
+// effective roles as Issue does, otherwise the client itself is the token's subject.
+func (inter *TokenInteractorImpl) IssueForClient(client entities.Client, session *entities.Session, scopes []string) (*entities.AccessToken, error) {
+	return inter.issue(&client, session, scopes)
+}
+
+// IssueIDToken issues a signed OpenID Connect ID token of a session to a client. The
+// user's email address is included if the email scope is granted.
+func (inter *TokenInteractorImpl) IssueIDToken(client entities.Client, session entities.Session, scopes []string, nonce string) (string, error) {
+	key, err := inter.enabledKey()
+	if err != nil {
+		return "", err
+	}
+	if session.IsExpired() {
+		return "", errs.NewUseCaseError(errs.ErrorTypeUnauthorized, "Session has expired", nil)
+	}
+
+	now := time.Now().UTC()
+	claims := entities.IDTokenClaims{
+		Issuer:          config.JWTIssuer(),
+		Subject:         session.User.ID,
+		Audience:        client.ID,
+		AuthorizedParty: client.ID,
+		ExpiresAt:       now.Add(time.Duration(config.AccessTokenTTLMinutes()) * time.Minute).Unix(),
+		IssuedAt:        now.Unix(),
+		AuthTime:        session.CreatedOn.Unix(),
+		Nonce:           nonce,
+		SessionRef:      session.Reference,
+		Name:            session.User.Name,
+		Domain:          session.Domain.Name,
+	}
+	if entities.HasScope(scopes, entities.ScopeEmail) {
+		if email := session.User.Email(); email != "" {
+			verified := false
+			claims.Email, claims.EmailVerified = email, &verified
+		}
+	}
+
+	token, err := jwt.Sign(claims, key)
+	if err != nil {
+		return "", errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to sign an ID token", err)
+	}
+	return token, nil
+}
+
+// Verify verifies a token with any of the keys tokens are signed with (retired keys
+// which are still published included) and returns its claims
+func (inter *TokenInteractorImpl) Verify(token string) (*entities.AccessTokenClaims, error) {
+	keys := []*jwt.Key{}
+	if inter.Keys != nil {
+		set, err := inter.Keys.PublicKeys()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, set.PublicKeys()...)
+	}
+	if inter.Key != nil {
+		keys = append(keys, inter.Key)
+	}
+
+	var claims entities.AccessTokenClaims
+	if err := jwt.Verify(token, keys, &claims); err != nil {
+		return nil, errs.NewUseCaseError(errs.ErrorTypeUnauthorized, "Invalid access token", err)
+	}
+	return &claims, nil
+}
+
+func (inter *TokenInteractorImpl) issue(client *entities.Client, session *entities.Session, scopes []string) (*entities.AccessToken, error) {
+	key, err := inter.enabledKey()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	expiresOn := now.Add(time.Duration(config.AccessTokenTTLMinutes()) * time.Minute)
+	claims := entities.AccessTokenClaims{
+		Issuer:   config.JWTIssuer(),
+		ID:       uuid.NewV4().String(),
+		IssuedAt: now.Unix(),
+		Roles:    []string{},
+	}
+	if client != nil {
+		claims.Subject = client.ID
+		claims.DomainID = client.DomainID
+		claims.ClientID = client.ID
+		claims.Scope = entities.FormatScope(scopes)
+	}
+	if session != nil {
+		if session.IsExpired() {
+			return nil, errs.NewUseCaseError(errs.ErrorTypeUnauthorized, "Session has expired", nil)
+		}
+		if session.ExpiresOn.Before(expiresOn) {
+			expiresOn = session.ExpiresOn.Time
+		}
+		roles, err := inter.RBAC.ListEffectiveRoles(*session)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range roles {
+			claims.Roles = append(claims.Roles, r.Name)
+		}
+		claims.Subject = session.User.ID
+		claims.SessionRef = session.Reference
+		claims.DomainID = session.Domain.ID
+	}
+	claims.ExpiresAt = expiresOn.Unix()
+
+	token, err := jwt.Sign(claims, key)
+	if err != nil {
+		return nil, errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to sign an access token", err)
+	}
+
+	t := &entities.AccessToken{
+		Token: token,
+		Type:  "Bearer",
+	}
+	t.ExpiresOn.Time = time.Unix(claims.ExpiresAt, 0).UTC()
+	return t, nil
+}
+
+// enabledKey returns a key to sign tokens with or an error if there is none
+func (inter *TokenInteractorImpl) enabledKey() (*jwt.Key, error) {
+	key, err := inter.signingKey()
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, errs.NewUseCaseError(errs.ErrorTypeConflict, "Access tokens are not enabled", nil)
+	}
+	return key, nil
+}
+
+// signingKey returns a key to sign tokens with or nil if there is none
+func (inter *TokenInteractorImpl) signingKey() (*jwt.Key, error) {
+	if inter.Keys != nil {
+		key, err := inter.Keys.SigningKey()
+		if err != nil || key != nil {
+			return key, err
+		}
+	}
+	return inter.Key, nil
+}
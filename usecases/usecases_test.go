@@ -0,0 +1,117 @@
+package usecases_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+	"github.com/oleksandr/idp/jwt"
+	"github.com/oleksandr/idp/memory"
+	"github.com/oleksandr/idp/usecases"
+)
+
+//
+// fixture wires interactors to the repositories of an in-memory store
+//
+type fixture struct {
+	store    *memory.Store
+	domains  *usecases.DomainInteractorImpl
+	users    *usecases.UserInteractorImpl
+	sessions *usecases.SessionInteractorImpl
+	rbac     *usecases.RBACInteractorImpl
+	mfa      *usecases.MFAInteractorImpl
+	webauthn *usecases.WebAuthnInteractorImpl
+	lockout  *usecases.LockoutInteractorImpl
+	tokens   *usecases.TokenInteractorImpl
+	oauth    *usecases.OAuthInteractorImpl
+}
+
+func newFixture() *fixture {
+	s := memory.NewStore()
+	domains := &memory.DomainRepository{Store: s}
+	users := &memory.UserRepository{Store: s}
+	sessions := &memory.SessionRepository{Store: s}
+	roles := &memory.RoleRepository{Store: s}
+	passwords := &usecases.PasswordPolicyInteractorImpl{
+		Policies: &memory.PasswordPolicyRepository{Store: s},
+		Domains:  domains,
+		Users:    users,
+	}
+
+	f := &fixture{store: s}
+	f.domains = &usecases.DomainInteractorImpl{Domains: domains, Sessions: sessions}
+	f.users = &usecases.UserInteractorImpl{Users: users, Roles: roles, Sessions: sessions, Passwords: passwords}
+	f.rbac = &usecases.RBACInteractorImpl{Roles: roles, Permissions: &memory.PermissionRepository{Store: s}}
+	f.mfa = &usecases.MFAInteractorImpl{MFA: &memory.MFARepository{Store: s}, Users: users, Secret: []byte("secret")}
+	f.webauthn = &usecases.WebAuthnInteractorImpl{WebAuthn: &memory.WebAuthnRepository{Store: s}, Domains: domains, Users: users}
+	f.lockout = &usecases.LockoutInteractorImpl{
+		Lockout: &memory.LockoutRepository{Store: s},
+		Users:   users,
+		Thresholds: map[string]int{
+			entities.LockoutScopeUser:    5,
+			entities.LockoutScopeAddress: 20,
+		},
+		Duration: time.Minute,
+	}
+	f.mfa.Lockout = f.lockout
+	f.sessions = &usecases.SessionInteractorImpl{
+		Domains:   domains,
+		Users:     users,
+		Sessions:  sessions,
+		MFA:       f.mfa,
+		WebAuthn:  f.webauthn,
+		Lockout:   f.lockout,
+		Passwords: passwords,
+	}
+	f.tokens = &usecases.TokenInteractorImpl{RBAC: f.rbac, Key: jwt.NewHMACKey("", []byte("secret"))}
+	f.oauth = &usecases.OAuthInteractorImpl{
+		Clients:  &memory.ClientRepository{Store: s},
+		Domains:  domains,
+		Sessions: f.sessions,
+		Users:    f.users,
+		RBAC:     f.rbac,
+		Tokens:   f.tokens,
+	}
+	return f
+}
+
+// domain creates an enabled domain
+func (f *fixture) domain(t *testing.T, name string) *entities.BasicDomain {
+	d := entities.NewBasicDomain(name, "")
+	d.Enabled = true
+	must(t, f.domains.Create(*d))
+	return d
+}
+
+// user creates an enabled user with a given password in given domains
+func (f *fixture) user(t *testing.T, name, password string, domains ...*entities.BasicDomain) *entities.BasicUser {
+	u := entities.NewBasicUser(name)
+	u.Enabled = true
+	must(t, u.SetPassword(password))
+	var ids []string
+	for _, d := range domains {
+		ids = append(ids, d.ID)
+	}
+	must(t, f.users.Create(*u, password, ids))
+	return u
+}
+
+func must(t *testing.T, err error) {
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// errType returns a type of a given error or an empty string if there's none
+func errType(err error) errs.ErrorType {
+	if e, ok := err.(*errs.Error); ok {
+		return e.Type
+	}
+	return ""
+}
+
+var (
+	firstPage = entities.Pager{Page: 1, PerPage: 10}
+	byName    = entities.Sorter{Field: "name", Asc: true}
+)
@@ -0,0 +1,100 @@
+package usecases_test
+
+import (
+	"testing"
+
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+)
+
+func TestUserInteractor(t *testing.T) {
+	f := newFixture()
+	d1 := f.domain(t, "domain1.com")
+	d2 := f.domain(t, "domain2.com")
+	u := f.user(t, "john", "secret", d1)
+
+	if err := f.users.Create(*entities.NewBasicUser("jane"), "", nil); errType(err) != errs.ErrorTypeConflict {
+		t.Errorf("Create of a user without a password: %v", err)
+	}
+	if _, err := f.users.FindByNameInDomain("john", d2.ID); errType(err) != errs.ErrorTypeNotFound {
+		t.Errorf("FindByNameInDomain of a user of another domain: %v", err)
+	}
+
+	must(t, f.users.Update(*u, "", []string{d2.ID}, []string{d1.ID}))
+	if _, err := f.users.FindInDomain(u.ID, d2.ID); err != nil {
+		t.Errorf("FindInDomain of an added domain: %v", err)
+	}
+	if _, err := f.users.FindInDomain(u.ID, d1.ID); errType(err) != errs.ErrorTypeNotFound {
+		t.Errorf("FindInDomain of a removed domain: %v", err)
+	}
+
+	f.user(t, "jane", "secret", d2)
+	c, err := f.users.ListByDomain(d2.ID, firstPage, byName)
+	must(t, err)
+	if c.Paginator.Total != 2 || c.Users[0].Name != "jane" || c.Users[1].Name != "john" || c.Users[1].DomainsCount != 1 {
+		t.Errorf("ListByDomain = %+v", c)
+	}
+
+	_, err = f.sessions.Create(*d2, *u, "agent", "127.0.0.1")
+	must(t, err)
+	must(t, f.users.Delete(u.ID))
+	if _, err = f.users.Find(u.ID); errType(err) != errs.ErrorTypeNotFound {
+		t.Errorf("Find of a deleted user: %v", err)
+	}
+	sc, err := f.sessions.List(firstPage, entities.Sorter{})
+	must(t, err)
+	if len(sc.Sessions) != 0 {
+		t.Errorf("Sessions left after Delete: %+v", sc.Sessions)
+	}
+}
+
+func TestUserInteractorRoles(t *testing.T) {
+	f := newFixture()
+	d1 := f.domain(t, "domain1.com")
+	d2 := f.domain(t, "domain2.com")
+	u := f.user(t, "john", "secret", d1, d2)
+	for _, name := range []string{"admin", "clerk", "auditor"} {
+		r := entities.NewBasicRole(name, "")
+		r.Enabled = true
+		must(t, f.rbac.CreateRole(*r))
+	}
+	must(t, f.rbac.CreateSoDConstraint(entities.SoDConstraint{Name: "books", Roles: []string{"clerk", "auditor"}, Cardinality: 1}))
+
+	must(t, f.users.AssignRoles(u.ID, []string{"admin"}, d1.ID))
+	must(t, f.users.AssignRoles(u.ID, []string{"clerk"}, ""))
+	if err := f.users.AssignRoles(u.ID, []string{"clerk"}, ""); err == nil {
+		t.Error("AssignRoles of an assigned role succeeded")
+	}
+	if err := f.users.AssignRoles(u.ID, []string{"auditor"}, d2.ID); errType(err) != errs.ErrorTypeConflict {
+		t.Errorf("AssignRoles violating a constraint: %v", err)
+	}
+
+	s1, err := f.sessions.Create(*d1, *u, "agent", "127.0.0.1")
+	must(t, err)
+	s2, err := f.sessions.Create(*d2, *u, "agent", "127.0.0.1")
+	must(t, err)
+	for _, tc := range []struct {
+		session *entities.Session
+		role    string
+		want    bool
+	}{
+		{s1, "admin", true},
+		{s1, "clerk", true},
+		{s2, "admin", false},
+		{s2, "clerk", true},
+	} {
+		ok, err := f.rbac.AssertRole(*tc.session, tc.role)
+		must(t, err)
+		if ok != tc.want {
+			t.Errorf("AssertRole(%v, %v) = %v, want %v", tc.session.Domain.Name, tc.role, ok, tc.want)
+		}
+	}
+
+	must(t, f.users.RevokeRoles(u.ID, []string{"clerk"}, ""))
+	must(t, f.users.AssignRoles(u.ID, []string{"auditor"}, d2.ID))
+	c, err := f.rbac.ListRolesByUser(u.ID, firstPage, byName)
+	must(t, err)
+	if c.Paginator.Total != 2 || c.Roles[0].Name != "admin" || c.Roles[1].Name != "auditor" {
+		t.Errorf("ListRolesByUser = %+v", c)
+	}
+}
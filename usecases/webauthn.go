@@ -0,0 +1,326 @@
+package usecases
+
+import (
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/oleksandr/idp/config"
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+	"github.com/oleksandr/idp/webauthn"
+)
+
+const (
+	// defaultCredentialName names credentials registered without a name
+	defaultCredentialName = "Passkey"
+	// maxCredentialColumnLength is the maximal length of a credential's encoded ID
+	// and name as they're stored
+	maxCredentialColumnLength = 255
+)
+
+//
+// WebAuthnInteractor is an interface that defines all WebAuthn (passkey) related
+// use-cases signatures
+//
+type WebAuthnInteractor interface {
+	BeginRegistration(userID string) (*entities.WebAuthnChallenge, *webauthn.CreationOptions, error)
+	FinishRegistration(userID, challengeID, name string, res webauthn.AttestationResponse) (*entities.Credential, error)
+	ListCredentials(userID string) ([]entities.Credential, error)
+	DeleteCredential(userID, id string) error
+	BeginAssertion(domain entities.BasicDomain, user entities.BasicUser) (*entities.WebAuthnChallenge, *webauthn.RequestOptions, error)
+	FinishAssertion(challengeID string, res webauthn.AssertionResponse) (*entities.WebAuthnChallenge, error)
+	Purge() error
+}
+
+// WebAuthnInteractorImpl is an actual interactor that implements WebAuthnInteractor.
+// The relying party is RelyingParty if it's set, otherwise the configured origin
+// (IDP_WEBAUTHN_ORIGIN, the issuer by default) and credentials are scoped to its host
+// unless the relying party ID is configured (IDP_WEBAUTHN_RP_ID).
+type WebAuthnInteractorImpl struct {
+	WebAuthn     WebAuthnRepository
+	Domains      DomainRepository
+	Users        UserRepository
+	RelyingParty *webauthn.RelyingParty
+}
+
+// BeginRegistration creates a challenge a user completes by creating a credential
+// with the returned options
+func (inter *WebAuthnInteractorImpl) BeginRegistration(userID string) (*entities.WebAuthnChallenge, *webauthn.CreationOptions, error) {
+	rp, err := inter.relyingParty()
+	if err != nil {
+		return nil, nil, err
+	}
+	u, err := inter.Users.FindByID(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	exclude, err := inter.credentialIDs(u.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c, err := entities.NewWebAuthnChallenge(webauthn.CeremonyCreate, "", u.ID)
+	if err != nil {
+		return nil, nil, errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to create a challenge", err)
+	}
+	err = inter.WebAuthn.CreateChallenge(*c)
+	if err != nil {
+		return nil, nil, err
+	}
+	user := webauthn.User{
+		ID:          []byte(u.ID),
+		Name:        u.Name,
+		DisplayName: u.Name,
+	}
+	return c, rp.CreationOptions(c.Challenge, user, exclude, entities.WebAuthnChallengeTTL), nil
+}
+
+// FinishRegistration completes a challenge created by BeginRegistration with a new
+// credential of the user and stores it
+func (inter *WebAuthnInteractorImpl) FinishRegistration(userID, challengeID, name string, res webauthn.AttestationResponse) (*entities.Credential, error) {
+	rp, err := inter.relyingParty()
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		name = defaultCredentialName
+	}
+	if len(name) > maxCredentialColumnLength {
+		return nil, errs.NewUseCaseError(errs.ErrorTypeConflict, "Name is too long", nil)
+	}
+	c, err := inter.takeChallenge(challengeID, webauthn.CeremonyCreate)
+	if err != nil {
+		return nil, err
+	}
+	if c.UserID != userID {
+		return nil, errs.NewUseCaseError(errs.ErrorTypeNotFound, "Challenge not found", nil)
+	}
+
+	cred, err := rp.VerifyAttestation(c.Challenge, res)
+	if err != nil {
+		return nil, errs.NewUseCaseError(errs.ErrorTypeConflict, err.Error(), err)
+	}
+	id := webauthn.Encode(cred.ID)
+	if len(id) > maxCredentialColumnLength {
+		return nil, errs.NewUseCaseError(errs.ErrorTypeConflict, "Credential ID is too long", nil)
+	}
+	credential := entities.NewCredential(c.UserID, id, name, cred.PublicKey, cred.SignCount)
+	err = inter.WebAuthn.CreateCredential(*credential)
+	if err != nil {
+		return nil, err
+	}
+	return credential, nil
+}
+
+// ListCredentials lists all credentials of a user
+func (inter *WebAuthnInteractorImpl) ListCredentials(userID string) ([]entities.Credential, error) {
+	u, err := inter.Users.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	return inter.WebAuthn.ListCredentials(u.ID)
+}
+
+// DeleteCredential deletes a credential of a user, e.g. when the authenticator is lost
+func (inter *WebAuthnInteractorImpl) DeleteCredential(userID, id string) error {
+	return inter.WebAuthn.DeleteCredential(userID, id)
+}
+
+// BeginAssertion creates a challenge a user completes with one of their credentials
+// to open a session in a domain. If no user is given, any user of the domain may
+// sign in with a discoverable credential (passkey).
+func (inter *WebAuthnInteractorImpl) BeginAssertion(domain entities.BasicDomain, user entities.BasicUser) (*entities.WebAuthnChallenge, *webauthn.RequestOptions, error) {
+	rp, err := inter.relyingParty()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var d *entities.BasicDomain
+	if domain.ID != "" {
+		d, err = inter.Domains.FindByID(domain.ID)
+	} else if domain.Name != "" {
+		d, err = inter.Domains.FindByName(domain.Name)
+	} else {
+		err = errs.NewUseCaseError(errs.ErrorTypeConflict, "You need to provide domain ID or name", nil)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if !d.Enabled {
+		return nil, nil, errs.NewUseCaseError(errs.ErrorTypeForbidden, "Domain is disabled", nil)
+	}
+
+	// Only credentials of a given user are allowed
+	userID := ""
+	allow := [][]byte{}
+	if user.ID != "" || user.Name != "" {
+		var u *entities.BasicUser
+		if user.ID != "" {
+			u, err = inter.Users.FindByID(user.ID)
+		} else {
+			u, err = inter.Users.FindByName(user.Name)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		allow, err = inter.credentialIDs(u.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(allow) == 0 {
+			return nil, nil, errs.NewUseCaseError(errs.ErrorTypeConflict, "User has no credentials", nil)
+		}
+		userID = u.ID
+	}
+
+	c, err := entities.NewWebAuthnChallenge(webauthn.CeremonyGet, d.ID, userID)
+	if err != nil {
+		return nil, nil, errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to create a challenge", err)
+	}
+	err = inter.WebAuthn.CreateChallenge(*c)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c, rp.RequestOptions(c.Challenge, allow, entities.WebAuthnChallengeTTL), nil
+}
+
+// FinishAssertion completes a challenge created by BeginAssertion with an assertion
+// of a credential and returns the challenge along with the credential's user.
+// Invalid assertions are reported as an unauthorized error. A challenge can be
+// attempted only once.
+func (inter *WebAuthnInteractorImpl) FinishAssertion(challengeID string, res webauthn.AssertionResponse) (*entities.WebAuthnChallenge, error) {
+	rp, err := inter.relyingParty()
+	if err != nil {
+		return nil, err
+	}
+	c, err := inter.takeChallenge(challengeID, webauthn.CeremonyGet)
+	if err != nil {
+		return nil, err
+	}
+
+	invalid := errs.NewUseCaseError(errs.ErrorTypeUnauthorized, "Invalid credential", nil)
+	rawID, err := webauthn.Decode(res.ID)
+	if err != nil {
+		return nil, invalid
+	}
+	credential, err := inter.WebAuthn.FindCredential(webauthn.Encode(rawID))
+	if err != nil {
+		if e, ok := err.(*errs.Error); ok && e.Type == errs.ErrorTypeNotFound {
+			return nil, invalid
+		}
+		return nil, err
+	}
+	if c.UserID != "" && c.UserID != credential.UserID {
+		return nil, invalid
+	}
+	if res.Response.UserHandle != "" {
+		handle, err := webauthn.Decode(res.Response.UserHandle)
+		if err != nil || string(handle) != credential.UserID {
+			return nil, invalid
+		}
+	}
+
+	count, err := rp.VerifyAssertion(c.Challenge, res, credential.PublicKey)
+	if err != nil {
+		return nil, errs.NewUseCaseError(errs.ErrorTypeUnauthorized, "Invalid credential", err)
+	}
+	// Authenticators which don't count signatures always report 0, otherwise a
+	// counter which hasn't grown means that the credential has been cloned
+	if (count != 0 || credential.SignCount != 0) && count <= credential.SignCount {
+		return nil, errs.NewUseCaseError(errs.ErrorTypeForbidden, "Credential may have been cloned", nil)
+	}
+	// The counter is updated conditionally, so a concurrent assertion can't pass as well
+	err = inter.WebAuthn.UpdateSignCount(credential.ID, credential.SignCount, count, time.Now().UTC())
+	if err != nil {
+		if e, ok := err.(*errs.Error); ok && e.Type == errs.ErrorTypeConflict {
+			return nil, invalid
+		}
+		return nil, err
+	}
+
+	c.UserID = credential.UserID
+	return c, nil
+}
+
+// Purge purges all expired challenges
+func (inter *WebAuthnInteractorImpl) Purge() error {
+	return inter.WebAuthn.DeleteExpiredChallenges(time.Now().UTC())
+}
+
+// takeChallenge finds a challenge of a ceremony and deletes it, so it's completed
+// only once
+func (inter *WebAuthnInteractorImpl) takeChallenge(id, ceremony string) (*entities.WebAuthnChallenge, error) {
+	notFound := errs.NewUseCaseError(errs.ErrorTypeNotFound, "Challenge not found", nil)
+	c, err := inter.WebAuthn.FindChallenge(id)
+	if err != nil {
+		if e, ok := err.(*errs.Error); ok && e.Type == errs.ErrorTypeNotFound {
+			return nil, notFound
+		}
+		return nil, err
+	}
+	if c.Ceremony != ceremony {
+		return nil, notFound
+	}
+	err = inter.WebAuthn.DeleteChallenge(c.ID)
+	if err != nil {
+		if e, ok := err.(*errs.Error); ok && e.Type == errs.ErrorTypeNotFound {
+			return nil, notFound
+		}
+		return nil, err
+	}
+	if c.IsExpired() {
+		return nil, errs.NewUseCaseError(errs.ErrorTypeNotFound, "Challenge expired", nil)
+	}
+	return c, nil
+}
+
+// credentialIDs returns raw IDs of all credentials of a user
+func (inter *WebAuthnInteractorImpl) credentialIDs(userID string) ([][]byte, error) {
+	credentials, err := inter.WebAuthn.ListCredentials(userID)
+	if err != nil {
+		return nil, err
+	}
+	ids := [][]byte{}
+	for _, c := range credentials {
+		id, err := webauthn.Decode(c.ID)
+		if err != nil {
+			return nil, errs.NewUseCaseError(errs.ErrorTypeOperational, "Failed to decode a credential ID", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// relyingParty returns the relying party of the interactor or the configured one.
+// The configured origin defaults to the issuer and the ID to the origin's host.
+func (inter *WebAuthnInteractorImpl) relyingParty() (*webauthn.RelyingParty, error) {
+	if inter.RelyingParty != nil {
+		return inter.RelyingParty, nil
+	}
+	origin := config.WebAuthnOrigin()
+	if origin == "" {
+		origin = config.JWTIssuer()
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, errs.NewUseCaseError(errs.ErrorTypeConflict, "WebAuthn relying party is not configured", err)
+	}
+	host := u.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	id := config.WebAuthnRPID()
+	if id == "" {
+		id = host
+	}
+	if host != id && !strings.HasSuffix(host, "."+id) {
+		return nil, errs.NewUseCaseError(errs.ErrorTypeOperational, "WebAuthn relying party ID doesn't match the origin", nil)
+	}
+	return &webauthn.RelyingParty{
+		ID:     id,
+		Name:   id,
+		Origin: u.Scheme + "://" + u.Host,
+	}, nil
+}
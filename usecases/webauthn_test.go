@@ -0,0 +1,285 @@
+package usecases_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+	"github.com/oleksandr/idp/webauthn"
+	"golang.org/x/crypto/ed25519"
+)
+
+var testRelyingParty = &webauthn.RelyingParty{
+	ID:     "idp.example.com",
+	Name:   "idp.example.com",
+	Origin: "https://idp.example.com",
+}
+
+//
+// authenticator is a software WebAuthn authenticator with an ES256 or an Ed25519 key.
+// Its origin, relying party ID and flags can be changed to produce invalid responses.
+//
+type authenticator struct {
+	origin     string
+	rpID       string
+	flags      byte
+	signCount  uint32
+	id         []byte
+	userHandle []byte
+	ecKey      *ecdsa.PrivateKey
+	edKey      ed25519.PrivateKey
+}
+
+// Authenticator data flags: user present, user verified and attested credential data
+const (
+	flagUP = 0x01
+	flagUV = 0x04
+	flagAT = 0x40
+)
+
+func newAuthenticator(t *testing.T, alg int) *authenticator {
+	a := &authenticator{
+		origin: testRelyingParty.Origin,
+		rpID:   testRelyingParty.ID,
+		flags:  flagUP | flagUV,
+		id:     make([]byte, 16),
+	}
+	_, err := rand.Read(a.id)
+	must(t, err)
+	switch alg {
+	case webauthn.AlgorithmES256:
+		a.ecKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case webauthn.AlgorithmEdDSA:
+		_, a.edKey, err = ed25519.GenerateKey(rand.Reader)
+	}
+	must(t, err)
+	return a
+}
+
+// create creates the authenticator's credential for creation options
+func (a *authenticator) create(t *testing.T, options *webauthn.CreationOptions) webauthn.AttestationResponse {
+	handle, err := webauthn.Decode(options.User.ID)
+	must(t, err)
+	a.userHandle = handle
+
+	data := a.authenticatorData(a.flags | flagAT)
+	data = append(data, make([]byte, 16)...)
+	data = append(data, byte(len(a.id)>>8), byte(len(a.id)))
+	data = append(data, a.id...)
+	data = append(data, a.publicKey()...)
+	obj := cborMap{"fmt", "none", "attStmt", cborMap{}, "authData", data}
+
+	var res webauthn.AttestationResponse
+	res.ID = webauthn.Encode(a.id)
+	res.RawID = res.ID
+	res.Type = "public-key"
+	res.Response.ClientDataJSON = webauthn.Encode(a.clientData(t, webauthn.CeremonyCreate, options.Challenge))
+	res.Response.AttestationObject = webauthn.Encode(encodeCBOR(obj))
+	return res
+}
+
+// get signs an assertion for request options with the next signature counter
+func (a *authenticator) get(t *testing.T, options *webauthn.RequestOptions) webauthn.AssertionResponse {
+	a.signCount++
+	data := a.authenticatorData(a.flags)
+	cdata := a.clientData(t, webauthn.CeremonyGet, options.Challenge)
+	h := sha256.Sum256(cdata)
+	signed := append(append([]byte{}, data...), h[:]...)
+
+	var sig []byte
+	if a.ecKey != nil {
+		d := sha256.Sum256(signed)
+		var err error
+		sig, err = ecdsa.SignASN1(rand.Reader, a.ecKey, d[:])
+		must(t, err)
+	} else {
+		sig = ed25519.Sign(a.edKey, signed)
+	}
+
+	var res webauthn.AssertionResponse
+	res.ID = webauthn.Encode(a.id)
+	res.RawID = res.ID
+	res.Type = "public-key"
+	res.Response.ClientDataJSON = webauthn.Encode(cdata)
+	res.Response.AuthenticatorData = webauthn.Encode(data)
+	res.Response.Signature = webauthn.Encode(sig)
+	res.Response.UserHandle = webauthn.Encode(a.userHandle)
+	return res
+}
+
+func (a *authenticator) clientData(t *testing.T, ceremony, challenge string) []byte {
+	b, err := json.Marshal(map[string]interface{}{"type": ceremony, "challenge": challenge, "origin": a.origin})
+	must(t, err)
+	return b
+}
+
+func (a *authenticator) authenticatorData(flags byte) []byte {
+	h := sha256.Sum256([]byte(a.rpID))
+	data := append(h[:], flags, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(data[33:], a.signCount)
+	return data
+}
+
+// publicKey returns the COSE_Key of the authenticator's key
+func (a *authenticator) publicKey() []byte {
+	if a.ecKey != nil {
+		x, y := make([]byte, 32), make([]byte, 32)
+		a.ecKey.X.FillBytes(x)
+		a.ecKey.Y.FillBytes(y)
+		return encodeCBOR(cborMap{1, 2, 3, webauthn.AlgorithmES256, -1, 1, -2, x, -3, y})
+	}
+	return encodeCBOR(cborMap{1, 1, 3, webauthn.AlgorithmEdDSA, -1, 6, -2, []byte(a.edKey.Public().(ed25519.PublicKey))})
+}
+
+// cborMap is a CBOR map of alternating keys and values in their encoding order
+type cborMap []interface{}
+
+// encodeCBOR encodes integers, byte and text strings and maps of them
+func encodeCBOR(v interface{}) []byte {
+	head := func(major byte, n uint64) []byte {
+		switch {
+		case n < 24:
+			return []byte{major<<5 | byte(n)}
+		case n < 256:
+			return []byte{major<<5 | 24, byte(n)}
+		}
+		return []byte{major<<5 | 25, byte(n >> 8), byte(n)}
+	}
+	switch v := v.(type) {
+	case int:
+		if v < 0 {
+			return head(1, uint64(-1-v))
+		}
+		return head(0, uint64(v))
+	case []byte:
+		return append(head(2, uint64(len(v))), v...)
+	case string:
+		return append(head(3, uint64(len(v))), v...)
+	case cborMap:
+		b := head(5, uint64(len(v)/2))
+		for _, item := range v {
+			b = append(b, encodeCBOR(item)...)
+		}
+		return b
+	}
+	panic("unsupported CBOR value")
+}
+
+// register registers a credential of an authenticator for a user
+func register(t *testing.T, f *fixture, a *authenticator, u *entities.BasicUser) (*entities.Credential, error) {
+	c, options, err := f.webauthn.BeginRegistration(u.ID)
+	must(t, err)
+	return f.webauthn.FinishRegistration(u.ID, c.ID, "", a.create(t, options))
+}
+
+// signIn opens a session of a user in a domain with an authenticator
+func signIn(t *testing.T, f *fixture, a *authenticator, d *entities.BasicDomain, u *entities.BasicUser) (*entities.Session, error) {
+	c, options, err := f.webauthn.BeginAssertion(*d, *u)
+	must(t, err)
+	return f.sessions.CompleteWebAuthn(c.ID, a.get(t, options), "agent", "127.0.0.1")
+}
+
+func TestWebAuthnInteractor(t *testing.T) {
+	for _, alg := range []int{webauthn.AlgorithmES256, webauthn.AlgorithmEdDSA} {
+		f := newFixture()
+		f.webauthn.RelyingParty = testRelyingParty
+		d := f.domain(t, "domain1.com")
+		u := f.user(t, "john", "secret", d)
+		a := newAuthenticator(t, alg)
+
+		cred, err := register(t, f, a, u)
+		must(t, err)
+		if cred.ID != webauthn.Encode(a.id) || cred.UserID != u.ID || cred.Name != "Passkey" {
+			t.Errorf("FinishRegistration with algorithm %v = %+v", alg, cred)
+		}
+		if _, options, err := f.webauthn.BeginRegistration(u.ID); err != nil || len(options.ExcludeCredentials) != 1 {
+			t.Errorf("BeginRegistration of a registered user = %+v, %v", options, err)
+		}
+
+		s, err := signIn(t, f, a, d, u)
+		if err != nil || s.User.ID != u.ID || s.Domain.ID != d.ID {
+			t.Errorf("CompleteWebAuthn with algorithm %v = %+v, %v", alg, s, err)
+		}
+		s, err = signIn(t, f, a, d, &entities.BasicUser{})
+		if err != nil || s.User.ID != u.ID {
+			t.Errorf("CompleteWebAuthn with a discoverable credential = %+v, %v", s, err)
+		}
+		credentials, err := f.webauthn.ListCredentials(u.ID)
+		must(t, err)
+		if len(credentials) != 1 || credentials[0].SignCount != 2 {
+			t.Errorf("ListCredentials = %+v", credentials)
+		}
+	}
+}
+
+func TestWebAuthnInteractorRejects(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		mutate func(a *authenticator)
+	}{
+		{"wrong origin", func(a *authenticator) { a.origin = "https://evil.example.com" }},
+		{"wrong relying party ID", func(a *authenticator) { a.rpID = "evil.example.com" }},
+		{"missing user verification", func(a *authenticator) { a.flags = flagUP }},
+	} {
+		f := newFixture()
+		f.webauthn.RelyingParty = testRelyingParty
+		d := f.domain(t, "domain1.com")
+		u := f.user(t, "john", "secret", d)
+
+		a := newAuthenticator(t, webauthn.AlgorithmES256)
+		tc.mutate(a)
+		if _, err := register(t, f, a, u); errType(err) != errs.ErrorTypeConflict {
+			t.Errorf("FinishRegistration with %v: %v", tc.name, err)
+		}
+
+		a = newAuthenticator(t, webauthn.AlgorithmES256)
+		_, err := register(t, f, a, u)
+		must(t, err)
+		tc.mutate(a)
+		if _, err = signIn(t, f, a, d, u); errType(err) != errs.ErrorTypeUnauthorized {
+			t.Errorf("CompleteWebAuthn with %v: %v", tc.name, err)
+		}
+	}
+}
+
+func TestWebAuthnInteractorReplay(t *testing.T) {
+	f := newFixture()
+	f.webauthn.RelyingParty = testRelyingParty
+	d := f.domain(t, "domain1.com")
+	u := f.user(t, "john", "secret", d)
+	a := newAuthenticator(t, webauthn.AlgorithmEdDSA)
+	_, err := register(t, f, a, u)
+	must(t, err)
+
+	c, options, err := f.webauthn.BeginAssertion(*d, *u)
+	must(t, err)
+	res := a.get(t, options)
+	_, err = f.sessions.CompleteWebAuthn(c.ID, res, "agent", "127.0.0.1")
+	must(t, err)
+	if _, err = f.sessions.CompleteWebAuthn(c.ID, res, "agent", "127.0.0.1"); errType(err) != errs.ErrorTypeNotFound {
+		t.Errorf("CompleteWebAuthn of a completed challenge: %v", err)
+	}
+	c, _, err = f.webauthn.BeginAssertion(*d, *u)
+	must(t, err)
+	if _, err = f.sessions.CompleteWebAuthn(c.ID, res, "agent", "127.0.0.1"); errType(err) != errs.ErrorTypeUnauthorized {
+		t.Errorf("CompleteWebAuthn with an assertion of another challenge: %v", err)
+	}
+
+	a.signCount--
+	if _, err = signIn(t, f, a, d, u); errType(err) != errs.ErrorTypeForbidden {
+		t.Errorf("CompleteWebAuthn with a signature counter which hasn't grown: %v", err)
+	}
+	a.signCount = 0
+	if _, err = signIn(t, f, a, d, u); errType(err) != errs.ErrorTypeForbidden {
+		t.Errorf("CompleteWebAuthn with a signature counter which has dropped: %v", err)
+	}
+	if _, err = signIn(t, f, a, d, u); err != nil {
+		t.Errorf("CompleteWebAuthn with a signature counter which has grown: %v", err)
+	}
+}
@@ -2,12 +2,14 @@ package web
 
 import (
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"strings"
 
 	"github.com/gorilla/context"
+	"github.com/julienschmidt/httprouter"
 	"github.com/oleksandr/idp/config"
 	"github.com/oleksandr/idp/entities"
 	"github.com/oleksandr/idp/usecases"
@@ -72,6 +74,45 @@ func NewAuthenticationHandler(interactor usecases.SessionInteractor) func(next h
 	}
 }
 
+// NewAuthorizationHandler create a new handler that lets the request through
+// only if the current session's user has a given permission. It must be placed
+// after the authentication handler in the chain.
+func NewAuthorizationHandler(interactor usecases.RBACInteractor, permission string) func(next http.Handler) http.Handler {
+	logger := log.New(os.Stdout, "[auth] ", log.LstdFlags)
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			s, ok := context.Get(r, config.CtxSessionKey).(entities.Session)
+			if !ok {
+				respondWithError(w, http.StatusUnauthorized, "Unauthorized", errors.New("Session not found"))
+				return
+			}
+
+			// Route parameters are exposed to evaluation rules as attributes,
+			// e.g. "attrs.id == user.id" for /users/:id
+			attrs := map[string]string{}
+			if params, found := context.Get(r, config.CtxParamsKey).(httprouter.Params); found {
+				for _, p := range params {
+					attrs[p.Key] = p.Value
+				}
+			}
+
+			ok, err := interactor.AssertPermission(s, permission, attrs)
+			if err != nil {
+				logger.Println(err.Error())
+				respondWithError(w, http.StatusInternalServerError, "Could not assert permission", err)
+				return
+			}
+			if !ok {
+				respondWithError(w, http.StatusForbidden, "Forbidden", fmt.Errorf("Permission %v is required", permission))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
 func tokenFromXHeader(r *http.Request) (string, error) {
 	token := strings.TrimSpace(r.Header.Get("X-Auth-Token"))
 	if token == "" {
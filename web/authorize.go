@@ -0,0 +1,244 @@
+package web
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+	"github.com/oleksandr/idp/usecases"
+)
+
+// authorizeParams are the parameters of an authorization request carried over from
+// the login page to its submission
+var authorizeParams = []string{
+	"response_type", "client_id", "redirect_uri", "scope", "state", "nonce", "code_challenge", "code_challenge_method",
+}
+
+// loginPage is a minimal login page of the authorization endpoint
+var loginPage = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Sign in</title>
+<style>
+body { font-family: sans-serif; max-width: 20em; margin: 4em auto; padding: 0 1em; }
+input { display: block; width: 100%; margin: 0.5em 0 1em; box-sizing: border-box; }
+.error { color: #b00; }
+</style>
+</head>
+<body>
+{{if .Client}}<h1>Sign in to {{.Client}}</h1>{{else}}<h1>Sign in</h1>{{end}}
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+{{if .Form}}<form method="post" action="{{.Action}}">
+{{range $name, $value := .Params}}<input type="hidden" name="{{$name}}" value="{{$value}}">
+{{end}}{{if .Code}}<label>Authentication code<input type="text" name="code" autocomplete="off" required autofocus></label>
+<input type="submit" value="Verify">
+{{else}}<label>User name<input type="text" name="username" value="{{.UserName}}" autocomplete="username" required autofocus></label>
+<label>Password<input type="password" name="password" autocomplete="current-password" required></label>
+<input type="submit" value="Sign in">
+{{end}}</form>{{end}}
+</body>
+</html>
+`))
+
+// mfaChallengeParam carries the ID of a challenge from the login page asking for an
+// authentication code to its submission
+const mfaChallengeParam = "mfa_challenge"
+
+// loginPageData is rendered by loginPage. The page asks for an authentication code
+// instead of the credentials if Code is set.
+type loginPageData struct {
+	Client   string
+	Error    string
+	Form     bool
+	Code     bool
+	Action   string
+	Params   map[string]string
+	UserName string
+}
+
+// Authorize shows the login page of the authorization endpoint (RFC 6749 section
+// 3.1) if the authorization request is valid
+func (handler *OAuthWebHandler) Authorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		renderLoginPage(w, handler.log, http.StatusBadRequest, loginPageData{Error: "Failed to decode request data"})
+		return
+	}
+	req := authorizationRequestFromForm(r.Form)
+	client, redirectURI, err := handler.OAuthInteractor.ValidateAuthorization(req)
+	if err != nil {
+		handler.respondToAuthorization(w, r, redirectURI, req.State, err)
+		return
+	}
+	renderLoginPage(w, handler.log, http.StatusOK, loginPageData{
+		Client: client.Name,
+		Form:   true,
+		Action: r.URL.Path,
+		Params: authorizeParamsFromForm(r.Form),
+	})
+}
+
+// Login logs a user in with the credentials submitted from the login page and
+// redirects back to the client with an authorization code. The login page is shown
+// again if the credentials are invalid, or asks for an authentication code if the
+// user has to provide one.
+func (handler *OAuthWebHandler) Login(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		renderLoginPage(w, handler.log, http.StatusBadRequest, loginPageData{Error: "Failed to decode request data"})
+		return
+	}
+	req := authorizationRequestFromForm(r.PostForm)
+	client, redirectURI, err := handler.OAuthInteractor.ValidateAuthorization(req)
+	if err != nil {
+		handler.respondToAuthorization(w, r, redirectURI, req.State, err)
+		return
+	}
+
+	data := loginPageData{
+		Client:   client.Name,
+		Form:     true,
+		Action:   r.URL.Path,
+		Params:   authorizeParamsFromForm(r.PostForm),
+		UserName: r.PostForm.Get("username"),
+	}
+	if challengeID := r.PostForm.Get(mfaChallengeParam); challengeID != "" {
+		handler.loginWithCode(w, r, *client, req, redirectURI, challengeID, data)
+		return
+	}
+	password := r.PostForm.Get("password")
+	if data.UserName == "" || password == "" {
+		data.Error = "User name and password are required"
+		renderLoginPage(w, handler.log, http.StatusOK, data)
+		return
+	}
+	code, challenge, err := handler.OAuthInteractor.Authorize(*client, req, data.UserName, password, r.UserAgent(), remoteAddrFromRequest(r))
+	if e, ok := err.(*usecases.OAuthError); ok && e.Code == usecases.OAuthAccessDenied {
+		data.Error = "Invalid user name or password"
+		renderLoginPage(w, handler.log, http.StatusOK, data)
+		return
+	}
+	if e, ok := err.(*errs.Error); ok && (e.RetryAfter > 0 || e.Type == errs.ErrorTypePasswordExpired) {
+		data.Error = e.Msg
+		renderLoginPage(w, handler.log, http.StatusOK, data)
+		return
+	}
+	if err != nil {
+		handler.respondToAuthorization(w, r, redirectURI, req.State, err)
+		return
+	}
+	if challenge != nil {
+		data.Code = true
+		data.Params[mfaChallengeParam] = challenge.ID
+		renderLoginPage(w, handler.log, http.StatusOK, data)
+		return
+	}
+
+	handler.redirect(w, r, redirectURI, url.Values{"code": {code}}, req.State)
+}
+
+// loginWithCode completes a challenge with the authentication code submitted from
+// the login page. The page asks for another code if the code is invalid, or for the
+// credentials again if the challenge can't be completed anymore.
+func (handler *OAuthWebHandler) loginWithCode(w http.ResponseWriter, r *http.Request, client entities.Client, req entities.AuthorizationRequest, redirectURI, challengeID string, data loginPageData) {
+	code, err := handler.OAuthInteractor.AuthorizeMFA(client, req, challengeID, r.PostForm.Get("code"), r.UserAgent(), remoteAddrFromRequest(r))
+	if e, ok := err.(*errs.Error); ok && e.Type != errs.ErrorTypeOperational {
+		data.Error = e.Msg
+		if e.Type == errs.ErrorTypeUnauthorized {
+			data.Code = true
+			data.Params[mfaChallengeParam] = challengeID
+		}
+		renderLoginPage(w, handler.log, http.StatusOK, data)
+		return
+	}
+	if err != nil {
+		handler.respondToAuthorization(w, r, redirectURI, req.State, err)
+		return
+	}
+
+	handler.redirect(w, r, redirectURI, url.Values{"code": {code}}, req.State)
+}
+
+// respondToAuthorization reports an error of an authorization request. OAuth errors
+// are sent back to the client by redirecting to the redirect URI unless it's unknown,
+// in which case they are shown to the user (RFC 6749 section 4.1.2.1).
+func (handler *OAuthWebHandler) respondToAuthorization(w http.ResponseWriter, r *http.Request, redirectURI, state string, err error) {
+	e, ok := err.(*usecases.OAuthError)
+	if !ok {
+		handler.log.Println(err.Error())
+		status := http.StatusInternalServerError
+		if e, ok := err.(*errs.Error); ok {
+			status = errorToHTTPStatus(e)
+		}
+		renderLoginPage(w, handler.log, status, loginPageData{Error: "Authorization failed, please try again later"})
+		return
+	}
+	if redirectURI == "" {
+		renderLoginPage(w, handler.log, http.StatusBadRequest, loginPageData{Error: e.Description})
+		return
+	}
+	handler.redirect(w, r, redirectURI, url.Values{"error": {e.Code}, "error_description": {e.Description}}, state)
+}
+
+// redirect redirects to a client's redirect URI with given parameters and the state
+// of the authorization request added to its query
+func (handler *OAuthWebHandler) redirect(w http.ResponseWriter, r *http.Request, redirectURI string, params url.Values, state string) {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		handler.log.Println(err.Error())
+		renderLoginPage(w, handler.log, http.StatusInternalServerError, loginPageData{Error: "Invalid redirect URI"})
+		return
+	}
+	q := u.Query()
+	for k, v := range params {
+		q[k] = v
+	}
+	if state != "" {
+		q.Set("state", state)
+	}
+	u.RawQuery = q.Encode()
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+// renderLoginPage renders the login page. It may not be framed by other sites and
+// must not be cached.
+func renderLoginPage(w http.ResponseWriter, logger *log.Logger, statusCode int, data loginPageData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; frame-ancestors 'none'")
+	w.WriteHeader(statusCode)
+	if err := loginPage.Execute(w, data); err != nil {
+		logger.Println(err.Error())
+	}
+}
+
+// authorizationRequestFromForm reads an authorization request from query or form values
+func authorizationRequestFromForm(values url.Values) entities.AuthorizationRequest {
+	return entities.AuthorizationRequest{
+		ResponseType:        values.Get("response_type"),
+		ClientID:            values.Get("client_id"),
+		RedirectURI:         values.Get("redirect_uri"),
+		Scopes:              entities.ParseScope(values.Get("scope")),
+		State:               values.Get("state"),
+		Nonce:               values.Get("nonce"),
+		CodeChallenge:       values.Get("code_challenge"),
+		CodeChallengeMethod: values.Get("code_challenge_method"),
+	}
+}
+
+// authorizeParamsFromForm picks the authorization request parameters which are set
+func authorizeParamsFromForm(values url.Values) map[string]string {
+	params := map[string]string{}
+	for _, name := range authorizeParams {
+		if v := values.Get(name); v != "" {
+			params[name] = v
+		}
+	}
+	return params
+}
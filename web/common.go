@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"os"
 	"runtime/debug"
+	"strconv"
 	"strings"
 	"time"
 
@@ -30,6 +31,15 @@ func respondWithError(w http.ResponseWriter, statusCode int, title string, err e
 	d.Title = title
 	if e, ok := err.(*errs.Error); ok {
 		d.Message = e.Msg
+		if e.RetryAfter > 0 {
+			// Whole seconds, rounded up
+			seconds := int((e.RetryAfter + time.Second - 1) / time.Second)
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+			d.Details = map[string]interface{}{"retry_after": seconds}
+		}
+		if e.Type == errs.ErrorTypePasswordExpired {
+			d.Details = map[string]interface{}{"password_expired": true}
+		}
 	} else {
 		d.Message = err.Error()
 	}
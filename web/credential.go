@@ -0,0 +1,162 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/gorilla/context"
+	"github.com/julienschmidt/httprouter"
+	"github.com/oleksandr/idp/config"
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+	"github.com/oleksandr/idp/usecases"
+	"github.com/oleksandr/idp/webauthn"
+)
+
+// CredentialForm used for parsing a credential created by an authenticator along
+// with the password (and the code) of the current user
+type CredentialForm struct {
+	Credential struct {
+		ChallengeID         string                       `json:"challenge_id"`
+		Name                string                       `json:"name"`
+		Password            string                       `json:"password"`
+		Code                string                       `json:"code"`
+		PublicKeyCredential webauthn.AttestationResponse `json:"public_key_credential"`
+	} `json:"credential"`
+}
+
+// CredentialResource used for responses
+type CredentialResource struct {
+	Credential entities.Credential `json:"credential"`
+}
+
+// CredentialsResource used for responses listing credentials of a current user
+type CredentialsResource struct {
+	Credentials []entities.Credential `json:"credentials"`
+}
+
+//
+// CredentialWebHandler is a collection of methods for registering WebAuthn
+// credentials of the current user (/users/current/credentials)
+//
+type CredentialWebHandler struct {
+	log                *log.Logger
+	SessionInteractor  usecases.SessionInteractor
+	WebAuthnInteractor usecases.WebAuthnInteractor
+}
+
+// NewCredentialWebHandler creates new CredentialWebHandler
+func NewCredentialWebHandler() *CredentialWebHandler {
+	return &CredentialWebHandler{
+		log: log.New(os.Stdout, "[CredentialHandler] ", log.LstdFlags),
+	}
+}
+
+// Options creates a challenge to register a new credential of the current user
+func (handler *CredentialWebHandler) Options(w http.ResponseWriter, r *http.Request) {
+	s, ok := currentUserSession(r)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	challenge, options, err := handler.WebAuthnInteractor.BeginRegistration(s.User.ID)
+	if err != nil {
+		e := err.(*errs.Error)
+		handler.log.Println(e.Error())
+		respondWithError(w, errorToHTTPStatus(e), "Failed to create challenge", e)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(WebAuthnChallengeResource{WebAuthnChallenge: *challenge, PublicKey: options})
+}
+
+// Create completes a challenge created by Options with a credential created by an
+// authenticator and registers it. The current user has to sign in once again with
+// the password (and the code), so a stolen session can't add a credential.
+func (handler *CredentialWebHandler) Create(w http.ResponseWriter, r *http.Request) {
+	s, ok := currentUserSession(r)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	var form CredentialForm
+	err := json.NewDecoder(r.Body).Decode(&form)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Failed to decode request data", err)
+		return
+	}
+	if form.Credential.ChallengeID == "" {
+		respondWithError(w, http.StatusBadRequest, "Failed to register credential", errors.New("Challenge ID is required"))
+		return
+	}
+
+	err = handler.SessionInteractor.Reauthenticate(s, form.Credential.Password, form.Credential.Code, remoteAddrFromRequest(r))
+	if err != nil {
+		e := err.(*errs.Error)
+		handler.log.Println(e.Error())
+		respondWithError(w, errorToHTTPStatus(e), "Failed to register credential", e)
+		return
+	}
+
+	c, err := handler.WebAuthnInteractor.FinishRegistration(s.User.ID, form.Credential.ChallengeID, form.Credential.Name, form.Credential.PublicKeyCredential)
+	if err != nil {
+		e := err.(*errs.Error)
+		handler.log.Println(e.Error())
+		respondWithError(w, errorToHTTPStatus(e), "Failed to register credential", e)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(CredentialResource{Credential: *c})
+}
+
+// List returns all credentials of the current user
+func (handler *CredentialWebHandler) List(w http.ResponseWriter, r *http.Request) {
+	s, ok := currentUserSession(r)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	credentials, err := handler.WebAuthnInteractor.ListCredentials(s.User.ID)
+	if err != nil {
+		e := err.(*errs.Error)
+		handler.log.Println(e.Error())
+		respondWithError(w, errorToHTTPStatus(e), "Failed to list credentials", e)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(CredentialsResource{Credentials: credentials})
+}
+
+// Delete deletes a credential of the current user
+func (handler *CredentialWebHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	params := context.Get(r, config.CtxParamsKey).(httprouter.Params)
+	s, ok := currentUserSession(r)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	err := handler.WebAuthnInteractor.DeleteCredential(s.User.ID, params.ByName("credential"))
+	if err != nil {
+		e := err.(*errs.Error)
+		handler.log.Println(e.Error())
+		respondWithError(w, errorToHTTPStatus(e), "Failed to delete credential", e)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// currentUserSession returns the current session if a request's path refers to the
+// current user. httprouter doesn't allow "current" next to the ":id" parameter of
+// other users' routes, so it's checked here.
+func currentUserSession(r *http.Request) (entities.Session, bool) {
+	params := context.Get(r, config.CtxParamsKey).(httprouter.Params)
+	s, ok := context.Get(r, config.CtxSessionKey).(entities.Session)
+	return s, ok && params.ByName("id") == "current"
+}
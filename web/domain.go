@@ -1,35 +1,206 @@
 package web
 
 import (
+	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
+	"os"
 
+	"github.com/gorilla/context"
+	"github.com/julienschmidt/httprouter"
+	"github.com/oleksandr/idp/config"
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
 	"github.com/oleksandr/idp/usecases"
 )
 
+// domainSortFields maps sortable API fields to domain table columns
+var domainSortFields = map[string]string{
+	"name":       "name",
+	"enabled":    "is_enabled",
+	"created_on": "created_on",
+	"updated_on": "updated_on",
+}
+
+// DomainForm used for parsing incoming data. Pointers are used to tell
+// omitted attributes from empty ones when modifying a domain.
+type DomainForm struct {
+	Domain struct {
+		Name        *string `json:"name"`
+		Description *string `json:"description"`
+		Enabled     *bool   `json:"enabled"`
+		MFARequired *bool   `json:"mfa_required"`
+	} `json:"domain"`
+}
+
+// DomainResource used for responses
+type DomainResource struct {
+	Domain entities.BasicDomain `json:"domain"`
+}
+
 //
-// DomainWebHandler is a collection of CRUD methods for Users API
+// DomainWebHandler is a collection of CRUD methods for Domains API
 //
 type DomainWebHandler struct {
+	log              *log.Logger
 	DomainInteractor usecases.DomainInteractor
 }
 
+// NewDomainWebHandler creates new DomainWebHandler
+func NewDomainWebHandler() *DomainWebHandler {
+	return &DomainWebHandler{
+		log: log.New(os.Stdout, "[DomainHandler] ", log.LstdFlags),
+	}
+}
+
+// Create creates a new domain
 func (handler *DomainWebHandler) Create(w http.ResponseWriter, r *http.Request) {
-	//w.WriteHeader(http.StatusCreated)
-	w.WriteHeader(http.StatusNotImplemented)
+	var form DomainForm
+	err := json.NewDecoder(r.Body).Decode(&form)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Failed to decode request data", err)
+		return
+	}
+	if form.Domain.Name == nil {
+		respondWithError(w, http.StatusBadRequest, "Failed to create domain", errors.New("Name is required"))
+		return
+	}
+
+	d := entities.NewBasicDomain(*form.Domain.Name, "")
+	if form.Domain.Description != nil {
+		d.Description = *form.Domain.Description
+	}
+	if form.Domain.Enabled != nil {
+		d.Enabled = *form.Domain.Enabled
+	}
+	if form.Domain.MFARequired != nil {
+		d.MFARequired = *form.Domain.MFARequired
+	}
+
+	err = handler.DomainInteractor.Create(*d)
+	if err != nil {
+		e := err.(*errs.Error)
+		handler.log.Println(e.Error())
+		respondWithError(w, errorToHTTPStatus(e), "Failed to create domain", e)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(DomainResource{Domain: *d})
 }
 
+// Retrieve returns a domain by ID
 func (handler *DomainWebHandler) Retrieve(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusNotImplemented)
+	params := context.Get(r, config.CtxParamsKey).(httprouter.Params)
+
+	d, err := handler.DomainInteractor.Find(params.ByName("id"))
+	if err != nil {
+		e := err.(*errs.Error)
+		respondWithError(w, errorToHTTPStatus(e), "Failed to retrieve domain", e)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(DomainResource{Domain: *d})
 }
 
+// List returns a paginated collection of domains
 func (handler *DomainWebHandler) List(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusNotImplemented)
+	pager := pagerFromRequest(r)
+	sorter := sorterFromRequest(r, domainSortFields, entities.Sorter{Field: "name", Asc: true})
+
+	collection, err := handler.DomainInteractor.List(pager, sorter)
+	if err != nil {
+		e := err.(*errs.Error)
+		handler.log.Println(e.Error())
+		respondWithError(w, errorToHTTPStatus(e), "Failed to list domains", e)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(collection)
 }
 
-func (handler *DomainWebHandler) Modify(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusNotImplemented)
+// ListByUser returns a paginated collection of domains a given user belongs to
+func (handler *DomainWebHandler) ListByUser(w http.ResponseWriter, r *http.Request) {
+	params := context.Get(r, config.CtxParamsKey).(httprouter.Params)
+	pager := pagerFromRequest(r)
+	sorter := sorterFromRequest(r, domainSortFields, entities.Sorter{Field: "name", Asc: true})
+
+	collection, err := handler.DomainInteractor.ListByUser(params.ByName("id"), pager, sorter)
+	if err != nil {
+		e := err.(*errs.Error)
+		handler.log.Println(e.Error())
+		respondWithError(w, errorToHTTPStatus(e), "Failed to list domains", e)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(collection)
 }
 
+// Update modifies attributes of an existing domain
+func (handler *DomainWebHandler) Update(w http.ResponseWriter, r *http.Request) {
+	params := context.Get(r, config.CtxParamsKey).(httprouter.Params)
+
+	var form DomainForm
+	err := json.NewDecoder(r.Body).Decode(&form)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Failed to decode request data", err)
+		return
+	}
+
+	d, err := handler.DomainInteractor.Find(params.ByName("id"))
+	if err != nil {
+		e := err.(*errs.Error)
+		respondWithError(w, errorToHTTPStatus(e), "Failed to update domain", e)
+		return
+	}
+
+	if form.Domain.Name != nil {
+		d.Name = *form.Domain.Name
+	}
+	if form.Domain.Description != nil {
+		d.Description = *form.Domain.Description
+	}
+	if form.Domain.Enabled != nil {
+		d.Enabled = *form.Domain.Enabled
+	}
+	if form.Domain.MFARequired != nil {
+		d.MFARequired = *form.Domain.MFARequired
+	}
+
+	err = handler.DomainInteractor.Update(*d)
+	if err != nil {
+		e := err.(*errs.Error)
+		handler.log.Println(e.Error())
+		respondWithError(w, errorToHTTPStatus(e), "Failed to update domain", e)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(DomainResource{Domain: *d})
+}
+
+// Delete removes an existing domain
 func (handler *DomainWebHandler) Delete(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusNotImplemented)
+	params := context.Get(r, config.CtxParamsKey).(httprouter.Params)
+
+	d, err := handler.DomainInteractor.Find(params.ByName("id"))
+	if err != nil {
+		e := err.(*errs.Error)
+		respondWithError(w, errorToHTTPStatus(e), "Failed to delete domain", e)
+		return
+	}
+
+	err = handler.DomainInteractor.Delete(d.ID)
+	if err != nil {
+		e := err.(*errs.Error)
+		handler.log.Println(e.Error())
+		respondWithError(w, errorToHTTPStatus(e), "Failed to delete domain", e)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
 }
@@ -1,32 +1,127 @@
 package web
 
 import (
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
 
+	"github.com/oleksandr/idp/config"
+	"github.com/oleksandr/idp/entities"
 	"github.com/oleksandr/idp/errs"
 )
 
-// RemoteAddrFromRequest returns remote address of the requesting client
+const (
+	defaultPerPage = 20
+	maxPerPage     = 100
+)
+
+// RemoteAddrFromRequest returns remote address of the requesting client. X-Real-IP
+// and X-Forwarded-For are only honoured in requests of trusted proxies
+// (IDP_TRUSTED_PROXIES), otherwise any client could pose as another address.
 func remoteAddrFromRequest(r *http.Request) string {
-	remoteAddr := r.Header.Get("X-Real-IP")
-	if remoteAddr == "" {
-		remoteAddr = r.Header.Get("X-Forwarded-For")
-		if remoteAddr == "" {
-			s := strings.SplitN(r.RemoteAddr, "]:", 2)
-			if len(s) == 2 {
-				return strings.TrimLeft(s[0], "[")
-			}
-			remoteAddr = strings.Split(r.RemoteAddr, ":")[0]
+	remoteAddr := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		remoteAddr = host
+	}
+	if !isTrustedProxy(remoteAddr) {
+		return remoteAddr
+	}
+	if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); ip != "" {
+		return ip
+	}
+	// Every proxy appends the address it's been connected from, so the client is the
+	// last address which isn't a trusted proxy
+	forwarded := strings.Split(strings.Join(r.Header["X-Forwarded-For"], ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		ip := strings.TrimSpace(forwarded[i])
+		if ip == "" {
+			continue
+		}
+		remoteAddr = ip
+		if !isTrustedProxy(ip) {
+			break
 		}
 	}
 	return remoteAddr
 }
 
+// isTrustedProxy tells if an address belongs to a trusted proxy
+func isTrustedProxy(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, n := range config.TrustedProxies() {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSecureRequest tells if a request has been made with HTTPS. X-Forwarded-Proto is
+// only honoured in requests of trusted proxies (IDP_TRUSTED_PROXIES).
+func isSecureRequest(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+	peer := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(peer); err == nil {
+		peer = host
+	}
+	return isTrustedProxy(peer) && r.Header.Get("X-Forwarded-Proto") == "https"
+}
+
+// pagerFromRequest reads "page" and "per_page" query parameters
+func pagerFromRequest(r *http.Request) entities.Pager {
+	pager := entities.Pager{Page: 1, PerPage: defaultPerPage}
+	q := r.URL.Query()
+	if page, err := strconv.Atoi(q.Get("page")); err == nil && page > 0 {
+		pager.Page = page
+	}
+	if perPage, err := strconv.Atoi(q.Get("per_page")); err == nil && perPage > 0 {
+		pager.PerPage = perPage
+	}
+	if pager.PerPage > maxPerPage {
+		pager.PerPage = maxPerPage
+	}
+	return pager
+}
+
+// attributesFromQuery returns query parameters as attributes for evaluation
+// rules. Only the first value of a repeated parameter is used.
+func attributesFromQuery(r *http.Request) map[string]string {
+	attrs := map[string]string{}
+	for k, v := range r.URL.Query() {
+		if len(v) > 0 {
+			attrs[k] = v[0]
+		}
+	}
+	return attrs
+}
+
+// sorterFromRequest reads "sort" and "order" query parameters. Only the fields
+// listed in a given map (API name -> column name) are accepted, otherwise the
+// default sorter is returned.
+func sorterFromRequest(r *http.Request, fields map[string]string, def entities.Sorter) entities.Sorter {
+	q := r.URL.Query()
+	column, ok := fields[q.Get("sort")]
+	if !ok {
+		return def
+	}
+	return entities.Sorter{
+		Field: column,
+		Asc:   strings.ToLower(q.Get("order")) != "desc",
+	}
+}
+
 func errorToHTTPStatus(err *errs.Error) int {
 	switch err.Type {
-	case errs.ErrorTypeForbidden:
+	case errs.ErrorTypeForbidden, errs.ErrorTypePasswordExpired:
 		return http.StatusForbidden
+	case errs.ErrorTypeUnauthorized:
+		return http.StatusUnauthorized
 	case errs.ErrorTypeConflict:
 		return http.StatusBadRequest
 	case errs.ErrorTypeNotFound:
@@ -0,0 +1,45 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/oleksandr/idp/errs"
+	"github.com/oleksandr/idp/usecases"
+)
+
+// jwksMaxAge is how long (in seconds) clients may cache the published keys. A newly
+// generated key has to be published at least this long before it's activated.
+const jwksMaxAge = 300
+
+//
+// KeyWebHandler publishes public keys access tokens are signed with
+//
+type KeyWebHandler struct {
+	log           *log.Logger
+	KeyInteractor usecases.KeyInteractor
+}
+
+// NewKeyWebHandler creates new KeyWebHandler
+func NewKeyWebHandler() *KeyWebHandler {
+	return &KeyWebHandler{
+		log: log.New(os.Stdout, "[KeyHandler] ", log.LstdFlags),
+	}
+}
+
+// JWKS handles a read request of the published keys as a JWK set
+func (handler *KeyWebHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	set, err := handler.KeyInteractor.PublicKeys()
+	if err != nil {
+		e := err.(*errs.Error)
+		handler.log.Println(e.Error())
+		respondWithError(w, errorToHTTPStatus(e), "Failed to retrieve keys", e)
+		return
+	}
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%v", jwksMaxAge))
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(set)
+}
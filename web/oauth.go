@@ -0,0 +1,209 @@
+package web
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+	"github.com/oleksandr/idp/usecases"
+)
+
+// OAuthTokenResource used for token responses (RFC 6749 section 5.1)
+type OAuthTokenResource struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// OAuthErrorResource used for error responses (RFC 6749 section 5.2)
+type OAuthErrorResource struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+//
+// OAuthWebHandler implements the OAuth 2.0 authorization, token, revocation and
+// introspection endpoints. Requests are form encoded and clients authenticate with
+// HTTP Basic authentication or with client_id and client_secret parameters. Public
+// clients send client_id only.
+//
+type OAuthWebHandler struct {
+	log             *log.Logger
+	OAuthInteractor usecases.OAuthInteractor
+}
+
+// NewOAuthWebHandler creates new OAuthWebHandler
+func NewOAuthWebHandler() *OAuthWebHandler {
+	return &OAuthWebHandler{
+		log: log.New(os.Stdout, "[OAuthHandler] ", log.LstdFlags),
+	}
+}
+
+// Token issues tokens by the authorization_code, client_credentials, password and
+// refresh_token grants
+func (handler *OAuthWebHandler) Token(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+
+	client, ok := handler.authenticate(w, r)
+	if !ok {
+		return
+	}
+	scopes := entities.ParseScope(r.PostForm.Get("scope"))
+
+	var (
+		grant *entities.TokenGrant
+		err   error
+	)
+	switch r.PostForm.Get("grant_type") {
+	case entities.GrantTypeAuthorizationCode:
+		code, verifier := r.PostForm.Get("code"), r.PostForm.Get("code_verifier")
+		if code == "" || verifier == "" {
+			handler.respondWithError(w, &usecases.OAuthError{Code: usecases.OAuthInvalidRequest, Description: "Code and code verifier are required"})
+			return
+		}
+		grant, err = handler.OAuthInteractor.ExchangeCode(*client, code, r.PostForm.Get("redirect_uri"), verifier)
+	case entities.GrantTypeClientCredentials:
+		grant, err = handler.OAuthInteractor.GrantClientCredentials(*client, scopes)
+	case entities.GrantTypePassword:
+		userName, password := r.PostForm.Get("username"), r.PostForm.Get("password")
+		if userName == "" || password == "" {
+			handler.respondWithError(w, &usecases.OAuthError{Code: usecases.OAuthInvalidRequest, Description: "Username and password are required"})
+			return
+		}
+		grant, err = handler.OAuthInteractor.GrantPassword(*client, userName, password, scopes, r.UserAgent(), remoteAddrFromRequest(r))
+	case entities.GrantTypeRefreshToken:
+		refreshToken := r.PostForm.Get("refresh_token")
+		if refreshToken == "" {
+			handler.respondWithError(w, &usecases.OAuthError{Code: usecases.OAuthInvalidRequest, Description: "Refresh token is required"})
+			return
+		}
+		grant, err = handler.OAuthInteractor.Refresh(*client, refreshToken, scopes)
+	case "":
+		err = &usecases.OAuthError{Code: usecases.OAuthInvalidRequest, Description: "Grant type is required"}
+	default:
+		err = &usecases.OAuthError{Code: usecases.OAuthUnsupportedGrantType, Description: "Grant type is not supported"}
+	}
+	if err != nil {
+		handler.respondWithError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(OAuthTokenResource{
+		AccessToken:  grant.AccessToken.Token,
+		TokenType:    grant.AccessToken.Type,
+		ExpiresIn:    int64(grant.AccessToken.ExpiresOn.Sub(time.Now()).Seconds()),
+		RefreshToken: grant.RefreshToken,
+		IDToken:      grant.IDToken,
+		Scope:        entities.FormatScope(grant.Scopes),
+	})
+}
+
+// Revoke revokes a token (RFC 7009)
+func (handler *OAuthWebHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	client, ok := handler.authenticate(w, r)
+	if !ok {
+		return
+	}
+	token := r.PostForm.Get("token")
+	if token == "" {
+		handler.respondWithError(w, &usecases.OAuthError{Code: usecases.OAuthInvalidRequest, Description: "Token is required"})
+		return
+	}
+	err := handler.OAuthInteractor.Revoke(*client, token)
+	if err != nil {
+		handler.respondWithError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// Introspect describes a token (RFC 7662)
+func (handler *OAuthWebHandler) Introspect(w http.ResponseWriter, r *http.Request) {
+	client, ok := handler.authenticate(w, r)
+	if !ok {
+		return
+	}
+	token := r.PostForm.Get("token")
+	if token == "" {
+		handler.respondWithError(w, &usecases.OAuthError{Code: usecases.OAuthInvalidRequest, Description: "Token is required"})
+		return
+	}
+	info, err := handler.OAuthInteractor.Introspect(*client, token)
+	if err != nil {
+		handler.respondWithError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(info)
+}
+
+// authenticate parses a request's form and authenticates the client. An error
+// response is written if it fails.
+func (handler *OAuthWebHandler) authenticate(w http.ResponseWriter, r *http.Request) (*entities.Client, bool) {
+	if err := r.ParseForm(); err != nil {
+		handler.respondWithError(w, &usecases.OAuthError{Code: usecases.OAuthInvalidRequest, Description: "Failed to decode request data"})
+		return nil, false
+	}
+
+	// Credentials of HTTP Basic authentication are form encoded (RFC 6749 section 2.3.1)
+	id, secret, ok := r.BasicAuth()
+	if ok {
+		var err1, err2 error
+		id, err1 = url.QueryUnescape(id)
+		secret, err2 = url.QueryUnescape(secret)
+		ok = err1 == nil && err2 == nil
+	} else {
+		id, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+		ok = id != ""
+	}
+	if !ok {
+		handler.respondWithError(w, &usecases.OAuthError{Code: usecases.OAuthInvalidClient, Description: "Client authentication is required"})
+		return nil, false
+	}
+
+	client, err := handler.OAuthInteractor.Authenticate(id, secret)
+	if err != nil {
+		handler.respondWithError(w, err)
+		return nil, false
+	}
+	return client, true
+}
+
+// respondWithError writes an OAuth error response. Internal errors are logged and
+// reported as server errors.
+func (handler *OAuthWebHandler) respondWithError(w http.ResponseWriter, err error) {
+	res := OAuthErrorResource{}
+	status := http.StatusBadRequest
+	switch e := err.(type) {
+	case *usecases.OAuthError:
+		res.Error, res.Description = e.Code, e.Description
+		if e.Code == usecases.OAuthInvalidClient {
+			w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+			status = http.StatusUnauthorized
+		}
+	case *errs.Error:
+		handler.log.Println(e.Error())
+		status = errorToHTTPStatus(e)
+		if status == http.StatusInternalServerError {
+			res.Error = "server_error"
+		} else {
+			res.Error, res.Description = usecases.OAuthInvalidRequest, e.Msg
+		}
+	default:
+		handler.log.Println(err.Error())
+		status = http.StatusInternalServerError
+		res.Error = "server_error"
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,165 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/oleksandr/idp/config"
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+	"github.com/oleksandr/idp/usecases"
+)
+
+// OpenIDConfigurationResource used for OpenID Provider metadata (OpenID Connect
+// Discovery section 3)
+type OpenIDConfigurationResource struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	RevocationEndpoint                string   `json:"revocation_endpoint"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+//
+// OpenIDWebHandler implements the OpenID Connect discovery and userinfo endpoints.
+// OpenID Connect requires the issuer (IDP_JWT_ISSUER) to be set to the IdP's public
+// URL, which endpoint URLs are derived from.
+//
+type OpenIDWebHandler struct {
+	log             *log.Logger
+	OAuthInteractor usecases.OAuthInteractor
+	KeyInteractor   usecases.KeyInteractor
+}
+
+// NewOpenIDWebHandler creates new OpenIDWebHandler
+func NewOpenIDWebHandler() *OpenIDWebHandler {
+	return &OpenIDWebHandler{
+		log: log.New(os.Stdout, "[OpenIDHandler] ", log.LstdFlags),
+	}
+}
+
+// Configuration handles a read request of the OpenID Provider metadata
+func (handler *OpenIDWebHandler) Configuration(w http.ResponseWriter, r *http.Request) {
+	issuer := config.JWTIssuer()
+	if issuer == "" {
+		respondWithError(w, http.StatusNotFound, "OpenID Connect is not enabled",
+			errors.New("Issuer is not configured"))
+		return
+	}
+	set, err := handler.KeyInteractor.PublicKeys()
+	if err != nil {
+		e := err.(*errs.Error)
+		handler.log.Println(e.Error())
+		respondWithError(w, errorToHTTPStatus(e), "Failed to retrieve keys", e)
+		return
+	}
+	algorithms := []string{}
+	seen := map[string]bool{}
+	for _, k := range set.Keys {
+		if !seen[k.Algorithm] {
+			seen[k.Algorithm] = true
+			algorithms = append(algorithms, k.Algorithm)
+		}
+	}
+
+	base := strings.TrimSuffix(issuer, "/")
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%v", jwksMaxAge))
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(OpenIDConfigurationResource{
+		Issuer:                 issuer,
+		AuthorizationEndpoint:  base + "/oauth/authorize",
+		TokenEndpoint:          base + "/oauth/token",
+		UserInfoEndpoint:       base + "/userinfo",
+		JWKSURI:                base + "/.well-known/jwks.json",
+		RevocationEndpoint:     base + "/oauth/revoke",
+		IntrospectionEndpoint:  base + "/oauth/introspect",
+		ScopesSupported:        []string{entities.ScopeOpenID, entities.ScopeProfile, entities.ScopeEmail},
+		ResponseTypesSupported: []string{"code"},
+		GrantTypesSupported: []string{
+			entities.GrantTypeAuthorizationCode,
+			entities.GrantTypeRefreshToken,
+			entities.GrantTypeClientCredentials,
+			entities.GrantTypePassword,
+		},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  algorithms,
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{entities.CodeChallengeS256},
+		ClaimsSupported: []string{
+			"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce", "sid",
+			"name", "preferred_username", "email", "email_verified", "domain", "domain_id",
+		},
+	})
+}
+
+// UserInfo handles a read request of claims about the user of a bearer access token
+func (handler *OpenIDWebHandler) UserInfo(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+
+	token := bearerToken(r)
+	if token == "" {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="userinfo"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(OAuthErrorResource{Error: usecases.OAuthInvalidRequest, Description: "Access token is required"})
+		return
+	}
+	info, err := handler.OAuthInteractor.UserInfo(token)
+	if err != nil {
+		handler.respondWithError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(info)
+}
+
+// respondWithError writes an error response of a protected resource (RFC 6750
+// section 3). Internal errors are logged and reported as server errors.
+func (handler *OpenIDWebHandler) respondWithError(w http.ResponseWriter, err error) {
+	res := OAuthErrorResource{Error: "server_error"}
+	status := http.StatusInternalServerError
+	switch e := err.(type) {
+	case *usecases.OAuthError:
+		res.Error, res.Description = e.Code, e.Description
+		status = http.StatusUnauthorized
+		if e.Code == usecases.OAuthInsufficientScope {
+			status = http.StatusForbidden
+		}
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="userinfo", error=%q, error_description=%q`, e.Code, e.Description))
+	case *errs.Error:
+		handler.log.Println(e.Error())
+		status = errorToHTTPStatus(e)
+	default:
+		handler.log.Println(err.Error())
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(res)
+}
+
+// bearerToken returns a bearer token of the Authorization header or of the
+// access_token parameter of a form encoded body (RFC 6750 section 2)
+func bearerToken(r *http.Request) string {
+	s := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+	if len(s) == 2 && strings.EqualFold(s[0], "Bearer") {
+		return strings.TrimSpace(s[1])
+	}
+	if r.Method == "POST" && r.ParseForm() == nil {
+		return r.PostForm.Get("access_token")
+	}
+	return ""
+}
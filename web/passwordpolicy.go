@@ -0,0 +1,121 @@
+package web
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/gorilla/context"
+	"github.com/julienschmidt/httprouter"
+	"github.com/oleksandr/idp/config"
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+	"github.com/oleksandr/idp/usecases"
+)
+
+// PasswordPolicyForm used for parsing incoming data. The whole policy is replaced,
+// omitted rules are turned off.
+type PasswordPolicyForm struct {
+	PasswordPolicy struct {
+		MinLength     int  `json:"min_length"`
+		RequireLower  bool `json:"require_lower"`
+		RequireUpper  bool `json:"require_upper"`
+		RequireDigit  bool `json:"require_digit"`
+		RequireSymbol bool `json:"require_symbol"`
+		Blocklist     bool `json:"blocklist"`
+		History       int  `json:"history"`
+		MaxAgeDays    int  `json:"max_age_days"`
+	} `json:"password_policy"`
+}
+
+// PasswordPolicyResource used for responses
+type PasswordPolicyResource struct {
+	PasswordPolicy entities.PasswordPolicy `json:"password_policy"`
+}
+
+//
+// PasswordPolicyWebHandler is a collection of methods for password policies of
+// domains (/domains/:id/password-policy)
+//
+type PasswordPolicyWebHandler struct {
+	log                      *log.Logger
+	PasswordPolicyInteractor usecases.PasswordPolicyInteractor
+}
+
+// NewPasswordPolicyWebHandler creates new PasswordPolicyWebHandler
+func NewPasswordPolicyWebHandler() *PasswordPolicyWebHandler {
+	return &PasswordPolicyWebHandler{
+		log: log.New(os.Stdout, "[PasswordPolicyHandler] ", log.LstdFlags),
+	}
+}
+
+// Retrieve returns a password policy of a domain
+func (handler *PasswordPolicyWebHandler) Retrieve(w http.ResponseWriter, r *http.Request) {
+	params := context.Get(r, config.CtxParamsKey).(httprouter.Params)
+
+	p, err := handler.PasswordPolicyInteractor.Find(params.ByName("id"))
+	if err != nil {
+		e := err.(*errs.Error)
+		respondWithError(w, errorToHTTPStatus(e), "Failed to retrieve password policy", e)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(PasswordPolicyResource{PasswordPolicy: *p})
+}
+
+// Save sets a password policy of a domain replacing the existing one
+func (handler *PasswordPolicyWebHandler) Save(w http.ResponseWriter, r *http.Request) {
+	params := context.Get(r, config.CtxParamsKey).(httprouter.Params)
+
+	var form PasswordPolicyForm
+	err := json.NewDecoder(r.Body).Decode(&form)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Failed to decode request data", err)
+		return
+	}
+
+	err = handler.PasswordPolicyInteractor.Save(entities.PasswordPolicy{
+		DomainID:      params.ByName("id"),
+		MinLength:     form.PasswordPolicy.MinLength,
+		RequireLower:  form.PasswordPolicy.RequireLower,
+		RequireUpper:  form.PasswordPolicy.RequireUpper,
+		RequireDigit:  form.PasswordPolicy.RequireDigit,
+		RequireSymbol: form.PasswordPolicy.RequireSymbol,
+		Blocklist:     form.PasswordPolicy.Blocklist,
+		History:       form.PasswordPolicy.History,
+		MaxAgeDays:    form.PasswordPolicy.MaxAgeDays,
+	})
+	if err != nil {
+		e := err.(*errs.Error)
+		handler.log.Println(e.Error())
+		respondWithError(w, errorToHTTPStatus(e), "Failed to save password policy", e)
+		return
+	}
+
+	p, err := handler.PasswordPolicyInteractor.Find(params.ByName("id"))
+	if err != nil {
+		e := err.(*errs.Error)
+		handler.log.Println(e.Error())
+		respondWithError(w, errorToHTTPStatus(e), "Failed to retrieve password policy", e)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(PasswordPolicyResource{PasswordPolicy: *p})
+}
+
+// Delete removes a password policy of a domain
+func (handler *PasswordPolicyWebHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	params := context.Get(r, config.CtxParamsKey).(httprouter.Params)
+
+	err := handler.PasswordPolicyInteractor.Delete(params.ByName("id"))
+	if err != nil {
+		e := err.(*errs.Error)
+		handler.log.Println(e.Error())
+		respondWithError(w, errorToHTTPStatus(e), "Failed to delete password policy", e)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
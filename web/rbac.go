@@ -1,6 +1,8 @@
 package web
 
 import (
+	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"os"
@@ -13,6 +15,59 @@ import (
 	"github.com/oleksandr/idp/usecases"
 )
 
+// roleSortFields maps sortable API fields to role table columns
+var roleSortFields = map[string]string{
+	"name":    "name",
+	"enabled": "is_enabled",
+}
+
+// permissionSortFields maps sortable API fields to permission table columns
+var permissionSortFields = map[string]string{
+	"name":    "name",
+	"enabled": "is_enabled",
+}
+
+// RoleForm used for parsing incoming data. Pointers are used to tell
+// omitted attributes from empty ones when modifying a role.
+type RoleForm struct {
+	Role struct {
+		Name        *string `json:"name"`
+		Description *string `json:"description"`
+		Enabled     *bool   `json:"enabled"`
+	} `json:"role"`
+}
+
+// RoleResource used for responses
+type RoleResource struct {
+	Role entities.BasicRole `json:"role"`
+}
+
+// PermissionForm used for parsing incoming data. Pointers are used to tell
+// omitted attributes from empty ones when modifying a permission.
+type PermissionForm struct {
+	Permission struct {
+		Name           *string `json:"name"`
+		Description    *string `json:"description"`
+		EvaluationRule *string `json:"evaluation_rule"`
+		Enabled        *bool   `json:"enabled"`
+	} `json:"permission"`
+}
+
+// PermissionResource used for responses
+type PermissionResource struct {
+	Permission entities.BasicPermission `json:"permission"`
+}
+
+// EffectiveRolesResource used for responses listing roles of a current session
+type EffectiveRolesResource struct {
+	Roles []entities.BasicRole `json:"roles"`
+}
+
+// EffectivePermissionsResource used for responses listing permissions of a current session
+type EffectivePermissionsResource struct {
+	Permissions []entities.BasicPermission `json:"permissions"`
+}
+
 //
 // RBACWebHandler is a collection of various methods for RBAC
 //
@@ -45,7 +100,7 @@ func (handler *RBACWebHandler) AssertRole(w http.ResponseWriter, r *http.Request
 	role := params.ByName("role")
 	handler.log.Printf("AssertRole(%v, %v)", s.User.ID, role)
 
-	ok, err = handler.RBACInteractor.AssertRole(s.User.ID, role)
+	ok, err = handler.RBACInteractor.AssertRole(s, role)
 	if err != nil {
 		handler.log.Println("ERROR:", err.Error())
 		respondWithError(w, errorToHTTPStatus(err.(*errs.Error)), "Failed to assert role", err)
@@ -75,9 +130,10 @@ func (handler *RBACWebHandler) AssertPermission(w http.ResponseWriter, r *http.R
 
 	params := context.Get(r, config.CtxParamsKey).(httprouter.Params)
 	permission := params.ByName("permission")
-	handler.log.Printf("AssertPermission(%v, %v)", s.User.ID, permission)
+	attrs := attributesFromQuery(r)
+	handler.log.Printf("AssertPermission(%v, %v, %v)", s.User.ID, permission, attrs)
 
-	ok, err = handler.RBACInteractor.AssertPermission(s.User.ID, permission)
+	ok, err = handler.RBACInteractor.AssertPermission(s, permission, attrs)
 	if err != nil {
 		handler.log.Println("ERROR:", err.Error())
 		respondWithError(w, errorToHTTPStatus(err.(*errs.Error)), "Failed to assert permission", err)
@@ -91,3 +147,366 @@ func (handler *RBACWebHandler) AssertPermission(w http.ResponseWriter, r *http.R
 
 	w.WriteHeader(http.StatusNotFound)
 }
+
+// ListEffectiveRoles returns all roles a current user holds in the session's domain,
+// including inherited ones
+func (handler *RBACWebHandler) ListEffectiveRoles(w http.ResponseWriter, r *http.Request) {
+	s, ok := context.Get(r, config.CtxSessionKey).(entities.Session)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	roles, err := handler.RBACInteractor.ListEffectiveRoles(s)
+	if err != nil {
+		e := err.(*errs.Error)
+		handler.log.Println(e.Error())
+		respondWithError(w, errorToHTTPStatus(e), "Failed to list effective roles", e)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(EffectiveRolesResource{Roles: roles})
+}
+
+// ListEffectivePermissions returns all permissions granted to a current user in the session's
+// domain, so clients don't need to assert permissions one by one
+func (handler *RBACWebHandler) ListEffectivePermissions(w http.ResponseWriter, r *http.Request) {
+	s, ok := context.Get(r, config.CtxSessionKey).(entities.Session)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	permissions, err := handler.RBACInteractor.ListEffectivePermissions(s)
+	if err != nil {
+		e := err.(*errs.Error)
+		handler.log.Println(e.Error())
+		respondWithError(w, errorToHTTPStatus(e), "Failed to list effective permissions", e)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(EffectivePermissionsResource{Permissions: permissions})
+}
+
+// CreateRole creates a new role
+func (handler *RBACWebHandler) CreateRole(w http.ResponseWriter, r *http.Request) {
+	var form RoleForm
+	err := json.NewDecoder(r.Body).Decode(&form)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Failed to decode request data", err)
+		return
+	}
+	if form.Role.Name == nil {
+		respondWithError(w, http.StatusBadRequest, "Failed to create role", errors.New("Name is required"))
+		return
+	}
+
+	role := entities.NewBasicRole(*form.Role.Name, "")
+	if form.Role.Description != nil {
+		role.Description = *form.Role.Description
+	}
+	if form.Role.Enabled != nil {
+		role.Enabled = *form.Role.Enabled
+	}
+
+	err = handler.RBACInteractor.CreateRole(*role)
+	if err != nil {
+		e := err.(*errs.Error)
+		handler.log.Println(e.Error())
+		respondWithError(w, errorToHTTPStatus(e), "Failed to create role", e)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(RoleResource{Role: *role})
+}
+
+// RetrieveRole returns a role by name
+func (handler *RBACWebHandler) RetrieveRole(w http.ResponseWriter, r *http.Request) {
+	params := context.Get(r, config.CtxParamsKey).(httprouter.Params)
+
+	role, err := handler.RBACInteractor.FindRole(params.ByName("name"))
+	if err != nil {
+		e := err.(*errs.Error)
+		respondWithError(w, errorToHTTPStatus(e), "Failed to retrieve role", e)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(RoleResource{Role: *role})
+}
+
+// ListRoles returns a paginated collection of roles
+func (handler *RBACWebHandler) ListRoles(w http.ResponseWriter, r *http.Request) {
+	pager := pagerFromRequest(r)
+	sorter := sorterFromRequest(r, roleSortFields, entities.Sorter{Field: "name", Asc: true})
+
+	collection, err := handler.RBACInteractor.ListRoles(pager, sorter)
+	if err != nil {
+		e := err.(*errs.Error)
+		handler.log.Println(e.Error())
+		respondWithError(w, errorToHTTPStatus(e), "Failed to list roles", e)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(collection)
+}
+
+// ListRolesByUser returns a paginated collection of roles assigned to a given user
+func (handler *RBACWebHandler) ListRolesByUser(w http.ResponseWriter, r *http.Request) {
+	params := context.Get(r, config.CtxParamsKey).(httprouter.Params)
+	pager := pagerFromRequest(r)
+	sorter := sorterFromRequest(r, roleSortFields, entities.Sorter{Field: "name", Asc: true})
+
+	collection, err := handler.RBACInteractor.ListRolesByUser(params.ByName("id"), pager, sorter)
+	if err != nil {
+		e := err.(*errs.Error)
+		handler.log.Println(e.Error())
+		respondWithError(w, errorToHTTPStatus(e), "Failed to list roles", e)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(collection)
+}
+
+// UpdateRole modifies attributes of an existing role and renames it if required
+func (handler *RBACWebHandler) UpdateRole(w http.ResponseWriter, r *http.Request) {
+	params := context.Get(r, config.CtxParamsKey).(httprouter.Params)
+
+	var form RoleForm
+	err := json.NewDecoder(r.Body).Decode(&form)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Failed to decode request data", err)
+		return
+	}
+
+	role, err := handler.RBACInteractor.FindRole(params.ByName("name"))
+	if err != nil {
+		e := err.(*errs.Error)
+		respondWithError(w, errorToHTTPStatus(e), "Failed to update role", e)
+		return
+	}
+
+	if form.Role.Description != nil {
+		role.Description = *form.Role.Description
+	}
+	if form.Role.Enabled != nil {
+		role.Enabled = *form.Role.Enabled
+	}
+
+	err = handler.RBACInteractor.UpdateRole(*role)
+	if err == nil && form.Role.Name != nil && *form.Role.Name != role.Name {
+		err = handler.RBACInteractor.RenameRole(role.Name, *form.Role.Name)
+		role.Name = *form.Role.Name
+	}
+	if err != nil {
+		e := err.(*errs.Error)
+		handler.log.Println(e.Error())
+		respondWithError(w, errorToHTTPStatus(e), "Failed to update role", e)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(RoleResource{Role: *role})
+}
+
+// DeleteRole removes an existing role
+func (handler *RBACWebHandler) DeleteRole(w http.ResponseWriter, r *http.Request) {
+	params := context.Get(r, config.CtxParamsKey).(httprouter.Params)
+
+	role, err := handler.RBACInteractor.FindRole(params.ByName("name"))
+	if err != nil {
+		e := err.(*errs.Error)
+		respondWithError(w, errorToHTTPStatus(e), "Failed to delete role", e)
+		return
+	}
+
+	err = handler.RBACInteractor.DeleteRole(role.Name)
+	if err != nil {
+		e := err.(*errs.Error)
+		handler.log.Println(e.Error())
+		respondWithError(w, errorToHTTPStatus(e), "Failed to delete role", e)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ListRolePermissions returns a paginated collection of permissions of a given role
+func (handler *RBACWebHandler) ListRolePermissions(w http.ResponseWriter, r *http.Request) {
+	params := context.Get(r, config.CtxParamsKey).(httprouter.Params)
+	pager := pagerFromRequest(r)
+	sorter := sorterFromRequest(r, permissionSortFields, entities.Sorter{Field: "name", Asc: true})
+
+	collection, err := handler.RBACInteractor.ListPermissionsByRole(params.ByName("name"), pager, sorter)
+	if err != nil {
+		e := err.(*errs.Error)
+		handler.log.Println(e.Error())
+		respondWithError(w, errorToHTTPStatus(e), "Failed to list permissions", e)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(collection)
+}
+
+// AddRolePermission adds a permission to an existing role
+func (handler *RBACWebHandler) AddRolePermission(w http.ResponseWriter, r *http.Request) {
+	params := context.Get(r, config.CtxParamsKey).(httprouter.Params)
+
+	err := handler.RBACInteractor.UpdateRoleWithPermissions(params.ByName("name"), []string{params.ByName("permission")})
+	if err != nil {
+		e := err.(*errs.Error)
+		handler.log.Println(e.Error())
+		respondWithError(w, errorToHTTPStatus(e), "Failed to add permission to role", e)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// RemoveRolePermission removes a permission from an existing role
+func (handler *RBACWebHandler) RemoveRolePermission(w http.ResponseWriter, r *http.Request) {
+	params := context.Get(r, config.CtxParamsKey).(httprouter.Params)
+
+	err := handler.RBACInteractor.RemovePermissionsFromRole([]string{params.ByName("permission")}, params.ByName("name"))
+	if err != nil {
+		e := err.(*errs.Error)
+		handler.log.Println(e.Error())
+		respondWithError(w, errorToHTTPStatus(e), "Failed to remove permission from role", e)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// CreatePermission creates a new permission
+func (handler *RBACWebHandler) CreatePermission(w http.ResponseWriter, r *http.Request) {
+	var form PermissionForm
+	err := json.NewDecoder(r.Body).Decode(&form)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Failed to decode request data", err)
+		return
+	}
+	if form.Permission.Name == nil {
+		respondWithError(w, http.StatusBadRequest, "Failed to create permission", errors.New("Name is required"))
+		return
+	}
+
+	p := entities.NewBasicPermission(*form.Permission.Name, "")
+	if form.Permission.Description != nil {
+		p.Description = *form.Permission.Description
+	}
+	if form.Permission.EvaluationRule != nil {
+		p.EvaluationRule = *form.Permission.EvaluationRule
+	}
+	if form.Permission.Enabled != nil {
+		p.Enabled = *form.Permission.Enabled
+	}
+
+	err = handler.RBACInteractor.CreatePermission(*p)
+	if err != nil {
+		e := err.(*errs.Error)
+		handler.log.Println(e.Error())
+		respondWithError(w, errorToHTTPStatus(e), "Failed to create permission", e)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(PermissionResource{Permission: *p})
+}
+
+// RetrievePermission returns a permission by name
+func (handler *RBACWebHandler) RetrievePermission(w http.ResponseWriter, r *http.Request) {
+	params := context.Get(r, config.CtxParamsKey).(httprouter.Params)
+
+	p, err := handler.RBACInteractor.FindPermission(params.ByName("name"))
+	if err != nil {
+		e := err.(*errs.Error)
+		respondWithError(w, errorToHTTPStatus(e), "Failed to retrieve permission", e)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(PermissionResource{Permission: *p})
+}
+
+// ListPermissions returns a paginated collection of permissions
+func (handler *RBACWebHandler) ListPermissions(w http.ResponseWriter, r *http.Request) {
+	pager := pagerFromRequest(r)
+	sorter := sorterFromRequest(r, permissionSortFields, entities.Sorter{Field: "name", Asc: true})
+
+	collection, err := handler.RBACInteractor.ListPermissions(pager, sorter)
+	if err != nil {
+		e := err.(*errs.Error)
+		handler.log.Println(e.Error())
+		respondWithError(w, errorToHTTPStatus(e), "Failed to list permissions", e)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(collection)
+}
+
+// UpdatePermission modifies attributes of an existing permission and renames it if required
+func (handler *RBACWebHandler) UpdatePermission(w http.ResponseWriter, r *http.Request) {
+	params := context.Get(r, config.CtxParamsKey).(httprouter.Params)
+
+	var form PermissionForm
+	err := json.NewDecoder(r.Body).Decode(&form)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Failed to decode request data", err)
+		return
+	}
+
+	p, err := handler.RBACInteractor.FindPermission(params.ByName("name"))
+	if err != nil {
+		e := err.(*errs.Error)
+		respondWithError(w, errorToHTTPStatus(e), "Failed to update permission", e)
+		return
+	}
+
+	if form.Permission.Description != nil {
+		p.Description = *form.Permission.Description
+	}
+	if form.Permission.EvaluationRule != nil {
+		p.EvaluationRule = *form.Permission.EvaluationRule
+	}
+	if form.Permission.Enabled != nil {
+		p.Enabled = *form.Permission.Enabled
+	}
+
+	err = handler.RBACInteractor.UpdatePermission(*p)
+	if err == nil && form.Permission.Name != nil && *form.Permission.Name != p.Name {
+		err = handler.RBACInteractor.RenamePermission(p.Name, *form.Permission.Name)
+		p.Name = *form.Permission.Name
+	}
+	if err != nil {
+		e := err.(*errs.Error)
+		handler.log.Println(e.Error())
+		respondWithError(w, errorToHTTPStatus(e), "Failed to update permission", e)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(PermissionResource{Permission: *p})
+}
+
+// DeletePermission removes an existing permission
+func (handler *RBACWebHandler) DeletePermission(w http.ResponseWriter, r *http.Request) {
+	params := context.Get(r, config.CtxParamsKey).(httprouter.Params)
+
+	err := handler.RBACInteractor.DeletePermission(params.ByName("name"))
+	if err != nil {
+		e := err.(*errs.Error)
+		handler.log.Println(e.Error())
+		respondWithError(w, errorToHTTPStatus(e), "Failed to delete permission", e)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
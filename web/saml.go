@@ -0,0 +1,317 @@
+package web
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/oleksandr/idp/config"
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
+	"github.com/oleksandr/idp/saml"
+	"github.com/oleksandr/idp/usecases"
+)
+
+const (
+	// samlSessionCookie keeps the reference of a session a user has signed in with, so
+	// the user signs in to further service providers of the domain without a password.
+	// The reference can't be used as a bearer token, unlike the session's ID.
+	samlSessionCookie = "idp_session"
+	// samlLoginPath is where the login page of the single sign-on service is posted to
+	samlLoginPath = "/saml/login"
+	// samlPostScript submits the response form of the HTTP-POST binding
+	samlPostScript = "document.forms[0].submit();"
+)
+
+// samlPostPage posts a response to a service provider's ACS URL (HTTP-POST binding)
+var samlPostPage = template.Must(template.New("saml").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Signing in</title>
+</head>
+<body>
+<form method="post" action="{{.ACSURL}}">
+<input type="hidden" name="SAMLResponse" value="{{.Response}}">
+{{if .RelayState}}<input type="hidden" name="RelayState" value="{{.RelayState}}">
+{{end}}<noscript><input type="submit" value="Continue"></noscript>
+</form>
+<script>` + samlPostScript + `</script>
+</body>
+</html>
+`))
+
+// samlPostScriptHash allows samlPostScript only to be run by the response page
+var samlPostScriptHash = func() string {
+	h := sha256.Sum256([]byte(samlPostScript))
+	return "'sha256-" + base64.StdEncoding.EncodeToString(h[:]) + "'"
+}()
+
+// samlPostPageData is rendered by samlPostPage
+type samlPostPageData struct {
+	ACSURL     string
+	Response   string
+	RelayState string
+}
+
+//
+// SAMLWebHandler implements the metadata and single sign-on endpoints of the SAML
+// 2.0 identity provider. Authentication requests are taken with the HTTP-Redirect
+// and HTTP-POST bindings, responses are sent with the HTTP-POST binding.
+//
+type SAMLWebHandler struct {
+	log            *log.Logger
+	SAMLInteractor usecases.SAMLInteractor
+}
+
+// NewSAMLWebHandler creates new SAMLWebHandler
+func NewSAMLWebHandler() *SAMLWebHandler {
+	return &SAMLWebHandler{
+		log: log.New(os.Stdout, "[SAMLHandler] ", log.LstdFlags),
+	}
+}
+
+// Metadata handles a read request of the IdP's metadata
+func (handler *SAMLWebHandler) Metadata(w http.ResponseWriter, r *http.Request) {
+	data, err := handler.SAMLInteractor.Metadata()
+	if err != nil {
+		handler.log.Println(err.Error())
+		status := http.StatusInternalServerError
+		if e, ok := err.(*errs.Error); ok {
+			status = errorToHTTPStatus(e)
+		}
+		http.Error(w, "SAML metadata is not available", status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/samlmetadata+xml")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// SSO handles an authentication request of the HTTP-Redirect binding. The user is
+// signed in with an existing session if there is one (and the service provider
+// doesn't force authentication), otherwise the login page is shown.
+func (handler *SAMLWebHandler) SSO(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		renderLoginPage(w, handler.log, http.StatusBadRequest, loginPageData{Error: "Failed to decode request data"})
+		return
+	}
+	data, err := saml.DecodeRedirectRequest(r.Form.Get("SAMLRequest"))
+	if err != nil {
+		renderLoginPage(w, handler.log, http.StatusBadRequest, loginPageData{Error: err.Error()})
+		return
+	}
+	req, sp, ok := handler.validateRequest(w, data)
+	if !ok {
+		return
+	}
+	relayState := r.Form.Get("RelayState")
+
+	if c, err := r.Cookie(samlSessionCookie); err == nil && !req.ForceAuthn {
+		session, err := handler.SAMLInteractor.Resume(*sp, c.Value, r.UserAgent(), remoteAddrFromRequest(r))
+		if err != nil {
+			handler.respondWithError(w, err)
+			return
+		}
+		if session != nil {
+			handler.respond(w, r, *sp, *req, *session, relayState)
+			return
+		}
+	}
+
+	renderLoginPage(w, handler.log, http.StatusOK, loginPageData{
+		Client: sp.Name,
+		Form:   true,
+		Action: samlLoginPath,
+		Params: samlParams(data, relayState),
+	})
+}
+
+// SSOPost handles an authentication request of the HTTP-POST binding. The request is
+// redirected to the HTTP-Redirect binding, since browsers don't send the session
+// cookie along with cross-site POST requests.
+func (handler *SAMLWebHandler) SSOPost(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		renderLoginPage(w, handler.log, http.StatusBadRequest, loginPageData{Error: "Failed to decode request data"})
+		return
+	}
+	data, err := saml.DecodePOSTRequest(r.PostForm.Get("SAMLRequest"))
+	if err != nil {
+		renderLoginPage(w, handler.log, http.StatusBadRequest, loginPageData{Error: err.Error()})
+		return
+	}
+	encoded, err := saml.EncodeRedirectRequest(data)
+	if err != nil {
+		handler.respondWithError(w, err)
+		return
+	}
+	q := url.Values{"SAMLRequest": {encoded}}
+	if relayState := r.PostForm.Get("RelayState"); relayState != "" {
+		q.Set("RelayState", relayState)
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, r.URL.Path+"?"+q.Encode(), http.StatusSeeOther)
+}
+
+// Login signs a user in with the credentials submitted from the login page and
+// responds to the service provider. The login page is shown again if the credentials
+// are invalid, or asks for an authentication code if the user has to provide one.
+func (handler *SAMLWebHandler) Login(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		renderLoginPage(w, handler.log, http.StatusBadRequest, loginPageData{Error: "Failed to decode request data"})
+		return
+	}
+	data, err := saml.DecodePOSTRequest(r.PostForm.Get("SAMLRequest"))
+	if err != nil {
+		renderLoginPage(w, handler.log, http.StatusBadRequest, loginPageData{Error: err.Error()})
+		return
+	}
+	req, sp, ok := handler.validateRequest(w, data)
+	if !ok {
+		return
+	}
+	relayState := r.PostForm.Get("RelayState")
+
+	page := loginPageData{
+		Client:   sp.Name,
+		Form:     true,
+		Action:   samlLoginPath,
+		Params:   samlParams(data, relayState),
+		UserName: r.PostForm.Get("username"),
+	}
+	if challengeID := r.PostForm.Get(mfaChallengeParam); challengeID != "" {
+		handler.loginWithCode(w, r, *sp, *req, relayState, challengeID, page)
+		return
+	}
+	password := r.PostForm.Get("password")
+	if page.UserName == "" || password == "" {
+		page.Error = "User name and password are required"
+		renderLoginPage(w, handler.log, http.StatusOK, page)
+		return
+	}
+	session, challenge, err := handler.SAMLInteractor.Login(*sp, page.UserName, password, r.UserAgent(), remoteAddrFromRequest(r))
+	if e, ok := err.(*errs.Error); ok && e.Type == errs.ErrorTypeUnauthorized {
+		page.Error = "Invalid user name or password"
+		renderLoginPage(w, handler.log, http.StatusOK, page)
+		return
+	}
+	if e, ok := err.(*errs.Error); ok && (e.RetryAfter > 0 || e.Type == errs.ErrorTypePasswordExpired) {
+		page.Error = e.Msg
+		renderLoginPage(w, handler.log, http.StatusOK, page)
+		return
+	}
+	if err != nil {
+		handler.respondWithError(w, err)
+		return
+	}
+	if challenge != nil {
+		page.Code = true
+		page.Params[mfaChallengeParam] = challenge.ID
+		renderLoginPage(w, handler.log, http.StatusOK, page)
+		return
+	}
+
+	handler.respond(w, r, *sp, *req, *session, relayState)
+}
+
+// loginWithCode completes a challenge with the authentication code submitted from
+// the login page. The page asks for another code if the code is invalid, or for the
+// credentials again if the challenge can't be completed anymore.
+func (handler *SAMLWebHandler) loginWithCode(w http.ResponseWriter, r *http.Request, sp entities.ServiceProvider, req saml.AuthnRequest, relayState, challengeID string, page loginPageData) {
+	session, err := handler.SAMLInteractor.CompleteMFA(sp, challengeID, r.PostForm.Get("code"), r.UserAgent(), remoteAddrFromRequest(r))
+	if e, ok := err.(*errs.Error); ok && e.Type != errs.ErrorTypeOperational {
+		page.Error = e.Msg
+		if e.Type == errs.ErrorTypeUnauthorized {
+			page.Code = true
+			page.Params[mfaChallengeParam] = challengeID
+		}
+		renderLoginPage(w, handler.log, http.StatusOK, page)
+		return
+	}
+	if err != nil {
+		handler.respondWithError(w, err)
+		return
+	}
+
+	handler.respond(w, r, sp, req, *session, relayState)
+}
+
+// validateRequest parses an authentication request and finds the service provider
+// which has issued it. An error page is rendered if the request isn't valid.
+func (handler *SAMLWebHandler) validateRequest(w http.ResponseWriter, data []byte) (*saml.AuthnRequest, *entities.ServiceProvider, bool) {
+	req, err := saml.ParseAuthnRequest(data)
+	if err != nil {
+		renderLoginPage(w, handler.log, http.StatusBadRequest, loginPageData{Error: err.Error()})
+		return nil, nil, false
+	}
+	sp, err := handler.SAMLInteractor.ValidateRequest(*req)
+	if err != nil {
+		handler.respondWithError(w, err)
+		return nil, nil, false
+	}
+	return req, sp, true
+}
+
+// respond posts a response with an assertion about a session's user to the service
+// provider and keeps the session in a cookie for further sign-ins
+func (handler *SAMLWebHandler) respond(w http.ResponseWriter, r *http.Request, sp entities.ServiceProvider, req saml.AuthnRequest, session entities.Session, relayState string) {
+	res, err := handler.SAMLInteractor.Respond(sp, req, session)
+	if err != nil {
+		handler.respondWithError(w, err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     samlSessionCookie,
+		Value:    session.Reference,
+		Path:     "/saml",
+		Expires:  time.Now().UTC().Add(time.Duration(config.SessionTTLMinutes()) * time.Minute),
+		Secure:   isSecureRequest(r),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; script-src "+samlPostScriptHash+"; frame-ancestors 'none'")
+	w.WriteHeader(http.StatusOK)
+	err = samlPostPage.Execute(w, samlPostPageData{
+		ACSURL:     sp.ACSURL,
+		Response:   base64.StdEncoding.EncodeToString(res),
+		RelayState: relayState,
+	})
+	if err != nil {
+		handler.log.Println(err.Error())
+	}
+}
+
+// respondWithError shows an error of a single sign-on request to the user. Details
+// of internal errors are logged only.
+func (handler *SAMLWebHandler) respondWithError(w http.ResponseWriter, err error) {
+	handler.log.Println(err.Error())
+	status := http.StatusInternalServerError
+	message := "Sign in failed, please try again later"
+	if e, ok := err.(*errs.Error); ok {
+		status = errorToHTTPStatus(e)
+		if e.Type != errs.ErrorTypeOperational {
+			message = e.Msg
+		}
+	}
+	renderLoginPage(w, handler.log, status, loginPageData{Error: message})
+}
+
+// samlParams are the parameters of an authentication request carried over from the
+// login page to its submission. The request is carried in HTTP-POST encoding.
+func samlParams(data []byte, relayState string) map[string]string {
+	params := map[string]string{"SAMLRequest": base64.StdEncoding.EncodeToString(data)}
+	if relayState != "" {
+		params["RelayState"] = relayState
+	}
+	return params
+}
@@ -2,44 +2,88 @@ package web
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"os"
 
 	"github.com/gorilla/context"
+	"github.com/julienschmidt/httprouter"
 	"github.com/oleksandr/idp/config"
 	"github.com/oleksandr/idp/entities"
 	"github.com/oleksandr/idp/errs"
 	"github.com/oleksandr/idp/usecases"
+	"github.com/oleksandr/idp/webauthn"
 )
 
 // SessionForm used for parsing incoming data
 type SessionForm struct {
 	Session struct {
 		User struct {
-			Name     string `json:"name"`
-			Password string `json:"password"`
+			Name        string `json:"name"`
+			Password    string `json:"password"`
+			Code        string `json:"code"`
+			NewPassword string `json:"new_password"`
 		} `json:"user"`
 		Domain struct {
 			ID   string `json:"id"`
 			Name string `json:"name"`
 		} `json:"domain"`
+		AccessToken bool `json:"access_token"`
 	} `json:"session"`
 }
 
+// MFAForm used for parsing a code completing a challenge
+type MFAForm struct {
+	MFA struct {
+		Code        string `json:"code"`
+		AccessToken bool   `json:"access_token"`
+	} `json:"mfa"`
+}
+
+// AssertionForm used for parsing an assertion of a WebAuthn credential completing a
+// challenge
+type AssertionForm struct {
+	Assertion struct {
+		ChallengeID         string                     `json:"challenge_id"`
+		PublicKeyCredential webauthn.AssertionResponse `json:"public_key_credential"`
+		AccessToken         bool                       `json:"access_token"`
+	} `json:"assertion"`
+}
+
 // SessionResource used for responses
 type SessionResource struct {
-	Session entities.Session `json:"session"`
+	Session     entities.Session      `json:"session"`
+	AccessToken *entities.AccessToken `json:"access_token,omitempty"`
+}
+
+// MFAChallengeResource used for responses
+type MFAChallengeResource struct {
+	MFAChallenge entities.MFAChallenge `json:"mfa_challenge"`
+}
+
+// WebAuthnChallengeResource used for responses. PublicKey holds the options to be
+// passed to navigator.credentials.create() or navigator.credentials.get().
+type WebAuthnChallengeResource struct {
+	WebAuthnChallenge entities.WebAuthnChallenge `json:"webauthn_challenge"`
+	PublicKey         interface{}                `json:"public_key"`
+}
+
+// AccessTokenResource used for responses
+type AccessTokenResource struct {
+	AccessToken entities.AccessToken `json:"access_token"`
 }
 
 //
 // SessionWebHandler is a collection of CRUD methods for Sessions API
 //
 type SessionWebHandler struct {
-	log               *log.Logger
-	SessionInteractor usecases.SessionInteractor
-	UserInteractor    usecases.UserInteractor
-	DomainInteractor  usecases.DomainInteractor
+	log                *log.Logger
+	SessionInteractor  usecases.SessionInteractor
+	UserInteractor     usecases.UserInteractor
+	DomainInteractor   usecases.DomainInteractor
+	TokenInteractor    usecases.TokenInteractor
+	WebAuthnInteractor usecases.WebAuthnInteractor
 }
 
 // NewSessionWebHandler creates new SessionWebHandler
@@ -49,7 +93,11 @@ func NewSessionWebHandler() *SessionWebHandler {
 	}
 }
 
-// Create opens a new session if none exists
+// Create opens a new session if none exists. An access token of the session is
+// issued as well if requested. A challenge is created instead if the user has to
+// provide an authentication code, which is completed by CompleteMFA. If a new
+// password is given, the expired one is changed first, which requires the code
+// along with the old password, and the session is opened without a challenge.
 func (handler *SessionWebHandler) Create(w http.ResponseWriter, r *http.Request) {
 	// Parse incoming credentials
 	var form SessionForm
@@ -68,20 +116,141 @@ func (handler *SessionWebHandler) Create(w http.ResponseWriter, r *http.Request)
 	userAgent := r.UserAgent()
 	remoteAddr := remoteAddrFromRequest(r)
 
+	// Change password, which checks the code as well, and sign in right away
+	if form.Session.User.NewPassword != "" {
+		err = handler.SessionInteractor.ChangePassword(domain, user, form.Session.User.Password, form.Session.User.Code, form.Session.User.NewPassword, remoteAddr)
+		if err != nil {
+			e := err.(*errs.Error)
+			handler.log.Printf("%v@%v: %v", user.Name, domain.Name, err)
+			respondWithError(w, errorToHTTPStatus(e), "Failed to change password", e)
+			return
+		}
+		session, err := handler.SessionInteractor.Create(domain, user, userAgent, remoteAddr)
+		if err != nil {
+			e := err.(*errs.Error)
+			handler.log.Printf("%v@%v: %v", user.Name, domain.Name, err)
+			respondWithError(w, errorToHTTPStatus(e), "Failed to create session", e)
+			return
+		}
+		handler.respondWithSession(w, *session, form.Session.AccessToken)
+		return
+	}
+
 	// Create session
-	session, err := handler.SessionInteractor.CreateWithPassword(domain, user, form.Session.User.Password, userAgent, remoteAddr)
+	session, challenge, err := handler.SessionInteractor.BeginWithPassword(domain, user, form.Session.User.Password, userAgent, remoteAddr)
+	if err == nil && challenge != nil {
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(MFAChallengeResource{MFAChallenge: *challenge})
+		return
+	}
 	if err == nil {
-		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(SessionResource{Session: *session})
+		handler.respondWithSession(w, *session, form.Session.AccessToken)
 		return
 	}
 
 	// Handle errors
 	e := err.(*errs.Error)
-	handler.log.Printf("%v: %v", form, err)
+	handler.log.Printf("%v@%v: %v", user.Name, domain.Name, err)
+	respondWithError(w, errorToHTTPStatus(e), "Failed to create session", e)
+}
+
+// CompleteMFA completes a challenge created by Create with a one-time password or a
+// recovery code and opens the session. It has to be called from the same user agent
+// and remote address as Create.
+func (handler *SessionWebHandler) CompleteMFA(w http.ResponseWriter, r *http.Request) {
+	params := context.Get(r, config.CtxParamsKey).(httprouter.Params)
+
+	var form MFAForm
+	err := json.NewDecoder(r.Body).Decode(&form)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Failed to decode request data", err)
+		return
+	}
+	if form.MFA.Code == "" {
+		respondWithError(w, http.StatusBadRequest, "Failed to create session", errors.New("Code is required"))
+		return
+	}
+
+	session, err := handler.SessionInteractor.CompleteMFA(params.ByName("id"), form.MFA.Code, r.UserAgent(), remoteAddrFromRequest(r))
+	if err == nil {
+		handler.respondWithSession(w, *session, form.MFA.AccessToken)
+		return
+	}
+	e := err.(*errs.Error)
+	handler.log.Println(e.Error())
 	respondWithError(w, errorToHTTPStatus(e), "Failed to create session", e)
 }
 
+// BeginWebAuthn creates a challenge to open a session with a WebAuthn credential in
+// a given domain. The credentials are limited to the ones of a user if the user's
+// name is given, otherwise any passkey of a user of the domain is accepted.
+func (handler *SessionWebHandler) BeginWebAuthn(w http.ResponseWriter, r *http.Request) {
+	var form SessionForm
+	err := json.NewDecoder(r.Body).Decode(&form)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Failed to decode request data", err)
+		return
+	}
+
+	user := entities.BasicUser{}
+	user.Name = form.Session.User.Name
+	domain := entities.BasicDomain{}
+	domain.ID = form.Session.Domain.ID
+	domain.Name = form.Session.Domain.Name
+
+	challenge, options, err := handler.WebAuthnInteractor.BeginAssertion(domain, user)
+	if err == nil {
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(WebAuthnChallengeResource{WebAuthnChallenge: *challenge, PublicKey: options})
+		return
+	}
+	e := err.(*errs.Error)
+	handler.log.Println(e.Error())
+	respondWithError(w, errorToHTTPStatus(e), "Failed to create challenge", e)
+}
+
+// CompleteWebAuthn completes a challenge created by BeginWebAuthn with an assertion
+// of a credential and opens the session
+func (handler *SessionWebHandler) CompleteWebAuthn(w http.ResponseWriter, r *http.Request) {
+	var form AssertionForm
+	err := json.NewDecoder(r.Body).Decode(&form)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Failed to decode request data", err)
+		return
+	}
+	if form.Assertion.ChallengeID == "" {
+		respondWithError(w, http.StatusBadRequest, "Failed to create session", errors.New("Challenge ID is required"))
+		return
+	}
+
+	session, err := handler.SessionInteractor.CompleteWebAuthn(form.Assertion.ChallengeID, form.Assertion.PublicKeyCredential, r.UserAgent(), remoteAddrFromRequest(r))
+	if err == nil {
+		handler.respondWithSession(w, *session, form.Assertion.AccessToken)
+		return
+	}
+	e := err.(*errs.Error)
+	handler.log.Println(e.Error())
+	respondWithError(w, errorToHTTPStatus(e), "Failed to create session", e)
+}
+
+// respondWithSession responds with a session opened along with its access token if
+// requested
+func (handler *SessionWebHandler) respondWithSession(w http.ResponseWriter, session entities.Session, accessToken bool) {
+	res := SessionResource{Session: session}
+	if accessToken {
+		t, err := handler.TokenInteractor.Issue(session)
+		if err != nil {
+			e := err.(*errs.Error)
+			handler.log.Println(e.Error())
+			respondWithError(w, errorToHTTPStatus(e), "Failed to issue access token", e)
+			return
+		}
+		res.AccessToken = t
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(res)
+}
+
 // Check validates if current session is valid
 func (handler *SessionWebHandler) Check(w http.ResponseWriter, r *http.Request) {
 	if _, ok := context.Get(r, config.CtxSessionKey).(entities.Session); ok {
@@ -116,3 +285,21 @@ func (handler *SessionWebHandler) Delete(w http.ResponseWriter, r *http.Request)
 	}
 	w.WriteHeader(http.StatusUnauthorized)
 }
+
+// IssueToken issues a new access token of current session, so the session ID
+// serves as a refresh token until the session is deleted or expires
+func (handler *SessionWebHandler) IssueToken(w http.ResponseWriter, r *http.Request) {
+	if s, ok := context.Get(r, config.CtxSessionKey).(entities.Session); ok {
+		t, err := handler.TokenInteractor.Issue(s)
+		if err == nil {
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(AccessTokenResource{AccessToken: *t})
+			return
+		}
+		e := err.(*errs.Error)
+		handler.log.Println(e.Error())
+		respondWithError(w, errorToHTTPStatus(e), "Failed to issue access token", e)
+		return
+	}
+	w.WriteHeader(http.StatusUnauthorized)
+}
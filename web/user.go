@@ -1,34 +1,267 @@
 package web
 
 import (
+	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
+	"os"
 
+	"github.com/gorilla/context"
+	"github.com/julienschmidt/httprouter"
+	"github.com/oleksandr/idp/config"
+	"github.com/oleksandr/idp/entities"
+	"github.com/oleksandr/idp/errs"
 	"github.com/oleksandr/idp/usecases"
 )
 
+// userSortFields maps sortable API fields to user table columns
+var userSortFields = map[string]string{
+	"name":       "name",
+	"enabled":    "is_enabled",
+	"created_on": "created_on",
+	"updated_on": "updated_on",
+}
+
+// UserForm used for parsing incoming data. Pointers are used to tell
+// omitted attributes from empty ones when modifying a user.
+type UserForm struct {
+	User struct {
+		Name            *string  `json:"name"`
+		Password        *string  `json:"password"`
+		Enabled         *bool    `json:"enabled"`
+		Domains         []string `json:"domains"`
+		AddDomainIDs    []string `json:"add_domains"`
+		RemoveDomainIDs []string `json:"remove_domains"`
+	} `json:"user"`
+}
+
+// UserResource used for responses
+type UserResource struct {
+	User entities.BasicUser `json:"user"`
+}
+
 //
 // UserWebHandler is a collection of CRUD methods for Users API
 //
 type UserWebHandler struct {
+	log            *log.Logger
 	UserInteractor usecases.UserInteractor
 }
 
+// NewUserWebHandler creates new UserWebHandler
+func NewUserWebHandler() *UserWebHandler {
+	return &UserWebHandler{
+		log: log.New(os.Stdout, "[UserHandler] ", log.LstdFlags),
+	}
+}
+
+// Create creates a new user and assigns it to given domains
 func (handler *UserWebHandler) Create(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusNotImplemented)
+	var form UserForm
+	err := json.NewDecoder(r.Body).Decode(&form)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Failed to decode request data", err)
+		return
+	}
+	if form.User.Name == nil || form.User.Password == nil {
+		respondWithError(w, http.StatusBadRequest, "Failed to create user", errors.New("Name and password are required"))
+		return
+	}
+	if len(form.User.Domains) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Failed to create user", errors.New("At least one domain is required"))
+		return
+	}
+
+	u := entities.NewBasicUser(*form.User.Name)
+	err = u.SetPassword(*form.User.Password)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Failed to create user", err)
+		return
+	}
+	if form.User.Enabled != nil {
+		u.Enabled = *form.User.Enabled
+	}
+
+	err = handler.UserInteractor.Create(*u, *form.User.Password, form.User.Domains)
+	if err != nil {
+		e := err.(*errs.Error)
+		handler.log.Println(e.Error())
+		respondWithError(w, errorToHTTPStatus(e), "Failed to create user", e)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(UserResource{User: *u})
 }
 
+// Retrieve returns a user by ID
 func (handler *UserWebHandler) Retrieve(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusNotImplemented)
+	params := context.Get(r, config.CtxParamsKey).(httprouter.Params)
+
+	u, err := handler.UserInteractor.Find(params.ByName("id"))
+	if err != nil {
+		e := err.(*errs.Error)
+		respondWithError(w, errorToHTTPStatus(e), "Failed to retrieve user", e)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(UserResource{User: *u})
+}
+
+// RetrieveByNameInDomain returns a user by name if the user is in a given domain
+func (handler *UserWebHandler) RetrieveByNameInDomain(w http.ResponseWriter, r *http.Request) {
+	params := context.Get(r, config.CtxParamsKey).(httprouter.Params)
+
+	u, err := handler.UserInteractor.FindByNameInDomain(params.ByName("name"), params.ByName("id"))
+	if err != nil {
+		e := err.(*errs.Error)
+		respondWithError(w, errorToHTTPStatus(e), "Failed to retrieve user", e)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(UserResource{User: *u})
 }
 
+// List returns a paginated collection of users
 func (handler *UserWebHandler) List(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusNotImplemented)
+	pager := pagerFromRequest(r)
+	sorter := sorterFromRequest(r, userSortFields, entities.Sorter{Field: "name", Asc: true})
+
+	collection, err := handler.UserInteractor.List(pager, sorter)
+	if err != nil {
+		e := err.(*errs.Error)
+		handler.log.Println(e.Error())
+		respondWithError(w, errorToHTTPStatus(e), "Failed to list users", e)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(collection)
 }
 
-func (handler *UserWebHandler) Modify(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusNotImplemented)
+// ListByDomain returns a paginated collection of users of a given domain
+func (handler *UserWebHandler) ListByDomain(w http.ResponseWriter, r *http.Request) {
+	params := context.Get(r, config.CtxParamsKey).(httprouter.Params)
+	pager := pagerFromRequest(r)
+	sorter := sorterFromRequest(r, userSortFields, entities.Sorter{Field: "name", Asc: true})
+
+	collection, err := handler.UserInteractor.ListByDomain(params.ByName("id"), pager, sorter)
+	if err != nil {
+		e := err.(*errs.Error)
+		handler.log.Println(e.Error())
+		respondWithError(w, errorToHTTPStatus(e), "Failed to list users", e)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(collection)
+}
+
+// Update modifies attributes and domain membership of an existing user
+func (handler *UserWebHandler) Update(w http.ResponseWriter, r *http.Request) {
+	params := context.Get(r, config.CtxParamsKey).(httprouter.Params)
+
+	var form UserForm
+	err := json.NewDecoder(r.Body).Decode(&form)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Failed to decode request data", err)
+		return
+	}
+
+	u, err := handler.UserInteractor.Find(params.ByName("id"))
+	if err != nil {
+		e := err.(*errs.Error)
+		respondWithError(w, errorToHTTPStatus(e), "Failed to update user", e)
+		return
+	}
+
+	if form.User.Name != nil {
+		u.Name = *form.User.Name
+	}
+	password := ""
+	if form.User.Password != nil {
+		password = *form.User.Password
+		if password == "" {
+			respondWithError(w, http.StatusBadRequest, "Failed to update user", errors.New("Password cannot be empty"))
+			return
+		}
+		err = u.SetPassword(password)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Failed to update user", err)
+			return
+		}
+	}
+	if form.User.Enabled != nil {
+		u.Enabled = *form.User.Enabled
+	}
+
+	err = handler.UserInteractor.Update(*u, password, form.User.AddDomainIDs, form.User.RemoveDomainIDs)
+	if err != nil {
+		e := err.(*errs.Error)
+		handler.log.Println(e.Error())
+		respondWithError(w, errorToHTTPStatus(e), "Failed to update user", e)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(UserResource{User: *u})
 }
 
+// Delete removes an existing user
 func (handler *UserWebHandler) Delete(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusNotImplemented)
+	params := context.Get(r, config.CtxParamsKey).(httprouter.Params)
+
+	u, err := handler.UserInteractor.Find(params.ByName("id"))
+	if err != nil {
+		e := err.(*errs.Error)
+		respondWithError(w, errorToHTTPStatus(e), "Failed to delete user", e)
+		return
+	}
+
+	err = handler.UserInteractor.Delete(u.ID)
+	if err != nil {
+		e := err.(*errs.Error)
+		handler.log.Println(e.Error())
+		respondWithError(w, errorToHTTPStatus(e), "Failed to delete user", e)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// AssignRole assigns a role to an existing user. The assignment is scoped to
+// a domain given by the "domain" query parameter or is global otherwise.
+func (handler *UserWebHandler) AssignRole(w http.ResponseWriter, r *http.Request) {
+	params := context.Get(r, config.CtxParamsKey).(httprouter.Params)
+	domainID := r.URL.Query().Get("domain")
+
+	err := handler.UserInteractor.AssignRoles(params.ByName("id"), []string{params.ByName("role")}, domainID)
+	if err != nil {
+		e := err.(*errs.Error)
+		handler.log.Println(e.Error())
+		respondWithError(w, errorToHTTPStatus(e), "Failed to assign role", e)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// RevokeRole revokes a role from an existing user. Only the assignment scoped
+// to a domain given by the "domain" query parameter (or the global one) is revoked.
+func (handler *UserWebHandler) RevokeRole(w http.ResponseWriter, r *http.Request) {
+	params := context.Get(r, config.CtxParamsKey).(httprouter.Params)
+	domainID := r.URL.Query().Get("domain")
+
+	err := handler.UserInteractor.RevokeRoles(params.ByName("id"), []string{params.ByName("role")}, domainID)
+	if err != nil {
+		e := err.(*errs.Error)
+		handler.log.Println(e.Error())
+		respondWithError(w, errorToHTTPStatus(e), "Failed to revoke role", e)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
 }
@@ -0,0 +1,171 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// maxCBORDepth limits nesting of decoded CBOR items
+const maxCBORDepth = 16
+
+// CBOR major types (RFC 7049 section 2.1)
+const (
+	cborUnsigned = 0
+	cborNegative = 1
+	cborBytes    = 2
+	cborText     = 3
+	cborArray    = 4
+	cborMap      = 5
+	cborTag      = 6
+	cborSimple   = 7
+)
+
+//
+// cborDecoder decodes the subset of CBOR (RFC 7049) used by authenticators:
+// integers, byte and text strings, arrays, maps, booleans and null of definite
+// length. Integers are decoded as int64, maps as map[interface{}]interface{}.
+//
+type cborDecoder struct {
+	data []byte
+	off  int
+}
+
+// decodeCBOR decodes a single CBOR item which must span all of the data
+func decodeCBOR(data []byte) (interface{}, error) {
+	d := &cborDecoder{data: data}
+	v, err := d.value(0)
+	if err != nil {
+		return nil, err
+	}
+	if d.off != len(d.data) {
+		return nil, errors.New("Unexpected data after CBOR item")
+	}
+	return v, nil
+}
+
+// value decodes the next item
+func (d *cborDecoder) value(depth int) (interface{}, error) {
+	if depth > maxCBORDepth {
+		return nil, errors.New("CBOR item is nested too deeply")
+	}
+	major, arg, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case cborUnsigned:
+		if arg > math.MaxInt64 {
+			return nil, errors.New("CBOR integer overflows")
+		}
+		return int64(arg), nil
+	case cborNegative:
+		if arg > math.MaxInt64 {
+			return nil, errors.New("CBOR integer overflows")
+		}
+		return -1 - int64(arg), nil
+	case cborBytes, cborText:
+		b, err := d.bytes(arg)
+		if err != nil {
+			return nil, err
+		}
+		if major == cborText {
+			return string(b), nil
+		}
+		return b, nil
+	case cborArray:
+		if arg > uint64(len(d.data)-d.off) {
+			return nil, errors.New("CBOR array is truncated")
+		}
+		a := make([]interface{}, 0, int(arg))
+		for i := uint64(0); i < arg; i++ {
+			v, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, v)
+		}
+		return a, nil
+	case cborMap:
+		if arg > uint64(len(d.data)-d.off) {
+			return nil, errors.New("CBOR map is truncated")
+		}
+		m := map[interface{}]interface{}{}
+		for i := uint64(0); i < arg; i++ {
+			k, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			switch k.(type) {
+			case int64, string:
+			default:
+				return nil, fmt.Errorf("Unsupported CBOR map key %T", k)
+			}
+			if _, ok := m[k]; ok {
+				return nil, fmt.Errorf("Duplicate CBOR map key %v", k)
+			}
+			v, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = v
+		}
+		return m, nil
+	case cborSimple:
+		switch arg {
+		case 20:
+			return false, nil
+		case 21:
+			return true, nil
+		case 22:
+			return nil, nil
+		}
+	}
+	return nil, fmt.Errorf("Unsupported CBOR item (major type %v)", major)
+}
+
+// head decodes the initial byte of an item along with its argument
+func (d *cborDecoder) head() (byte, uint64, error) {
+	if d.off >= len(d.data) {
+		return 0, 0, errors.New("Unexpected end of CBOR data")
+	}
+	major := d.data[d.off] >> 5
+	info := d.data[d.off] & 0x1f
+	d.off++
+
+	var size int
+	switch {
+	case info < 24:
+		return major, uint64(info), nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	default:
+		// Indefinite lengths aren't used by authenticators
+		return 0, 0, errors.New("Unsupported CBOR length")
+	}
+	if len(d.data)-d.off < size {
+		return 0, 0, errors.New("Unexpected end of CBOR data")
+	}
+	b := make([]byte, 8)
+	copy(b[8-size:], d.data[d.off:d.off+size])
+	d.off += size
+	return major, binary.BigEndian.Uint64(b), nil
+}
+
+// bytes returns the next n bytes of the data
+func (d *cborDecoder) bytes(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.off) {
+		return nil, errors.New("CBOR string is truncated")
+	}
+	b := d.data[d.off : d.off+int(n)]
+	d.off += int(n)
+	return b, nil
+}
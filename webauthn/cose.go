@@ -0,0 +1,133 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// COSE algorithms (RFC 8152 section 8, RFC 8812) of supported credential keys
+const (
+	AlgorithmES256 = -7
+	AlgorithmEdDSA = -8
+	AlgorithmRS256 = -257
+)
+
+// COSE key parameters and values
+const (
+	coseKeyType   = 1
+	coseAlgorithm = 3
+	coseCurve     = -1
+	coseX         = -2
+	coseY         = -3
+	coseN         = -1
+	coseE         = -2
+
+	coseKeyTypeOKP = 1
+	coseKeyTypeEC2 = 2
+	coseKeyTypeRSA = 3
+	coseCurveP256  = 1
+	coseCurveEd    = 6
+)
+
+// minRSAKeySize is the minimal size of RSA keys in bits
+const minRSAKeySize = 2048
+
+//
+// PublicKey is a credential public key decoded from its COSE_Key encoding
+//
+type PublicKey struct {
+	Algorithm int
+	Key       crypto.PublicKey
+}
+
+// ParsePublicKey decodes a COSE_Key (RFC 8152 section 7) of an ES256 (P-256),
+// an EdDSA (Ed25519) or an RS256 key
+func ParsePublicKey(data []byte) (*PublicKey, error) {
+	v, err := decodeCBOR(data)
+	if err != nil {
+		return nil, err
+	}
+	return publicKeyFromCOSE(v)
+}
+
+// publicKeyFromCOSE converts a decoded COSE_Key
+func publicKeyFromCOSE(v interface{}) (*PublicKey, error) {
+	m, ok := v.(map[interface{}]interface{})
+	if !ok {
+		return nil, errors.New("COSE key is not a map")
+	}
+	kty, _ := m[int64(coseKeyType)].(int64)
+	alg, _ := m[int64(coseAlgorithm)].(int64)
+
+	switch {
+	case kty == coseKeyTypeEC2 && alg == AlgorithmES256:
+		crv, _ := m[int64(coseCurve)].(int64)
+		x, _ := m[int64(coseX)].([]byte)
+		y, _ := m[int64(coseY)].([]byte)
+		if crv != coseCurveP256 || len(x) != 32 || len(y) != 32 {
+			return nil, errors.New("Invalid P-256 key")
+		}
+		key := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("Invalid P-256 key")
+		}
+		return &PublicKey{Algorithm: AlgorithmES256, Key: key}, nil
+	case kty == coseKeyTypeOKP && alg == AlgorithmEdDSA:
+		crv, _ := m[int64(coseCurve)].(int64)
+		x, _ := m[int64(coseX)].([]byte)
+		if crv != coseCurveEd || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("Invalid Ed25519 key")
+		}
+		return &PublicKey{Algorithm: AlgorithmEdDSA, Key: ed25519.PublicKey(x)}, nil
+	case kty == coseKeyTypeRSA && alg == AlgorithmRS256:
+		n, _ := m[int64(coseN)].([]byte)
+		e, _ := m[int64(coseE)].([]byte)
+		if len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("Invalid RSA key")
+		}
+		key := &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+		if key.N.BitLen() < minRSAKeySize || key.E < 3 {
+			return nil, errors.New("Invalid RSA key")
+		}
+		return &PublicKey{Algorithm: AlgorithmRS256, Key: key}, nil
+	}
+	return nil, fmt.Errorf("Unsupported key type %v with algorithm %v", kty, alg)
+}
+
+// Verify checks a signature of data made with the private part of the key
+func (k *PublicKey) Verify(data, sig []byte) bool {
+	switch k.Algorithm {
+	case AlgorithmES256:
+		var es struct {
+			R, S *big.Int
+		}
+		rest, err := asn1.Unmarshal(sig, &es)
+		if err != nil || len(rest) > 0 || es.R.Sign() <= 0 || es.S.Sign() <= 0 {
+			return false
+		}
+		h := sha256.Sum256(data)
+		return ecdsa.Verify(k.Key.(*ecdsa.PublicKey), h[:], es.R, es.S)
+	case AlgorithmEdDSA:
+		return ed25519.Verify(k.Key.(ed25519.PublicKey), data, sig)
+	case AlgorithmRS256:
+		h := sha256.Sum256(data)
+		return rsa.VerifyPKCS1v15(k.Key.(*rsa.PublicKey), crypto.SHA256, h[:], sig) == nil
+	}
+	return false
+}
@@ -0,0 +1,422 @@
+// Package webauthn implements the relying party side of Web Authentication
+// (WebAuthn): the options of registration and authentication ceremonies and the
+// verification of their results. Only "none" attestation is requested and
+// attestation statements aren't verified, so any authenticator can be registered.
+// Credentials have to verify the user (e.g. with a PIN or a fingerprint).
+package webauthn
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Ceremony types as given in the client data
+const (
+	CeremonyCreate = "webauthn.create"
+	CeremonyGet    = "webauthn.get"
+)
+
+// Flags of authenticator data
+const (
+	flagUserPresent      = 0x01
+	flagUserVerified     = 0x04
+	flagAttestedData     = 0x40
+	flagExtensionData    = 0x80
+	authenticatorDataMin = 37
+	aaguidSize           = 16
+)
+
+var (
+	// ErrMalformed is returned when a response can't be decoded
+	ErrMalformed = errors.New("Malformed credential response")
+	// ErrUnsupportedKey is returned when a credential's key is of an unsupported type
+	ErrUnsupportedKey = errors.New("Unsupported credential key")
+	// ErrCeremony is returned when a response belongs to another ceremony, challenge,
+	// origin or relying party
+	ErrCeremony = errors.New("Credential response doesn't match the ceremony")
+	// ErrUserNotVerified is returned when an authenticator hasn't verified the user
+	ErrUserNotVerified = errors.New("User is not verified by the authenticator")
+	// ErrSignature is returned when an assertion's signature is invalid
+	ErrSignature = errors.New("Invalid assertion signature")
+)
+
+//
+// RelyingParty identifies the IdP to authenticators. ID is a domain name, which
+// credentials are scoped to, Origin is the origin (scheme, host and port) of the
+// pages running the ceremonies. Its host has to be the ID or a subdomain of it.
+//
+type RelyingParty struct {
+	ID     string
+	Name   string
+	Origin string
+}
+
+//
+// User is the user account a credential is created for. ID (the user handle) is
+// returned by authenticators along with assertions of discoverable credentials.
+//
+type User struct {
+	ID          []byte
+	Name        string
+	DisplayName string
+}
+
+// RelyingPartyEntity describes the relying party in creation options
+type RelyingPartyEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// UserEntity describes the user in creation options
+type UserEntity struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+// CredentialParameters is a type of credentials the relying party accepts
+type CredentialParameters struct {
+	Type      string `json:"type"`
+	Algorithm int    `json:"alg"`
+}
+
+// CredentialDescriptor refers to an existing credential
+type CredentialDescriptor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// AuthenticatorSelection tells which authenticators may be used
+type AuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+//
+// CreationOptions are the options of a registration ceremony
+// (PublicKeyCredentialCreationOptions). Binary values are base64url encoded as in
+// their JSON form, which browsers parse with
+// PublicKeyCredential.parseCreationOptionsFromJSON().
+//
+type CreationOptions struct {
+	RelyingParty           RelyingPartyEntity     `json:"rp"`
+	User                   UserEntity             `json:"user"`
+	Challenge              string                 `json:"challenge"`
+	Parameters             []CredentialParameters `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+//
+// RequestOptions are the options of an authentication ceremony
+// (PublicKeyCredentialRequestOptions) in their JSON form. Allowed credentials are
+// empty for discoverable credentials (passkeys) of any user.
+//
+type RequestOptions struct {
+	Challenge        string                 `json:"challenge"`
+	Timeout          int64                  `json:"timeout"`
+	RelyingPartyID   string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+//
+// AttestationResponse is a credential created by an authenticator in its JSON form
+// (PublicKeyCredential.toJSON())
+//
+type AttestationResponse struct {
+	ID       string `json:"id"`
+	RawID    string `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON"`
+		AttestationObject string `json:"attestationObject"`
+	} `json:"response"`
+}
+
+//
+// AssertionResponse is an assertion of a credential signed by an authenticator in
+// its JSON form (PublicKeyCredential.toJSON())
+//
+type AssertionResponse struct {
+	ID       string `json:"id"`
+	RawID    string `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON"`
+		AuthenticatorData string `json:"authenticatorData"`
+		Signature         string `json:"signature"`
+		UserHandle        string `json:"userHandle"`
+	} `json:"response"`
+}
+
+//
+// Credential is a credential verified by VerifyAttestation. PublicKey is kept in its
+// COSE_Key encoding, see ParsePublicKey.
+//
+type Credential struct {
+	ID        []byte
+	PublicKey []byte
+	SignCount uint32
+}
+
+// clientData is the client data signed by authenticators
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// authenticatorData is the data of authenticator data (WebAuthn section 6.1)
+type authenticatorData struct {
+	rpIDHash     []byte
+	flags        byte
+	signCount    uint32
+	credentialID []byte
+	publicKey    []byte
+}
+
+// CreationOptions returns the options of a registration ceremony for a user. Existing
+// credentials of the user are excluded, so an authenticator isn't registered twice.
+func (rp *RelyingParty) CreationOptions(challenge []byte, user User, exclude [][]byte, timeout time.Duration) *CreationOptions {
+	return &CreationOptions{
+		RelyingParty: RelyingPartyEntity{ID: rp.ID, Name: rp.Name},
+		User: UserEntity{
+			ID:          Encode(user.ID),
+			Name:        user.Name,
+			DisplayName: user.DisplayName,
+		},
+		Challenge: Encode(challenge),
+		Parameters: []CredentialParameters{
+			{Type: "public-key", Algorithm: AlgorithmES256},
+			{Type: "public-key", Algorithm: AlgorithmEdDSA},
+			{Type: "public-key", Algorithm: AlgorithmRS256},
+		},
+		Timeout:            int64(timeout / time.Millisecond),
+		ExcludeCredentials: descriptors(exclude),
+		AuthenticatorSelection: AuthenticatorSelection{
+			ResidentKey:      "preferred",
+			UserVerification: "required",
+		},
+		Attestation: "none",
+	}
+}
+
+// RequestOptions returns the options of an authentication ceremony. Only given
+// credentials are allowed unless none are given.
+func (rp *RelyingParty) RequestOptions(challenge []byte, allow [][]byte, timeout time.Duration) *RequestOptions {
+	return &RequestOptions{
+		Challenge:        Encode(challenge),
+		Timeout:          int64(timeout / time.Millisecond),
+		RelyingPartyID:   rp.ID,
+		AllowCredentials: descriptors(allow),
+		UserVerification: "required",
+	}
+}
+
+// VerifyAttestation verifies the result of a registration ceremony with a given
+// challenge and returns the created credential
+func (rp *RelyingParty) VerifyAttestation(challenge []byte, res AttestationResponse) (*Credential, error) {
+	id, err := responseID(res.ID, res.RawID, res.Type)
+	if err != nil {
+		return nil, err
+	}
+	cdata, err := Decode(res.Response.ClientDataJSON)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	if err = rp.verifyClientData(cdata, CeremonyCreate, challenge); err != nil {
+		return nil, err
+	}
+
+	obj, err := Decode(res.Response.AttestationObject)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	v, err := decodeCBOR(obj)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	m, ok := v.(map[interface{}]interface{})
+	if !ok {
+		return nil, ErrMalformed
+	}
+	if _, ok := m["fmt"].(string); !ok {
+		return nil, ErrMalformed
+	}
+	raw, ok := m["authData"].([]byte)
+	if !ok {
+		return nil, ErrMalformed
+	}
+	data, err := parseAuthenticatorData(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err = rp.verifyAuthenticatorData(data); err != nil {
+		return nil, err
+	}
+	if data.credentialID == nil {
+		return nil, ErrMalformed
+	}
+	if !bytes.Equal(data.credentialID, id) {
+		return nil, ErrCeremony
+	}
+	if _, err = ParsePublicKey(data.publicKey); err != nil {
+		return nil, ErrUnsupportedKey
+	}
+
+	return &Credential{
+		ID:        data.credentialID,
+		PublicKey: data.publicKey,
+		SignCount: data.signCount,
+	}, nil
+}
+
+// VerifyAssertion verifies the result of an authentication ceremony with a given
+// challenge signed with a credential's public key (COSE_Key) and returns the
+// authenticator's signature counter
+func (rp *RelyingParty) VerifyAssertion(challenge []byte, res AssertionResponse, publicKey []byte) (uint32, error) {
+	if _, err := responseID(res.ID, res.RawID, res.Type); err != nil {
+		return 0, err
+	}
+	key, err := ParsePublicKey(publicKey)
+	if err != nil {
+		return 0, ErrUnsupportedKey
+	}
+	cdata, err := Decode(res.Response.ClientDataJSON)
+	if err != nil {
+		return 0, ErrMalformed
+	}
+	if err = rp.verifyClientData(cdata, CeremonyGet, challenge); err != nil {
+		return 0, err
+	}
+	raw, err := Decode(res.Response.AuthenticatorData)
+	if err != nil {
+		return 0, ErrMalformed
+	}
+	sig, err := Decode(res.Response.Signature)
+	if err != nil {
+		return 0, ErrMalformed
+	}
+	data, err := parseAuthenticatorData(raw)
+	if err != nil {
+		return 0, err
+	}
+	if err = rp.verifyAuthenticatorData(data); err != nil {
+		return 0, err
+	}
+
+	// The signature covers the authenticator data and the hash of the client data
+	h := sha256.Sum256(cdata)
+	if !key.Verify(append(append([]byte{}, raw...), h[:]...), sig) {
+		return 0, ErrSignature
+	}
+	return data.signCount, nil
+}
+
+// verifyClientData checks the ceremony type, the challenge and the origin of client data
+func (rp *RelyingParty) verifyClientData(data []byte, ceremony string, challenge []byte) error {
+	var c clientData
+	if err := json.Unmarshal(data, &c); err != nil {
+		return ErrMalformed
+	}
+	if c.Type != ceremony || c.Challenge != Encode(challenge) || c.Origin != rp.Origin || c.CrossOrigin {
+		return ErrCeremony
+	}
+	return nil
+}
+
+// verifyAuthenticatorData checks the relying party ID hash and the user verification
+// flags of authenticator data
+func (rp *RelyingParty) verifyAuthenticatorData(data *authenticatorData) error {
+	h := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(data.rpIDHash, h[:]) {
+		return ErrCeremony
+	}
+	if data.flags&flagUserPresent == 0 || data.flags&flagUserVerified == 0 {
+		return ErrUserNotVerified
+	}
+	return nil
+}
+
+// parseAuthenticatorData decodes authenticator data along with the attested
+// credential data if present
+func parseAuthenticatorData(raw []byte) (*authenticatorData, error) {
+	if len(raw) < authenticatorDataMin {
+		return nil, ErrMalformed
+	}
+	data := &authenticatorData{
+		rpIDHash:  raw[:32],
+		flags:     raw[32],
+		signCount: binary.BigEndian.Uint32(raw[33:37]),
+	}
+	d := &cborDecoder{data: raw, off: authenticatorDataMin}
+
+	if data.flags&flagAttestedData != 0 {
+		if len(raw)-d.off < aaguidSize+2 {
+			return nil, ErrMalformed
+		}
+		d.off += aaguidSize
+		n := int(binary.BigEndian.Uint16(raw[d.off:]))
+		d.off += 2
+		if n == 0 || len(raw)-d.off < n {
+			return nil, ErrMalformed
+		}
+		data.credentialID = raw[d.off : d.off+n]
+		d.off += n
+		start := d.off
+		if _, err := d.value(0); err != nil {
+			return nil, ErrMalformed
+		}
+		data.publicKey = raw[start:d.off]
+	}
+	if data.flags&flagExtensionData != 0 {
+		if _, err := d.value(0); err != nil {
+			return nil, ErrMalformed
+		}
+	}
+	if d.off != len(raw) {
+		return nil, ErrMalformed
+	}
+	return data, nil
+}
+
+// responseID decodes the credential ID of a response
+func responseID(id, rawID, typ string) ([]byte, error) {
+	if typ != "public-key" || (rawID != "" && rawID != id) {
+		return nil, ErrMalformed
+	}
+	b, err := Decode(id)
+	if err != nil || len(b) == 0 {
+		return nil, ErrMalformed
+	}
+	return b, nil
+}
+
+// descriptors returns descriptors of given credential IDs
+func descriptors(ids [][]byte) []CredentialDescriptor {
+	d := []CredentialDescriptor{}
+	for _, id := range ids {
+		d = append(d, CredentialDescriptor{Type: "public-key", ID: Encode(id)})
+	}
+	return d
+}
+
+// Encode encodes binary data with unpadded base64url as in WebAuthn's JSON forms
+func Encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Decode decodes unpadded base64url. Padding is tolerated, since some clients add it.
+func Decode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}